@@ -0,0 +1,283 @@
+// Command loadgen replays a recorded distribution of searches against a
+// running instance and reports throughput, latency percentiles, status codes
+// and the cache hit rate over the run. It is meant for instances whose
+// sources are mocked, to measure the server itself: worker pools, rate
+// limiting and the cache, not the retailers.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type options struct {
+	target      string
+	queries     string
+	country     string
+	params      string
+	apiKey      string
+	duration    time.Duration
+	requests    int
+	concurrency int
+	rate        float64
+	timeout     time.Duration
+	seed        int64
+	jsonOutput  bool
+}
+
+func main() {
+	var opts options
+	flag.StringVar(&opts.target, "target", "http://localhost:8085", "base URL of the instance under test")
+	flag.StringVar(&opts.queries, "queries", "", "recorded queries: JSON lines with query, country and an optional count (server logs work), or one query per line (required)")
+	flag.StringVar(&opts.country, "country", "US", "country for queries recorded without one")
+	flag.StringVar(&opts.params, "params", "", "extra /search query parameters, e.g. limit=20&sort=price")
+	flag.StringVar(&opts.apiKey, "api-key", "", "X-API-Key sent with every request")
+	flag.DurationVar(&opts.duration, "duration", time.Minute, "how long to run")
+	flag.IntVar(&opts.requests, "requests", 0, "stop after this many requests (0: run for -duration)")
+	flag.IntVar(&opts.concurrency, "concurrency", 10, "requests in flight at once")
+	flag.Float64Var(&opts.rate, "rate", 0, "requests per second across all workers (0: as fast as -concurrency allows)")
+	flag.DurationVar(&opts.timeout, "timeout", 60*time.Second, "per-request timeout")
+	flag.Int64Var(&opts.seed, "seed", 0, "random seed for picking queries (0: time-based)")
+	flag.BoolVar(&opts.jsonOutput, "json", false, "print the report as JSON")
+	flag.Parse()
+
+	if err := run(opts); err != nil {
+		fmt.Fprintln(os.Stderr, "loadgen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(opts options) error {
+	if opts.queries == "" {
+		return fmt.Errorf("-queries is required")
+	}
+	if opts.concurrency <= 0 {
+		return fmt.Errorf("-concurrency must be positive")
+	}
+	extra, err := url.ParseQuery(opts.params)
+	if err != nil {
+		return fmt.Errorf("invalid -params: %v", err)
+	}
+	dist, err := loadDistribution(opts.queries, opts.country)
+	if err != nil {
+		return err
+	}
+	if opts.seed == 0 {
+		opts.seed = time.Now().UnixNano()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if opts.requests == 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.duration)
+		defer cancel()
+	}
+
+	client := &http.Client{Timeout: opts.timeout}
+	target := strings.TrimSuffix(opts.target, "/")
+	before, statsErr := cacheStats(client, target)
+	if statsErr != nil {
+		fmt.Fprintln(os.Stderr, "loadgen: cache stats unavailable:", statsErr)
+	}
+
+	fmt.Fprintf(os.Stderr, "loadgen: %d distinct queries, %d workers, target %s\n", len(dist.searches), opts.concurrency, target)
+	rec := newRecorder()
+	start := time.Now()
+	runWorkers(ctx, opts, dist, func(s search) {
+		rec.add(send(ctx, client, target, opts.apiKey, s, extra))
+	})
+	elapsed := time.Since(start)
+
+	report := rec.report(elapsed)
+	if after, err := cacheStats(client, target); err == nil && statsErr == nil {
+		report.Cache = cacheDelta(before, after)
+	}
+	if opts.jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	report.print(os.Stdout)
+	return nil
+}
+
+// search is one recorded query.
+type search struct {
+	Query   string
+	Country string
+}
+
+// distribution picks searches with the frequency they were recorded with.
+type distribution struct {
+	searches []search
+	// cumulative[i] is the total weight of searches[:i+1]
+	cumulative []int
+}
+
+func (d *distribution) pick(rng *rand.Rand) search {
+	n := rng.Intn(d.cumulative[len(d.cumulative)-1])
+	i := sort.SearchInts(d.cumulative, n+1)
+	return d.searches[i]
+}
+
+// recordedSearch is a line of the queries file. Server log lines have the
+// same fields, one per scraper run, so request_id keeps a search from
+// counting once per scraper.
+type recordedSearch struct {
+	Query     string `json:"query"`
+	Country   string `json:"country"`
+	Count     int    `json:"count"`
+	RequestID string `json:"request_id"`
+}
+
+// loadDistribution reads the queries file and weighs each search by how
+// often it was recorded.
+func loadDistribution(path, defaultCountry string) (*distribution, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	weights := map[search]int{}
+	seen := map[string]bool{}
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rec := recordedSearch{Query: line, Count: 1}
+		if strings.HasPrefix(line, "{") {
+			rec = recordedSearch{}
+			if err := json.Unmarshal([]byte(line), &rec); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, n+1, err)
+			}
+			if rec.Query == "" {
+				continue
+			}
+			if rec.Count <= 0 {
+				rec.Count = 1
+			}
+		}
+		if rec.Country == "" {
+			rec.Country = defaultCountry
+		}
+		s := search{Query: rec.Query, Country: strings.ToUpper(rec.Country)}
+		if rec.RequestID != "" {
+			id := rec.RequestID + "\x00" + s.Query + "\x00" + s.Country
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+		}
+		weights[s] += rec.Count
+	}
+	if len(weights) == 0 {
+		return nil, fmt.Errorf("%s holds no queries", path)
+	}
+
+	d := &distribution{}
+	for s := range weights {
+		d.searches = append(d.searches, s)
+	}
+	// A fixed order keeps runs with the same seed identical
+	sort.Slice(d.searches, func(i, j int) bool {
+		if d.searches[i].Country != d.searches[j].Country {
+			return d.searches[i].Country < d.searches[j].Country
+		}
+		return d.searches[i].Query < d.searches[j].Query
+	})
+	total := 0
+	for _, s := range d.searches {
+		total += weights[s]
+		d.cumulative = append(d.cumulative, total)
+	}
+	return d, nil
+}
+
+// runWorkers calls do with picked searches from opts.concurrency workers
+// until ctx is done or opts.requests have been sent, paced to opts.rate.
+func runWorkers(ctx context.Context, opts options, dist *distribution, do func(search)) {
+	jobs := make(chan search)
+	var wg sync.WaitGroup
+	for i := 0; i < opts.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for s := range jobs {
+				do(s)
+			}
+		}()
+	}
+
+	var tick <-chan time.Time
+	if opts.rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / opts.rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	rng := rand.New(rand.NewSource(opts.seed))
+	for sent := 0; opts.requests == 0 || sent < opts.requests; sent++ {
+		if tick != nil {
+			select {
+			case <-ctx.Done():
+			case <-tick:
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+		case jobs <- dist.pick(rng):
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// result is the outcome of one request.
+type result struct {
+	status  int
+	latency time.Duration
+	err     error
+}
+
+func send(ctx context.Context, client *http.Client, target, apiKey string, s search, extra url.Values) result {
+	q := url.Values{}
+	for k, v := range extra {
+		q[k] = v
+	}
+	q.Set("q", s.Query)
+	q.Set("country", s.Country)
+
+	req, err := http.NewRequestWithContext(context.WithoutCancel(ctx), http.MethodGet, target+"/search?"+q.Encode(), nil)
+	if err != nil {
+		return result{err: err}
+	}
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return result{latency: time.Since(start), err: err}
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return result{status: resp.StatusCode, latency: time.Since(start)}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// recorder collects request results from every worker.
+type recorder struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+	statuses  map[int]int
+	errors    map[string]int
+}
+
+func newRecorder() *recorder {
+	return &recorder{statuses: map[int]int{}, errors: map[string]int{}}
+}
+
+func (r *recorder) add(res result) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.latencies = append(r.latencies, res.latency)
+	if res.err != nil {
+		r.errors[res.err.Error()]++
+		return
+	}
+	r.statuses[res.status]++
+}
+
+// Report is what a run measured.
+type Report struct {
+	Requests int     `json:"requests"`
+	Duration string  `json:"duration"`
+	Rate     float64 `json:"requests_per_second"`
+	// Statuses counts responses by HTTP status; Errors counts requests that
+	// got no response, by error
+	Statuses map[string]int `json:"statuses"`
+	Errors   map[string]int `json:"errors,omitempty"`
+	// RateLimited is how many requests were turned away with 429
+	RateLimited int               `json:"rate_limited"`
+	Latency     map[string]string `json:"latency"`
+	Cache       *CacheReport      `json:"cache,omitempty"`
+}
+
+// CacheReport is the change in the instance's cache counters over the run.
+type CacheReport struct {
+	Hits     int64   `json:"hits"`
+	Misses   int64   `json:"misses"`
+	HitRatio float64 `json:"hit_ratio"`
+	Sets     int64   `json:"sets"`
+}
+
+// percentiles reported for latency
+var percentiles = []float64{50, 90, 95, 99}
+
+func (r *recorder) report(elapsed time.Duration) Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report := Report{
+		Requests: len(r.latencies),
+		Duration: elapsed.Round(time.Millisecond).String(),
+		Statuses: map[string]int{},
+		Errors:   r.errors,
+		Latency:  map[string]string{},
+	}
+	if elapsed > 0 {
+		report.Rate = math.Round(float64(report.Requests)/elapsed.Seconds()*100) / 100
+	}
+	for status, n := range r.statuses {
+		report.Statuses[strconv.Itoa(status)] = n
+	}
+	report.RateLimited = r.statuses[http.StatusTooManyRequests]
+
+	sorted := append([]time.Duration(nil), r.latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	if len(sorted) > 0 {
+		for _, p := range percentiles {
+			report.Latency[fmt.Sprintf("p%g", p)] = percentile(sorted, p).Round(time.Millisecond).String()
+		}
+		report.Latency["max"] = sorted[len(sorted)-1].Round(time.Millisecond).String()
+	}
+	return report
+}
+
+// percentile returns the p-th percentile of sorted by the nearest-rank
+// method.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func (r Report) print(w io.Writer) {
+	fmt.Fprintf(w, "Requests:      %d in %s (%.2f/s)\n", r.Requests, r.Duration, r.Rate)
+
+	codes := make([]string, 0, len(r.Statuses))
+	for code := range r.Statuses {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	fmt.Fprintf(w, "Statuses:     ")
+	for _, code := range codes {
+		fmt.Fprintf(w, " %s=%d", code, r.Statuses[code])
+	}
+	fmt.Fprintln(w)
+	if r.RateLimited > 0 {
+		fmt.Fprintf(w, "Rate limited:  %d\n", r.RateLimited)
+	}
+	for msg, n := range r.Errors {
+		fmt.Fprintf(w, "Error:         %d x %s\n", n, msg)
+	}
+
+	if len(r.Latency) > 0 {
+		fmt.Fprintf(w, "Latency:      ")
+		for _, p := range percentiles {
+			key := fmt.Sprintf("p%g", p)
+			fmt.Fprintf(w, " %s=%s", key, r.Latency[key])
+		}
+		fmt.Fprintf(w, " max=%s\n", r.Latency["max"])
+	}
+	if r.Cache != nil {
+		fmt.Fprintf(w, "Cache:         %d hits, %d misses (hit ratio %.2f%%), %d sets\n",
+			r.Cache.Hits, r.Cache.Misses, r.Cache.HitRatio*100, r.Cache.Sets)
+	}
+}
+
+// cacheCounters are the fields of /cache/stats the report uses.
+type cacheCounters struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+	Sets   int64 `json:"sets"`
+}
+
+func cacheStats(client *http.Client, target string) (cacheCounters, error) {
+	var stats cacheCounters
+	resp, err := client.Get(target + "/cache/stats")
+	if err != nil {
+		return stats, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return stats, fmt.Errorf("/cache/stats returned status %d", resp.StatusCode)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&stats)
+	return stats, err
+}
+
+func cacheDelta(before, after cacheCounters) *CacheReport {
+	c := &CacheReport{
+		Hits:   after.Hits - before.Hits,
+		Misses: after.Misses - before.Misses,
+		Sets:   after.Sets - before.Sets,
+	}
+	if lookups := c.Hits + c.Misses; lookups > 0 {
+		c.HitRatio = math.Round(float64(c.Hits)/float64(lookups)*10000) / 10000
+	}
+	return c
+}
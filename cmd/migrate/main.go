@@ -0,0 +1,143 @@
+// Command migrate manages the SQL storage of the catalog, price history and
+// alerts: it applies the schema migrations, lists which are applied, and
+// imports a store's existing bbolt file. It reads the same configuration as
+// the server (config.yaml, CONFIG_FILE and the STORAGE_* variables).
+//
+//	migrate up
+//	migrate status
+//	migrate import -store catalog -from /data/catalog.db
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+	"price-comparison-api/internal/alerts"
+	"price-comparison-api/internal/catalog"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/history"
+	"price-comparison-api/internal/storage"
+)
+
+// stores are the buckets of each store import can copy.
+var stores = map[string][][]byte{
+	catalog.StoreName: catalog.Buckets,
+	history.StoreName: history.Buckets,
+	alerts.StoreName:  alerts.Buckets,
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: migrate up | status | import -store catalog|history|alerts -from <bolt file>")
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	godotenv.Load()
+	if err := run(flag.Arg(0), flag.Args()[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "migrate:", err)
+		os.Exit(1)
+	}
+}
+
+func run(command string, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if cfg.Storage.Driver == config.StorageBolt {
+		return fmt.Errorf("STORAGE_DRIVER is bolt; set it to %s or %s", config.StoragePostgres, config.StorageSQLite)
+	}
+	backend, err := storage.Connect(cfg.Storage)
+	if err != nil {
+		return err
+	}
+	defer backend.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	switch command {
+	case "up":
+		applied, err := backend.Migrate(ctx)
+		for _, version := range applied {
+			fmt.Println("applied", version)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("schema is up to date")
+		}
+		return nil
+	case "status":
+		list, err := backend.Migrations(ctx)
+		if err != nil {
+			return err
+		}
+		for _, m := range list {
+			applied := "pending"
+			if m.AppliedAt != nil {
+				applied = m.AppliedAt.Local().Format(time.RFC3339)
+			}
+			fmt.Printf("%-30s %s\n", m.Version, applied)
+		}
+		return nil
+	case "import":
+		return importBolt(ctx, backend, args)
+	default:
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
+
+// importBolt copies a store's bbolt file into the SQL database, after
+// bringing its schema up to date.
+func importBolt(ctx context.Context, backend *storage.Backend, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	store := fs.String("store", "", "store to import: catalog, history or alerts (required)")
+	from := fs.String("from", "", "the store's bbolt file (required)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	buckets, ok := stores[*store]
+	if !ok {
+		return fmt.Errorf("-store must be catalog, history or alerts")
+	}
+	if *from == "" {
+		return fmt.Errorf("-from is required")
+	}
+	if _, err := os.Stat(*from); err != nil {
+		return err
+	}
+
+	if _, err := backend.Migrate(ctx); err != nil {
+		return err
+	}
+	bolt, err := storage.Connect(config.StorageConfig{Driver: config.StorageBolt})
+	if err != nil {
+		return err
+	}
+	src, err := bolt.Open(*store, *from, buckets...)
+	if err != nil {
+		return fmt.Errorf("opening %s: %v", *from, err)
+	}
+	defer src.Close()
+	dst, err := backend.Open(*store, "", buckets...)
+	if err != nil {
+		return err
+	}
+
+	copied, err := storage.Copy(src, dst, buckets...)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("imported %d %s records from %s\n", copied, *store, *from)
+	return nil
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"price-comparison-api/internal/apierr"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/querynorm"
+	"price-comparison-api/internal/scrapers"
+	"price-comparison-api/internal/services"
+	"price-comparison-api/pkg/cache"
+)
+
+type scraperToggleRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+type maintenanceRequest struct {
+	Message string `json:"message"`
+}
+
+type rateLimitOverrideRequest struct {
+	RequestsPerSecond float64 `json:"requests_per_second" binding:"required,gt=0"`
+	Burst             int     `json:"burst" binding:"required,gt=0"`
+}
+
+// adminAuthMiddleware only lets through requests carrying one of the
+// configured API keys, either as X-API-Key or "Authorization: Bearer <key>".
+func adminAuthMiddleware(apiKeys []string) gin.HandlerFunc {
+	if len(apiKeys) == 0 {
+		log.Info().Msg("No admin API keys configured (ADMIN_API_KEYS), /admin routes are locked")
+	}
+
+	return func(c *gin.Context) {
+		key := requestAPIKey(c)
+		if key == "" || !validAPIKey(key, apiKeys) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, models.ErrorResponse{
+				Error:   "unauthorized",
+				Code:    http.StatusUnauthorized,
+				Message: "a valid admin API key is required",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// requestAPIKey reads the caller's key from X-API-Key or an
+// "Authorization: Bearer <key>" header.
+func requestAPIKey(c *gin.Context) string {
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key
+	}
+	if auth := c.GetHeader("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+	return ""
+}
+
+func validAPIKey(key string, apiKeys []string) bool {
+	valid := false
+	for _, k := range apiKeys {
+		// Check every key so timing doesn't reveal which one matched
+		if subtle.ConstantTimeCompare([]byte(key), []byte(k)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+func registerAdminRoutes(admin *gin.RouterGroup, cfg *config.Config, searchService *services.SearchService, redisCache *cache.RedisCache, maintenance *maintenanceMode) {
+	// Cache debug endpoint
+	admin.GET("/cache/debug", func(c *gin.Context) {
+		if redisCache == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error": "cache not available",
+			})
+			return
+		}
+
+		keys := redisCache.GetAllKeys()
+
+		// Get detailed info for each key
+		keyDetails := make([]gin.H, 0, len(keys))
+		for _, key := range keys {
+			ttl := redisCache.GetKeyTTL(key)
+			keyDetails = append(keyDetails, gin.H{
+				"key":         key,
+				"ttl_seconds": int(ttl.Seconds()),
+				"expires_in":  ttl.String(),
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"total_keys":  len(keys),
+			"cache_keys":  keyDetails,
+			"cache_stats": redisCache.GetStats(),
+			"debug_info": gin.H{
+				"redis_available": redisCache.IsAvailable(),
+				"timestamp":       time.Now().Format(time.RFC3339),
+			},
+		})
+	})
+
+	// Cache flush endpoint (for testing)
+	admin.DELETE("/cache/flush", func(c *gin.Context) {
+		if redisCache == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error": "cache not available",
+			})
+			return
+		}
+
+		if err := redisCache.FlushCache(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "failed to flush cache",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":   "cache flushed successfully",
+			"timestamp": time.Now().Format(time.RFC3339),
+		})
+	})
+
+	// Remove one query's keys, or the keys matching a pattern, e.g.
+	// search:iphone*, leaving the rest of the cache in place
+	admin.DELETE("/cache/keys", func(c *gin.Context) {
+		if redisCache == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error": "cache not available",
+			})
+			return
+		}
+
+		pattern, query := c.Query("pattern"), c.Query("query")
+		switch {
+		case query != "" && pattern != "":
+			writeError(c, apierr.Validation("invalid_request", "send either query (and country) or pattern, not both"))
+			return
+		case query != "":
+			// The keys are named like the searches cache them, braces
+			// included in cluster mode
+			country := c.DefaultQuery("country", cfg.Server.DefaultCountry)
+			pattern = redisCache.GenerateQueryPattern(querynorm.Normalize(query), country)
+		case c.Query("country") != "":
+			writeError(c, apierr.Validation("invalid_request", "country needs a query"))
+			return
+		case pattern == "":
+			writeError(c, apierr.Validation("invalid_request", "query or pattern is required, e.g. query=iphone&country=US"))
+			return
+		}
+
+		deleted, err := redisCache.DeleteKeys(c.Request.Context(), pattern)
+		if errors.Is(err, apierr.ErrValidation) {
+			writeError(c, err)
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "failed to delete cache keys",
+				"details": err.Error(),
+				"deleted": deleted,
+			})
+			return
+		}
+
+		log.Info().Str("pattern", pattern).Int("deleted", deleted).Msg("Cache keys deleted")
+		c.JSON(http.StatusOK, gin.H{
+			"pattern":   pattern,
+			"deleted":   deleted,
+			"timestamp": time.Now().Format(time.RFC3339),
+		})
+	})
+
+	// Override the rate limit for a single IP
+	admin.PUT("/rate-limits/:ip", func(c *gin.Context) {
+		var req rateLimitOverrideRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			writeError(c, apierr.Validation("invalid_request", "body must be {\"requests_per_second\": >0, \"burst\": >0}").WithDetails(err.Error()))
+			return
+		}
+
+		ip := c.Param("ip")
+		setRateLimitOverride(ip, config.RateLimitConfig{
+			RequestsPerSecond: req.RequestsPerSecond,
+			Burst:             req.Burst,
+		})
+
+		c.JSON(http.StatusOK, gin.H{
+			"ip":               ip,
+			"limit_per_second": req.RequestsPerSecond,
+			"burst_capacity":   req.Burst,
+		})
+	})
+
+	// Drop an override so the IP goes back to the default limit
+	admin.DELETE("/rate-limits/:ip", func(c *gin.Context) {
+		ip := c.Param("ip")
+		clearRateLimitOverride(ip)
+		c.JSON(http.StatusOK, gin.H{
+			"ip":               ip,
+			"limit_per_second": cfg.RateLimit.RequestsPerSecond,
+			"burst_capacity":   cfg.RateLimit.Burst,
+		})
+	})
+
+	admin.GET("/rate-limits", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"default": gin.H{
+				"limit_per_second": cfg.RateLimit.RequestsPerSecond,
+				"burst_capacity":   cfg.RateLimit.Burst,
+			},
+			"overrides": rateLimitOverrides(),
+		})
+	})
+
+	admin.GET("/maintenance", func(c *gin.Context) {
+		c.JSON(http.StatusOK, maintenance.status())
+	})
+
+	// Switch maintenance mode on or off; "on" accepts an optional message
+	admin.POST("/maintenance/:state", func(c *gin.Context) {
+		var enabled bool
+		switch c.Param("state") {
+		case "on":
+			enabled = true
+		case "off":
+			enabled = false
+		default:
+			writeError(c, apierr.Validation("invalid_request", "maintenance state must be on or off"))
+			return
+		}
+
+		var req maintenanceRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				writeError(c, apierr.Validation("invalid_request", "body must be {\"message\": \"...\"}").WithDetails(err.Error()))
+				return
+			}
+		}
+
+		maintenance.set(enabled, req.Message)
+		log.Info().Msgf("Maintenance mode enabled=%v", enabled)
+		c.JSON(http.StatusOK, maintenance.status())
+	})
+
+	// Shadow mode stats and recorded diffs against the live results
+	admin.GET("/shadow", func(c *gin.Context) {
+		shadow := searchService.Shadow()
+		if shadow == nil {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "shadow_disabled",
+				Code:    http.StatusNotFound,
+				Message: "shadow mode is not enabled (SHADOW_ENABLED)",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"stats": shadow.Stats(),
+			"diffs": shadow.Diffs(),
+		})
+	})
+
+	admin.DELETE("/shadow", func(c *gin.Context) {
+		shadow := searchService.Shadow()
+		if shadow == nil {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "shadow_disabled",
+				Code:    http.StatusNotFound,
+				Message: "shadow mode is not enabled (SHADOW_ENABLED)",
+			})
+			return
+		}
+
+		shadow.Reset()
+		c.JSON(http.StatusOK, gin.H{
+			"message": "shadow diffs cleared",
+		})
+	})
+
+	// How users narrow their searches
+	admin.GET("/sessions/stats", func(c *gin.Context) {
+		c.JSON(http.StatusOK, searchService.Sessions().Stats())
+	})
+
+	// List scraper on/off state, the scrapers skipped for a maintenance
+	// window now with when it ends, and each retailer's circuit breaker
+	admin.GET("/scrapers", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"scrapers":      searchService.ScraperStatus(),
+			"maintenance":   searchService.Maintenance(),
+			"circuits":      searchService.Circuits(),
+			"official_apis": searchService.OfficialAPIs(),
+		})
+	})
+
+	// The last structure fingerprint of each retailer's search and product
+	// pages, layout changes first
+	admin.GET("/fingerprints", func(c *gin.Context) {
+		list := scrapers.Fingerprints()
+		c.JSON(http.StatusOK, gin.H{
+			"fingerprints": list,
+			"total":        len(list),
+		})
+	})
+
+	// The CSS selectors each scraper reads search results with, and the
+	// override files they were loaded from
+	admin.GET("/selectors", func(c *gin.Context) {
+		c.JSON(http.StatusOK, scrapers.Selectors())
+	})
+
+	// Re-read the selector override files; an invalid file is rejected and
+	// the selectors in use are kept
+	admin.POST("/selectors/reload", func(c *gin.Context) {
+		status, err := scrapers.ReloadSelectors()
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, status)
+	})
+
+	// What serving a new country takes: covering scrapers, expected currency
+	// and locale, and a starter config (format=yaml returns just the config)
+	admin.GET("/countries/:country/onboarding", func(c *gin.Context) {
+		report, err := searchService.Onboarding(c.Param("country"))
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		if c.Query("format") == "yaml" {
+			c.Data(http.StatusOK, "application/yaml; charset=utf-8", []byte(report.Config))
+			return
+		}
+		c.JSON(http.StatusOK, report)
+	})
+
+	// Enable or disable a scraper without redeploying
+	admin.PATCH("/scrapers/:name", func(c *gin.Context) {
+		var req scraperToggleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			writeError(c, apierr.Validation("invalid_request", "body must be {\"enabled\": true|false}").WithDetails(err.Error()))
+			return
+		}
+
+		name := c.Param("name")
+		if err := searchService.SetScraperEnabled(name, *req.Enabled); err != nil {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "unknown_scraper",
+				Code:    http.StatusNotFound,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"scraper": name,
+			"enabled": *req.Enabled,
+		})
+	})
+
+	// Daily exchange rates used to convert price history; days missed while
+	// the server was down can be backfilled
+	admin.GET("/currency/rates", func(c *gin.Context) {
+		store := searchService.History()
+		if store == nil {
+			c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{
+				Error:   "history_unavailable",
+				Code:    http.StatusServiceUnavailable,
+				Message: "price history is disabled (HISTORY_ENABLED)",
+			})
+			return
+		}
+		book, err := store.Rates()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "history_error",
+				Code:    http.StatusInternalServerError,
+				Message: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"days":    book.Days(),
+			"current": cfg.Currency.Rates,
+		})
+	})
+
+	admin.PUT("/currency/rates/:day", func(c *gin.Context) {
+		store := searchService.History()
+		if store == nil {
+			c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{
+				Error:   "history_unavailable",
+				Code:    http.StatusServiceUnavailable,
+				Message: "price history is disabled (HISTORY_ENABLED)",
+			})
+			return
+		}
+		day, err := store.Day(c.Param("day"))
+		if err != nil {
+			writeError(c, apierr.Validation("invalid_day", "day must be YYYY-MM-DD"))
+			return
+		}
+		var rates map[string]float64
+		if err := c.ShouldBindJSON(&rates); err != nil || len(rates) == 0 {
+			writeError(c, apierr.Validation("invalid_request", "body must be {\"EUR\": 0.92, ...} in units per US dollar"))
+			return
+		}
+		for code, rate := range rates {
+			if rate <= 0 {
+				writeError(c, apierr.Validation("invalid_rates", "rate for "+code+" must be positive"))
+				return
+			}
+		}
+		if err := store.RecordRates(day, rates); err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "history_error",
+				Code:    http.StatusInternalServerError,
+				Message: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"day":   c.Param("day"),
+			"rates": rates,
+		})
+	})
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"price-comparison-api/internal/alerts"
+	"price-comparison-api/internal/apierr"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/profiles"
+)
+
+func registerAlertRoutes(r *gin.Engine, alertService *alerts.Service, stockChecker *alerts.StockChecker, profileStore *profiles.Store, cfg *config.Config) {
+	group := r.Group("/alerts", func(c *gin.Context) {
+		if alertService == nil {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, models.ErrorResponse{
+				Error:   "alerts_unavailable",
+				Code:    http.StatusServiceUnavailable,
+				Message: "alerts are disabled (ALERTS_ENABLED, HISTORY_ENABLED)",
+			})
+			return
+		}
+		c.Next()
+	})
+
+	// Create an alert on a product seen in a previous search
+	group.POST("", func(c *gin.Context) {
+		var opts alerts.Options
+		if err := c.ShouldBindJSON(&opts); err != nil {
+			writeError(c, apierr.Validation("invalid_request", "invalid alert request").WithDetails(err.Error()))
+			return
+		}
+		if opts.TimeZone == "" {
+			opts.TimeZone = callerTimeZone(c, profileStore, cfg)
+		}
+		opts.Owner = callerID(c, cfg.Profiles)
+
+		alert, err := alertService.Create(opts)
+		if err != nil {
+			writeError(c, apierr.Validation("invalid_alert", err.Error()))
+			return
+		}
+		c.JSON(http.StatusCreated, alert.Redacted())
+	})
+
+	// List the caller's alerts, optionally filtered; status=triggered lists
+	// the alerts that fired, most recent first
+	group.GET("", callerMiddleware(cfg.Profiles), func(c *gin.Context) {
+		var filter alerts.Filter
+		if err := c.ShouldBindQuery(&filter); err != nil {
+			writeError(c, apierr.Validation("invalid_request", "invalid alert filter").WithDetails(err.Error()))
+			return
+		}
+		filter.Owner = c.GetString("caller_id")
+		list, err := alertService.Find(filter)
+		if err != nil {
+			writeError(c, apierr.Validation("invalid_filter", err.Error()))
+			return
+		}
+
+		page := 1
+		if p, err := strconv.Atoi(c.Query("page")); err == nil && p > 0 {
+			page = p
+		}
+		limit := 50
+		if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 {
+			limit = l
+		}
+		if limit > 200 {
+			limit = 200
+		}
+
+		total := len(list)
+		start := (page - 1) * limit
+		if start > total {
+			start = total
+		}
+		end := start + limit
+		if end > total {
+			end = total
+		}
+		visible := list[start:end]
+		for i := range visible {
+			visible[i] = visible[i].Redacted()
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"alerts":      visible,
+			"total":       total,
+			"page":        page,
+			"limit":       limit,
+			"total_pages": int(math.Ceil(float64(total) / float64(limit))),
+			"types":       alerts.Types,
+			"statuses":    alerts.Statuses,
+		})
+	})
+
+	// Pause or resume every one of the caller's alerts matching a filter
+	group.PATCH("/bulk", callerMiddleware(cfg.Profiles), func(c *gin.Context) {
+		var req struct {
+			Action string        `json:"action" binding:"required"`
+			Filter alerts.Filter `json:"filter"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			writeError(c, apierr.Validation("invalid_request", "invalid bulk request").WithDetails(err.Error()))
+			return
+		}
+
+		var paused bool
+		switch req.Action {
+		case "pause":
+			paused = true
+		case "resume":
+		default:
+			writeError(c, apierr.Validation("invalid_action", "action must be pause or resume"))
+			return
+		}
+
+		req.Filter.Owner = c.GetString("caller_id")
+		updated, err := alertService.SetPaused(req.Filter, paused)
+		if err != nil {
+			writeError(c, apierr.Validation("invalid_filter", err.Error()))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"action": req.Action, "updated": updated})
+	})
+
+	// Delete every one of the caller's alerts matching the filter in the
+	// query string
+	group.DELETE("/bulk", callerMiddleware(cfg.Profiles), func(c *gin.Context) {
+		var filter alerts.Filter
+		if err := c.ShouldBindQuery(&filter); err != nil {
+			writeError(c, apierr.Validation("invalid_request", "invalid alert filter").WithDetails(err.Error()))
+			return
+		}
+		filter.Owner = c.GetString("caller_id")
+		deleted, err := alertService.DeleteMatching(filter)
+		if err != nil {
+			writeError(c, apierr.Validation("invalid_filter", err.Error()))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"deleted": deleted})
+	})
+
+	// Recent events across the caller's alerts
+	group.GET("/events", callerMiddleware(cfg.Profiles), func(c *gin.Context) {
+		limit, _ := strconv.Atoi(c.Query("limit"))
+		events, err := alertService.Events("", c.GetString("caller_id"), "", limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "alerts_error",
+				Code:    http.StatusInternalServerError,
+				Message: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"events": events})
+	})
+
+	// Long poll for the events of the caller's alerts after a cursor. Without
+	// since it resumes after the last acknowledged cursor, so events are
+	// delivered at least once
+	poll := group.Group("/poll", callerMiddleware(cfg.Profiles))
+	poll.GET("", func(c *gin.Context) {
+		wait := alerts.DefaultPollWait
+		if w := c.Query("wait"); w != "" {
+			seconds, err := strconv.Atoi(w)
+			if err != nil || seconds < 0 {
+				writeError(c, apierr.Validation("invalid_wait", "wait must be a number of seconds"))
+				return
+			}
+			wait = time.Duration(seconds) * time.Second
+		}
+		if wait > alerts.MaxPollWait {
+			wait = alerts.MaxPollWait
+		}
+		limit, _ := strconv.Atoi(c.Query("limit"))
+
+		result, err := alertService.Poll(c.Request.Context(), c.GetString("caller_id"), c.Query("since"), limit, wait)
+		switch {
+		case errors.Is(err, alerts.ErrInvalidCursor):
+			writeError(c, apierr.Validation("invalid_cursor", err.Error()))
+			return
+		case c.Request.Context().Err() != nil:
+			// The client went away
+			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "alerts_error",
+				Code:    http.StatusInternalServerError,
+				Message: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	})
+
+	// Acknowledge the events up to a cursor, so the next poll without since
+	// starts after it
+	poll.POST("/ack", func(c *gin.Context) {
+		var req struct {
+			Cursor string `json:"cursor" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			writeError(c, apierr.Validation("invalid_request", "invalid acknowledgement").WithDetails(err.Error()))
+			return
+		}
+		acked, err := alertService.Ack(c.GetString("caller_id"), req.Cursor)
+		switch {
+		case errors.Is(err, alerts.ErrInvalidCursor):
+			writeError(c, apierr.Validation("invalid_cursor", err.Error()))
+			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "alerts_error",
+				Code:    http.StatusInternalServerError,
+				Message: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"acked": acked})
+	})
+
+	// Out-of-stock products whose detail pages are being re-checked
+	group.GET("/stock-checks", func(c *gin.Context) {
+		if stockChecker == nil {
+			c.JSON(http.StatusOK, gin.H{"enabled": false, "checks": []alerts.StockCheck{}})
+			return
+		}
+		checks := stockChecker.Checks()
+		c.JSON(http.StatusOK, gin.H{
+			"enabled": true,
+			"checks":  checks,
+			"total":   len(checks),
+		})
+	})
+
+	group.GET("/:id", callerMiddleware(cfg.Profiles), func(c *gin.Context) {
+		alert, err := ownAlert(alertService, c.Param("id"), c.GetString("caller_id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "alert_not_found",
+				Code:    http.StatusNotFound,
+				Message: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, alert.Redacted())
+	})
+
+	group.GET("/:id/events", callerMiddleware(cfg.Profiles), func(c *gin.Context) {
+		if _, err := ownAlert(alertService, c.Param("id"), c.GetString("caller_id")); err != nil {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "alert_not_found",
+				Code:    http.StatusNotFound,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		limit, _ := strconv.Atoi(c.Query("limit"))
+		events, err := alertService.Events("", c.GetString("caller_id"), c.Param("id"), limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "alerts_error",
+				Code:    http.StatusInternalServerError,
+				Message: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"events": events})
+	})
+
+	group.DELETE("/:id", callerMiddleware(cfg.Profiles), func(c *gin.Context) {
+		if _, err := ownAlert(alertService, c.Param("id"), c.GetString("caller_id")); err != nil {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "alert_not_found",
+				Code:    http.StatusNotFound,
+				Message: err.Error(),
+			})
+			return
+		}
+		if err := alertService.Delete(c.Param("id")); err != nil {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "alert_not_found",
+				Code:    http.StatusNotFound,
+				Message: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "alert deleted"})
+	})
+}
+
+// publicAlert looks up an alert outside any organization; organization
+// alerts are only reachable through /orgs.
+func publicAlert(alertService *alerts.Service, id string) (*alerts.Alert, error) {
+	alert, err := alertService.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if alert.OrgID != "" {
+		return nil, alerts.ErrNotFound
+	}
+	return alert, nil
+}
+
+// ownAlert looks up one of the caller's alerts outside any organization;
+// other callers' alerts are reported as not found.
+func ownAlert(alertService *alerts.Service, id, caller string) (*alerts.Alert, error) {
+	alert, err := publicAlert(alertService, id)
+	if err != nil {
+		return nil, err
+	}
+	if alert.Owner != caller {
+		return nil, alerts.ErrNotFound
+	}
+	return alert, nil
+}
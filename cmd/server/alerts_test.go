@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// alertRequest sends a request as the caller with apiKey and decodes the
+// JSON response into out, when given.
+func alertRequest(t *testing.T, r *gin.Engine, method, path, body, apiKey string, out interface{}) int {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", apiKey)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if out != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: %v: %s", method, path, err, w.Body)
+		}
+	}
+	return w.Code
+}
+
+func TestAlertChangesStayWithTheCaller(t *testing.T) {
+	r := contractApp(t).router
+	const callerA, callerB = "caller-a-key", "caller-b-key"
+
+	create := func(apiKey string) string {
+		var alert struct {
+			ID string `json:"id"`
+		}
+		body := `{"type":"launch","query":"usb c cable","country":"US"}`
+		if code := alertRequest(t, r, "POST", "/alerts", body, apiKey, &alert); code != http.StatusCreated {
+			t.Fatalf("create alert: status %d", code)
+		}
+		return alert.ID
+	}
+	alertA := create(callerA)
+	create(callerB)
+
+	// checkA fails unless caller A's alert is still there and not paused
+	checkA := func(step string) {
+		t.Helper()
+		var alert struct {
+			Paused bool `json:"paused"`
+		}
+		if code := alertRequest(t, r, "GET", "/alerts/"+alertA, "", callerA, &alert); code != http.StatusOK {
+			t.Fatalf("after %s: caller A's alert: status %d", step, code)
+		}
+		if alert.Paused {
+			t.Fatalf("after %s: caller A's alert is paused", step)
+		}
+	}
+
+	var paused struct {
+		Updated int `json:"updated"`
+	}
+	if code := alertRequest(t, r, "PATCH", "/alerts/bulk", `{"action":"pause","filter":{"country":"US"}}`, callerB, &paused); code != http.StatusOK {
+		t.Fatalf("bulk pause: status %d", code)
+	}
+	if paused.Updated != 1 {
+		t.Errorf("bulk pause updated %d alerts, want caller B's 1", paused.Updated)
+	}
+	checkA("caller B's bulk pause")
+
+	if code := alertRequest(t, r, "DELETE", "/alerts/"+alertA, "", callerB, nil); code != http.StatusNotFound {
+		t.Errorf("caller B deleting caller A's alert: status %d, want 404", code)
+	}
+	checkA("caller B's delete")
+
+	var deleted struct {
+		Deleted int `json:"deleted"`
+	}
+	if code := alertRequest(t, r, "DELETE", "/alerts/bulk?country=US", "", callerB, &deleted); code != http.StatusOK {
+		t.Fatalf("bulk delete: status %d", code)
+	}
+	if deleted.Deleted != 1 {
+		t.Errorf("bulk delete removed %d alerts, want caller B's 1", deleted.Deleted)
+	}
+	checkA("caller B's bulk delete")
+}
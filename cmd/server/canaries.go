@@ -0,0 +1,21 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"price-comparison-api/internal/services"
+)
+
+func registerCanaryRoutes(r *gin.Engine, admin *gin.RouterGroup, canaries *services.CanaryRunner) {
+	// The last canary search of each retailer, for monitoring to catch
+	// selectors that stopped matching
+	r.GET("/health/scrapers", func(c *gin.Context) {
+		c.JSON(http.StatusOK, canaries.Report())
+	})
+
+	// Run every retailer's canary now and wait for the results
+	admin.POST("/canaries/run", func(c *gin.Context) {
+		c.JSON(http.StatusOK, canaries.Run(c.Request.Context()))
+	})
+}
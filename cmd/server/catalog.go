@@ -0,0 +1,198 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"price-comparison-api/internal/apierr"
+	"price-comparison-api/internal/catalog"
+	"price-comparison-api/internal/models"
+)
+
+func registerCatalogRoutes(r *gin.Engine, store *catalog.Store) {
+	// Responses are JSON:API documents for requests that accept them
+	group := r.Group("/catalog", jsonAPINegotiation(), func(c *gin.Context) {
+		if store == nil {
+			body := models.ErrorResponse{
+				Error:   "catalog_unavailable",
+				Code:    http.StatusServiceUnavailable,
+				Message: "the product catalog is disabled (CATALOG_ENABLED)",
+			}
+			if wantsJSONAPI(c) {
+				writeJSONAPIError(c, http.StatusServiceUnavailable, body)
+				c.Abort()
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, body)
+			return
+		}
+		c.Next()
+	})
+
+	// Canonical products, most recently seen first
+	group.GET("/products", func(c *gin.Context) {
+		var filter catalog.Filter
+		if err := c.ShouldBindQuery(&filter); err != nil {
+			catalogError(c, apierr.Validation("invalid_request", "invalid catalog filter").WithDetails(err.Error()))
+			return
+		}
+		var include map[string]bool
+		if wantsJSONAPI(c) {
+			var ok bool
+			if include, ok = jsonAPIIncludes(c, []string{"offers", "offers.retailer"}, "offers", "offers.retailer"); !ok {
+				return
+			}
+		}
+		list, err := store.Find(filter)
+		if err != nil {
+			catalogError(c, err)
+			return
+		}
+
+		page := 1
+		if p, err := strconv.Atoi(c.Query("page")); err == nil && p > 0 {
+			page = p
+		}
+		limit := 50
+		if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 {
+			limit = l
+		}
+		if limit > 200 {
+			limit = 200
+		}
+
+		total := len(list)
+		start := (page - 1) * limit
+		if start > total {
+			start = total
+		}
+		end := start + limit
+		if end > total {
+			end = total
+		}
+
+		products, offers := store.Counts()
+		totalPages := int(math.Ceil(float64(total) / float64(limit)))
+		if wantsJSONAPI(c) {
+			data := make([]jsonAPIResource, 0, end-start)
+			for _, p := range list[start:end] {
+				data = append(data, productResource(p))
+			}
+			var included jsonAPIIncluded
+			if len(include) > 0 {
+				pageOffers, err := catalogOffers(store, list[start:end])
+				if err != nil {
+					catalogError(c, err)
+					return
+				}
+				included.addOffers(pageOffers, include)
+			}
+			writeJSONAPI(c, http.StatusOK, jsonAPIDocument{
+				Data:     data,
+				Included: included.resources,
+				Meta: gin.H{
+					"total":          total,
+					"page":           page,
+					"limit":          limit,
+					"total_pages":    totalPages,
+					"catalog_size":   products,
+					"catalog_offers": offers,
+				},
+				Links: pageLinks(c, page, limit, totalPages),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"products":       list[start:end],
+			"total":          total,
+			"page":           page,
+			"limit":          limit,
+			"total_pages":    totalPages,
+			"catalog_size":   products,
+			"catalog_offers": offers,
+		})
+	})
+
+	// A product with every retailer's offer, cheapest in-stock first
+	group.GET("/products/:id", func(c *gin.Context) {
+		var include map[string]bool
+		if wantsJSONAPI(c) {
+			var ok bool
+			if include, ok = jsonAPIIncludes(c, []string{"offers", "offers.retailer"}, "offers", "offers.retailer"); !ok {
+				return
+			}
+		}
+		product, err := store.Get(c.Param("id"))
+		if err != nil {
+			catalogError(c, err)
+			return
+		}
+		if wantsJSONAPI(c) {
+			var included jsonAPIIncluded
+			included.addOffers(product.Offers, include)
+			writeJSONAPI(c, http.StatusOK, jsonAPIDocument{Data: productResource(product.Product), Included: included.resources})
+			return
+		}
+		c.JSON(http.StatusOK, product)
+	})
+
+	// One listing, by the id a search returned for it
+	group.GET("/offers/:id", func(c *gin.Context) {
+		var include map[string]bool
+		if wantsJSONAPI(c) {
+			var ok bool
+			if include, ok = jsonAPIIncludes(c, []string{"product", "retailer"}, "product", "retailer"); !ok {
+				return
+			}
+		}
+		offer, err := store.Offer(c.Param("id"))
+		if err != nil {
+			catalogError(c, err)
+			return
+		}
+		if wantsJSONAPI(c) {
+			var included jsonAPIIncluded
+			if include["product"] {
+				product, err := store.Get(offer.ProductID)
+				if err != nil {
+					catalogError(c, err)
+					return
+				}
+				included.add(productResource(product.Product))
+			}
+			if include["retailer"] {
+				included.addRetailer(offer.Source)
+			}
+			writeJSONAPI(c, http.StatusOK, jsonAPIDocument{Data: offerResource(*offer), Included: included.resources})
+			return
+		}
+		c.JSON(http.StatusOK, offer)
+	})
+}
+
+func catalogError(c *gin.Context, err error) {
+	status, body := http.StatusInternalServerError, models.ErrorResponse{
+		Error:   "catalog_error",
+		Code:    http.StatusInternalServerError,
+		Message: err.Error(),
+	}
+	var apiErr *apierr.Error
+	switch {
+	case errors.Is(err, catalog.ErrNotFound):
+		status, body = http.StatusNotFound, models.ErrorResponse{
+			Error:   "catalog_not_found",
+			Code:    http.StatusNotFound,
+			Message: err.Error(),
+		}
+	case errors.As(err, &apiErr):
+		status, body = apierr.Response(err, "catalog_error")
+	}
+	if wantsJSONAPI(c) {
+		writeJSONAPIError(c, status, body)
+		return
+	}
+	c.JSON(status, body)
+}
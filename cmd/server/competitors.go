@@ -0,0 +1,329 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"price-comparison-api/internal/apierr"
+	"price-comparison-api/internal/competitors"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/services"
+)
+
+func registerCompetitorRoutes(r *gin.Engine, monitor *competitors.Monitor, attribution *services.Attributor, cfg *config.Config) {
+	group := r.Group("/competitors", func(c *gin.Context) {
+		if monitor == nil {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, models.ErrorResponse{
+				Error:   "competitors_unavailable",
+				Code:    http.StatusServiceUnavailable,
+				Message: "competitor monitoring is disabled (COMPETITORS_ENABLED)",
+			})
+			return
+		}
+		c.Next()
+	}, callerMiddleware(cfg.Profiles))
+
+	// Where every SKU stands against its competitors; format=csv downloads
+	// one line per SKU and competitor listing
+	group.GET("", func(c *gin.Context) {
+		rows := competitors.Report(monitor.List(c.GetString("caller_id")))
+		if c.Query("format") == "csv" {
+			var attribute func(string, time.Time) models.Attribution
+			if attribution != nil {
+				attribute = attribution.Source
+			}
+			c.Header("Content-Type", "text/csv; charset=utf-8")
+			c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=competitors-%s.csv", time.Now().In(cfg.Reporting.Location()).Format("2006-01-02")))
+			if err := competitors.WriteCSV(c.Writer, rows, attribute); err != nil {
+				c.Error(err)
+			}
+			return
+		}
+
+		undercut := 0
+		var listings []models.Product
+		for _, row := range rows {
+			if row.Undercutting > 0 {
+				undercut++
+			}
+			for _, l := range row.Listings {
+				listings = append(listings, models.Product{Source: l.Source, ScrapedAt: l.SeenAt})
+			}
+		}
+		resp := gin.H{
+			"skus":          rows,
+			"total":         len(rows),
+			"undercut_skus": undercut,
+		}
+		if attrs := attribution.Products(listings); len(attrs) > 0 {
+			resp["attribution"] = attrs
+		}
+		c.JSON(http.StatusOK, resp)
+	})
+
+	// Recent undercut events, optionally for one SKU
+	group.GET("/events", func(c *gin.Context) {
+		limit, _ := strconv.Atoi(c.Query("limit"))
+		events, err := monitor.Events(c.GetString("caller_id"), c.Query("sku_id"), limit)
+		if err != nil {
+			competitorError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"events": events})
+	})
+
+	group.POST("/skus", func(c *gin.Context) {
+		var in competitors.Input
+		if !bindSKU(c, &in) {
+			return
+		}
+		sku, err := monitor.Add(c.GetString("caller_id"), in)
+		if err != nil {
+			competitorError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, sku)
+	})
+
+	group.GET("/skus", func(c *gin.Context) {
+		skus := monitor.List(c.GetString("caller_id"))
+		c.JSON(http.StatusOK, gin.H{"skus": skus, "total": len(skus)})
+	})
+
+	group.GET("/skus/:id", func(c *gin.Context) {
+		sku, err := monitor.Get(c.GetString("caller_id"), c.Param("id"))
+		if err != nil {
+			competitorError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, sku)
+	})
+
+	// Replace a SKU's details, typically its price after repricing
+	group.PUT("/skus/:id", func(c *gin.Context) {
+		var in competitors.Input
+		if !bindSKU(c, &in) {
+			return
+		}
+		sku, err := monitor.Update(c.GetString("caller_id"), c.Param("id"), in)
+		if err != nil {
+			competitorError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, sku)
+	})
+
+	group.DELETE("/skus/:id", func(c *gin.Context) {
+		if err := monitor.Delete(c.GetString("caller_id"), c.Param("id")); err != nil {
+			competitorError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "sku deleted"})
+	})
+
+	// Latest recommended price of every SKU with a repricing rule
+	group.GET("/recommendations", func(c *gin.Context) {
+		recs := monitor.Recommendations(c.GetString("caller_id"))
+		c.JSON(http.StatusOK, gin.H{"recommendations": recs, "total": len(recs)})
+	})
+
+	group.PUT("/skus/:id/repricing", func(c *gin.Context) {
+		var rule competitors.Rule
+		if err := c.ShouldBindJSON(&rule); err != nil {
+			writeError(c, apierr.Validation("invalid_request", "invalid repricing rule").WithDetails(err.Error()))
+			return
+		}
+		sku, err := monitor.SetRule(c.GetString("caller_id"), c.Param("id"), &rule)
+		if err != nil {
+			competitorError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, sku)
+	})
+
+	group.DELETE("/skus/:id/repricing", func(c *gin.Context) {
+		sku, err := monitor.SetRule(c.GetString("caller_id"), c.Param("id"), nil)
+		if err != nil {
+			competitorError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, sku)
+	})
+
+	// Dry run: replay a rule (the SKU's own without a body) over the price
+	// history of the last days (30) without changing anything
+	group.POST("/skus/:id/simulate", func(c *gin.Context) {
+		days := 30
+		if raw := c.Query("days"); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil || parsed <= 0 {
+				writeError(c, apierr.Validation("invalid_request", "days must be a positive number"))
+				return
+			}
+			days = parsed
+		}
+
+		var rule *competitors.Rule
+		if c.Request.ContentLength != 0 {
+			rule = &competitors.Rule{}
+			if err := c.ShouldBindJSON(rule); err != nil {
+				writeError(c, apierr.Validation("invalid_request", "invalid repricing rule").WithDetails(err.Error()))
+				return
+			}
+		}
+
+		since := time.Now().AddDate(0, 0, -days)
+		sim, err := monitor.Simulate(c.GetString("caller_id"), c.Param("id"), rule, since)
+		if err != nil {
+			competitorError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, sim)
+	})
+
+	// Shopify and WooCommerce stores whose products are imported as SKUs
+	group.POST("/integrations", func(c *gin.Context) {
+		var in competitors.IntegrationInput
+		if err := c.ShouldBindJSON(&in); err != nil {
+			writeError(c, apierr.Validation("invalid_request", "invalid integration").WithDetails(err.Error()))
+			return
+		}
+		integration, err := monitor.AddIntegration(c.GetString("caller_id"), in)
+		if err != nil {
+			competitorError(c, err)
+			return
+		}
+		c.JSON(http.StatusAccepted, integration)
+	})
+
+	group.GET("/integrations", func(c *gin.Context) {
+		list := monitor.Integrations(c.GetString("caller_id"))
+		c.JSON(http.StatusOK, gin.H{"integrations": list, "total": len(list)})
+	})
+
+	group.GET("/integrations/:id", func(c *gin.Context) {
+		integration, err := monitor.Integration(c.GetString("caller_id"), c.Param("id"))
+		if err != nil {
+			competitorError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, integration)
+	})
+
+	group.DELETE("/integrations/:id", func(c *gin.Context) {
+		if err := monitor.DeleteIntegration(c.GetString("caller_id"), c.Param("id")); err != nil {
+			competitorError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "integration deleted; its SKUs are kept"})
+	})
+
+	// Import the store's products now instead of waiting for the interval
+	group.POST("/integrations/:id/sync", func(c *gin.Context) {
+		result, err := monitor.Sync(c.Request.Context(), c.GetString("caller_id"), c.Param("id"))
+		if err != nil {
+			competitorError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	})
+
+	// How imported products were matched; status=pending lists the ones
+	// waiting for review
+	group.GET("/integrations/:id/mappings", func(c *gin.Context) {
+		mappings, err := monitor.Mappings(c.GetString("caller_id"), c.Param("id"), c.Query("status"))
+		if err != nil {
+			competitorError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"mappings": mappings, "total": len(mappings)})
+	})
+
+	group.POST("/integrations/:id/mappings/:mapping", func(c *gin.Context) {
+		var decision competitors.MappingDecision
+		if err := c.ShouldBindJSON(&decision); err != nil {
+			writeError(c, apierr.Validation("invalid_request", "invalid mapping decision").WithDetails(err.Error()))
+			return
+		}
+		mapping, err := monitor.ResolveMapping(c.GetString("caller_id"), c.Param("id"), c.Param("mapping"), decision)
+		if err != nil {
+			competitorError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, mapping)
+	})
+
+	// Search for a SKU's competitors now instead of waiting for the interval
+	group.POST("/skus/:id/check", func(c *gin.Context) {
+		sku, err := monitor.Check(c.Request.Context(), c.GetString("caller_id"), c.Param("id"))
+		if err != nil {
+			competitorError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, sku)
+	})
+}
+
+func bindSKU(c *gin.Context, in *competitors.Input) bool {
+	if err := c.ShouldBindJSON(in); err != nil {
+		writeError(c, apierr.Validation("invalid_request", "invalid sku").WithDetails(err.Error()))
+		return false
+	}
+	return true
+}
+
+func competitorError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, competitors.ErrNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "sku_not_found",
+			Code:    http.StatusNotFound,
+			Message: err.Error(),
+		})
+	case errors.Is(err, competitors.ErrIntegrationNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "integration_not_found",
+			Code:    http.StatusNotFound,
+			Message: err.Error(),
+		})
+	case errors.Is(err, competitors.ErrMappingNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "mapping_not_found",
+			Code:    http.StatusNotFound,
+			Message: err.Error(),
+		})
+	case errors.Is(err, competitors.ErrStoreUnavailable):
+		c.JSON(http.StatusBadGateway, models.ErrorResponse{
+			Error:   "store_unavailable",
+			Code:    http.StatusBadGateway,
+			Message: err.Error(),
+		})
+	case errors.Is(err, competitors.ErrSyncing):
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "sync_in_progress",
+			Code:    http.StatusConflict,
+			Message: err.Error(),
+		})
+	case errors.Is(err, competitors.ErrNoRule):
+		writeError(c, apierr.Validation("no_repricing_rule", "the sku has no repricing rule; send one in the body"))
+	case errors.Is(err, competitors.ErrNoHistory):
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{
+			Error:   "history_unavailable",
+			Code:    http.StatusServiceUnavailable,
+			Message: "price history is disabled (HISTORY_ENABLED)",
+		})
+	case errors.Is(err, competitors.ErrInvalid):
+		writeError(c, apierr.Validation("invalid_sku", err.Error()))
+	default:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "competitors_error",
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		})
+	}
+}
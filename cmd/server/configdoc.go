@@ -0,0 +1,311 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+	"price-comparison-api/internal/apierr"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/services"
+)
+
+// configDocumentVersion is bumped whenever the document's shape changes;
+// imports of other versions are refused.
+const configDocumentVersion = 1
+
+// configDocument is the whole configuration of a running server: the
+// settings changed at runtime through the admin API, which an import
+// applies, and the file and environment configuration it started with,
+// which an import only compares because applying it takes a restart.
+type configDocument struct {
+	Version    int             `json:"version"`
+	ExportedAt time.Time       `json:"exported_at"`
+	Runtime    runtimeSettings `json:"runtime"`
+	// Config uses the YAML keys of config.yaml, with secrets redacted
+	Config map[string]interface{} `json:"config,omitempty"`
+}
+
+// runtimeSettings are the settings the admin API changes without a restart.
+type runtimeSettings struct {
+	// Scrapers switches each scraper on or off
+	Scrapers map[string]bool `json:"scrapers"`
+	// RateLimitOverrides are per-IP rate limits
+	RateLimitOverrides map[string]config.RateLimitConfig `json:"rate_limit_overrides"`
+	Maintenance        maintenanceSettings               `json:"maintenance"`
+}
+
+type maintenanceSettings struct {
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message,omitempty"`
+}
+
+// redacted replaces secrets in exported configuration.
+const redacted = "redacted"
+
+// configImportMu keeps imports from interleaving, so a rollback restores
+// what the import itself replaced.
+var configImportMu sync.Mutex
+
+func exportConfig(cfg *config.Config, searchService *services.SearchService, maintenance *maintenanceMode) (*configDocument, error) {
+	settings, err := configSettings(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &configDocument{
+		Version:    configDocumentVersion,
+		ExportedAt: time.Now().UTC(),
+		Runtime:    currentRuntime(searchService, maintenance),
+		Config:     settings,
+	}, nil
+}
+
+func currentRuntime(searchService *services.SearchService, maintenance *maintenanceMode) runtimeSettings {
+	return runtimeSettings{
+		Scrapers:           searchService.ScraperStatus(),
+		RateLimitOverrides: rateLimitOverrides(),
+		Maintenance:        maintenance.settings(),
+	}
+}
+
+// configSettings returns cfg keyed like config.yaml, with passwords, API
+// keys and credentials in URLs redacted.
+func configSettings(cfg *config.Config) (map[string]interface{}, error) {
+	c := *cfg
+	redact := func(s *string) {
+		if *s != "" {
+			*s = redacted
+		}
+	}
+	redact(&c.Redis.Password)
+	redact(&c.Redis.SentinelPassword)
+	redactURL := func(s *string) {
+		if u, err := url.Parse(*s); err == nil && u.User != nil {
+			u.User = url.User(redacted)
+			*s = u.String()
+		}
+	}
+	redactURL(&c.Redis.URL)
+	redactURL(&c.Storage.DSN)
+	proxies := append([]string(nil), c.Chrome.Proxies...)
+	for i := range proxies {
+		redactURL(&proxies[i])
+	}
+	c.Chrome.Proxies = proxies
+	redact(&c.Chrome.Captcha.APIKey)
+	keys := make([]string, len(c.Admin.APIKeys))
+	for i := range keys {
+		keys[i] = redacted
+	}
+	c.Admin.APIKeys = keys
+	redact(&c.Watermark.Secret)
+	resellers := make(map[string]string, len(c.Watermark.Keys))
+	for name := range c.Watermark.Keys {
+		resellers[name] = redacted
+	}
+	c.Watermark.Keys = resellers
+	redact(&c.Providers.Ebay.ClientSecret)
+	redact(&c.Providers.BestBuy.APIKey)
+	redact(&c.Alerts.Chat.TelegramBotToken)
+
+	raw, err := yaml.Marshal(&c)
+	if err != nil {
+		return nil, err
+	}
+	var settings map[string]interface{}
+	if err := yaml.Unmarshal(raw, &settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// validate checks the whole document before anything is applied.
+func (d *configDocument) validate(searchService *services.SearchService) error {
+	if d.Version != configDocumentVersion {
+		return apierr.Validation("unsupported_version", fmt.Sprintf("config document version %d is not supported; this server reads version %d", d.Version, configDocumentVersion))
+	}
+	known := searchService.ScraperStatus()
+	for name := range d.Runtime.Scrapers {
+		if _, ok := known[name]; !ok {
+			return apierr.Validation("invalid_config", fmt.Sprintf("unknown scraper: %s. Valid scrapers: %s", name, strings.Join(config.ScraperNames, ", ")))
+		}
+	}
+	for ip, limit := range d.Runtime.RateLimitOverrides {
+		if limit.RequestsPerSecond <= 0 || limit.Burst <= 0 {
+			return apierr.Validation("invalid_config", fmt.Sprintf("rate limit override for %s needs positive requests_per_second and burst", ip))
+		}
+	}
+	return nil
+}
+
+// applyRuntime makes settings current. Scrapers the settings don't mention
+// keep their state; rate limit overrides are replaced as a whole.
+func applyRuntime(settings runtimeSettings, searchService *services.SearchService, maintenance *maintenanceMode) error {
+	for name, enabled := range settings.Scrapers {
+		if err := searchService.SetScraperEnabled(name, enabled); err != nil {
+			return err
+		}
+	}
+	for ip := range rateLimitOverrides() {
+		if _, keep := settings.RateLimitOverrides[ip]; !keep {
+			clearRateLimitOverride(ip)
+		}
+	}
+	for ip, limit := range settings.RateLimitOverrides {
+		setRateLimitOverride(ip, limit)
+	}
+	maintenance.set(settings.Maintenance.Enabled, settings.Maintenance.Message)
+	return nil
+}
+
+// runtimeChanges describes how next differs from current, one line per
+// setting.
+func runtimeChanges(current, next runtimeSettings) []string {
+	changes := []string{}
+	for name, enabled := range next.Scrapers {
+		if was := current.Scrapers[name]; was != enabled {
+			changes = append(changes, fmt.Sprintf("scrapers.%s: %t -> %t", name, was, enabled))
+		}
+	}
+	for ip, limit := range next.RateLimitOverrides {
+		if was, ok := current.RateLimitOverrides[ip]; !ok {
+			changes = append(changes, fmt.Sprintf("rate_limit_overrides.%s: added", ip))
+		} else if was != limit {
+			changes = append(changes, fmt.Sprintf("rate_limit_overrides.%s: changed", ip))
+		}
+	}
+	for ip := range current.RateLimitOverrides {
+		if _, ok := next.RateLimitOverrides[ip]; !ok {
+			changes = append(changes, fmt.Sprintf("rate_limit_overrides.%s: removed", ip))
+		}
+	}
+	if current.Maintenance.Enabled != next.Maintenance.Enabled {
+		changes = append(changes, fmt.Sprintf("maintenance.enabled: %t -> %t", current.Maintenance.Enabled, next.Maintenance.Enabled))
+	}
+	sort.Strings(changes)
+	return changes
+}
+
+// restartRequired lists the keys whose value in the imported config differs
+// from the running one. Redacted secrets compare equal.
+func restartRequired(running, imported map[string]interface{}) []string {
+	have, want := map[string]string{}, map[string]string{}
+	flattenSettings("", running, have)
+	flattenSettings("", imported, want)
+
+	keys := []string{}
+	for key, value := range want {
+		if have[key] != value {
+			keys = append(keys, key)
+		}
+	}
+	for key := range have {
+		if _, ok := want[key]; !ok && len(imported) > 0 {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// flattenSettings writes nested settings as "redis.ttl" style keys.
+func flattenSettings(prefix string, v interface{}, out map[string]string) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, child := range t {
+			flattenSettings(joinKey(prefix, k), child, out)
+		}
+	case []interface{}:
+		parts := make([]string, len(t))
+		for i, item := range t {
+			parts[i] = fmt.Sprint(item)
+		}
+		out[prefix] = strings.Join(parts, ",")
+	default:
+		out[prefix] = fmt.Sprint(t)
+	}
+}
+
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+func registerConfigRoutes(admin *gin.RouterGroup, cfg *config.Config, searchService *services.SearchService, maintenance *maintenanceMode) {
+	// The complete configuration as one versioned document
+	admin.GET("/config/export", func(c *gin.Context) {
+		doc, err := exportConfig(cfg, searchService, maintenance)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "config_export_failed",
+				Code:    http.StatusInternalServerError,
+				Message: err.Error(),
+			})
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=config-%s.json", time.Now().In(cfg.Reporting.Location()).Format("2006-01-02")))
+		c.JSON(http.StatusOK, doc)
+	})
+
+	// Apply an exported document's runtime settings all at once, rolling
+	// back if any fails; dry_run=true only validates and reports changes
+	admin.POST("/config/import", func(c *gin.Context) {
+		var doc configDocument
+		if err := c.ShouldBindJSON(&doc); err != nil {
+			writeError(c, apierr.Validation("invalid_request", "body must be a config document from /admin/config/export").WithDetails(err.Error()))
+			return
+		}
+		if err := doc.validate(searchService); err != nil {
+			writeError(c, err)
+			return
+		}
+		running, err := configSettings(cfg)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "config_import_failed",
+				Code:    http.StatusInternalServerError,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		configImportMu.Lock()
+		defer configImportMu.Unlock()
+
+		dryRun := c.Query("dry_run") == "true"
+		previous := currentRuntime(searchService, maintenance)
+		result := gin.H{
+			"dry_run":          dryRun,
+			"changes":          runtimeChanges(previous, doc.Runtime),
+			"restart_required": restartRequired(running, doc.Config),
+		}
+		if dryRun {
+			c.JSON(http.StatusOK, result)
+			return
+		}
+
+		if err := applyRuntime(doc.Runtime, searchService, maintenance); err != nil {
+			rollbackErr := applyRuntime(previous, searchService, maintenance)
+			log.Error().Err(err).AnErr("rollback_error", rollbackErr).Msg("Config import failed; rolled back")
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "config_import_failed",
+				Code:    http.StatusInternalServerError,
+				Message: fmt.Sprintf("import failed and was rolled back: %v", err),
+			})
+			return
+		}
+		log.Info().Msgf("Imported config document exported at %s", doc.ExportedAt.Format(time.RFC3339))
+		result["previous"] = previous
+		c.JSON(http.StatusOK, result)
+	})
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+
+	"price-comparison-api/internal/config"
+)
+
+func TestConfigSettingsRedactsTelegramBotToken(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Alerts.Chat.TelegramBotToken = "123456:secret-token"
+
+	settings, err := configSettings(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alerts, _ := settings["alerts"].(map[string]interface{})
+	chat, _ := alerts["chat"].(map[string]interface{})
+	if got := chat["telegram_bot_token"]; got != redacted {
+		t.Errorf("alerts.chat.telegram_bot_token = %v, want %q", got, redacted)
+	}
+	if cfg.Alerts.Chat.TelegramBotToken != "123456:secret-token" {
+		t.Errorf("exporting changed the running config's token to %q", cfg.Alerts.Chat.TelegramBotToken)
+	}
+}
@@ -0,0 +1,538 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+)
+
+// contractCase is one request of the contract and the status it must get.
+type contractCase struct {
+	Name   string
+	Method string
+	// Path may refer to a value an earlier case saved as {{name}}
+	Path string
+	Body string
+	// Accept defaults to application/json
+	Accept string
+	// APIKey is sent as X-API-Key, for routes limited to the caller
+	APIKey string
+	Status int
+	// Save keeps values of the JSON response for later cases, as
+	// "name=field.path", an array index being a path element
+	Save []string
+}
+
+// API keys of the contract's callers
+const (
+	contractKey      = "contract-key"
+	contractOtherKey = "contract-other-key"
+	contractAdminKey = "contract-admin-key"
+)
+
+// Mock product pages, one per retailer with product pages the comparison
+// needs
+const (
+	contractAmazonURL  = "https://www.amazon.com/mock/acme-usb-c-cable-pro-black-1"
+	contractWalmartURL = "https://www.walmart.com/mock/acme-usb-c-cable-pro-black-1"
+)
+
+// contractCases are the public endpoints, their successful responses and
+// the errors clients are expected to handle. Requests run in order, so a
+// case may rely on state an earlier one created.
+var contractCases = []contractCase{
+	{Name: "health", Method: "GET", Path: "/health", Status: 200},
+	{Name: "health_scrapers", Method: "GET", Path: "/health/scrapers", Status: 200},
+	{Name: "metrics", Method: "GET", Path: "/metrics", Accept: "text/plain", Status: 200},
+	{Name: "api_info", Method: "GET", Path: "/api/info", Status: 200},
+	{Name: "categories", Method: "GET", Path: "/categories", Status: 200},
+	{Name: "rate_limit_status", Method: "GET", Path: "/rate-limit/status", Status: 200},
+	{Name: "cache_stats", Method: "GET", Path: "/cache/stats", Status: 200},
+
+	{Name: "search", Method: "GET", Path: "/search?q=usb+c+cable&country=US", Status: 200, Save: []string{"session=session_id"}},
+	{Name: "search_filtered", Method: "GET", Path: "/search?q=usb+c+cable&country=US&sort=price&order=asc&condition=new&limit=5", Status: 200},
+	{Name: "search_localized", Method: "GET", Path: "/search?q=usb+c+cable&country=US&price_format=localized", Status: 200},
+	{Name: "search_debug", Method: "GET", Path: "/search?q=usb+c+cable&country=US&debug=true", APIKey: contractAdminKey, Status: 200},
+	{Name: "search_debug_unauthorized", Method: "GET", Path: "/search?q=usb+c+cable&country=US&debug=true", Status: 401},
+	{Name: "search_plan", Method: "GET", Path: "/search/plan?q=laptop&country=US", Status: 200},
+	{Name: "search_empty_query", Method: "GET", Path: "/search", Status: 400},
+	{Name: "search_invalid_sort", Method: "GET", Path: "/search?q=laptop&sort=bogus", Status: 400},
+	{Name: "quick_search", Method: "GET", Path: "/search/quick?q=usb+c+cable&country=US", Status: 200},
+	{Name: "quick_search_empty_query", Method: "GET", Path: "/search/quick", Status: 400},
+	{Name: "export_pdf", Method: "GET", Path: "/search/export.pdf?q=usb+c+cable&country=US", Accept: "application/pdf", Status: 200},
+	{Name: "export_pdf_empty_query", Method: "GET", Path: "/search/export.pdf", Status: 400},
+	{Name: "export_pdf_watermarked", Method: "GET", Path: "/search/export.pdf?q=usb+c+cable&country=US", APIKey: resellerKey, Status: 403},
+
+	{Name: "session", Method: "GET", Path: "/sessions/{{session}}", Status: 200},
+	{Name: "session_refine", Method: "POST", Path: "/sessions/{{session}}/refine", Body: `{"sort":{"field":"price","order":"asc"},"limit":5}`, Status: 200},
+	{Name: "session_undo", Method: "POST", Path: "/sessions/{{session}}/undo", Status: 200},
+	{Name: "session_not_found", Method: "GET", Path: "/sessions/unknown", Status: 404},
+
+	{Name: "graphql_get", Method: "GET", Path: "/graphql?query={__typename}", Status: 200},
+	{Name: "graphql_post", Method: "POST", Path: "/graphql", Body: `{"query":"{__typename}"}`, Status: 200},
+
+	{Name: "product", Method: "GET", Path: "/product?url=" + contractAmazonURL, Status: 200},
+	{Name: "product_missing_url", Method: "GET", Path: "/product", Status: 400},
+	{Name: "product_unsupported_url", Method: "GET", Path: "/product?url=https://example.com/item/1", Status: 400},
+	{Name: "lookup_invalid_code", Method: "GET", Path: "/lookup?gtin=123", Status: 400},
+	{Name: "regional_invalid_id", Method: "GET", Path: "/products/unknown/regional", Status: 400},
+	{Name: "product_stats_not_found", Method: "GET", Path: "/products/unknown/stats", Status: 404},
+	{Name: "compare", Method: "GET", Path: "/compare?urls=" + contractAmazonURL + "," + contractWalmartURL, Status: 200},
+	{Name: "compare_missing_products", Method: "GET", Path: "/compare", Status: 400},
+	{Name: "compare_unknown_product", Method: "GET", Path: "/compare?ids=unknown", Status: 200},
+
+	{Name: "catalog_products", Method: "GET", Path: "/catalog/products", Status: 200},
+	{Name: "catalog_products_jsonapi", Method: "GET", Path: "/catalog/products", Accept: jsonAPIMediaType, Status: 200, Save: []string{"product=data.0.id", "offer=data.0.relationships.offers.data.0.id"}},
+	{Name: "catalog_products_invalid_include", Method: "GET", Path: "/catalog/products?include=sellers", Accept: jsonAPIMediaType, Status: 400},
+	{Name: "catalog_products_not_acceptable", Method: "GET", Path: "/catalog/products", Accept: jsonAPIMediaType + "; ext=bulk", Status: 406},
+	{Name: "catalog_product", Method: "GET", Path: "/catalog/products/{{product}}", Accept: jsonAPIMediaType, Status: 200},
+	{Name: "catalog_product_not_found", Method: "GET", Path: "/catalog/products/unknown", Status: 404},
+	{Name: "catalog_offer", Method: "GET", Path: "/catalog/offers/{{offer}}", Accept: jsonAPIMediaType, Status: 200},
+	{Name: "catalog_offer_not_found", Method: "GET", Path: "/catalog/offers/unknown", Status: 404},
+
+	{Name: "history_missing_key", Method: "GET", Path: "/history", Status: 400},
+	{Name: "history_changes", Method: "GET", Path: "/history/changes", Status: 200},
+	{Name: "deals_drops", Method: "GET", Path: "/deals/drops", Status: 200},
+	{Name: "image_proxy_missing_url", Method: "GET", Path: "/images/proxy", Status: 400},
+
+	{Name: "watchlist_add", Method: "POST", Path: "/watchlist", Body: `{"url":"` + contractAmazonURL + `"}`, Status: 201, Save: []string{"item=id"}},
+	{Name: "watchlist", Method: "GET", Path: "/watchlist", Status: 200},
+	{Name: "watchlist_item", Method: "GET", Path: "/watchlist/{{item}}", Status: 200},
+	{Name: "watchlist_item_not_found", Method: "GET", Path: "/watchlist/unknown", Status: 404},
+	{Name: "watchlist_remove", Method: "DELETE", Path: "/watchlist/{{item}}", Status: 204},
+
+	{Name: "saved_search_create", Method: "POST", Path: "/saved-searches", Body: `{"query":"usb c cable","country":"US"}`, Status: 201, Save: []string{"search=saved_search.id"}},
+	{Name: "saved_search_invalid", Method: "POST", Path: "/saved-searches", Body: `{}`, Status: 400},
+	{Name: "saved_searches", Method: "GET", Path: "/saved-searches", Status: 200},
+	{Name: "saved_search", Method: "GET", Path: "/saved-searches/{{search}}", Status: 200},
+	{Name: "saved_search_not_found", Method: "GET", Path: "/saved-searches/unknown", Status: 404},
+	{Name: "feed", Method: "GET", Path: "/feeds/{{search}}.xml", Accept: "application/rss+xml", Status: 200},
+	{Name: "feed_not_found", Method: "GET", Path: "/feeds/unknown.xml", Status: 404},
+	{Name: "saved_search_delete", Method: "DELETE", Path: "/saved-searches/{{search}}", Status: 204},
+
+	{Name: "me", Method: "GET", Path: "/me", APIKey: contractKey, Status: 200},
+	{Name: "me_other", Method: "GET", Path: "/me", APIKey: contractOtherKey, Status: 200, Save: []string{"member=id"}},
+	{Name: "me_unauthorized", Method: "GET", Path: "/me", Status: 401},
+	{Name: "preferences_not_found", Method: "GET", Path: "/me/preferences", APIKey: contractKey, Status: 404},
+	{Name: "preferences_put", Method: "PUT", Path: "/me/preferences", Body: `{"country":"US","currency":"USD","time_zone":"America/New_York"}`, APIKey: contractKey, Status: 200},
+	{Name: "preferences", Method: "GET", Path: "/me/preferences", APIKey: contractKey, Status: 200},
+	{Name: "preferences_delete", Method: "DELETE", Path: "/me/preferences", APIKey: contractKey, Status: 200},
+
+	{Name: "alert_create", Method: "POST", Path: "/alerts", Body: `{"type":"launch","query":"usb c cable","country":"US"}`, APIKey: contractKey, Status: 201, Save: []string{"alert=id"}},
+	{Name: "alert_create_invalid", Method: "POST", Path: "/alerts", Body: `{"type":"bogus"}`, APIKey: contractKey, Status: 400},
+	{Name: "alerts", Method: "GET", Path: "/alerts", APIKey: contractKey, Status: 200},
+	{Name: "alerts_unauthorized", Method: "GET", Path: "/alerts", Status: 401},
+	{Name: "alerts_bulk_pause", Method: "PATCH", Path: "/alerts/bulk", Body: `{"action":"pause","filter":{"country":"US"}}`, APIKey: contractKey, Status: 200},
+	{Name: "alerts_bulk_invalid", Method: "PATCH", Path: "/alerts/bulk", Body: `{"action":"bogus"}`, APIKey: contractKey, Status: 400},
+	{Name: "alert_events", Method: "GET", Path: "/alerts/events", APIKey: contractKey, Status: 200},
+	{Name: "alert_poll", Method: "GET", Path: "/alerts/poll?wait=0", APIKey: contractKey, Status: 200},
+	{Name: "alert_poll_ack_invalid", Method: "POST", Path: "/alerts/poll/ack", Body: `{}`, APIKey: contractKey, Status: 400},
+	{Name: "alert_stock_checks", Method: "GET", Path: "/alerts/stock-checks", Status: 200},
+	{Name: "alert", Method: "GET", Path: "/alerts/{{alert}}", APIKey: contractKey, Status: 200},
+	{Name: "alert_unauthorized", Method: "GET", Path: "/alerts/unknown", Status: 401},
+	{Name: "alert_not_found", Method: "GET", Path: "/alerts/unknown", APIKey: contractKey, Status: 404},
+	{Name: "alert_events_of_alert", Method: "GET", Path: "/alerts/{{alert}}/events", APIKey: contractKey, Status: 200},
+	{Name: "alert_delete", Method: "DELETE", Path: "/alerts/{{alert}}", APIKey: contractKey, Status: 200},
+	{Name: "alerts_bulk_delete", Method: "DELETE", Path: "/alerts/bulk?country=US", APIKey: contractKey, Status: 200},
+
+	{Name: "org_create", Method: "POST", Path: "/orgs", Body: `{"name":"Acme"}`, APIKey: contractKey, Status: 201, Save: []string{"org=id"}},
+	{Name: "org_create_invalid", Method: "POST", Path: "/orgs", Body: `{}`, APIKey: contractKey, Status: 400},
+	{Name: "orgs", Method: "GET", Path: "/orgs", APIKey: contractKey, Status: 200},
+	{Name: "orgs_unauthorized", Method: "GET", Path: "/orgs", Status: 401},
+	{Name: "org", Method: "GET", Path: "/orgs/{{org}}", APIKey: contractKey, Status: 200},
+	{Name: "org_not_found", Method: "GET", Path: "/orgs/unknown", APIKey: contractKey, Status: 404},
+	{Name: "org_rename", Method: "PATCH", Path: "/orgs/{{org}}", Body: `{"name":"Acme Corp"}`, APIKey: contractKey, Status: 200},
+	{Name: "org_member_put", Method: "PUT", Path: "/orgs/{{org}}/members/{{member}}", Body: `{"role":"viewer"}`, APIKey: contractKey, Status: 200},
+	{Name: "org_rename_forbidden", Method: "PATCH", Path: "/orgs/{{org}}", Body: `{"name":"Mine"}`, APIKey: contractOtherKey, Status: 403},
+	{Name: "org_watchlist_add", Method: "POST", Path: "/orgs/{{org}}/watchlist", Body: `{"url":"` + contractAmazonURL + `","country":"US","note":"flagship"}`, APIKey: contractKey, Status: 201, Save: []string{"org_item=id"}},
+	{Name: "org_watchlist", Method: "GET", Path: "/orgs/{{org}}/watchlist", APIKey: contractOtherKey, Status: 200},
+	{Name: "org_watchlist_delete", Method: "DELETE", Path: "/orgs/{{org}}/watchlist/{{org_item}}", APIKey: contractKey, Status: 200},
+	{Name: "org_channel_add", Method: "POST", Path: "/orgs/{{org}}/channels", Body: `{"name":"ops","type":"webhook","url":"https://hooks.example.com/prices"}`, APIKey: contractKey, Status: 201, Save: []string{"channel=id"}},
+	{Name: "org_channels", Method: "GET", Path: "/orgs/{{org}}/channels", APIKey: contractKey, Status: 200},
+	{Name: "org_channel_delete", Method: "DELETE", Path: "/orgs/{{org}}/channels/{{channel}}", APIKey: contractKey, Status: 200},
+	{Name: "org_alert_create", Method: "POST", Path: "/orgs/{{org}}/alerts", Body: `{"type":"launch","query":"usb c cable","country":"US"}`, APIKey: contractKey, Status: 201, Save: []string{"org_alert=id"}},
+	{Name: "org_alerts", Method: "GET", Path: "/orgs/{{org}}/alerts", APIKey: contractKey, Status: 200},
+	{Name: "org_alert_events", Method: "GET", Path: "/orgs/{{org}}/alerts/events", APIKey: contractKey, Status: 200},
+	{Name: "org_alert_delete", Method: "DELETE", Path: "/orgs/{{org}}/alerts/{{org_alert}}", APIKey: contractKey, Status: 200},
+	{Name: "org_member_delete", Method: "DELETE", Path: "/orgs/{{org}}/members/{{member}}", APIKey: contractKey, Status: 200},
+	{Name: "org_delete", Method: "DELETE", Path: "/orgs/{{org}}", APIKey: contractKey, Status: 200},
+
+	{Name: "sku_create", Method: "POST", Path: "/competitors/skus", Body: `{"sku":"ACME-1","name":"Acme USB-C Cable Pro","query":"usb c cable","country":"US","price":19.99,"currency":"USD"}`, APIKey: contractKey, Status: 201, Save: []string{"sku=id"}},
+	{Name: "sku_create_invalid", Method: "POST", Path: "/competitors/skus", Body: `{}`, APIKey: contractKey, Status: 400},
+	{Name: "skus", Method: "GET", Path: "/competitors/skus", APIKey: contractKey, Status: 200},
+	{Name: "skus_unauthorized", Method: "GET", Path: "/competitors/skus", Status: 401},
+	{Name: "sku", Method: "GET", Path: "/competitors/skus/{{sku}}", APIKey: contractKey, Status: 200},
+	{Name: "sku_put", Method: "PUT", Path: "/competitors/skus/{{sku}}", Body: `{"sku":"ACME-1","name":"Acme USB-C Cable Pro","query":"usb c cable","country":"US","price":18.99,"currency":"USD"}`, APIKey: contractKey, Status: 200},
+	{Name: "sku_check", Method: "POST", Path: "/competitors/skus/{{sku}}/check", APIKey: contractKey, Status: 200},
+	{Name: "sku_repricing_put", Method: "PUT", Path: "/competitors/skus/{{sku}}/repricing", Body: `{"beat_pct":1,"floor":10}`, APIKey: contractKey, Status: 200},
+	{Name: "sku_simulate", Method: "POST", Path: "/competitors/skus/{{sku}}/simulate", Body: `{"beat_pct":2,"floor":10}`, APIKey: contractKey, Status: 200},
+	{Name: "competitor_recommendations", Method: "GET", Path: "/competitors/recommendations", APIKey: contractKey, Status: 200},
+	{Name: "competitor_report", Method: "GET", Path: "/competitors", APIKey: contractKey, Status: 200},
+	{Name: "competitor_report_csv", Method: "GET", Path: "/competitors?format=csv", Accept: "text/csv", APIKey: contractKey, Status: 200},
+	{Name: "competitor_events", Method: "GET", Path: "/competitors/events", APIKey: contractKey, Status: 200},
+	{Name: "sku_repricing_delete", Method: "DELETE", Path: "/competitors/skus/{{sku}}/repricing", APIKey: contractKey, Status: 200},
+	{Name: "sku_delete", Method: "DELETE", Path: "/competitors/skus/{{sku}}", APIKey: contractKey, Status: 200},
+	{Name: "sku_not_found", Method: "GET", Path: "/competitors/skus/unknown", APIKey: contractKey, Status: 404},
+	{Name: "integration_create_invalid", Method: "POST", Path: "/competitors/integrations", Body: `{"platform":"bogus","store_url":"https://shop.example.com"}`, APIKey: contractKey, Status: 400},
+	{Name: "integrations", Method: "GET", Path: "/competitors/integrations", APIKey: contractKey, Status: 200},
+	{Name: "integration_not_found", Method: "GET", Path: "/competitors/integrations/unknown", APIKey: contractKey, Status: 404},
+	{Name: "integration_sync_not_found", Method: "POST", Path: "/competitors/integrations/unknown/sync", APIKey: contractKey, Status: 404},
+	{Name: "integration_mappings_not_found", Method: "GET", Path: "/competitors/integrations/unknown/mappings", APIKey: contractKey, Status: 404},
+	{Name: "integration_mapping_not_found", Method: "POST", Path: "/competitors/integrations/unknown/mappings/unknown", Body: `{"action":"ignore"}`, APIKey: contractKey, Status: 404},
+	{Name: "integration_delete_not_found", Method: "DELETE", Path: "/competitors/integrations/unknown", APIKey: contractKey, Status: 404},
+
+	{Name: "admin_unauthorized", Method: "GET", Path: "/admin/scrapers", Status: 401},
+	{Name: "admin_wrong_key", Method: "GET", Path: "/admin/scrapers", APIKey: contractKey, Status: 401},
+	{Name: "admin_scrapers", Method: "GET", Path: "/admin/scrapers", APIKey: contractAdminKey, Status: 200},
+	{Name: "admin_scraper_toggle", Method: "PATCH", Path: "/admin/scrapers/etsy", Body: `{"enabled":true}`, APIKey: contractAdminKey, Status: 200},
+	{Name: "admin_scraper_not_found", Method: "PATCH", Path: "/admin/scrapers/unknown", Body: `{"enabled":true}`, APIKey: contractAdminKey, Status: 404},
+	{Name: "admin_cache_debug", Method: "GET", Path: "/admin/cache/debug", APIKey: contractAdminKey, Status: 200},
+	{Name: "admin_cache_keys_delete", Method: "DELETE", Path: "/admin/cache/keys", APIKey: contractAdminKey, Status: 400},
+	{Name: "admin_cache_flush", Method: "DELETE", Path: "/admin/cache/flush", APIKey: contractAdminKey, Status: 200},
+	{Name: "admin_rate_limit_put", Method: "PUT", Path: "/admin/rate-limits/192.0.2.1", Body: `{"requests_per_second":5,"burst":10}`, APIKey: contractAdminKey, Status: 200},
+	{Name: "admin_rate_limit_put_invalid", Method: "PUT", Path: "/admin/rate-limits/192.0.2.1", Body: `{}`, APIKey: contractAdminKey, Status: 400},
+	{Name: "admin_rate_limits", Method: "GET", Path: "/admin/rate-limits", APIKey: contractAdminKey, Status: 200},
+	{Name: "admin_rate_limit_delete", Method: "DELETE", Path: "/admin/rate-limits/192.0.2.1", APIKey: contractAdminKey, Status: 200},
+	{Name: "admin_shadow", Method: "GET", Path: "/admin/shadow", APIKey: contractAdminKey, Status: 404},
+	{Name: "admin_shadow_reset", Method: "DELETE", Path: "/admin/shadow", APIKey: contractAdminKey, Status: 404},
+	{Name: "admin_session_stats", Method: "GET", Path: "/admin/sessions/stats", APIKey: contractAdminKey, Status: 200},
+	{Name: "admin_fingerprints", Method: "GET", Path: "/admin/fingerprints", APIKey: contractAdminKey, Status: 200},
+	{Name: "admin_selectors", Method: "GET", Path: "/admin/selectors", APIKey: contractAdminKey, Status: 200},
+	{Name: "admin_selectors_reload", Method: "POST", Path: "/admin/selectors/reload", APIKey: contractAdminKey, Status: 200},
+	{Name: "admin_onboarding", Method: "GET", Path: "/admin/countries/US/onboarding", APIKey: contractAdminKey, Status: 200},
+	{Name: "admin_onboarding_invalid", Method: "GET", Path: "/admin/countries/USA/onboarding", APIKey: contractAdminKey, Status: 400},
+	{Name: "admin_currency_rates_put", Method: "PUT", Path: "/admin/currency/rates/2026-01-02", Body: `{"EUR":0.92,"GBP":0.79}`, APIKey: contractAdminKey, Status: 200},
+	{Name: "admin_currency_rates", Method: "GET", Path: "/admin/currency/rates", APIKey: contractAdminKey, Status: 200},
+	{Name: "admin_extractors", Method: "GET", Path: "/admin/extractors", APIKey: contractAdminKey, Status: 404},
+	{Name: "admin_extractors_compare", Method: "POST", Path: "/admin/extractors/compare", Body: `{"query":"usb c cable"}`, APIKey: contractAdminKey, Status: 404},
+	{Name: "admin_extractors_reset", Method: "DELETE", Path: "/admin/extractors", APIKey: contractAdminKey, Status: 404},
+	{Name: "admin_trace_not_found", Method: "GET", Path: "/admin/traces/unknown", APIKey: contractAdminKey, Status: 404},
+	{Name: "admin_watermark_trace", Method: "POST", Path: "/admin/watermark/trace", Body: `{"products":[{"url":"` + contractAmazonURL + `","price_value":19.99}]}`, APIKey: contractAdminKey, Status: 200},
+	{Name: "admin_config_export", Method: "GET", Path: "/admin/config/export", APIKey: contractAdminKey, Status: 200},
+	{Name: "admin_config_import_invalid", Method: "POST", Path: "/admin/config/import", Body: `{}`, APIKey: contractAdminKey, Status: 400},
+	{Name: "admin_maintenance", Method: "GET", Path: "/admin/maintenance", APIKey: contractAdminKey, Status: 200},
+	{Name: "admin_maintenance_on", Method: "POST", Path: "/admin/maintenance/on", Body: `{"message":"back soon"}`, APIKey: contractAdminKey, Status: 200},
+	{Name: "search_maintenance", Method: "GET", Path: "/search?q=hdmi+cable&country=US", Status: 503},
+	{Name: "admin_maintenance_off", Method: "POST", Path: "/admin/maintenance/off", APIKey: contractAdminKey, Status: 200},
+	{Name: "admin_maintenance_invalid", Method: "POST", Path: "/admin/maintenance/sometimes", APIKey: contractAdminKey, Status: 400},
+}
+
+// contractUnexercised are the operations no contract case sends, and why.
+var contractUnexercised = map[string]string{
+	"searchWebSocket":    "TestContractWebSocket",
+	"runCanaries":        "scrapes live sites",
+	"testChromeBasic":    "scrapes live sites",
+	"testChrome":         "scrapes live sites",
+	"testAmazon":         "scrapes live sites",
+	"testeBay":           "scrapes live sites",
+	"testFlipkart":       "scrapes live sites",
+	"testWalmart":        "scrapes live sites",
+	"testTarget":         "scrapes live sites",
+	"testBestBuy":        "scrapes live sites",
+	"testGoogleShopping": "scrapes live sites",
+	"testAliExpress":     "scrapes live sites",
+	"testCostco":         "scrapes live sites",
+	"testSamsClub":       "scrapes live sites",
+	"testEtsy":           "scrapes live sites",
+	"testMercadoLibre":   "scrapes live sites",
+}
+
+var contractPlaceholder = regexp.MustCompile(`\{\{(\w+)\}\}`)
+
+// TestContract guards the public API against refactors that would silently
+// break clients. It serves the API in mock mode, so searches and product
+// pages answer without scraping, sends each request in contractCases,
+// errors included, and checks the response against api/openapi.yaml: the
+// status and Content-Type must be documented for the operation, and a JSON
+// body must validate against the documented schema. Every operation in the
+// spec must be sent by a case, except those in contractUnexercised.
+func TestContract(t *testing.T) {
+	spec := loadOpenAPISpec(t)
+	a := contractApp(t)
+	// Mock searches stay out of the catalog, so it gets a listing of its own
+	listing := models.Product{Name: "Acme USB-C Cable Pro", URL: contractAmazonURL, Source: "Amazon", Price: "$19.99", PriceValue: 19.99, Currency: "USD", InStock: true}
+	if err := a.catalogStore.Upsert("usb c cable", "US", []models.Product{listing}); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(a.router)
+	defer server.Close()
+
+	saved := map[string]string{}
+	exercised := map[string]bool{}
+	for _, tc := range contractCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			path := contractPlaceholder.ReplaceAllStringFunc(tc.Path, func(m string) string {
+				name := contractPlaceholder.FindStringSubmatch(m)[1]
+				value, ok := saved[name]
+				if !ok {
+					t.Fatalf("no earlier case saved %s", name)
+				}
+				return value
+			})
+			op, template, err := spec.operation(tc.Method, strings.SplitN(path, "?", 2)[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			exercised[fmt.Sprint(op["operationId"])] = true
+
+			value := checkContract(t, spec, server, op, tc, path)
+			for _, s := range tc.Save {
+				name, field, _ := strings.Cut(s, "=")
+				v, ok := jsonField(value, field)
+				if !ok {
+					t.Fatalf("%s %s: no %s in the response to save as %s", tc.Method, template, field, name)
+				}
+				saved[name] = fmt.Sprint(v)
+			}
+		})
+	}
+
+	for _, p := range spec.paths {
+		for _, method := range specMethods {
+			op, ok := p.item[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			id := fmt.Sprint(op["operationId"])
+			if !exercised[id] && contractUnexercised[id] == "" {
+				t.Errorf("%s %s (%s): no contract case sends it", strings.ToUpper(method), p.template, id)
+			}
+		}
+	}
+}
+
+// TestContractRoutes fails when a registered route is missing from the
+// spec, or the spec describes a route the server doesn't have.
+func TestContractRoutes(t *testing.T) {
+	spec := loadOpenAPISpec(t)
+	ginParam := regexp.MustCompile(`[:*](\w+)`)
+
+	registered := map[string]bool{}
+	for _, route := range contractApp(t).router.Routes() {
+		template := ginParam.ReplaceAllString(route.Path, "{$1}")
+		registered[route.Method+" "+template] = true
+		if item, ok := specPathItem(spec, template); !ok {
+			t.Errorf("%s %s is not in %s", route.Method, template, openAPISpecPath)
+		} else if _, ok := item[strings.ToLower(route.Method)]; !ok {
+			t.Errorf("%s %s is not in %s", route.Method, template, openAPISpecPath)
+		}
+	}
+	for _, p := range spec.paths {
+		for _, method := range specMethods {
+			if _, ok := p.item[method]; ok && !registered[strings.ToUpper(method)+" "+p.template] {
+				t.Errorf("%s documents %s %s, which the server doesn't route", openAPISpecPath, strings.ToUpper(method), p.template)
+			}
+		}
+	}
+}
+
+func specPathItem(spec *openAPISpec, template string) (map[string]interface{}, bool) {
+	for _, p := range spec.paths {
+		if p.template == template {
+			return p.item, true
+		}
+	}
+	return nil, false
+}
+
+// TestContractWebSocket runs a search over /ws/search and checks every
+// message the server sends against the spec.
+func TestContractWebSocket(t *testing.T) {
+	spec := loadOpenAPISpec(t)
+	server := httptest.NewServer(contractApp(t).router)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	conn, _, _, err := ws.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http")+"/ws/search")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	if err := wsutil.WriteClientText(conn, []byte(`{"type":"search","query":"usb c cable","country":"US"}`)); err != nil {
+		t.Fatal(err)
+	}
+	schema := map[string]interface{}{"$ref": "#/components/schemas/WSServerMessage"}
+	for {
+		data, err := wsutil.ReadServerText(conn)
+		if err != nil {
+			t.Fatalf("no done event: %v", err)
+		}
+		value, err := decodeJSON(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("invalid JSON message: %v: %s", err, data)
+		}
+		for _, problem := range spec.validate("$", schema, value) {
+			t.Error(problem)
+		}
+		if msg, _ := value.(map[string]interface{}); msg["type"] == "done" || msg["type"] == "error" {
+			if msg["type"] == "error" {
+				t.Errorf("search failed: %s", data)
+			}
+			return
+		}
+	}
+}
+
+// contractApp sets up the service in mock mode with empty stores of its
+// own.
+func contractApp(t *testing.T) *app {
+	gin.SetMode(gin.TestMode)
+	// The stores default to files in the temporary directory
+	t.Setenv("TMPDIR", t.TempDir())
+
+	cfg := config.Default()
+	cfg.Mock.Enabled = true
+	cfg.Server.DefaultCountry = "US"
+	cfg.RateLimit = config.RateLimitConfig{RequestsPerSecond: 1000, Burst: 1000}
+	cfg.Admin.APIKeys = []string{contractAdminKey}
+	cfg.Watermark.Enabled = true
+	cfg.Watermark.Secret = "contract-watermark-secret"
+	cfg.Watermark.Keys = map[string]string{"acme": resellerKey}
+	// Costco is down all day, so searches report a skipped source
+	costco := cfg.Scraper(config.ScraperCostco)
+	costco.Maintenance = []config.MaintenanceWindow{{Start: "00:00", End: "12:00"}, {Start: "12:00", End: "00:00"}}
+	cfg.Scrapers[config.ScraperCostco] = costco
+	// Mock results stay out of any Redis running locally: the disk cache
+	// stands in
+	cfg.Redis.URL = "redis://" + closedAddr(t)
+
+	a, err := newApp(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		a.close(ctx)
+	})
+	return a
+}
+
+// closedAddr returns a local address nothing listens on.
+func closedAddr(t *testing.T) string {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := lis.Addr().String()
+	lis.Close()
+	return addr
+}
+
+// checkContract sends a case's request and checks the response against the
+// operation in the spec. It returns the decoded JSON body, if any.
+func checkContract(t *testing.T, spec *openAPISpec, server *httptest.Server, op map[string]interface{}, tc contractCase, path string) interface{} {
+	var body io.Reader
+	if tc.Body != "" {
+		body = strings.NewReader(tc.Body)
+	}
+	req, err := http.NewRequest(tc.Method, server.URL+path, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accept := tc.Accept
+	if accept == "" {
+		accept = "application/json"
+	}
+	req.Header.Set("Accept", accept)
+	if tc.Body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if tc.APIKey != "" {
+		req.Header.Set("X-API-Key", tc.APIKey)
+	}
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != tc.Status {
+		t.Fatalf("%s %s: status %d, want %d: %s", tc.Method, path, resp.StatusCode, tc.Status, data)
+	}
+	documented, err := spec.response(op, resp.StatusCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode == http.StatusNoContent {
+		if len(data) > 0 {
+			t.Errorf("204 with a body: %s", data)
+		}
+		return nil
+	}
+	contentType := resp.Header.Get("Content-Type")
+	schema, ok := spec.mediaSchema(documented, contentType)
+	if !ok {
+		t.Fatalf("Content-Type %q is not documented for %d", contentType, resp.StatusCode)
+	}
+	if !strings.Contains(contentType, "json") {
+		return nil
+	}
+
+	value, err := decodeJSON(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("invalid JSON: %v: %s", err, data)
+	}
+	if schema == nil {
+		return value
+	}
+	for _, problem := range spec.validate("$", schema, value) {
+		t.Error(problem)
+	}
+	return value
+}
+
+// decodeJSON decodes a JSON value the way validate expects it, numbers as
+// json.Number.
+func decodeJSON(r io.Reader) (interface{}, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var value interface{}
+	err := dec.Decode(&value)
+	return value, err
+}
+
+// jsonField returns the value at a dotted path, array indexes included.
+func jsonField(value interface{}, path string) (interface{}, bool) {
+	for _, key := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if value, ok = v[key]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			value = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return value, value != nil
+}
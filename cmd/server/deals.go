@@ -0,0 +1,220 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"price-comparison-api/internal/apierr"
+	"price-comparison-api/internal/history"
+	"price-comparison-api/internal/models"
+)
+
+// dropWindows are the look-back periods accepted by /deals/drops
+var dropWindows = map[string]time.Duration{
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+}
+
+func registerDealRoutes(r *gin.Engine, historyStore *history.Store, rates map[string]float64) {
+	// Largest price decreases per category and country
+	r.GET("/deals/drops", func(c *gin.Context) {
+		if historyStore == nil {
+			c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{
+				Error:   "history_unavailable",
+				Code:    http.StatusServiceUnavailable,
+				Message: "price history is disabled (HISTORY_ENABLED)",
+			})
+			return
+		}
+
+		windowName := c.DefaultQuery("window", "24h")
+		window, ok := dropWindows[windowName]
+		if !ok {
+			writeError(c, apierr.Validation("invalid_window", "window must be one of: 24h, 7d"))
+			return
+		}
+
+		minDiscount := 0.0
+		if v := c.Query("min_discount"); v != "" {
+			d, err := strconv.ParseFloat(v, 64)
+			if err != nil || d < 0 || d >= 100 {
+				writeError(c, apierr.Validation("invalid_min_discount", "min_discount must be a percentage between 0 and 100"))
+				return
+			}
+			minDiscount = d
+		}
+
+		page := 1
+		if p, err := strconv.Atoi(c.Query("page")); err == nil && p > 0 {
+			page = p
+		}
+		limit := 20
+		if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 {
+			limit = l
+		}
+		if limit > 100 {
+			limit = 100
+		}
+
+		country := strings.ToUpper(c.Query("country"))
+		category := c.Query("category")
+
+		drops, err := historyStore.Drops(history.DropQuery{
+			Country:     country,
+			Category:    category,
+			Window:      window,
+			MinDiscount: minDiscount,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "history_error",
+				Code:    http.StatusInternalServerError,
+				Message: err.Error(),
+			})
+			return
+		}
+
+		total := len(drops)
+		start := (page - 1) * limit
+		if start > total {
+			start = total
+		}
+		end := start + limit
+		if end > total {
+			end = total
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"window":       windowName,
+			"country":      country,
+			"category":     category,
+			"min_discount": minDiscount,
+			"drops":        drops[start:end],
+			"total":        total,
+			"page":         page,
+			"limit":        limit,
+			"total_pages":  int(math.Ceil(float64(total) / float64(limit))),
+		})
+	})
+
+	// Price histories of one or more products in a single currency, each
+	// point converted at the exchange rates of its day, with each product's
+	// availability timeline and the weekdays they came back in stock on
+	r.GET("/history", func(c *gin.Context) {
+		if historyStore == nil {
+			c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{
+				Error:   "history_unavailable",
+				Code:    http.StatusServiceUnavailable,
+				Message: "price history is disabled (HISTORY_ENABLED)",
+			})
+			return
+		}
+
+		keys := c.QueryArray("key")
+		if len(keys) == 0 {
+			writeError(c, apierr.Validation("missing_key", "at least one key parameter is required"))
+			return
+		}
+		currency := strings.ToUpper(c.DefaultQuery("currency", "USD"))
+		if _, ok := rates[currency]; !ok {
+			writeError(c, apierr.Validation("invalid_currency", "unsupported currency: "+currency))
+			return
+		}
+
+		book, err := historyStore.Rates()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "history_error",
+				Code:    http.StatusInternalServerError,
+				Message: err.Error(),
+			})
+			return
+		}
+		now := time.Now()
+		series := make([]*history.Series, 0, len(keys))
+		entries := make([]*history.Entry, 0, len(keys))
+		for _, key := range keys {
+			entry, err := historyStore.Get(key)
+			if err == nil && entry == nil {
+				c.JSON(http.StatusNotFound, models.ErrorResponse{
+					Error:   "history_not_found",
+					Code:    http.StatusNotFound,
+					Message: "no price history for " + key,
+				})
+				return
+			}
+			var s *history.Series
+			if err == nil {
+				s, err = book.Convert(entry, currency, rates)
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+					Error:   "history_error",
+					Code:    http.StatusInternalServerError,
+					Message: err.Error(),
+				})
+				return
+			}
+			s.Availability = entry.Availability(now)
+			series = append(series, s)
+			entries = append(entries, entry)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"currency":         currency,
+			"series":           series,
+			"restock_weekdays": historyStore.RestockWeekdays(entries, now),
+		})
+	})
+
+	// Title, image and seller changes of the products named by key, or of
+	// every product, newest first
+	r.GET("/history/changes", func(c *gin.Context) {
+		if historyStore == nil {
+			c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{
+				Error:   "history_unavailable",
+				Code:    http.StatusServiceUnavailable,
+				Message: "price history is disabled (HISTORY_ENABLED)",
+			})
+			return
+		}
+
+		q := history.ChangeQuery{
+			Keys:    c.QueryArray("key"),
+			Country: c.Query("country"),
+			Field:   strings.ToLower(c.Query("field")),
+			Limit:   100,
+		}
+		if q.Field != "" && !slices.Contains(history.ChangeFields, q.Field) {
+			writeError(c, apierr.Validation("invalid_field", "field must be one of: "+strings.Join(history.ChangeFields, ", ")))
+			return
+		}
+		if v := c.Query("since"); v != "" {
+			since, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				writeError(c, apierr.Validation("invalid_since", "since must be an RFC 3339 time"))
+				return
+			}
+			q.Since = since
+		}
+		if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 && l < q.Limit {
+			q.Limit = l
+		}
+
+		changes, err := historyStore.Changes(q)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "history_error",
+				Code:    http.StatusInternalServerError,
+				Message: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"changes": changes, "total": len(changes)})
+	})
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"price-comparison-api/internal/apierr"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/scrapetrace"
+)
+
+// searchDiagnostics is a search response with the diagnostics debug=true
+// asks for: the request's scrape trace, with every source's fetches, their
+// status and phase timings, and the selectors that matched.
+type searchDiagnostics struct {
+	*models.SearchResponse
+	Diagnostics *scrapetrace.Trace `json:"diagnostics"`
+}
+
+// startDebugSearch prepares a debug=true search. Debug searches skip the
+// cache and show how each retailer is scraped, so they take an admin key.
+// The request gets a scrape trace even when traces aren't kept. It
+// reports false when it answered the request itself.
+func startDebugSearch(c *gin.Context, apiKeys []string) bool {
+	if key := requestAPIKey(c); key == "" || !validAPIKey(key, apiKeys) {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Code:    http.StatusUnauthorized,
+			Message: "debug=true requires a valid admin API key",
+		})
+		return false
+	}
+	if ctx := c.Request.Context(); scrapetrace.FromContext(ctx) == nil {
+		c.Request = c.Request.WithContext(scrapetrace.WithTrace(ctx, c.Writer.Header().Get("X-Request-ID")))
+	}
+	return true
+}
+
+// writeDebugError responds to a failed debug=true search with the error
+// and the diagnostics, which say why every source failed.
+func writeDebugError(c *gin.Context, err error) {
+	status, body := apierr.Response(err, "internal_error")
+	c.JSON(status, struct {
+		models.ErrorResponse
+		Diagnostics *scrapetrace.Trace `json:"diagnostics"`
+	}{body, scrapetrace.FromContext(c.Request.Context())})
+}
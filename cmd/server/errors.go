@@ -0,0 +1,13 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+	"price-comparison-api/internal/apierr"
+)
+
+// writeError responds with err's status and ErrorResponse. Errors outside
+// the apierr taxonomy are reported as internal errors.
+func writeError(c *gin.Context, err error) {
+	status, body := apierr.Response(err, "internal_error")
+	c.JSON(status, body)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/export"
+	"price-comparison-api/internal/profiles"
+	"price-comparison-api/internal/services"
+)
+
+// maxExportProducts caps the rows of an exported comparison sheet
+const maxExportProducts = 50
+
+func registerExportRoutes(r *gin.Engine, searchService *services.SearchService, profileStore *profiles.Store, cfg *config.Config) {
+	// A printable comparison sheet of a search's top offers, one row and QR
+	// code per listing; takes the /search parameters plus top
+	r.GET("/search/export.pdf", func(c *gin.Context) {
+		params := parseSearchParams(c)
+		applyProfile(c, profileStore, cfg.Profiles, &params)
+
+		top := 10
+		if t, err := strconv.Atoi(c.Query("top")); err == nil && t > 0 {
+			top = t
+		}
+		if top > maxExportProducts {
+			top = maxExportProducts
+		}
+		params.Page, params.Limit = 1, top
+
+		results, err := searchService.SearchProducts(c.Request.Context(), params)
+		if err != nil {
+			zerolog.Ctx(c.Request.Context()).Warn().Err(err).Msg("Export search error")
+			writeError(c, err)
+			return
+		}
+
+		country := params.Country
+		if country == "" {
+			country = cfg.Server.DefaultCountry
+		}
+		sheet := export.Sheet{
+			Query:     results.Query,
+			Country:   country,
+			Total:     results.Total,
+			Products:  results.Products,
+			Generated: time.Now(),
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("inline; filename=comparison-%s.pdf", fileSlug(results.Query)))
+		c.Data(http.StatusOK, "application/pdf", sheet.PDF())
+	})
+}
+
+// fileSlug turns a query into something safe in a file name.
+func fileSlug(query string) string {
+	slug := strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return unicode.ToLower(r)
+		}
+		return '-'
+	}, query)
+	slug = strings.Trim(slug, "-")
+	for strings.Contains(slug, "--") {
+		slug = strings.ReplaceAll(slug, "--", "-")
+	}
+	if slug == "" {
+		return "search"
+	}
+	return slug
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"price-comparison-api/internal/apierr"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/services"
+)
+
+// extractorSet reads which extractor set a search asks for, from the
+// X-Extractor-Set header or the extractors query parameter.
+func extractorSet(c *gin.Context) (string, error) {
+	set := c.GetHeader("X-Extractor-Set")
+	if set == "" {
+		set = c.Query("extractors")
+	}
+	return services.ParseExtractorSet(set)
+}
+
+func nextExtractorsDisabled(c *gin.Context) {
+	c.JSON(http.StatusNotFound, models.ErrorResponse{
+		Error:   "extractor_set_unavailable",
+		Code:    http.StatusNotFound,
+		Message: "no next extractor set is configured (EXTRACTORS_NEXT_CONFIG_FILE)",
+	})
+}
+
+func registerExtractorRoutes(admin *gin.RouterGroup, searchService *services.SearchService) {
+	// The stable/next comparison report and the recorded comparisons
+	admin.GET("/extractors", func(c *gin.Context) {
+		next := searchService.NextExtractors()
+		if next == nil {
+			nextExtractorsDisabled(c)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"report":      next.Report(),
+			"comparisons": next.Comparisons(),
+		})
+	})
+
+	// Run one search with both sets now
+	admin.POST("/extractors/compare", func(c *gin.Context) {
+		if searchService.NextExtractors() == nil {
+			nextExtractorsDisabled(c)
+			return
+		}
+		var req struct {
+			Query   string `json:"query" binding:"required"`
+			Country string `json:"country"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			writeError(c, apierr.Validation("invalid_request", "query is required").WithDetails(err.Error()))
+			return
+		}
+
+		comparison, err := searchService.CompareExtractors(c.Request.Context(), models.SearchParams{
+			Query:   req.Query,
+			Country: req.Country,
+		})
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, comparison)
+	})
+
+	admin.DELETE("/extractors", func(c *gin.Context) {
+		next := searchService.NextExtractors()
+		if next == nil {
+			nextExtractorsDisabled(c)
+			return
+		}
+
+		next.Reset()
+		c.JSON(http.StatusOK, gin.H{
+			"message": "extractor comparisons cleared",
+		})
+	})
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"price-comparison-api/internal/apierr"
+	"price-comparison-api/internal/catalog"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/gql"
+	"price-comparison-api/internal/history"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/profiles"
+	"price-comparison-api/internal/services"
+)
+
+// graphqlRequest is a GraphQL query, posted as JSON or passed in the query
+// string of a GET.
+type graphqlRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// ginContextKey carries the request's gin.Context to the search resolver,
+// which applies the caller's profile from it.
+type ginContextKey struct{}
+
+func registerGraphQLRoutes(r *gin.Engine, searchService *services.SearchService, catalogStore *catalog.Store, historyStore *history.Store, profileStore *profiles.Store, cfg *config.Config) {
+	schema := gql.NewSchema(gql.Options{
+		Search:         searchService,
+		Catalog:        catalogStore,
+		History:        historyStore,
+		Rates:          cfg.Currency.Rates,
+		DefaultCountry: cfg.Server.DefaultCountry,
+		PrepareSearch: func(ctx context.Context, params *models.SearchParams) {
+			if c, ok := ctx.Value(ginContextKey{}).(*gin.Context); ok {
+				applyProfile(c, profileStore, cfg.Profiles, params)
+			}
+		},
+	})
+
+	// Searches, catalog offers and price history in one query, selecting
+	// just the fields the client needs; the schema is in internal/gql
+	handler := func(c *gin.Context) {
+		var req graphqlRequest
+		if c.Request.Method == http.MethodGet {
+			req.Query = c.Query("query")
+			req.OperationName = c.Query("operationName")
+			if v := c.Query("variables"); v != "" {
+				if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+					writeError(c, apierr.Validation("invalid_variables", "variables must be a JSON object").WithDetails(err.Error()))
+					return
+				}
+			}
+		} else if err := c.ShouldBindJSON(&req); err != nil {
+			writeError(c, apierr.Validation("invalid_request", "invalid GraphQL request").WithDetails(err.Error()))
+			return
+		}
+		if req.Query == "" {
+			writeError(c, apierr.Validation("missing_query", "a GraphQL query is required"))
+			return
+		}
+
+		ctx := context.WithValue(c.Request.Context(), ginContextKey{}, c)
+		c.JSON(http.StatusOK, schema.Exec(ctx, req.Query, req.OperationName, req.Variables))
+	}
+	r.GET("/graphql", handler)
+	r.POST("/graphql", handler)
+}
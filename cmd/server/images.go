@@ -0,0 +1,65 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"price-comparison-api/internal/apierr"
+	"price-comparison-api/internal/imageproxy"
+	"price-comparison-api/internal/models"
+)
+
+func registerImageRoutes(r *gin.Engine, proxy *imageproxy.Proxy) {
+	// A product image fetched through the API, optionally as a thumbnail
+	r.GET("/images/proxy", func(c *gin.Context) {
+		if proxy == nil {
+			c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{
+				Error:   "images_disabled",
+				Code:    http.StatusServiceUnavailable,
+				Message: "the image proxy is disabled (IMAGES_ENABLED)",
+			})
+			return
+		}
+
+		source := c.Query("url")
+		if source == "" {
+			writeError(c, apierr.Validation("missing_url", "url is required"))
+			return
+		}
+		width := 0
+		if w := c.Query("w"); w != "" {
+			n, err := strconv.Atoi(w)
+			if err != nil || n <= 0 || n > proxy.MaxWidth() {
+				writeError(c, apierr.Validation("invalid_width", fmt.Sprintf("w must be a width between 1 and %d", proxy.MaxWidth())))
+				return
+			}
+			width = n
+		}
+
+		img, err := proxy.Get(c.Request.Context(), source, width)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+
+		sum := sha256.Sum256(img.Data)
+		etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+		c.Header("ETag", etag)
+		c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(proxy.CacheTTL().Seconds())))
+		c.Header("X-Content-Type-Options", "nosniff")
+		if img.Cached {
+			c.Header("X-Cache", "HIT")
+		} else {
+			c.Header("X-Cache", "MISS")
+		}
+		if c.GetHeader("If-None-Match") == etag {
+			c.Status(http.StatusNotModified)
+			return
+		}
+		c.Data(http.StatusOK, img.ContentType, img.Data)
+	})
+}
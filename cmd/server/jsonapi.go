@@ -0,0 +1,304 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"price-comparison-api/internal/catalog"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/services"
+)
+
+// jsonAPIMediaType is asked for with Accept to get catalog responses as
+// JSON:API documents (https://jsonapi.org/format/1.0/).
+const jsonAPIMediaType = "application/vnd.api+json"
+
+// JSON:API resource types
+const (
+	typeProducts  = "products"
+	typeOffers    = "offers"
+	typeRetailers = "retailers"
+)
+
+type jsonAPIDocument struct {
+	Data     interface{}       `json:"data,omitempty"`
+	Included []jsonAPIResource `json:"included,omitempty"`
+	Errors   []jsonAPIError    `json:"errors,omitempty"`
+	Meta     gin.H             `json:"meta,omitempty"`
+	Links    gin.H             `json:"links,omitempty"`
+	JSONAPI  gin.H             `json:"jsonapi"`
+}
+
+type jsonAPIResource struct {
+	Type          string                         `json:"type"`
+	ID            string                         `json:"id"`
+	Attributes    interface{}                    `json:"attributes,omitempty"`
+	Relationships map[string]jsonAPIRelationship `json:"relationships,omitempty"`
+	Links         gin.H                          `json:"links,omitempty"`
+}
+
+type jsonAPIIdentifier struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// jsonAPIRelationship holds one identifier, or a slice of them for to-many
+// relationships.
+type jsonAPIRelationship struct {
+	Data interface{} `json:"data"`
+}
+
+type jsonAPIError struct {
+	Status string `json:"status"`
+	Code   string `json:"code"`
+	Title  string `json:"title"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type productAttributes struct {
+	Country     string    `json:"country"`
+	Name        string    `json:"name"`
+	Image       string    `json:"image,omitempty"`
+	Category    string    `json:"category"`
+	LowestPrice float64   `json:"lowest_price"`
+	Currency    string    `json:"currency"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+}
+
+type offerAttributes struct {
+	Source    string    `json:"source"`
+	Merchant  string    `json:"merchant,omitempty"`
+	Country   string    `json:"country"`
+	Name      string    `json:"name"`
+	URL       string    `json:"url"`
+	Image     string    `json:"image,omitempty"`
+	Price     float64   `json:"price"`
+	Currency  string    `json:"currency"`
+	InStock   bool      `json:"in_stock"`
+	Condition string    `json:"condition,omitempty"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	TimesSeen int       `json:"times_seen"`
+}
+
+type retailerAttributes struct {
+	Name string `json:"name"`
+}
+
+// jsonAPINegotiation marks requests that accept JSON:API, so handlers answer
+// with documents. As the spec requires, a request that only accepts the
+// media type with parameters gets a 406.
+func jsonAPINegotiation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		plain, withParams := false, false
+		for _, accepted := range strings.Split(c.GetHeader("Accept"), ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+			if err != nil || mediaType != jsonAPIMediaType {
+				continue
+			}
+			delete(params, "q")
+			if len(params) == 0 {
+				plain = true
+			} else {
+				withParams = true
+			}
+		}
+		if plain || withParams {
+			c.Set("jsonapi", true)
+		}
+		if withParams && !plain {
+			writeJSONAPIError(c, http.StatusNotAcceptable, models.ErrorResponse{
+				Error:   "not_acceptable",
+				Message: jsonAPIMediaType + " is only served without media type parameters",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// wantsJSONAPI reports whether the request accepts JSON:API documents.
+func wantsJSONAPI(c *gin.Context) bool {
+	return c.GetBool("jsonapi")
+}
+
+func writeJSONAPI(c *gin.Context, status int, doc jsonAPIDocument) {
+	doc.JSONAPI = gin.H{"version": "1.0"}
+	c.Header("Content-Type", jsonAPIMediaType)
+	c.JSON(status, doc)
+}
+
+func writeJSONAPIError(c *gin.Context, status int, body models.ErrorResponse) {
+	writeJSONAPI(c, status, jsonAPIDocument{Errors: []jsonAPIError{{
+		Status: strconv.Itoa(status),
+		Code:   body.Error,
+		Title:  http.StatusText(status),
+		Detail: strings.TrimSpace(body.Message + " " + body.Details),
+	}}})
+}
+
+// jsonAPIIncludes reads the include parameter: the relationship paths whose
+// resources the document carries. Without it every path in defaults is
+// included. ok is false, after answering 400, when a path isn't in allowed.
+func jsonAPIIncludes(c *gin.Context, defaults []string, allowed ...string) (map[string]bool, bool) {
+	paths := defaults
+	if raw, given := c.GetQuery("include"); given {
+		paths = nil
+		for _, path := range strings.Split(raw, ",") {
+			if path = strings.TrimSpace(path); path != "" {
+				paths = append(paths, path)
+			}
+		}
+	}
+	include := map[string]bool{}
+	for _, path := range paths {
+		known := false
+		for _, a := range allowed {
+			known = known || a == path
+		}
+		if !known {
+			writeJSONAPIError(c, http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid_include",
+				Message: "include may only name " + strings.Join(allowed, ", "),
+			})
+			return nil, false
+		}
+		include[path] = true
+	}
+	return include, true
+}
+
+func productResource(p catalog.Product) jsonAPIResource {
+	offers := make([]jsonAPIIdentifier, 0, len(p.OfferIDs))
+	for _, id := range p.OfferIDs {
+		offers = append(offers, jsonAPIIdentifier{Type: typeOffers, ID: id})
+	}
+	return jsonAPIResource{
+		Type: typeProducts,
+		ID:   p.ID,
+		Attributes: productAttributes{
+			Country:     p.Country,
+			Name:        p.Name,
+			Image:       p.Image,
+			Category:    p.Category,
+			LowestPrice: p.LowestPrice,
+			Currency:    p.Currency,
+			FirstSeen:   p.FirstSeen,
+			LastSeen:    p.LastSeen,
+		},
+		Relationships: map[string]jsonAPIRelationship{
+			"offers": {Data: offers},
+		},
+		Links: gin.H{"self": "/catalog/products/" + p.ID},
+	}
+}
+
+func offerResource(o catalog.Offer) jsonAPIResource {
+	retailer, _ := services.Retailer(o.Source)
+	return jsonAPIResource{
+		Type: typeOffers,
+		ID:   o.ID,
+		Attributes: offerAttributes{
+			Source:    o.Source,
+			Merchant:  o.Merchant,
+			Country:   o.Country,
+			Name:      o.Name,
+			URL:       o.URL,
+			Image:     o.Image,
+			Price:     o.Price,
+			Currency:  o.Currency,
+			InStock:   o.InStock,
+			Condition: o.Condition,
+			FirstSeen: o.FirstSeen,
+			LastSeen:  o.LastSeen,
+			TimesSeen: o.TimesSeen,
+		},
+		Relationships: map[string]jsonAPIRelationship{
+			"product":  {Data: jsonAPIIdentifier{Type: typeProducts, ID: o.ProductID}},
+			"retailer": {Data: jsonAPIIdentifier{Type: typeRetailers, ID: retailer}},
+		},
+		Links: gin.H{"self": "/catalog/offers/" + o.ID},
+	}
+}
+
+// jsonAPIIncluded collects included resources once each, in the order
+// they're first added.
+type jsonAPIIncluded struct {
+	resources []jsonAPIResource
+	seen      map[jsonAPIIdentifier]bool
+}
+
+func (inc *jsonAPIIncluded) add(r jsonAPIResource) {
+	id := jsonAPIIdentifier{Type: r.Type, ID: r.ID}
+	if inc.seen == nil {
+		inc.seen = map[jsonAPIIdentifier]bool{}
+	}
+	if !inc.seen[id] {
+		inc.seen[id] = true
+		inc.resources = append(inc.resources, r)
+	}
+}
+
+func (inc *jsonAPIIncluded) addRetailer(source string) {
+	key, name := services.Retailer(source)
+	inc.add(jsonAPIResource{Type: typeRetailers, ID: key, Attributes: retailerAttributes{Name: name}})
+}
+
+// addOffers includes a product's offers and, with offers.retailer in
+// include, their retailers.
+func (inc *jsonAPIIncluded) addOffers(offers []catalog.Offer, include map[string]bool) {
+	for _, o := range offers {
+		if include["offers"] || include["offers.retailer"] {
+			inc.add(offerResource(o))
+		}
+		if include["offers.retailer"] {
+			inc.addRetailer(o.Source)
+		}
+	}
+}
+
+// catalogOffers returns the offers of each product, for the included
+// section of a product list.
+func catalogOffers(store *catalog.Store, products []catalog.Product) ([]catalog.Offer, error) {
+	var offers []catalog.Offer
+	for _, p := range products {
+		view, err := store.Get(p.ID)
+		if err != nil {
+			return nil, err
+		}
+		offers = append(offers, view.Offers...)
+	}
+	return offers, nil
+}
+
+// pageLinks are a paginated list's first, prev, next and last links.
+func pageLinks(c *gin.Context, page, limit, totalPages int) gin.H {
+	link := func(p int) string {
+		query := url.Values{}
+		for k, v := range c.Request.URL.Query() {
+			query[k] = v
+		}
+		query.Set("page", strconv.Itoa(p))
+		query.Set("limit", strconv.Itoa(limit))
+		return c.Request.URL.Path + "?" + query.Encode()
+	}
+	last := totalPages
+	if last < 1 {
+		last = 1
+	}
+	links := gin.H{"self": link(page), "first": link(1), "last": link(last)}
+	if page > 1 {
+		links["prev"] = link(page - 1)
+	}
+	if page < last {
+		links["next"] = link(page + 1)
+	}
+	return links
+}
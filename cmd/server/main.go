@@ -2,50 +2,301 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"math"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
-	"github.com/chromedp/chromedp"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
 	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"price-comparison-api/internal/alerts"
+	"price-comparison-api/internal/catalog"
+	"price-comparison-api/internal/competitors"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/grpcapi"
+	"price-comparison-api/internal/history"
+	"price-comparison-api/internal/imageproxy"
 	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/orgs"
+	"price-comparison-api/internal/profiles"
+	"price-comparison-api/internal/savedsearch"
 	"price-comparison-api/internal/scrapers"
+	"price-comparison-api/internal/scrapetrace"
 	"price-comparison-api/internal/services"
+	"price-comparison-api/internal/storage"
+	"price-comparison-api/internal/watchlist"
+	"price-comparison-api/internal/watermark"
 	"price-comparison-api/pkg/browser"
 	"price-comparison-api/pkg/cache"
+	"price-comparison-api/pkg/logger"
+	"price-comparison-api/pkg/metrics"
+	"price-comparison-api/pkg/politeness"
+	"price-comparison-api/pkg/retry"
+	"price-comparison-api/pkg/tracing"
 )
 
 var (
 	rateLimiters = make(map[string]*rate.Limiter)
-	rateMutex    = &sync.RWMutex{}
+	// rateOverrides keeps per-IP limits set through the admin API
+	rateOverrides = make(map[string]config.RateLimitConfig)
+	rateMutex     = &sync.RWMutex{}
 )
 
 func main() {
+	selfTest := flag.Bool("selftest", false, "verify config, Redis, Chrome, DNS and scraper extraction, print a JSON report and exit")
+	flag.Parse()
+
 	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found")
+		log.Info().Msg("No .env file found")
+	}
+
+	if *selfTest {
+		os.Exit(runSelfTest())
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid configuration")
+	}
+	if err := logger.Setup(cfg.Log); err != nil {
+		log.Fatal().Err(err).Msg("Invalid log configuration")
+	}
+	gin.SetMode(cfg.Server.GinMode)
+
+	shutdownTracing, err := tracing.Setup(cfg.Tracing)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to set up tracing")
+	}
+
+	politeness.Configure(cfg.Politeness)
+	retry.Configure(cfg.Retry)
+	if err := scrapers.ConfigureSelectors(cfg.Selectors); err != nil {
+		log.Fatal().Err(err).Msg("Invalid selector files")
+	}
+
+	a, err := newApp(cfg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to set up the server")
+	}
+
+	srv := &http.Server{
+		Addr:    ":" + cfg.Server.Port,
+		Handler: a.router,
+	}
+
+	stop, cancelSignals := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancelSignals()
+
+	go func() {
+		log.Info().Msgf("Starting cached server on :%s", cfg.Server.Port)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal().Err(err).Msg("Failed to start server")
+		}
+	}()
+
+	// The gRPC API for backend services, on its own port
+	var grpcServer *grpc.Server
+	if cfg.GRPC.Enabled {
+		lis, err := net.Listen("tcp", ":"+cfg.GRPC.Port)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to listen for gRPC")
+		}
+		grpcServer = grpcapi.NewServer(cfg.GRPC, grpcapi.Options{Search: a.searchService, Maintenance: a.maintenance.active})
+		go func() {
+			log.Info().Msgf("Starting gRPC server on :%s", cfg.GRPC.Port)
+			if err := grpcServer.Serve(lis); err != nil {
+				log.Fatal().Err(err).Msg("Failed to start gRPC server")
+			}
+		}()
+	}
+
+	<-stop.Done()
+	log.Info().Msgf("Shutting down, waiting up to %s for in-flight requests", cfg.Server.ShutdownTimeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	// Stop accepting requests and drain the ones in progress, then finish
+	// background scrapes before closing the stores they write to
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Warn().Err(err).Msg("HTTP server shutdown incomplete")
+	}
+	if grpcServer != nil {
+		stopGRPC(ctx, grpcServer)
+	}
+	a.close(ctx)
+	if err := shutdownTracing(ctx); err != nil {
+		log.Warn().Err(err).Msg("Failed to flush traces")
+	}
+	log.Info().Msg("Server stopped")
+}
+
+// app is the service: the stores and background workers the configuration
+// enables and the router serving the HTTP API over them.
+type app struct {
+	router            *gin.Engine
+	searchService     *services.SearchService
+	maintenance       *maintenanceMode
+	canaries          *services.CanaryRunner
+	redisCache        *cache.RedisCache
+	storageBackend    *storage.Backend
+	historyStore      *history.Store
+	catalogStore      *catalog.Store
+	alertService      *alerts.Service
+	stockChecker      *alerts.StockChecker
+	watchService      *watchlist.Service
+	savedSearches     *savedsearch.Service
+	profileStore      *profiles.Store
+	orgStore          *orgs.Store
+	competitorMonitor *competitors.Monitor
+}
+
+// newApp sets up the stores, background workers and routes of cfg. main
+// serves its router; tests serve it with httptest.
+func newApp(cfg *config.Config) (*app, error) {
+	redisCache := cache.NewRedisCache(cfg.Redis, cfg.DiskCache)
+	searchService := services.NewSearchService(cfg, redisCache)
+	searchService.SetSessions(services.NewSessionStore(cfg.Sessions))
+
+	// The catalog, history and alerts share the storage backend
+	storageBackend, err := storage.NewBackend(cfg.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up storage: %v", err)
+	}
+
+	var historyStore *history.Store
+	if cfg.History.Enabled {
+		if historyStore, err = history.NewStore(cfg.History, cfg.Reporting.Location(), storageBackend); err != nil {
+			log.Warn().Err(err).Msg("Price history disabled")
+		} else {
+			searchService.SetHistory(historyStore)
+			historyStore.TrackRates(cfg.Currency.Rates)
+		}
+	}
+
+	var catalogStore *catalog.Store
+	if cfg.Catalog.Enabled {
+		if catalogStore, err = catalog.NewStore(cfg.Catalog, storageBackend); err != nil {
+			log.Warn().Err(err).Msg("Product catalog disabled")
+			catalogStore = nil
+		} else {
+			searchService.SetCatalog(catalogStore)
+		}
+	}
+
+	var alertService *alerts.Service
+	if cfg.Alerts.Enabled {
+		if alertService, err = alerts.NewService(cfg.Alerts, historyStore, storageBackend); err != nil {
+			log.Warn().Err(err).Msg("Alerts disabled")
+			alertService = nil
+		} else {
+			alertService.OnEvent(alerts.NewNotifier(alertService, cfg.Alerts.Chat).Deliver)
+		}
+	}
+
+	var stockChecker *alerts.StockChecker
+	if alertService != nil && cfg.Alerts.StockChecks.Enabled {
+		stockChecker = alerts.NewStockChecker(cfg.Alerts.StockChecks, alertService, historyStore)
+		stockChecker.Start()
+	}
+	var watchService *watchlist.Service
+	if cfg.Watchlist.Enabled {
+		if watchService, err = watchlist.NewService(cfg.Watchlist, searchService.ProductDetail); err != nil {
+			log.Warn().Err(err).Msg("Watchlist disabled")
+			watchService = nil
+		} else {
+			watchService.Start()
+		}
+	}
+	var savedSearches *savedsearch.Service
+	if cfg.SavedSearch.Enabled {
+		if savedSearches, err = savedsearch.NewService(cfg.SavedSearch, searchService.Products); err != nil {
+			log.Warn().Err(err).Msg("Saved searches disabled")
+			savedSearches = nil
+		} else {
+			savedSearches.Start()
+		}
+	}
+	var profileStore *profiles.Store
+	if cfg.Profiles.Enabled {
+		if profileStore, err = profiles.NewStore(cfg.Profiles); err != nil {
+			log.Warn().Err(err).Msg("Preference profiles disabled")
+			profileStore = nil
+		}
+	}
+	var orgStore *orgs.Store
+	if cfg.Orgs.Enabled {
+		if orgStore, err = orgs.NewStore(cfg.Orgs); err != nil {
+			log.Warn().Err(err).Msg("Organizations disabled")
+			orgStore = nil
+		} else if alertService != nil {
+			alertService.OnEvent(orgs.NewNotifier(orgStore, cfg.Orgs.WebhookTimeout).Deliver)
+		}
+	}
+	var competitorMonitor *competitors.Monitor
+	if cfg.Competitors.Enabled {
+		if competitorMonitor, err = competitors.NewMonitor(cfg.Competitors, cfg.Server.DefaultCountry, searchService.Products, historyStore); err != nil {
+			log.Warn().Err(err).Msg("Competitor monitoring disabled")
+			competitorMonitor = nil
+		} else {
+			competitorMonitor.Start()
+		}
+	}
+	maintenance := newMaintenanceMode(cfg.Maintenance)
+
+	var imageProxy *imageproxy.Proxy
+	if cfg.Images.Enabled {
+		imageProxy = imageproxy.New(cfg.Images, redisCache)
+	}
+
+	var traceStore *scrapetrace.Store
+	if cfg.ScrapeTrace.Enabled {
+		if traceStore = scrapetrace.NewStore(redisCache, cfg.ScrapeTrace.TTL); traceStore == nil {
+			log.Warn().Msg("Scrape traces disabled: no cache to keep them in")
+		}
 	}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8085"
+	if cfg.Shadow.Enabled {
+		shadowCfg, err := cfg.Overlay(cfg.Shadow.ConfigFile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid shadow configuration: %v", err)
+		}
+		searchService.SetShadow(services.NewShadowRunner(shadowCfg, cfg.Shadow))
+	}
+	if cfg.Extractors.NextConfigFile != "" {
+		nextCfg, err := cfg.Overlay(cfg.Extractors.NextConfigFile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid next extractor set: %v", err)
+		}
+		searchService.SetNextExtractors(services.NewNextExtractors(cfg, nextCfg, cfg.Extractors))
 	}
 
-	searchService := services.NewSearchService()
-	redisCache := cache.NewRedisCache()
+	canaries := services.NewCanaryRunner(searchService, cfg.Canary)
+	canaries.Start()
 
-	r := gin.Default()
+	// Request logging is done by the request ID middleware below
+	r := gin.New()
+	r.Use(gin.Recovery())
 
 	// Add CORS middleware
 	r.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type")
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PATCH, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key, X-User-ID, X-Extractor-Set")
+		c.Header("Access-Control-Expose-Headers", "X-Request-ID, X-Session-ID, X-Extractor-Set")
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
 			return
@@ -53,19 +304,55 @@ func main() {
 		c.Next()
 	})
 
-	// Add request ID middleware
+	r.Use(tracing.Middleware())
+
+	// Add request ID middleware; the trace ID doubles as the request ID so
+	// a request can be looked up in the tracing backend. The request logger
+	// is stored on the context so services and scrapers log with the ID,
+	// and so is the scrape trace kept for GET /admin/traces/:request_id.
 	r.Use(func(c *gin.Context) {
-		requestID := fmt.Sprintf("%d", time.Now().UnixNano())
+		requestID := tracing.TraceID(c.Request.Context())
+		if requestID == "" {
+			requestID = fmt.Sprintf("%d", time.Now().UnixNano())
+		}
 		c.Header("X-Request-ID", requestID)
+
+		reqLogger := log.With().Str("request_id", requestID).Logger()
+		ctx := reqLogger.WithContext(c.Request.Context())
+		if traceStore != nil {
+			ctx = scrapetrace.WithTrace(ctx, requestID)
+		}
+		c.Request = c.Request.WithContext(ctx)
+
 		start := time.Now()
 		c.Next()
-		log.Printf("[%s] %s %s - %v - %d",
-			requestID, c.Request.Method, c.Request.URL.Path,
-			time.Since(start), c.Writer.Status())
+		if trace := scrapetrace.FromContext(ctx); trace != nil {
+			go func() {
+				saveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+				defer cancel()
+				if err := traceStore.Save(saveCtx, trace); err != nil {
+					reqLogger.Warn().Err(err).Msg("Failed to save scrape trace")
+				}
+			}()
+		}
+		reqLogger.Info().
+			Str("method", c.Request.Method).
+			Str("path", c.Request.URL.Path).
+			Int("status", c.Writer.Status()).
+			Dur("latency", time.Since(start)).
+			Msg("request")
 	})
 
+	r.Use(metrics.Middleware())
+
 	// Add rate limiting middleware (ADD THIS)
-	r.Use(rateLimitMiddleware())
+	r.Use(rateLimitMiddleware(cfg.RateLimit))
+
+	// Licensed resellers' responses are watermarked
+	marker := watermark.New(cfg.Watermark)
+	if marker != nil {
+		r.Use(watermarkMiddleware(marker))
+	}
 
 	// Enhanced health check with cache status
 	r.GET("/health", func(c *gin.Context) {
@@ -75,26 +362,61 @@ func main() {
 			"version": "1.0.0",
 		}
 
+		if maintenance.active() {
+			health["status"] = "maintenance"
+		}
+
 		if redisCache != nil && redisCache.IsAvailable() {
 			health["cache"] = "redis connected"
+			if redisCache.Backend() == "disk" {
+				health["cache"] = "redis unavailable, using disk fallback"
+			}
 		} else {
 			health["cache"] = "redis unavailable"
 		}
 
+		storageHealth := gin.H{"driver": storageBackend.Driver(), "status": "ok"}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+		defer cancel()
+		if err := storageBackend.Ping(ctx); err != nil {
+			storageHealth["status"] = "unreachable"
+			storageHealth["error"] = err.Error()
+			health["status"] = "degraded"
+		}
+		if stats := storageBackend.Stats(); stats != nil {
+			storageHealth["open_connections"] = stats.OpenConnections
+			storageHealth["in_use"] = stats.InUse
+			storageHealth["idle"] = stats.Idle
+		}
+		health["storage"] = storageHealth
+
 		c.JSON(http.StatusOK, health)
 	})
 
+	// Prometheus scrape endpoint
+	r.GET("/metrics", metrics.Handler())
+
 	// Rate limit status endpoint
 	r.GET("/rate-limit/status", func(c *gin.Context) {
 		ip := c.ClientIP()
-		limiter := getRateLimiter(ip)
+		limiter := getRateLimiter(ip, cfg.RateLimit)
+		now := time.Now()
+		tokens := limiter.TokensAt(now)
+		wait := rateLimitWait(limiter, now)
+		// What a 429 sent now would say: none while a token is left
+		retryAfter := 0
+		if wait > 0 {
+			retryAfter = retryAfterSeconds(wait)
+		}
 
 		c.JSON(http.StatusOK, gin.H{
-			"ip":               ip,
-			"limit_per_second": limiter.Limit(),
-			"burst_capacity":   limiter.Burst(),
-			"tokens_available": limiter.Tokens(),
-			"next_token_at":    time.Now().Add(time.Second / time.Duration(limiter.Limit())),
+			"ip":                  ip,
+			"limit_per_second":    limiter.Limit(),
+			"burst_capacity":      limiter.Burst(),
+			"tokens_available":    tokens,
+			"next_token_at":       now.Add(wait),
+			"wait_seconds":        wait.Seconds(),
+			"retry_after_seconds": retryAfter,
 		})
 	})
 
@@ -111,132 +433,137 @@ func main() {
 		c.JSON(http.StatusOK, stats)
 	})
 
-	// Cache debug endpoint
-	r.GET("/cache/debug", func(c *gin.Context) {
-		if redisCache == nil {
-			c.JSON(http.StatusServiceUnavailable, gin.H{
-				"error": "cache not available",
-			})
+	// Enhanced search endpoint with caching
+	r.GET("/search", maintenance.searchGuard(searchService), func(c *gin.Context) {
+		params := parseSearchParams(c)
+		applyProfile(c, profileStore, cfg.Profiles, &params)
+		if params.Debug && !startDebugSearch(c, cfg.Admin.APIKeys) {
 			return
 		}
-
-		keys := redisCache.GetAllKeys()
-
-		// Get detailed info for each key
-		keyDetails := make([]gin.H, 0, len(keys))
-		for _, key := range keys {
-			ttl := redisCache.GetKeyTTL(key)
-			keyDetails = append(keyDetails, gin.H{
-				"key":         key,
-				"ttl_seconds": int(ttl.Seconds()),
-				"expires_in":  ttl.String(),
-			})
+		set, err := extractorSet(c)
+		if err != nil {
+			writeError(c, err)
+			return
 		}
-
-		c.JSON(http.StatusOK, gin.H{
-			"total_keys":  len(keys),
-			"cache_keys":  keyDetails,
-			"cache_stats": redisCache.GetStats(),
-			"debug_info": gin.H{
-				"redis_available": redisCache.IsAvailable(),
-				"timestamp":       time.Now().Format(time.RFC3339),
-			},
-		})
-	})
-
-	// Cache flush endpoint (for testing)
-	r.DELETE("/cache/flush", func(c *gin.Context) {
-		if redisCache == nil {
-			c.JSON(http.StatusServiceUnavailable, gin.H{
-				"error": "cache not available",
-			})
+		format, err := priceFormat(c)
+		if err != nil {
+			writeError(c, err)
 			return
 		}
 
-		if err := redisCache.FlushCache(); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "failed to flush cache",
-				"details": err.Error(),
-			})
+		// The next extractor set answers without a session to refine
+		var results *models.SearchResponse
+		if set == services.ExtractorNext {
+			results, err = searchService.SearchNext(c.Request.Context(), params)
+		} else {
+			searchService.CountServed(set)
+			results, err = searchService.StartSession(c.Request.Context(), params)
+		}
+		if err != nil {
+			zerolog.Ctx(c.Request.Context()).Warn().Err(err).Msg("Search error")
+			if params.Debug {
+				writeDebugError(c, err)
+				return
+			}
+			writeError(c, err)
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{
-			"message":   "cache flushed successfully",
-			"timestamp": time.Now().Format(time.RFC3339),
-		})
+		if results.SessionID != "" {
+			c.Header("X-Session-ID", results.SessionID)
+		}
+		c.Header("X-Extractor-Set", set)
+		country := params.Country
+		if country == "" {
+			country = cfg.Server.DefaultCountry
+		}
+		if params.Debug {
+			writePrices(c, searchDiagnostics{results, scrapetrace.FromContext(c.Request.Context())}, format, country)
+			return
+		}
+		writePrices(c, results, format, country)
 	})
 
-	// Enhanced search endpoint with caching
-	r.GET("/search", func(c *gin.Context) {
+	// What a search would scrape, without scraping
+	r.GET("/search/plan", func(c *gin.Context) {
 		params := parseSearchParams(c)
+		applyProfile(c, profileStore, cfg.Profiles, &params)
 
-		results, err := searchService.SearchProducts(params)
+		plan, err := searchService.Plan(c.Request.Context(), params)
 		if err != nil {
-			log.Printf("Search error: %v", err)
-			c.JSON(http.StatusBadRequest, models.ErrorResponse{
-				Error:   "search_failed",
-				Code:    http.StatusBadRequest,
-				Message: err.Error(),
-			})
+			writeError(c, err)
 			return
 		}
-
-		c.JSON(http.StatusOK, results)
+		c.JSON(http.StatusOK, plan)
 	})
 
-	// Test Chrome availability
-	r.GET("/test/chrome-basic", func(c *gin.Context) {
-		log.Printf("Testing basic Chrome functionality...")
-
-		// Enhanced Chrome options for macOS
-		opts := append(chromedp.DefaultExecAllocatorOptions[:],
-			chromedp.Flag("headless", true),
-			chromedp.Flag("disable-gpu", true),
-			chromedp.Flag("disable-dev-shm-usage", true),
-			chromedp.Flag("disable-extensions", true),
-			chromedp.Flag("no-sandbox", true),
-			chromedp.Flag("disable-web-security", true),
-			chromedp.Flag("disable-features", "VizDisplayCompositor"),
-			chromedp.Flag("disable-background-timer-throttling", true),
-			chromedp.Flag("disable-backgrounding-occluded-windows", true),
-			chromedp.Flag("disable-renderer-backgrounding", true),
-			chromedp.Flag("disable-field-trial-config", true),
-			chromedp.Flag("disable-ipc-flooding-protection", true),
-			chromedp.ExecPath("/Applications/Google Chrome.app/Contents/MacOS/Google Chrome"),
-			chromedp.UserAgent("Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36"),
-		)
-
-		allocCtx, allocCancel := chromedp.NewExecAllocator(context.Background(), opts...)
-		defer allocCancel()
-
-		ctx, cancel := chromedp.NewContext(allocCtx)
-		defer cancel()
-
-		ctx, timeoutCancel := context.WithTimeout(ctx, 15*time.Second)
-		defer timeoutCancel()
-
-		var title string
-		err := chromedp.Run(ctx,
-			chromedp.Navigate("https://httpbin.org/get"),
-			chromedp.Sleep(2*time.Second),
-			chromedp.WaitVisible("body", chromedp.ByQuery),
-			chromedp.Title(&title),
-		)
+	// Whatever a search can answer within a few seconds without scraping:
+	// the cache, the catalog and the official APIs
+	r.GET("/search/quick", func(c *gin.Context) {
+		params := parseSearchParams(c)
+		applyProfile(c, profileStore, cfg.Profiles, &params)
+		format, err := priceFormat(c)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
 
+		results, err := searchService.QuickSearch(c.Request.Context(), params)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":      "Chrome test failed",
-				"details":    err.Error(),
-				"suggestion": "Try installing Chrome: brew install --cask google-chrome",
-			})
+			writeError(c, err)
 			return
 		}
+		country := params.Country
+		if country == "" {
+			country = cfg.Server.DefaultCountry
+		}
+		writePrices(c, results, format, country)
+	})
 
-		c.JSON(http.StatusOK, gin.H{
-			"status":  "Chrome working",
-			"title":   title,
-			"message": "Chrome browser is properly configured",
+	registerExportRoutes(r, searchService, profileStore, cfg)
+	registerWSRoutes(r, searchService, profileStore, cfg, maintenance)
+	registerGraphQLRoutes(r, searchService, catalogStore, historyStore, profileStore, cfg)
+	registerSessionRoutes(r, searchService)
+	registerProductRoutes(r, searchService)
+	registerDealRoutes(r, historyStore, cfg.Currency.Rates)
+	registerStatsRoutes(r, historyStore, catalogStore)
+	registerCatalogRoutes(r, catalogStore)
+	registerWatchlistRoutes(r, watchService, catalogStore, cfg)
+	registerSavedSearchRoutes(r, savedSearches, cfg)
+	registerAlertRoutes(r, alertService, stockChecker, profileStore, cfg)
+	registerProfileRoutes(r, profileStore, cfg)
+	registerOrgRoutes(r, orgStore, alertService, historyStore, cfg)
+	registerCompetitorRoutes(r, competitorMonitor, searchService.Attribution(), cfg)
+	registerImageRoutes(r, imageProxy)
+
+	// Test Chrome availability
+	r.GET("/test/chrome-basic", func(c *gin.Context) {
+		log.Info().Msg("Testing basic Chrome functionality...")
+
+		renderer, err := browser.NewRenderer(cfg.Chrome)
+		if err == nil {
+			defer renderer.Close()
+
+			ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+			defer cancel()
+
+			var html string
+			if html, err = renderer.Render(ctx, "https://httpbin.org/get", false); err == nil {
+				c.JSON(http.StatusOK, gin.H{
+					"status":  "Chrome working",
+					"backend": cfg.Chrome.Backend,
+					"bytes":   len(html),
+					"message": "Chrome browser is properly configured",
+				})
+				return
+			}
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":      "Chrome test failed",
+			"backend":    cfg.Chrome.Backend,
+			"details":    err.Error(),
+			"suggestion": "Install Chrome (brew install --cask google-chrome), or set CHROME_BACKEND=rod to have one downloaded, or CHROME_BACKEND=remote with CHROME_REMOTE_URL to use a running browser",
 		})
 	})
 
@@ -251,10 +578,10 @@ func main() {
 			country = "US"
 		}
 
-		chromeScraper := browser.NewChromeScraper()
+		chromeScraper := browser.NewChromeScraper(cfg.Chrome)
 		defer chromeScraper.Close()
 
-		products, err := chromeScraper.SearchUniversal(query, country)
+		products, err := chromeScraper.SearchUniversal(c.Request.Context(), query, country)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   "Chrome scraper failed",
@@ -283,8 +610,8 @@ func main() {
 			country = "IN"
 		}
 
-		amazonScraper := scrapers.NewAmazonScraper()
-		products, err := amazonScraper.Search(query, country)
+		amazonScraper := scrapers.NewAmazonScraper(cfg.Scraper(config.ScraperAmazon))
+		products, err := amazonScraper.Search(c.Request.Context(), query, country)
 
 		c.JSON(http.StatusOK, gin.H{
 			"scraper":  "Amazon",
@@ -307,8 +634,8 @@ func main() {
 			country = "IN"
 		}
 
-		ebayScraper := scrapers.NewEbayScraper()
-		products, err := ebayScraper.Search(query, country)
+		ebayScraper := scrapers.NewEbayScraper(cfg.Scraper(config.ScraperEbay))
+		products, err := ebayScraper.Search(c.Request.Context(), query, country)
 
 		c.JSON(http.StatusOK, gin.H{
 			"scraper":  "eBay",
@@ -331,8 +658,8 @@ func main() {
 			country = "IN"
 		}
 
-		flipkartScraper := scrapers.NewFlipkartScraper()
-		products, err := flipkartScraper.Search(query, country)
+		flipkartScraper := scrapers.NewFlipkartScraper(cfg.Scraper(config.ScraperFlipkart))
+		products, err := flipkartScraper.Search(c.Request.Context(), query, country)
 
 		c.JSON(http.StatusOK, gin.H{
 			"scraper":  "Flipkart",
@@ -355,8 +682,8 @@ func main() {
 			country = "US"
 		}
 
-		walmartScraper := scrapers.NewWalmartScraper()
-		products, err := walmartScraper.Search(query, country)
+		walmartScraper := scrapers.NewWalmartScraper(cfg.Scraper(config.ScraperWalmart))
+		products, err := walmartScraper.Search(c.Request.Context(), query, country)
 
 		c.JSON(http.StatusOK, gin.H{
 			"scraper":  "Walmart",
@@ -379,8 +706,8 @@ func main() {
 			country = "US"
 		}
 
-		targetScraper := scrapers.NewTargetScraper()
-		products, err := targetScraper.Search(query, country)
+		targetScraper := scrapers.NewTargetScraper(cfg.Scraper(config.ScraperTarget))
+		products, err := targetScraper.Search(c.Request.Context(), query, country)
 
 		c.JSON(http.StatusOK, gin.H{
 			"scraper":  "Target",
@@ -403,8 +730,8 @@ func main() {
 			country = "US"
 		}
 
-		bestBuyScraper := scrapers.NewBestBuyScraper()
-		products, err := bestBuyScraper.Search(query, country)
+		bestBuyScraper := scrapers.NewBestBuyScraper(cfg.Scraper(config.ScraperBestBuy))
+		products, err := bestBuyScraper.Search(c.Request.Context(), query, country)
 
 		c.JSON(http.StatusOK, gin.H{
 			"scraper":  "Best Buy",
@@ -416,6 +743,158 @@ func main() {
 		})
 	})
 
+	// Test Google Shopping scraper individually (no browser fallback)
+	r.GET("/test/google-shopping", func(c *gin.Context) {
+		query := c.Query("q")
+		country := c.Query("country")
+		if query == "" {
+			query = "smartphone"
+		}
+		if country == "" {
+			country = "US"
+		}
+
+		googleScraper := scrapers.NewGoogleShoppingScraper(cfg.Scraper(config.ScraperGoogleShopping))
+		products, err := googleScraper.Search(c.Request.Context(), query, country)
+
+		c.JSON(http.StatusOK, gin.H{
+			"scraper":  "Google Shopping",
+			"country":  country,
+			"query":    query,
+			"count":    len(products),
+			"products": products,
+			"error":    err,
+		})
+	})
+
+	// Test AliExpress scraper individually (plain HTML, no browser)
+	r.GET("/test/aliexpress", func(c *gin.Context) {
+		query := c.Query("q")
+		country := c.Query("country")
+		if query == "" {
+			query = "smartphone"
+		}
+		if country == "" {
+			country = "US"
+		}
+
+		aliExpressScraper := scrapers.NewAliExpressScraper(cfg.Scraper(config.ScraperAliExpress))
+		products, err := aliExpressScraper.Search(c.Request.Context(), query, country)
+
+		c.JSON(http.StatusOK, gin.H{
+			"scraper":  "AliExpress",
+			"country":  country,
+			"query":    query,
+			"count":    len(products),
+			"products": products,
+			"error":    err,
+		})
+	})
+
+	// Test Costco scraper individually
+	r.GET("/test/costco", func(c *gin.Context) {
+		query := c.Query("q")
+		country := c.Query("country")
+		if query == "" {
+			query = "paper towels"
+		}
+		if country == "" {
+			country = "US"
+		}
+
+		costcoScraper := scrapers.NewCostcoScraper(cfg.Scraper(config.ScraperCostco))
+		products, err := costcoScraper.Search(c.Request.Context(), query, country)
+
+		c.JSON(http.StatusOK, gin.H{
+			"scraper":  "Costco",
+			"country":  country,
+			"query":    query,
+			"count":    len(products),
+			"products": products,
+			"error":    err,
+		})
+	})
+
+	// Test Sam's Club scraper individually
+	r.GET("/test/samsclub", func(c *gin.Context) {
+		query := c.Query("q")
+		country := c.Query("country")
+		if query == "" {
+			query = "paper towels"
+		}
+		if country == "" {
+			country = "US"
+		}
+
+		samsClubScraper := scrapers.NewSamsClubScraper(cfg.Scraper(config.ScraperSamsClub))
+		products, err := samsClubScraper.Search(c.Request.Context(), query, country)
+
+		c.JSON(http.StatusOK, gin.H{
+			"scraper":  "Sam's Club",
+			"country":  country,
+			"query":    query,
+			"count":    len(products),
+			"products": products,
+			"error":    err,
+		})
+	})
+
+	// Test Etsy scraper individually (US, UK, CA, AU)
+	r.GET("/test/etsy", func(c *gin.Context) {
+		query := c.Query("q")
+		country := c.Query("country")
+		if query == "" {
+			query = "handmade mug"
+		}
+		if country == "" {
+			country = "US"
+		}
+
+		etsyScraper := scrapers.NewEtsyScraper(cfg.Scraper(config.ScraperEtsy))
+		products, err := etsyScraper.Search(c.Request.Context(), query, country)
+
+		c.JSON(http.StatusOK, gin.H{
+			"scraper":  "Etsy",
+			"country":  country,
+			"query":    query,
+			"count":    len(products),
+			"products": products,
+			"error":    err,
+		})
+	})
+
+	// Test Mercado Libre scraper individually (MX, BR, AR)
+	r.GET("/test/mercadolibre", func(c *gin.Context) {
+		query := c.Query("q")
+		country := c.Query("country")
+		if query == "" {
+			query = "audifonos bluetooth"
+		}
+		if country == "" {
+			country = "MX"
+		}
+
+		mercadoLibreScraper := scrapers.NewMercadoLibreScraper(cfg.Scraper(config.ScraperMercadoLibre))
+		products, err := mercadoLibreScraper.Search(c.Request.Context(), query, country)
+
+		c.JSON(http.StatusOK, gin.H{
+			"scraper":  "Mercado Libre",
+			"country":  country,
+			"query":    query,
+			"count":    len(products),
+			"products": products,
+			"error":    err,
+		})
+	})
+
+	admin := r.Group("/admin", adminAuthMiddleware(cfg.Admin.APIKeys))
+	registerAdminRoutes(admin, cfg, searchService, redisCache, maintenance)
+	registerConfigRoutes(admin, cfg, searchService, maintenance)
+	registerExtractorRoutes(admin, searchService)
+	registerCanaryRoutes(r, admin, canaries)
+	registerTraceRoutes(admin, traceStore)
+	registerWatermarkRoutes(admin, marker)
+
 	// API info endpoint
 	r.GET("/api/info", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
@@ -424,18 +903,90 @@ func main() {
 			"description": "API for comparing product prices across multiple sources",
 			"features":    []string{"Multi-source scraping", "Price comparison", "Redis caching", "Filtering", "Sorting", "Pagination"},
 			"endpoints": map[string]string{
-				"GET /search":      "Search products with filtering and sorting",
-				"GET /health":      "Health check",
-				"GET /cache/stats": "Cache statistics",
-				"GET /api/info":    "API information",
+				"GET /search":          "Search products with filtering and sorting",
+				"GET /health":          "Health check",
+				"GET /health/scrapers": "Last canary search of each retailer",
+				"GET /cache/stats":     "Cache statistics",
+				"GET /api/info":        "API information",
 			},
 			"supported_sources": []string{"Amazon", "eBay"},
 		})
 	})
 
-	log.Printf("Starting cached server on :%s", port)
-	if err := r.Run(":" + port); err != nil {
-		log.Fatal("Failed to start server:", err)
+	return &app{
+		router:            r,
+		searchService:     searchService,
+		maintenance:       maintenance,
+		canaries:          canaries,
+		redisCache:        redisCache,
+		storageBackend:    storageBackend,
+		historyStore:      historyStore,
+		catalogStore:      catalogStore,
+		alertService:      alertService,
+		stockChecker:      stockChecker,
+		watchService:      watchService,
+		savedSearches:     savedSearches,
+		profileStore:      profileStore,
+		orgStore:          orgStore,
+		competitorMonitor: competitorMonitor,
+	}, nil
+}
+
+// close stops the background workers and finishes background scrapes, then
+// closes the stores they write to.
+func (a *app) close(ctx context.Context) {
+	a.canaries.Stop()
+	if err := a.searchService.Shutdown(ctx); err != nil {
+		log.Warn().Err(err).Msg("Search service shutdown incomplete")
+	}
+	a.stockChecker.Stop()
+	a.watchService.Stop()
+	if err := a.watchService.Close(); err != nil {
+		log.Warn().Err(err).Msg("Failed to close watchlist store")
+	}
+	a.savedSearches.Stop()
+	if err := a.savedSearches.Close(); err != nil {
+		log.Warn().Err(err).Msg("Failed to close saved searches store")
+	}
+	if err := a.alertService.Close(); err != nil {
+		log.Warn().Err(err).Msg("Failed to close alerts store")
+	}
+	if err := a.competitorMonitor.Close(); err != nil {
+		log.Warn().Err(err).Msg("Failed to close competitors store")
+	}
+	if err := a.orgStore.Close(); err != nil {
+		log.Warn().Err(err).Msg("Failed to close orgs store")
+	}
+	if err := a.profileStore.Close(); err != nil {
+		log.Warn().Err(err).Msg("Failed to close profiles store")
+	}
+	if err := a.historyStore.Close(); err != nil {
+		log.Warn().Err(err).Msg("Failed to close history store")
+	}
+	if err := a.catalogStore.Close(); err != nil {
+		log.Warn().Err(err).Msg("Failed to close catalog store")
+	}
+	if err := a.storageBackend.Close(); err != nil {
+		log.Warn().Err(err).Msg("Failed to close storage")
+	}
+	if err := a.redisCache.Close(); err != nil {
+		log.Warn().Err(err).Msg("Failed to close cache")
+	}
+}
+
+// stopGRPC lets in-flight calls finish, cutting off the ones still running
+// when ctx is done.
+func stopGRPC(ctx context.Context, grpcServer *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		log.Warn().Msg("gRPC server shutdown incomplete")
+		grpcServer.Stop()
 	}
 }
 
@@ -502,6 +1053,63 @@ func parseSearchParams(c *gin.Context) models.SearchParams {
 		}
 	}
 
+	if minDiscount := c.Query("min_discount"); minDiscount != "" {
+		if filters == nil {
+			filters = &models.Filters{}
+		}
+		if discount, err := strconv.ParseFloat(minDiscount, 64); err == nil {
+			filters.MinDiscount = discount
+		}
+	}
+
+	if membership := c.Query("requires_membership"); membership != "" {
+		if filters == nil {
+			filters = &models.Filters{}
+		}
+		if member, err := strconv.ParseBool(membership); err == nil {
+			filters.RequiresMembership = &member
+		}
+	}
+
+	if category := c.Query("category"); category != "" {
+		if filters == nil {
+			filters = &models.Filters{}
+		}
+		filters.Category = category
+	}
+
+	if seller := c.Query("seller"); seller != "" {
+		if filters == nil {
+			filters = &models.Filters{}
+		}
+		filters.Seller = seller
+	}
+
+	if condition := c.Query("condition"); condition != "" {
+		if filters == nil {
+			filters = &models.Filters{}
+		}
+		filters.Condition = condition
+	}
+
+	if exclude := c.Query("exclude_marketplace_sellers"); exclude != "" {
+		if filters == nil {
+			filters = &models.Filters{}
+		}
+		if on, err := strconv.ParseBool(exclude); err == nil {
+			filters.ExcludeMarketplaceSellers = on
+		}
+	}
+
+	if strict := c.Query("strict"); strict != "" {
+		if filters == nil {
+			filters = &models.Filters{}
+		}
+		if on, err := strconv.ParseBool(strict); err == nil {
+			filters.Strict = &on
+		}
+	}
+
 	// Parse sort
 	var sort *models.Sort
 	if sortField := c.Query("sort"); sortField != "" {
@@ -514,24 +1122,51 @@ func parseSearchParams(c *gin.Context) models.SearchParams {
 		}
 	}
 
+	debug, _ := strconv.ParseBool(c.Query("debug"))
+
+	// Personal preferences, applied on top of the shared cache
+	prefs := &models.Preferences{
+		PreferredRetailers: splitList(c.Query("preferred_retailers")),
+		ExcludedSellers:    splitList(c.Query("excluded_sellers")),
+		Currency:           c.Query("currency"),
+		SafeSearch:         c.Query("safe_search"),
+	}
+	if prefs.Empty() {
+		prefs = nil
+	}
+
 	return models.SearchParams{
-		Query:   query,
-		Country: country,
-		Page:    page,
-		Limit:   limit,
-		Filters: filters,
-		Sort:    sort,
+		Query:       query,
+		Country:     country,
+		Page:        page,
+		Limit:       limit,
+		Filters:     filters,
+		Sort:        sort,
+		Preferences: prefs,
+		ResultSetID: c.Query("result_set"),
+		Debug:       debug,
 	}
 }
 
-func getRateLimiter(ip string) *rate.Limiter {
+// splitList splits a comma-separated query parameter.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func getRateLimiter(ip string, cfg config.RateLimitConfig) *rate.Limiter {
 	rateMutex.RLock()
 	limiter, exists := rateLimiters[ip]
 	rateMutex.RUnlock()
 
 	if !exists {
 		rateMutex.Lock()
-		limiter = rate.NewLimiter(rate.Limit(10), 20) // 10 req/sec, burst 20
+		limiter = rate.NewLimiter(rate.Limit(cfg.RequestsPerSecond), cfg.Burst)
 		rateLimiters[ip] = limiter
 		rateMutex.Unlock()
 	}
@@ -539,17 +1174,73 @@ func getRateLimiter(ip string) *rate.Limiter {
 	return limiter
 }
 
-func rateLimitMiddleware() gin.HandlerFunc {
+func setRateLimitOverride(ip string, cfg config.RateLimitConfig) {
+	rateMutex.Lock()
+	defer rateMutex.Unlock()
+	rateOverrides[ip] = cfg
+	rateLimiters[ip] = rate.NewLimiter(rate.Limit(cfg.RequestsPerSecond), cfg.Burst)
+}
+
+func clearRateLimitOverride(ip string) {
+	rateMutex.Lock()
+	defer rateMutex.Unlock()
+	delete(rateOverrides, ip)
+	delete(rateLimiters, ip)
+}
+
+func rateLimitOverrides() map[string]config.RateLimitConfig {
+	rateMutex.RLock()
+	defer rateMutex.RUnlock()
+	overrides := make(map[string]config.RateLimitConfig, len(rateOverrides))
+	for ip, cfg := range rateOverrides {
+		overrides[ip] = cfg
+	}
+	return overrides
+}
+
+// rateLimitWait is how long until limiter's bucket refills to a whole token,
+// so the next request from its IP is let through; 0 when one already would be.
+func rateLimitWait(limiter *rate.Limiter, now time.Time) time.Duration {
+	tokens := limiter.TokensAt(now)
+	if tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - tokens) / float64(limiter.Limit()) * float64(time.Second))
+}
+
+// retryAfterSeconds rounds a wait up to the whole seconds of a Retry-After
+// header, at least one so clients don't retry at once.
+func retryAfterSeconds(wait time.Duration) int {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
+
+// retryAfterText spells out a Retry-After wait for the 429 message.
+func retryAfterText(seconds int) string {
+	if seconds == 1 {
+		return "1 second"
+	}
+	return fmt.Sprintf("%d seconds", seconds)
+}
+
+func rateLimitMiddleware(cfg config.RateLimitConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ip := c.ClientIP()
-		limiter := getRateLimiter(ip)
+		limiter := getRateLimiter(ip, cfg)
 
 		if !limiter.Allow() {
+			metrics.RateLimitRejected()
+			retryAfter := retryAfterSeconds(rateLimitWait(limiter, time.Now()))
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
 			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error":       "rate_limit_exceeded",
-				"message":     "Too many requests from your IP",
-				"retry_after": "1 second",
-				"ip":          ip,
+				"error":               "rate_limit_exceeded",
+				"message":             "Too many requests from your IP",
+				"retry_after":         retryAfterText(retryAfter),
+				"retry_after_seconds": retryAfter,
+				"ip":                  ip,
 			})
 			c.Abort()
 			return
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/services"
+)
+
+// maintenanceMode pauses scraping while operators work on Redis or the
+// database, without taking the process down.
+type maintenanceMode struct {
+	mu          sync.RWMutex
+	enabled     bool
+	serveCached bool
+	message     string
+	retryAfter  time.Duration
+	since       time.Time
+}
+
+func newMaintenanceMode(cfg config.MaintenanceConfig) *maintenanceMode {
+	m := &maintenanceMode{
+		enabled:     cfg.Enabled,
+		serveCached: cfg.ServeCached,
+		message:     cfg.Message,
+		retryAfter:  cfg.RetryAfter,
+	}
+	if m.enabled {
+		m.since = time.Now()
+	}
+	return m
+}
+
+func (m *maintenanceMode) set(enabled bool, message string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if enabled && !m.enabled {
+		m.since = time.Now()
+	}
+	if !enabled {
+		m.since = time.Time{}
+	}
+	m.enabled = enabled
+	if message != "" {
+		m.message = message
+	}
+}
+
+func (m *maintenanceMode) settings() maintenanceSettings {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return maintenanceSettings{Enabled: m.enabled, Message: m.message}
+}
+
+func (m *maintenanceMode) active() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.enabled
+}
+
+func (m *maintenanceMode) status() gin.H {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	status := gin.H{
+		"maintenance":         m.enabled,
+		"serve_cached":        m.serveCached,
+		"message":             m.message,
+		"retry_after_seconds": int(m.retryAfter.Seconds()),
+	}
+	if m.enabled {
+		status["since"] = m.since.Format(time.RFC3339)
+	}
+	return status
+}
+
+// searchGuard serves /search from the cache (when allowed) while maintenance
+// is on, and otherwise answers 503 with a Retry-After hint.
+func (m *maintenanceMode) searchGuard(searchService *services.SearchService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !m.active() {
+			c.Next()
+			return
+		}
+
+		m.mu.RLock()
+		serveCached, message, retryAfter := m.serveCached, m.message, m.retryAfter
+		m.mu.RUnlock()
+
+		c.Header("X-Maintenance-Mode", "true")
+
+		if serveCached {
+			if cached, err := searchService.SearchCached(c.Request.Context(), parseSearchParams(c)); err == nil && cached != nil {
+				c.AbortWithStatusJSON(http.StatusOK, cached)
+				return
+			}
+		}
+
+		if retryAfter > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+		}
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, models.ErrorResponse{
+			Error:   "maintenance",
+			Code:    http.StatusServiceUnavailable,
+			Message: message,
+		})
+	}
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// openAPISpecPath is the API description clients are generated from.
+const openAPISpecPath = "../../api/openapi.yaml"
+
+// openAPISpec is the part of an OpenAPI 3.1 document the contract tests
+// read: the operations of each path and the components they refer to.
+type openAPISpec struct {
+	doc   map[string]interface{}
+	paths []specPath
+}
+
+// specPath is one path template and a pattern matching the request paths
+// it describes.
+type specPath struct {
+	template string
+	pattern  *regexp.Regexp
+	params   int
+	item     map[string]interface{}
+}
+
+// specMethods are the operations a path item may hold.
+var specMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+var pathParamPattern = regexp.MustCompile(`\{([^}/]+)\}`)
+
+func loadOpenAPISpec(t *testing.T) *openAPISpec {
+	t.Helper()
+	data, err := os.ReadFile(openAPISpecPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("%s: %v", openAPISpecPath, err)
+	}
+	spec := &openAPISpec{doc: doc}
+
+	paths, _ := doc["paths"].(map[string]interface{})
+	for template, item := range paths {
+		segments := strings.Split(template, "/")
+		for i, segment := range segments {
+			if pathParamPattern.MatchString(segment) {
+				segments[i] = `[^/]+`
+			} else {
+				segments[i] = regexp.QuoteMeta(segment)
+			}
+		}
+		spec.paths = append(spec.paths, specPath{
+			template: template,
+			pattern:  regexp.MustCompile("^" + strings.Join(segments, "/") + "$"),
+			params:   len(pathParamPattern.FindAllString(template, -1)),
+			item:     item.(map[string]interface{}),
+		})
+	}
+	// Literal segments win over parameters, so /alerts/events isn't read
+	// as /alerts/{id}
+	sort.Slice(spec.paths, func(i, j int) bool {
+		if spec.paths[i].params != spec.paths[j].params {
+			return spec.paths[i].params < spec.paths[j].params
+		}
+		return spec.paths[i].template < spec.paths[j].template
+	})
+	return spec
+}
+
+// operation returns the operation a request is sent to and its path
+// template.
+func (s *openAPISpec) operation(method, path string) (map[string]interface{}, string, error) {
+	for _, p := range s.paths {
+		if !p.pattern.MatchString(path) {
+			continue
+		}
+		op, ok := p.item[strings.ToLower(method)].(map[string]interface{})
+		if !ok {
+			return nil, p.template, fmt.Errorf("%s has no %s operation", p.template, method)
+		}
+		return op, p.template, nil
+	}
+	return nil, "", fmt.Errorf("no path describes %s", path)
+}
+
+// response returns the response an operation documents for status.
+func (s *openAPISpec) response(op map[string]interface{}, status int) (map[string]interface{}, error) {
+	responses, _ := op["responses"].(map[string]interface{})
+	r, ok := responses[fmt.Sprint(status)]
+	if !ok {
+		if r, ok = responses["default"]; !ok {
+			return nil, fmt.Errorf("operation %v documents no %d response", op["operationId"], status)
+		}
+	}
+	resolved, err := s.resolve(r)
+	if err != nil {
+		return nil, err
+	}
+	return resolved.(map[string]interface{}), nil
+}
+
+// mediaSchema returns the schema a response documents for a Content-Type,
+// matching wildcards like image/*.
+func (s *openAPISpec) mediaSchema(response map[string]interface{}, contentType string) (interface{}, bool) {
+	content, _ := response["content"].(map[string]interface{})
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(mediaType)
+	for documented, media := range content {
+		if documented == mediaType || strings.HasSuffix(documented, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(documented, "*")) {
+			return media.(map[string]interface{})["schema"], true
+		}
+	}
+	return nil, false
+}
+
+// resolve follows a local $ref.
+func (s *openAPISpec) resolve(node interface{}) (interface{}, error) {
+	for {
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return node, nil
+		}
+		ref, ok := m["$ref"].(string)
+		if !ok {
+			return node, nil
+		}
+		target, err := s.lookup(ref)
+		if err != nil {
+			return nil, err
+		}
+		node = target
+	}
+}
+
+func (s *openAPISpec) lookup(ref string) (interface{}, error) {
+	pointer, ok := strings.CutPrefix(ref, "#/")
+	if !ok {
+		return nil, fmt.Errorf("%s: only local references are supported", ref)
+	}
+	var node interface{} = s.doc
+	for _, key := range strings.Split(pointer, "/") {
+		m, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: %s is not an object", ref, key)
+		}
+		if node, ok = m[key]; !ok {
+			return nil, fmt.Errorf("%s does not exist", ref)
+		}
+	}
+	return node, nil
+}
+
+// refs calls fn with every $ref in the document.
+func refs(node interface{}, fn func(string)) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for key, v := range n {
+			if ref, ok := v.(string); ok && key == "$ref" {
+				fn(ref)
+				continue
+			}
+			refs(v, fn)
+		}
+	case []interface{}:
+		for _, v := range n {
+			refs(v, fn)
+		}
+	}
+}
+
+// ignoredKeywords document a schema without constraining the responses
+// the contract checks.
+var ignoredKeywords = map[string]bool{
+	"description": true, "default": true, "example": true, "minimum": true,
+	"maximum": true, "exclusiveMaximum": true, "pattern": true,
+}
+
+// validate checks a JSON value, decoded with UseNumber, against a schema.
+// It supports the keywords the API's schemas use and reports the others,
+// so a typo in the spec can't pass for a constraint.
+func (s *openAPISpec) validate(at string, schema, value interface{}) []string {
+	schema, err := s.resolve(schema)
+	if err != nil {
+		return []string{at + ": " + err.Error()}
+	}
+	rules, ok := schema.(map[string]interface{})
+	if !ok {
+		return []string{fmt.Sprintf("%s: schema is a %T", at, schema)}
+	}
+
+	var problems []string
+	for keyword, rule := range rules {
+		switch keyword {
+		case "type":
+			if !matchesType(rule, value) {
+				problems = append(problems, fmt.Sprintf("%s: %s, want type %v", at, jsonKind(value), rule))
+			}
+		case "enum":
+			if !inEnum(rule.([]interface{}), value) {
+				problems = append(problems, fmt.Sprintf("%s: %v is not one of %v", at, value, rule))
+			}
+		case "format":
+			if str, ok := value.(string); ok && rule == "date-time" {
+				if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+					problems = append(problems, fmt.Sprintf("%s: %q is not a date-time", at, str))
+				}
+			}
+		case "required":
+			if obj, ok := value.(map[string]interface{}); ok {
+				for _, name := range rule.([]interface{}) {
+					if _, ok := obj[name.(string)]; !ok {
+						problems = append(problems, fmt.Sprintf("%s: missing required field %s", at, name))
+					}
+				}
+			}
+		case "properties":
+			if obj, ok := value.(map[string]interface{}); ok {
+				for name, property := range rule.(map[string]interface{}) {
+					if v, ok := obj[name]; ok {
+						problems = append(problems, s.validate(at+"."+name, property, v)...)
+					}
+				}
+			}
+		case "additionalProperties":
+			obj, ok := value.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			declared, _ := rules["properties"].(map[string]interface{})
+			for name, v := range obj {
+				if _, ok := declared[name]; ok {
+					continue
+				}
+				if rule == false {
+					problems = append(problems, fmt.Sprintf("%s: unexpected field %s", at, name))
+				} else if rule != true {
+					problems = append(problems, s.validate(at+"."+name, rule, v)...)
+				}
+			}
+		case "items":
+			if arr, ok := value.([]interface{}); ok {
+				for i, v := range arr {
+					problems = append(problems, s.validate(fmt.Sprintf("%s[%d]", at, i), rule, v)...)
+				}
+			}
+		case "allOf":
+			for _, sub := range rule.([]interface{}) {
+				problems = append(problems, s.validate(at, sub, value)...)
+			}
+		case "anyOf", "oneOf":
+			var matched int
+			var reasons []string
+			for _, sub := range rule.([]interface{}) {
+				if p := s.validate(at, sub, value); len(p) == 0 {
+					matched++
+				} else {
+					reasons = append(reasons, p...)
+				}
+			}
+			switch {
+			case matched == 0:
+				problems = append(problems, fmt.Sprintf("%s: matches none of %s: %s", at, keyword, strings.Join(reasons, "; ")))
+			case keyword == "oneOf" && matched > 1:
+				problems = append(problems, fmt.Sprintf("%s: matches %d schemas of oneOf", at, matched))
+			}
+		default:
+			if !ignoredKeywords[keyword] {
+				problems = append(problems, fmt.Sprintf("%s: unsupported schema keyword %s", at, keyword))
+			}
+		}
+	}
+	return problems
+}
+
+func matchesType(rule, value interface{}) bool {
+	types, ok := rule.([]interface{})
+	if !ok {
+		types = []interface{}{rule}
+	}
+	for _, t := range types {
+		switch t {
+		case "null":
+			if value == nil {
+				return true
+			}
+		case "boolean":
+			if _, ok := value.(bool); ok {
+				return true
+			}
+		case "string":
+			if _, ok := value.(string); ok {
+				return true
+			}
+		case "number":
+			if _, ok := value.(json.Number); ok {
+				return true
+			}
+		case "integer":
+			if n, ok := value.(json.Number); ok {
+				if f, ok := new(big.Float).SetString(n.String()); ok && f.IsInt() {
+					return true
+				}
+			}
+		case "array":
+			if _, ok := value.([]interface{}); ok {
+				return true
+			}
+		case "object":
+			if _, ok := value.(map[string]interface{}); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func inEnum(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if fmt.Sprint(v) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonKind(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// TestOpenAPISpec checks the document itself: every reference resolves,
+// every path parameter is declared, and every operation can be told apart.
+func TestOpenAPISpec(t *testing.T) {
+	spec := loadOpenAPISpec(t)
+	if v := spec.doc["openapi"]; v != "3.1.0" {
+		t.Errorf("openapi %v, want 3.1.0", v)
+	}
+
+	seen := map[string]bool{}
+	refs(spec.doc, func(ref string) {
+		if seen[ref] {
+			return
+		}
+		seen[ref] = true
+		if _, err := spec.lookup(ref); err != nil {
+			t.Error(err)
+		}
+	})
+
+	operationIDs := map[string]string{}
+	for _, p := range spec.paths {
+		for _, method := range specMethods {
+			op, ok := p.item[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			id, _ := op["operationId"].(string)
+			if other, dup := operationIDs[id]; id == "" || dup {
+				t.Errorf("%s %s: operationId %q is missing or also used by %s", method, p.template, id, other)
+			}
+			operationIDs[id] = method + " " + p.template
+			if responses, _ := op["responses"].(map[string]interface{}); len(responses) == 0 {
+				t.Errorf("%s %s documents no responses", method, p.template)
+			}
+
+			declared := map[string]bool{}
+			for _, params := range []interface{}{p.item["parameters"], op["parameters"]} {
+				list, _ := params.([]interface{})
+				for _, param := range list {
+					param, err := spec.resolve(param)
+					if err != nil {
+						t.Error(err)
+						continue
+					}
+					if m := param.(map[string]interface{}); m["in"] == "path" {
+						declared[m["name"].(string)] = true
+					}
+				}
+			}
+			for _, match := range pathParamPattern.FindAllStringSubmatch(p.template, -1) {
+				if !declared[match[1]] {
+					t.Errorf("%s %s: path parameter %s is not declared", method, p.template, match[1])
+				}
+			}
+		}
+	}
+}
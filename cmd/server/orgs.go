@@ -0,0 +1,406 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"price-comparison-api/internal/alerts"
+	"price-comparison-api/internal/apierr"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/history"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/orgs"
+)
+
+func registerOrgRoutes(r *gin.Engine, store *orgs.Store, alertService *alerts.Service, historyStore *history.Store, cfg *config.Config) {
+	group := r.Group("/orgs", func(c *gin.Context) {
+		if store == nil {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, models.ErrorResponse{
+				Error:   "orgs_unavailable",
+				Code:    http.StatusServiceUnavailable,
+				Message: "organizations are disabled (ORGS_ENABLED)",
+			})
+			return
+		}
+		c.Next()
+	}, callerMiddleware(cfg.Profiles))
+
+	// orgAccess loads the organization in :id into "org" when the caller has
+	// at least role in it
+	orgAccess := func(role string) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			org, err := store.Get(c.Param("id"))
+			caller := c.GetString("caller_id")
+			if err == nil && org.Role(caller) == "" {
+				err = orgs.ErrNotFound
+			}
+			if err != nil {
+				orgError(c, err)
+				c.Abort()
+				return
+			}
+			if !org.Can(caller, role) {
+				c.AbortWithStatusJSON(http.StatusForbidden, models.ErrorResponse{
+					Error:   "forbidden",
+					Code:    http.StatusForbidden,
+					Message: "this requires the " + role + " role",
+				})
+				return
+			}
+			c.Set("org", org)
+			c.Next()
+		}
+	}
+	// update applies fn to the organization in :id, saves it and answers
+	// with fn's result
+	update := func(c *gin.Context, status int, fn func(o *orgs.Organization) (interface{}, error)) {
+		var result interface{}
+		_, err := store.Update(c.Param("id"), func(o *orgs.Organization) error {
+			var err error
+			result, err = fn(o)
+			return err
+		})
+		if err != nil {
+			orgError(c, err)
+			return
+		}
+		c.JSON(status, result)
+	}
+
+	// Create an organization; the caller becomes its admin
+	group.POST("", func(c *gin.Context) {
+		var req struct {
+			Name string `json:"name" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			writeError(c, apierr.Validation("invalid_request", "invalid organization").WithDetails(err.Error()))
+			return
+		}
+		if strings.TrimSpace(req.Name) == "" {
+			orgError(c, badRequest(errors.New("name is required")))
+			return
+		}
+		org, err := store.Create(req.Name, c.GetString("caller_id"))
+		if err != nil {
+			orgError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, org)
+	})
+
+	// Organizations the caller belongs to
+	group.GET("", func(c *gin.Context) {
+		caller := c.GetString("caller_id")
+		list, err := store.ForMember(caller)
+		if err != nil {
+			orgError(c, err)
+			return
+		}
+		summaries := make([]gin.H, 0, len(list))
+		for _, o := range list {
+			summaries = append(summaries, gin.H{
+				"id":         o.ID,
+				"name":       o.Name,
+				"role":       o.Role(caller),
+				"members":    len(o.Members),
+				"created_at": o.CreatedAt,
+			})
+		}
+		c.JSON(http.StatusOK, gin.H{"orgs": summaries, "roles": orgs.Roles})
+	})
+
+	group.GET("/:id", orgAccess(orgs.RoleViewer), func(c *gin.Context) {
+		org := c.MustGet("org").(*orgs.Organization)
+		c.JSON(http.StatusOK, orgView(org, c.GetString("caller_id")))
+	})
+
+	group.PATCH("/:id", orgAccess(orgs.RoleAdmin), func(c *gin.Context) {
+		var req struct {
+			Name string `json:"name" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			writeError(c, apierr.Validation("invalid_request", "invalid organization").WithDetails(err.Error()))
+			return
+		}
+		if strings.TrimSpace(req.Name) == "" {
+			orgError(c, badRequest(errors.New("name is required")))
+			return
+		}
+		update(c, http.StatusOK, func(o *orgs.Organization) (interface{}, error) {
+			o.Name = strings.TrimSpace(req.Name)
+			return orgView(o, c.GetString("caller_id")), nil
+		})
+	})
+
+	// Delete the organization and its alerts
+	group.DELETE("/:id", orgAccess(orgs.RoleAdmin), func(c *gin.Context) {
+		deleted := 0
+		if alertService != nil {
+			var err error
+			if deleted, err = alertService.DeleteMatching(alerts.Filter{OrgID: c.Param("id")}); err != nil {
+				orgError(c, err)
+				return
+			}
+		}
+		if err := store.Delete(c.Param("id")); err != nil {
+			orgError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "organization deleted", "alerts_deleted": deleted})
+	})
+
+	// Add a member or change a member's role; members are identified by the
+	// ID GET /me returns for their key
+	group.PUT("/:id/members/:member", orgAccess(orgs.RoleAdmin), func(c *gin.Context) {
+		var req struct {
+			Role string `json:"role" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			writeError(c, apierr.Validation("invalid_request", "invalid member").WithDetails(err.Error()))
+			return
+		}
+		update(c, http.StatusOK, func(o *orgs.Organization) (interface{}, error) {
+			if err := o.SetMember(c.Param("member"), strings.ToLower(strings.TrimSpace(req.Role))); err != nil {
+				return nil, badRequest(err)
+			}
+			return o.Members, nil
+		})
+	})
+
+	// Remove a member; any member may remove themselves
+	group.DELETE("/:id/members/:member", orgAccess(orgs.RoleViewer), func(c *gin.Context) {
+		org := c.MustGet("org").(*orgs.Organization)
+		caller := c.GetString("caller_id")
+		if c.Param("member") != caller && !org.Can(caller, orgs.RoleAdmin) {
+			c.JSON(http.StatusForbidden, models.ErrorResponse{
+				Error:   "forbidden",
+				Code:    http.StatusForbidden,
+				Message: "this requires the admin role",
+			})
+			return
+		}
+		update(c, http.StatusOK, func(o *orgs.Organization) (interface{}, error) {
+			if err := o.RemoveMember(c.Param("member")); err != nil {
+				return nil, badRequest(err)
+			}
+			return o.Members, nil
+		})
+	})
+
+	group.GET("/:id/watchlist", orgAccess(orgs.RoleViewer), func(c *gin.Context) {
+		org := c.MustGet("org").(*orgs.Organization)
+		c.JSON(http.StatusOK, gin.H{"watchlist": org.Watchlist, "total": len(org.Watchlist)})
+	})
+
+	// Add a product by product_key, or by url and country
+	group.POST("/:id/watchlist", orgAccess(orgs.RoleEditor), func(c *gin.Context) {
+		var req struct {
+			ProductKey string `json:"product_key"`
+			URL        string `json:"url"`
+			Country    string `json:"country"`
+			Name       string `json:"name"`
+			Note       string `json:"note"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			writeError(c, apierr.Validation("invalid_request", "invalid watchlist item").WithDetails(err.Error()))
+			return
+		}
+		item := orgs.WatchItem{
+			ProductKey: req.ProductKey,
+			Name:       strings.TrimSpace(req.Name),
+			URL:        req.URL,
+			Country:    strings.ToUpper(strings.TrimSpace(req.Country)),
+			Note:       strings.TrimSpace(req.Note),
+			AddedBy:    c.GetString("caller_id"),
+		}
+		if item.ProductKey == "" {
+			if item.URL == "" || item.Country == "" {
+				writeError(c, apierr.Validation("invalid_watchlist_item", "product_key or url and country are required"))
+				return
+			}
+			item.ProductKey = item.Country + "|" + item.URL
+		}
+		// Fill in what the price history knows about the product
+		if historyStore != nil {
+			if entry, err := historyStore.Get(item.ProductKey); err == nil && entry != nil {
+				if item.Name == "" {
+					item.Name = entry.Name
+				}
+				item.URL, item.Country = entry.URL, entry.Country
+			}
+		}
+		update(c, http.StatusCreated, func(o *orgs.Organization) (interface{}, error) {
+			return o.AddWatch(item), nil
+		})
+	})
+
+	group.DELETE("/:id/watchlist/:item", orgAccess(orgs.RoleEditor), func(c *gin.Context) {
+		update(c, http.StatusOK, func(o *orgs.Organization) (interface{}, error) {
+			if err := o.RemoveWatch(c.Param("item")); err != nil {
+				return nil, notFound(err)
+			}
+			return gin.H{"message": "watchlist item removed"}, nil
+		})
+	})
+
+	// Channel URLs often embed secrets, so viewers only see that they exist
+	group.GET("/:id/channels", orgAccess(orgs.RoleViewer), func(c *gin.Context) {
+		org := c.MustGet("org").(*orgs.Organization)
+		c.JSON(http.StatusOK, gin.H{"channels": orgView(org, c.GetString("caller_id")).Channels})
+	})
+
+	group.POST("/:id/channels", orgAccess(orgs.RoleEditor), func(c *gin.Context) {
+		var ch orgs.Channel
+		if err := c.ShouldBindJSON(&ch); err != nil {
+			writeError(c, apierr.Validation("invalid_request", "invalid channel").WithDetails(err.Error()))
+			return
+		}
+		update(c, http.StatusCreated, func(o *orgs.Organization) (interface{}, error) {
+			added, err := o.AddChannel(ch)
+			if err != nil {
+				return nil, badRequest(err)
+			}
+			return added, nil
+		})
+	})
+
+	group.DELETE("/:id/channels/:channel", orgAccess(orgs.RoleEditor), func(c *gin.Context) {
+		update(c, http.StatusOK, func(o *orgs.Organization) (interface{}, error) {
+			if err := o.RemoveChannel(c.Param("channel")); err != nil {
+				return nil, notFound(err)
+			}
+			return gin.H{"message": "channel removed"}, nil
+		})
+	})
+
+	// The organization's alerts take the same options as POST /alerts and
+	// fire to its channels
+	orgAlerts := group.Group("/:id/alerts", func(c *gin.Context) {
+		if alertService == nil {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, models.ErrorResponse{
+				Error:   "alerts_unavailable",
+				Code:    http.StatusServiceUnavailable,
+				Message: "alerts are disabled (ALERTS_ENABLED, HISTORY_ENABLED)",
+			})
+			return
+		}
+		c.Next()
+	})
+
+	orgAlerts.GET("", orgAccess(orgs.RoleViewer), func(c *gin.Context) {
+		var filter alerts.Filter
+		if err := c.ShouldBindQuery(&filter); err != nil {
+			writeError(c, apierr.Validation("invalid_request", "invalid alert filter").WithDetails(err.Error()))
+			return
+		}
+		filter.OrgID = c.Param("id")
+		list, err := alertService.Find(filter)
+		if err != nil {
+			writeError(c, apierr.Validation("invalid_filter", err.Error()))
+			return
+		}
+		for i := range list {
+			list[i] = list[i].Redacted()
+		}
+		c.JSON(http.StatusOK, gin.H{"alerts": list, "total": len(list)})
+	})
+
+	orgAlerts.POST("", orgAccess(orgs.RoleEditor), func(c *gin.Context) {
+		var opts alerts.Options
+		if err := c.ShouldBindJSON(&opts); err != nil {
+			writeError(c, apierr.Validation("invalid_request", "invalid alert request").WithDetails(err.Error()))
+			return
+		}
+		opts.OrgID = c.Param("id")
+		if opts.TimeZone == "" {
+			opts.TimeZone = cfg.Reporting.TimeZone
+		}
+		alert, err := alertService.Create(opts)
+		if err != nil {
+			writeError(c, apierr.Validation("invalid_alert", err.Error()))
+			return
+		}
+		c.JSON(http.StatusCreated, alert.Redacted())
+	})
+
+	orgAlerts.GET("/events", orgAccess(orgs.RoleViewer), func(c *gin.Context) {
+		limit, _ := strconv.Atoi(c.Query("limit"))
+		events, err := alertService.Events(c.Param("id"), "", "", limit)
+		if err != nil {
+			orgError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"events": events})
+	})
+
+	orgAlerts.DELETE("/:alert", orgAccess(orgs.RoleEditor), func(c *gin.Context) {
+		deleted, err := alertService.DeleteMatching(alerts.Filter{OrgID: c.Param("id"), IDs: []string{c.Param("alert")}})
+		if err == nil && deleted == 0 {
+			err = notFound(alerts.ErrNotFound)
+		}
+		if err != nil {
+			orgError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "alert deleted"})
+	})
+}
+
+// orgView hides channel URLs from members who can't edit them.
+func orgView(o *orgs.Organization, caller string) *orgs.Organization {
+	if o.Can(caller, orgs.RoleEditor) {
+		return o
+	}
+	view := *o
+	view.Channels = make([]orgs.Channel, len(o.Channels))
+	for i, ch := range o.Channels {
+		ch.URL = ""
+		view.Channels[i] = ch
+	}
+	return &view
+}
+
+// orgRequestError carries the status for errors raised inside an update.
+type orgRequestError struct {
+	status int
+	err    error
+}
+
+func (e orgRequestError) Error() string { return e.err.Error() }
+
+func badRequest(err error) error {
+	return apierr.Validation("invalid_request", err.Error())
+}
+
+func notFound(err error) error {
+	return orgRequestError{status: http.StatusNotFound, err: err}
+}
+
+func orgError(c *gin.Context, err error) {
+	var reqErr orgRequestError
+	switch {
+	case errors.Is(err, orgs.ErrNotFound):
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "org_not_found",
+			Code:    http.StatusNotFound,
+			Message: err.Error(),
+		})
+	case errors.Is(err, apierr.ErrValidation):
+		writeError(c, err)
+	case errors.As(err, &reqErr):
+		c.JSON(reqErr.status, models.ErrorResponse{
+			Error:   "not_found",
+			Code:    reqErr.status,
+			Message: reqErr.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "orgs_error",
+			Code:    http.StatusInternalServerError,
+			Message: err.Error(),
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"price-comparison-api/internal/apierr"
+	"price-comparison-api/internal/pricefmt"
+)
+
+// priceFormat reads the price_format query parameter, raw by default.
+func priceFormat(c *gin.Context) (string, error) {
+	format := strings.ToLower(strings.TrimSpace(c.DefaultQuery("price_format", pricefmt.Raw)))
+	if !pricefmt.Valid(format) {
+		return "", apierr.Validation("invalid_price_format", "price_format must be one of "+strings.Join(pricefmt.Formats, ", "))
+	}
+	return format, nil
+}
+
+// writePrices answers with v, its price_value fields written in format:
+// left as they are, rounded to their currency's minor unit, or formatted
+// as strings the way country writes them. Objects without a currency of
+// their own, such as offers, take their parent's.
+func writePrices(c *gin.Context, v interface{}, format, country string) {
+	if format == pricefmt.Raw {
+		c.JSON(http.StatusOK, v)
+		return
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var tree interface{}
+	if err := decoder.Decode(&tree); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, formatPrices(tree, format, country, ""))
+}
+
+func formatPrices(node interface{}, format, country, currency string) interface{} {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if code, ok := n["currency"].(string); ok && code != "" {
+			currency = code
+		}
+		for key, child := range n {
+			if number, ok := child.(json.Number); ok && key == "price_value" {
+				value, err := number.Float64()
+				if err != nil {
+					continue
+				}
+				if format == pricefmt.Localized {
+					n[key] = pricefmt.Localize(value, currency, country)
+				} else {
+					n[key] = pricefmt.Round(value, currency)
+				}
+				continue
+			}
+			n[key] = formatPrices(child, format, country, currency)
+		}
+	case []interface{}:
+		for i, child := range n {
+			n[i] = formatPrices(child, format, country, currency)
+		}
+	}
+	return node
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"price-comparison-api/internal/apierr"
+	"price-comparison-api/internal/categories"
+	"price-comparison-api/internal/scrapers"
+	"price-comparison-api/internal/services"
+)
+
+func registerProductRoutes(r *gin.Engine, searchService *services.SearchService) {
+	// Full details of a single product page
+	r.GET("/product", func(c *gin.Context) {
+		url := c.Query("url")
+		if url == "" {
+			writeError(c, apierr.Validation("missing_url", "url parameter is required"))
+			return
+		}
+		if _, err := scrapers.DetailScraperFor(url); err != nil {
+			writeError(c, apierr.Validation("invalid_url", err.Error()))
+			return
+		}
+
+		detail, err := searchService.ProductDetail(c.Request.Context(), url)
+		if err != nil {
+			writeError(c, apierr.Classify(err))
+			return
+		}
+		c.JSON(http.StatusOK, detail)
+	})
+
+	// The category tree searches can be filtered by
+	r.GET("/categories", func(c *gin.Context) {
+		tree := categories.Tree()
+		c.JSON(http.StatusOK, gin.H{
+			"categories": tree,
+			"total":      len(categories.Slugs()),
+		})
+	})
+
+	// Offers for a scanned barcode (UPC, EAN, GTIN-14) or an ASIN
+	r.GET("/lookup", func(c *gin.Context) {
+		result, err := searchService.Lookup(c.Request.Context(), c.Query("gtin"), c.Query("country"))
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	})
+
+	// The same product's price in each country, converted to one currency,
+	// flagging countries cheap enough to buy from instead of at home
+	r.GET("/products/:id/regional", func(c *gin.Context) {
+		currency := c.DefaultQuery("currency", "USD")
+		landed := false
+		if raw := c.Query("landed"); raw != "" {
+			var err error
+			if landed, err = strconv.ParseBool(raw); err != nil {
+				writeError(c, apierr.Validation("invalid_landed", "landed must be true or false"))
+				return
+			}
+		}
+
+		result, err := searchService.Regional(c.Request.Context(), c.Param("id"), splitList(c.Query("countries")), currency, landed, c.Query("home"))
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	})
+
+	// Products side by side: a row per source with price, shipping, rating
+	// and availability, and the cheapest total per product and overall
+	r.GET("/compare", func(c *gin.Context) {
+		refs := append(splitList(c.Query("ids")), splitList(c.Query("urls"))...)
+		result, err := searchService.Compare(c.Request.Context(), refs, c.Query("currency"))
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	})
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"price-comparison-api/internal/apierr"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/profiles"
+)
+
+var countryCode = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// callerID identifies the caller by API key, or by X-User-ID when the config
+// trusts it. It returns "" for anonymous callers.
+func callerID(c *gin.Context, cfg config.ProfilesConfig) string {
+	if cfg.TrustUserHeader {
+		if user := strings.TrimSpace(c.GetHeader("X-User-ID")); user != "" {
+			return profiles.UserOwner(user)
+		}
+	}
+	if key := requestAPIKey(c); key != "" {
+		return profiles.KeyOwner(key)
+	}
+	return ""
+}
+
+// callerMiddleware rejects anonymous callers and stores the caller's ID
+// under "caller_id".
+func callerMiddleware(cfg config.ProfilesConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		caller := callerID(c, cfg)
+		if caller == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, models.ErrorResponse{
+				Error:   "unauthorized",
+				Code:    http.StatusUnauthorized,
+				Message: "an API key (X-API-Key or Authorization: Bearer) is required",
+			})
+			return
+		}
+		c.Set("caller_id", caller)
+		c.Next()
+	}
+}
+
+func registerProfileRoutes(r *gin.Engine, store *profiles.Store, cfg *config.Config) {
+	me := r.Group("/me", callerMiddleware(cfg.Profiles))
+
+	// The caller's ID, which organization admins use to add members
+	me.GET("", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"id": c.GetString("caller_id")})
+	})
+
+	group := me.Group("", func(c *gin.Context) {
+		if store == nil {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, models.ErrorResponse{
+				Error:   "profiles_unavailable",
+				Code:    http.StatusServiceUnavailable,
+				Message: "preference profiles are disabled (PROFILES_ENABLED)",
+			})
+			return
+		}
+		c.Next()
+	})
+
+	group.GET("/preferences", func(c *gin.Context) {
+		profile, err := store.Get(c.GetString("caller_id"))
+		if err != nil {
+			profileError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, profile)
+	})
+
+	// Replace the stored preferences
+	group.PUT("/preferences", func(c *gin.Context) {
+		var profile profiles.Profile
+		if err := c.ShouldBindJSON(&profile); err != nil {
+			writeError(c, apierr.Validation("invalid_request", "invalid preferences").WithDetails(err.Error()))
+			return
+		}
+		if err := normalizeProfile(&profile, cfg); err != nil {
+			writeError(c, apierr.Validation("invalid_preferences", err.Error()))
+			return
+		}
+		if err := store.Put(c.GetString("caller_id"), &profile); err != nil {
+			profileError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, profile)
+	})
+
+	group.DELETE("/preferences", func(c *gin.Context) {
+		if err := store.Delete(c.GetString("caller_id")); err != nil {
+			profileError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "preferences deleted"})
+	})
+}
+
+func profileError(c *gin.Context, err error) {
+	if errors.Is(err, profiles.ErrNotFound) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "preferences_not_found",
+			Code:    http.StatusNotFound,
+			Message: err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+		Error:   "profiles_error",
+		Code:    http.StatusInternalServerError,
+		Message: err.Error(),
+	})
+}
+
+// normalizeProfile upper-cases codes, drops blank list entries and rejects
+// values a search would reject.
+func normalizeProfile(p *profiles.Profile, cfg *config.Config) error {
+	p.Country = strings.ToUpper(strings.TrimSpace(p.Country))
+	if p.Country != "" && !countryCode.MatchString(p.Country) {
+		return fmt.Errorf("country must be a two-letter code: %s", p.Country)
+	}
+	p.Currency = strings.ToUpper(strings.TrimSpace(p.Currency))
+	if _, ok := cfg.Currency.Rates[p.Currency]; p.Currency != "" && !ok {
+		return fmt.Errorf("unsupported currency: %s", p.Currency)
+	}
+	p.SafeSearch = strings.ToLower(strings.TrimSpace(p.SafeSearch))
+	if p.SafeSearch != "" && !validSafeSearch(p.SafeSearch) {
+		return fmt.Errorf("invalid safe_search: %s. Valid levels: %s", p.SafeSearch, strings.Join(models.SafeSearchLevels, ", "))
+	}
+	p.TimeZone = strings.TrimSpace(p.TimeZone)
+	if _, err := time.LoadLocation(p.TimeZone); p.TimeZone != "" && err != nil {
+		return fmt.Errorf("invalid time_zone: %s", p.TimeZone)
+	}
+	p.PreferredSources = splitList(strings.Join(p.PreferredSources, ","))
+	p.ExcludedSellers = splitList(strings.Join(p.ExcludedSellers, ","))
+	return nil
+}
+
+func validSafeSearch(level string) bool {
+	for _, l := range models.SafeSearchLevels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
+
+// callerTimeZone returns the time zone in the caller's stored profile, or the
+// reporting time zone when there is none.
+func callerTimeZone(c *gin.Context, store *profiles.Store, cfg *config.Config) string {
+	if store != nil {
+		if caller := callerID(c, cfg.Profiles); caller != "" {
+			if profile, err := store.Get(caller); err == nil && profile.TimeZone != "" {
+				return profile.TimeZone
+			}
+		}
+	}
+	return cfg.Reporting.TimeZone
+}
+
+// applyProfile fills in whatever the search request left out from the
+// caller's stored profile. A parameter that is present, even empty, wins, so
+// excluded_sellers= clears the stored list for one search.
+func applyProfile(c *gin.Context, store *profiles.Store, cfg config.ProfilesConfig, params *models.SearchParams) {
+	if store == nil {
+		return
+	}
+	caller := callerID(c, cfg)
+	if caller == "" {
+		return
+	}
+	profile, err := store.Get(caller)
+	if err != nil {
+		if !errors.Is(err, profiles.ErrNotFound) {
+			zerolog.Ctx(c.Request.Context()).Warn().Err(err).Msg("Failed to load preference profile")
+		}
+		return
+	}
+
+	if params.Country == "" {
+		params.Country = profile.Country
+	}
+
+	prefs := &models.Preferences{}
+	if params.Preferences != nil {
+		*prefs = *params.Preferences
+	}
+	if _, ok := c.GetQuery("preferred_retailers"); !ok {
+		prefs.PreferredRetailers = profile.PreferredSources
+	}
+	if _, ok := c.GetQuery("excluded_sellers"); !ok {
+		prefs.ExcludedSellers = profile.ExcludedSellers
+	}
+	if _, ok := c.GetQuery("currency"); !ok {
+		prefs.Currency = profile.Currency
+	}
+	if _, ok := c.GetQuery("safe_search"); !ok {
+		prefs.SafeSearch = profile.SafeSearch
+	}
+	if prefs.Empty() {
+		prefs = nil
+	}
+	params.Preferences = prefs
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"price-comparison-api/internal/apierr"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/savedsearch"
+)
+
+type savedSearchRequest struct {
+	Query   string `json:"query" binding:"required"`
+	Country string `json:"country"`
+}
+
+func registerSavedSearchRoutes(r *gin.Engine, service *savedsearch.Service, cfg *config.Config) {
+	available := func(c *gin.Context) {
+		if service == nil {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, models.ErrorResponse{
+				Error:   "saved_searches_unavailable",
+				Code:    http.StatusServiceUnavailable,
+				Message: "saved searches are disabled (SAVED_SEARCHES_ENABLED)",
+			})
+			return
+		}
+		c.Next()
+	}
+	group := r.Group("/saved-searches", available)
+
+	// Save a query; it runs now and then every SAVED_SEARCHES_INTERVAL
+	group.POST("", func(c *gin.Context) {
+		var req savedSearchRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			writeError(c, apierr.Validation("invalid_request", "invalid saved search request").WithDetails(err.Error()))
+			return
+		}
+		if req.Country == "" {
+			req.Country = cfg.Server.DefaultCountry
+		}
+		search, err := service.Create(req.Query, req.Country, callerID(c, cfg.Profiles))
+		if err != nil {
+			writeError(c, apierr.Validation("invalid_saved_search", err.Error()))
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{
+			"saved_search": search,
+			"feed_url":     feedURL(c, search.ID),
+		})
+	})
+
+	// The caller's saved searches, most recently created first
+	group.GET("", func(c *gin.Context) {
+		searches, err := service.List(callerID(c, cfg.Profiles))
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"saved_searches": searches,
+			"total":          len(searches),
+		})
+	})
+
+	// A saved search's last run and the events of its feed
+	group.GET("/:id", func(c *gin.Context) {
+		search, err := service.Get(c.Param("id"))
+		if err != nil {
+			savedSearchError(c, err)
+			return
+		}
+		search.Offers = nil
+		c.JSON(http.StatusOK, gin.H{
+			"saved_search": search,
+			"feed_url":     feedURL(c, search.ID),
+		})
+	})
+
+	group.DELETE("/:id", func(c *gin.Context) {
+		if err := service.Delete(c.Param("id")); err != nil {
+			savedSearchError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	// The RSS feed of a saved search's new offers and price changes. Feed
+	// readers can't send API keys, so the unguessable ID is the credential.
+	r.GET("/feeds/:file", available, func(c *gin.Context) {
+		id, ok := strings.CutSuffix(c.Param("file"), ".xml")
+		if !ok {
+			savedSearchError(c, savedsearch.ErrNotFound)
+			return
+		}
+		search, err := service.Get(id)
+		if err != nil {
+			savedSearchError(c, err)
+			return
+		}
+		feed, err := savedsearch.RSS(search, feedURL(c, id), service.Interval())
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.Data(http.StatusOK, "application/rss+xml; charset=utf-8", feed)
+	})
+}
+
+// feedURL is the absolute URL of a saved search's feed, as the caller
+// reached the server.
+func feedURL(c *gin.Context, id string) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + c.Request.Host + "/feeds/" + id + ".xml"
+}
+
+func savedSearchError(c *gin.Context, err error) {
+	if errors.Is(err, savedsearch.ErrNotFound) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "saved_search_not_found",
+			Code:    http.StatusNotFound,
+			Message: err.Error(),
+		})
+		return
+	}
+	writeError(c, err)
+}
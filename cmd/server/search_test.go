@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+)
+
+func TestSearchReportsSourcesStatus(t *testing.T) {
+	r := contractApp(t).router
+
+	var response models.SearchResponse
+	if code := alertRequest(t, r, "GET", "/search?q=usb+c+cable&country=US", "", "", &response); code != http.StatusOK {
+		t.Fatalf("search: status %d", code)
+	}
+	statuses := make(map[string]models.SourceStatus)
+	for _, st := range response.SourcesStatus {
+		statuses[st.Name] = st
+	}
+	// contractApp puts Costco in a maintenance window
+	if st := statuses[config.ScraperCostco]; st.Status != "maintenance" || st.Reason == "" {
+		t.Errorf("costco: %+v, want maintenance with a reason", st)
+	}
+	if st := statuses[config.ScraperAmazon]; st.Status != "ok" {
+		t.Errorf("amazon: %+v, want ok", st)
+	}
+	if _, ok := statuses[config.ScraperFlipkart]; ok {
+		t.Error("flipkart, which doesn't cover US, is listed")
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/scrapers"
+	"price-comparison-api/pkg/browser"
+	"price-comparison-api/pkg/cache"
+)
+
+// Self-test check outcomes. "warn" does not fail the run.
+const (
+	checkPass = "pass"
+	checkWarn = "warn"
+	checkFail = "fail"
+	checkSkip = "skip"
+)
+
+type selfTestCheck struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	DurationMS int64  `json:"duration_ms"`
+	Details    string `json:"details,omitempty"`
+}
+
+type selfTestReport struct {
+	Status     string          `json:"status"`
+	StartedAt  time.Time       `json:"started_at"`
+	DurationMS int64           `json:"duration_ms"`
+	Checks     []selfTestCheck `json:"checks"`
+}
+
+// runSelfTest verifies the deployment (config, Redis, Chrome, DNS for every
+// enabled retailer, and one canned extraction per scraper), prints a JSON
+// report to stdout and returns the process exit code.
+func runSelfTest() int {
+	report := selfTestReport{StartedAt: time.Now()}
+
+	run := func(name string, fn func() (string, string)) {
+		start := time.Now()
+		status, details := fn()
+		report.Checks = append(report.Checks, selfTestCheck{
+			Name:       name,
+			Status:     status,
+			DurationMS: time.Since(start).Milliseconds(),
+			Details:    details,
+		})
+	}
+
+	cfg, err := config.Load()
+	run("config", func() (string, string) {
+		if err != nil {
+			return checkFail, err.Error()
+		}
+		return checkPass, ""
+	})
+
+	if cfg != nil {
+		run("redis", func() (string, string) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := cache.CheckRedis(ctx, cfg.Redis); err != nil {
+				// The service still runs on the disk cache, so only warn then
+				if cfg.DiskCache.Enabled {
+					return checkWarn, fmt.Sprintf("redis unreachable, disk fallback will be used: %v", err)
+				}
+				return checkFail, err.Error()
+			}
+			return checkPass, fmt.Sprintf("mode %s", cfg.Redis.Mode)
+		})
+
+		run("chrome", func() (string, string) {
+			return checkChrome(cfg.Chrome)
+		})
+
+		for _, name := range config.ScraperNames {
+			name := name
+			if !cfg.Scraper(name).Enabled {
+				run("dns:"+name, func() (string, string) { return checkSkip, "scraper disabled" })
+				run("extract:"+name, func() (string, string) { return checkSkip, "scraper disabled" })
+				continue
+			}
+
+			tc := scrapers.SelfTestCases[name]
+			run("dns:"+name, func() (string, string) {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				addrs, err := net.DefaultResolver.LookupHost(ctx, tc.Host)
+				if err != nil {
+					return checkFail, err.Error()
+				}
+				return checkPass, fmt.Sprintf("%s -> %d addresses", tc.Host, len(addrs))
+			})
+			run("extract:"+name, func() (string, string) {
+				return checkExtraction(name, cfg.Scraper(name), tc)
+			})
+		}
+	}
+
+	report.Status = checkPass
+	for _, check := range report.Checks {
+		if check.Status == checkFail {
+			report.Status = checkFail
+		}
+	}
+	report.DurationMS = time.Since(report.StartedAt).Milliseconds()
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(report)
+
+	if report.Status == checkFail {
+		return 1
+	}
+	return 0
+}
+
+func checkChrome(cfg config.ChromeConfig) (string, string) {
+	if cfg.Backend == config.BrowserChromedp && cfg.ExecPath != "" {
+		if _, err := os.Stat(cfg.ExecPath); err != nil {
+			// The Chrome scraper is optional, so a missing browser is a warning
+			return checkWarn, fmt.Sprintf("chrome not found at %s", cfg.ExecPath)
+		}
+	}
+
+	renderer, err := browser.NewRenderer(cfg)
+	if err != nil {
+		return checkWarn, err.Error()
+	}
+	defer renderer.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	if _, err := renderer.Render(ctx, "about:blank", false); err != nil {
+		return checkWarn, fmt.Sprintf("chrome (%s backend) failed to start: %v", cfg.Backend, err)
+	}
+	return checkPass, ""
+}
+
+func checkExtraction(name string, cfg config.ScraperConfig, tc scrapers.SelfTestCase) (string, string) {
+	// No politeness delay needed against a canned page
+	cfg.Delay = 0
+	cfg.RetryDelay = 0
+
+	scraper, err := scrapers.New(name, cfg)
+	if err != nil {
+		return checkFail, err.Error()
+	}
+	scraper.SetTransport(scrapers.StaticTransport{HTML: tc.HTML})
+
+	products, err := scraper.Search(context.Background(), tc.Query, tc.Country)
+	if err != nil {
+		return checkFail, err.Error()
+	}
+	if len(products) == 0 {
+		return checkFail, "no products extracted from fixture page"
+	}
+
+	p := products[0]
+	if p.Name == "" || p.Price == "" || p.URL == "" {
+		return checkFail, fmt.Sprintf("incomplete product extracted: name=%q price=%q url=%q", p.Name, p.Price, p.URL)
+	}
+	return checkPass, fmt.Sprintf("%d products, first: %s - %s", len(products), p.Name, p.Price)
+}
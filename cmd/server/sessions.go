@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"price-comparison-api/internal/apierr"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/services"
+)
+
+func registerSessionRoutes(r *gin.Engine, searchService *services.SearchService) {
+	// Current state and history of a search session
+	r.GET("/sessions/:id", func(c *gin.Context) {
+		sess, err := searchService.Sessions().Get(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "session_not_found",
+				Code:    http.StatusNotFound,
+				Message: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, sess)
+	})
+
+	// Narrow or re-sort the session's results without scraping again
+	r.POST("/sessions/:id/refine", func(c *gin.Context) {
+		var req models.RefineRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			writeError(c, apierr.Validation("invalid_request", "invalid refine request").WithDetails(err.Error()))
+			return
+		}
+		format, err := priceFormat(c)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+
+		results, err := searchService.RefineSession(c.Request.Context(), c.Param("id"), req)
+		if err != nil {
+			sessionError(c, searchService, c.Param("id"), "refine_failed", err)
+			return
+		}
+		writeSessionPrices(c, searchService, results, format)
+	})
+
+	// Go back to the previous refinement
+	r.POST("/sessions/:id/undo", func(c *gin.Context) {
+		format, err := priceFormat(c)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+
+		results, err := searchService.UndoSession(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			sessionError(c, searchService, c.Param("id"), "undo_failed", err)
+			return
+		}
+		writeSessionPrices(c, searchService, results, format)
+	})
+}
+
+// writeSessionPrices writes a session's results in the price format asked
+// for, localized for the country the session searched.
+func writeSessionPrices(c *gin.Context, searchService *services.SearchService, results *models.SearchResponse, format string) {
+	country := ""
+	if sess, err := searchService.Sessions().Get(c.Param("id")); err == nil {
+		country = sess.Country
+	}
+	writePrices(c, results, format, country)
+}
+
+// sessionError tells a missing session (404) apart from a failed
+// refinement, which keeps the kind of the underlying error and is otherwise
+// a validation error.
+func sessionError(c *gin.Context, searchService *services.SearchService, id, code string, err error) {
+	if _, getErr := searchService.Sessions().Get(id); getErr != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   code,
+			Code:    http.StatusNotFound,
+			Message: err.Error(),
+		})
+		return
+	}
+	var apiErr *apierr.Error
+	if !errors.As(err, &apiErr) {
+		err = apierr.Validation(code, err.Error())
+	}
+	writeError(c, err)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"price-comparison-api/internal/apierr"
+	"price-comparison-api/internal/catalog"
+	"price-comparison-api/internal/history"
+	"price-comparison-api/internal/models"
+)
+
+func registerStatsRoutes(r *gin.Engine, historyStore *history.Store, catalogStore *catalog.Store) {
+	// Min, max and average price over 7, 30 and 90 days, where the current
+	// price ranks among them, and whether it's a good deal
+	r.GET("/products/:id/stats", func(c *gin.Context) {
+		if historyStore == nil {
+			c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{
+				Error:   "history_unavailable",
+				Code:    http.StatusServiceUnavailable,
+				Message: "price history is disabled (HISTORY_ENABLED)",
+			})
+			return
+		}
+		if catalogStore == nil {
+			writeError(c, apierr.Validation("catalog_unavailable", "products are identified by catalog id, which needs the catalog (CATALOG_ENABLED)"))
+			return
+		}
+
+		entry, err := statsEntry(historyStore, catalogStore, c.Param("id"))
+		if errors.Is(err, catalog.ErrNotFound) {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "catalog_not_found",
+				Code:    http.StatusNotFound,
+				Message: err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "history_error",
+				Code:    http.StatusInternalServerError,
+				Message: err.Error(),
+			})
+			return
+		}
+		if entry == nil || len(entry.Points) == 0 {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "history_not_found",
+				Code:    http.StatusNotFound,
+				Message: "no price history for " + c.Param("id"),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, entry.Stats(time.Now()))
+	})
+}
+
+// statsEntry finds the price history of a catalog offer, or of a catalog
+// product's cheapest offer that has one.
+func statsEntry(historyStore *history.Store, catalogStore *catalog.Store, id string) (*history.Entry, error) {
+	if offer, err := catalogStore.Offer(id); err == nil {
+		return historyStore.Get(offer.Key)
+	} else if !errors.Is(err, catalog.ErrNotFound) {
+		return nil, err
+	}
+
+	product, err := catalogStore.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	for _, offer := range product.Offers {
+		if entry, err := historyStore.Get(offer.Key); err != nil || entry != nil {
+			return entry, err
+		}
+	}
+	return nil, nil
+}
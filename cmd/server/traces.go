@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/scrapetrace"
+)
+
+func registerTraceRoutes(admin *gin.RouterGroup, store *scrapetrace.Store) {
+	// The scrape trace of a recent request, by its X-Request-ID
+	admin.GET("/traces/:request_id", func(c *gin.Context) {
+		if store == nil {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "scrape_traces_disabled",
+				Code:    http.StatusNotFound,
+				Message: "scrape traces are disabled (SCRAPE_TRACE_ENABLED) or no cache is available",
+			})
+			return
+		}
+
+		requestID := c.Param("request_id")
+		trace, err := store.Get(c.Request.Context(), requestID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "trace_lookup_failed",
+				Code:    http.StatusInternalServerError,
+				Message: err.Error(),
+			})
+			return
+		}
+		if trace == nil {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "trace_not_found",
+				Code:    http.StatusNotFound,
+				Message: "no trace for request " + requestID + "; it made no search or its trace expired",
+			})
+			return
+		}
+		c.Data(http.StatusOK, "application/json; charset=utf-8", trace)
+	})
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"price-comparison-api/internal/apierr"
+	"price-comparison-api/internal/catalog"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/scrapers"
+	"price-comparison-api/internal/watchlist"
+)
+
+// watchRequest names the product to watch by its URL, or by a listing or
+// catalog product ID from an earlier search.
+type watchRequest struct {
+	URL string `json:"url"`
+	ID  string `json:"id"`
+}
+
+func registerWatchlistRoutes(r *gin.Engine, service *watchlist.Service, catalogStore *catalog.Store, cfg *config.Config) {
+	group := r.Group("/watchlist", func(c *gin.Context) {
+		if service == nil {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, models.ErrorResponse{
+				Error:   "watchlist_unavailable",
+				Code:    http.StatusServiceUnavailable,
+				Message: "the watchlist is disabled (WATCHLIST_ENABLED)",
+			})
+			return
+		}
+		c.Next()
+	})
+
+	// Start watching a product; its page is scraped now and then every
+	// WATCHLIST_INTERVAL
+	group.POST("", func(c *gin.Context) {
+		var req watchRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			writeError(c, apierr.Validation("invalid_request", "invalid watchlist request").WithDetails(err.Error()))
+			return
+		}
+
+		url, productID, catalogID := req.URL, "", ""
+		switch {
+		case req.URL != "":
+		case req.ID != "":
+			var err error
+			if url, productID, catalogID, err = resolveWatchID(catalogStore, req.ID); err != nil {
+				if errors.Is(err, catalog.ErrNotFound) {
+					c.JSON(http.StatusNotFound, models.ErrorResponse{
+						Error:   "product_not_found",
+						Code:    http.StatusNotFound,
+						Message: "no listing or catalog product has id " + req.ID,
+					})
+					return
+				}
+				writeError(c, err)
+				return
+			}
+		default:
+			writeError(c, apierr.Validation("missing_product", "url or id is required"))
+			return
+		}
+		if _, err := scrapers.DetailScraperFor(url); err != nil {
+			writeError(c, apierr.Validation("invalid_url", err.Error()))
+			return
+		}
+
+		item, created, err := service.Add(url, productID, catalogID, callerID(c, cfg.Profiles))
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		status := http.StatusOK
+		if created {
+			status = http.StatusCreated
+		}
+		c.JSON(status, item)
+	})
+
+	// The caller's watched products, most recently added first
+	group.GET("", func(c *gin.Context) {
+		items, err := service.List(callerID(c, cfg.Profiles))
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"items": items,
+			"total": len(items),
+		})
+	})
+
+	// A watched product's current and lowest-ever price, last check and
+	// price snapshots
+	group.GET("/:id", func(c *gin.Context) {
+		item, err := service.Get(c.Param("id"))
+		if err != nil {
+			watchlistError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, item)
+	})
+
+	group.DELETE("/:id", func(c *gin.Context) {
+		if err := service.Remove(c.Param("id")); err != nil {
+			watchlistError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+}
+
+// resolveWatchID finds the URL to watch for a listing ID or, for a catalog
+// product ID, its cheapest in-stock offer.
+func resolveWatchID(store *catalog.Store, id string) (url, productID, catalogID string, err error) {
+	if store == nil {
+		return "", "", "", apierr.Validation("catalog_unavailable", "products can only be watched by id while the catalog is enabled (CATALOG_ENABLED); send url instead")
+	}
+	if offer, err := store.Offer(id); err == nil {
+		return offer.URL, offer.ID, offer.ProductID, nil
+	} else if !errors.Is(err, catalog.ErrNotFound) {
+		return "", "", "", err
+	}
+
+	product, err := store.Get(id)
+	if err != nil {
+		return "", "", "", err
+	}
+	for _, offer := range product.Offers {
+		if offer.URL != "" {
+			return offer.URL, offer.ID, product.ID, nil
+		}
+	}
+	return "", "", "", catalog.ErrNotFound
+}
+
+func watchlistError(c *gin.Context, err error) {
+	if errors.Is(err, watchlist.ErrNotFound) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "watchlist_item_not_found",
+			Code:    http.StatusNotFound,
+			Message: err.Error(),
+		})
+		return
+	}
+	writeError(c, err)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+	"price-comparison-api/internal/apierr"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/pricefmt"
+	"price-comparison-api/internal/watermark"
+)
+
+// maxTraceBody caps the dataset POST /admin/watermark/trace reads.
+const maxTraceBody = 32 << 20
+
+// watermarkWriter holds a response back so it can be marked before it is
+// sent.
+type watermarkWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *watermarkWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *watermarkWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+// watermarkMiddleware marks the successful JSON responses of the resellers'
+// API keys. Other callers pass through untouched. Resellers are refused
+// what the mark can't survive: rounded and localized prices, WebSocket
+// searches, and successful responses in formats other than JSON, such as
+// the PDF and CSV exports and RSS feeds. Images carry no prices and pass.
+func watermarkMiddleware(marker *watermark.Marker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reseller := marker.Reseller(requestAPIKey(c))
+		if reseller == "" {
+			c.Next()
+			return
+		}
+		if format := strings.TrimSpace(c.Query("price_format")); format != "" && !strings.EqualFold(format, pricefmt.Raw) {
+			writeError(c, apierr.Validation("price_format_unavailable", "watermarked API keys get raw prices only: rounded and localized prices would lose the watermark"))
+			c.Abort()
+			return
+		}
+		if c.GetHeader("Upgrade") != "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, unmarkedFormat("WebSocket searches"))
+			return
+		}
+
+		original := c.Writer
+		w := &watermarkWriter{ResponseWriter: original}
+		c.Writer = w
+		c.Next()
+		c.Writer = original
+
+		body := w.body.Bytes()
+		status := original.Status()
+		contentType := original.Header().Get("Content-Type")
+		switch {
+		case status < 200 || status >= 300:
+		case jsonMediaType(contentType):
+			decoder := json.NewDecoder(bytes.NewReader(body))
+			decoder.UseNumber()
+			var tree interface{}
+			if err := decoder.Decode(&tree); err == nil {
+				if marked, err := json.Marshal(marker.Apply(reseller, tree)); err == nil {
+					body = marked
+				}
+			} else {
+				zerolog.Ctx(c.Request.Context()).Warn().Err(err).Msg("Response left unwatermarked")
+			}
+		case !strings.HasPrefix(contentType, "image/"):
+			// The handler's status and headers aren't sent until the body is
+			original.Header().Del("Content-Disposition")
+			original.Header().Set("Content-Type", "application/json; charset=utf-8")
+			original.WriteHeader(http.StatusForbidden)
+			body, _ = json.Marshal(unmarkedFormat(contentType + " responses"))
+		}
+		original.Header().Del("Content-Length")
+		original.Write(body)
+	}
+}
+
+// unmarkedFormat is the error refusing a reseller what can't be watermarked.
+func unmarkedFormat(what string) models.ErrorResponse {
+	return models.ErrorResponse{
+		Error:   "watermark_unavailable",
+		Code:    http.StatusForbidden,
+		Message: what + " can't be watermarked and aren't available to watermarked API keys; request JSON instead",
+	}
+}
+
+// jsonMediaType reports whether a Content-Type is JSON, including JSON:API's
+// application/vnd.api+json.
+func jsonMediaType(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(mediaType)
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func registerWatermarkRoutes(admin *gin.RouterGroup, marker *watermark.Marker) {
+	// Which reseller a leaked dataset came from: its tokens and how many of
+	// its prices carry each reseller's noise. Takes the dataset as JSON, a
+	// response or any structure holding products.
+	admin.POST("/watermark/trace", func(c *gin.Context) {
+		if marker == nil {
+			c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{
+				Error:   "watermark_disabled",
+				Code:    http.StatusServiceUnavailable,
+				Message: "response watermarking is disabled (WATERMARK_ENABLED)",
+			})
+			return
+		}
+		decoder := json.NewDecoder(io.LimitReader(c.Request.Body, maxTraceBody))
+		decoder.UseNumber()
+		var dataset interface{}
+		if err := decoder.Decode(&dataset); err != nil {
+			writeError(c, apierr.Validation("invalid_dataset", "the dataset must be JSON").WithDetails(err.Error()))
+			return
+		}
+		matches := marker.Trace(dataset)
+		c.JSON(http.StatusOK, gin.H{"matches": matches, "total": len(matches)})
+	})
+}
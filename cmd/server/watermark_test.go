@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/watermark"
+)
+
+const resellerKey = "reseller-key-0123456789"
+
+// watermarkedRouter serves a product list in JSON, as CSV and as an image
+// behind the watermark middleware.
+func watermarkedRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	marker := watermark.New(config.WatermarkConfig{
+		Enabled: true,
+		Secret:  "watermark-test-secret",
+		Keys:    map[string]string{"acme": resellerKey},
+		Token:   true,
+		Noise:   true,
+	})
+	products := gin.H{"products": []gin.H{{"url": "https://www.amazon.com/dp/B000", "price": "$19.99", "price_value": 19.99, "currency": "USD"}}}
+
+	r := gin.New()
+	r.Use(watermarkMiddleware(marker))
+	r.GET("/products", func(c *gin.Context) {
+		format, err := priceFormat(c)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		writePrices(c, products, format, "US")
+	})
+	r.GET("/products.csv", func(c *gin.Context) {
+		c.Header("Content-Disposition", "attachment; filename=products.csv")
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", []byte("url,price\nhttps://www.amazon.com/dp/B000,19.99\n"))
+	})
+	r.GET("/image.png", func(c *gin.Context) {
+		c.Data(http.StatusOK, "image/png", []byte("png"))
+	})
+	return r
+}
+
+func watermarkRequest(r *gin.Engine, path, apiKey string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestWatermarkMarksJSON(t *testing.T) {
+	w := watermarkRequest(watermarkedRouter(), "/products", resellerKey)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200: %s", w.Code, w.Body)
+	}
+	var body struct {
+		Watermark string `json:"watermark"`
+		Products  []struct {
+			Price      *string `json:"price"`
+			PriceValue float64 `json:"price_value"`
+		} `json:"products"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Watermark == "" {
+		t.Error("no watermark token")
+	}
+	if got := body.Products[0].PriceValue; got == 19.99 {
+		t.Error("price_value carries no noise")
+	}
+	if price := body.Products[0].Price; price != nil {
+		t.Errorf("price %q gives the unmarked price back", *price)
+	}
+}
+
+func TestWatermarkRefusesUnmarkedFormats(t *testing.T) {
+	r := watermarkedRouter()
+	for _, tc := range []struct {
+		path   string
+		status int
+	}{
+		{"/products?price_format=localized", http.StatusBadRequest},
+		{"/products?price_format=rounded", http.StatusBadRequest},
+		{"/products.csv", http.StatusForbidden},
+	} {
+		w := watermarkRequest(r, tc.path, resellerKey)
+		if w.Code != tc.status {
+			t.Errorf("%s: status %d, want %d: %s", tc.path, w.Code, tc.status, w.Body)
+		}
+		if !jsonMediaType(w.Header().Get("Content-Type")) {
+			t.Errorf("%s: Content-Type %q, want JSON", tc.path, w.Header().Get("Content-Type"))
+		}
+		if w.Header().Get("Content-Disposition") != "" {
+			t.Errorf("%s: refusal is sent as an attachment", tc.path)
+		}
+	}
+
+	if w := watermarkRequest(r, "/image.png", resellerKey); w.Code != http.StatusOK {
+		t.Errorf("/image.png: status %d, want 200", w.Code)
+	}
+	// Other callers get every format
+	for _, path := range []string{"/products?price_format=localized", "/products.csv"} {
+		if w := watermarkRequest(r, path, ""); w.Code != http.StatusOK {
+			t.Errorf("%s without a reseller key: status %d, want 200", path, w.Code)
+		}
+	}
+}
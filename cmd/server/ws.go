@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gobwas/ws"
+	"github.com/rs/zerolog"
+	"price-comparison-api/internal/apierr"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/profiles"
+	"price-comparison-api/internal/services"
+)
+
+const (
+	// wsMaxMessage caps a client message; a search fits in far less
+	wsMaxMessage = 64 << 10
+	// wsWriteTimeout bounds each write to a client that stopped reading
+	wsWriteTimeout = 10 * time.Second
+)
+
+var errMessageTooBig = errors.New("websocket message too big")
+
+// wsRequest is a message from a /ws/search client: "search" with the
+// SearchParams fields alongside, or "cancel" to stop the running search.
+type wsRequest struct {
+	Type string `json:"type"`
+	models.SearchParams
+}
+
+// wsError is sent for a message the server can't act on.
+type wsError struct {
+	Type  string `json:"type"`
+	Error string `json:"error"`
+}
+
+// wsResult is a finished search, sent on as its done event.
+type wsResult struct {
+	response *models.SearchResponse
+	err      error
+}
+
+func registerWSRoutes(r *gin.Engine, searchService *services.SearchService, profileStore *profiles.Store, cfg *config.Config, maintenance *maintenanceMode) {
+	// Live search progress: the client sends searches and cancels as JSON
+	// messages, and each search's sources are pushed as they finish
+	r.GET("/ws/search", maintenance.searchGuard(searchService), func(c *gin.Context) {
+		conn, rw, _, err := ws.UpgradeHTTP(c.Request, c.Writer)
+		if err != nil {
+			zerolog.Ctx(c.Request.Context()).Debug().Err(err).Msg("WebSocket upgrade failed")
+			return
+		}
+		defer conn.Close()
+
+		// Frames the client sent right after the handshake may already be
+		// buffered
+		var reader io.Reader = conn
+		if rw != nil {
+			reader = rw.Reader
+		}
+		serveSearchSocket(c, conn, reader, searchService, profileStore, cfg)
+	})
+}
+
+// serveSearchSocket runs one /ws/search connection until the client closes
+// it. Searches run one at a time; closing the connection cancels the
+// running one.
+func serveSearchSocket(c *gin.Context, conn net.Conn, reader io.Reader, searchService *services.SearchService, profileStore *profiles.Store, cfg *config.Config) {
+	ctx, cancelConn := context.WithCancel(c.Request.Context())
+	defer cancelConn()
+	logger := zerolog.Ctx(ctx)
+
+	frames := make(chan ws.Frame)
+	readErr := make(chan error, 1)
+	go readFrames(ctx, reader, frames, readErr)
+
+	send := func(v any) bool {
+		body, err := json.Marshal(v)
+		if err != nil {
+			return false
+		}
+		return writeFrame(conn, ws.NewTextFrame(body))
+	}
+
+	var (
+		events       chan services.ProgressEvent
+		results      chan wsResult
+		cancelSearch context.CancelFunc = func() {}
+		cancelled    bool
+		started      time.Time
+		message      []byte
+	)
+	defer func() { cancelSearch() }()
+
+	for {
+		select {
+		case err := <-readErr:
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				logger.Debug().Err(err).Msg("WebSocket read failed")
+			}
+			if errors.Is(err, errMessageTooBig) {
+				writeFrame(conn, ws.NewCloseFrame(ws.NewCloseFrameBody(ws.StatusMessageTooBig, "message too big")))
+			}
+			return
+
+		case event := <-events:
+			if !send(event) {
+				return
+			}
+
+		case result := <-results:
+			done := services.ProgressEvent{Type: services.ProgressDone, Response: result.response, Cancelled: cancelled}
+			if result.err != nil {
+				done.Response = nil
+				if !cancelled {
+					done.Error = apierr.Classify(result.err).Message
+				}
+			}
+			if done.Response != nil {
+				done.Count = len(done.Response.Products)
+			}
+			done.Elapsed = time.Since(started).Round(time.Millisecond).String()
+			cancelSearch()
+			events, results, cancelled = nil, nil, false
+			if !send(done) {
+				return
+			}
+
+		case frame := <-frames:
+			switch frame.Header.OpCode {
+			case ws.OpPing:
+				if !writeFrame(conn, ws.NewPongFrame(frame.Payload)) {
+					return
+				}
+				continue
+			case ws.OpPong:
+				continue
+			case ws.OpClose:
+				writeFrame(conn, ws.NewCloseFrame(frame.Payload))
+				return
+			case ws.OpBinary:
+				if !send(wsError{Type: "error", Error: "binary messages are not supported; send JSON as text"}) {
+					return
+				}
+				continue
+			}
+
+			// Text messages may arrive in fragments
+			message = append(message, frame.Payload...)
+			if len(message) > wsMaxMessage {
+				writeFrame(conn, ws.NewCloseFrame(ws.NewCloseFrameBody(ws.StatusMessageTooBig, "message too big")))
+				return
+			}
+			if !frame.Header.Fin {
+				continue
+			}
+			var req wsRequest
+			err := json.Unmarshal(message, &req)
+			message = nil
+			if err != nil {
+				if !send(wsError{Type: "error", Error: "invalid message: " + err.Error()}) {
+					return
+				}
+				continue
+			}
+
+			switch req.Type {
+			case "search":
+				if results != nil {
+					if !send(wsError{Type: "error", Error: "a search is already running; cancel it first"}) {
+						return
+					}
+					continue
+				}
+				params := req.SearchParams
+				applyProfile(c, profileStore, cfg.Profiles, &params)
+
+				searchCtx, cancel := context.WithCancel(ctx)
+				cancelSearch = cancel
+				started = time.Now()
+				events = make(chan services.ProgressEvent)
+				results = make(chan wsResult, 1)
+				go func(ctx context.Context, events chan<- services.ProgressEvent, results chan<- wsResult) {
+					response, err := searchService.SearchProducts(services.WithProgress(ctx, events), params)
+					results <- wsResult{response: response, err: err}
+				}(searchCtx, events, results)
+
+			case "cancel":
+				if results == nil {
+					if !send(wsError{Type: "error", Error: "no search is running"}) {
+						return
+					}
+					continue
+				}
+				// The search still answers; its done event says it was cancelled
+				cancelled = true
+				cancelSearch()
+
+			default:
+				if !send(wsError{Type: "error", Error: `invalid message type: use "search" or "cancel"`}) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// readFrames reads the client's frames, unmasked, until the connection
+// fails or closes.
+func readFrames(ctx context.Context, r io.Reader, frames chan<- ws.Frame, errs chan<- error) {
+	for {
+		header, err := ws.ReadHeader(r)
+		if err != nil {
+			errs <- err
+			return
+		}
+		if header.Length > wsMaxMessage {
+			errs <- errMessageTooBig
+			return
+		}
+		payload := make([]byte, header.Length)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			errs <- err
+			return
+		}
+		if header.Masked {
+			ws.Cipher(payload, header.Mask, 0)
+		}
+		select {
+		case frames <- ws.Frame{Header: header, Payload: payload}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// writeFrame writes a whole frame at once, reporting whether it succeeded.
+func writeFrame(conn net.Conn, frame ws.Frame) bool {
+	conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return ws.WriteFrame(conn, frame) == nil
+}
@@ -0,0 +1,372 @@
+package alerts
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"price-comparison-api/internal/history"
+)
+
+// Alert types
+const (
+	TypeThreshold    = "threshold"      // price at or below an absolute value
+	TypePercentDrop  = "percent_drop"   // price fell by a percentage from the baseline
+	TypeAnyChange    = "any_change"     // any price change
+	TypeBackInStock  = "back_in_stock"  // out of stock -> in stock
+	TypeLowestInDays = "lowest_in_days" // lowest price seen in the last N days
+	TypeLaunch       = "launch"         // a preorder or announced release can now be bought
+	TypeGift         = "gift"           // buy-by date; fires when it's time to buy
+)
+
+// launchWindow is how long after its release date a product that was never
+// seen as a preorder still counts as launching.
+const launchWindow = 7 * 24 * time.Hour
+
+// Types lists the supported alert types.
+var Types = []string{TypeThreshold, TypePercentDrop, TypeAnyChange, TypeBackInStock, TypeLowestInDays, TypeLaunch, TypeGift}
+
+// defaultCooldowns debounce each alert type so a flapping price doesn't send
+// a notification on every scrape.
+var defaultCooldowns = map[string]time.Duration{
+	TypeThreshold:    24 * time.Hour,
+	TypePercentDrop:  12 * time.Hour,
+	TypeAnyChange:    time.Hour,
+	TypeBackInStock:  6 * time.Hour,
+	TypeLowestInDays: 24 * time.Hour,
+	// launch alerts fire once per product instead
+	TypeLaunch: 0,
+	TypeGift:   24 * time.Hour,
+}
+
+type Alert struct {
+	ID         string `json:"id"`
+	ProductKey string `json:"product_key"`
+	Name       string `json:"name"`
+	URL        string `json:"url"`
+	Country    string `json:"country"`
+	Currency   string `json:"currency"`
+	Category   string `json:"category,omitempty"`
+	// OrgID is the organization that owns the alert; organization alerts are
+	// only listed and fired to channels within it
+	OrgID string `json:"org_id,omitempty"`
+	// Owner is the caller that created the alert with an API key; its events
+	// can be collected with /alerts/poll
+	Owner string `json:"owner,omitempty"`
+	// Query makes a launch alert watch every retailer's listings whose name
+	// contains all of its words, instead of a single product
+	Query string `json:"query,omitempty"`
+
+	Type      string  `json:"type"`
+	Threshold float64 `json:"threshold,omitempty"`
+	Percent   float64 `json:"percent,omitempty"`
+	Days      int     `json:"days,omitempty"`
+	// CooldownSeconds overrides the per-type debounce period
+	CooldownSeconds int `json:"cooldown_seconds,omitempty"`
+	// Paused alerts keep their state but aren't evaluated
+	Paused bool `json:"paused"`
+	// BuyBy adds a buy-now-or-wait recommendation to every event
+	BuyBy *time.Time `json:"buy_by,omitempty"`
+	// TimeZone is the IANA time zone a buy_by date was given in
+	TimeZone string `json:"time_zone,omitempty"`
+	// Notify lists the chats fired events are also sent to
+	Notify []Notification `json:"notify,omitempty"`
+
+	// BaselinePrice is what percent_drop measures against: the highest
+	// price seen since the alert was created or last fired
+	BaselinePrice float64 `json:"baseline_price"`
+	LastPrice     float64 `json:"last_price"`
+	LastInStock   bool    `json:"last_in_stock"`
+
+	LastTriggeredAt    *time.Time `json:"last_triggered_at,omitempty"`
+	LastTriggeredPrice float64    `json:"last_triggered_price,omitempty"`
+	TriggerCount       int        `json:"trigger_count"`
+	// Launched lists the products a launch alert has already fired for
+	Launched []string `json:"launched,omitempty"`
+	// Recommendation is the latest advice for alerts with a buy-by date
+	Recommendation *Recommendation `json:"recommendation,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// Options describe a new alert. The product is identified by product_key
+// (as returned by /deals/drops) or by its URL and country. Launch alerts may
+// instead give a query and country to watch listings across retailers.
+type Options struct {
+	ProductKey      string  `json:"product_key"`
+	URL             string  `json:"url"`
+	Country         string  `json:"country"`
+	Query           string  `json:"query"`
+	Type            string  `json:"type" binding:"required"`
+	Threshold       float64 `json:"threshold"`
+	Percent         float64 `json:"percent"`
+	Days            int     `json:"days"`
+	CooldownSeconds int     `json:"cooldown_seconds"`
+	// BuyBy is a date (2006-01-02) or RFC 3339 time
+	BuyBy string `json:"buy_by"`
+	// TimeZone is the IANA time zone a buy_by date ends in; UTC when empty
+	TimeZone string `json:"time_zone"`
+	// Notify sends fired events to Telegram chats or Slack webhooks
+	Notify []Notification `json:"notify"`
+	// OrgID is set by the organization routes, never from the request body
+	OrgID string `json:"-"`
+	// Owner is set from the caller's API key, never from the request body
+	Owner string `json:"-"`
+
+	buyBy *time.Time
+}
+
+func (o *Options) validate() error {
+	loc := time.UTC
+	if o.TimeZone != "" {
+		var err error
+		if loc, err = time.LoadLocation(o.TimeZone); err != nil {
+			return fmt.Errorf("invalid time_zone %q", o.TimeZone)
+		}
+	}
+	if o.BuyBy != "" {
+		t, err := parseBuyBy(o.BuyBy, loc)
+		if err != nil {
+			return err
+		}
+		if !t.After(time.Now()) {
+			return fmt.Errorf("buy_by must be in the future")
+		}
+		o.buyBy = &t
+	}
+
+	switch o.Type {
+	case TypeThreshold:
+		if o.Threshold <= 0 {
+			return fmt.Errorf("threshold must be positive for %s alerts", o.Type)
+		}
+	case TypePercentDrop:
+		if o.Percent <= 0 || o.Percent >= 100 {
+			return fmt.Errorf("percent must be between 0 and 100 for %s alerts", o.Type)
+		}
+	case TypeLowestInDays:
+		if o.Days <= 0 || o.Days > 365 {
+			return fmt.Errorf("days must be between 1 and 365 for %s alerts", o.Type)
+		}
+	case TypeAnyChange, TypeBackInStock:
+	case TypeGift:
+		if o.buyBy == nil {
+			return fmt.Errorf("buy_by is required for %s alerts", o.Type)
+		}
+		if o.Threshold < 0 {
+			return fmt.Errorf("threshold cannot be negative")
+		}
+	case TypeLaunch:
+		if o.Query != "" && o.Country == "" {
+			return fmt.Errorf("country is required for launch alerts on a query")
+		}
+	default:
+		return fmt.Errorf("invalid alert type: %s", o.Type)
+	}
+	if o.Query != "" && o.Type != TypeLaunch {
+		return fmt.Errorf("query is only supported for %s alerts", TypeLaunch)
+	}
+	if o.CooldownSeconds < 0 {
+		return fmt.Errorf("cooldown_seconds cannot be negative")
+	}
+	return nil
+}
+
+// Event is a fired alert.
+type Event struct {
+	ID            string    `json:"id"`
+	AlertID       string    `json:"alert_id"`
+	OrgID         string    `json:"org_id,omitempty"`
+	Owner         string    `json:"owner,omitempty"`
+	ProductKey    string    `json:"product_key"`
+	Name          string    `json:"name"`
+	URL           string    `json:"url"`
+	Type          string    `json:"type"`
+	Price         float64   `json:"price"`
+	PreviousPrice float64   `json:"previous_price"`
+	Currency      string    `json:"currency"`
+	InStock       bool      `json:"in_stock"`
+	Message       string    `json:"message"`
+	At            time.Time `json:"at"`
+	// Recommendation is set for alerts with a buy-by date
+	Recommendation *Recommendation `json:"recommendation,omitempty"`
+}
+
+// parseBuyBy accepts a date, meaning the end of that day in loc, or a full
+// RFC 3339 time.
+func parseBuyBy(s string, loc *time.Location) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, loc); err == nil {
+		return t.AddDate(0, 0, 1).Add(-time.Second), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid buy_by %q: use YYYY-MM-DD or RFC 3339", s)
+	}
+	return t, nil
+}
+
+func (a *Alert) cooldown() time.Duration {
+	if a.CooldownSeconds > 0 {
+		return time.Duration(a.CooldownSeconds) * time.Second
+	}
+	return defaultCooldowns[a.Type]
+}
+
+// evaluate checks the alert against the product's latest history and updates
+// the alert's observed state. It returns the event to emit, if any.
+func (a *Alert) evaluate(entry history.Entry, now time.Time) (*Event, bool) {
+	cur := entry.Latest()
+	prevPrice, prevInStock := a.LastPrice, a.LastInStock
+	defer func() {
+		a.LastPrice = cur.Price
+		a.LastInStock = cur.InStock
+	}()
+
+	var rec *Recommendation
+	if a.BuyBy != nil {
+		r := recommend(entry, *a.BuyBy, a.Threshold, now)
+		rec = &r
+	}
+	prevRec := a.Recommendation
+	a.Recommendation = rec
+
+	var message string
+	switch a.Type {
+	case TypeThreshold:
+		if cur.Price > a.Threshold {
+			return nil, false
+		}
+		message = fmt.Sprintf("%s is now %.2f %s, at or below your target of %.2f", entry.Name, cur.Price, entry.Currency, a.Threshold)
+
+	case TypePercentDrop:
+		// Measure from the highest price since the last notification
+		if cur.Price > a.BaselinePrice {
+			a.BaselinePrice = cur.Price
+		}
+		if a.BaselinePrice <= 0 {
+			return nil, false
+		}
+		drop := (a.BaselinePrice - cur.Price) / a.BaselinePrice * 100
+		if drop < a.Percent {
+			return nil, false
+		}
+		message = fmt.Sprintf("%s dropped %.1f%% from %.2f to %.2f %s", entry.Name, drop, a.BaselinePrice, cur.Price, entry.Currency)
+
+	case TypeAnyChange:
+		if prevPrice == 0 || cur.Price == prevPrice {
+			return nil, false
+		}
+		message = fmt.Sprintf("%s changed from %.2f to %.2f %s", entry.Name, prevPrice, cur.Price, entry.Currency)
+
+	case TypeBackInStock:
+		if prevInStock || !cur.InStock {
+			return nil, false
+		}
+		message = fmt.Sprintf("%s is back in stock at %.2f %s", entry.Name, cur.Price, entry.Currency)
+
+	case TypeLowestInDays:
+		since := now.Add(-time.Duration(a.Days) * 24 * time.Hour)
+		lowest := math.Inf(1)
+		for _, p := range entry.Points[:len(entry.Points)-1] {
+			if !p.At.Before(since) && p.Price < lowest {
+				lowest = p.Price
+			}
+		}
+		if math.IsInf(lowest, 1) || cur.Price >= lowest {
+			return nil, false
+		}
+		message = fmt.Sprintf("%s is at its lowest price in %d days: %.2f %s", entry.Name, a.Days, cur.Price, entry.Currency)
+
+	case TypeLaunch:
+		if !launched(entry, now) || a.hasLaunched(entry.Key) {
+			return nil, false
+		}
+		a.Launched = append(a.Launched, entry.Key)
+		message = fmt.Sprintf("%s is out: available from %s at %.2f %s", entry.Name, entry.Source, cur.Price, entry.Currency)
+
+	case TypeGift:
+		// Fire when the advice turns to buy now
+		if rec.Action != ActionBuyNow || (prevRec != nil && prevRec.Action == ActionBuyNow) {
+			return nil, false
+		}
+		message = fmt.Sprintf("Time to buy %s at %.2f %s: %s (%d days before your buy-by date)", entry.Name, cur.Price, entry.Currency, rec.Reason, rec.DaysLeft)
+
+	default:
+		return nil, false
+	}
+
+	// Debounce: respect the cooldown and don't repeat the same price
+	if a.LastTriggeredAt != nil && a.Type != TypeLaunch {
+		if now.Sub(*a.LastTriggeredAt) < a.cooldown() {
+			return nil, false
+		}
+		if a.Type != TypeBackInStock && cur.Price == a.LastTriggeredPrice {
+			return nil, false
+		}
+	}
+
+	triggeredAt := now
+	a.LastTriggeredAt = &triggeredAt
+	a.LastTriggeredPrice = cur.Price
+	a.TriggerCount++
+	if a.Type == TypePercentDrop {
+		a.BaselinePrice = cur.Price
+	}
+
+	return &Event{
+		AlertID:        a.ID,
+		OrgID:          a.OrgID,
+		Owner:          a.Owner,
+		ProductKey:     entry.Key,
+		Name:           entry.Name,
+		URL:            entry.URL,
+		Type:           a.Type,
+		Price:          cur.Price,
+		PreviousPrice:  prevPrice,
+		Currency:       entry.Currency,
+		InStock:        cur.InStock,
+		Message:        message,
+		At:             now,
+		Recommendation: rec,
+	}, true
+}
+
+// launched reports whether a product has just become available to buy: it
+// is in stock and no longer a preorder, after either being seen as one or
+// passing its announced release date recently.
+func launched(entry history.Entry, now time.Time) bool {
+	cur := entry.Latest()
+	if cur.Preorder || !cur.InStock {
+		return false
+	}
+	for _, p := range entry.Points[:len(entry.Points)-1] {
+		if p.Preorder {
+			return true
+		}
+	}
+	return entry.ReleaseDate != nil && !now.Before(*entry.ReleaseDate) && now.Sub(*entry.ReleaseDate) < launchWindow
+}
+
+func (a *Alert) hasLaunched(key string) bool {
+	for _, k := range a.Launched {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether a query alert covers entry: same country and every
+// query word in the product name.
+func (a *Alert) matches(entry history.Entry) bool {
+	if a.Query == "" || entry.Country != a.Country {
+		return false
+	}
+	name := strings.ToLower(entry.Name)
+	for _, word := range strings.Fields(strings.ToLower(a.Query)) {
+		if !strings.Contains(name, word) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,204 @@
+package alerts
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"price-comparison-api/internal/history"
+	"price-comparison-api/internal/storage"
+)
+
+// Alert statuses a Filter can select
+const (
+	StatusActive    = "active"    // not paused
+	StatusPaused    = "paused"    // paused by the user
+	StatusTriggered = "triggered" // fired at least once
+)
+
+// Statuses lists the supported status filters.
+var Statuses = []string{StatusActive, StatusPaused, StatusTriggered}
+
+// Filter selects alerts for listing and bulk changes. Empty fields match
+// everything; set fields must all match. OrgID is the exception: alerts only
+// ever match the filter of their own organization, so a filter without one
+// never touches organization alerts.
+type Filter struct {
+	IDs        []string `json:"ids" form:"ids"`
+	Type       string   `json:"type" form:"type"`
+	Country    string   `json:"country" form:"country"`
+	Category   string   `json:"category" form:"category"`
+	ProductKey string   `json:"product_key" form:"product_key"`
+	Status     string   `json:"status" form:"status"`
+	// OrgID is set by the organization routes, never from the request
+	OrgID string `json:"-" form:"-"`
+	// Owner limits the filter to the caller's alerts; it's set from the
+	// caller's API key, never from the request
+	Owner string `json:"-" form:"-"`
+}
+
+// normalize cleans up the filter and splits comma-separated IDs, as sent in
+// a query string.
+func (f *Filter) normalize() error {
+	var ids []string
+	for _, id := range f.IDs {
+		for _, part := range strings.Split(id, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				ids = append(ids, part)
+			}
+		}
+	}
+	f.IDs = ids
+	f.Type = strings.ToLower(strings.TrimSpace(f.Type))
+	f.Country = strings.ToUpper(strings.TrimSpace(f.Country))
+	f.Category = history.Category(f.Category)
+	f.Status = strings.ToLower(strings.TrimSpace(f.Status))
+
+	switch f.Status {
+	case "", StatusActive, StatusPaused, StatusTriggered:
+	default:
+		return fmt.Errorf("invalid status: %s (use %s)", f.Status, strings.Join(Statuses, ", "))
+	}
+	return nil
+}
+
+// Empty reports whether the filter matches every alert outside an
+// organization.
+func (f Filter) Empty() bool {
+	return len(f.IDs) == 0 && f.Type == "" && f.Country == "" && f.Category == "" && f.ProductKey == "" && f.Status == "" && f.OrgID == ""
+}
+
+func (f Filter) matches(a *Alert) bool {
+	if a.OrgID != f.OrgID {
+		return false
+	}
+	if f.Owner != "" && a.Owner != f.Owner {
+		return false
+	}
+	if len(f.IDs) > 0 && !contains(f.IDs, a.ID) {
+		return false
+	}
+	if f.Type != "" && a.Type != f.Type {
+		return false
+	}
+	if f.Country != "" && a.Country != f.Country {
+		return false
+	}
+	if f.Category != "" && a.Category != f.Category {
+		return false
+	}
+	if f.ProductKey != "" && a.ProductKey != f.ProductKey {
+		return false
+	}
+	switch f.Status {
+	case StatusActive:
+		return !a.Paused
+	case StatusPaused:
+		return a.Paused
+	case StatusTriggered:
+		return a.TriggerCount > 0
+	}
+	return true
+}
+
+// Find returns the alerts matching f, newest first. Triggered alerts are
+// ordered by when they last fired instead.
+func (s *Service) Find(f Filter) ([]Alert, error) {
+	if err := f.normalize(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	list := []Alert{}
+	for _, a := range s.alerts {
+		if f.matches(a) {
+			list = append(list, *a)
+		}
+	}
+	s.mu.Unlock()
+
+	if f.Status == StatusTriggered {
+		sort.Slice(list, func(i, j int) bool { return list[i].LastTriggeredAt.After(*list[j].LastTriggeredAt) })
+	} else {
+		sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	}
+	return list, nil
+}
+
+// SetPaused pauses or resumes every alert matching f and returns how many
+// changed. Paused alerts keep their state but aren't evaluated.
+func (s *Service) SetPaused(f Filter, paused bool) (int, error) {
+	if err := f.normalize(); err != nil {
+		return 0, err
+	}
+	if f.Empty() {
+		return 0, fmt.Errorf("a filter is required for bulk changes")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed := 0
+	for _, a := range s.alerts {
+		if !f.matches(a) || a.Paused == paused {
+			continue
+		}
+		a.Paused = paused
+		if err := s.save(a); err != nil {
+			a.Paused = !paused
+			return changed, err
+		}
+		changed++
+	}
+	return changed, nil
+}
+
+// DeleteMatching deletes every alert matching f and returns how many were
+// removed.
+func (s *Service) DeleteMatching(f Filter) (int, error) {
+	if err := f.normalize(); err != nil {
+		return 0, err
+	}
+	if f.Empty() {
+		return 0, fmt.Errorf("a filter is required for bulk changes")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var matched []*Alert
+	for _, a := range s.alerts {
+		if f.matches(a) {
+			matched = append(matched, a)
+		}
+	}
+	if len(matched) == 0 {
+		return 0, nil
+	}
+
+	err := s.db.Update(func(tx storage.Tx) error {
+		bucket := tx.Bucket(alertsBucket)
+		for _, a := range matched {
+			if err := bucket.Delete([]byte(a.ID)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, a := range matched {
+		s.unindex(a)
+	}
+	return len(matched), nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
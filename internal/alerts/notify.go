@@ -0,0 +1,200 @@
+package alerts
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"price-comparison-api/internal/config"
+)
+
+// Notification targets
+const (
+	NotifyTelegram = "telegram"
+	NotifySlack    = "slack"
+)
+
+// telegramChat matches a numeric chat ID (negative for groups) or a public
+// channel's @username.
+var telegramChat = regexp.MustCompile(`^(-?\d{1,20}|@[A-Za-z][A-Za-z0-9_]{4,31})$`)
+
+// slackHosts serve Slack incoming webhooks.
+var slackHosts = map[string]bool{"hooks.slack.com": true, "hooks.slack-gov.com": true}
+
+// Notification is a chat an alert's events are sent to: a Telegram chat,
+// messaged by the configured bot, or a Slack incoming webhook.
+type Notification struct {
+	Type       string `json:"type"`
+	ChatID     string `json:"chat_id,omitempty"`
+	WebhookURL string `json:"webhook_url,omitempty"`
+}
+
+// Redacted returns a copy of the alert for API responses. A Slack webhook
+// URL lets anyone who has it post to the channel, so the secret ending it
+// is replaced.
+func (a Alert) Redacted() Alert {
+	if len(a.Notify) == 0 {
+		return a
+	}
+	notify := make([]Notification, len(a.Notify))
+	for i, n := range a.Notify {
+		if n.WebhookURL != "" {
+			n.WebhookURL = n.WebhookURL[:strings.LastIndex(n.WebhookURL, "/")+1] + redactedSecret
+		}
+		notify[i] = n
+	}
+	a.Notify = notify
+	return a
+}
+
+// redactedSecret replaces the secrets of webhook URLs in responses.
+const redactedSecret = "redacted"
+
+// validateNotifications checks each target has its type's settings, and no
+// target is repeated.
+func validateNotifications(targets []Notification, cfg config.ChatConfig) ([]Notification, error) {
+	seen := map[Notification]bool{}
+	var valid []Notification
+	for _, n := range targets {
+		n.Type = strings.ToLower(strings.TrimSpace(n.Type))
+		n.ChatID = strings.TrimSpace(n.ChatID)
+		n.WebhookURL = strings.TrimSpace(n.WebhookURL)
+		switch n.Type {
+		case NotifyTelegram:
+			if cfg.TelegramBotToken == "" {
+				return nil, fmt.Errorf("telegram notifications need a bot token (TELEGRAM_BOT_TOKEN)")
+			}
+			if !telegramChat.MatchString(n.ChatID) {
+				return nil, fmt.Errorf("telegram notifications need a chat_id: a numeric chat ID or a channel's @username")
+			}
+			n.WebhookURL = ""
+		case NotifySlack:
+			u, err := url.Parse(n.WebhookURL)
+			if err != nil || u.Scheme != "https" || !slackHosts[u.Host] || !strings.HasPrefix(u.Path, "/services/") {
+				return nil, fmt.Errorf("slack notifications need a webhook_url: an incoming webhook such as https://hooks.slack.com/services/...")
+			}
+			n.ChatID = ""
+		default:
+			return nil, fmt.Errorf("invalid notification type: %q (use %s or %s)", n.Type, NotifyTelegram, NotifySlack)
+		}
+		if seen[n] {
+			continue
+		}
+		seen[n] = true
+		valid = append(valid, n)
+	}
+	return valid, nil
+}
+
+// Notifier sends fired alerts to the Telegram chats and Slack channels the
+// alerts name.
+type Notifier struct {
+	alerts *Service
+	cfg    config.ChatConfig
+	client *http.Client
+}
+
+func NewNotifier(alerts *Service, cfg config.ChatConfig) *Notifier {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &Notifier{alerts: alerts, cfg: cfg, client: &http.Client{Timeout: timeout}}
+}
+
+// Deliver sends event to its alert's notification targets. Each message is
+// sent in the background so a slow chat API can't hold up alert evaluation.
+func (n *Notifier) Deliver(event Event) {
+	a, err := n.alerts.Get(event.AlertID)
+	if err != nil {
+		return
+	}
+	for _, target := range a.Notify {
+		go n.send(target, event)
+	}
+}
+
+func (n *Notifier) send(target Notification, event Event) {
+	var endpoint string
+	var payload any
+	switch target.Type {
+	case NotifyTelegram:
+		endpoint = strings.TrimRight(n.cfg.TelegramAPIURL, "/") + "/bot" + n.cfg.TelegramBotToken + "/sendMessage"
+		payload = map[string]any{
+			"chat_id":    target.ChatID,
+			"text":       chatText(event, false),
+			"parse_mode": "HTML",
+		}
+	case NotifySlack:
+		endpoint = target.WebhookURL
+		payload = map[string]any{"text": chatText(event, true)}
+	default:
+		return
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+
+	resp, err := n.client.Post(endpoint, "application/json", bytes.NewReader(body))
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			err = fmt.Errorf("status %d", resp.StatusCode)
+		}
+	}
+	if err != nil {
+		// The error of a failed Telegram request includes the URL, and with it
+		// the bot token
+		if target.Type == NotifyTelegram {
+			err = fmt.Errorf("%s", strings.ReplaceAll(err.Error(), n.cfg.TelegramBotToken, "<token>"))
+		}
+		log.Warn().Err(err).Str("alert_id", event.AlertID).Str("notify", target.Type).Msg("Alert chat notification failed")
+		return
+	}
+	log.Debug().Str("alert_id", event.AlertID).Str("notify", target.Type).Msgf("Sent alert %s", event.ID)
+}
+
+// chatText writes an event as a short chat message: the product name linking
+// to the listing, then the alert's message. Slack uses its own link markup
+// (mrkdwn) and Telegram HTML.
+func chatText(event Event, slack bool) string {
+	name := event.Name
+	if name == "" {
+		name = event.ProductKey
+	}
+	var title string
+	switch {
+	case slack && event.URL != "":
+		title = fmt.Sprintf("*<%s|%s>*", event.URL, slackEscape(name))
+	case slack:
+		title = "*" + slackEscape(name) + "*"
+	case event.URL != "":
+		title = fmt.Sprintf(`<b><a href="%s">%s</a></b>`, htmlEscape(event.URL), htmlEscape(name))
+	default:
+		title = "<b>" + htmlEscape(name) + "</b>"
+	}
+
+	message := event.Message
+	if event.Recommendation != nil && event.Recommendation.Reason != "" {
+		message += "\n" + event.Recommendation.Reason
+	}
+	if slack {
+		return title + "\n" + slackEscape(message)
+	}
+	return title + "\n" + htmlEscape(message)
+}
+
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+func htmlEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;").Replace(s)
+}
@@ -0,0 +1,34 @@
+package alerts
+
+import "testing"
+
+func TestRedactedHidesWebhookSecret(t *testing.T) {
+	a := Alert{Notify: []Notification{
+		{Type: NotifyTelegram, ChatID: "-1001234567890"},
+		{Type: NotifySlack, WebhookURL: "https://hooks.slack.com/services/T000/B000/XXXX"},
+	}}
+
+	r := a.Redacted()
+	if got, want := r.Notify[1].WebhookURL, "https://hooks.slack.com/services/T000/B000/redacted"; got != want {
+		t.Errorf("webhook_url = %q, want %q", got, want)
+	}
+	if got := r.Notify[0].ChatID; got != "-1001234567890" {
+		t.Errorf("chat_id = %q, want it unchanged", got)
+	}
+	if got := a.Notify[1].WebhookURL; got != "https://hooks.slack.com/services/T000/B000/XXXX" {
+		t.Errorf("redacting changed the stored webhook_url to %q", got)
+	}
+}
+
+func TestFilterOwner(t *testing.T) {
+	mine := &Alert{ID: "a", Owner: "key:mine"}
+	theirs := &Alert{ID: "b", Owner: "key:theirs"}
+
+	f := Filter{Owner: "key:mine"}
+	if !f.matches(mine) {
+		t.Error("owner filter leaves out the caller's alert")
+	}
+	if f.matches(theirs) {
+		t.Error("owner filter matches another caller's alert")
+	}
+}
@@ -0,0 +1,157 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"price-comparison-api/internal/storage"
+)
+
+// Polling limits
+const (
+	DefaultPollWait = 30 * time.Second
+	MaxPollWait     = 60 * time.Second
+	maxPollEvents   = 200
+)
+
+// ErrInvalidCursor is returned for since and ack cursors that aren't event
+// IDs.
+var ErrInvalidCursor = fmt.Errorf("invalid cursor: use the cursor of a previous poll")
+
+// Poll is a batch of events for a caller of /alerts/poll.
+type Poll struct {
+	Events []Event `json:"events"`
+	// Cursor is the ID of the last event returned, to acknowledge once the
+	// events are handled or to pass as since for the next batch
+	Cursor string `json:"cursor"`
+	// Acked is the caller's acknowledged cursor
+	Acked string `json:"acked,omitempty"`
+}
+
+// wake releases every waiting poll.
+func (s *Service) wake() {
+	s.storedMu.Lock()
+	defer s.storedMu.Unlock()
+	close(s.stored)
+	s.stored = make(chan struct{})
+}
+
+func (s *Service) storedCh() <-chan struct{} {
+	s.storedMu.Lock()
+	defer s.storedMu.Unlock()
+	return s.stored
+}
+
+// Poll returns up to limit events of the owner's alerts stored after since,
+// oldest first. Without since it starts after the owner's acknowledged
+// cursor, so events are delivered again until they are acknowledged. When
+// there are none it waits up to wait for one to fire.
+func (s *Service) Poll(ctx context.Context, owner, since string, limit int, wait time.Duration) (*Poll, error) {
+	if limit <= 0 || limit > maxPollEvents {
+		limit = maxPollEvents
+	}
+	if since != "" && !validCursor(since) {
+		return nil, ErrInvalidCursor
+	}
+	acked, err := s.Acked(owner)
+	if err != nil {
+		return nil, err
+	}
+	if since == "" {
+		since = acked
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		// Take the channel before reading, so an event stored in between
+		// still wakes us
+		stored := s.storedCh()
+		events, err := s.eventsAfter(owner, since, limit)
+		if err != nil {
+			return nil, err
+		}
+		if len(events) > 0 {
+			return &Poll{Events: events, Cursor: events[len(events)-1].ID, Acked: acked}, nil
+		}
+
+		select {
+		case <-stored:
+		case <-timer.C:
+			return &Poll{Events: events, Cursor: since, Acked: acked}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// eventsAfter reads the owner's events with IDs after since.
+func (s *Service) eventsAfter(owner, since string, limit int) ([]Event, error) {
+	events := []Event{}
+	err := s.db.View(func(tx storage.Tx) error {
+		c := tx.Bucket(eventsBucket).Cursor()
+		k, v := c.First()
+		if since != "" {
+			k, v = c.Seek([]byte(since))
+			if k != nil && string(k) == since {
+				k, v = c.Next()
+			}
+		}
+		for ; k != nil && len(events) < limit; k, v = c.Next() {
+			var event Event
+			if err := json.Unmarshal(v, &event); err != nil {
+				continue
+			}
+			if event.Owner == owner {
+				events = append(events, event)
+			}
+		}
+		return nil
+	})
+	return events, err
+}
+
+// Acked returns the owner's acknowledged cursor, or "" before the first
+// acknowledgement.
+func (s *Service) Acked(owner string) (string, error) {
+	var cursor string
+	err := s.db.View(func(tx storage.Tx) error {
+		cursor = string(tx.Bucket(cursorsBucket).Get([]byte(owner)))
+		return nil
+	})
+	return cursor, err
+}
+
+// Ack marks the owner's events up to and including cursor as delivered. The
+// cursor never moves back, so a late acknowledgement of an older batch is
+// harmless.
+func (s *Service) Ack(owner, cursor string) (string, error) {
+	if !validCursor(cursor) {
+		return "", ErrInvalidCursor
+	}
+	err := s.db.Update(func(tx storage.Tx) error {
+		bucket := tx.Bucket(cursorsBucket)
+		if current := string(bucket.Get([]byte(owner))); current >= cursor {
+			cursor = current
+			return nil
+		}
+		return bucket.Put([]byte(owner), []byte(cursor))
+	})
+	return cursor, err
+}
+
+// validCursor reports whether cursor looks like an event ID: a 20-digit
+// timestamp, a dash and an alert ID.
+func validCursor(cursor string) bool {
+	if len(cursor) < 22 || cursor[20] != '-' {
+		return false
+	}
+	for _, r := range cursor[:20] {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,104 @@
+package alerts
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"price-comparison-api/internal/history"
+)
+
+// Recommendation actions
+const (
+	ActionBuyNow = "buy_now"
+	ActionWait   = "wait"
+)
+
+const (
+	// trendWindow is the history used to estimate the price trend
+	trendWindow = 14 * 24 * time.Hour
+	// lowWindow is the history the current price is compared against
+	lowWindow = 30 * 24 * time.Hour
+	// trendThreshold is the daily change, in percent, that counts as a trend
+	trendThreshold = 0.2
+	// lastCallDays is when a gift alert stops waiting for a better price
+	lastCallDays = 3
+)
+
+// Recommendation tells a buy-by alert's owner whether to buy now or wait.
+type Recommendation struct {
+	Action   string  `json:"action"`
+	Reason   string  `json:"reason"`
+	DaysLeft int     `json:"days_left"`
+	Trend    string  `json:"trend"`             // falling, rising, flat
+	TrendPct float64 `json:"trend_pct_per_day"` // average daily change
+	Lowest   float64 `json:"lowest_30d"`
+}
+
+// recommend weighs the price trend and the lowest recent price against the
+// time left before buyBy.
+func recommend(entry history.Entry, buyBy time.Time, target float64, now time.Time) Recommendation {
+	cur := entry.Latest()
+	rec := Recommendation{
+		DaysLeft: int(math.Ceil(buyBy.Sub(now).Hours() / 24)),
+		Trend:    "flat",
+		Lowest:   cur.Price,
+	}
+
+	for _, p := range entry.Points {
+		if now.Sub(p.At) <= lowWindow && p.Price < rec.Lowest {
+			rec.Lowest = p.Price
+		}
+	}
+	if slope, ok := dailySlope(entry.Points, now); ok && cur.Price > 0 {
+		rec.TrendPct = math.Round(slope/cur.Price*10000) / 100
+		switch {
+		case rec.TrendPct <= -trendThreshold:
+			rec.Trend = "falling"
+		case rec.TrendPct >= trendThreshold:
+			rec.Trend = "rising"
+		}
+	}
+
+	switch {
+	case rec.DaysLeft <= 0:
+		rec.Action, rec.Reason = ActionBuyNow, "buy-by date reached"
+	case target > 0 && cur.Price <= target:
+		rec.Action, rec.Reason = ActionBuyNow, fmt.Sprintf("price is at or below your target of %.2f", target)
+	case rec.DaysLeft <= lastCallDays:
+		rec.Action, rec.Reason = ActionBuyNow, fmt.Sprintf("only %d days left", rec.DaysLeft)
+	case cur.Price <= rec.Lowest*1.01:
+		rec.Action, rec.Reason = ActionBuyNow, "at its lowest price in 30 days"
+	case rec.Trend == "rising":
+		rec.Action, rec.Reason = ActionBuyNow, fmt.Sprintf("price is rising %.1f%% a day", rec.TrendPct)
+	case rec.Trend == "falling" && rec.DaysLeft > 7:
+		rec.Action, rec.Reason = ActionWait, fmt.Sprintf("price is falling %.1f%% a day, likely to drop further", -rec.TrendPct)
+	case rec.DaysLeft > 14:
+		rec.Action, rec.Reason = ActionWait, fmt.Sprintf("price is stable and %d days are left", rec.DaysLeft)
+	default:
+		rec.Action, rec.Reason = ActionBuyNow, "price is stable and the buy-by date is getting close"
+	}
+	return rec
+}
+
+// dailySlope fits a least-squares line through the points in the trend
+// window and returns the price change per day.
+func dailySlope(points []history.Point, now time.Time) (float64, bool) {
+	var n, sumX, sumY, sumXY, sumXX float64
+	for _, p := range points {
+		if now.Sub(p.At) > trendWindow {
+			continue
+		}
+		x := p.At.Sub(now).Hours() / 24
+		n++
+		sumX += x
+		sumY += p.Price
+		sumXY += x * p.Price
+		sumXX += x * x
+	}
+	denom := n*sumXX - sumX*sumX
+	if n < 3 || denom == 0 {
+		return 0, false
+	}
+	return (n*sumXY - sumX*sumY) / denom, true
+}
@@ -0,0 +1,423 @@
+package alerts
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/history"
+	"price-comparison-api/internal/storage"
+)
+
+var (
+	alertsBucket  = []byte("alerts")
+	eventsBucket  = []byte("events")
+	cursorsBucket = []byte("poll_cursors")
+)
+
+// StoreName is the alerts' namespace in a shared SQL database.
+const StoreName = "alerts"
+
+// Buckets are the alerts' buckets, for copying them between databases.
+var Buckets = [][]byte{alertsBucket, eventsBucket, cursorsBucket}
+
+// ErrNotFound is returned for unknown alert IDs.
+var ErrNotFound = fmt.Errorf("alert not found")
+
+// Service stores alerts and evaluates them whenever the price history
+// records new prices.
+type Service struct {
+	db        storage.DB
+	history   *history.Store
+	maxEvents int
+	chat      config.ChatConfig
+
+	mu        sync.Mutex
+	alerts    map[string]*Alert
+	byProduct map[string][]string
+	// byQuery holds launch alerts that watch a query instead of a product
+	byQuery []string
+
+	listenersMu sync.RWMutex
+	listeners   []func(Event)
+
+	// stored is closed and replaced whenever an event is stored, waking
+	// long polls
+	storedMu sync.Mutex
+	stored   chan struct{}
+}
+
+func NewService(cfg config.AlertsConfig, hist *history.Store, backend *storage.Backend) (*Service, error) {
+	if hist == nil {
+		return nil, fmt.Errorf("alerts need the price history store (HISTORY_ENABLED)")
+	}
+
+	path := cfg.Path
+	if path == "" {
+		path = filepath.Join(os.TempDir(), "price-comparison-alerts.db")
+	}
+	maxEvents := cfg.MaxEvents
+	if maxEvents <= 0 {
+		maxEvents = 1000
+	}
+
+	if backend.Driver() == config.StorageBolt {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create alerts directory: %v", err)
+		}
+	}
+	db, err := backend.Open(StoreName, path, Buckets...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open alerts store: %v", err)
+	}
+
+	s := &Service{
+		db:        db,
+		history:   hist,
+		maxEvents: maxEvents,
+		chat:      cfg.Chat,
+		alerts:    make(map[string]*Alert),
+		byProduct: make(map[string][]string),
+		stored:    make(chan struct{}),
+	}
+
+	err = db.View(func(tx storage.Tx) error {
+		return tx.Bucket(alertsBucket).ForEach(func(k, v []byte) error {
+			var a Alert
+			if err := json.Unmarshal(v, &a); err != nil {
+				log.Warn().Err(err).Msgf("Skipping unreadable alert %s", k)
+				return nil
+			}
+			s.index(&a)
+			return nil
+		})
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to init alerts store: %v", err)
+	}
+
+	hist.OnRecord(s.Evaluate)
+	log.Info().Msgf("Alerts ready (%s), %d alerts loaded", backend.Describe(path), len(s.alerts))
+	return s, nil
+}
+
+// index adds an alert to the in-memory maps. Caller holds mu (or is the
+// constructor).
+func (s *Service) index(a *Alert) {
+	s.alerts[a.ID] = a
+	if a.Query != "" {
+		s.byQuery = append(s.byQuery, a.ID)
+		return
+	}
+	s.byProduct[a.ProductKey] = append(s.byProduct[a.ProductKey], a.ID)
+}
+
+// unindex removes an alert from the in-memory maps. Caller holds mu.
+func (s *Service) unindex(a *Alert) {
+	delete(s.alerts, a.ID)
+	if a.Query != "" {
+		s.byQuery = removeID(s.byQuery, a.ID)
+		return
+	}
+	s.byProduct[a.ProductKey] = removeID(s.byProduct[a.ProductKey], a.ID)
+	if len(s.byProduct[a.ProductKey]) == 0 {
+		delete(s.byProduct, a.ProductKey)
+	}
+}
+
+// OnEvent registers fn to receive every fired alert.
+func (s *Service) OnEvent(fn func(Event)) {
+	s.listenersMu.Lock()
+	defer s.listenersMu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+// Create validates opts and stores a new alert seeded with the product's
+// current price.
+func (s *Service) Create(opts Options) (*Alert, error) {
+	opts.Type = strings.ToLower(strings.TrimSpace(opts.Type))
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	notify, err := validateNotifications(opts.Notify, s.chat)
+	if err != nil {
+		return nil, err
+	}
+	opts.Notify = notify
+
+	if opts.Query != "" {
+		return s.createQueryAlert(opts)
+	}
+
+	key := opts.ProductKey
+	if key == "" {
+		if opts.URL == "" || opts.Country == "" {
+			return nil, fmt.Errorf("product_key or url and country are required")
+		}
+		key = strings.ToUpper(opts.Country) + "|" + opts.URL
+	}
+
+	entry, err := s.history.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil || len(entry.Points) == 0 {
+		return nil, fmt.Errorf("product %s has no price history yet; search for it first", key)
+	}
+	cur := entry.Latest()
+
+	a := &Alert{
+		ID:              newID(),
+		ProductKey:      key,
+		Name:            entry.Name,
+		URL:             entry.URL,
+		Country:         entry.Country,
+		Currency:        entry.Currency,
+		Category:        entry.Category,
+		Type:            opts.Type,
+		Threshold:       opts.Threshold,
+		Percent:         opts.Percent,
+		Days:            opts.Days,
+		CooldownSeconds: opts.CooldownSeconds,
+		BuyBy:           opts.buyBy,
+		TimeZone:        opts.TimeZone,
+		Notify:          opts.Notify,
+		OrgID:           opts.OrgID,
+		Owner:           opts.Owner,
+		BaselinePrice:   cur.Price,
+		LastPrice:       cur.Price,
+		LastInStock:     cur.InStock,
+		CreatedAt:       time.Now(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.save(a); err != nil {
+		return nil, err
+	}
+	s.index(a)
+	return a, nil
+}
+
+// createQueryAlert stores a launch alert that matches listings by name, so
+// it needs no price history yet.
+func (s *Service) createQueryAlert(opts Options) (*Alert, error) {
+	a := &Alert{
+		ID:              newID(),
+		Name:            strings.TrimSpace(opts.Query),
+		Query:           strings.TrimSpace(opts.Query),
+		Country:         strings.ToUpper(opts.Country),
+		Category:        history.Category(opts.Query),
+		OrgID:           opts.OrgID,
+		Owner:           opts.Owner,
+		Type:            opts.Type,
+		CooldownSeconds: opts.CooldownSeconds,
+		Notify:          opts.Notify,
+		CreatedAt:       time.Now(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.save(a); err != nil {
+		return nil, err
+	}
+	s.index(a)
+	return a, nil
+}
+
+// save persists an alert. Caller holds mu.
+func (s *Service) save(a *Alert) error {
+	raw, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx storage.Tx) error {
+		return tx.Bucket(alertsBucket).Put([]byte(a.ID), raw)
+	})
+}
+
+func (s *Service) Get(id string) (*Alert, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.alerts[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	copied := *a
+	return &copied, nil
+}
+
+func (s *Service) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.alerts[id]
+	if !ok {
+		return ErrNotFound
+	}
+	err := s.db.Update(func(tx storage.Tx) error {
+		return tx.Bucket(alertsBucket).Delete([]byte(id))
+	})
+	if err != nil {
+		return err
+	}
+
+	s.unindex(a)
+	return nil
+}
+
+// Watchers returns how many active alerts watch each product.
+func (s *Service) Watchers() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	watchers := make(map[string]int, len(s.byProduct))
+	for key, ids := range s.byProduct {
+		for _, id := range ids {
+			if !s.alerts[id].Paused {
+				watchers[key]++
+			}
+		}
+	}
+	return watchers
+}
+
+// Evaluate runs every alert watching one of the updated products. It is
+// registered as a history observer.
+func (s *Service) Evaluate(entries []history.Entry) {
+	now := time.Now()
+	var events []Event
+
+	s.mu.Lock()
+	for _, entry := range entries {
+		ids := s.byProduct[entry.Key]
+		for _, id := range s.byQuery {
+			if s.alerts[id].matches(entry) {
+				ids = append(ids[:len(ids):len(ids)], id)
+			}
+		}
+		for _, id := range ids {
+			a := s.alerts[id]
+			if a.Paused {
+				continue
+			}
+			event, fired := a.evaluate(entry, now)
+			if err := s.save(a); err != nil {
+				log.Warn().Err(err).Msgf("Failed to save alert %s", a.ID)
+			}
+			if fired {
+				events = append(events, *event)
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	for _, event := range events {
+		s.emit(event)
+	}
+}
+
+func (s *Service) emit(event Event) {
+	event.ID = fmt.Sprintf("%020d-%s", event.At.UnixNano(), event.AlertID)
+	if err := s.storeEvent(event); err != nil {
+		log.Warn().Err(err).Msg("Failed to store alert event")
+	} else {
+		s.wake()
+	}
+	log.Info().Msgf("Alert %s fired (%s): %s", event.AlertID, event.Type, event.Message)
+
+	s.listenersMu.RLock()
+	listeners := s.listeners
+	s.listenersMu.RUnlock()
+	for _, fn := range listeners {
+		fn(event)
+	}
+}
+
+// storeEvent appends an event and drops the oldest past maxEvents. Event IDs
+// start with the timestamp so the bucket is in chronological order.
+func (s *Service) storeEvent(event Event) error {
+	raw, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx storage.Tx) error {
+		bucket := tx.Bucket(eventsBucket)
+		if err := bucket.Put([]byte(event.ID), raw); err != nil {
+			return err
+		}
+		count := 0
+		bucket.ForEach(func(k, v []byte) error {
+			count++
+			return nil
+		})
+
+		over := count - s.maxEvents
+		c := bucket.Cursor()
+		for k, _ := c.First(); k != nil && over > 0; k, _ = c.First() {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+			over--
+		}
+		return nil
+	})
+}
+
+// Events returns recent events of the organization's alerts (or of alerts
+// outside any organization when orgID is empty), newest first, optionally
+// only the owner's or for one alert.
+func (s *Service) Events(orgID, owner, alertID string, limit int) ([]Event, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	events := []Event{}
+	err := s.db.View(func(tx storage.Tx) error {
+		c := tx.Bucket(eventsBucket).Cursor()
+		for k, v := c.Last(); k != nil && len(events) < limit; k, v = c.Prev() {
+			var event Event
+			if err := json.Unmarshal(v, &event); err != nil {
+				continue
+			}
+			if event.OrgID != orgID || (owner != "" && event.Owner != owner) || (alertID != "" && event.AlertID != alertID) {
+				continue
+			}
+			events = append(events, event)
+		}
+		return nil
+	})
+	return events, err
+}
+
+func (s *Service) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+func removeID(ids []string, id string) []string {
+	for i, other := range ids {
+		if other == id {
+			return append(ids[:i:i], ids[i+1:]...)
+		}
+	}
+	return ids
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,185 @@
+package alerts
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/history"
+	"price-comparison-api/internal/scrapers"
+)
+
+// stockTick is how often the checker looks for products that are due.
+const stockTick = time.Minute
+
+// StockCheck is the scheduling state of one out-of-stock product.
+type StockCheck struct {
+	ProductKey  string     `json:"product_key"`
+	Name        string     `json:"name"`
+	URL         string     `json:"url"`
+	Watchers    int        `json:"watchers"`
+	Interval    string     `json:"interval"`
+	LastChecked *time.Time `json:"last_checked,omitempty"`
+	NextCheck   time.Time  `json:"next_check"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+// StockChecker periodically re-checks the detail pages of out-of-stock
+// products that have alerts. When one comes back in stock the change is
+// recorded in the price history, which in turn fires back_in_stock alerts.
+type StockChecker struct {
+	alerts  *Service
+	history *history.Store
+	cfg     config.StockCheckConfig
+	client  *http.Client
+	stop    chan struct{}
+
+	mu     sync.Mutex
+	checks map[string]*StockCheck
+}
+
+func NewStockChecker(cfg config.StockCheckConfig, alerts *Service, hist *history.Store) *StockChecker {
+	return &StockChecker{
+		alerts:  alerts,
+		history: hist,
+		cfg:     cfg,
+		client:  &http.Client{Timeout: cfg.Timeout},
+		stop:    make(chan struct{}),
+		checks:  make(map[string]*StockCheck),
+	}
+}
+
+func (c *StockChecker) Start() {
+	log.Info().Msgf("Stock checks enabled, interval: %s, popular interval: %s (%d+ watchers)",
+		c.cfg.Interval, c.cfg.PopularInterval, c.cfg.PopularWatchers)
+	go func() {
+		ticker := time.NewTicker(stockTick)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+				c.runDue()
+			}
+		}
+	}()
+}
+
+func (c *StockChecker) Stop() {
+	if c == nil {
+		return
+	}
+	close(c.stop)
+}
+
+// interval picks the check frequency from how many alerts watch a product.
+func (c *StockChecker) interval(watchers int) time.Duration {
+	if watchers >= c.cfg.PopularWatchers {
+		return c.cfg.PopularInterval
+	}
+	return c.cfg.Interval
+}
+
+// runDue checks every tracked product whose next check has come up. Checks
+// run one at a time to keep the load on retailers low.
+func (c *StockChecker) runDue() {
+	now := time.Now()
+	watchers := c.alerts.Watchers()
+
+	var due []*StockCheck
+	c.mu.Lock()
+	for key := range c.checks {
+		if watchers[key] == 0 {
+			delete(c.checks, key)
+		}
+	}
+	for key, n := range watchers {
+		entry, err := c.history.Get(key)
+		if err != nil || entry == nil || len(entry.Points) == 0 || entry.URL == "" {
+			continue
+		}
+		if entry.Latest().InStock {
+			delete(c.checks, key)
+			continue
+		}
+
+		interval := c.interval(n)
+		check, ok := c.checks[key]
+		if !ok {
+			// First seen out of stock: wait one interval from the last scrape
+			check = &StockCheck{ProductKey: key, NextCheck: entry.Latest().At.Add(interval)}
+			c.checks[key] = check
+		}
+		check.Name = entry.Name
+		check.URL = entry.URL
+		check.Watchers = n
+		check.Interval = interval.String()
+		if check.LastChecked != nil {
+			check.NextCheck = check.LastChecked.Add(interval)
+		}
+		if !now.Before(check.NextCheck) {
+			due = append(due, check)
+		}
+	}
+	c.mu.Unlock()
+
+	for _, check := range due {
+		c.check(check)
+	}
+}
+
+func (c *StockChecker) check(check *StockCheck) {
+	c.mu.Lock()
+	key, url := check.ProductKey, check.URL
+	c.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.Timeout)
+	defer cancel()
+	inStock, err := scrapers.CheckAvailability(ctx, c.client, url, c.cfg.UserAgent)
+
+	now := time.Now()
+	c.mu.Lock()
+	check.LastChecked = &now
+	check.NextCheck = now.Add(c.interval(check.Watchers))
+	check.LastError = ""
+	if err != nil {
+		check.LastError = err.Error()
+	}
+	c.mu.Unlock()
+
+	if err != nil {
+		log.Warn().Err(err).Str("product_key", key).Msg("Stock check failed")
+		return
+	}
+	if !inStock {
+		log.Debug().Str("product_key", key).Msg("Stock check: still out of stock")
+		return
+	}
+
+	log.Info().Str("product_key", key).Msg("Stock check: product back in stock")
+	if err := c.history.RecordAvailability(key, true); err != nil {
+		log.Warn().Err(err).Str("product_key", key).Msg("Failed to record availability")
+		return
+	}
+	c.mu.Lock()
+	delete(c.checks, key)
+	c.mu.Unlock()
+}
+
+// Checks returns the tracked out-of-stock products, next check first.
+func (c *StockChecker) Checks() []StockCheck {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	list := make([]StockCheck, 0, len(c.checks))
+	for _, check := range c.checks {
+		list = append(list, *check)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].NextCheck.Before(list[j].NextCheck) })
+	return list
+}
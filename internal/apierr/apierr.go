@@ -0,0 +1,166 @@
+// Package apierr is the error taxonomy shared by scrapers, services and HTTP
+// handlers. Every failure a client can act on belongs to one kind, and each
+// kind maps to one HTTP status and one "type" in the error response, so
+// clients can branch on the type instead of parsing messages.
+package apierr
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"price-comparison-api/internal/models"
+)
+
+// Error kinds. Match them with errors.Is.
+var (
+	// ErrValidation is a request the API refuses as given
+	ErrValidation = errors.New("validation failed")
+	// ErrUnsupportedCountry is a country no enabled source can search
+	ErrUnsupportedCountry = errors.New("unsupported country")
+	// ErrBlocked is a retailer refusing to serve us (403, 429, captcha)
+	ErrBlocked = errors.New("blocked by retailer")
+	// ErrTimeout is a retailer or the request running out of time
+	ErrTimeout = errors.New("timed out")
+	// ErrUpstream is any other failure of a retailer or API
+	ErrUpstream = errors.New("upstream error")
+)
+
+// kinds maps each kind to its response type and HTTP status.
+var kinds = []struct {
+	kind   error
+	typ    string
+	status int
+}{
+	{ErrValidation, "validation", http.StatusBadRequest},
+	{ErrUnsupportedCountry, "unsupported_country", http.StatusUnprocessableEntity},
+	{ErrBlocked, "blocked", http.StatusServiceUnavailable},
+	{ErrTimeout, "timeout", http.StatusGatewayTimeout},
+	{ErrUpstream, "upstream", http.StatusBadGateway},
+}
+
+// Error is a failure of a known kind with a machine-readable code, like
+// "invalid_sort" for ErrValidation.
+type Error struct {
+	Kind    error
+	Code    string
+	Message string
+	Details string
+}
+
+func (e *Error) Error() string { return e.Message }
+
+func (e *Error) Unwrap() error { return e.Kind }
+
+// WithDetails attaches extra context, such as a JSON decoding error.
+func (e *Error) WithDetails(details string) *Error {
+	e.Details = details
+	return e
+}
+
+// New returns an error of kind with the given code and message.
+func New(kind error, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+// Validation returns an ErrValidation error.
+func Validation(code, message string) *Error {
+	return New(ErrValidation, code, message)
+}
+
+// UnsupportedCountry returns an ErrUnsupportedCountry error.
+func UnsupportedCountry(message string) *Error {
+	return New(ErrUnsupportedCountry, "unsupported_country", message)
+}
+
+// Classify returns err as an *Error, deciding the kind of errors that don't
+// carry one from what they wrap or say: timeouts, blocking responses (403,
+// 429, captchas) and, for everything else, ErrUpstream.
+func Classify(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+
+	var netErr net.Error
+	msg := err.Error()
+	lower := strings.ToLower(msg)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout(),
+		strings.Contains(lower, "timeout"), strings.Contains(lower, "deadline exceeded"):
+		return New(ErrTimeout, "timeout", msg)
+	case errors.Is(err, ErrBlocked), blocked(lower):
+		return New(ErrBlocked, "blocked", msg)
+	}
+	return New(ErrUpstream, "upstream_error", msg)
+}
+
+// blocked reports whether an error message describes a blocking response.
+// Colly reports HTTP errors by status text ("Forbidden") and the API
+// clients by code ("returned status 429").
+func blocked(msg string) bool {
+	for _, marker := range []string{
+		strings.ToLower(http.StatusText(http.StatusForbidden)),
+		strings.ToLower(http.StatusText(http.StatusTooManyRequests)),
+		"status 403", "status 429", "captcha", "robot check",
+	} {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// Status returns the HTTP status for err's kind, or 500 when it has none.
+func Status(err error) int {
+	for _, k := range kinds {
+		if errors.Is(err, k.kind) {
+			return k.status
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+// Type returns the response type for err's kind, or "internal".
+func Type(err error) string {
+	for _, k := range kinds {
+		if errors.Is(err, k.kind) {
+			return k.typ
+		}
+	}
+	return "internal"
+}
+
+// Response returns the status and body for err. Errors without a kind are
+// reported as internal errors under fallbackCode.
+func Response(err error, fallbackCode string) (int, models.ErrorResponse) {
+	resp := models.ErrorResponse{
+		Error:   fallbackCode,
+		Type:    Type(err),
+		Code:    Status(err),
+		Message: err.Error(),
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		resp.Error = e.Code
+		resp.Details = e.Details
+	}
+	return resp.Code, resp
+}
+
+// FromResponse rebuilds the error a response body was made from, such as
+// one kept in the cache.
+func FromResponse(resp models.ErrorResponse) *Error {
+	kind := ErrUpstream
+	for _, k := range kinds {
+		if k.typ == resp.Type {
+			kind = k.kind
+		}
+	}
+	return &Error{Kind: kind, Code: resp.Error, Message: resp.Message, Details: resp.Details}
+}
@@ -0,0 +1,417 @@
+package catalog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/rs/zerolog/log"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/history"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/storage"
+	"price-comparison-api/pkg/utils"
+)
+
+var (
+	productsBucket = []byte("products")
+	offersBucket   = []byte("offers")
+	// matchesBucket maps a product's match key to its ID
+	matchesBucket = []byte("matches")
+)
+
+// StoreName is the catalog's namespace in a shared SQL database.
+const StoreName = "catalog"
+
+// Buckets are the catalog's buckets, for copying it between databases.
+var Buckets = [][]byte{productsBucket, offersBucket, matchesBucket}
+
+// ErrNotFound is returned for unknown product IDs.
+var ErrNotFound = fmt.Errorf("product not found in catalog")
+
+// Product is a canonical product: the listings of every retailer in one
+// country that sell the same variant of the same thing under the same title.
+type Product struct {
+	ID       string `json:"id"`
+	Country  string `json:"country"`
+	Name     string `json:"name"`
+	Image    string `json:"image,omitempty"`
+	Category string `json:"category"`
+	// Attributes are the variant its listings share, such as its storage
+	// and color
+	Attributes map[string]string `json:"attributes,omitempty"`
+	OfferIDs   []string          `json:"offer_ids"`
+	// LowestPrice is the cheapest in-stock offer's last price, or the
+	// cheapest offer's when none is in stock
+	LowestPrice float64   `json:"lowest_price"`
+	Currency    string    `json:"currency"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+}
+
+// Offer is one retailer listing of a product, as last scraped.
+type Offer struct {
+	ID        string `json:"id"`
+	ProductID string `json:"product_id"`
+	// Key is the listing's price history key
+	Key       string    `json:"key"`
+	Source    string    `json:"source"`
+	Merchant  string    `json:"merchant,omitempty"`
+	Country   string    `json:"country"`
+	Name      string    `json:"name"`
+	URL       string    `json:"url"`
+	Image     string    `json:"image,omitempty"`
+	Price     float64   `json:"price"`
+	Currency  string    `json:"currency"`
+	InStock   bool      `json:"in_stock"`
+	Condition string    `json:"condition,omitempty"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	TimesSeen int       `json:"times_seen"`
+}
+
+// ProductView is a product with its offers, cheapest first.
+type ProductView struct {
+	Product
+	Offers []Offer `json:"offers"`
+}
+
+// Store keeps the catalog in the configured storage.
+type Store struct {
+	db storage.DB
+}
+
+func NewStore(cfg config.CatalogConfig, backend *storage.Backend) (*Store, error) {
+	path := cfg.Path
+	if path == "" {
+		path = filepath.Join(os.TempDir(), "price-comparison-catalog.db")
+	}
+	if backend.Driver() == config.StorageBolt {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create catalog directory: %v", err)
+		}
+	}
+	db, err := backend.Open(StoreName, path, Buckets...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open catalog store: %v", err)
+	}
+
+	log.Info().Msgf("Product catalog ready (%s)", backend.Describe(path))
+	return &Store{db: db}, nil
+}
+
+// Upsert records every listing a search found as an offer under its product
+// ID, and sets CatalogID to the canonical product the listing belongs to. A
+// new listing joins the product with the same title in the same country, or
+// starts one; once placed, a listing stays with its product even if its
+// title changes, so the IDs clients hold keep pointing at the same thing.
+func (s *Store) Upsert(query, country string, products []models.Product) error {
+	now := time.Now()
+	country = strings.ToUpper(country)
+
+	return s.db.Update(func(tx storage.Tx) error {
+		offers := tx.Bucket(offersBucket)
+		touched := map[string]*Product{}
+
+		for i := range products {
+			p := &products[i]
+			key := history.Key(country, *p)
+			offerID := p.ID
+			if offerID == "" {
+				offerID = "o_" + hashID(key)
+			}
+
+			var offer Offer
+			if raw := offers.Get([]byte(offerID)); raw != nil {
+				if err := json.Unmarshal(raw, &offer); err != nil {
+					offer = Offer{}
+				}
+			}
+			if offer.ProductID == "" {
+				productID, err := placeOffer(tx, touched, country, query, offerID, *p, now)
+				if err != nil {
+					return err
+				}
+				offer = Offer{ID: offerID, ProductID: productID, Key: key, FirstSeen: now}
+			}
+
+			offer.Source = p.Source
+			offer.Merchant = p.Merchant
+			offer.Country = country
+			offer.Name = p.Name
+			offer.URL = p.URL
+			offer.Image = p.Image
+			if p.PriceValue > 0 {
+				offer.Price = p.PriceValue
+				offer.Currency = p.Currency
+			}
+			offer.InStock = p.InStock
+			offer.Condition = p.Condition
+			offer.LastSeen = now
+			offer.TimesSeen++
+			if err := putJSON(offers, offer.ID, offer); err != nil {
+				return err
+			}
+
+			if _, ok := touched[offer.ProductID]; !ok {
+				product, err := getProduct(tx, offer.ProductID)
+				if err != nil {
+					return err
+				}
+				touched[offer.ProductID] = product
+			}
+			p.ID = offer.ID
+			p.CatalogID = offer.ProductID
+		}
+
+		for _, product := range touched {
+			if err := refresh(tx, product, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// placeOffer finds or creates the product a new listing belongs to and adds
+// the listing to it.
+func placeOffer(tx storage.Tx, touched map[string]*Product, country, query, offerID string, p models.Product, now time.Time) (string, error) {
+	match := matchKey(country, p.Name)
+	if match == country+"|" {
+		// Without a title there's nothing to match on
+		match = offerID
+	}
+	matches := tx.Bucket(matchesBucket)
+
+	if id := matches.Get([]byte(match)); id != nil {
+		product, ok := touched[string(id)]
+		if !ok {
+			var err error
+			if product, err = getProduct(tx, string(id)); err != nil {
+				return "", err
+			}
+			touched[product.ID] = product
+		}
+		product.OfferIDs = append(product.OfferIDs, offerID)
+		return product.ID, nil
+	}
+
+	category := p.Category
+	if category == "" {
+		category = history.Category(query)
+	}
+	product := &Product{
+		ID:         "p_" + hashID(match),
+		Country:    country,
+		Name:       p.Name,
+		Image:      p.Image,
+		Category:   category,
+		Attributes: utils.ParseAttributes(p.Name),
+		OfferIDs:   []string{offerID},
+		FirstSeen:  now,
+	}
+	if err := matches.Put([]byte(match), []byte(product.ID)); err != nil {
+		return "", err
+	}
+	touched[product.ID] = product
+	return product.ID, nil
+}
+
+// refresh recomputes a product's price from its offers and saves it.
+func refresh(tx storage.Tx, product *Product, now time.Time) error {
+	offers, err := productOffers(tx, product)
+	if err != nil {
+		return err
+	}
+	product.LowestPrice, product.Currency = 0, ""
+	if len(offers) > 0 && offers[0].Price > 0 {
+		product.LowestPrice, product.Currency = offers[0].Price, offers[0].Currency
+	}
+	if product.Image == "" {
+		for _, o := range offers {
+			if o.Image != "" {
+				product.Image = o.Image
+				break
+			}
+		}
+	}
+	product.LastSeen = now
+	return putJSON(tx.Bucket(productsBucket), product.ID, product)
+}
+
+// productOffers reads a product's offers, in stock first, then cheapest
+// first; offers without a price go last.
+func productOffers(tx storage.Tx, product *Product) ([]Offer, error) {
+	bucket := tx.Bucket(offersBucket)
+	offers := make([]Offer, 0, len(product.OfferIDs))
+	for _, id := range product.OfferIDs {
+		raw := bucket.Get([]byte(id))
+		if raw == nil {
+			continue
+		}
+		var offer Offer
+		if err := json.Unmarshal(raw, &offer); err != nil {
+			return nil, err
+		}
+		offers = append(offers, offer)
+	}
+	sort.SliceStable(offers, func(i, j int) bool {
+		a, b := offers[i], offers[j]
+		if a.InStock != b.InStock {
+			return a.InStock
+		}
+		if (a.Price > 0) != (b.Price > 0) {
+			return a.Price > 0
+		}
+		return a.Price < b.Price
+	})
+	return offers, nil
+}
+
+func getProduct(tx storage.Tx, id string) (*Product, error) {
+	raw := tx.Bucket(productsBucket).Get([]byte(id))
+	if raw == nil {
+		return nil, ErrNotFound
+	}
+	var product Product
+	if err := json.Unmarshal(raw, &product); err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+// Get returns a product with its offers.
+func (s *Store) Get(id string) (*ProductView, error) {
+	var view *ProductView
+	err := s.db.View(func(tx storage.Tx) error {
+		product, err := getProduct(tx, id)
+		if err != nil {
+			return err
+		}
+		offers, err := productOffers(tx, product)
+		if err != nil {
+			return err
+		}
+		view = &ProductView{Product: *product, Offers: offers}
+		return nil
+	})
+	return view, err
+}
+
+// Offer returns one listing.
+func (s *Store) Offer(id string) (*Offer, error) {
+	var offer *Offer
+	err := s.db.View(func(tx storage.Tx) error {
+		raw := tx.Bucket(offersBucket).Get([]byte(id))
+		if raw == nil {
+			return ErrNotFound
+		}
+		offer = &Offer{}
+		return json.Unmarshal(raw, offer)
+	})
+	return offer, err
+}
+
+// Filter selects catalog products. Empty fields match everything.
+type Filter struct {
+	Country  string `form:"country"`
+	Category string `form:"category"`
+	// Query matches products whose name holds every word of it
+	Query string `form:"q"`
+}
+
+// Find returns the products matching filter, most recently seen first.
+func (s *Store) Find(filter Filter) ([]Product, error) {
+	country := strings.ToUpper(strings.TrimSpace(filter.Country))
+	category := strings.ToLower(strings.TrimSpace(filter.Category))
+	words := strings.Fields(strings.ToLower(filter.Query))
+
+	products := []Product{}
+	err := s.db.View(func(tx storage.Tx) error {
+		return tx.Bucket(productsBucket).ForEach(func(k, v []byte) error {
+			var product Product
+			if err := json.Unmarshal(v, &product); err != nil {
+				return nil
+			}
+			if country != "" && product.Country != country {
+				return nil
+			}
+			if category != "" && product.Category != category {
+				return nil
+			}
+			name := strings.ToLower(product.Name)
+			for _, word := range words {
+				if !strings.Contains(name, word) {
+					return nil
+				}
+			}
+			products = append(products, product)
+			return nil
+		})
+	})
+	sort.Slice(products, func(i, j int) bool {
+		return products[i].LastSeen.After(products[j].LastSeen)
+	})
+	return products, err
+}
+
+// Counts returns how many products and offers the catalog holds.
+func (s *Store) Counts() (products, offers int) {
+	s.db.View(func(tx storage.Tx) error {
+		products = tx.Bucket(productsBucket).KeyN()
+		offers = tx.Bucket(offersBucket).KeyN()
+		return nil
+	})
+	return products, offers
+}
+
+func (s *Store) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// matchKey is what listings of the same product share: the country, the
+// title's words without its variant, lowercased, without punctuation and in
+// any order, and the variant's attributes. "128 GB" and "128GB" are the same
+// variant, but a 128GB phone never matches the 512GB one.
+func matchKey(country, name string) string {
+	base, attrs := utils.SplitVariant(name)
+	words := strings.FieldsFunc(strings.ToLower(base), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	sort.Strings(words)
+	unique := words[:0]
+	for i, w := range words {
+		if i == 0 || w != words[i-1] {
+			unique = append(unique, w)
+		}
+	}
+	key := country + "|" + strings.Join(unique, " ")
+	if len(attrs) > 0 {
+		key += "|" + utils.VariantKey(attrs)
+	}
+	return key
+}
+
+// hashID shortens key to 16 hex characters.
+func hashID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+func putJSON(bucket storage.Bucket, id string, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(id), raw)
+}
@@ -0,0 +1,220 @@
+// Package categories is the product taxonomy searches can be narrowed to.
+// Retailers name their departments differently, so scrapers collect
+// whatever hints a listing gives (breadcrumbs, category paths, the product
+// name) and Detect maps them onto one shared, two-level tree.
+package categories
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Category is one node of the taxonomy.
+type Category struct {
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+	// Parent is the slug of the top-level category a subcategory belongs to
+	Parent   string     `json:"parent,omitempty"`
+	Children []Category `json:"children,omitempty"`
+
+	// keywords are lower-case words and phrases that point at the category;
+	// plurals are matched too
+	keywords []string
+}
+
+// taxonomy lists the top-level categories, each followed by its
+// subcategories. Subcategory keywords are specific enough to win over their
+// parent's.
+var taxonomy = []Category{
+	{Slug: "electronics", Name: "Electronics", keywords: []string{
+		"electronics", "electronic", "charger", "cable", "adapter", "battery", "power bank",
+		"usb", "hdmi", "bluetooth", "wireless", "smart home", "smartwatch", "watch", "wearable",
+		"drone", "router", "ipad", "tablet", "kindle", "e-reader",
+	}},
+	{Slug: "phones", Name: "Cell Phones", Parent: "electronics", keywords: []string{
+		"phone", "cell phone", "smartphone", "mobile", "iphone", "galaxy", "pixel", "android",
+		"unlocked", "5g", "sim",
+	}},
+	{Slug: "computers", Name: "Computers & Tablets", Parent: "electronics", keywords: []string{
+		"computer", "laptop", "notebook", "macbook", "chromebook", "desktop", "pc", "monitor",
+		"keyboard", "mouse", "ssd", "hard drive", "printer", "imac", "mac mini", "gpu",
+		"graphics card", "processor", "cpu", "ram",
+	}},
+	{Slug: "tv-audio", Name: "TV & Audio", Parent: "electronics", keywords: []string{
+		"tv", "television", "oled", "qled", "soundbar", "speaker", "headphone", "headset",
+		"earbud", "earphone", "airpods", "audio", "home theater", "projector", "receiver",
+		"streaming", "roku", "fire stick",
+	}},
+	{Slug: "cameras", Name: "Cameras", Parent: "electronics", keywords: []string{
+		"camera", "dslr", "mirrorless", "lens", "gopro", "camcorder", "tripod", "webcam",
+	}},
+	{Slug: "gaming", Name: "Video Games", Parent: "electronics", keywords: []string{
+		"video game", "gaming", "playstation", "ps5", "ps4", "xbox", "nintendo", "switch",
+		"console", "controller", "steam deck",
+	}},
+	{Slug: "home", Name: "Home & Kitchen", keywords: []string{
+		"home", "kitchen", "furniture", "appliance", "vacuum", "blender", "cookware", "pan",
+		"pot", "knife", "mattress", "bedding", "pillow", "sofa", "chair", "table", "lamp",
+		"decor", "rug", "curtain", "coffee maker", "air fryer", "microwave", "refrigerator",
+		"washer", "dryer", "garden", "patio", "tool", "drill",
+	}},
+	{Slug: "fashion", Name: "Clothing, Shoes & Jewelry", keywords: []string{
+		"clothing", "apparel", "fashion", "shirt", "t-shirt", "dress", "jeans", "pants",
+		"jacket", "coat", "hoodie", "sweater", "shoe", "sneaker", "boot", "sandal", "jewelry",
+		"necklace", "ring", "bracelet", "earring", "handbag", "bag", "backpack", "wallet",
+		"sunglasses",
+	}},
+	{Slug: "beauty", Name: "Beauty & Health", keywords: []string{
+		"beauty", "makeup", "cosmetic", "skincare", "skin care", "lipstick", "perfume",
+		"fragrance", "shampoo", "conditioner", "lotion", "serum", "hair dryer", "razor",
+		"toothbrush", "vitamin", "supplement", "health", "personal care",
+	}},
+	{Slug: "sports", Name: "Sports & Outdoors", keywords: []string{
+		"sports", "sport", "outdoor", "outdoors", "fitness", "exercise", "yoga", "bike",
+		"bicycle", "camping", "tent", "hiking", "golf", "tennis", "basketball", "football",
+		"soccer", "treadmill", "dumbbell", "fishing",
+	}},
+	{Slug: "toys", Name: "Toys & Games", keywords: []string{
+		"toy", "toys", "lego", "doll", "puzzle", "board game", "action figure", "plush",
+		"kids", "baby", "stroller",
+	}},
+	{Slug: "books", Name: "Books & Media", keywords: []string{
+		"book", "books", "novel", "paperback", "hardcover", "audiobook", "textbook", "dvd",
+		"blu-ray", "vinyl", "cd", "magazine",
+	}},
+	{Slug: "grocery", Name: "Grocery", keywords: []string{
+		"grocery", "groceries", "food", "snack", "coffee", "tea", "juice", "fruit",
+		"pie", "cereal", "chocolate", "candy", "sauce", "spice", "pantry", "beverage", "drink",
+		"water", "organic",
+	}},
+	{Slug: "automotive", Name: "Automotive", keywords: []string{
+		"automotive", "car", "auto", "vehicle", "tire", "motor oil", "dash cam", "truck",
+		"motorcycle", "car seat",
+	}},
+}
+
+// index maps each slug to its position in taxonomy.
+var index = func() map[string]int {
+	m := make(map[string]int, len(taxonomy))
+	for i, c := range taxonomy {
+		m[c.Slug] = i
+	}
+	return m
+}()
+
+// Tree returns the top-level categories with their subcategories.
+func Tree() []Category {
+	var tree []Category
+	for _, c := range taxonomy {
+		if c.Parent == "" {
+			c.Children = nil
+			tree = append(tree, c)
+		}
+	}
+	for _, c := range taxonomy {
+		if c.Parent == "" {
+			continue
+		}
+		for i := range tree {
+			if tree[i].Slug == c.Parent {
+				tree[i].Children = append(tree[i].Children, c)
+			}
+		}
+	}
+	return tree
+}
+
+// Slugs lists every category slug, top-level ones with their subcategories
+// following them.
+func Slugs() []string {
+	slugs := make([]string, len(taxonomy))
+	for i, c := range taxonomy {
+		slugs[i] = c.Slug
+	}
+	return slugs
+}
+
+// Lookup returns the category with slug.
+func Lookup(slug string) (Category, bool) {
+	i, ok := index[strings.ToLower(slug)]
+	if !ok {
+		return Category{}, false
+	}
+	return taxonomy[i], true
+}
+
+// Within reports whether category is filter or one of its subcategories,
+// so filtering on "electronics" keeps phones too.
+func Within(category, filter string) bool {
+	if category == "" {
+		return false
+	}
+	filter = strings.ToLower(filter)
+	if category == filter {
+		return true
+	}
+	c, ok := Lookup(category)
+	return ok && c.Parent == filter
+}
+
+// Detect returns the slug of the category hints point at, or "" when none
+// do. Hints are tried in order and the first one that matches anything
+// decides, so callers pass breadcrumbs and category paths before the
+// product name. Within a hint, a subcategory counts its parent's matches
+// too, which lets "Electronics > Cell Phones" land on phones.
+func Detect(hints ...string) string {
+	for _, hint := range hints {
+		text := normalize(hint)
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		scores := make([]int, len(taxonomy))
+		for i, c := range taxonomy {
+			for _, kw := range c.keywords {
+				if matches(text, kw) {
+					scores[i]++
+				}
+			}
+		}
+
+		best, bestScore := "", 0
+		for i, c := range taxonomy {
+			score := scores[i]
+			if score == 0 {
+				continue
+			}
+			if c.Parent != "" {
+				score += scores[index[c.Parent]]
+			}
+			// Ties go to the subcategory, which is listed after its parent
+			if score > bestScore || score == bestScore && c.Parent != "" {
+				best, bestScore = c.Slug, score
+			}
+		}
+		if best != "" {
+			return best
+		}
+	}
+	return ""
+}
+
+// normalize lower-cases text and turns everything but letters, digits and
+// hyphens into single spaces, padding the result so whole words can be
+// matched as " word ".
+func normalize(text string) string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-'
+	})
+	return " " + strings.Join(fields, " ") + " "
+}
+
+// matches reports whether the normalized text holds kw as whole words, in
+// the singular or with a plural ending.
+func matches(text, kw string) bool {
+	for _, form := range []string{kw, kw + "s", kw + "es"} {
+		if strings.Contains(text, " "+form+" ") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,673 @@
+package competitors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Mapping statuses
+const (
+	MappingPending = "pending" // waiting for the merchant to pick a SKU
+	MappingLinked  = "linked"  // linked to an existing SKU
+	MappingCreated = "created" // imported as a new SKU
+	MappingIgnored = "ignored" // never imported
+)
+
+// Mapping actions
+const (
+	ActionLink   = "link"
+	ActionCreate = "create"
+	ActionIgnore = "ignore"
+)
+
+// nameMatchThreshold is the share of words two names must have in common for
+// an existing SKU to be offered as a candidate for an imported product.
+const nameMatchThreshold = 0.6
+
+// Integration imports a merchant's products from their Shopify or
+// WooCommerce store as SKUs and keeps their prices in sync.
+type Integration struct {
+	ID       string `json:"id"`
+	Owner    string `json:"owner"`
+	Platform string `json:"platform"`
+	// StoreURL is the shop's base URL, e.g. https://shop.myshopify.com
+	StoreURL string `json:"store_url"`
+	// AccessToken is a Shopify Admin API token; ConsumerKey and
+	// ConsumerSecret are WooCommerce REST API keys
+	AccessToken    string `json:"access_token,omitempty"`
+	ConsumerKey    string `json:"consumer_key,omitempty"`
+	ConsumerSecret string `json:"consumer_secret,omitempty"`
+	// Country, Currency and ExcludeSellers are given to every imported SKU
+	Country        string   `json:"country"`
+	Currency       string   `json:"currency,omitempty"`
+	ExcludeSellers []string `json:"exclude_sellers,omitempty"`
+
+	LastSync   *time.Time  `json:"last_sync,omitempty"`
+	LastError  string      `json:"last_error,omitempty"`
+	LastResult *SyncResult `json:"last_result,omitempty"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
+
+// IntegrationInput is what a merchant sends to connect a store.
+type IntegrationInput struct {
+	Platform       string   `json:"platform" binding:"required"`
+	StoreURL       string   `json:"store_url" binding:"required"`
+	AccessToken    string   `json:"access_token"`
+	ConsumerKey    string   `json:"consumer_key"`
+	ConsumerSecret string   `json:"consumer_secret"`
+	Country        string   `json:"country"`
+	Currency       string   `json:"currency"`
+	ExcludeSellers []string `json:"exclude_sellers"`
+}
+
+// SyncResult counts what one sync did with the store's products.
+type SyncResult struct {
+	Fetched int `json:"fetched"`
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Linked  int `json:"linked"`
+	Pending int `json:"pending"`
+	Skipped int `json:"skipped"`
+}
+
+// Candidate is an existing SKU an imported product might be.
+type Candidate struct {
+	SKUID  string `json:"sku_id"`
+	SKU    string `json:"sku,omitempty"`
+	Name   string `json:"name"`
+	Reason string `json:"reason"` // sku, identifier or name
+}
+
+// Mapping records how an imported product was matched to a SKU, and holds
+// the ambiguous ones for review.
+type Mapping struct {
+	ID            string          `json:"id"`
+	Owner         string          `json:"owner"`
+	IntegrationID string          `json:"integration_id"`
+	Product       ExternalProduct `json:"product"`
+	Status        string          `json:"status"`
+	Candidates    []Candidate     `json:"candidates,omitempty"`
+	SKUID         string          `json:"sku_id,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	ResolvedAt    *time.Time      `json:"resolved_at,omitempty"`
+}
+
+// MappingDecision resolves a pending mapping.
+type MappingDecision struct {
+	Action string `json:"action" binding:"required"`
+	SKUID  string `json:"sku_id"`
+}
+
+// importKey ties a SKU to the store product it was imported from.
+func importKey(integrationID, productID string) string {
+	return integrationID + ":" + productID
+}
+
+func (in *IntegrationInput) validate(defaultCountry string) error {
+	in.Platform = strings.ToLower(strings.TrimSpace(in.Platform))
+	switch in.Platform {
+	case PlatformShopify:
+		if in.AccessToken == "" {
+			return fmt.Errorf("%w: access_token is required for shopify", ErrInvalid)
+		}
+	case PlatformWooCommerce:
+		if in.ConsumerKey == "" || in.ConsumerSecret == "" {
+			return fmt.Errorf("%w: consumer_key and consumer_secret are required for woocommerce", ErrInvalid)
+		}
+	default:
+		return fmt.Errorf("%w: platform must be %s or %s", ErrInvalid, PlatformShopify, PlatformWooCommerce)
+	}
+
+	storeURL := strings.TrimRight(strings.TrimSpace(in.StoreURL), "/")
+	if !strings.Contains(storeURL, "://") {
+		storeURL = "https://" + storeURL
+	}
+	u, err := url.Parse(storeURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("%w: store_url must be an https URL", ErrInvalid)
+	}
+	in.StoreURL = storeURL
+
+	in.Country = strings.ToUpper(strings.TrimSpace(in.Country))
+	if in.Country == "" {
+		in.Country = defaultCountry
+	}
+	in.Currency = strings.ToUpper(strings.TrimSpace(in.Currency))
+	return nil
+}
+
+// Public returns the integration without its credentials.
+func (in Integration) Public() Integration {
+	in.AccessToken, in.ConsumerKey, in.ConsumerSecret = "", "", ""
+	return in
+}
+
+// input builds the SKU details of an imported product.
+func (in *Integration) input(p ExternalProduct) Input {
+	sku := Input{
+		SKU:            p.SKU,
+		Name:           p.Name,
+		Country:        in.Country,
+		Price:          p.Price,
+		Currency:       p.Currency,
+		ExcludeSellers: in.ExcludeSellers,
+	}
+	if p.Barcode != "" {
+		sku.Identifiers = map[string]string{"gtin": p.Barcode}
+	}
+	return sku
+}
+
+// candidates returns the owner's unlinked SKUs that p might be: an exact
+// SKU code or barcode match, or failing that a similar name.
+func candidates(skus map[string]*SKU, owner string, p ExternalProduct) (exact, similar []Candidate) {
+	for _, s := range skus {
+		if s.Owner != owner || s.ImportID != "" {
+			continue
+		}
+		c := Candidate{SKUID: s.ID, SKU: s.SKU, Name: s.Name}
+		switch {
+		case p.SKU != "" && strings.EqualFold(s.SKU, p.SKU):
+			c.Reason = "sku"
+			exact = append(exact, c)
+		case p.Barcode != "" && hasIdentifier(s, p.Barcode):
+			c.Reason = "identifier"
+			exact = append(exact, c)
+		case nameSimilarity(s.Name, p.Name) >= nameMatchThreshold:
+			c.Reason = "name"
+			similar = append(similar, c)
+		}
+	}
+	byName := func(list []Candidate) {
+		sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	}
+	byName(exact)
+	byName(similar)
+	return exact, similar
+}
+
+func hasIdentifier(s *SKU, value string) bool {
+	for _, id := range s.Identifiers {
+		if strings.EqualFold(id, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// nameSimilarity is the share of distinct words the two names have in common.
+func nameSimilarity(a, b string) float64 {
+	words := func(s string) map[string]bool {
+		set := make(map[string]bool)
+		for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+		}) {
+			set[w] = true
+		}
+		return set
+	}
+	wa, wb := words(a), words(b)
+	if len(wa) == 0 || len(wb) == 0 {
+		return 0
+	}
+	common := 0
+	for w := range wa {
+		if wb[w] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(wa)+len(wb)-common)
+}
+
+// AddIntegration connects a store for owner. Its products are imported in
+// the background.
+func (m *Monitor) AddIntegration(owner string, input IntegrationInput) (*Integration, error) {
+	if err := input.validate(m.defaultCountry); err != nil {
+		return nil, err
+	}
+	in := &Integration{
+		ID:             newID(),
+		Owner:          owner,
+		Platform:       input.Platform,
+		StoreURL:       input.StoreURL,
+		AccessToken:    input.AccessToken,
+		ConsumerKey:    input.ConsumerKey,
+		ConsumerSecret: input.ConsumerSecret,
+		Country:        input.Country,
+		Currency:       input.Currency,
+		ExcludeSellers: input.ExcludeSellers,
+		CreatedAt:      time.Now(),
+	}
+
+	m.mu.Lock()
+	err := m.saveRecord(integrationsBucket, in.ID, in)
+	if err == nil {
+		m.integrations[in.ID] = in
+	}
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), syncTimeout)
+		defer cancel()
+		if _, err := m.sync(ctx, in.ID); err != nil {
+			log.Warn().Err(err).Str("integration_id", in.ID).Msg("Initial product import failed")
+		}
+	}()
+	public := in.Public()
+	return &public, nil
+}
+
+func (m *Monitor) Integration(owner, id string) (*Integration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	in, ok := m.integrations[id]
+	if !ok || in.Owner != owner {
+		return nil, ErrIntegrationNotFound
+	}
+	public := in.Public()
+	return &public, nil
+}
+
+// Integrations returns the owner's stores, oldest first.
+func (m *Monitor) Integrations(owner string) []Integration {
+	m.mu.Lock()
+	list := []Integration{}
+	for _, in := range m.integrations {
+		if in.Owner == owner {
+			list = append(list, in.Public())
+		}
+	}
+	m.mu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list
+}
+
+// DeleteIntegration disconnects a store. Its SKUs are kept but no longer
+// synced; its mappings are dropped.
+func (m *Monitor) DeleteIntegration(owner, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	in, ok := m.integrations[id]
+	if !ok || in.Owner != owner {
+		return ErrIntegrationNotFound
+	}
+
+	prefix := id + ":"
+	var unlinked []*SKU
+	for _, s := range m.skus {
+		if strings.HasPrefix(s.ImportID, prefix) {
+			updated := *s
+			updated.ImportID = ""
+			unlinked = append(unlinked, &updated)
+		}
+	}
+	err := m.db.Update(func(tx *bolt.Tx) error {
+		for _, s := range unlinked {
+			raw, err := json.Marshal(s)
+			if err != nil {
+				return err
+			}
+			if err := tx.Bucket(skusBucket).Put([]byte(s.ID), raw); err != nil {
+				return err
+			}
+		}
+		for mid, mapping := range m.mappings {
+			if mapping.IntegrationID == id {
+				if err := tx.Bucket(mappingsBucket).Delete([]byte(mid)); err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Bucket(integrationsBucket).Delete([]byte(id))
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, s := range unlinked {
+		m.skus[s.ID] = s
+	}
+	for mid, mapping := range m.mappings {
+		if mapping.IntegrationID == id {
+			delete(m.mappings, mid)
+		}
+	}
+	delete(m.integrations, id)
+	return nil
+}
+
+// Sync imports the store's products now.
+func (m *Monitor) Sync(ctx context.Context, owner, id string) (*SyncResult, error) {
+	if _, err := m.Integration(owner, id); err != nil {
+		return nil, err
+	}
+	return m.sync(ctx, id)
+}
+
+// Mappings returns the integration's mappings, optionally with one status,
+// newest first.
+func (m *Monitor) Mappings(owner, integrationID, status string) ([]Mapping, error) {
+	if _, err := m.Integration(owner, integrationID); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	list := []Mapping{}
+	for _, mapping := range m.mappings {
+		if mapping.IntegrationID == integrationID && (status == "" || mapping.Status == status) {
+			list = append(list, *mapping)
+		}
+	}
+	m.mu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list, nil
+}
+
+// ResolveMapping links a pending product to one of the owner's SKUs, imports
+// it as a new SKU or ignores it. The decision sticks across syncs.
+func (m *Monitor) ResolveMapping(owner, integrationID, id string, decision MappingDecision) (*Mapping, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	in, ok := m.integrations[integrationID]
+	if !ok || in.Owner != owner {
+		return nil, ErrIntegrationNotFound
+	}
+	mapping, ok := m.mappings[id]
+	if !ok || mapping.IntegrationID != integrationID {
+		return nil, ErrMappingNotFound
+	}
+	if mapping.Status != MappingPending {
+		return nil, fmt.Errorf("%w: mapping is already %s", ErrInvalid, mapping.Status)
+	}
+
+	resolved := *mapping
+	now := time.Now()
+	resolved.ResolvedAt = &now
+	key := importKey(integrationID, mapping.Product.ID)
+
+	switch strings.ToLower(decision.Action) {
+	case ActionLink:
+		s, ok := m.skus[decision.SKUID]
+		if !ok || s.Owner != owner {
+			return nil, fmt.Errorf("%w: sku_id %q not found", ErrInvalid, decision.SKUID)
+		}
+		if s.ImportID != "" {
+			return nil, fmt.Errorf("%w: sku %s is already linked to an imported product", ErrInvalid, s.ID)
+		}
+		if err := m.syncSKU(s, key, mapping.Product); err != nil {
+			return nil, err
+		}
+		resolved.Status, resolved.SKUID = MappingLinked, s.ID
+	case ActionCreate:
+		s, err := m.importSKU(in, key, mapping.Product)
+		if err != nil {
+			return nil, err
+		}
+		resolved.Status, resolved.SKUID = MappingCreated, s.ID
+	case ActionIgnore:
+		resolved.Status = MappingIgnored
+	default:
+		return nil, fmt.Errorf("%w: action must be %s, %s or %s", ErrInvalid, ActionLink, ActionCreate, ActionIgnore)
+	}
+	resolved.Candidates = nil
+
+	if err := m.saveRecord(mappingsBucket, resolved.ID, &resolved); err != nil {
+		return nil, err
+	}
+	m.mappings[id] = &resolved
+	copied := resolved
+	return &copied, nil
+}
+
+// runImports re-syncs every store not synced within the import interval.
+func (m *Monitor) runImports() {
+	now := time.Now()
+	var due []string
+	m.mu.Lock()
+	for id, in := range m.integrations {
+		if in.LastSync == nil || now.Sub(*in.LastSync) >= m.importInterval {
+			due = append(due, id)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, id := range due {
+		select {
+		case <-m.stop:
+			return
+		default:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), syncTimeout)
+		if _, err := m.sync(ctx, id); err != nil {
+			log.Warn().Err(err).Str("integration_id", id).Msg("Product import failed")
+		}
+		cancel()
+	}
+}
+
+func (m *Monitor) sync(ctx context.Context, id string) (*SyncResult, error) {
+	m.mu.Lock()
+	in, ok := m.integrations[id]
+	if !ok {
+		m.mu.Unlock()
+		return nil, ErrIntegrationNotFound
+	}
+	if m.checking["integration:"+id] {
+		m.mu.Unlock()
+		return nil, ErrSyncing
+	}
+	m.checking["integration:"+id] = true
+	snapshot := *in
+	m.mu.Unlock()
+
+	products, fetchErr := fetchProducts(ctx, m.importClient, &snapshot, m.importMax)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.checking, "integration:"+id)
+	if in, ok = m.integrations[id]; !ok {
+		return nil, ErrIntegrationNotFound
+	}
+
+	result := &SyncResult{Fetched: len(products)}
+	if fetchErr == nil {
+		for _, p := range products {
+			if err := m.importProduct(in, p, result); err != nil {
+				log.Warn().Err(err).Str("integration_id", id).Msgf("Failed to import product %s", p.ID)
+				result.Skipped++
+			}
+		}
+	}
+
+	updated := *in
+	now := time.Now()
+	updated.LastSync = &now
+	updated.LastError = ""
+	updated.LastResult = result
+	if fetchErr != nil {
+		updated.LastError = fetchErr.Error()
+		updated.LastResult = in.LastResult
+	}
+	if err := m.saveRecord(integrationsBucket, id, &updated); err != nil {
+		log.Warn().Err(err).Str("integration_id", id).Msg("Failed to save integration")
+	}
+	m.integrations[id] = &updated
+	if fetchErr != nil {
+		return nil, fmt.Errorf("%w: %v", ErrStoreUnavailable, fetchErr)
+	}
+	log.Info().Str("integration_id", id).Msgf("Imported %d products: %d created, %d updated, %d linked, %d pending",
+		result.Fetched, result.Created, result.Updated, result.Linked, result.Pending)
+	return result, nil
+}
+
+// importProduct matches one store product to a SKU. Caller holds mu.
+func (m *Monitor) importProduct(in *Integration, p ExternalProduct, result *SyncResult) error {
+	if p.Price <= 0 || strings.TrimSpace(p.Name) == "" {
+		result.Skipped++
+		return nil
+	}
+	key := importKey(in.ID, p.ID)
+
+	for _, s := range m.skus {
+		if s.ImportID == key {
+			if err := m.syncSKU(s, key, p); err != nil {
+				return err
+			}
+			result.Updated++
+			return nil
+		}
+	}
+
+	var existing *Mapping
+	for _, mapping := range m.mappings {
+		if mapping.IntegrationID == in.ID && mapping.Product.ID == p.ID {
+			existing = mapping
+			break
+		}
+	}
+	if existing != nil && existing.Status != MappingPending {
+		// Ignored, or its SKU was deleted since; either way the merchant
+		// doesn't want it monitored
+		result.Skipped++
+		return nil
+	}
+
+	exact, similar := candidates(m.skus, in.Owner, p)
+	if existing == nil && len(exact) == 1 {
+		s := m.skus[exact[0].SKUID]
+		if err := m.syncSKU(s, key, p); err != nil {
+			return err
+		}
+		result.Linked++
+		now := time.Now()
+		return m.putMapping(&Mapping{ID: newID(), Owner: in.Owner, IntegrationID: in.ID, Product: p,
+			Status: MappingLinked, SKUID: s.ID, CreatedAt: now, ResolvedAt: &now})
+	}
+	if existing == nil && len(exact) == 0 && len(similar) == 0 {
+		s, err := m.importSKU(in, key, p)
+		if err != nil {
+			return err
+		}
+		result.Created++
+		now := time.Now()
+		return m.putMapping(&Mapping{ID: newID(), Owner: in.Owner, IntegrationID: in.ID, Product: p,
+			Status: MappingCreated, SKUID: s.ID, CreatedAt: now, ResolvedAt: &now})
+	}
+
+	// Several SKUs could be this product: keep it for review
+	result.Pending++
+	mapping := &Mapping{ID: newID(), Owner: in.Owner, IntegrationID: in.ID, Status: MappingPending, CreatedAt: time.Now()}
+	if existing != nil {
+		copied := *existing
+		mapping = &copied
+	}
+	mapping.Product = p
+	mapping.Candidates = append(exact, similar...)
+	return m.putMapping(mapping)
+}
+
+// importSKU creates a SKU for a store product. Caller holds mu.
+func (m *Monitor) importSKU(in *Integration, key string, p ExternalProduct) (*SKU, error) {
+	input := in.input(p)
+	if err := input.validate(m.defaultCountry); err != nil {
+		return nil, err
+	}
+	s := &SKU{ID: newID(), Owner: in.Owner, ImportID: key, Competitors: []CompetitorPrice{}, CreatedAt: time.Now()}
+	s.apply(input)
+	m.reprice(s, s.CreatedAt)
+	if err := m.save(s); err != nil {
+		return nil, err
+	}
+	m.skus[s.ID] = s
+	return s, nil
+}
+
+// syncSKU links a SKU to a store product and takes its price, name and SKU
+// code from the store; a query left at its default follows the name.
+// Caller holds mu.
+func (m *Monitor) syncSKU(s *SKU, key string, p ExternalProduct) error {
+	updated := *s
+	updated.ImportID = key
+	if updated.Query == updated.Name {
+		updated.Query = p.Name
+	}
+	updated.Name = p.Name
+	updated.Price = p.Price
+	if p.SKU != "" {
+		updated.SKU = p.SKU
+	}
+	if p.Currency != "" {
+		updated.Currency = p.Currency
+	}
+	if p.Barcode != "" && !hasIdentifier(&updated, p.Barcode) {
+		identifiers := make(map[string]string, len(s.Identifiers)+1)
+		for k, v := range s.Identifiers {
+			identifiers[k] = v
+		}
+		identifiers["gtin"] = p.Barcode
+		updated.Identifiers = identifiers
+	}
+	if updated.Query != s.Query {
+		updated.Competitors = []CompetitorPrice{}
+		updated.LastChecked = nil
+	}
+	updated.rescore()
+	m.reprice(&updated, time.Now())
+	if err := m.save(&updated); err != nil {
+		return err
+	}
+	m.skus[s.ID] = &updated
+	return nil
+}
+
+// putMapping stores a mapping. Caller holds mu.
+func (m *Monitor) putMapping(mapping *Mapping) error {
+	if err := m.saveRecord(mappingsBucket, mapping.ID, mapping); err != nil {
+		return err
+	}
+	m.mappings[mapping.ID] = mapping
+	return nil
+}
+
+// ignoreImport remembers that an imported SKU was deleted so the next sync
+// doesn't bring it back. Caller holds mu.
+func (m *Monitor) ignoreImport(s *SKU) {
+	integrationID, productID, ok := strings.Cut(s.ImportID, ":")
+	if !ok {
+		return
+	}
+	for _, mapping := range m.mappings {
+		if mapping.IntegrationID == integrationID && mapping.Product.ID == productID {
+			updated := *mapping
+			now := time.Now()
+			updated.Status, updated.SKUID, updated.ResolvedAt = MappingIgnored, "", &now
+			if err := m.putMapping(&updated); err != nil {
+				log.Warn().Err(err).Str("sku_id", s.ID).Msg("Failed to save mapping")
+			}
+			return
+		}
+	}
+}
+
+// saveRecord persists v under id in bucket. Caller holds mu.
+func (m *Monitor) saveRecord(bucket []byte, id string, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return m.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).Put([]byte(id), raw)
+	})
+}
@@ -0,0 +1,549 @@
+package competitors
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	bolt "go.etcd.io/bbolt"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/history"
+	"price-comparison-api/internal/models"
+)
+
+var (
+	skusBucket         = []byte("skus")
+	eventsBucket       = []byte("events")
+	integrationsBucket = []byte("integrations")
+	mappingsBucket     = []byte("mappings")
+)
+
+// monitorTick is how often the monitor looks for SKUs that are due.
+const monitorTick = time.Minute
+
+// checkTimeout bounds the search for one SKU.
+const checkTimeout = 2 * time.Minute
+
+// syncTimeout bounds one product import; importRequestTimeout each request
+// to the store.
+const (
+	syncTimeout          = 5 * time.Minute
+	importRequestTimeout = 30 * time.Second
+)
+
+var (
+	// ErrNotFound is returned for unknown SKUs and for other callers' SKUs.
+	ErrNotFound = fmt.Errorf("sku not found")
+	// ErrInvalid wraps validation errors.
+	ErrInvalid = fmt.Errorf("invalid sku")
+	// ErrNoHistory is returned by Simulate when price history is disabled.
+	ErrNoHistory = fmt.Errorf("price history is disabled")
+	// ErrNoRule is returned by Simulate for a SKU without a repricing rule
+	// when none is given.
+	ErrNoRule = fmt.Errorf("sku has no repricing rule")
+	// ErrIntegrationNotFound and ErrMappingNotFound are returned for unknown
+	// integrations and mappings and for other callers' ones.
+	ErrIntegrationNotFound = fmt.Errorf("integration not found")
+	ErrMappingNotFound     = fmt.Errorf("mapping not found")
+	// ErrStoreUnavailable wraps failures to read products from a store.
+	ErrStoreUnavailable = fmt.Errorf("store unavailable")
+	// ErrSyncing is returned when a store's products are already being
+	// imported.
+	ErrSyncing = fmt.Errorf("products are already being imported")
+)
+
+// SearchFunc returns every listing found for a query in a country.
+type SearchFunc func(ctx context.Context, query, country string) ([]models.Product, error)
+
+// Monitor stores merchants' SKUs and searches each one every interval,
+// recording competitor prices and undercut events, and recommending prices
+// for SKUs with a repricing rule.
+type Monitor struct {
+	db             *bolt.DB
+	search         SearchFunc
+	history        *history.Store
+	client         *http.Client
+	importClient   *http.Client
+	importInterval time.Duration
+	importMax      int
+	interval       time.Duration
+	maxCompetitors int
+	maxEvents      int
+	defaultCountry string
+	stop           chan struct{}
+
+	mu           sync.Mutex
+	skus         map[string]*SKU
+	integrations map[string]*Integration
+	mappings     map[string]*Mapping
+	// checking holds the SKUs being searched and the stores being imported,
+	// so a manual run and the ticker don't do the same work twice
+	checking map[string]bool
+}
+
+// NewMonitor opens the SKU store. historyStore may be nil, which disables
+// repricing simulations.
+func NewMonitor(cfg config.CompetitorsConfig, defaultCountry string, search SearchFunc, historyStore *history.Store) (*Monitor, error) {
+	path := cfg.Path
+	if path == "" {
+		path = filepath.Join(os.TempDir(), "price-comparison-competitors.db")
+	}
+	maxEvents := cfg.MaxEvents
+	if maxEvents <= 0 {
+		maxEvents = 1000
+	}
+	timeout := cfg.WebhookTimeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	importMax := cfg.ImportMaxProducts
+	if importMax <= 0 {
+		importMax = 500
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create competitors directory: %v", err)
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open competitors store: %v", err)
+	}
+
+	m := &Monitor{
+		db:             db,
+		search:         search,
+		history:        historyStore,
+		client:         &http.Client{Timeout: timeout},
+		importClient:   &http.Client{Timeout: importRequestTimeout},
+		importInterval: cfg.ImportInterval,
+		importMax:      importMax,
+		interval:       cfg.Interval,
+		maxCompetitors: cfg.MaxCompetitors,
+		maxEvents:      maxEvents,
+		defaultCountry: defaultCountry,
+		stop:           make(chan struct{}),
+		skus:           make(map[string]*SKU),
+		integrations:   make(map[string]*Integration),
+		mappings:       make(map[string]*Mapping),
+		checking:       make(map[string]bool),
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(eventsBucket); err != nil {
+			return err
+		}
+		bucket, err := tx.CreateBucketIfNotExists(skusBucket)
+		if err != nil {
+			return err
+		}
+		err = bucket.ForEach(func(k, v []byte) error {
+			var s SKU
+			if err := json.Unmarshal(v, &s); err != nil {
+				log.Warn().Err(err).Msgf("Skipping unreadable SKU %s", k)
+				return nil
+			}
+			m.skus[s.ID] = &s
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if bucket, err = tx.CreateBucketIfNotExists(integrationsBucket); err != nil {
+			return err
+		}
+		err = bucket.ForEach(func(k, v []byte) error {
+			var in Integration
+			if err := json.Unmarshal(v, &in); err != nil {
+				log.Warn().Err(err).Msgf("Skipping unreadable integration %s", k)
+				return nil
+			}
+			m.integrations[in.ID] = &in
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if bucket, err = tx.CreateBucketIfNotExists(mappingsBucket); err != nil {
+			return err
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			var mapping Mapping
+			if err := json.Unmarshal(v, &mapping); err != nil {
+				log.Warn().Err(err).Msgf("Skipping unreadable mapping %s", k)
+				return nil
+			}
+			m.mappings[mapping.ID] = &mapping
+			return nil
+		})
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to init competitors store: %v", err)
+	}
+
+	log.Info().Msgf("Competitor monitoring ready at %s, %d SKUs loaded, interval: %s", path, len(m.skus), m.interval)
+	return m, nil
+}
+
+func (m *Monitor) Start() {
+	go func() {
+		ticker := time.NewTicker(monitorTick)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+				m.runImports()
+				m.runDue()
+			}
+		}
+	}()
+}
+
+func (m *Monitor) Close() error {
+	if m == nil {
+		return nil
+	}
+	close(m.stop)
+	return m.db.Close()
+}
+
+// Add registers a SKU for owner. It is checked on the next tick.
+func (m *Monitor) Add(owner string, in Input) (*SKU, error) {
+	if err := in.validate(m.defaultCountry); err != nil {
+		return nil, err
+	}
+	s := &SKU{ID: newID(), Owner: owner, Competitors: []CompetitorPrice{}, CreatedAt: time.Now()}
+	s.apply(in)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reprice(s, s.CreatedAt)
+	if err := m.save(s); err != nil {
+		return nil, err
+	}
+	m.skus[s.ID] = s
+	copied := *s
+	return &copied, nil
+}
+
+// Update replaces a SKU's details. Known competitors are kept and re-marked
+// against the new price; a changed query or country is searched on the next
+// tick.
+func (m *Monitor) Update(owner, id string, in Input) (*SKU, error) {
+	if err := in.validate(m.defaultCountry); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.skus[id]
+	if !ok || s.Owner != owner {
+		return nil, ErrNotFound
+	}
+	updated := *s
+	if in.Query != s.Query || in.Country != s.Country {
+		updated.Competitors = []CompetitorPrice{}
+		updated.LastChecked = nil
+	}
+	updated.apply(in)
+	updated.rescore()
+	m.reprice(&updated, time.Now())
+	if err := m.save(&updated); err != nil {
+		return nil, err
+	}
+	m.skus[id] = &updated
+	copied := updated
+	return &copied, nil
+}
+
+func (m *Monitor) Get(owner, id string) (*SKU, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.skus[id]
+	if !ok || s.Owner != owner {
+		return nil, ErrNotFound
+	}
+	copied := *s
+	return &copied, nil
+}
+
+// List returns the owner's SKUs, oldest first.
+func (m *Monitor) List(owner string) []SKU {
+	m.mu.Lock()
+	list := []SKU{}
+	for _, s := range m.skus {
+		if s.Owner == owner {
+			list = append(list, *s)
+		}
+	}
+	m.mu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list
+}
+
+func (m *Monitor) Delete(owner, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.skus[id]
+	if !ok || s.Owner != owner {
+		return ErrNotFound
+	}
+	err := m.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(skusBucket).Delete([]byte(id))
+	})
+	if err != nil {
+		return err
+	}
+	if s.ImportID != "" {
+		m.ignoreImport(s)
+	}
+	delete(m.skus, id)
+	return nil
+}
+
+// Check searches for one of the owner's SKUs now.
+func (m *Monitor) Check(ctx context.Context, owner, id string) (*SKU, error) {
+	if _, err := m.Get(owner, id); err != nil {
+		return nil, err
+	}
+	if err := m.check(ctx, id); err != nil {
+		return nil, err
+	}
+	return m.Get(owner, id)
+}
+
+// runDue checks every SKU not checked within the interval. Checks run one
+// at a time; the searches share the search cache, so SKUs with the same
+// query cost one scrape.
+func (m *Monitor) runDue() {
+	now := time.Now()
+	var due []string
+	m.mu.Lock()
+	for id, s := range m.skus {
+		if s.LastChecked == nil || now.Sub(*s.LastChecked) >= m.interval {
+			due = append(due, id)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, id := range due {
+		select {
+		case <-m.stop:
+			return
+		default:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+		if err := m.check(ctx, id); err != nil {
+			log.Warn().Err(err).Str("sku_id", id).Msg("Competitor check failed")
+		}
+		cancel()
+	}
+}
+
+func (m *Monitor) check(ctx context.Context, id string) error {
+	m.mu.Lock()
+	s, ok := m.skus[id]
+	if !ok {
+		m.mu.Unlock()
+		return ErrNotFound
+	}
+	if m.checking[id] {
+		m.mu.Unlock()
+		return nil
+	}
+	m.checking[id] = true
+	query, country := s.Query, s.Country
+	m.mu.Unlock()
+
+	products, searchErr := m.search(ctx, query, country)
+
+	m.mu.Lock()
+	delete(m.checking, id)
+	if s, ok = m.skus[id]; !ok {
+		m.mu.Unlock()
+		return ErrNotFound
+	}
+	now := time.Now()
+	var events []Event
+	if searchErr != nil {
+		s.LastChecked = &now
+		s.LastError = searchErr.Error()
+	} else {
+		s.LastError = ""
+		events = s.update(products, m.maxCompetitors, now)
+		m.reprice(s, now)
+	}
+	if err := m.save(s); err != nil {
+		log.Warn().Err(err).Str("sku_id", id).Msg("Failed to save SKU")
+	}
+	m.mu.Unlock()
+
+	for _, event := range events {
+		event.ID = fmt.Sprintf("%020d-%s-%s", event.At.UnixNano(), event.SKUID, newID())
+		if err := m.storeEvent(event); err != nil {
+			log.Warn().Err(err).Msg("Failed to store undercut event")
+		}
+		log.Info().Str("sku_id", event.SKUID).Msgf("Undercut by %s: %.2f < %.2f", event.Source, event.CompetitorPrice, event.OwnPrice)
+	}
+	return searchErr
+}
+
+// SetRule sets or, with a nil rule, removes a SKU's repricing rule and
+// returns the SKU with its new recommendation.
+func (m *Monitor) SetRule(owner, id string, rule *Rule) (*SKU, error) {
+	if rule != nil {
+		if err := rule.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.skus[id]
+	if !ok || s.Owner != owner {
+		return nil, ErrNotFound
+	}
+	updated := *s
+	updated.Repricing = rule
+	m.reprice(&updated, time.Now())
+	if err := m.save(&updated); err != nil {
+		return nil, err
+	}
+	m.skus[id] = &updated
+	copied := updated
+	return &copied, nil
+}
+
+// Recommendations returns the latest recommendation of every owner's SKU
+// that has a repricing rule, oldest SKU first.
+func (m *Monitor) Recommendations(owner string) []Recommendation {
+	recs := []Recommendation{}
+	for _, s := range m.List(owner) {
+		if s.Recommendation != nil {
+			recs = append(recs, *s.Recommendation)
+		}
+	}
+	return recs
+}
+
+// Simulate replays rule, or the SKU's own rule when nil, over the price
+// history recorded since since, without changing the SKU.
+func (m *Monitor) Simulate(owner, id string, rule *Rule, since time.Time) (*Simulation, error) {
+	s, err := m.Get(owner, id)
+	if err != nil {
+		return nil, err
+	}
+	if rule == nil {
+		rule = s.Repricing
+	}
+	if rule == nil {
+		return nil, ErrNoRule
+	}
+	if err := rule.validate(); err != nil {
+		return nil, err
+	}
+	if m.history == nil {
+		return nil, ErrNoHistory
+	}
+	return s.simulate(m.history, *rule, since)
+}
+
+// reprice recomputes a SKU's recommendation and posts it to the rule's
+// webhook when the recommended price changed. Caller holds mu.
+func (m *Monitor) reprice(s *SKU, now time.Time) {
+	previous := s.Recommendation
+	s.Recommendation = s.recommend(now)
+	rec := s.Recommendation
+	if rec == nil || s.Repricing.WebhookURL == "" {
+		return
+	}
+	if previous != nil && previous.RecommendedPrice == rec.RecommendedPrice {
+		return
+	}
+	m.postRecommendation(s.Repricing.WebhookURL, *rec)
+}
+
+// save persists a SKU. Caller holds mu.
+func (m *Monitor) save(s *SKU) error {
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return m.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(skusBucket).Put([]byte(s.ID), raw)
+	})
+}
+
+func (m *Monitor) storeEvent(event Event) error {
+	raw, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return m.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(eventsBucket)
+		if err := bucket.Put([]byte(event.ID), raw); err != nil {
+			return err
+		}
+		count := 0
+		bucket.ForEach(func(k, v []byte) error {
+			count++
+			return nil
+		})
+
+		over := count - m.maxEvents
+		c := bucket.Cursor()
+		for k, _ := c.First(); k != nil && over > 0; k, _ = c.First() {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+			over--
+		}
+		return nil
+	})
+}
+
+// Events returns the owner's recent undercut events, newest first,
+// optionally for one SKU.
+func (m *Monitor) Events(owner, skuID string, limit int) ([]Event, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	events := []Event{}
+	err := m.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(eventsBucket).Cursor()
+		for k, v := c.Last(); k != nil && len(events) < limit; k, v = c.Prev() {
+			var event Event
+			if err := json.Unmarshal(v, &event); err != nil {
+				continue
+			}
+			if event.Owner != owner || (skuID != "" && event.SKUID != skuID) {
+				continue
+			}
+			events = append(events, event)
+		}
+		return nil
+	})
+	return events, err
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
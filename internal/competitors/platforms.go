@@ -0,0 +1,176 @@
+package competitors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Store platforms products can be imported from
+const (
+	PlatformShopify     = "shopify"
+	PlatformWooCommerce = "woocommerce"
+)
+
+// shopifyAPIVersion is the Admin API version products are read from.
+const shopifyAPIVersion = "2024-04"
+
+// ExternalProduct is a product, or one variant of it, read from a store.
+type ExternalProduct struct {
+	ID       string  `json:"id"`
+	SKU      string  `json:"sku,omitempty"`
+	Name     string  `json:"name"`
+	Brand    string  `json:"brand,omitempty"`
+	Barcode  string  `json:"barcode,omitempty"`
+	Price    float64 `json:"price"`
+	Currency string  `json:"currency,omitempty"`
+}
+
+// Shopify's Link header: <https://...page_info=abc>; rel="next"
+var nextLinkPattern = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// fetchProducts reads up to max products from the integration's store.
+func fetchProducts(ctx context.Context, client *http.Client, in *Integration, max int) ([]ExternalProduct, error) {
+	switch in.Platform {
+	case PlatformShopify:
+		return fetchShopify(ctx, client, in, max)
+	case PlatformWooCommerce:
+		return fetchWooCommerce(ctx, client, in, max)
+	default:
+		return nil, fmt.Errorf("unsupported platform: %s", in.Platform)
+	}
+}
+
+// fetchShopify reads every variant from the Admin API, following the Link
+// header from page to page. Variants become separate products since each
+// has its own SKU, barcode and price.
+func fetchShopify(ctx context.Context, client *http.Client, in *Integration, max int) ([]ExternalProduct, error) {
+	var page struct {
+		Products []struct {
+			ID       int64  `json:"id"`
+			Title    string `json:"title"`
+			Vendor   string `json:"vendor"`
+			Status   string `json:"status"`
+			Variants []struct {
+				ID      int64  `json:"id"`
+				Title   string `json:"title"`
+				SKU     string `json:"sku"`
+				Price   string `json:"price"`
+				Barcode string `json:"barcode"`
+			} `json:"variants"`
+		} `json:"products"`
+	}
+
+	var products []ExternalProduct
+	next := fmt.Sprintf("%s/admin/api/%s/products.json?limit=250&fields=id,title,vendor,status,variants", in.StoreURL, shopifyAPIVersion)
+	for next != "" && len(products) < max {
+		resp, err := getJSON(ctx, client, next, func(req *http.Request) {
+			req.Header.Set("X-Shopify-Access-Token", in.AccessToken)
+		}, &page)
+		if err != nil {
+			return nil, fmt.Errorf("shopify: %v", err)
+		}
+		for _, p := range page.Products {
+			if p.Status != "" && p.Status != "active" {
+				continue
+			}
+			for _, v := range p.Variants {
+				name := p.Title
+				if v.Title != "" && v.Title != "Default Title" {
+					name += " - " + v.Title
+				}
+				price, _ := strconv.ParseFloat(v.Price, 64)
+				products = append(products, ExternalProduct{
+					ID:       strconv.FormatInt(v.ID, 10),
+					SKU:      strings.TrimSpace(v.SKU),
+					Name:     name,
+					Brand:    p.Vendor,
+					Barcode:  strings.TrimSpace(v.Barcode),
+					Price:    price,
+					Currency: in.Currency,
+				})
+			}
+		}
+		next = ""
+		if m := nextLinkPattern.FindStringSubmatch(resp.Header.Get("Link")); m != nil {
+			next = m[1]
+		}
+	}
+	if len(products) > max {
+		products = products[:max]
+	}
+	return products, nil
+}
+
+// fetchWooCommerce reads published products from the REST API page by page.
+// Variable products are imported at their parent price.
+func fetchWooCommerce(ctx context.Context, client *http.Client, in *Integration, max int) ([]ExternalProduct, error) {
+	var products []ExternalProduct
+	for page := 1; len(products) < max; page++ {
+		var items []struct {
+			ID             int64  `json:"id"`
+			Name           string `json:"name"`
+			SKU            string `json:"sku"`
+			Price          string `json:"price"`
+			GlobalUniqueID string `json:"global_unique_id"`
+			Brands         []struct {
+				Name string `json:"name"`
+			} `json:"brands"`
+		}
+		pageURL := fmt.Sprintf("%s/wp-json/wc/v3/products?status=publish&per_page=100&page=%d", in.StoreURL, page)
+		resp, err := getJSON(ctx, client, pageURL, func(req *http.Request) {
+			req.SetBasicAuth(in.ConsumerKey, in.ConsumerSecret)
+		}, &items)
+		if err != nil {
+			return nil, fmt.Errorf("woocommerce: %v", err)
+		}
+		for _, item := range items {
+			price, _ := strconv.ParseFloat(item.Price, 64)
+			p := ExternalProduct{
+				ID:       strconv.FormatInt(item.ID, 10),
+				SKU:      strings.TrimSpace(item.SKU),
+				Name:     item.Name,
+				Barcode:  strings.TrimSpace(item.GlobalUniqueID),
+				Price:    price,
+				Currency: in.Currency,
+			}
+			if len(item.Brands) > 0 {
+				p.Brand = item.Brands[0].Name
+			}
+			products = append(products, p)
+		}
+		totalPages, _ := strconv.Atoi(resp.Header.Get("X-WP-TotalPages"))
+		if len(items) == 0 || page >= totalPages {
+			break
+		}
+	}
+	if len(products) > max {
+		products = products[:max]
+	}
+	return products, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, auth func(*http.Request), v interface{}) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	auth(req)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned status %d", req.URL.Host, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return nil, fmt.Errorf("invalid response: %v", err)
+	}
+	return resp, nil
+}
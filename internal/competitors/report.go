@@ -0,0 +1,137 @@
+package competitors
+
+import (
+	"encoding/csv"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"price-comparison-api/internal/models"
+)
+
+// ReportRow summarizes where one SKU stands against its competitors.
+type ReportRow struct {
+	SKUID    string  `json:"sku_id"`
+	SKU      string  `json:"sku,omitempty"`
+	Name     string  `json:"name"`
+	Country  string  `json:"country"`
+	OwnPrice float64 `json:"own_price"`
+	Currency string  `json:"currency,omitempty"`
+	// RecommendedPrice is set for SKUs with a repricing rule
+	RecommendedPrice float64 `json:"recommended_price,omitempty"`
+	// Position is the SKU's rank by price among itself and its comparable
+	// competitors; 1 is the cheapest
+	Position       int               `json:"position"`
+	Competitors    int               `json:"competitors"`
+	Undercutting   int               `json:"undercutting"`
+	LowestPrice    float64           `json:"lowest_price,omitempty"`
+	LowestSource   string            `json:"lowest_source,omitempty"`
+	LowestURL      string            `json:"lowest_url,omitempty"`
+	GapToLowest    float64           `json:"gap_to_lowest"`
+	GapToLowestPct float64           `json:"gap_to_lowest_pct"`
+	Listings       []CompetitorPrice `json:"listings"`
+	LastChecked    *time.Time        `json:"last_checked,omitempty"`
+	LastError      string            `json:"last_error,omitempty"`
+}
+
+// Report builds a row per SKU.
+func Report(skus []SKU) []ReportRow {
+	rows := make([]ReportRow, 0, len(skus))
+	for _, s := range skus {
+		row := ReportRow{
+			SKUID:       s.ID,
+			SKU:         s.SKU,
+			Name:        s.Name,
+			Country:     s.Country,
+			OwnPrice:    s.Price,
+			Currency:    s.Currency,
+			Position:    1,
+			Competitors: len(s.Competitors),
+			Listings:    s.Competitors,
+			LastChecked: s.LastChecked,
+			LastError:   s.LastError,
+		}
+		if s.Recommendation != nil {
+			row.RecommendedPrice = s.Recommendation.RecommendedPrice
+		}
+		for _, c := range s.Competitors {
+			if !s.comparable(c) {
+				continue
+			}
+			if c.Undercuts {
+				row.Undercutting++
+				row.Position++
+			}
+			if row.LowestPrice == 0 || c.Price < row.LowestPrice {
+				row.LowestPrice, row.LowestSource, row.LowestURL = c.Price, c.Source, c.URL
+			}
+		}
+		if row.LowestPrice > 0 && row.LowestPrice < s.Price {
+			gap := s.Price - row.LowestPrice
+			row.GapToLowest = math.Round(gap*100) / 100
+			row.GapToLowestPct = math.Round(gap/s.Price*1000) / 10
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+var csvHeader = []string{
+	"sku_id", "sku", "name", "country", "own_price", "currency", "recommended_price", "position", "undercutting",
+	"competitor_source", "competitor_merchant", "competitor_name", "competitor_price", "competitor_currency",
+	"competitor_in_stock", "undercuts", "difference", "matched_by", "competitor_url",
+	"retailer", "retrieved_at", "terms_url", "attribution", "last_checked",
+}
+
+// WriteCSV writes one line per SKU and competitor listing; SKUs without
+// competitors get a line with the competitor columns empty. attribute fills
+// the attribution columns of each listing; they stay empty when it is nil.
+func WriteCSV(w io.Writer, rows []ReportRow, attribute func(source string, seenAt time.Time) models.Attribution) error {
+	out := csv.NewWriter(w)
+	if err := out.Write(csvHeader); err != nil {
+		return err
+	}
+	money := func(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) }
+	optional := func(v float64) string {
+		if v == 0 {
+			return ""
+		}
+		return money(v)
+	}
+	for _, row := range rows {
+		checked := ""
+		if row.LastChecked != nil {
+			checked = row.LastChecked.UTC().Format(time.RFC3339)
+		}
+		base := []string{
+			row.SKUID, row.SKU, row.Name, row.Country, money(row.OwnPrice), row.Currency, optional(row.RecommendedPrice),
+			strconv.Itoa(row.Position), strconv.Itoa(row.Undercutting),
+		}
+		if len(row.Listings) == 0 {
+			line := append(base, make([]string, len(csvHeader)-len(base)-1)...)
+			if err := out.Write(append(line, checked)); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, c := range row.Listings {
+			var attr models.Attribution
+			retrieved := ""
+			if attribute != nil {
+				attr = attribute(c.Source, c.SeenAt)
+				retrieved = attr.RetrievedAt.Format(time.RFC3339)
+			}
+			line := append(append([]string{}, base...),
+				c.Source, c.Merchant, c.Name, money(c.Price), c.Currency,
+				strconv.FormatBool(c.InStock), strconv.FormatBool(c.Undercuts), money(c.Price-row.OwnPrice),
+				c.MatchedBy, c.URL, attr.Retailer, retrieved, attr.TermsURL, attr.Text, checked,
+			)
+			if err := out.Write(line); err != nil {
+				return err
+			}
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
@@ -0,0 +1,282 @@
+package competitors
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"price-comparison-api/internal/history"
+	"price-comparison-api/internal/models"
+)
+
+// Recommendation reasons
+const (
+	ReasonBeatLowest    = "beat_lowest"    // priced under the lowest competitor
+	ReasonFloor         = "floor"          // beating the lowest would go below the floor
+	ReasonCeiling       = "ceiling"        // beating the lowest would go above the ceiling
+	ReasonNoCompetitors = "no_competitors" // nothing to beat; the ceiling or current price is kept
+)
+
+// Rule is how a SKU is repriced against its competitors: beat the lowest
+// competitor by BeatPct percent and then BeatAmount, never going below Floor
+// or above Ceiling.
+type Rule struct {
+	BeatPct    float64 `json:"beat_pct"`
+	BeatAmount float64 `json:"beat_amount,omitempty"`
+	Floor      float64 `json:"floor"`
+	// Ceiling is optional; it is also the price recommended when no
+	// competitor is found
+	Ceiling float64 `json:"ceiling,omitempty"`
+	// InStockOnly ignores competitors that are out of stock
+	InStockOnly bool `json:"in_stock_only,omitempty"`
+	// WebhookURL receives every new recommendation as JSON
+	WebhookURL string `json:"webhook_url,omitempty"`
+}
+
+// Recommendation is the price a SKU's rule suggests given its competitors.
+type Recommendation struct {
+	SKUID            string    `json:"sku_id"`
+	SKU              string    `json:"sku,omitempty"`
+	Name             string    `json:"name"`
+	Currency         string    `json:"currency,omitempty"`
+	CurrentPrice     float64   `json:"current_price"`
+	RecommendedPrice float64   `json:"recommended_price"`
+	Change           float64   `json:"change"`
+	ChangePct        float64   `json:"change_pct"`
+	Reason           string    `json:"reason"`
+	LowestPrice      float64   `json:"lowest_price,omitempty"`
+	LowestSource     string    `json:"lowest_source,omitempty"`
+	LowestURL        string    `json:"lowest_url,omitempty"`
+	At               time.Time `json:"at"`
+}
+
+// SimulationStep is a point in the simulated history where the recommended
+// price changed.
+type SimulationStep struct {
+	At               time.Time `json:"at"`
+	LowestPrice      float64   `json:"lowest_price,omitempty"`
+	LowestSource     string    `json:"lowest_source,omitempty"`
+	Competitors      int       `json:"competitors"`
+	RecommendedPrice float64   `json:"recommended_price"`
+	Reason           string    `json:"reason"`
+}
+
+// Simulation replays a rule over the recorded prices of a SKU's competitors.
+type Simulation struct {
+	SKUID        string           `json:"sku_id"`
+	Rule         Rule             `json:"rule"`
+	Since        time.Time        `json:"since"`
+	CurrentPrice float64          `json:"current_price"`
+	Listings     int              `json:"listings"`
+	Observations int              `json:"observations"`
+	Changes      int              `json:"changes"`
+	MinPrice     float64          `json:"min_price"`
+	MaxPrice     float64          `json:"max_price"`
+	AveragePrice float64          `json:"average_price"`
+	AtFloor      int              `json:"at_floor"`
+	AtCeiling    int              `json:"at_ceiling"`
+	Steps        []SimulationStep `json:"steps"`
+}
+
+func (r *Rule) validate() error {
+	if r.Floor <= 0 {
+		return fmt.Errorf("%w: repricing floor must be positive", ErrInvalid)
+	}
+	if r.BeatPct < 0 || r.BeatPct >= 100 {
+		return fmt.Errorf("%w: repricing beat_pct must be between 0 and 100", ErrInvalid)
+	}
+	if r.BeatAmount < 0 {
+		return fmt.Errorf("%w: repricing beat_amount can't be negative", ErrInvalid)
+	}
+	if r.Ceiling != 0 && r.Ceiling < r.Floor {
+		return fmt.Errorf("%w: repricing ceiling must be at least the floor", ErrInvalid)
+	}
+	r.WebhookURL = strings.TrimSpace(r.WebhookURL)
+	if r.WebhookURL != "" {
+		u, err := url.Parse(r.WebhookURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("%w: repricing webhook_url must be an http(s) URL", ErrInvalid)
+		}
+	}
+	return nil
+}
+
+// price applies the rule to the lowest competitor price, 0 when there is
+// none, and returns the price with the reason for it.
+func (r *Rule) price(lowest, current float64) (float64, string) {
+	if lowest <= 0 {
+		if r.Ceiling > 0 {
+			return r.Ceiling, ReasonNoCompetitors
+		}
+		return math.Max(current, r.Floor), ReasonNoCompetitors
+	}
+	target := lowest*(1-r.BeatPct/100) - r.BeatAmount
+	// Round down so the result still beats the competitor
+	target = math.Floor(target*100+1e-6) / 100
+	switch {
+	case target < r.Floor:
+		return r.Floor, ReasonFloor
+	case r.Ceiling > 0 && target > r.Ceiling:
+		return r.Ceiling, ReasonCeiling
+	}
+	return target, ReasonBeatLowest
+}
+
+// lowest returns the cheapest competitor the rule considers.
+func (s *SKU) lowest(r *Rule) (CompetitorPrice, bool) {
+	var found CompetitorPrice
+	ok := false
+	for _, c := range s.Competitors {
+		if !s.comparable(c) || (r.InStockOnly && !c.InStock) {
+			continue
+		}
+		if !ok || c.Price < found.Price {
+			found, ok = c, true
+		}
+	}
+	return found, ok
+}
+
+// recommend applies the SKU's rule to its current competitors, or returns
+// nil when it has no rule.
+func (s *SKU) recommend(now time.Time) *Recommendation {
+	if s.Repricing == nil {
+		return nil
+	}
+	rec := &Recommendation{
+		SKUID:        s.ID,
+		SKU:          s.SKU,
+		Name:         s.Name,
+		Currency:     s.Currency,
+		CurrentPrice: s.Price,
+		At:           now,
+	}
+	lowest, ok := s.lowest(s.Repricing)
+	if ok {
+		rec.LowestPrice, rec.LowestSource, rec.LowestURL = lowest.Price, lowest.Source, lowest.URL
+	}
+	rec.RecommendedPrice, rec.Reason = s.Repricing.price(rec.LowestPrice, s.Price)
+	change := rec.RecommendedPrice - s.Price
+	rec.Change = math.Round(change*100) / 100
+	rec.ChangePct = math.Round(change/s.Price*1000) / 10
+	return rec
+}
+
+// simulate replays rule over the history of every listing recorded for the
+// SKU's country that matches it, recomputing the recommendation each time a
+// search recorded new prices.
+func (s *SKU) simulate(store *history.Store, rule Rule, since time.Time) (*Simulation, error) {
+	type observation struct {
+		key   string
+		point history.Point
+	}
+	sources := make(map[string]string)
+	var observations []observation
+	err := store.Each(func(e *history.Entry) bool {
+		if e.Country != s.Country {
+			return true
+		}
+		if s.match(models.Product{Name: e.Name, URL: e.URL, Source: e.Source}) == "" {
+			return true
+		}
+		if !s.comparable(CompetitorPrice{Currency: e.Currency}) {
+			return true
+		}
+		sources[e.Key] = e.Source
+		for _, p := range e.Points {
+			if !p.At.Before(since) && p.Price > 0 {
+				observations = append(observations, observation{key: e.Key, point: p})
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(observations, func(i, j int) bool { return observations[i].point.At.Before(observations[j].point.At) })
+
+	sim := &Simulation{
+		SKUID:        s.ID,
+		Rule:         rule,
+		Since:        since,
+		CurrentPrice: s.Price,
+		Listings:     len(sources),
+		Observations: len(observations),
+		Steps:        []SimulationStep{},
+	}
+
+	current := make(map[string]history.Point)
+	total, evaluated := 0.0, 0
+	for i := 0; i < len(observations); {
+		// Points recorded by one search share a timestamp
+		at := observations[i].point.At
+		for ; i < len(observations) && observations[i].point.At.Equal(at); i++ {
+			current[observations[i].key] = observations[i].point
+		}
+
+		step := SimulationStep{At: at}
+		for key, p := range current {
+			if rule.InStockOnly && !p.InStock {
+				continue
+			}
+			step.Competitors++
+			if step.LowestPrice == 0 || p.Price < step.LowestPrice {
+				step.LowestPrice, step.LowestSource = p.Price, sources[key]
+			}
+		}
+		step.RecommendedPrice, step.Reason = rule.price(step.LowestPrice, s.Price)
+
+		evaluated++
+		total += step.RecommendedPrice
+		switch step.Reason {
+		case ReasonFloor:
+			sim.AtFloor++
+		case ReasonCeiling:
+			sim.AtCeiling++
+		}
+		if sim.MinPrice == 0 || step.RecommendedPrice < sim.MinPrice {
+			sim.MinPrice = step.RecommendedPrice
+		}
+		if step.RecommendedPrice > sim.MaxPrice {
+			sim.MaxPrice = step.RecommendedPrice
+		}
+		if n := len(sim.Steps); n == 0 || sim.Steps[n-1].RecommendedPrice != step.RecommendedPrice {
+			sim.Steps = append(sim.Steps, step)
+		}
+	}
+	if len(sim.Steps) > 0 {
+		sim.Changes = len(sim.Steps) - 1
+	}
+	if evaluated > 0 {
+		sim.AveragePrice = math.Round(total/float64(evaluated)*100) / 100
+	}
+	return sim, nil
+}
+
+// postRecommendation sends rec to the rule's webhook in the background.
+func (m *Monitor) postRecommendation(webhook string, rec Recommendation) {
+	go func() {
+		body, err := json.Marshal(rec)
+		if err != nil {
+			return
+		}
+		resp, err := m.client.Post(webhook, "application/json", bytes.NewReader(body))
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode >= 300 {
+				err = fmt.Errorf("status %d", resp.StatusCode)
+			}
+		}
+		if err != nil {
+			log.Warn().Err(err).Str("sku_id", rec.SKUID).Msg("Repricing webhook delivery failed")
+			return
+		}
+		log.Debug().Str("sku_id", rec.SKUID).Msgf("Delivered repricing recommendation %.2f", rec.RecommendedPrice)
+	}()
+}
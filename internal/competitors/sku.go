@@ -0,0 +1,254 @@
+package competitors
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"price-comparison-api/internal/models"
+)
+
+// Match reasons
+const (
+	MatchIdentifier = "identifier" // an identifier appears in the listing's title or URL
+	MatchName       = "name"       // the title has every word of the SKU's query
+)
+
+// SKU is a product a merchant sells, with what competitors charge for it.
+type SKU struct {
+	ID string `json:"id"`
+	// Owner is the caller that registered the SKU
+	Owner string `json:"owner"`
+	// SKU is the merchant's own product code
+	SKU  string `json:"sku,omitempty"`
+	Name string `json:"name"`
+	// Query is searched for the SKU; it defaults to Name
+	Query string `json:"query"`
+	// Identifiers such as gtin, mpn, asin or model; a listing containing any
+	// of them matches regardless of its title
+	Identifiers map[string]string `json:"identifiers,omitempty"`
+	Country     string            `json:"country"`
+	Price       float64           `json:"price"`
+	Currency    string            `json:"currency,omitempty"`
+	// ExcludeSellers are the merchant's own storefronts, never competitors
+	ExcludeSellers []string `json:"exclude_sellers,omitempty"`
+	// ImportID is set for SKUs imported from a store: the integration and
+	// the store's product ID
+	ImportID string `json:"import_id,omitempty"`
+
+	// Repricing is the SKU's repricing rule; Recommendation is what it
+	// suggests given the latest competitor prices
+	Repricing      *Rule           `json:"repricing,omitempty"`
+	Recommendation *Recommendation `json:"recommendation,omitempty"`
+
+	Competitors []CompetitorPrice `json:"competitors"`
+	LastChecked *time.Time        `json:"last_checked,omitempty"`
+	LastError   string            `json:"last_error,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+}
+
+// CompetitorPrice is a competitor listing seen in the latest check.
+type CompetitorPrice struct {
+	Source    string    `json:"source"`
+	Merchant  string    `json:"merchant,omitempty"`
+	Name      string    `json:"name"`
+	URL       string    `json:"url"`
+	Price     float64   `json:"price"`
+	Currency  string    `json:"currency"`
+	InStock   bool      `json:"in_stock"`
+	MatchedBy string    `json:"matched_by"`
+	Undercuts bool      `json:"undercuts"`
+	SeenAt    time.Time `json:"seen_at"`
+}
+
+// Event reports a competitor that started undercutting a SKU, or undercut it
+// further.
+type Event struct {
+	ID              string    `json:"id"`
+	Owner           string    `json:"owner"`
+	SKUID           string    `json:"sku_id"`
+	SKU             string    `json:"sku,omitempty"`
+	Name            string    `json:"name"`
+	Source          string    `json:"source"`
+	Merchant        string    `json:"merchant,omitempty"`
+	URL             string    `json:"url"`
+	OwnPrice        float64   `json:"own_price"`
+	CompetitorPrice float64   `json:"competitor_price"`
+	Difference      float64   `json:"difference"`
+	DifferencePct   float64   `json:"difference_pct"`
+	Currency        string    `json:"currency"`
+	At              time.Time `json:"at"`
+}
+
+// Input is what a merchant sends to register or update a SKU.
+type Input struct {
+	SKU            string            `json:"sku"`
+	Name           string            `json:"name" binding:"required"`
+	Query          string            `json:"query"`
+	Identifiers    map[string]string `json:"identifiers"`
+	Country        string            `json:"country"`
+	Price          float64           `json:"price" binding:"required"`
+	Currency       string            `json:"currency"`
+	ExcludeSellers []string          `json:"exclude_sellers"`
+	Repricing      *Rule             `json:"repricing"`
+}
+
+func (in *Input) validate(defaultCountry string) error {
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalid)
+	}
+	if in.Price <= 0 {
+		return fmt.Errorf("%w: price must be positive", ErrInvalid)
+	}
+	in.Query = strings.TrimSpace(in.Query)
+	if in.Query == "" {
+		in.Query = in.Name
+	}
+	in.Country = strings.ToUpper(strings.TrimSpace(in.Country))
+	if in.Country == "" {
+		in.Country = defaultCountry
+	}
+	in.Currency = strings.ToUpper(strings.TrimSpace(in.Currency))
+	identifiers := make(map[string]string, len(in.Identifiers))
+	for kind, value := range in.Identifiers {
+		if value = strings.TrimSpace(value); value != "" {
+			identifiers[strings.ToLower(strings.TrimSpace(kind))] = value
+		}
+	}
+	in.Identifiers = identifiers
+	if in.Repricing != nil {
+		return in.Repricing.validate()
+	}
+	return nil
+}
+
+func (s *SKU) apply(in Input) {
+	s.SKU = strings.TrimSpace(in.SKU)
+	s.Name = in.Name
+	s.Query = in.Query
+	s.Identifiers = in.Identifiers
+	s.Country = in.Country
+	s.Price = in.Price
+	s.Currency = in.Currency
+	s.ExcludeSellers = in.ExcludeSellers
+	s.Repricing = in.Repricing
+}
+
+// match reports how a listing matches the SKU, or "" if it doesn't.
+// Identifiers shorter than four characters are too ambiguous to use.
+func (s *SKU) match(p models.Product) string {
+	for _, seller := range s.ExcludeSellers {
+		seller = strings.ToLower(strings.TrimSpace(seller))
+		if seller != "" && (strings.ToLower(p.Merchant) == seller || strings.Contains(strings.ToLower(p.Source), seller)) {
+			return ""
+		}
+	}
+	name := strings.ToLower(p.Name)
+	url := strings.ToLower(p.URL)
+	for _, id := range s.Identifiers {
+		id = strings.ToLower(id)
+		if len(id) >= 4 && (strings.Contains(name, id) || strings.Contains(url, id)) {
+			return MatchIdentifier
+		}
+	}
+	for _, word := range strings.Fields(strings.ToLower(s.Query)) {
+		if !strings.Contains(name, word) {
+			return ""
+		}
+	}
+	return MatchName
+}
+
+// comparable reports whether a competitor price can be compared with the
+// SKU's own price.
+func (s *SKU) comparable(c CompetitorPrice) bool {
+	return s.Currency == "" || c.Currency == "" || strings.EqualFold(s.Currency, c.Currency)
+}
+
+// update replaces the SKU's competitors with the listings in products that
+// match it, cheapest first, and returns the undercut events: competitors now
+// cheaper than the SKU that weren't before, or that dropped their price
+// further.
+func (s *SKU) update(products []models.Product, max int, now time.Time) []Event {
+	previous := make(map[string]CompetitorPrice, len(s.Competitors))
+	for _, c := range s.Competitors {
+		previous[c.URL] = c
+	}
+
+	seen := make(map[string]bool)
+	var found []CompetitorPrice
+	for _, p := range products {
+		if p.PriceValue <= 0 || p.URL == "" || seen[p.URL] {
+			continue
+		}
+		matchedBy := s.match(p)
+		if matchedBy == "" {
+			continue
+		}
+		seen[p.URL] = true
+		c := CompetitorPrice{
+			Source:    p.Source,
+			Merchant:  p.Merchant,
+			Name:      p.Name,
+			URL:       p.URL,
+			Price:     p.PriceValue,
+			Currency:  p.Currency,
+			InStock:   p.InStock,
+			MatchedBy: matchedBy,
+			SeenAt:    now,
+		}
+		c.Undercuts = s.comparable(c) && c.Price < s.Price
+		found = append(found, c)
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].Price < found[j].Price })
+	if len(found) > max {
+		found = found[:max]
+	}
+
+	var events []Event
+	for _, c := range found {
+		if !c.Undercuts {
+			continue
+		}
+		if prev, ok := previous[c.URL]; ok && prev.Undercuts && c.Price >= prev.Price {
+			continue
+		}
+		diff := s.Price - c.Price
+		events = append(events, Event{
+			SKUID:           s.ID,
+			SKU:             s.SKU,
+			Name:            s.Name,
+			Source:          c.Source,
+			Merchant:        c.Merchant,
+			URL:             c.URL,
+			OwnPrice:        s.Price,
+			CompetitorPrice: c.Price,
+			Difference:      math.Round(diff*100) / 100,
+			DifferencePct:   math.Round(diff/s.Price*1000) / 10,
+			Currency:        c.Currency,
+			At:              now,
+			Owner:           s.Owner,
+		})
+	}
+
+	if found == nil {
+		found = []CompetitorPrice{}
+	}
+	s.Competitors = found
+	s.LastChecked = &now
+	return events
+}
+
+// rescore re-marks undercutting competitors after the SKU's own price
+// changed. The slice is copied since earlier copies of the SKU share it.
+func (s *SKU) rescore() {
+	competitors := make([]CompetitorPrice, len(s.Competitors))
+	for i, c := range s.Competitors {
+		c.Undercuts = s.comparable(c) && c.Price < s.Price
+		competitors[i] = c
+	}
+	s.Competitors = competitors
+}
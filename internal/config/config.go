@@ -0,0 +1,1652 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+// Scraper names used as keys in Config.Scrapers
+const (
+	ScraperAmazon   = "amazon"
+	ScraperEbay     = "ebay"
+	ScraperFlipkart = "flipkart"
+	ScraperWalmart  = "walmart"
+	ScraperTarget   = "target"
+	ScraperBestBuy  = "bestbuy"
+	// ScraperGoogleShopping aggregates merchant offers from Google Shopping
+	ScraperGoogleShopping = "google_shopping"
+	ScraperAliExpress     = "aliexpress"
+	ScraperEtsy           = "etsy"
+	ScraperCostco         = "costco"
+	ScraperSamsClub       = "samsclub"
+	ScraperMercadoLibre   = "mercadolibre"
+)
+
+// ScraperNames lists every built-in scraper in registration order.
+var ScraperNames = []string{
+	ScraperAmazon, ScraperEbay, ScraperFlipkart, ScraperWalmart, ScraperTarget, ScraperBestBuy,
+	ScraperGoogleShopping, ScraperAliExpress, ScraperEtsy, ScraperCostco, ScraperSamsClub,
+	ScraperMercadoLibre,
+}
+
+const defaultUserAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+
+type Config struct {
+	Server      ServerConfig             `yaml:"server"`
+	GRPC        GRPCConfig               `yaml:"grpc"`
+	Redis       RedisConfig              `yaml:"redis"`
+	DiskCache   DiskCacheConfig          `yaml:"disk_cache"`
+	RateLimit   RateLimitConfig          `yaml:"rate_limit"`
+	Scrapers    map[string]ScraperConfig `yaml:"scrapers"`
+	Politeness  PolitenessConfig         `yaml:"politeness"`
+	Retry       RetryConfig              `yaml:"retry"`
+	Circuit     CircuitBreakerConfig     `yaml:"circuit_breaker"`
+	Selectors   SelectorsConfig          `yaml:"selectors"`
+	Canary      CanaryConfig             `yaml:"canary"`
+	Mock        MockConfig               `yaml:"mock"`
+	Chrome      ChromeConfig             `yaml:"chrome"`
+	Admin       AdminConfig              `yaml:"admin"`
+	Watermark   WatermarkConfig          `yaml:"watermark"`
+	Maintenance MaintenanceConfig        `yaml:"maintenance"`
+	Shadow      ShadowConfig             `yaml:"shadow"`
+	Extractors  ExtractorsConfig         `yaml:"extractors"`
+	Sessions    SessionConfig            `yaml:"sessions"`
+	Tracing     TracingConfig            `yaml:"tracing"`
+	ScrapeTrace ScrapeTraceConfig        `yaml:"scrape_trace"`
+	Storage     StorageConfig            `yaml:"storage"`
+	History     HistoryConfig            `yaml:"history"`
+	Catalog     CatalogConfig            `yaml:"catalog"`
+	Watchlist   WatchlistConfig          `yaml:"watchlist"`
+	SavedSearch SavedSearchesConfig      `yaml:"saved_searches"`
+	Alerts      AlertsConfig             `yaml:"alerts"`
+	Log         LogConfig                `yaml:"log"`
+	Currency    CurrencyConfig           `yaml:"currency"`
+	Regional    RegionalConfig           `yaml:"regional"`
+	Profiles    ProfilesConfig           `yaml:"profiles"`
+	Orgs        OrgsConfig               `yaml:"orgs"`
+	Competitors CompetitorsConfig        `yaml:"competitors"`
+	Attribution AttributionConfig        `yaml:"attribution"`
+	Display     DisplayConfig            `yaml:"display"`
+	Images      ImagesConfig             `yaml:"images"`
+	Providers   ProvidersConfig          `yaml:"providers"`
+	Reporting   ReportingConfig          `yaml:"reporting"`
+}
+
+type ServerConfig struct {
+	Port           string        `yaml:"port"`
+	GinMode        string        `yaml:"gin_mode"`
+	ScrapeTimeout  time.Duration `yaml:"scrape_timeout"`
+	DefaultCountry string        `yaml:"default_country"`
+	// ShutdownTimeout is how long in-flight requests get to finish on
+	// SIGINT/SIGTERM
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+// GRPCConfig serves the gRPC API, for backend services, on its own port
+// next to the HTTP one.
+type GRPCConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Port    string `yaml:"port"`
+	// Reflection lets tools such as grpcurl list the services and messages
+	Reflection bool `yaml:"reflection"`
+}
+
+type RedisConfig struct {
+	Mode             string        `yaml:"mode"` // standalone, cluster, sentinel
+	URL              string        `yaml:"url"`
+	Addrs            []string      `yaml:"addrs"`
+	MasterName       string        `yaml:"master_name"`
+	Password         string        `yaml:"password"`
+	SentinelPassword string        `yaml:"sentinel_password"`
+	DB               int           `yaml:"db"`
+	RouteByLatency   bool          `yaml:"route_by_latency"`
+	TTL              time.Duration `yaml:"ttl"`
+	// NegativeTTL is how long searches that found nothing, or failed on
+	// every source, are cached
+	NegativeTTL time.Duration `yaml:"negative_ttl"`
+	// StaleWhileRevalidate keeps entries this long past TTL. A search hitting
+	// a stale entry gets it at once while the query is scraped again in the
+	// background. 0 turns it off.
+	StaleWhileRevalidate time.Duration `yaml:"stale_while_revalidate"`
+}
+
+type DiskCacheConfig struct {
+	Enabled    bool          `yaml:"enabled"`
+	Path       string        `yaml:"path"`
+	TTL        time.Duration `yaml:"ttl"`
+	MaxEntries int           `yaml:"max_entries"`
+}
+
+type RateLimitConfig struct {
+	RequestsPerSecond float64 `yaml:"requests_per_second" json:"requests_per_second"`
+	Burst             int     `yaml:"burst" json:"burst"`
+}
+
+// Robots modes
+const (
+	// RobotsOff never reads robots.txt
+	RobotsOff = "off"
+	// RobotsRespect honors robots.txt crawl-delay
+	RobotsRespect = "respect"
+	// RobotsStrict honors crawl-delay and refuses disallowed pages
+	RobotsStrict = "strict"
+)
+
+// PolitenessConfig controls how scrapers treat each retailer's robots.txt.
+// Per-scraper delay and parallelism stay in ScraperConfig.
+type PolitenessConfig struct {
+	// Robots is off, respect or strict
+	Robots string `yaml:"robots"`
+	// Agent is the user agent robots.txt groups are matched against; groups
+	// for "*" apply when none names it
+	Agent string `yaml:"agent"`
+	// RobotsTTL is how long a retailer's robots.txt is kept before it is
+	// fetched again
+	RobotsTTL time.Duration `yaml:"robots_ttl"`
+	// MaxCrawlDelay caps the crawl-delay honored, so a retailer asking for
+	// minutes between requests can't stall searches
+	MaxCrawlDelay time.Duration `yaml:"max_crawl_delay"`
+	Timeout       time.Duration `yaml:"timeout"`
+}
+
+// RetryConfig controls how scrapers retry page fetches that fail, and when
+// they stop fetching from a domain that keeps failing.
+type RetryConfig struct {
+	// MaxAttempts is how many times a page is fetched before giving up; 1
+	// turns retries off
+	MaxAttempts int `yaml:"max_attempts"`
+	// Backoff is the wait before the first retry, doubled for each retry
+	// after it up to MaxBackoff; up to half of it again is added as jitter
+	Backoff    time.Duration `yaml:"backoff"`
+	MaxBackoff time.Duration `yaml:"max_backoff"`
+	// Statuses are the HTTP statuses retried. Fetches failing without a
+	// response, such as timeouts, are always retried.
+	Statuses []int `yaml:"statuses"`
+	// FailureThreshold consecutive pages of a domain failing, retries
+	// included, stop its pages being fetched for Cooldown; 0 never does
+	FailureThreshold int           `yaml:"failure_threshold"`
+	Cooldown         time.Duration `yaml:"cooldown"`
+}
+
+// CircuitBreakerConfig controls when searches skip a retailer that keeps
+// failing.
+type CircuitBreakerConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// FailureThreshold failed searches of a retailer in a row open its
+	// circuit: it isn't searched for Cooldown, after which one trial search
+	// decides whether it is searched again
+	FailureThreshold int           `yaml:"failure_threshold"`
+	Cooldown         time.Duration `yaml:"cooldown"`
+}
+
+// SelectorsConfig points at the files overriding the CSS selectors the
+// scrapers read search results with.
+type SelectorsConfig struct {
+	// Dir holds a <scraper>.yaml file for each retailer whose built-in
+	// selectors are overridden; empty uses only the built-in ones
+	Dir string `yaml:"dir"`
+}
+
+// CanaryConfig controls the canary searches behind GET /health/scrapers:
+// one small search per enabled retailer, repeated so that selectors that
+// stop matching show up before users see empty results.
+type CanaryConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Interval is the time between canary runs
+	Interval time.Duration `yaml:"interval"`
+	// Timeout bounds each retailer's canary search
+	Timeout time.Duration `yaml:"timeout"`
+	// MinProducts is the fewest products a healthy canary search finds
+	MinProducts int `yaml:"min_products"`
+	// Queries replaces the built-in canary search of the retailers it
+	// lists, keyed by scraper
+	Queries map[string]CanaryQuery `yaml:"queries"`
+}
+
+// MockConfig controls mock mode, where searches return synthetic products
+// instead of scraping, for frontend development and load tests.
+type MockConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Products is how many products each retailer returns per search
+	Products int `yaml:"products"`
+	// Latency is added to every mocked search, to stand in for scraping
+	Latency time.Duration `yaml:"latency"`
+}
+
+// CanaryQuery is the search a retailer's canary runs. An empty Country
+// keeps the built-in one.
+type CanaryQuery struct {
+	Query   string `yaml:"query"`
+	Country string `yaml:"country"`
+}
+
+type ScraperConfig struct {
+	Enabled     bool          `yaml:"enabled"`
+	Delay       time.Duration `yaml:"delay"`
+	Parallelism int           `yaml:"parallelism"`
+	UserAgent   string        `yaml:"user_agent"`
+	// RetryDelay is the pause between selector attempts
+	RetryDelay time.Duration `yaml:"retry_delay"`
+	// CacheTTL is how long the scraper's results for a query are cached on
+	// their own; 0 uses the cache TTL
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+	// SolveCaptchas lets Chrome hand this retailer's captchas to the
+	// configured solving service (chrome.captcha)
+	SolveCaptchas bool `yaml:"solve_captchas"`
+	// Maintenance lists the retailer's regular downtime; the scraper is
+	// skipped during these windows
+	Maintenance []MaintenanceWindow `yaml:"maintenance"`
+}
+
+// MaintenanceWindow is a daily period, such as 02:00-03:00 IST, during which
+// a retailer is down and isn't scraped. A window whose end is before its
+// start runs past midnight.
+type MaintenanceWindow struct {
+	// Start and End are "15:04" times
+	Start string `yaml:"start"`
+	End   string `yaml:"end"`
+	// TimeZone is an IANA name such as "Asia/Kolkata"; UTC when empty
+	TimeZone string `yaml:"time_zone"`
+	// Days limits the window to the days it starts on ("mon" to "sun");
+	// every day when empty
+	Days []string `yaml:"days"`
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Until returns when the window ends if now falls inside it.
+func (w MaintenanceWindow) Until(now time.Time) (time.Time, bool) {
+	start, err1 := time.Parse("15:04", w.Start)
+	end, err2 := time.Parse("15:04", w.End)
+	loc, err3 := time.LoadLocation(w.TimeZone)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return time.Time{}, false
+	}
+	now = now.In(loc)
+	// Check the window starting today and the one that started yesterday
+	for _, daysAgo := range []int{0, 1} {
+		day := now.AddDate(0, 0, -daysAgo)
+		if !w.on(day.Weekday()) {
+			continue
+		}
+		from := time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), 0, 0, loc)
+		to := time.Date(day.Year(), day.Month(), day.Day(), end.Hour(), end.Minute(), 0, 0, loc)
+		if !to.After(from) {
+			to = to.AddDate(0, 0, 1)
+		}
+		if !now.Before(from) && now.Before(to) {
+			return to, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func (w MaintenanceWindow) on(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if weekdays[strings.ToLower(d)] == day {
+			return true
+		}
+	}
+	return false
+}
+
+func (w MaintenanceWindow) validate() error {
+	start, err := time.Parse("15:04", w.Start)
+	if err != nil {
+		return fmt.Errorf("start %q must be HH:MM", w.Start)
+	}
+	end, err := time.Parse("15:04", w.End)
+	if err != nil {
+		return fmt.Errorf("end %q must be HH:MM", w.End)
+	}
+	if start.Equal(end) {
+		return fmt.Errorf("start and end are both %s", w.Start)
+	}
+	if _, err := time.LoadLocation(w.TimeZone); err != nil {
+		return fmt.Errorf("invalid time_zone %q: %v", w.TimeZone, err)
+	}
+	for _, d := range w.Days {
+		if _, ok := weekdays[strings.ToLower(d)]; !ok {
+			return fmt.Errorf("unknown day %q, use mon to sun", d)
+		}
+	}
+	return nil
+}
+
+// InMaintenance reports whether the scraper is in one of its maintenance
+// windows at now, and when that window ends.
+func (sc ScraperConfig) InMaintenance(now time.Time) (time.Time, bool) {
+	for _, w := range sc.Maintenance {
+		if until, ok := w.Until(now); ok {
+			return until, true
+		}
+	}
+	return time.Time{}, false
+}
+
+type AdminConfig struct {
+	// APIKeys grants access to the /admin routes; admin is locked when empty
+	APIKeys []string `yaml:"api_keys"`
+}
+
+// WatermarkConfig marks the JSON responses of licensed data resellers' API
+// keys, so a leaked dataset can be traced to the key that exported it.
+type WatermarkConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Secret keys the marks; changing it makes earlier marks untraceable
+	Secret string `yaml:"secret"`
+	// Keys are the watermarked API keys, by reseller name
+	Keys map[string]string `yaml:"keys"`
+	// Token adds a "watermark" field to each response; Noise shifts every
+	// price_value by a fraction of a cent that depends on the key and the
+	// listing
+	Token bool `yaml:"token"`
+	Noise bool `yaml:"noise"`
+}
+
+type MaintenanceConfig struct {
+	// Enabled starts the server in maintenance mode
+	Enabled bool `yaml:"enabled"`
+	// ServeCached keeps answering /search from the cache during maintenance
+	ServeCached bool          `yaml:"serve_cached"`
+	Message     string        `yaml:"message"`
+	RetryAfter  time.Duration `yaml:"retry_after"`
+}
+
+// ShadowConfig mirrors a sample of live searches to a second scraper setup
+// and records how its results differ, without touching user responses.
+type ShadowConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// SampleRate is the fraction (0-1) of scraped searches that get mirrored
+	SampleRate float64 `yaml:"sample_rate"`
+	// ConfigFile is a YAML file in the same format as config.yaml; whatever it
+	// sets replaces the live value for the shadow run
+	ConfigFile string `yaml:"config_file"`
+	MaxDiffs   int    `yaml:"max_diffs"`
+}
+
+// ExtractorsConfig sets up a "next" extractor set that runs beside the
+// stable one, the live config, for searches that ask for it.
+type ExtractorsConfig struct {
+	// NextConfigFile is a YAML file in the same format as config.yaml holding
+	// the next set: whatever it sets, typically a few retailers' scrapers
+	// entries, replaces the stable value. No next set exists when empty.
+	NextConfigFile string `yaml:"next_config_file"`
+	// MaxComparisons is how many stable/next comparisons are kept in memory
+	MaxComparisons int `yaml:"max_comparisons"`
+}
+
+// SessionConfig bounds the in-memory search sessions used for refine/undo.
+type SessionConfig struct {
+	TTL         time.Duration `yaml:"ttl"`
+	MaxSessions int           `yaml:"max_sessions"`
+}
+
+// Storage drivers
+const (
+	StorageBolt     = "bolt"
+	StoragePostgres = "postgres"
+	StorageSQLite   = "sqlite"
+)
+
+// StorageConfig picks where the catalog, price history and alerts are kept:
+// a bbolt file per store (each store's path), or one Postgres or SQLite
+// database shared by all three.
+type StorageConfig struct {
+	Driver string `yaml:"driver"`
+	// DSN is the database to connect to: a postgres:// URL, or a SQLite
+	// file path
+	DSN string `yaml:"dsn"`
+	// Connection pool of the SQL drivers
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+	// Migrate applies pending schema migrations at startup; with it off, run
+	// cmd/migrate before deploying
+	Migrate bool `yaml:"migrate"`
+}
+
+// HistoryConfig controls the price history store that records every scraped
+// price; deals and other trend features read from it.
+type HistoryConfig struct {
+	Enabled   bool          `yaml:"enabled"`
+	Path      string        `yaml:"path"`
+	Retention time.Duration `yaml:"retention"`
+}
+
+// CatalogConfig controls the product catalog, which keeps every listing a
+// search finds under a stable ID, grouped into canonical products.
+type CatalogConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Path    string `yaml:"path"`
+}
+
+// WatchlistConfig controls the watchlist, whose products have their detail
+// pages re-scraped on a schedule whether or not anyone searches for them.
+type WatchlistConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Path    string `yaml:"path"`
+	// Interval is how often each watched product is scraped
+	Interval time.Duration `yaml:"interval"`
+	// MaxSnapshots caps the price snapshots kept per product
+	MaxSnapshots int `yaml:"max_snapshots"`
+}
+
+// SavedSearchesConfig controls saved searches, which are re-run on a
+// schedule and publish the offers and price changes they find as RSS feeds.
+type SavedSearchesConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Path    string `yaml:"path"`
+	// Interval is how often each saved search is run again
+	Interval time.Duration `yaml:"interval"`
+	// FeedItems caps the items kept, and served, per feed
+	FeedItems int `yaml:"feed_items"`
+}
+
+// AlertsConfig controls price alerts, which are evaluated against the
+// history store and therefore need it enabled.
+type AlertsConfig struct {
+	Enabled     bool             `yaml:"enabled"`
+	Path        string           `yaml:"path"`
+	MaxEvents   int              `yaml:"max_events"`
+	StockChecks StockCheckConfig `yaml:"stock_checks"`
+	// Chat sends fired alerts to the Telegram chats and Slack channels they
+	// name
+	Chat ChatConfig `yaml:"chat"`
+}
+
+// ChatConfig sets up alert notifications in chat apps.
+type ChatConfig struct {
+	// TelegramBotToken is the token of the bot that messages Telegram
+	// chats; alerts can only notify Telegram when it is set
+	TelegramBotToken string `yaml:"telegram_bot_token"`
+	// TelegramAPIURL is the Bot API's base URL, for a self-hosted server
+	TelegramAPIURL string `yaml:"telegram_api_url"`
+	// Timeout bounds each message
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// ProfilesConfig controls stored preference profiles, which are applied to
+// every search made with the caller's API key.
+type ProfilesConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Path    string `yaml:"path"`
+	// TrustUserHeader identifies callers by X-User-ID as well as by API key;
+	// only enable it behind a gateway that sets the header itself
+	TrustUserHeader bool `yaml:"trust_user_header"`
+}
+
+// OrgsConfig controls organizations, which group API keys that share a
+// watchlist, alerts and alert channels.
+type OrgsConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Path    string `yaml:"path"`
+	// WebhookTimeout bounds each delivery to an alert channel
+	WebhookTimeout time.Duration `yaml:"webhook_timeout"`
+}
+
+// CompetitorsConfig controls competitor monitoring: merchants register their
+// own SKUs and each one is searched for every Interval.
+type CompetitorsConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Path     string        `yaml:"path"`
+	Interval time.Duration `yaml:"interval"`
+	// MaxCompetitors caps the competitor listings kept per SKU, cheapest first
+	MaxCompetitors int `yaml:"max_competitors"`
+	MaxEvents      int `yaml:"max_events"`
+	// WebhookTimeout bounds each delivery of a repricing recommendation
+	WebhookTimeout time.Duration `yaml:"webhook_timeout"`
+	// ImportInterval is how often products are re-imported from connected
+	// Shopify and WooCommerce stores; ImportMaxProducts caps each import
+	ImportInterval    time.Duration `yaml:"import_interval"`
+	ImportMaxProducts int           `yaml:"import_max_products"`
+}
+
+// AttributionConfig controls the per-source attribution (retailer,
+// retrieval time, terms URL) attached to search results and exports, for
+// users who redistribute the data.
+type AttributionConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// EmbedText adds a human-readable attribution line built from Template,
+	// in which {retailer}, {source}, {retrieved_at} and {terms_url} are
+	// replaced
+	EmbedText bool   `yaml:"embed_text"`
+	Template  string `yaml:"template"`
+	// TermsURLs overrides a retailer's terms URL, keyed by scraper name
+	TermsURLs map[string]string `yaml:"terms_urls"`
+}
+
+// ProvidersConfig holds credentials for retailers' official product APIs.
+// A retailer with credentials is searched through its API, and its scraper
+// only runs when the API call fails.
+type ProvidersConfig struct {
+	// Timeout bounds each API request
+	Timeout time.Duration    `yaml:"timeout"`
+	Ebay    EbayAPIConfig    `yaml:"ebay"`
+	BestBuy BestBuyAPIConfig `yaml:"bestbuy"`
+	Walmart WalmartAPIConfig `yaml:"walmart"`
+}
+
+// EbayAPIConfig is an eBay developer application's keyset, used for the
+// Browse API.
+type EbayAPIConfig struct {
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+}
+
+// BestBuyAPIConfig is a Best Buy Products API key.
+type BestBuyAPIConfig struct {
+	APIKey string `yaml:"api_key"`
+}
+
+// WalmartAPIConfig is a Walmart Affiliate API consumer. Requests are signed
+// with the RSA private key whose public half was registered for KeyVersion.
+type WalmartAPIConfig struct {
+	ConsumerID     string `yaml:"consumer_id"`
+	KeyVersion     string `yaml:"key_version"`
+	PrivateKeyFile string `yaml:"private_key_file"`
+	// PublisherID is the Impact affiliate ID added to product links
+	PublisherID string `yaml:"publisher_id"`
+}
+
+// StockCheckConfig schedules availability checks on the detail pages of
+// out-of-stock products that have alerts, so back_in_stock alerts fire
+// without waiting for someone to search for the product again.
+type StockCheckConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Interval is how often a product is checked
+	Interval time.Duration `yaml:"interval"`
+	// PopularInterval applies to products watched by at least
+	// PopularWatchers alerts
+	PopularInterval time.Duration `yaml:"popular_interval"`
+	PopularWatchers int           `yaml:"popular_watchers"`
+	Timeout         time.Duration `yaml:"timeout"`
+	UserAgent       string        `yaml:"user_agent"`
+}
+
+type LogConfig struct {
+	Level  string `yaml:"level"`  // debug, info, warn, error
+	Format string `yaml:"format"` // json, console
+}
+
+// RegionalConfig controls the regional price report, which compares one
+// product's price across countries.
+type RegionalConfig struct {
+	// Countries are compared when a request doesn't name any
+	Countries []string `yaml:"countries"`
+	// ArbitragePercent is how much cheaper than at home, in percent, a
+	// country must be to be flagged as worth buying from
+	ArbitragePercent float64 `yaml:"arbitrage_percent"`
+	// Shipping is the international shipping, in US dollars, added to
+	// landed costs
+	Shipping float64 `yaml:"shipping"`
+	// ImportTax is the duty and import VAT each destination country
+	// charges, as a fraction of the price with shipping
+	ImportTax map[string]float64 `yaml:"import_tax"`
+}
+
+// CurrencyConfig holds the exchange rates used to show prices in a user's
+// preferred currency.
+type CurrencyConfig struct {
+	// Rates are units of each currency per US dollar
+	Rates map[string]float64 `yaml:"rates"`
+}
+
+// ReportingConfig sets the time zone whose midnight starts each day of the
+// daily records and reports (exchange rate days, dated exports) and that
+// dates given without a time zone are read in.
+type ReportingConfig struct {
+	// TimeZone is an IANA name such as "America/New_York"
+	TimeZone string `yaml:"time_zone"`
+}
+
+// Location returns the reporting time zone, or UTC when it can't be loaded.
+func (r ReportingConfig) Location() *time.Location {
+	loc, err := time.LoadLocation(r.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+type TracingConfig struct {
+	Enabled  bool   `yaml:"enabled"`
+	Exporter string `yaml:"exporter"` // otlp, stdout
+	// Endpoint is the OTLP/HTTP collector URL; the standard
+	// OTEL_EXPORTER_OTLP_* variables apply when it's empty
+	Endpoint    string  `yaml:"endpoint"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+	ServiceName string  `yaml:"service_name"`
+}
+
+// DisplayConfig shapes product fields meant for showing rather than
+// matching.
+type DisplayConfig struct {
+	// NameLength cuts product titles to this many characters, at a word
+	// boundary, into display_name; 0 leaves display_name out
+	NameLength int `yaml:"name_length"`
+}
+
+// ImagesConfig controls the image proxy, which fetches product images from
+// retailer CDNs, resizes them into thumbnails and serves them from the cache.
+type ImagesConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// CacheTTL is how long a fetched image, and each thumbnail of it, is kept
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+	// MaxBytes caps the size of an image fetched from a retailer
+	MaxBytes int `yaml:"max_bytes"`
+	// MaxWidth caps the thumbnail width a client can ask for
+	MaxWidth  int           `yaml:"max_width"`
+	Timeout   time.Duration `yaml:"timeout"`
+	UserAgent string        `yaml:"user_agent"`
+}
+
+// ScrapeTraceConfig keeps a trace of every search request, looked up by
+// request ID, for a short while.
+type ScrapeTraceConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	TTL     time.Duration `yaml:"ttl"`
+}
+
+// Browser rendering backends
+const (
+	// BrowserChromedp launches the local Chrome at ExecPath with chromedp
+	BrowserChromedp = "chromedp"
+	// BrowserRemote connects chromedp to a running browser at RemoteURL
+	BrowserRemote = "remote"
+	// BrowserRod drives a browser with go-rod: the one at RemoteURL, or a
+	// local one it launches, downloading Chromium when none is installed
+	BrowserRod = "rod"
+)
+
+type ChromeConfig struct {
+	// Backend is chromedp, remote or rod
+	Backend string `yaml:"backend"`
+	// RemoteURL is a browser's DevTools endpoint, ws://host:port/devtools/...
+	// or http://host:9222, for the remote and rod backends
+	RemoteURL string        `yaml:"remote_url"`
+	ExecPath  string        `yaml:"exec_path"`
+	Headless  bool          `yaml:"headless"`
+	NoSandbox bool          `yaml:"no_sandbox"`
+	UserAgent string        `yaml:"user_agent"`
+	Timeout   time.Duration `yaml:"timeout"`
+	// Proxies are http://, https:// or socks5:// proxy URLs, optionally with
+	// user:password credentials; each scrape takes the next one in turn
+	Proxies []string      `yaml:"proxies"`
+	Captcha CaptchaConfig `yaml:"captcha"`
+}
+
+// Captcha solving providers
+const (
+	CaptchaTwoCaptcha  = "2captcha"
+	CaptchaAntiCaptcha = "anticaptcha"
+)
+
+// CaptchaConfig is the captcha-solving service Chrome hands captcha pages
+// to. Nothing is sent to it unless Provider is set and the retailer's
+// scraper has solve_captchas on.
+type CaptchaConfig struct {
+	// Provider is 2captcha or anticaptcha, or empty for none
+	Provider string `yaml:"provider"`
+	APIKey   string `yaml:"api_key"`
+	// Endpoint replaces the provider's API base URL, for services speaking
+	// the same API
+	Endpoint string `yaml:"endpoint"`
+	// Timeout bounds one solve; its result is checked every PollInterval
+	Timeout      time.Duration `yaml:"timeout"`
+	PollInterval time.Duration `yaml:"poll_interval"`
+}
+
+// Default returns the configuration the service used before it was
+// configurable; every field can be overridden from YAML or env.
+func Default() *Config {
+	cfg := &Config{
+		Server: ServerConfig{
+			Port:            "8085",
+			GinMode:         "debug",
+			ScrapeTimeout:   30 * time.Second,
+			DefaultCountry:  "IN",
+			ShutdownTimeout: 30 * time.Second,
+		},
+		Watermark: WatermarkConfig{
+			Token: true,
+			Noise: true,
+		},
+		GRPC: GRPCConfig{
+			Port:       "9090",
+			Reflection: true,
+		},
+		Redis: RedisConfig{
+			Mode:        "standalone",
+			URL:         "redis://localhost:6379",
+			MasterName:  "mymaster",
+			TTL:         10 * time.Minute,
+			NegativeTTL: 30 * time.Second,
+		},
+		DiskCache: DiskCacheConfig{
+			Enabled:    true,
+			TTL:        2 * time.Minute,
+			MaxEntries: 500,
+		},
+		RateLimit: RateLimitConfig{
+			RequestsPerSecond: 10,
+			Burst:             20,
+		},
+		Scrapers: map[string]ScraperConfig{},
+		Chrome: ChromeConfig{
+			Backend:   BrowserChromedp,
+			ExecPath:  "/Applications/Google Chrome.app/Contents/MacOS/Google Chrome",
+			Headless:  true,
+			NoSandbox: true,
+			UserAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36",
+			Timeout:   45 * time.Second,
+			Captcha: CaptchaConfig{
+				Timeout:      2 * time.Minute,
+				PollInterval: 5 * time.Second,
+			},
+		},
+		Maintenance: MaintenanceConfig{
+			ServeCached: true,
+			Message:     "Search is temporarily unavailable while we perform maintenance. Please try again shortly.",
+			RetryAfter:  5 * time.Minute,
+		},
+		Shadow: ShadowConfig{
+			SampleRate: 0.05,
+			MaxDiffs:   200,
+		},
+		Extractors: ExtractorsConfig{
+			MaxComparisons: 200,
+		},
+		Sessions: SessionConfig{
+			TTL:         30 * time.Minute,
+			MaxSessions: 5000,
+		},
+		Storage: StorageConfig{
+			Driver:          StorageBolt,
+			MaxOpenConns:    10,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+			Migrate:         true,
+		},
+		History: HistoryConfig{
+			Enabled:   true,
+			Retention: 30 * 24 * time.Hour,
+		},
+		Catalog: CatalogConfig{
+			Enabled: true,
+		},
+		Watchlist: WatchlistConfig{
+			Enabled:      true,
+			Interval:     6 * time.Hour,
+			MaxSnapshots: 500,
+		},
+		SavedSearch: SavedSearchesConfig{
+			Enabled:   true,
+			Interval:  time.Hour,
+			FeedItems: 50,
+		},
+		Alerts: AlertsConfig{
+			Enabled:   true,
+			MaxEvents: 1000,
+			StockChecks: StockCheckConfig{
+				Enabled:         true,
+				Interval:        6 * time.Hour,
+				PopularInterval: 30 * time.Minute,
+				PopularWatchers: 3,
+				Timeout:         15 * time.Second,
+				UserAgent:       defaultUserAgent,
+			},
+			Chat: ChatConfig{
+				TelegramAPIURL: "https://api.telegram.org",
+				Timeout:        10 * time.Second,
+			},
+		},
+		Profiles: ProfilesConfig{
+			Enabled: true,
+		},
+		Orgs: OrgsConfig{
+			Enabled:        true,
+			WebhookTimeout: 10 * time.Second,
+		},
+		Competitors: CompetitorsConfig{
+			Enabled:           true,
+			Interval:          6 * time.Hour,
+			MaxCompetitors:    20,
+			MaxEvents:         1000,
+			WebhookTimeout:    10 * time.Second,
+			ImportInterval:    24 * time.Hour,
+			ImportMaxProducts: 500,
+		},
+		Providers: ProvidersConfig{
+			Timeout: 10 * time.Second,
+			Walmart: WalmartAPIConfig{KeyVersion: "1"},
+		},
+		Attribution: AttributionConfig{
+			Enabled:  true,
+			Template: "Data from {retailer} ({source}), retrieved {retrieved_at}. Terms: {terms_url}",
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "json",
+		},
+		Currency: CurrencyConfig{
+			Rates: map[string]float64{
+				"USD": 1, "EUR": 0.92, "GBP": 0.79, "INR": 83.3,
+				"CAD": 1.36, "AUD": 1.52, "JPY": 150,
+				"MXN": 17.1, "BRL": 4.97, "ARS": 830,
+			},
+		},
+		Regional: RegionalConfig{
+			Countries:        []string{"US", "UK", "DE", "FR", "IT", "ES", "CA", "JP", "IN", "AU", "MX", "BR"},
+			ArbitragePercent: 20,
+			Shipping:         25,
+			ImportTax: map[string]float64{
+				"US": 0, "UK": 0.20, "DE": 0.19, "FR": 0.20, "IT": 0.22, "ES": 0.21,
+				"CA": 0.05, "JP": 0.10, "IN": 0.38, "AU": 0.10, "MX": 0.16, "BR": 0.60,
+			},
+		},
+		Reporting: ReportingConfig{
+			TimeZone: "UTC",
+		},
+		Tracing: TracingConfig{
+			Exporter:    "otlp",
+			SampleRatio: 1,
+			ServiceName: "price-comparison-api",
+		},
+		ScrapeTrace: ScrapeTraceConfig{
+			Enabled: true,
+			TTL:     30 * time.Minute,
+		},
+		Politeness: PolitenessConfig{
+			Robots:        RobotsRespect,
+			Agent:         "price-comparison-api",
+			RobotsTTL:     24 * time.Hour,
+			MaxCrawlDelay: 10 * time.Second,
+			Timeout:       5 * time.Second,
+		},
+		Retry: RetryConfig{
+			MaxAttempts:      3,
+			Backoff:          500 * time.Millisecond,
+			MaxBackoff:       5 * time.Second,
+			Statuses:         []int{408, 425, 429, 500, 502, 503, 504},
+			FailureThreshold: 5,
+			Cooldown:         2 * time.Minute,
+		},
+		Circuit: CircuitBreakerConfig{
+			Enabled:          true,
+			FailureThreshold: 5,
+			Cooldown:         5 * time.Minute,
+		},
+		Canary: CanaryConfig{
+			Interval:    30 * time.Minute,
+			Timeout:     time.Minute,
+			MinProducts: 1,
+		},
+		Mock: MockConfig{
+			Products: 8,
+		},
+		Images: ImagesConfig{
+			Enabled:   true,
+			CacheTTL:  24 * time.Hour,
+			MaxBytes:  10 << 20,
+			MaxWidth:  1200,
+			Timeout:   10 * time.Second,
+			UserAgent: defaultUserAgent,
+		},
+	}
+
+	delays := map[string]time.Duration{
+		ScraperAmazon:   2 * time.Second,
+		ScraperEbay:     2 * time.Second,
+		ScraperFlipkart: 5 * time.Second,
+		ScraperWalmart:  3 * time.Second,
+		ScraperTarget:   3 * time.Second,
+		ScraperBestBuy:  3 * time.Second,
+		// Google rate-limits quickly, so keep well apart
+		ScraperGoogleShopping: 5 * time.Second,
+		ScraperAliExpress:     3 * time.Second,
+		ScraperEtsy:           3 * time.Second,
+		ScraperCostco:         3 * time.Second,
+		ScraperSamsClub:       3 * time.Second,
+		ScraperMercadoLibre:   3 * time.Second,
+	}
+	for _, name := range ScraperNames {
+		cfg.Scrapers[name] = ScraperConfig{
+			Enabled:     true,
+			Delay:       delays[name],
+			Parallelism: 1,
+			UserAgent:   defaultUserAgent,
+			RetryDelay:  2 * time.Second,
+		}
+	}
+
+	return cfg
+}
+
+// Load builds the configuration from defaults, then the YAML file named by
+// CONFIG_FILE (or ./config.yaml when present), then environment variables.
+func Load() (*Config, error) {
+	cfg := Default()
+
+	path := os.Getenv("CONFIG_FILE")
+	if path == "" {
+		if _, err := os.Stat("config.yaml"); err == nil {
+			path = "config.yaml"
+		}
+	}
+
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+		log.Info().Msgf("Loaded configuration from %s", path)
+	}
+
+	cfg.applyEnv()
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Overlay returns a copy of the configuration with the YAML file at path
+// applied on top. It is used to build the alternate setup for shadow mode.
+func (c *Config) Overlay(path string) (*Config, error) {
+	overlay := *c
+	overlay.Scrapers = make(map[string]ScraperConfig, len(c.Scrapers))
+	for name, sc := range c.Scrapers {
+		overlay.Scrapers[name] = sc
+	}
+	// Never let the copy shadow itself or load another extractor set
+	overlay.Shadow = ShadowConfig{}
+	overlay.Extractors = ExtractorsConfig{}
+
+	if err := overlay.loadFile(path); err != nil {
+		return nil, err
+	}
+	overlay.Shadow = ShadowConfig{}
+	overlay.Extractors = ExtractorsConfig{}
+	if err := overlay.Validate(); err != nil {
+		return nil, err
+	}
+	return &overlay, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file %s: %v", path, err)
+	}
+
+	// Decode over the defaults so omitted fields keep their default values.
+	// Scrapers are merged one entry at a time below for the same reason, since
+	// decoding a map replaces whole values.
+	scraperDefaults := c.Scrapers
+	c.Scrapers = nil
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+	c.Scrapers = scraperDefaults
+
+	var raw struct {
+		Scrapers map[string]yaml.Node `yaml:"scrapers"`
+	}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+	for name, node := range raw.Scrapers {
+		name = strings.ToLower(name)
+		sc, ok := c.Scrapers[name]
+		if !ok {
+			sc = ScraperConfig{Enabled: true, Parallelism: 1, UserAgent: defaultUserAgent}
+		}
+		if err := node.Decode(&sc); err != nil {
+			return fmt.Errorf("invalid scraper config %s: %v", name, err)
+		}
+		c.Scrapers[name] = sc
+	}
+
+	return nil
+}
+
+func (c *Config) applyEnv() {
+	envString("PORT", &c.Server.Port)
+	envString("GIN_MODE", &c.Server.GinMode)
+	envSeconds("SCRAPING_TIMEOUT", &c.Server.ScrapeTimeout)
+	envString("DEFAULT_COUNTRY", &c.Server.DefaultCountry)
+	envSeconds("SHUTDOWN_TIMEOUT", &c.Server.ShutdownTimeout)
+
+	envBool("GRPC_ENABLED", &c.GRPC.Enabled)
+	envString("GRPC_PORT", &c.GRPC.Port)
+	envBool("GRPC_REFLECTION", &c.GRPC.Reflection)
+
+	envString("REDIS_MODE", &c.Redis.Mode)
+	envString("REDIS_URL", &c.Redis.URL)
+	envList("REDIS_ADDRS", &c.Redis.Addrs)
+	envString("REDIS_MASTER_NAME", &c.Redis.MasterName)
+	envString("REDIS_PASSWORD", &c.Redis.Password)
+	envString("REDIS_SENTINEL_PASSWORD", &c.Redis.SentinelPassword)
+	envInt("REDIS_DB", &c.Redis.DB)
+	envBool("REDIS_ROUTE_BY_LATENCY", &c.Redis.RouteByLatency)
+	envSeconds("CACHE_TTL", &c.Redis.TTL)
+	envSeconds("CACHE_NEGATIVE_TTL", &c.Redis.NegativeTTL)
+	envSeconds("CACHE_STALE_WHILE_REVALIDATE", &c.Redis.StaleWhileRevalidate)
+
+	envBool("DISK_CACHE_ENABLED", &c.DiskCache.Enabled)
+	envString("DISK_CACHE_PATH", &c.DiskCache.Path)
+	envSeconds("DISK_CACHE_TTL", &c.DiskCache.TTL)
+	envInt("DISK_CACHE_MAX_ENTRIES", &c.DiskCache.MaxEntries)
+
+	envFloat("RATE_LIMIT_REQUESTS", &c.RateLimit.RequestsPerSecond)
+	envInt("RATE_LIMIT_BURST", &c.RateLimit.Burst)
+
+	envString("ROBOTS_MODE", &c.Politeness.Robots)
+	envString("ROBOTS_AGENT", &c.Politeness.Agent)
+	envSeconds("ROBOTS_TTL", &c.Politeness.RobotsTTL)
+	envSeconds("ROBOTS_MAX_CRAWL_DELAY", &c.Politeness.MaxCrawlDelay)
+
+	envInt("RETRY_MAX_ATTEMPTS", &c.Retry.MaxAttempts)
+	envMillis("RETRY_BACKOFF_MS", &c.Retry.Backoff)
+	envMillis("RETRY_MAX_BACKOFF_MS", &c.Retry.MaxBackoff)
+	envInts("RETRY_STATUSES", &c.Retry.Statuses)
+	envInt("RETRY_FAILURE_THRESHOLD", &c.Retry.FailureThreshold)
+	envSeconds("RETRY_COOLDOWN", &c.Retry.Cooldown)
+
+	envBool("CIRCUIT_BREAKER_ENABLED", &c.Circuit.Enabled)
+	envInt("CIRCUIT_BREAKER_THRESHOLD", &c.Circuit.FailureThreshold)
+	envSeconds("CIRCUIT_BREAKER_COOLDOWN", &c.Circuit.Cooldown)
+
+	envString("SELECTORS_DIR", &c.Selectors.Dir)
+
+	envBool("CANARY_ENABLED", &c.Canary.Enabled)
+	envSeconds("CANARY_INTERVAL", &c.Canary.Interval)
+	envSeconds("CANARY_TIMEOUT", &c.Canary.Timeout)
+	envInt("CANARY_MIN_PRODUCTS", &c.Canary.MinProducts)
+
+	envBool("MOCK_MODE", &c.Mock.Enabled)
+	envInt("MOCK_PRODUCTS", &c.Mock.Products)
+	envMillis("MOCK_LATENCY_MS", &c.Mock.Latency)
+
+	// SCRAPERS_ENABLED=amazon,ebay switches on only the listed scrapers
+	if list := os.Getenv("SCRAPERS_ENABLED"); list != "" {
+		enabled := map[string]bool{}
+		for _, name := range strings.Split(list, ",") {
+			enabled[strings.ToLower(strings.TrimSpace(name))] = true
+		}
+		for name, sc := range c.Scrapers {
+			sc.Enabled = enabled[name]
+			c.Scrapers[name] = sc
+		}
+	}
+
+	for name, sc := range c.Scrapers {
+		prefix := "SCRAPER_" + strings.ToUpper(name) + "_"
+		envBool(prefix+"ENABLED", &sc.Enabled)
+		envMillis(prefix+"DELAY_MS", &sc.Delay)
+		envInt(prefix+"PARALLELISM", &sc.Parallelism)
+		envString(prefix+"USER_AGENT", &sc.UserAgent)
+		envSeconds(prefix+"CACHE_TTL", &sc.CacheTTL)
+		envBool(prefix+"SOLVE_CAPTCHAS", &sc.SolveCaptchas)
+		c.Scrapers[name] = sc
+	}
+
+	envString("CHROME_BACKEND", &c.Chrome.Backend)
+	envString("CHROME_REMOTE_URL", &c.Chrome.RemoteURL)
+	envString("CHROME_PATH", &c.Chrome.ExecPath)
+	envBool("CHROME_HEADLESS", &c.Chrome.Headless)
+	envBool("CHROME_NO_SANDBOX", &c.Chrome.NoSandbox)
+	envString("CHROME_USER_AGENT", &c.Chrome.UserAgent)
+	envSeconds("CHROME_TIMEOUT", &c.Chrome.Timeout)
+	envList("CHROME_PROXIES", &c.Chrome.Proxies)
+	envString("CAPTCHA_PROVIDER", &c.Chrome.Captcha.Provider)
+	envString("CAPTCHA_API_KEY", &c.Chrome.Captcha.APIKey)
+	envString("CAPTCHA_ENDPOINT", &c.Chrome.Captcha.Endpoint)
+	envSeconds("CAPTCHA_TIMEOUT", &c.Chrome.Captcha.Timeout)
+	envSeconds("CAPTCHA_POLL_INTERVAL", &c.Chrome.Captcha.PollInterval)
+
+	envList("ADMIN_API_KEYS", &c.Admin.APIKeys)
+
+	envBool("WATERMARK_ENABLED", &c.Watermark.Enabled)
+	envString("WATERMARK_SECRET", &c.Watermark.Secret)
+	var watermarkKeys []string
+	envList("WATERMARK_KEYS", &watermarkKeys)
+	for _, entry := range watermarkKeys {
+		if name, key, ok := strings.Cut(entry, "="); ok {
+			if c.Watermark.Keys == nil {
+				c.Watermark.Keys = make(map[string]string)
+			}
+			c.Watermark.Keys[strings.TrimSpace(name)] = strings.TrimSpace(key)
+		}
+	}
+	envBool("WATERMARK_TOKEN", &c.Watermark.Token)
+	envBool("WATERMARK_NOISE", &c.Watermark.Noise)
+
+	envBool("MAINTENANCE_MODE", &c.Maintenance.Enabled)
+	envBool("MAINTENANCE_SERVE_CACHED", &c.Maintenance.ServeCached)
+	envString("MAINTENANCE_MESSAGE", &c.Maintenance.Message)
+	envSeconds("MAINTENANCE_RETRY_AFTER", &c.Maintenance.RetryAfter)
+
+	envBool("SHADOW_ENABLED", &c.Shadow.Enabled)
+	envFloat("SHADOW_SAMPLE_RATE", &c.Shadow.SampleRate)
+	envString("SHADOW_CONFIG_FILE", &c.Shadow.ConfigFile)
+	envInt("SHADOW_MAX_DIFFS", &c.Shadow.MaxDiffs)
+
+	envString("EXTRACTORS_NEXT_CONFIG_FILE", &c.Extractors.NextConfigFile)
+	envInt("EXTRACTORS_MAX_COMPARISONS", &c.Extractors.MaxComparisons)
+
+	envSeconds("SESSION_TTL", &c.Sessions.TTL)
+	envInt("SESSION_MAX", &c.Sessions.MaxSessions)
+
+	envString("STORAGE_DRIVER", &c.Storage.Driver)
+	envString("STORAGE_DSN", &c.Storage.DSN)
+	envInt("STORAGE_MAX_OPEN_CONNS", &c.Storage.MaxOpenConns)
+	envInt("STORAGE_MAX_IDLE_CONNS", &c.Storage.MaxIdleConns)
+	envSeconds("STORAGE_CONN_MAX_LIFETIME", &c.Storage.ConnMaxLifetime)
+	envSeconds("STORAGE_CONN_MAX_IDLE_TIME", &c.Storage.ConnMaxIdleTime)
+	envBool("STORAGE_MIGRATE", &c.Storage.Migrate)
+
+	envBool("HISTORY_ENABLED", &c.History.Enabled)
+	envString("HISTORY_PATH", &c.History.Path)
+	envDays("HISTORY_RETENTION_DAYS", &c.History.Retention)
+	envBool("CATALOG_ENABLED", &c.Catalog.Enabled)
+	envString("CATALOG_PATH", &c.Catalog.Path)
+	envBool("WATCHLIST_ENABLED", &c.Watchlist.Enabled)
+	envString("WATCHLIST_PATH", &c.Watchlist.Path)
+	envSeconds("WATCHLIST_INTERVAL", &c.Watchlist.Interval)
+	envInt("WATCHLIST_MAX_SNAPSHOTS", &c.Watchlist.MaxSnapshots)
+	envBool("SAVED_SEARCHES_ENABLED", &c.SavedSearch.Enabled)
+	envString("SAVED_SEARCHES_PATH", &c.SavedSearch.Path)
+	envSeconds("SAVED_SEARCHES_INTERVAL", &c.SavedSearch.Interval)
+	envInt("SAVED_SEARCHES_FEED_ITEMS", &c.SavedSearch.FeedItems)
+
+	envBool("ALERTS_ENABLED", &c.Alerts.Enabled)
+	envString("ALERTS_PATH", &c.Alerts.Path)
+	envInt("ALERTS_MAX_EVENTS", &c.Alerts.MaxEvents)
+	envBool("STOCK_CHECKS_ENABLED", &c.Alerts.StockChecks.Enabled)
+	envSeconds("STOCK_CHECK_INTERVAL", &c.Alerts.StockChecks.Interval)
+	envSeconds("STOCK_CHECK_POPULAR_INTERVAL", &c.Alerts.StockChecks.PopularInterval)
+	envInt("STOCK_CHECK_POPULAR_WATCHERS", &c.Alerts.StockChecks.PopularWatchers)
+	envString("TELEGRAM_BOT_TOKEN", &c.Alerts.Chat.TelegramBotToken)
+	envString("TELEGRAM_API_URL", &c.Alerts.Chat.TelegramAPIURL)
+	envSeconds("ALERTS_CHAT_TIMEOUT", &c.Alerts.Chat.Timeout)
+
+	envBool("PROFILES_ENABLED", &c.Profiles.Enabled)
+	envString("PROFILES_PATH", &c.Profiles.Path)
+	envBool("PROFILES_TRUST_USER_HEADER", &c.Profiles.TrustUserHeader)
+
+	envBool("ORGS_ENABLED", &c.Orgs.Enabled)
+	envString("ORGS_PATH", &c.Orgs.Path)
+	envSeconds("ORGS_WEBHOOK_TIMEOUT", &c.Orgs.WebhookTimeout)
+
+	envBool("COMPETITORS_ENABLED", &c.Competitors.Enabled)
+	envString("COMPETITORS_PATH", &c.Competitors.Path)
+	envSeconds("COMPETITORS_INTERVAL", &c.Competitors.Interval)
+	envInt("COMPETITORS_MAX_COMPETITORS", &c.Competitors.MaxCompetitors)
+	envInt("COMPETITORS_MAX_EVENTS", &c.Competitors.MaxEvents)
+	envSeconds("COMPETITORS_WEBHOOK_TIMEOUT", &c.Competitors.WebhookTimeout)
+	envSeconds("COMPETITORS_IMPORT_INTERVAL", &c.Competitors.ImportInterval)
+	envInt("COMPETITORS_IMPORT_MAX_PRODUCTS", &c.Competitors.ImportMaxProducts)
+
+	envSeconds("PROVIDERS_TIMEOUT", &c.Providers.Timeout)
+	envString("EBAY_CLIENT_ID", &c.Providers.Ebay.ClientID)
+	envString("EBAY_CLIENT_SECRET", &c.Providers.Ebay.ClientSecret)
+	envString("BESTBUY_API_KEY", &c.Providers.BestBuy.APIKey)
+	envString("WALMART_CONSUMER_ID", &c.Providers.Walmart.ConsumerID)
+	envString("WALMART_KEY_VERSION", &c.Providers.Walmart.KeyVersion)
+	envString("WALMART_PRIVATE_KEY_FILE", &c.Providers.Walmart.PrivateKeyFile)
+	envString("WALMART_PUBLISHER_ID", &c.Providers.Walmart.PublisherID)
+
+	envBool("ATTRIBUTION_ENABLED", &c.Attribution.Enabled)
+	envBool("ATTRIBUTION_EMBED_TEXT", &c.Attribution.EmbedText)
+	envString("ATTRIBUTION_TEMPLATE", &c.Attribution.Template)
+
+	// CURRENCY_RATES=EUR=0.92,GBP=0.79 overrides individual rates
+	var rates []string
+	envList("CURRENCY_RATES", &rates)
+	for _, item := range rates {
+		code, value, ok := strings.Cut(item, "=")
+		if rate, err := strconv.ParseFloat(strings.TrimSpace(value), 64); ok && err == nil {
+			if c.Currency.Rates == nil {
+				c.Currency.Rates = make(map[string]float64)
+			}
+			c.Currency.Rates[strings.ToUpper(strings.TrimSpace(code))] = rate
+		}
+	}
+
+	envList("REGIONAL_COUNTRIES", &c.Regional.Countries)
+	envFloat("REGIONAL_ARBITRAGE_PERCENT", &c.Regional.ArbitragePercent)
+	envFloat("REGIONAL_SHIPPING", &c.Regional.Shipping)
+
+	envString("REPORTING_TIME_ZONE", &c.Reporting.TimeZone)
+
+	envString("LOG_LEVEL", &c.Log.Level)
+	envString("LOG_FORMAT", &c.Log.Format)
+
+	envBool("TRACING_ENABLED", &c.Tracing.Enabled)
+	envString("TRACING_EXPORTER", &c.Tracing.Exporter)
+	envString("TRACING_ENDPOINT", &c.Tracing.Endpoint)
+	envFloat("TRACING_SAMPLE_RATIO", &c.Tracing.SampleRatio)
+	envString("OTEL_SERVICE_NAME", &c.Tracing.ServiceName)
+
+	envInt("DISPLAY_NAME_LENGTH", &c.Display.NameLength)
+
+	envBool("SCRAPE_TRACE_ENABLED", &c.ScrapeTrace.Enabled)
+	envSeconds("SCRAPE_TRACE_TTL", &c.ScrapeTrace.TTL)
+
+	envBool("IMAGES_ENABLED", &c.Images.Enabled)
+	envSeconds("IMAGES_CACHE_TTL", &c.Images.CacheTTL)
+	envInt("IMAGES_MAX_BYTES", &c.Images.MaxBytes)
+	envInt("IMAGES_MAX_WIDTH", &c.Images.MaxWidth)
+	envSeconds("IMAGES_TIMEOUT", &c.Images.Timeout)
+}
+
+// Validate rejects configurations that would fail later in less obvious ways.
+func (c *Config) Validate() error {
+	c.Redis.Mode = strings.ToLower(c.Redis.Mode)
+	switch c.Redis.Mode {
+	case "", "standalone":
+		c.Redis.Mode = "standalone"
+	case "cluster", "sentinel":
+		if len(c.Redis.Addrs) == 0 {
+			return fmt.Errorf("redis.addrs (REDIS_ADDRS) is required when redis mode is %s", c.Redis.Mode)
+		}
+	default:
+		return fmt.Errorf("invalid redis mode: %s. Valid modes: standalone, cluster, sentinel", c.Redis.Mode)
+	}
+	if c.Redis.NegativeTTL <= 0 {
+		return fmt.Errorf("cache negative ttl (CACHE_NEGATIVE_TTL) must be positive")
+	}
+	if c.Redis.StaleWhileRevalidate < 0 {
+		return fmt.Errorf("cache stale-while-revalidate window (CACHE_STALE_WHILE_REVALIDATE) cannot be negative")
+	}
+
+	if c.Server.Port == "" {
+		return fmt.Errorf("server port cannot be empty")
+	}
+	if c.Watermark.Enabled {
+		if len(c.Watermark.Secret) < 16 {
+			return fmt.Errorf("watermark secret (WATERMARK_SECRET) must be at least 16 characters")
+		}
+		if len(c.Watermark.Keys) == 0 {
+			return fmt.Errorf("watermark keys (WATERMARK_KEYS) are required when watermarking is enabled")
+		}
+		for name, key := range c.Watermark.Keys {
+			if name == "" || key == "" {
+				return fmt.Errorf("watermark keys need a reseller name and an API key each")
+			}
+		}
+		if !c.Watermark.Token && !c.Watermark.Noise {
+			return fmt.Errorf("watermarking needs the token, the noise or both")
+		}
+	}
+	if c.GRPC.Enabled && (c.GRPC.Port == "" || c.GRPC.Port == c.Server.Port) {
+		return fmt.Errorf("grpc port (GRPC_PORT) must be set and differ from the HTTP port")
+	}
+	if c.RateLimit.RequestsPerSecond <= 0 {
+		return fmt.Errorf("rate limit must be positive")
+	}
+	if c.RateLimit.Burst <= 0 {
+		return fmt.Errorf("rate limit burst must be positive")
+	}
+
+	if c.Shadow.Enabled {
+		if c.Shadow.SampleRate < 0 || c.Shadow.SampleRate > 1 {
+			return fmt.Errorf("shadow sample rate must be between 0 and 1")
+		}
+		if c.Shadow.ConfigFile == "" {
+			return fmt.Errorf("shadow.config_file (SHADOW_CONFIG_FILE) is required when shadow mode is enabled")
+		}
+	}
+
+	c.Chrome.Backend = strings.ToLower(c.Chrome.Backend)
+	switch c.Chrome.Backend {
+	case "":
+		c.Chrome.Backend = BrowserChromedp
+	case BrowserChromedp, BrowserRemote, BrowserRod:
+	default:
+		return fmt.Errorf("invalid chrome backend (CHROME_BACKEND) %q: must be chromedp, remote or rod", c.Chrome.Backend)
+	}
+	if c.Chrome.Backend == BrowserRemote && c.Chrome.RemoteURL == "" {
+		return fmt.Errorf("chrome.remote_url (CHROME_REMOTE_URL) is required with the remote backend")
+	}
+	if c.Chrome.RemoteURL != "" {
+		u, err := url.Parse(c.Chrome.RemoteURL)
+		if err != nil || u.Host == "" || (u.Scheme != "ws" && u.Scheme != "wss" && u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("chrome remote URL must be a ws://, wss://, http:// or https:// DevTools endpoint")
+		}
+	}
+
+	for _, proxy := range c.Chrome.Proxies {
+		u, err := url.Parse(proxy)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("invalid chrome proxy URL: want scheme://[user:password@]host:port")
+		}
+		if u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5" {
+			return fmt.Errorf("chrome proxy %s: scheme must be http, https or socks5", u.Redacted())
+		}
+	}
+
+	if cc := c.Chrome.Captcha; cc.Provider != "" {
+		if cc.Provider != CaptchaTwoCaptcha && cc.Provider != CaptchaAntiCaptcha {
+			return fmt.Errorf("chrome captcha provider must be %s or %s", CaptchaTwoCaptcha, CaptchaAntiCaptcha)
+		}
+		if cc.APIKey == "" {
+			return fmt.Errorf("chrome.captcha.api_key (CAPTCHA_API_KEY) is required when a captcha provider is set")
+		}
+		if cc.Endpoint != "" {
+			if u, err := url.Parse(cc.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("chrome captcha endpoint must be an http(s) URL")
+			}
+		}
+		if cc.Timeout <= 0 || cc.PollInterval <= 0 {
+			return fmt.Errorf("chrome captcha timeout and poll_interval must be positive")
+		}
+	}
+
+	c.Storage.Driver = strings.ToLower(c.Storage.Driver)
+	switch c.Storage.Driver {
+	case "", StorageBolt:
+		c.Storage.Driver = StorageBolt
+	case StoragePostgres, StorageSQLite:
+		if c.Storage.DSN == "" {
+			return fmt.Errorf("storage.dsn (STORAGE_DSN) is required with the %s driver", c.Storage.Driver)
+		}
+		if c.Storage.MaxOpenConns <= 0 || c.Storage.MaxIdleConns < 0 {
+			return fmt.Errorf("storage max_open_conns must be positive and max_idle_conns not negative")
+		}
+	default:
+		return fmt.Errorf("invalid storage driver: %s. Valid drivers: %s, %s, %s", c.Storage.Driver, StorageBolt, StoragePostgres, StorageSQLite)
+	}
+
+	if c.Extractors.NextConfigFile != "" && c.Extractors.MaxComparisons <= 0 {
+		return fmt.Errorf("extractors.max_comparisons must be positive")
+	}
+
+	if sc := c.Alerts.StockChecks; sc.Enabled {
+		if sc.Interval < time.Minute || sc.PopularInterval < time.Minute {
+			return fmt.Errorf("stock check intervals must be at least one minute")
+		}
+		if sc.PopularWatchers <= 0 {
+			return fmt.Errorf("stock check popular_watchers must be positive")
+		}
+	}
+
+	if c.Regional.ArbitragePercent <= 0 || c.Regional.Shipping < 0 {
+		return fmt.Errorf("regional arbitrage_percent must be positive and shipping not negative")
+	}
+
+	if wc := c.Watchlist; wc.Enabled {
+		if wc.Interval < time.Minute {
+			return fmt.Errorf("watchlist interval must be at least one minute")
+		}
+		if wc.MaxSnapshots <= 0 {
+			return fmt.Errorf("watchlist max_snapshots must be positive")
+		}
+	}
+
+	if sc := c.SavedSearch; sc.Enabled {
+		if sc.Interval < time.Minute {
+			return fmt.Errorf("saved_searches interval must be at least one minute")
+		}
+		if sc.FeedItems <= 0 {
+			return fmt.Errorf("saved_searches feed_items must be positive")
+		}
+	}
+
+	if cc := c.Competitors; cc.Enabled {
+		if cc.Interval < time.Minute || cc.ImportInterval < time.Minute {
+			return fmt.Errorf("competitors interval and import_interval must be at least one minute")
+		}
+		if cc.MaxCompetitors <= 0 {
+			return fmt.Errorf("competitors max_competitors must be positive")
+		}
+	}
+
+	if e := c.Providers.Ebay; (e.ClientID == "") != (e.ClientSecret == "") {
+		return fmt.Errorf("providers ebay needs both client_id and client_secret (EBAY_CLIENT_ID, EBAY_CLIENT_SECRET)")
+	}
+	if w := c.Providers.Walmart; w.ConsumerID != "" && w.PrivateKeyFile == "" {
+		return fmt.Errorf("providers walmart private_key_file (WALMART_PRIVATE_KEY_FILE) is required with a consumer_id")
+	}
+
+	if a := c.Attribution; a.Enabled && a.EmbedText && strings.TrimSpace(a.Template) == "" {
+		return fmt.Errorf("attribution template (ATTRIBUTION_TEMPLATE) is required when embed_text is on")
+	}
+
+	rates := make(map[string]float64, len(c.Currency.Rates))
+	for code, rate := range c.Currency.Rates {
+		if rate <= 0 {
+			return fmt.Errorf("currency rate for %s must be positive", code)
+		}
+		rates[strings.ToUpper(code)] = rate
+	}
+	c.Currency.Rates = rates
+
+	if _, err := time.LoadLocation(c.Reporting.TimeZone); err != nil {
+		return fmt.Errorf("invalid reporting time_zone (REPORTING_TIME_ZONE) %q: %v", c.Reporting.TimeZone, err)
+	}
+
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		return fmt.Errorf("tracing sample ratio must be between 0 and 1")
+	}
+	if c.Display.NameLength < 0 {
+		return fmt.Errorf("display name length (DISPLAY_NAME_LENGTH) cannot be negative")
+	}
+	if c.ScrapeTrace.Enabled && c.ScrapeTrace.TTL <= 0 {
+		return fmt.Errorf("scrape trace ttl (SCRAPE_TRACE_TTL) must be positive")
+	}
+	if c.Images.Enabled {
+		if c.Images.CacheTTL <= 0 {
+			return fmt.Errorf("image cache ttl (IMAGES_CACHE_TTL) must be positive")
+		}
+		if c.Images.MaxBytes <= 0 || c.Images.MaxWidth <= 0 || c.Images.Timeout <= 0 {
+			return fmt.Errorf("image max bytes, max width and timeout must be positive")
+		}
+	}
+
+	c.Politeness.Robots = strings.ToLower(c.Politeness.Robots)
+	switch c.Politeness.Robots {
+	case "":
+		c.Politeness.Robots = RobotsOff
+	case RobotsOff, RobotsRespect, RobotsStrict:
+	default:
+		return fmt.Errorf("invalid robots mode (ROBOTS_MODE) %q: must be off, respect or strict", c.Politeness.Robots)
+	}
+	if c.Politeness.Robots != RobotsOff && (c.Politeness.RobotsTTL <= 0 || c.Politeness.Timeout <= 0) {
+		return fmt.Errorf("politeness robots_ttl (ROBOTS_TTL) and timeout must be positive")
+	}
+	if c.Politeness.MaxCrawlDelay < 0 {
+		return fmt.Errorf("max crawl delay (ROBOTS_MAX_CRAWL_DELAY) cannot be negative")
+	}
+
+	if c.Retry.MaxAttempts < 1 {
+		return fmt.Errorf("retry max attempts (RETRY_MAX_ATTEMPTS) must be at least 1")
+	}
+	if c.Retry.Backoff < 0 || c.Retry.MaxBackoff < c.Retry.Backoff {
+		return fmt.Errorf("retry backoff (RETRY_BACKOFF_MS) cannot be negative or above max backoff (RETRY_MAX_BACKOFF_MS)")
+	}
+	for _, status := range c.Retry.Statuses {
+		if status < 400 || status > 599 {
+			return fmt.Errorf("retry status %d is not an HTTP error status", status)
+		}
+	}
+	if c.Retry.FailureThreshold < 0 {
+		return fmt.Errorf("retry failure threshold (RETRY_FAILURE_THRESHOLD) cannot be negative")
+	}
+	if c.Retry.FailureThreshold > 0 && c.Retry.Cooldown <= 0 {
+		return fmt.Errorf("retry cooldown (RETRY_COOLDOWN) must be positive")
+	}
+	if c.Circuit.Enabled && (c.Circuit.FailureThreshold <= 0 || c.Circuit.Cooldown <= 0) {
+		return fmt.Errorf("circuit breaker threshold (CIRCUIT_BREAKER_THRESHOLD) and cooldown (CIRCUIT_BREAKER_COOLDOWN) must be positive")
+	}
+	if c.Canary.Enabled && (c.Canary.Interval <= 0 || c.Canary.Timeout <= 0) {
+		return fmt.Errorf("canary interval (CANARY_INTERVAL) and timeout (CANARY_TIMEOUT) must be positive")
+	}
+	if c.Canary.MinProducts < 1 {
+		return fmt.Errorf("canary min products (CANARY_MIN_PRODUCTS) must be at least 1")
+	}
+	for name, q := range c.Canary.Queries {
+		if _, ok := c.Scrapers[name]; !ok {
+			return fmt.Errorf("canary query for unknown scraper %q", name)
+		}
+		if strings.TrimSpace(q.Query) == "" {
+			return fmt.Errorf("canary query for %s cannot be empty", name)
+		}
+	}
+
+	if c.Mock.Products < 1 || c.Mock.Products > 50 {
+		return fmt.Errorf("mock products (MOCK_PRODUCTS) must be between 1 and 50")
+	}
+	if c.Mock.Latency < 0 {
+		return fmt.Errorf("mock latency (MOCK_LATENCY_MS) cannot be negative")
+	}
+
+	for name, sc := range c.Scrapers {
+		if sc.Parallelism <= 0 {
+			return fmt.Errorf("scraper %s: parallelism must be positive", name)
+		}
+		if sc.Delay < 0 {
+			return fmt.Errorf("scraper %s: delay cannot be negative", name)
+		}
+		if sc.CacheTTL < 0 {
+			return fmt.Errorf("scraper %s: cache ttl cannot be negative", name)
+		}
+		for i, w := range sc.Maintenance {
+			if err := w.validate(); err != nil {
+				return fmt.Errorf("scraper %s: maintenance window %d: %v", name, i+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Scraper returns the settings for a scraper, falling back to defaults for
+// names that aren't configured.
+func (c *Config) Scraper(name string) ScraperConfig {
+	if sc, ok := c.Scrapers[name]; ok {
+		return sc
+	}
+	return ScraperConfig{
+		Enabled:     true,
+		Delay:       2 * time.Second,
+		Parallelism: 1,
+		UserAgent:   defaultUserAgent,
+		RetryDelay:  2 * time.Second,
+	}
+}
+
+func envString(key string, dst *string) {
+	if v := os.Getenv(key); v != "" {
+		*dst = v
+	}
+}
+
+func envList(key string, dst *[]string) {
+	v := os.Getenv(key)
+	if v == "" {
+		return
+	}
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	*dst = items
+}
+
+func envInts(key string, dst *[]int) {
+	v := os.Getenv(key)
+	if v == "" {
+		return
+	}
+	var items []int
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		n, err := strconv.Atoi(item)
+		if err != nil {
+			log.Warn().Err(err).Msgf("Ignoring invalid %s=%q", key, v)
+			return
+		}
+		items = append(items, n)
+	}
+	*dst = items
+}
+
+func envInt(key string, dst *int) {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			*dst = n
+		} else {
+			log.Warn().Err(err).Msgf("Ignoring invalid %s=%q", key, v)
+		}
+	}
+}
+
+func envFloat(key string, dst *float64) {
+	if v := os.Getenv(key); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			*dst = f
+		} else {
+			log.Warn().Err(err).Msgf("Ignoring invalid %s=%q", key, v)
+		}
+	}
+}
+
+func envBool(key string, dst *bool) {
+	if v := os.Getenv(key); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			*dst = b
+		} else {
+			log.Warn().Err(err).Msgf("Ignoring invalid %s=%q", key, v)
+		}
+	}
+}
+
+func envSeconds(key string, dst *time.Duration) {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			*dst = time.Duration(n) * time.Second
+		} else {
+			log.Warn().Err(err).Msgf("Ignoring invalid %s=%q", key, v)
+		}
+	}
+}
+
+func envDays(key string, dst *time.Duration) {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			*dst = time.Duration(n) * 24 * time.Hour
+		} else {
+			log.Warn().Err(err).Msgf("Ignoring invalid %s=%q", key, v)
+		}
+	}
+}
+
+func envMillis(key string, dst *time.Duration) {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			*dst = time.Duration(n) * time.Millisecond
+		} else {
+			log.Warn().Err(err).Msgf("Ignoring invalid %s=%q", key, v)
+		}
+	}
+}
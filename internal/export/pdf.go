@@ -0,0 +1,255 @@
+package export
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+)
+
+// A small PDF 1.4 writer: A4 pages of Helvetica text, rectangles, lines
+// and link annotations, which is all a comparison sheet needs.
+
+const (
+	pageWidth  = 595.0
+	pageHeight = 842.0
+)
+
+// Fonts, as named in page resources
+const (
+	fontRegular = "F1"
+	fontBold    = "F2"
+)
+
+type pdfPage struct {
+	content bytes.Buffer
+	links   []pdfLink
+}
+
+type pdfLink struct {
+	x, y, w, h float64
+	url        string
+}
+
+type pdfDocument struct {
+	title string
+	pages []*pdfPage
+}
+
+func (d *pdfDocument) addPage() *pdfPage {
+	page := &pdfPage{}
+	d.pages = append(d.pages, page)
+	return page
+}
+
+// text writes s with its baseline at x, y (from the bottom left) in gray,
+// 0 for black.
+func (p *pdfPage) text(x, y float64, font string, size, gray float64, s string) {
+	fmt.Fprintf(&p.content, "BT %.3f g /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", gray, font, size, x, y, pdfString(s))
+}
+
+// rect fills a rectangle in the given RGB color.
+func (p *pdfPage) rect(x, y, w, h float64, r, g, b float64) {
+	fmt.Fprintf(&p.content, "%.3f %.3f %.3f rg %.2f %.2f %.2f %.2f re f\n", r, g, b, x, y, w, h)
+}
+
+func (p *pdfPage) line(x1, y1, x2, y2, gray float64) {
+	fmt.Fprintf(&p.content, "%.3f G 0.5 w %.2f %.2f m %.2f %.2f l S\n", gray, x1, y1, x2, y2)
+}
+
+// link makes a rectangle open url when clicked.
+func (p *pdfPage) link(x, y, w, h float64, url string) {
+	p.links = append(p.links, pdfLink{x: x, y: y, w: w, h: h, url: url})
+}
+
+// qr draws code with its bottom left corner at x, y, side points wide.
+func (p *pdfPage) qr(code *qrCode, x, y, side float64) {
+	module := side / float64(code.size)
+	p.content.WriteString("0 g\n")
+	for row := 0; row < code.size; row++ {
+		// One rectangle per run of dark modules
+		for col := 0; col < code.size; col++ {
+			if !code.modules[row][col] {
+				continue
+			}
+			run := 1
+			for col+run < code.size && code.modules[row][col+run] {
+				run++
+			}
+			fmt.Fprintf(&p.content, "%.3f %.3f %.3f %.3f re\n", x+float64(col)*module, y+side-float64(row+1)*module, float64(run)*module, module)
+			col += run
+		}
+	}
+	p.content.WriteString("f\n")
+}
+
+// bytes writes the document: catalog, page tree, fonts, then each page with
+// its content stream and link annotations, and the cross-reference table.
+func (d *pdfDocument) bytes() []byte {
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) int {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+		return len(offsets)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objects 1-5 are fixed; page objects follow, each with its content
+	// stream and annotations right after it
+	pageIDs := make([]string, len(d.pages))
+	next := 6
+	for i, page := range d.pages {
+		pageIDs[i] = fmt.Sprintf("%d 0 R", next)
+		next += 2 + len(page.links)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(pageIDs, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	info := object(fmt.Sprintf("<< /Title (%s) /Producer (price-comparison-api) >>", pdfString(d.title)))
+
+	for _, page := range d.pages {
+		id := len(offsets) + 1
+		annots := make([]string, len(page.links))
+		for i := range page.links {
+			annots[i] = fmt.Sprintf("%d 0 R", id+2+i)
+		}
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /%s 3 0 R /%s 4 0 R >> >> /Contents %d 0 R /Annots [%s] >>",
+			pageWidth, pageHeight, fontRegular, fontBold, id+1, strings.Join(annots, " ")))
+		var stream bytes.Buffer
+		w := zlib.NewWriter(&stream)
+		w.Write(page.content.Bytes())
+		w.Close()
+		object(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", stream.Len(), stream.String()))
+		for _, l := range page.links {
+			object(fmt.Sprintf("<< /Type /Annot /Subtype /Link /Rect [%.2f %.2f %.2f %.2f] /Border [0 0 0] /A << /S /URI /URI (%s) >> >>",
+				l.x, l.y, l.x+l.w, l.y+l.h, pdfString(l.url)))
+		}
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, info, xref)
+	return out.Bytes()
+}
+
+// winAnsi maps the characters WinAnsiEncoding has outside Latin-1.
+var winAnsi = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8A, '‹': 0x8B, 'Œ': 0x8C, 'Ž': 0x8E, '‘': 0x91,
+	'’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '˜': 0x98,
+	'™': 0x99, 'š': 0x9A, '›': 0x9B, 'œ': 0x9C, 'ž': 0x9E, 'Ÿ': 0x9F,
+}
+
+// substitutes stand in for characters the standard fonts don't have.
+var substitutes = map[rune]string{
+	'₹': "Rs.", '￥': "¥", '₩': "KRW ", '₺': "TL", '₫': "VND", 'ł': "l",
+	'\u202f': "\u00a0", '★': "*", '☆': "*",
+}
+
+// pdfString encodes s in WinAnsiEncoding and escapes it for a literal
+// string. Characters the encoding lacks become '?'.
+func pdfString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if sub, ok := substitutes[r]; ok {
+			b.WriteString(pdfString(sub))
+			continue
+		}
+		var c byte
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+			continue
+		case r == '\n' || r == '\r' || r == '\t':
+			c = ' '
+		case r >= 0x20 && r < 0x7F:
+			c = byte(r)
+		case r >= 0xA0 && r <= 0xFF:
+			c = byte(r)
+		default:
+			var ok bool
+			if c, ok = winAnsi[r]; !ok {
+				c = '?'
+			}
+		}
+		if c >= 0x80 {
+			fmt.Fprintf(&b, "\\%03o", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// helveticaWidths are Helvetica's glyph widths for ASCII 32-126, in
+// thousandths of the font size.
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+// textWidth estimates how wide s is set in font at size; bold glyphs are
+// taken to be a little wider and others as wide as a digit.
+func textWidth(s, font string, size float64) float64 {
+	total := 0
+	for _, r := range s {
+		if r >= 32 && r <= 126 {
+			total += helveticaWidths[r-32]
+		} else {
+			total += 556
+		}
+	}
+	width := float64(total) * size / 1000
+	if font == fontBold {
+		width *= 1.06
+	}
+	return width
+}
+
+// fit shortens s with an ellipsis until it is at most width wide.
+func fit(s, font string, size, width float64) string {
+	if textWidth(s, font, size) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && textWidth(string(runes)+"…", font, size) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return strings.TrimSpace(string(runes)) + "…"
+}
+
+// wrap breaks s into at most lines lines no wider than width, the last one
+// shortened when the text doesn't fit.
+func wrap(s, font string, size, width float64, lines int) []string {
+	var result []string
+	current := ""
+	words := strings.Fields(s)
+	for i, word := range words {
+		candidate := strings.TrimSpace(current + " " + word)
+		if current == "" || textWidth(candidate, font, size) <= width {
+			current = candidate
+			continue
+		}
+		if len(result) == lines-1 {
+			current = strings.Join(append([]string{current}, words[i:]...), " ")
+			break
+		}
+		result = append(result, current)
+		current = word
+	}
+	if current != "" {
+		result = append(result, fit(current, font, size, width))
+	}
+	return result
+}
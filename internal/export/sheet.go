@@ -0,0 +1,215 @@
+// Package export renders search results for use outside the API: a
+// printable PDF comparison sheet with a QR code per listing, for sharing a
+// comparison offline.
+package export
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/pricefmt"
+	"price-comparison-api/internal/productid"
+)
+
+// Sheet is a search's top results as a comparison sheet.
+type Sheet struct {
+	Query   string
+	Country string
+	// Total is how many products the search found
+	Total     int
+	Products  []models.Product
+	Generated time.Time
+}
+
+// Layout, in points
+const (
+	margin    = 40.0
+	rowHeight = 92.0
+	qrSide    = 72.0
+	headerEnd = pageHeight - 96
+	footerTop = 60.0
+	nameWidth = 290.0
+	priceEdge = 470.0
+)
+
+// PDF renders the sheet: a header naming the search, then one row per
+// product with its price, rating and stock, and a QR code linking to the
+// listing.
+func (s Sheet) PDF() []byte {
+	doc := &pdfDocument{title: "Price comparison: " + s.Query}
+	lowest := s.lowest()
+
+	perPage := int(math.Floor((headerEnd - footerTop) / rowHeight))
+	var page *pdfPage
+	y := 0.0
+	for i, p := range s.Products {
+		if i%perPage == 0 {
+			page = doc.addPage()
+			s.header(page)
+			y = headerEnd
+		}
+		s.row(page, i+1, p, i == lowest, y)
+		y -= rowHeight
+	}
+	if len(doc.pages) == 0 {
+		page = doc.addPage()
+		s.header(page)
+		page.text(margin, headerEnd-24, fontRegular, 11, 0.3, "No products matched this search.")
+	}
+
+	for i, page := range doc.pages {
+		s.footer(page, i+1, len(doc.pages))
+	}
+	return doc.bytes()
+}
+
+func (s Sheet) header(page *pdfPage) {
+	top := pageHeight - margin
+	page.text(margin, top-18, fontBold, 18, 0, fit("Price comparison: "+s.Query, fontBold, 18, pageWidth-2*margin))
+
+	var details []string
+	if s.Country != "" {
+		details = append(details, strings.ToUpper(s.Country))
+	}
+	details = append(details, fmt.Sprintf("top %d of %d results", len(s.Products), s.Total))
+	details = append(details, s.Generated.UTC().Format("2 Jan 2006 15:04 MST"))
+	page.text(margin, top-36, fontRegular, 9, 0.4, strings.Join(details, " · "))
+
+	page.text(margin, headerEnd+6, fontBold, 8, 0.4, "PRODUCT")
+	page.text(rightAligned("PRICE", fontBold, 8, priceEdge), headerEnd+6, fontBold, 8, 0.4, "PRICE")
+	page.text(pageWidth-margin-qrSide, headerEnd+6, fontBold, 8, 0.4, "SCAN TO OPEN")
+	page.line(margin, headerEnd, pageWidth-margin, headerEnd, 0.6)
+}
+
+func (s Sheet) footer(page *pdfPage, number, total int) {
+	note := "Prices and stock as scraped when the sheet was made; check with the retailer before buying."
+	page.line(margin, footerTop-14, pageWidth-margin, footerTop-14, 0.8)
+	page.text(margin, footerTop-28, fontRegular, 7.5, 0.45, note)
+	counter := fmt.Sprintf("Page %d of %d", number, total)
+	page.text(rightAligned(counter, fontRegular, 7.5, pageWidth-margin), footerTop-28, fontRegular, 7.5, 0.45, counter)
+}
+
+// row draws a product below top.
+func (s Sheet) row(page *pdfPage, rank int, p models.Product, lowest bool, top float64) {
+	page.text(margin, top-20, fontBold, 13, 0.5, fmt.Sprintf("%d", rank))
+
+	left := margin + 24
+	lines := wrap(p.Name, fontBold, 10, nameWidth, 2)
+	for i, line := range lines {
+		page.text(left, top-18-float64(i)*13, fontBold, 10, 0, line)
+	}
+	y := top - 18 - float64(len(lines))*13 - 4
+
+	seller := []string{strings.ReplaceAll(p.Source, "_", " ")}
+	if p.Merchant != "" {
+		seller = append(seller, "sold by "+p.Merchant)
+	}
+	if p.Condition != "" && p.Condition != models.ConditionNew {
+		seller = append(seller, p.Condition)
+	}
+	page.text(left, y, fontRegular, 8.5, 0.35, fit(strings.Join(seller, " · "), fontRegular, 8.5, nameWidth))
+	y -= 12
+
+	if rating := ratingText(p); rating != "" {
+		page.text(left, y, fontRegular, 8.5, 0.35, rating)
+		y -= 12
+	}
+	page.text(left, y, fontBold, 8.5, stockGray(p), stockText(p))
+
+	price := s.price(p)
+	page.text(rightAligned(price, fontBold, 15, priceEdge), top-22, fontBold, 15, 0, price)
+	if was := discountText(p); was != "" {
+		page.text(rightAligned(was, fontRegular, 8, priceEdge), top-36, fontRegular, 8, 0.4, was)
+	}
+	if lowest {
+		label := "LOWEST PRICE"
+		width := textWidth(label, fontBold, 7) + 10
+		page.rect(priceEdge-width, top-56, width, 13, 0.13, 0.55, 0.27)
+		page.text(priceEdge-width+5, top-52, fontBold, 7, 1, label)
+	}
+
+	if p.URL != "" {
+		link := productid.CleanURL(p.URL)
+		x, qrY := pageWidth-margin-qrSide, top-qrSide-8
+		if code := encodeQR([]byte(link)); code != nil {
+			page.qr(code, x, qrY, qrSide)
+			page.link(x, qrY, qrSide, qrSide, link)
+		}
+		page.link(left, y-4, nameWidth, top-y, link)
+	}
+
+	page.line(margin, top-rowHeight+4, pageWidth-margin, top-rowHeight+4, 0.88)
+}
+
+// price formats a product's price for the sheet's country, falling back to
+// the retailer's text when it couldn't be parsed.
+func (s Sheet) price(p models.Product) string {
+	if p.PriceValue > 0 {
+		return pricefmt.Localize(p.PriceValue, p.Currency, s.Country)
+	}
+	if p.Price != "" {
+		return p.Price
+	}
+	return "—"
+}
+
+// lowest is the index of the cheapest in-stock product, or -1.
+func (s Sheet) lowest() int {
+	best := -1
+	for i, p := range s.Products {
+		if !p.InStock || p.PriceValue <= 0 {
+			continue
+		}
+		if best < 0 || p.PriceValue < s.Products[best].PriceValue {
+			best = i
+		}
+	}
+	return best
+}
+
+func ratingText(p models.Product) string {
+	if p.RatingValue <= 0 {
+		return ""
+	}
+	text := fmt.Sprintf("Rated %.1f/5", p.RatingValue)
+	if p.Reviews != "" {
+		text += " from " + p.Reviews + " reviews"
+	}
+	return text
+}
+
+func discountText(p models.Product) string {
+	switch {
+	case p.OriginalPrice != "" && p.Discount > 0:
+		return fmt.Sprintf("was %s · %.0f%% off", p.OriginalPrice, p.Discount)
+	case p.OriginalPrice != "":
+		return "was " + p.OriginalPrice
+	case p.Discount > 0:
+		return fmt.Sprintf("%.0f%% off", p.Discount)
+	}
+	return ""
+}
+
+func stockText(p models.Product) string {
+	switch {
+	case p.Preorder:
+		return "Pre-order"
+	case p.InStock:
+		return "In stock"
+	}
+	return "Out of stock"
+}
+
+func stockGray(p models.Product) float64 {
+	if p.InStock {
+		return 0.2
+	}
+	return 0.55
+}
+
+func rightAligned(s, font string, size, edge float64) float64 {
+	return edge - textWidth(s, font, size)
+}
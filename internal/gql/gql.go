@@ -0,0 +1,251 @@
+// Package gql serves the API as GraphQL: searches, catalog offers and price
+// history under one schema (schema.graphql), so clients fetch exactly the
+// fields they need.
+package gql
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/graph-gophers/graphql-go"
+	"price-comparison-api/internal/apierr"
+	"price-comparison-api/internal/catalog"
+	"price-comparison-api/internal/history"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/services"
+)
+
+//go:embed schema.graphql
+var schema string
+
+// maxDepth bounds how deeply a query nests, so a product's offers' history
+// is fine but a runaway query is refused before it runs.
+const maxDepth = 8
+
+// Options are what the schema resolves against. Catalog and History may be
+// nil when those stores are disabled; their fields then resolve empty.
+type Options struct {
+	Search  *services.SearchService
+	Catalog *catalog.Store
+	History *history.Store
+	// Rates are the current exchange rates, per USD
+	Rates          map[string]float64
+	DefaultCountry string
+	// PrepareSearch, when set, fills in a search's parameters before it runs,
+	// such as from the caller's preference profile
+	PrepareSearch func(ctx context.Context, params *models.SearchParams)
+}
+
+// NewSchema parses the schema and binds it to opts.
+func NewSchema(opts Options) *graphql.Schema {
+	return graphql.MustParseSchema(schema, &resolver{opts: opts}, graphql.MaxDepth(maxDepth))
+}
+
+type resolver struct {
+	opts Options
+}
+
+type searchFilter struct {
+	MinPrice                  *float64
+	MaxPrice                  *float64
+	InStock                   *bool
+	MinRating                 *float64
+	MinDiscount               *float64
+	Source                    *string
+	Category                  *string
+	RequiresMembership        *bool
+	Strict                    *bool
+	Seller                    *string
+	ExcludeMarketplaceSellers *bool
+	Condition                 *string
+}
+
+type searchSort struct {
+	Field string
+	Order *string
+}
+
+func (r *resolver) Search(ctx context.Context, args struct {
+	Query   string
+	Country *string
+	Page    *int32
+	Limit   *int32
+	Filter  *searchFilter
+	Sort    *searchSort
+}) (*searchResult, error) {
+	params := models.SearchParams{Query: args.Query}
+	if args.Country != nil {
+		params.Country = *args.Country
+	}
+	if args.Page != nil {
+		params.Page = int(*args.Page)
+	}
+	if args.Limit != nil {
+		params.Limit = int(*args.Limit)
+	}
+	if f := args.Filter; f != nil {
+		params.Filters = &models.Filters{
+			MinPrice:                  deref(f.MinPrice),
+			MaxPrice:                  deref(f.MaxPrice),
+			InStock:                   f.InStock,
+			MinRating:                 deref(f.MinRating),
+			MinDiscount:               deref(f.MinDiscount),
+			Source:                    deref(f.Source),
+			Category:                  deref(f.Category),
+			RequiresMembership:        f.RequiresMembership,
+			Strict:                    f.Strict,
+			Seller:                    deref(f.Seller),
+			ExcludeMarketplaceSellers: f.ExcludeMarketplaceSellers != nil && *f.ExcludeMarketplaceSellers,
+			Condition:                 deref(f.Condition),
+		}
+	}
+	if s := args.Sort; s != nil {
+		params.Sort = &models.Sort{Field: s.Field, Order: "asc"}
+		if s.Order != nil {
+			params.Sort.Order = *s.Order
+		}
+	}
+	if r.opts.PrepareSearch != nil {
+		r.opts.PrepareSearch(ctx, &params)
+	}
+
+	resp, err := r.opts.Search.SearchProducts(ctx, params)
+	if err != nil {
+		return nil, wrapError(err)
+	}
+	country := params.Country
+	if country == "" {
+		country = r.opts.DefaultCountry
+	}
+	return &searchResult{resp: resp, country: country, root: r}, nil
+}
+
+func (r *resolver) Offer(args struct{ ID graphql.ID }) (*offerResolver, error) {
+	if r.opts.Catalog == nil {
+		return nil, nil
+	}
+	offer, err := r.opts.Catalog.Offer(string(args.ID))
+	if errors.Is(err, catalog.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &offerResolver{offer: *offer, root: r}, nil
+}
+
+func (r *resolver) Offers(args struct{ ProductID graphql.ID }) ([]*offerResolver, error) {
+	return r.catalogOffers(string(args.ProductID))
+}
+
+func (r *resolver) PriceHistory(args struct {
+	Key      string
+	Currency *string
+}) (*priceHistory, error) {
+	return r.history(args.Key, args.Currency)
+}
+
+// catalogOffers returns a catalog product's offers, or none when the
+// product or the catalog doesn't exist.
+func (r *resolver) catalogOffers(productID string) ([]*offerResolver, error) {
+	offers := []*offerResolver{}
+	if r.opts.Catalog == nil || productID == "" {
+		return offers, nil
+	}
+	view, err := r.opts.Catalog.Get(productID)
+	if errors.Is(err, catalog.ErrNotFound) {
+		return offers, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, offer := range view.Offers {
+		offers = append(offers, &offerResolver{offer: offer, root: r})
+	}
+	return offers, nil
+}
+
+// history returns the price history kept under key in currency, or nil
+// when none was recorded.
+func (r *resolver) history(key string, currency *string) (*priceHistory, error) {
+	if r.opts.History == nil {
+		return nil, nil
+	}
+	to := "USD"
+	if currency != nil {
+		to = strings.ToUpper(*currency)
+	}
+	if _, ok := r.opts.Rates[to]; !ok {
+		return nil, wrapError(apierr.Validation("invalid_currency", "unsupported currency: "+to))
+	}
+	entry, err := r.opts.History.Get(key)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	book, err := r.opts.History.Rates()
+	if err != nil {
+		return nil, err
+	}
+	series, err := book.Convert(entry, to, r.opts.Rates)
+	if err != nil {
+		return nil, err
+	}
+	return &priceHistory{series: series, availability: entry.Availability(time.Now())}, nil
+}
+
+// apiError carries an apierr error's type and code into the GraphQL error's
+// extensions, for clients to branch on as they do on REST error responses.
+type apiError struct {
+	err *apierr.Error
+}
+
+func (e apiError) Error() string { return e.err.Message }
+
+func (e apiError) Extensions() map[string]interface{} {
+	return map[string]interface{}{
+		"type": apierr.Type(e.err),
+		"code": e.err.Code,
+	}
+}
+
+func wrapError(err error) error {
+	e := apierr.Classify(err)
+	if e == nil {
+		return err
+	}
+	return apiError{err: e}
+}
+
+func deref[T any](p *T) T {
+	var zero T
+	if p == nil {
+		return zero
+	}
+	return *p
+}
+
+// optional returns a nullable string field: nil for an empty s.
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func optionalInt(n int) *int32 {
+	if n == 0 {
+		return nil
+	}
+	v := int32(n)
+	return &v
+}
+
+func optionalFloat(f float64) *float64 {
+	if f == 0 {
+		return nil
+	}
+	return &f
+}
@@ -0,0 +1,173 @@
+package gql
+
+import (
+	"github.com/graph-gophers/graphql-go"
+	"price-comparison-api/internal/catalog"
+	"price-comparison-api/internal/history"
+	"price-comparison-api/internal/models"
+)
+
+type searchResult struct {
+	resp *models.SearchResponse
+	// country is the one searched, which keys the products' histories
+	country string
+	root    *resolver
+}
+
+func (s *searchResult) Query() string            { return s.resp.Query }
+func (s *searchResult) NormalizedQuery() *string { return optional(s.resp.NormalizedQuery) }
+func (s *searchResult) Total() int32             { return int32(s.resp.Total) }
+func (s *searchResult) Page() int32              { return int32(s.resp.Page) }
+func (s *searchResult) Limit() int32             { return int32(s.resp.Limit) }
+func (s *searchResult) TotalPages() int32        { return int32(s.resp.TotalPages) }
+func (s *searchResult) Duration() string         { return s.resp.Duration }
+func (s *searchResult) ResultSetID() *string     { return optional(s.resp.ResultSetID) }
+
+func (s *searchResult) Products() []*productResolver {
+	products := make([]*productResolver, len(s.resp.Products))
+	for i := range s.resp.Products {
+		products[i] = &productResolver{p: &s.resp.Products[i], country: s.country, root: s.root}
+	}
+	return products
+}
+
+type productResolver struct {
+	p       *models.Product
+	country string
+	root    *resolver
+}
+
+func (r *productResolver) ID() graphql.ID            { return graphql.ID(r.p.ID) }
+func (r *productResolver) SourceProductID() *string  { return optional(r.p.SourceProductID) }
+func (r *productResolver) Name() string              { return r.p.Name }
+func (r *productResolver) Price() *float64           { return optionalFloat(r.p.PriceValue) }
+func (r *productResolver) PriceText() string         { return r.p.Price }
+func (r *productResolver) Currency() string          { return r.p.Currency }
+func (r *productResolver) URL() string               { return r.p.URL }
+func (r *productResolver) Image() *string            { return optional(r.p.Image) }
+func (r *productResolver) Rating() *float64          { return optionalFloat(r.p.RatingValue) }
+func (r *productResolver) Reviews() *string          { return optional(r.p.Reviews) }
+func (r *productResolver) ReviewCount() *int32       { return optionalInt(r.p.ReviewCount) }
+func (r *productResolver) Source() string            { return r.p.Source }
+func (r *productResolver) Merchant() *string         { return optional(r.p.Merchant) }
+func (r *productResolver) SellerRating() *string     { return optional(r.p.SellerRating) }
+func (r *productResolver) MarketplaceSeller() bool   { return r.p.MarketplaceSeller }
+func (r *productResolver) Condition() *string        { return optional(r.p.Condition) }
+func (r *productResolver) InStock() bool             { return r.p.InStock }
+func (r *productResolver) Preorder() bool            { return r.p.Preorder }
+func (r *productResolver) DiscountPercent() *float64 { return optionalFloat(r.p.Discount) }
+func (r *productResolver) OriginalPrice() *string    { return optional(r.p.OriginalPrice) }
+func (r *productResolver) DealBadge() *string        { return optional(r.p.DealBadge) }
+func (r *productResolver) RequiresMembership() bool  { return r.p.RequiresMembership }
+func (r *productResolver) ScrapedAt() graphql.Time   { return graphql.Time{Time: r.p.ScrapedAt} }
+
+func (r *productResolver) CatalogID() *graphql.ID {
+	if r.p.CatalogID == "" {
+		return nil
+	}
+	id := graphql.ID(r.p.CatalogID)
+	return &id
+}
+
+func (r *productResolver) Offers() ([]*offerResolver, error) {
+	return r.root.catalogOffers(r.p.CatalogID)
+}
+
+func (r *productResolver) History(args struct{ Currency *string }) (*priceHistory, error) {
+	return r.root.history(history.Key(r.country, *r.p), args.Currency)
+}
+
+type offerResolver struct {
+	offer catalog.Offer
+	root  *resolver
+}
+
+func (r *offerResolver) ID() graphql.ID          { return graphql.ID(r.offer.ID) }
+func (r *offerResolver) ProductID() graphql.ID   { return graphql.ID(r.offer.ProductID) }
+func (r *offerResolver) Key() string             { return r.offer.Key }
+func (r *offerResolver) Source() string          { return r.offer.Source }
+func (r *offerResolver) Merchant() *string       { return optional(r.offer.Merchant) }
+func (r *offerResolver) Country() string         { return r.offer.Country }
+func (r *offerResolver) Name() string            { return r.offer.Name }
+func (r *offerResolver) URL() string             { return r.offer.URL }
+func (r *offerResolver) Image() *string          { return optional(r.offer.Image) }
+func (r *offerResolver) Price() float64          { return r.offer.Price }
+func (r *offerResolver) Currency() string        { return r.offer.Currency }
+func (r *offerResolver) InStock() bool           { return r.offer.InStock }
+func (r *offerResolver) Condition() *string      { return optional(r.offer.Condition) }
+func (r *offerResolver) FirstSeen() graphql.Time { return graphql.Time{Time: r.offer.FirstSeen} }
+func (r *offerResolver) LastSeen() graphql.Time  { return graphql.Time{Time: r.offer.LastSeen} }
+func (r *offerResolver) TimesSeen() int32        { return int32(r.offer.TimesSeen) }
+
+func (r *offerResolver) History(args struct{ Currency *string }) (*priceHistory, error) {
+	return r.root.history(r.offer.Key, args.Currency)
+}
+
+type priceHistory struct {
+	series       *history.Series
+	availability *history.Availability
+}
+
+func (h *priceHistory) Key() string            { return h.series.Key }
+func (h *priceHistory) Name() string           { return h.series.Name }
+func (h *priceHistory) URL() string            { return h.series.URL }
+func (h *priceHistory) Source() string         { return h.series.Source }
+func (h *priceHistory) Country() string        { return h.series.Country }
+func (h *priceHistory) Currency() string       { return h.series.Currency }
+func (h *priceHistory) SourceCurrency() string { return h.series.SourceCurrency }
+
+func (h *priceHistory) Points() []*pricePoint {
+	points := make([]*pricePoint, len(h.series.Points))
+	for i := range h.series.Points {
+		points[i] = &pricePoint{&h.series.Points[i]}
+	}
+	return points
+}
+
+func (h *priceHistory) Lowest() *float64 {
+	var lowest *float64
+	for i := range h.series.Points {
+		if p := &h.series.Points[i].Price; lowest == nil || *p < *lowest {
+			lowest = p
+		}
+	}
+	return lowest
+}
+
+func (h *priceHistory) Highest() *float64 {
+	var highest *float64
+	for i := range h.series.Points {
+		if p := &h.series.Points[i].Price; highest == nil || *p > *highest {
+			highest = p
+		}
+	}
+	return highest
+}
+
+func (h *priceHistory) Availability() *availability {
+	if h.availability == nil {
+		return nil
+	}
+	return &availability{h.availability}
+}
+
+type pricePoint struct {
+	p *history.ConvertedPoint
+}
+
+func (p *pricePoint) Price() float64       { return p.p.Price }
+func (p *pricePoint) SourcePrice() float64 { return p.p.SourcePrice }
+func (p *pricePoint) InStock() bool        { return p.p.InStock }
+func (p *pricePoint) Preorder() bool       { return p.p.Preorder }
+func (p *pricePoint) At() graphql.Time     { return graphql.Time{Time: p.p.At} }
+func (p *pricePoint) RateDay() *string     { return optional(p.p.RateDay) }
+
+type availability struct {
+	a *history.Availability
+}
+
+func (a *availability) InStock() bool         { return a.a.InStock }
+func (a *availability) Since() graphql.Time   { return graphql.Time{Time: a.a.Since} }
+func (a *availability) Restocks() int32       { return int32(a.a.Restocks) }
+func (a *availability) SellOuts() int32       { return int32(a.a.SellOuts) }
+func (a *availability) InStockShare() float64 { return a.a.InStockShare }
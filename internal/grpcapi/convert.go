@@ -0,0 +1,180 @@
+package grpcapi
+
+import (
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/services"
+	pb "price-comparison-api/pkg/pricecomparisonpb"
+)
+
+func searchParams(req *pb.SearchRequest) models.SearchParams {
+	params := models.SearchParams{
+		Query:       req.GetQuery(),
+		Country:     req.GetCountry(),
+		Page:        int(req.GetPage()),
+		Limit:       int(req.GetLimit()),
+		ResultSetID: req.GetResultSetId(),
+	}
+	if f := req.GetFilters(); f != nil {
+		params.Filters = &models.Filters{
+			MinPrice:           f.GetMinPrice(),
+			MaxPrice:           f.GetMaxPrice(),
+			InStock:            f.InStock,
+			MinRating:          f.GetMinRating(),
+			Source:             f.GetSource(),
+			MinDiscount:        f.GetMinDiscount(),
+			RequiresMembership: f.RequiresMembership,
+			Category:           f.GetCategory(),
+			Strict:             f.Strict,
+		}
+	}
+	if s := req.GetSort(); s != nil && s.GetField() != "" {
+		params.Sort = &models.Sort{Field: s.GetField(), Order: s.GetOrder()}
+		if params.Sort.Order == "" {
+			params.Sort.Order = "asc"
+		}
+	}
+	if p := req.GetPreferences(); p != nil {
+		params.Preferences = &models.Preferences{
+			PreferredRetailers: p.GetPreferredRetailers(),
+			ExcludedSellers:    p.GetExcludedSellers(),
+			Currency:           p.GetCurrency(),
+			SafeSearch:         p.GetSafeSearch(),
+		}
+	}
+	return params
+}
+
+func searchResponse(r *models.SearchResponse) *pb.SearchResponse {
+	resp := &pb.SearchResponse{
+		Query:           r.Query,
+		Products:        products(r.Products),
+		Total:           int32(r.Total),
+		Page:            int32(r.Page),
+		Limit:           int32(r.Limit),
+		TotalPages:      int32(r.TotalPages),
+		Source:          r.Source,
+		Duration:        r.Duration,
+		NormalizedQuery: r.NormalizedQuery,
+		ResultSetId:     r.ResultSetID,
+		Personalized:    r.Personalized,
+	}
+	for i := range r.Attribution {
+		resp.Attribution = append(resp.Attribution, attribution(&r.Attribution[i]))
+	}
+	return resp
+}
+
+func products(list []models.Product) []*pb.Product {
+	out := make([]*pb.Product, len(list))
+	for i := range list {
+		out[i] = product(&list[i])
+	}
+	return out
+}
+
+func product(p *models.Product) *pb.Product {
+	out := &pb.Product{
+		Id:                 p.ID,
+		SourceProductId:    p.SourceProductID,
+		CatalogId:          p.CatalogID,
+		Name:               p.Name,
+		Title:              p.Title,
+		DisplayName:        p.DisplayName,
+		Price:              p.Price,
+		PriceValue:         p.PriceValue,
+		Currency:           p.Currency,
+		Url:                p.URL,
+		Image:              p.Image,
+		Rating:             p.Rating,
+		RatingValue:        p.RatingValue,
+		Reviews:            p.Reviews,
+		Source:             p.Source,
+		Merchant:           p.Merchant,
+		ScrapedAt:          timestamp(p.ScrapedAt),
+		InStock:            p.InStock,
+		Description:        p.Description,
+		OriginalPrice:      p.OriginalPrice,
+		DiscountPercent:    p.Discount,
+		DealBadge:          p.DealBadge,
+		Preorder:           p.Preorder,
+		SourcePrice:        p.SourcePrice,
+		SourceCurrency:     p.SourceCurrency,
+		RequiresMembership: p.RequiresMembership,
+		Condition:          p.Condition,
+		SellerRating:       p.SellerRating,
+		Category:           p.Category,
+	}
+	if p.ReleaseDate != nil {
+		out.ReleaseDate = timestamp(*p.ReleaseDate)
+	}
+	for _, o := range p.Offers {
+		out.Offers = append(out.Offers, &pb.Offer{Merchant: o.Merchant, Price: o.Price, PriceValue: o.PriceValue, Url: o.URL})
+	}
+	return out
+}
+
+func productDetail(d *models.ProductDetail) *pb.ProductDetail {
+	out := &pb.ProductDetail{
+		Product:        product(&d.Product),
+		Country:        d.Country,
+		Brand:          d.Brand,
+		Specifications: d.Specifications,
+		Seller:         d.Seller,
+		ShippingCost:   d.ShippingCost,
+		Availability:   d.Availability,
+		Images:         d.Images,
+	}
+	if r := d.ReviewSummary; r != nil {
+		out.ReviewSummary = &pb.ReviewSummary{Snippets: r.Snippets, Sentiment: r.Sentiment, Score: r.Score, Pros: r.Pros, Cons: r.Cons}
+	}
+	if d.Attribution != nil {
+		out.Attribution = attribution(d.Attribution)
+	}
+	return out
+}
+
+func attribution(a *models.Attribution) *pb.Attribution {
+	return &pb.Attribution{
+		Retailer:    a.Retailer,
+		Source:      a.Source,
+		RetrievedAt: timestamp(a.RetrievedAt),
+		TermsUrl:    a.TermsURL,
+		Text:        a.Text,
+		Products:    int32(a.Products),
+	}
+}
+
+var eventTypes = map[string]pb.SearchEvent_Type{
+	services.ProgressSourceStarted:  pb.SearchEvent_SOURCE_STARTED,
+	services.ProgressProductsBatch:  pb.SearchEvent_PRODUCTS_BATCH,
+	services.ProgressSourceFinished: pb.SearchEvent_SOURCE_FINISHED,
+	services.ProgressDone:           pb.SearchEvent_DONE,
+}
+
+func searchEvent(e services.ProgressEvent) *pb.SearchEvent {
+	out := &pb.SearchEvent{
+		Type:    eventTypes[e.Type],
+		Source:  e.Source,
+		Count:   int32(e.Count),
+		Error:   e.Error,
+		Elapsed: e.Elapsed,
+	}
+	if len(e.Products) > 0 {
+		out.Products = products(e.Products)
+	}
+	if e.Response != nil {
+		out.Response = searchResponse(e.Response)
+	}
+	return out
+}
+
+// timestamp is nil for the zero time.
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
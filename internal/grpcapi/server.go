@@ -0,0 +1,226 @@
+// Package grpcapi serves the PriceComparison gRPC service defined in
+// proto/price_comparison.proto, for backend services that want typed stubs
+// and streaming rather than the REST API. It runs the same searches as the
+// HTTP handlers, through the search service.
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"price-comparison-api/internal/apierr"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/scrapers"
+	"price-comparison-api/internal/services"
+	pb "price-comparison-api/pkg/pricecomparisonpb"
+)
+
+// Options configures the gRPC server.
+type Options struct {
+	Search *services.SearchService
+	// Maintenance reports whether the server is in maintenance mode, during
+	// which searches are refused as they are over HTTP
+	Maintenance func() bool
+}
+
+type server struct {
+	pb.UnimplementedPriceComparisonServer
+	opts Options
+}
+
+// NewServer returns a gRPC server with the PriceComparison service
+// registered, logging every call with a request ID.
+func NewServer(cfg config.GRPCConfig, opts Options) *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unaryInterceptor),
+		grpc.ChainStreamInterceptor(streamInterceptor),
+	)
+	pb.RegisterPriceComparisonServer(srv, &server{opts: opts})
+	if cfg.Reflection {
+		reflection.Register(srv)
+	}
+	return srv
+}
+
+func (s *server) SearchProducts(ctx context.Context, req *pb.SearchRequest) (*pb.SearchResponse, error) {
+	if err := s.available(); err != nil {
+		return nil, err
+	}
+	response, err := s.opts.Search.SearchProducts(ctx, searchParams(req))
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return searchResponse(response), nil
+}
+
+func (s *server) GetProduct(ctx context.Context, req *pb.GetProductRequest) (*pb.ProductDetail, error) {
+	if req.GetUrl() == "" {
+		return nil, statusError(apierr.Validation("missing_url", "url is required"))
+	}
+	if _, err := scrapers.DetailScraperFor(req.GetUrl()); err != nil {
+		return nil, statusError(apierr.Validation("invalid_url", err.Error()))
+	}
+	detail, err := s.opts.Search.ProductDetail(ctx, req.GetUrl())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return productDetail(detail), nil
+}
+
+// StreamSearch sends the search's progress events as they happen. A failed
+// search ends the stream with its error instead of a DONE event.
+func (s *server) StreamSearch(req *pb.SearchRequest, stream grpc.ServerStreamingServer[pb.SearchEvent]) error {
+	if err := s.available(); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
+	type result struct {
+		response *pb.SearchResponse
+		err      error
+	}
+	events := make(chan services.ProgressEvent)
+	results := make(chan result, 1)
+	started := time.Now()
+	go func() {
+		response, err := s.opts.Search.SearchProducts(services.WithProgress(ctx, events), searchParams(req))
+		if err != nil {
+			results <- result{err: err}
+			return
+		}
+		results <- result{response: searchResponse(response)}
+	}()
+
+	for {
+		select {
+		case event := <-events:
+			if err := stream.Send(searchEvent(event)); err != nil {
+				// The search stops sending once ctx is cancelled
+				return err
+			}
+		case r := <-results:
+			if r.err != nil {
+				return statusError(r.err)
+			}
+			return stream.Send(&pb.SearchEvent{
+				Type:     pb.SearchEvent_DONE,
+				Count:    int32(len(r.response.Products)),
+				Response: r.response,
+				Elapsed:  time.Since(started).Round(time.Millisecond).String(),
+			})
+		}
+	}
+}
+
+// available refuses searches while maintenance mode is on.
+func (s *server) available() error {
+	if s.opts.Maintenance != nil && s.opts.Maintenance() {
+		return status.Error(codes.Unavailable, "the service is down for maintenance")
+	}
+	return nil
+}
+
+// kindCodes maps apierr kinds to gRPC status codes.
+var kindCodes = []struct {
+	kind error
+	code codes.Code
+}{
+	{apierr.ErrValidation, codes.InvalidArgument},
+	{apierr.ErrUnsupportedCountry, codes.FailedPrecondition},
+	{apierr.ErrBlocked, codes.Unavailable},
+	{apierr.ErrTimeout, codes.DeadlineExceeded},
+	{apierr.ErrUpstream, codes.Unavailable},
+}
+
+// statusError turns err into a gRPC status whose ErrorInfo carries the
+// error's code and type, for clients to branch on as they do on REST error
+// responses.
+func statusError(err error) error {
+	if errors.Is(err, context.Canceled) {
+		return status.Error(codes.Canceled, err.Error())
+	}
+	e := apierr.Classify(err)
+	code := codes.Internal
+	for _, k := range kindCodes {
+		if errors.Is(e, k.kind) {
+			code = k.code
+			break
+		}
+	}
+	st := status.New(code, e.Message)
+	info := &errdetails.ErrorInfo{
+		Reason:   e.Code,
+		Domain:   "price-comparison-api",
+		Metadata: map[string]string{"type": apierr.Type(e)},
+	}
+	if e.Details != "" {
+		info.Metadata["details"] = e.Details
+	}
+	if withInfo, err := st.WithDetails(info); err == nil {
+		st = withInfo
+	}
+	return st.Err()
+}
+
+func unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	ctx, done := startCall(ctx, info.FullMethod)
+	defer func() {
+		if p := recover(); p != nil {
+			err = recovered(ctx, p)
+		}
+		done(err)
+	}()
+	return handler(ctx, req)
+}
+
+func streamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	ctx, done := startCall(ss.Context(), info.FullMethod)
+	defer func() {
+		if p := recover(); p != nil {
+			err = recovered(ctx, p)
+		}
+		done(err)
+	}()
+	return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+}
+
+// startCall stores a logger with a new request ID on ctx, so services and
+// scrapers log with it, and returns the function that logs the finished
+// call.
+func startCall(ctx context.Context, method string) (context.Context, func(error)) {
+	requestID := fmt.Sprintf("%d", time.Now().UnixNano())
+	reqLogger := log.With().Str("request_id", requestID).Logger()
+	start := time.Now()
+	return reqLogger.WithContext(ctx), func(err error) {
+		reqLogger.Info().
+			Str("method", method).
+			Str("code", status.Code(err).String()).
+			Dur("latency", time.Since(start)).
+			Msg("grpc request")
+	}
+}
+
+func recovered(ctx context.Context, p any) error {
+	log.Ctx(ctx).Error().Interface("panic", p).Bytes("stack", debug.Stack()).Msg("gRPC handler panicked")
+	return status.Error(codes.Internal, "internal error")
+}
+
+// contextStream is a ServerStream with the call's logger on its context.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,183 @@
+package history
+
+import (
+	"math"
+	"time"
+)
+
+// maxFlips caps the availability changes kept per product. They are kept
+// apart from price points, which frequent repricing would push them out of.
+const maxFlips = 200
+
+// Flip is a change in a product's availability.
+type Flip struct {
+	InStock bool      `json:"in_stock"`
+	At      time.Time `json:"at"`
+}
+
+// applyAvailability records a flip when inStock differs from the entry's
+// last known availability, and reports whether it did. Entries recorded
+// before flips were kept start from their points.
+func (e *Entry) applyAvailability(inStock bool, now time.Time) bool {
+	if len(e.Flips) == 0 {
+		e.Flips = flipsFromPoints(e.Points)
+	}
+	if n := len(e.Flips); n > 0 && e.Flips[n-1].InStock == inStock {
+		return false
+	}
+	e.Flips = append(e.Flips, Flip{InStock: inStock, At: now})
+	if len(e.Flips) > maxFlips {
+		e.Flips = e.Flips[len(e.Flips)-maxFlips:]
+	}
+	return true
+}
+
+// flips returns the entry's availability changes, oldest first; the first
+// is the availability the product was first seen with.
+func (e *Entry) flips() []Flip {
+	if len(e.Flips) > 0 {
+		return e.Flips
+	}
+	return flipsFromPoints(e.Points)
+}
+
+func flipsFromPoints(points []Point) []Flip {
+	var flips []Flip
+	for _, p := range points {
+		if len(flips) == 0 || flips[len(flips)-1].InStock != p.InStock {
+			flips = append(flips, Flip{InStock: p.InStock, At: p.At})
+		}
+	}
+	return flips
+}
+
+// Period is a stretch of time a product was in or out of stock.
+type Period struct {
+	InStock bool      `json:"in_stock"`
+	From    time.Time `json:"from"`
+	// To is nil for the current period
+	To    *time.Time `json:"to,omitempty"`
+	Hours float64    `json:"hours"`
+}
+
+// Availability is a product's availability timeline at one retailer.
+type Availability struct {
+	InStock bool      `json:"in_stock"`
+	Since   time.Time `json:"since"`
+	// Restocks and SellOuts count the flips back in and out of stock
+	Restocks int `json:"restocks"`
+	SellOuts int `json:"sell_outs"`
+	// InStockShare is the fraction of the tracked time the product was in
+	// stock
+	InStockShare float64  `json:"in_stock_share"`
+	Timeline     []Period `json:"timeline"`
+}
+
+// Availability returns the entry's availability timeline up to now, or nil
+// when nothing was recorded.
+func (e *Entry) Availability(now time.Time) *Availability {
+	flips := e.flips()
+	if len(flips) == 0 {
+		return nil
+	}
+
+	a := &Availability{Timeline: make([]Period, 0, len(flips))}
+	var inStock, total time.Duration
+	for i, f := range flips {
+		end := now
+		period := Period{InStock: f.InStock, From: f.At}
+		if i+1 < len(flips) {
+			end = flips[i+1].At
+			period.To = &end
+		}
+		length := end.Sub(f.At)
+		period.Hours = roundHours(length)
+		a.Timeline = append(a.Timeline, period)
+
+		total += length
+		if f.InStock {
+			inStock += length
+		}
+		if i > 0 {
+			if f.InStock {
+				a.Restocks++
+			} else {
+				a.SellOuts++
+			}
+		}
+	}
+
+	last := flips[len(flips)-1]
+	a.InStock, a.Since = last.InStock, last.At
+	if total > 0 {
+		a.InStockShare = math.Round(float64(inStock)/float64(total)*1000) / 1000
+	} else if last.InStock {
+		a.InStockShare = 1
+	}
+	return a
+}
+
+// WeekdayRestocks is how often products came back in stock on one weekday.
+type WeekdayRestocks struct {
+	Weekday  string `json:"weekday"`
+	Restocks int    `json:"restocks"`
+	// Likelihood is the share of these weekdays, since tracking began, on
+	// which at least one of the products came back in stock
+	Likelihood float64 `json:"likelihood"`
+}
+
+// RestockWeekdays sums up when entries came back in stock, by weekday in
+// the reporting time zone, Monday first.
+func (s *Store) RestockWeekdays(entries []*Entry, now time.Time) []WeekdayRestocks {
+	var start time.Time
+	restockDays := map[string]bool{}
+	var restocks [7]int
+	for _, e := range entries {
+		flips := e.flips()
+		if len(flips) == 0 {
+			continue
+		}
+		if start.IsZero() || flips[0].At.Before(start) {
+			start = flips[0].At
+		}
+		for i, f := range flips {
+			if i == 0 || !f.InStock {
+				continue
+			}
+			at := f.At.In(s.loc)
+			restocks[at.Weekday()]++
+			restockDays[at.Format("2006-01-02")] = true
+		}
+	}
+
+	// How many of each weekday the tracked period spans
+	var days, withRestock [7]int
+	if !start.IsZero() {
+		day := dayStart(start.In(s.loc))
+		for end := now.In(s.loc); !day.After(end); day = day.AddDate(0, 0, 1) {
+			days[day.Weekday()]++
+			if restockDays[day.Format("2006-01-02")] {
+				withRestock[day.Weekday()]++
+			}
+		}
+	}
+
+	stats := make([]WeekdayRestocks, 0, 7)
+	for i := 1; i <= 7; i++ {
+		wd := time.Weekday(i % 7)
+		stat := WeekdayRestocks{Weekday: wd.String(), Restocks: restocks[wd]}
+		if days[wd] > 0 {
+			stat.Likelihood = math.Round(float64(withRestock[wd])/float64(days[wd])*1000) / 1000
+		}
+		stats = append(stats, stat)
+	}
+	return stats
+}
+
+func dayStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func roundHours(d time.Duration) float64 {
+	return math.Round(d.Hours()*10) / 10
+}
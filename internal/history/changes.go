@@ -0,0 +1,181 @@
+package history
+
+import (
+	"encoding/json"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"price-comparison-api/internal/storage"
+)
+
+// Listing fields whose changes are recorded
+const (
+	FieldTitle  = "title"
+	FieldImage  = "image"
+	FieldSeller = "seller"
+)
+
+// ChangeFields lists the fields a change can be about.
+var ChangeFields = []string{FieldTitle, FieldImage, FieldSeller}
+
+// maxChanges caps the changes kept per product
+const maxChanges = 50
+
+// Change is a listing detail that changed between two scrapes. A new title,
+// image and seller together is what a hijacked marketplace listing looks
+// like.
+type Change struct {
+	Field string    `json:"field"`
+	Old   string    `json:"old"`
+	New   string    `json:"new"`
+	At    time.Time `json:"at"`
+}
+
+// listing is what a scrape saw of a product besides its price.
+type listing struct {
+	name, image, seller string
+}
+
+// applyListing records the changes from the entry's listing to l and takes
+// l's values. Empty values in l are taken as not scraped, not as removed. It
+// reports whether anything changed.
+func (e *Entry) applyListing(l listing, now time.Time) bool {
+	var changes []Change
+	if l.name != "" {
+		if e.Name != "" && normalizeTitle(e.Name) != normalizeTitle(l.name) {
+			changes = append(changes, Change{Field: FieldTitle, Old: e.Name, New: l.name, At: now})
+		}
+		e.Name = l.name
+	}
+	if l.image != "" {
+		if e.Image != "" && imageID(e.Image) != imageID(l.image) {
+			changes = append(changes, Change{Field: FieldImage, Old: e.Image, New: l.image, At: now})
+		}
+		e.Image = l.image
+	}
+	if l.seller != "" {
+		if e.Seller != "" && !strings.EqualFold(strings.TrimSpace(e.Seller), strings.TrimSpace(l.seller)) {
+			changes = append(changes, Change{Field: FieldSeller, Old: e.Seller, New: l.seller, At: now})
+		}
+		e.Seller = l.seller
+	}
+	if len(changes) == 0 {
+		return false
+	}
+	e.Changes = append(e.Changes, changes...)
+	if len(e.Changes) > maxChanges {
+		e.Changes = e.Changes[len(e.Changes)-maxChanges:]
+	}
+	return true
+}
+
+// normalizeTitle ignores case and spacing, which retailers change freely.
+func normalizeTitle(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// amazonImageModifier is the size and crop part of an Amazon image name,
+// as in 71abc._AC_UY218_.jpg, which differs between pages
+var amazonImageModifier = regexp.MustCompile(`\._[^/]*_\.`)
+
+// imageID reduces an image URL to the picture it shows: its file name
+// without query string or resizing modifiers.
+func imageID(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	name := amazonImageModifier.ReplaceAllString(path.Base(u.Path), ".")
+	return strings.ToLower(name)
+}
+
+// RecordListing records what a product page showed of a product already in
+// history: its title, image and seller. Products not in history are
+// ignored; changes are reported as for searches.
+func (s *Store) RecordListing(key, name, image, seller string) error {
+	return s.db.Update(func(tx storage.Tx) error {
+		bucket := tx.Bucket(productsBucket)
+		raw := bucket.Get([]byte(key))
+		if raw == nil {
+			return nil
+		}
+		var entry Entry
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			return err
+		}
+		before := entry.Name + "\x00" + entry.Image + "\x00" + entry.Seller
+		entry.applyListing(listing{name: name, image: image, seller: seller}, time.Now())
+		if entry.Name+"\x00"+entry.Image+"\x00"+entry.Seller == before {
+			return nil
+		}
+		raw, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(key), raw)
+	})
+}
+
+// ProductChange is a change together with the product it happened to.
+type ProductChange struct {
+	Key     string `json:"key"`
+	Name    string `json:"name"`
+	URL     string `json:"url"`
+	Source  string `json:"source"`
+	Country string `json:"country"`
+	Change
+}
+
+// ChangeQuery selects changes across products. Empty fields match
+// everything.
+type ChangeQuery struct {
+	// Keys limits the changes to these products
+	Keys    []string
+	Country string
+	Field   string
+	Since   time.Time
+	Limit   int
+}
+
+// Changes returns the listing changes matching q, newest first.
+func (s *Store) Changes(q ChangeQuery) ([]ProductChange, error) {
+	country := strings.ToUpper(q.Country)
+	changes := []ProductChange{}
+	collect := func(e *Entry) bool {
+		if country != "" && e.Country != country {
+			return true
+		}
+		for _, c := range e.Changes {
+			if (q.Field != "" && c.Field != q.Field) || c.At.Before(q.Since) {
+				continue
+			}
+			changes = append(changes, ProductChange{Key: e.Key, Name: e.Name, URL: e.URL, Source: e.Source, Country: e.Country, Change: c})
+		}
+		return true
+	}
+
+	var err error
+	if len(q.Keys) == 0 {
+		err = s.Each(collect)
+	}
+	for _, key := range q.Keys {
+		var entry *Entry
+		if entry, err = s.Get(key); err != nil {
+			break
+		}
+		if entry != nil {
+			collect(entry)
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].At.After(changes[j].At)
+	})
+	if q.Limit > 0 && len(changes) > q.Limit {
+		changes = changes[:q.Limit]
+	}
+	return changes, err
+}
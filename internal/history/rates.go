@@ -0,0 +1,188 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"price-comparison-api/internal/storage"
+)
+
+var ratesBucket = []byte("rates")
+
+// dayLayout keys daily exchange rates.
+const dayLayout = "2006-01-02"
+
+// RecordRates stores the exchange rates, in units per US dollar, in effect
+// on day, a day of the reporting time zone. Recording a day again replaces
+// its rates.
+func (s *Store) RecordRates(day time.Time, rates map[string]float64) error {
+	clean := make(map[string]float64, len(rates))
+	for code, rate := range rates {
+		if rate <= 0 {
+			return fmt.Errorf("rate for %s must be positive", code)
+		}
+		clean[strings.ToUpper(code)] = rate
+	}
+	raw, err := json.Marshal(clean)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx storage.Tx) error {
+		return tx.Bucket(ratesBucket).Put([]byte(day.In(s.loc).Format(dayLayout)), raw)
+	})
+}
+
+// TrackRates records rates for today, and again each day the server keeps
+// running, so points can later be converted with the rates of their day.
+// Days already recorded, for example backfilled ones, are left alone.
+func (s *Store) TrackRates(rates map[string]float64) {
+	record := func() {
+		day := time.Now()
+		if book, err := s.Rates(); err == nil && book.has(day) {
+			return
+		}
+		if err := s.RecordRates(day, rates); err != nil {
+			log.Warn().Err(err).Msg("Failed to record exchange rates")
+		}
+	}
+	record()
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				record()
+			}
+		}
+	}()
+}
+
+// RateBook holds the recorded daily exchange rates.
+type RateBook struct {
+	days  []string
+	rates map[string]map[string]float64
+	loc   *time.Location
+}
+
+// Rates loads every recorded day.
+func (s *Store) Rates() (*RateBook, error) {
+	book := &RateBook{rates: make(map[string]map[string]float64), loc: s.loc}
+	err := s.db.View(func(tx storage.Tx) error {
+		return tx.Bucket(ratesBucket).ForEach(func(k, v []byte) error {
+			var rates map[string]float64
+			if err := json.Unmarshal(v, &rates); err != nil {
+				return nil
+			}
+			day := string(k)
+			book.days = append(book.days, day)
+			book.rates[day] = rates
+			return nil
+		})
+	})
+	sort.Strings(book.days)
+	return book, err
+}
+
+// Days lists the recorded days, oldest first.
+func (b *RateBook) Days() []string {
+	return b.days
+}
+
+// Day parses a YYYY-MM-DD day of the reporting time zone.
+func (s *Store) Day(day string) (time.Time, error) {
+	return time.ParseInLocation(dayLayout, day, s.loc)
+}
+
+func (b *RateBook) has(day time.Time) bool {
+	_, ok := b.rates[day.In(b.loc).Format(dayLayout)]
+	return ok
+}
+
+// On returns the rates in effect at t: those of its day in the reporting
+// time zone or, failing that, of the closest earlier day. Points older than
+// every recorded day use the oldest one. The returned day is empty when
+// nothing is recorded.
+func (b *RateBook) On(t time.Time) (string, map[string]float64) {
+	if len(b.days) == 0 {
+		return "", nil
+	}
+	key := t.In(b.loc).Format(dayLayout)
+	i := sort.SearchStrings(b.days, key)
+	switch {
+	case i < len(b.days) && b.days[i] == key:
+	case i == 0:
+	default:
+		i--
+	}
+	return b.days[i], b.rates[b.days[i]]
+}
+
+// ConvertedPoint is a point priced in another currency.
+type ConvertedPoint struct {
+	Point
+	// SourcePrice is the price in the product's own currency
+	SourcePrice float64 `json:"source_price"`
+	// RateDay is the day whose exchange rates were used; empty when the
+	// current rates were
+	RateDay string `json:"rate_day,omitempty"`
+}
+
+// Series is a product's price history in a requested currency.
+type Series struct {
+	Key            string           `json:"key"`
+	Name           string           `json:"name"`
+	URL            string           `json:"url"`
+	Source         string           `json:"source"`
+	Country        string           `json:"country"`
+	Currency       string           `json:"currency"`
+	SourceCurrency string           `json:"source_currency"`
+	Points         []ConvertedPoint `json:"points"`
+	// Availability is the product's in and out of stock timeline
+	Availability *Availability `json:"availability,omitempty"`
+}
+
+// Convert prices every point of e in currency with the exchange rates of
+// the point's day, so histories from different countries can be compared
+// without today's rates distorting past prices. current fills in
+// currencies a recorded day doesn't have.
+func (b *RateBook) Convert(e *Entry, currency string, current map[string]float64) (*Series, error) {
+	currency = strings.ToUpper(currency)
+	series := &Series{
+		Key:            e.Key,
+		Name:           e.Name,
+		URL:            e.URL,
+		Source:         e.Source,
+		Country:        e.Country,
+		Currency:       currency,
+		SourceCurrency: e.Currency,
+		Points:         make([]ConvertedPoint, 0, len(e.Points)),
+	}
+	for _, p := range e.Points {
+		cp := ConvertedPoint{Point: p, SourcePrice: p.Price}
+		if e.Currency != currency {
+			day, rates := b.On(p.At)
+			from, fromOK := rates[e.Currency]
+			to, toOK := rates[currency]
+			if !fromOK || !toOK {
+				day = ""
+				from, fromOK = current[e.Currency]
+				to, toOK = current[currency]
+			}
+			if !fromOK || !toOK || from <= 0 {
+				return nil, fmt.Errorf("no exchange rate between %s and %s", e.Currency, currency)
+			}
+			cp.Price = math.Round(p.Price/from*to*100) / 100
+			cp.RateDay = day
+		}
+		series.Points = append(series.Points, cp)
+	}
+	return series, nil
+}
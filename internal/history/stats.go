@@ -0,0 +1,129 @@
+package history
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// StatsWindows are the trailing windows, in days, Stats sums up.
+var StatsWindows = []int{7, 30, 90}
+
+// dealWindow and dealPercentile define a good deal: a current price below
+// the 25th percentile of the last 30 days' prices.
+const (
+	dealWindow     = 30
+	dealPercentile = 25
+)
+
+// WindowStats sums up the prices seen in one trailing window.
+type WindowStats struct {
+	Days int `json:"days"`
+	// Points counts the prices seen; windows longer than the retention
+	// only cover what's kept
+	Points int     `json:"points"`
+	Min    float64 `json:"min,omitempty"`
+	Max    float64 `json:"max,omitempty"`
+	Avg    float64 `json:"avg,omitempty"`
+}
+
+// Stats are camelcamelcamel-style insights into a product's price history.
+type Stats struct {
+	Key          string    `json:"key"`
+	Name         string    `json:"name"`
+	URL          string    `json:"url"`
+	Source       string    `json:"source"`
+	Currency     string    `json:"currency"`
+	Country      string    `json:"country"`
+	CurrentPrice float64   `json:"current_price"`
+	CurrentAt    time.Time `json:"current_at"`
+	InStock      bool      `json:"in_stock"`
+
+	Windows []WindowStats `json:"windows"`
+	// Percentile is the share of the longest window's prices below the
+	// current one, in percent: 0 is the lowest price seen
+	Percentile float64 `json:"percentile"`
+	// DealThreshold is the 30-day 25th percentile; GoodDeal is set when
+	// the current price is below it
+	DealThreshold float64 `json:"deal_threshold,omitempty"`
+	GoodDeal      bool    `json:"good_deal"`
+}
+
+// Stats sums up the entry's prices over each of StatsWindows up to now.
+func (e *Entry) Stats(now time.Time) *Stats {
+	current := e.Latest()
+	stats := &Stats{
+		Key:          e.Key,
+		Name:         e.Name,
+		URL:          e.URL,
+		Source:       e.Source,
+		Currency:     e.Currency,
+		Country:      e.Country,
+		CurrentPrice: current.Price,
+		CurrentAt:    current.At,
+		InStock:      current.InStock,
+	}
+
+	for _, days := range StatsWindows {
+		prices := e.pricesSince(now.AddDate(0, 0, -days))
+		window := WindowStats{Days: days, Points: len(prices)}
+		if len(prices) > 0 {
+			sum := 0.0
+			window.Min, window.Max = prices[0], prices[0]
+			for _, p := range prices {
+				window.Min = math.Min(window.Min, p)
+				window.Max = math.Max(window.Max, p)
+				sum += p
+			}
+			window.Avg = math.Round(sum/float64(len(prices))*100) / 100
+		}
+		stats.Windows = append(stats.Windows, window)
+	}
+
+	if prices := e.pricesSince(now.AddDate(0, 0, -StatsWindows[len(StatsWindows)-1])); len(prices) > 0 {
+		below := 0
+		for _, p := range prices {
+			if p < current.Price {
+				below++
+			}
+		}
+		stats.Percentile = math.Round(float64(below)/float64(len(prices))*1000) / 10
+	}
+	// A single price is no history to judge a deal by
+	if prices := e.pricesSince(now.AddDate(0, 0, -dealWindow)); len(prices) > 1 {
+		stats.DealThreshold = math.Round(percentile(prices, dealPercentile)*100) / 100
+		stats.GoodDeal = current.Price > 0 && current.Price < stats.DealThreshold
+	}
+	return stats
+}
+
+// PriceAt returns the last point recorded at or before t.
+func (e *Entry) PriceAt(t time.Time) (Point, bool) {
+	for i := len(e.Points) - 1; i >= 0; i-- {
+		if !e.Points[i].At.After(t) {
+			return e.Points[i], true
+		}
+	}
+	return Point{}, false
+}
+
+// pricesSince returns the prices seen at or after since.
+func (e *Entry) pricesSince(since time.Time) []float64 {
+	var prices []float64
+	for _, p := range e.Points {
+		if p.Price > 0 && !p.At.Before(since) {
+			prices = append(prices, p.Price)
+		}
+	}
+	return prices
+}
+
+// percentile interpolates the pth percentile of values.
+func percentile(values []float64, p float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
@@ -0,0 +1,465 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/storage"
+)
+
+var productsBucket = []byte("products")
+
+// StoreName is the history's namespace in a shared SQL database.
+const StoreName = "history"
+
+// Buckets are the history's buckets, for copying it between databases.
+var Buckets = [][]byte{productsBucket, ratesBucket}
+
+const (
+	// maxPoints caps the points kept per product
+	maxPoints = 500
+	// sameMinGap is how long an unchanged price goes unrecorded
+	sameMinGap = time.Hour
+)
+
+// Point is one observed price.
+type Point struct {
+	Price    float64   `json:"price"`
+	InStock  bool      `json:"in_stock"`
+	Preorder bool      `json:"preorder,omitempty"`
+	At       time.Time `json:"at"`
+}
+
+// Entry is the price history of one product in one country.
+type Entry struct {
+	Key   string `json:"key"`
+	Name  string `json:"name"`
+	URL   string `json:"url"`
+	Image string `json:"image,omitempty"`
+	// Seller is the marketplace seller or merchant, when the source names one
+	Seller   string `json:"seller,omitempty"`
+	Source   string `json:"source"`
+	Currency string `json:"currency"`
+	Country  string `json:"country"`
+	Category string `json:"category"`
+	// ReleaseDate is the last release date a listing announced
+	ReleaseDate *time.Time `json:"release_date,omitempty"`
+	Points      []Point    `json:"points"`
+	// Changes are the title, image and seller changes seen, oldest first
+	Changes []Change `json:"changes,omitempty"`
+	// Flips are the availability changes seen, oldest first; see Availability
+	Flips []Flip `json:"flips,omitempty"`
+}
+
+// Latest returns the most recent point.
+func (e *Entry) Latest() Point {
+	return e.Points[len(e.Points)-1]
+}
+
+// Store keeps product price history in the configured storage.
+type Store struct {
+	db        storage.DB
+	retention time.Duration
+	stop      chan struct{}
+	// loc is the reporting time zone daily records are kept in
+	loc *time.Location
+
+	observersMu sync.RWMutex
+	observers   []func(entries []Entry)
+}
+
+// NewStore opens the history database. Daily records start each day at
+// midnight in loc, the reporting time zone.
+func NewStore(cfg config.HistoryConfig, loc *time.Location, backend *storage.Backend) (*Store, error) {
+	path := cfg.Path
+	if path == "" {
+		path = filepath.Join(os.TempDir(), "price-comparison-history.db")
+	}
+	retention := cfg.Retention
+	if retention <= 0 {
+		retention = 30 * 24 * time.Hour
+	}
+
+	if backend.Driver() == config.StorageBolt {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create history directory: %v", err)
+		}
+	}
+	db, err := backend.Open(StoreName, path, Buckets...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history store: %v", err)
+	}
+
+	log.Info().Msgf("Price history store ready (%s), retention: %s", backend.Describe(path), retention)
+
+	if loc == nil {
+		loc = time.UTC
+	}
+	s := &Store{db: db, retention: retention, stop: make(chan struct{}), loc: loc}
+	go s.janitor()
+	return s, nil
+}
+
+// Key identifies a product across scrapes: its URL, or source and name when
+// the scraper didn't find one.
+func Key(country string, p models.Product) string {
+	id := p.URL
+	if id == "" {
+		id = p.Source + ":" + p.Name
+	}
+	return strings.ToUpper(country) + "|" + id
+}
+
+// Category is what history groups products by. Products don't carry a
+// category yet, so the normalized search term stands in for one.
+func Category(query string) string {
+	return strings.ToLower(strings.Join(strings.Fields(query), " "))
+}
+
+// OnRecord registers fn to be called with every entry that gained a new
+// point. Observers run synchronously after the write commits.
+func (s *Store) OnRecord(fn func(entries []Entry)) {
+	s.observersMu.Lock()
+	defer s.observersMu.Unlock()
+	s.observers = append(s.observers, fn)
+}
+
+// Record appends the current price of every product found by a search.
+func (s *Store) Record(query, country string, products []models.Product) error {
+	now := time.Now()
+	category := Category(query)
+	country = strings.ToUpper(country)
+
+	var updated []Entry
+	err := s.db.Update(func(tx storage.Tx) error {
+		updated = updated[:0]
+		bucket := tx.Bucket(productsBucket)
+		for _, p := range products {
+			key := Key(country, p)
+
+			var entry Entry
+			raw := bucket.Get([]byte(key))
+			if raw != nil {
+				if err := json.Unmarshal(raw, &entry); err != nil {
+					entry = Entry{}
+				}
+			}
+			if p.PriceValue <= 0 {
+				// Sold-out listings often show no price; a product already
+				// in history still has its availability noted
+				if raw != nil && len(entry.Points) > 0 && entry.applyAvailability(p.InStock, now) {
+					if err := putEntry(bucket, &entry); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+
+			changed := entry.applyListing(listing{name: p.Name, image: p.Image, seller: p.Merchant}, now)
+			entry.Key = key
+			entry.URL = p.URL
+			entry.Source = p.Source
+			entry.Currency = p.Currency
+			entry.Country = country
+			if entry.Category == "" {
+				entry.Category = category
+			}
+			if p.ReleaseDate != nil {
+				entry.ReleaseDate = p.ReleaseDate
+			}
+
+			// A listing change is saved even when the price needs no new point
+			point := true
+			if n := len(entry.Points); n > 0 {
+				last := entry.Points[n-1]
+				if last.Price == p.PriceValue && last.InStock == p.InStock && last.Preorder == p.Preorder && now.Sub(last.At) < sameMinGap {
+					point = false
+				}
+			}
+			if entry.applyAvailability(p.InStock, now) {
+				changed = true
+			}
+			if !point && !changed {
+				continue
+			}
+			if point {
+				entry.Points = append(entry.Points, Point{Price: p.PriceValue, InStock: p.InStock, Preorder: p.Preorder, At: now})
+				entry.Points = s.trim(entry.Points, now)
+			}
+
+			if err := putEntry(bucket, &entry); err != nil {
+				return err
+			}
+			if point {
+				updated = append(updated, entry)
+			}
+		}
+		return nil
+	})
+	if err != nil || len(updated) == 0 {
+		return err
+	}
+
+	s.observersMu.RLock()
+	observers := s.observers
+	s.observersMu.RUnlock()
+	for _, fn := range observers {
+		fn(updated)
+	}
+	return nil
+}
+
+// RecordAvailability appends a point at the last known price when a stock
+// check finds the product's availability changed. Observers are notified the
+// same way as for Record.
+func (s *Store) RecordAvailability(key string, inStock bool) error {
+	now := time.Now()
+
+	var updated *Entry
+	err := s.db.Update(func(tx storage.Tx) error {
+		bucket := tx.Bucket(productsBucket)
+		raw := bucket.Get([]byte(key))
+		if raw == nil {
+			return fmt.Errorf("no history for %s", key)
+		}
+		var entry Entry
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			return err
+		}
+		if len(entry.Points) == 0 {
+			return nil
+		}
+		flipped := entry.applyAvailability(inStock, now)
+		if entry.Latest().InStock == inStock {
+			if !flipped {
+				return nil
+			}
+			return putEntry(bucket, &entry)
+		}
+
+		entry.Points = append(entry.Points, Point{Price: entry.Latest().Price, InStock: inStock, At: now})
+		entry.Points = s.trim(entry.Points, now)
+		updated = &entry
+		return putEntry(bucket, &entry)
+	})
+	if err != nil || updated == nil {
+		return err
+	}
+
+	s.observersMu.RLock()
+	observers := s.observers
+	s.observersMu.RUnlock()
+	for _, fn := range observers {
+		fn([]Entry{*updated})
+	}
+	return nil
+}
+
+func putEntry(bucket storage.Bucket, entry *Entry) error {
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(entry.Key), raw)
+}
+
+// trim drops points past retention and caps the slice at maxPoints.
+func (s *Store) trim(points []Point, now time.Time) []Point {
+	cutoff := now.Add(-s.retention)
+	start := 0
+	for start < len(points)-1 && points[start].At.Before(cutoff) {
+		start++
+	}
+	if len(points)-start > maxPoints {
+		start = len(points) - maxPoints
+	}
+	return points[start:]
+}
+
+// Get returns the history of one product.
+func (s *Store) Get(key string) (*Entry, error) {
+	var entry *Entry
+	err := s.db.View(func(tx storage.Tx) error {
+		raw := tx.Bucket(productsBucket).Get([]byte(key))
+		if raw == nil {
+			return nil
+		}
+		entry = &Entry{}
+		return json.Unmarshal(raw, entry)
+	})
+	return entry, err
+}
+
+// Each calls fn for every stored entry. Returning false stops the scan.
+func (s *Store) Each(fn func(e *Entry) bool) error {
+	return s.db.View(func(tx storage.Tx) error {
+		c := tx.Bucket(productsBucket).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var entry Entry
+			if err := json.Unmarshal(v, &entry); err != nil || len(entry.Points) == 0 {
+				continue
+			}
+			if !fn(&entry) {
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+// janitor removes products not seen within the retention period.
+func (s *Store) janitor() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if n, err := s.prune(); err != nil {
+				log.Warn().Err(err).Msg("History prune failed")
+			} else if n > 0 {
+				log.Info().Msgf("History pruned %d stale products", n)
+			}
+		}
+	}
+}
+
+func (s *Store) prune() (int, error) {
+	cutoff := time.Now().Add(-s.retention)
+	var stale [][]byte
+	err := s.Each(func(e *Entry) bool {
+		if e.Latest().At.Before(cutoff) {
+			stale = append(stale, []byte(e.Key))
+		}
+		return true
+	})
+	if err != nil || len(stale) == 0 {
+		return 0, err
+	}
+	err = s.db.Update(func(tx storage.Tx) error {
+		bucket := tx.Bucket(productsBucket)
+		for _, k := range stale {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return len(stale), err
+}
+
+func (s *Store) Close() error {
+	if s == nil {
+		return nil
+	}
+	close(s.stop)
+	return s.db.Close()
+}
+
+// PriceDrop is a product whose current price is below its recent high.
+type PriceDrop struct {
+	Key             string    `json:"key"`
+	Name            string    `json:"name"`
+	URL             string    `json:"url"`
+	Image           string    `json:"image,omitempty"`
+	Source          string    `json:"source"`
+	Currency        string    `json:"currency"`
+	Country         string    `json:"country"`
+	Category        string    `json:"category"`
+	CurrentPrice    float64   `json:"current_price"`
+	PreviousPrice   float64   `json:"previous_price"`
+	Drop            float64   `json:"drop"`
+	DropPercent     float64   `json:"drop_percent"`
+	PreviousPriceAt time.Time `json:"previous_price_at"`
+	LastSeen        time.Time `json:"last_seen"`
+}
+
+// DropQuery selects entries for the price drop leaderboard.
+type DropQuery struct {
+	Country     string
+	Category    string
+	Window      time.Duration
+	MinDiscount float64 // percent
+}
+
+// Drops compares each product's latest price with the highest price seen in
+// the window and returns the ones that fell, largest drop first. Products
+// not seen within the window are skipped.
+func (s *Store) Drops(q DropQuery) ([]PriceDrop, error) {
+	now := time.Now()
+	since := now.Add(-q.Window)
+	country := strings.ToUpper(q.Country)
+	category := Category(q.Category)
+
+	drops := []PriceDrop{}
+	err := s.Each(func(e *Entry) bool {
+		if country != "" && e.Country != country {
+			return true
+		}
+		if category != "" && e.Category != category {
+			return true
+		}
+
+		current := e.Latest()
+		if current.At.Before(since) {
+			return true
+		}
+
+		var high Point
+		for _, p := range e.Points[:len(e.Points)-1] {
+			if p.At.Before(since) {
+				continue
+			}
+			if p.Price > high.Price {
+				high = p
+			}
+		}
+		if high.Price <= current.Price {
+			return true
+		}
+
+		percent := (high.Price - current.Price) / high.Price * 100
+		if percent < q.MinDiscount {
+			return true
+		}
+
+		drops = append(drops, PriceDrop{
+			Key:             e.Key,
+			Name:            e.Name,
+			URL:             e.URL,
+			Image:           e.Image,
+			Source:          e.Source,
+			Currency:        e.Currency,
+			Country:         e.Country,
+			Category:        e.Category,
+			CurrentPrice:    current.Price,
+			PreviousPrice:   high.Price,
+			Drop:            high.Price - current.Price,
+			DropPercent:     percent,
+			PreviousPriceAt: high.At,
+			LastSeen:        current.At,
+		})
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(drops, func(i, j int) bool {
+		if drops[i].DropPercent != drops[j].DropPercent {
+			return drops[i].DropPercent > drops[j].DropPercent
+		}
+		return drops[i].Drop > drops[j].Drop
+	})
+	return drops, nil
+}
@@ -0,0 +1,217 @@
+// Package imageproxy fetches product images from retailer CDNs and serves
+// them, resized to thumbnails on request, from the cache. Browser clients
+// then load images from this API alone, so retailers' hotlink blocks,
+// plain-HTTP image URLs and tracking never reach them.
+package imageproxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog"
+	"price-comparison-api/internal/apierr"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/pkg/cache"
+)
+
+// maxRedirects caps the redirects followed to an image
+const maxRedirects = 5
+
+// errPrivateAddress is a URL that resolves to an address the proxy won't
+// connect to, such as a loopback or private network address.
+var errPrivateAddress = errors.New("not a public address")
+
+// Image is an image ready to serve.
+type Image struct {
+	Data        []byte
+	ContentType string
+	// Cached is set when the image came from the cache
+	Cached bool
+}
+
+// Proxy fetches, resizes and caches images.
+type Proxy struct {
+	cfg    config.ImagesConfig
+	cache  *cache.RedisCache
+	client *http.Client
+}
+
+// New returns a proxy that keeps images in c, or fetches them every time
+// when c is nil or unavailable. It only connects to public addresses, so a
+// URL can't be used to reach the server's own network.
+func New(cfg config.ImagesConfig, c *cache.RedisCache) *Proxy {
+	dialer := &net.Dialer{Timeout: cfg.Timeout, Control: publicOnly}
+	transport := &http.Transport{
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: cfg.Timeout,
+		MaxIdleConnsPerHost: 4,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   cfg.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("redirected to unsupported scheme %q", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+	return &Proxy{cfg: cfg, cache: c, client: client}
+}
+
+// MaxWidth is the widest thumbnail the proxy makes.
+func (p *Proxy) MaxWidth() int {
+	return p.cfg.MaxWidth
+}
+
+// CacheTTL is how long images are kept, and how long clients may keep them.
+func (p *Proxy) CacheTTL() time.Duration {
+	return p.cfg.CacheTTL
+}
+
+// Get returns the image at rawURL, scaled down to width pixels when width
+// is positive and the image is wider. Images in formats that can't be
+// decoded, such as WebP, are served as fetched.
+func (p *Proxy) Get(ctx context.Context, rawURL string, width int) (*Image, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, apierr.Validation("invalid_url", "url must be an absolute http or https image URL")
+	}
+	source := u.String()
+
+	key := cacheKey(source, width)
+	if img := p.cached(ctx, key); img != nil {
+		return img, nil
+	}
+
+	original := p.cached(ctx, cacheKey(source, 0))
+	if original == nil {
+		if original, err = p.fetch(ctx, source); err != nil {
+			return nil, err
+		}
+		p.store(ctx, cacheKey(source, 0), original)
+	}
+	if width <= 0 {
+		return original, nil
+	}
+
+	data, contentType, err := thumbnail(original.Data, width)
+	if err != nil {
+		zerolog.Ctx(ctx).Debug().Err(err).Str("url", source).Msg("Serving image without resizing")
+		return original, nil
+	}
+	img := &Image{Data: data, ContentType: contentType}
+	p.store(ctx, key, img)
+	return img, nil
+}
+
+// fetch downloads an image, refusing anything that isn't one. SVGs are
+// refused too: served from this API's origin, their scripts would run as
+// its own.
+func (p *Proxy) fetch(ctx context.Context, source string) (*Image, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, apierr.Validation("invalid_url", err.Error())
+	}
+	req.Header.Set("User-Agent", p.cfg.UserAgent)
+	// Ask for formats that can be resized; CDNs that negotiate send them
+	req.Header.Set("Accept", "image/jpeg,image/png,image/gif,image/*;q=0.5")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		if errors.Is(err, errPrivateAddress) {
+			return nil, apierr.Validation("invalid_url", "url must point to a public address")
+		}
+		return nil, apierr.Classify(fmt.Errorf("image fetch failed: %w", err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apierr.New(apierr.ErrUpstream, "image_fetch_failed", fmt.Sprintf("image returned status %d", resp.StatusCode))
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(p.cfg.MaxBytes)+1))
+	if err != nil {
+		return nil, apierr.Classify(fmt.Errorf("image fetch failed: %w", err))
+	}
+	if len(data) > p.cfg.MaxBytes {
+		return nil, apierr.New(apierr.ErrUpstream, "image_too_large", fmt.Sprintf("image is larger than %d bytes", p.cfg.MaxBytes))
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = contentType[:i]
+	}
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	if !strings.HasPrefix(contentType, "image/") {
+		contentType = http.DetectContentType(data)
+	}
+	if !strings.HasPrefix(contentType, "image/") || strings.Contains(contentType, "svg") {
+		return nil, apierr.New(apierr.ErrUpstream, "not_an_image", "url does not point to a raster image")
+	}
+	return &Image{Data: data, ContentType: contentType}, nil
+}
+
+// cacheKey names an image in the cache; width 0 is the image as fetched.
+func cacheKey(source string, width int) string {
+	sum := sha256.Sum256([]byte(source))
+	return fmt.Sprintf("image:%s:%d", hex.EncodeToString(sum[:16]), width)
+}
+
+// cached returns the image stored under key, or nil. Images are stored as
+// their content type, a newline and the data.
+func (p *Proxy) cached(ctx context.Context, key string) *Image {
+	if p.cache == nil || !p.cache.IsAvailable() {
+		return nil
+	}
+	raw, err := p.cache.Get(ctx, key)
+	if err != nil || raw == nil {
+		return nil
+	}
+	i := bytes.IndexByte(raw, '\n')
+	if i < 0 {
+		return nil
+	}
+	return &Image{ContentType: string(raw[:i]), Data: raw[i+1:], Cached: true}
+}
+
+func (p *Proxy) store(ctx context.Context, key string, img *Image) {
+	if p.cache == nil || !p.cache.IsAvailable() {
+		return
+	}
+	raw := make([]byte, 0, len(img.ContentType)+1+len(img.Data))
+	raw = append(append(append(raw, img.ContentType...), '\n'), img.Data...)
+	if err := p.cache.Set(ctx, key, raw, p.cfg.CacheTTL); err != nil {
+		zerolog.Ctx(ctx).Warn().Err(err).Msg("Failed to cache image")
+	}
+}
+
+// publicOnly refuses connections to loopback, private, link-local and
+// other non-public addresses. It runs on the resolved address of every
+// connection, redirects included.
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+		return fmt.Errorf("%w: %s", errPrivateAddress, host)
+	}
+	return nil
+}
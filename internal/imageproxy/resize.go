@@ -0,0 +1,87 @@
+package imageproxy
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	_ "image/gif" // decoder only; GIF thumbnails are PNGs
+	"image/jpeg"
+	"image/png"
+)
+
+// jpegQuality is the quality thumbnails are encoded at
+const jpegQuality = 85
+
+// thumbnail scales an image down to width pixels, keeping its aspect ratio.
+// PNGs and GIFs, which may be transparent, become PNGs and everything else
+// a JPEG. An image no wider than width is returned as it is.
+func thumbnail(data []byte, width int) ([]byte, string, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	if config.Width <= width {
+		return data, "image/" + format, nil
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+
+	b := src.Bounds()
+	height := b.Dy() * width / b.Dx()
+	if height < 1 {
+		height = 1
+	}
+	dst := scaleDown(src, width, height)
+
+	var out bytes.Buffer
+	if format == "png" || format == "gif" {
+		err = png.Encode(&out, dst)
+		return out.Bytes(), "image/png", err
+	}
+	err = jpeg.Encode(&out, dst, &jpeg.Options{Quality: jpegQuality})
+	return out.Bytes(), "image/jpeg", err
+}
+
+// scaleDown shrinks src to width x height, each destination pixel the
+// average of the source pixels it covers. Averaging premultiplied colors
+// keeps transparent edges from darkening.
+func scaleDown(src image.Image, width, height int) *image.RGBA {
+	b := src.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+	sw, sh := b.Dx(), b.Dy()
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := y*sh/height, (y+1)*sh/height
+		if y1 == y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0, x1 := x*sw/width, (x+1)*sw/width
+			if x1 == x0 {
+				x1 = x0 + 1
+			}
+			var r, g, bl, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					px := row[sx*4 : sx*4+4]
+					r += uint32(px[0])
+					g += uint32(px[1])
+					bl += uint32(px[2])
+					a += uint32(px[3])
+					n++
+				}
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(bl / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,247 @@
+// Package mockdata generates the synthetic products mock mode serves in
+// place of scraped ones. Products are deterministic: the same query and
+// country always give the same products at the same prices, so frontends
+// and load tests can rely on them. Every retailer that covers the country
+// lists the same models, at prices a little apart, so comparisons between
+// retailers work as they would with real results.
+package mockdata
+
+import (
+	_ "embed"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/pricefmt"
+	"price-comparison-api/internal/productid"
+	"price-comparison-api/internal/scrapers"
+)
+
+// SourceSuffix ends the source of every mock product, "Amazon US (Mock)",
+// so they can't be mistaken for scraped ones and source=mock matches them.
+const SourceSuffix = " (Mock)"
+
+//go:embed catalog.yaml
+var catalogYAML []byte
+
+// catalog is what the generator draws from, from catalog.yaml.
+type catalog struct {
+	Retailers  map[string]string   `yaml:"retailers"`
+	Brands     []string            `yaml:"brands"`
+	Lines      []string            `yaml:"lines"`
+	Variants   []string            `yaml:"variants"`
+	Deals      []string            `yaml:"deals"`
+	Conditions []string            `yaml:"conditions"`
+	MinPrice   float64             `yaml:"min_price"`
+	MaxPrice   float64             `yaml:"max_price"`
+	Currencies map[string]currency `yaml:"currencies"`
+}
+
+type currency struct {
+	Symbol string  `yaml:"symbol"`
+	Rate   float64 `yaml:"rate"`
+}
+
+var data = mustLoadCatalog()
+
+func mustLoadCatalog() catalog {
+	var c catalog
+	if err := yaml.Unmarshal(catalogYAML, &c); err != nil {
+		panic(fmt.Sprintf("mock catalog: %v", err))
+	}
+	return c
+}
+
+// model is one product every retailer lists.
+type model struct {
+	name     string
+	slug     string
+	usdPrice float64
+}
+
+// Products returns perSource products from every retailer that covers
+// country, whether or not it's enabled.
+func Products(query, country string, perSource int) []models.Product {
+	if perSource < 1 {
+		return nil
+	}
+	country = strings.ToUpper(country)
+	list := newModels(query, perSource)
+
+	var products []models.Product
+	for _, name := range config.ScraperNames {
+		if cov := scrapers.CountryCoverage(name, country); cov.Supported {
+			products = append(products, retailerProducts(name, country, cov, list)...)
+		}
+	}
+	return products
+}
+
+// Detail returns the mock product page at rawURL, a product URL of any
+// retailer with product pages. The product is named after the URL's last
+// path segment, so the pages of mock search results carry the results'
+// names, and priced from the whole URL, the same on every call.
+func Detail(rawURL string) (*models.ProductDetail, error) {
+	name, err := scrapers.DetailScraperFor(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	country, err := scrapers.DetailCountry(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	slug := path.Base(u.Path)
+	words := strings.Split(slug, "-")
+	// Mock search results number their slugs
+	if len(words) > 1 {
+		if _, err := strconv.Atoi(words[len(words)-1]); err == nil {
+			words = words[:len(words)-1]
+		}
+	}
+	title := titleCase(strings.Join(words, " "))
+	if slug == "" || slug == "/" || slug == "." {
+		slug, title = "product", "Product"
+	}
+	r := random(rawURL)
+	m := model{
+		name:     title,
+		slug:     slug,
+		usdPrice: data.MinPrice + math.Pow(r.Float64(), 2)*(data.MaxPrice-data.MinPrice),
+	}
+
+	p := retailerProducts(name, country, scrapers.CountryCoverage(name, country), []model{m})[0]
+	p.URL = rawURL
+	p.ID, p.SourceProductID = productid.For(name, country, rawURL, "")
+	availability := scrapers.AvailabilityOutOfStock
+	if p.InStock {
+		availability = scrapers.AvailabilityInStock
+	}
+	return &models.ProductDetail{
+		Product:      p,
+		Country:      country,
+		Seller:       data.Retailers[name],
+		Availability: availability,
+		Images:       []string{p.Image},
+	}, nil
+}
+
+// newModels draws the products a query finds, the same for every
+// retailer.
+func newModels(query string, n int) []model {
+	r := random(query)
+	title := titleCase(query)
+	list := make([]model, n)
+	for i := range list {
+		brand := pick(r, data.Brands)
+		name := fmt.Sprintf("%s %s %s - %s", brand, title, pick(r, data.Lines), pick(r, data.Variants))
+		// Most products are cheap, a few expensive, as on real result pages
+		spread := math.Pow(r.Float64(), 2)
+		list[i] = model{
+			name:     name,
+			slug:     fmt.Sprintf("%s-%d", slugify(name), i+1),
+			usdPrice: data.MinPrice + spread*(data.MaxPrice-data.MinPrice),
+		}
+	}
+	return list
+}
+
+// retailerProducts lists models at one retailer, each priced up to 15%
+// either side of its base price.
+func retailerProducts(name, country string, cov scrapers.Coverage, list []model) []models.Product {
+	r := random(name + "|" + country + "|" + list[0].slug)
+	code, cur := cov.Currency, data.Currencies[cov.Currency]
+	if cur.Rate == 0 {
+		code, cur = "USD", data.Currencies["USD"]
+	}
+	retailer := data.Retailers[name]
+	site := cov.Site
+	if site == "" {
+		site = "www." + name + ".com"
+	}
+	now := time.Now()
+
+	products := make([]models.Product, 0, len(list))
+	for _, m := range list {
+		amount := pricefmt.Round(m.usdPrice*(0.85+0.3*r.Float64())*cur.Rate, code)
+		p := models.Product{
+			Name:      m.name,
+			Price:     formatPrice(amount, code, cur),
+			Currency:  code,
+			URL:       "https://" + site + "/mock/" + m.slug,
+			Image:     "https://picsum.photos/seed/" + m.slug + "/400/400",
+			Rating:    fmt.Sprintf("%.1f out of 5 stars", 3+2*r.Float64()),
+			Reviews:   strconv.Itoa(r.Intn(5000)),
+			Source:    fmt.Sprintf("%s %s%s", retailer, country, SourceSuffix),
+			ScrapedAt: now,
+			InStock:   r.Intn(10) > 0,
+			Condition: pick(r, data.Conditions),
+		}
+		if r.Intn(4) == 0 {
+			original := pricefmt.Round(amount*(1.1+0.4*r.Float64()), code)
+			p.OriginalPrice = formatPrice(original, code, cur)
+			p.Discount = math.Round((1-amount/original)*1000) / 10
+			p.DealBadge = pick(r, data.Deals)
+		}
+		if name == config.ScraperCostco || name == config.ScraperSamsClub {
+			p.RequiresMembership = true
+		}
+		p.ID, p.SourceProductID = productid.For(name, country, p.URL, p.Name)
+		products = append(products, p)
+	}
+	return products
+}
+
+// random returns a generator seeded from key, so the same key always
+// draws the same values.
+func random(key string) *rand.Rand {
+	h := fnv.New64a()
+	h.Write([]byte(strings.ToLower(key)))
+	return rand.New(rand.NewSource(int64(h.Sum64())))
+}
+
+func pick(r *rand.Rand, list []string) string {
+	return list[r.Intn(len(list))]
+}
+
+// formatPrice writes amount the way scraped prices look: "$19.99".
+func formatPrice(amount float64, code string, cur currency) string {
+	return cur.Symbol + strconv.FormatFloat(amount, 'f', pricefmt.Digits(code), 64)
+}
+
+func titleCase(query string) string {
+	words := strings.Fields(query)
+	for i, w := range words {
+		first, size := utf8.DecodeRuneInString(w)
+		words[i] = string(unicode.ToUpper(first)) + w[size:]
+	}
+	return strings.Join(words, " ")
+}
+
+func slugify(name string) string {
+	var b strings.Builder
+	for _, word := range strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !('a' <= r && r <= 'z' || '0' <= r && r <= '9')
+	}) {
+		if b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		b.WriteString(word)
+	}
+	return b.String()
+}
@@ -5,7 +5,14 @@ import (
 )
 
 type Product struct {
-	ID          string    `json:"id"`
+	// ID is the same every time the listing is scraped; SourceProductID is
+	// the retailer's own ID for it (an ASIN, eBay item number or Flipkart
+	// PID) when the listing URL carries one
+	ID              string `json:"id"`
+	SourceProductID string `json:"source_product_id,omitempty"`
+	// CatalogID is the canonical product this listing belongs to in the
+	// product catalog, shared by every retailer selling it
+	CatalogID   string    `json:"catalog_id,omitempty"`
 	Name        string    `json:"name"`
 	Price       string    `json:"price"`
 	Currency    string    `json:"currency"`
@@ -13,11 +20,128 @@ type Product struct {
 	Image       string    `json:"image"`
 	Rating      string    `json:"rating,omitempty"`
 	Reviews     string    `json:"reviews,omitempty"`
+	ReviewCount int       `json:"review_count,omitempty"`
 	Source      string    `json:"source"`
 	ScrapedAt   time.Time `json:"scraped_at"`
 	InStock     bool      `json:"in_stock"`
 	Description string    `json:"description,omitempty"`
 	PriceValue  float64   `json:"price_value,omitempty"` // For filtering/sorting
+	// RatingValue is Rating on a 0-5 scale, whatever scale RatingScale says
+	// the source used; Rating keeps the source's text for display
+	RatingValue float64 `json:"rating_value,omitempty"`
+	RatingScale float64 `json:"rating_scale,omitempty"`
+	// OriginalPrice is the strike-through list price shown next to a reduced
+	// price; Discount is the percentage off and DealBadge a label like
+	// "Deal of the Day"
+	OriginalPrice string  `json:"original_price,omitempty"`
+	Discount      float64 `json:"discount_percent,omitempty"`
+	DealBadge     string  `json:"deal_badge,omitempty"`
+	// PriceChange24h is the percent change since the price history's last
+	// price a day ago, and Trend is up, down or stable by it; both are only
+	// set for listings with history that old in the same currency
+	PriceChange24h *float64 `json:"price_change_24h,omitempty"`
+	Trend          string   `json:"trend,omitempty"`
+	// Preorder is set for listings that can be ordered but haven't shipped
+	// yet; ReleaseDate is filled when the listing states one
+	Preorder    bool       `json:"preorder,omitempty"`
+	ReleaseDate *time.Time `json:"release_date,omitempty"`
+	// Merchant is the store selling the product when Source is an
+	// aggregator, or the seller on a marketplace; Offers lists every store
+	// the aggregator found it at
+	Merchant string  `json:"merchant,omitempty"`
+	Offers   []Offer `json:"offers,omitempty"`
+	// MarketplaceSeller is set when a third-party seller lists the product
+	// on a marketplace rather than the retailer selling it itself
+	MarketplaceSeller bool `json:"marketplace_seller,omitempty"`
+	// SourcePrice and SourceCurrency keep the retailer's own price when the
+	// product was converted to the user's preferred currency
+	SourcePrice    string `json:"source_price,omitempty"`
+	SourceCurrency string `json:"source_currency,omitempty"`
+	// RequiresMembership marks warehouse-club offers (Costco, Sam's Club)
+	// that only members can buy at the listed price
+	RequiresMembership bool `json:"requires_membership,omitempty"`
+	// Condition is new, used or refurbished when the source reports it
+	Condition string `json:"condition,omitempty"`
+	// SellerRating is the marketplace seller's feedback, e.g. "99.5%"
+	SellerRating string `json:"seller_rating,omitempty"`
+	// Category is the slug of the taxonomy category the product belongs to,
+	// like "phones"; see GET /categories
+	Category string `json:"category,omitempty"`
+	// Attributes are the variant attributes the title names, such as
+	// "storage": "256gb" or "color": "midnight blue"
+	Attributes map[string]string `json:"attributes,omitempty"`
+	// Title is the full product title as the retailer lists it; Name holds
+	// the same for existing clients. Neither is ever truncated, so matching
+	// and dedupe see every word. DisplayName is Title cut to the configured
+	// display length, set only when one is configured and Title exceeds it.
+	Title       string `json:"title,omitempty"`
+	DisplayName string `json:"display_name,omitempty"`
+}
+
+// Product conditions
+const (
+	ConditionNew         = "new"
+	ConditionUsed        = "used"
+	ConditionRefurbished = "refurbished"
+)
+
+var Conditions = []string{ConditionNew, ConditionUsed, ConditionRefurbished}
+
+// Price trends
+const (
+	TrendUp     = "up"
+	TrendDown   = "down"
+	TrendStable = "stable"
+)
+
+// Offer is one merchant's price for a product found through an aggregator.
+type Offer struct {
+	Merchant   string  `json:"merchant"`
+	Price      string  `json:"price"`
+	PriceValue float64 `json:"price_value"`
+	URL        string  `json:"url,omitempty"`
+}
+
+// ProductDetail is the full data scraped from a single product page.
+type ProductDetail struct {
+	Product
+	Country        string            `json:"country"`
+	Brand          string            `json:"brand,omitempty"`
+	Specifications map[string]string `json:"specifications,omitempty"`
+	Seller         string            `json:"seller,omitempty"`
+	ShippingCost   string            `json:"shipping_cost,omitempty"`
+	Availability   string            `json:"availability"` // in_stock, out_of_stock, preorder, unknown
+	Images         []string          `json:"images,omitempty"`
+	// ReviewSummary sums up the review snippets on the page, when it has any
+	ReviewSummary *ReviewSummary `json:"review_summary,omitempty"`
+	// Attribution credits the retailer the page was scraped from
+	Attribution *Attribution `json:"attribution,omitempty"`
+}
+
+// ReviewSummary is a keyword-level reading of a product page's top reviews.
+type ReviewSummary struct {
+	Snippets []string `json:"snippets"`
+	// Sentiment is positive, negative, mixed or neutral (no opinion words)
+	Sentiment string `json:"sentiment"`
+	// Score runs from -1, every opinion word negative, to 1, every one positive
+	Score float64 `json:"score"`
+	// Pros and Cons are the product aspects, such as "battery" or "price",
+	// most often praised and criticised, most mentioned first
+	Pros []string `json:"pros,omitempty"`
+	Cons []string `json:"cons,omitempty"`
+}
+
+// Attribution credits the retailer behind a product source, for users who
+// redistribute the data. Text is only set when the operator embeds
+// attribution text.
+type Attribution struct {
+	Retailer    string    `json:"retailer"`
+	Source      string    `json:"source"`
+	RetrievedAt time.Time `json:"retrieved_at"`
+	TermsURL    string    `json:"terms_url,omitempty"`
+	Text        string    `json:"text,omitempty"`
+	// Products is how many products in the response came from Source
+	Products int `json:"products,omitempty"`
 }
 
 type SearchResponse struct {
@@ -31,6 +155,82 @@ type SearchResponse struct {
 	Filters    *Filters  `json:"filters,omitempty"`
 	Sort       *Sort     `json:"sort,omitempty"`
 	Duration   string    `json:"duration"`
+	SessionID  string    `json:"session_id,omitempty"`
+	// NormalizedQuery is the query as it was searched for, when
+	// normalization changed it
+	NormalizedQuery string `json:"normalized_query,omitempty"`
+	// ResultSetID names the scraped results the response was built from;
+	// passing it back as result_set gets further pages of the same results
+	ResultSetID string `json:"result_set_id,omitempty"`
+	// Personalized is set when user preferences were applied
+	Personalized bool `json:"personalized,omitempty"`
+	// Attribution lists the retailers behind the returned products
+	Attribution []Attribution `json:"attribution,omitempty"`
+	// SourcesStatus says how each source for the country fared in the
+	// scrape the response was built from, including those it skipped
+	SourcesStatus []SourceStatus `json:"sources_status,omitempty"`
+	// Summary sums up every product matching the filters, not just the page
+	Summary *SearchSummary `json:"summary,omitempty"`
+	// Debug explains the response; set by filters such as strict
+	Debug *SearchDebug `json:"debug,omitempty"`
+}
+
+// SourceStatus is how one source fared in a search: ok, failed, blocked,
+// circuit_open, maintenance or disabled. Reason says why a source found
+// nothing or was skipped.
+type SourceStatus struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// SearchSummary is the headline figures of a search's results. Prices are in
+// Currency; listings priced in another currency are left out of them.
+type SearchSummary struct {
+	Currency     string          `json:"currency"`
+	LowestPrice  *SummaryProduct `json:"lowest_price,omitempty"`
+	HighestRated *SummaryProduct `json:"highest_rated,omitempty"`
+	MedianPrice  float64         `json:"median_price,omitempty"`
+	// Sources is each source's price range, cheapest source first
+	Sources []SourcePriceRange `json:"sources"`
+	// Savings is how much the lowest price saves on the priciest listing
+	Savings        float64 `json:"savings,omitempty"`
+	SavingsPercent float64 `json:"savings_percent,omitempty"`
+}
+
+// SummaryProduct points at a product a summary picked out.
+type SummaryProduct struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	Source      string  `json:"source"`
+	URL         string  `json:"url"`
+	PriceValue  float64 `json:"price_value,omitempty"`
+	Currency    string  `json:"currency,omitempty"`
+	RatingValue float64 `json:"rating_value,omitempty"`
+}
+
+// SourcePriceRange is the lowest and highest price one source listed.
+type SourcePriceRange struct {
+	Source   string  `json:"source"`
+	Products int     `json:"products"`
+	MinPrice float64 `json:"min_price"`
+	MaxPrice float64 `json:"max_price"`
+}
+
+// SearchDebug lists what the strict filter removed.
+type SearchDebug struct {
+	Removed []RemovedProduct `json:"removed"`
+}
+
+// RemovedProduct is a result the strict filter dropped.
+type RemovedProduct struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+	URL    string `json:"url,omitempty"`
+	Price  string `json:"price,omitempty"`
+	// Reason is accessory, missing_term, term_order or price_outlier
+	Reason string `json:"reason"`
+	Detail string `json:"detail"`
 }
 
 type Filters struct {
@@ -39,10 +239,34 @@ type Filters struct {
 	InStock   *bool   `json:"in_stock,omitempty"`
 	MinRating float64 `json:"min_rating,omitempty"`
 	Source    string  `json:"source,omitempty"`
+	// MinDiscount keeps products at least this many percent off
+	MinDiscount float64 `json:"min_discount,omitempty"`
+	// RequiresMembership false drops warehouse-club offers, true keeps only
+	// them
+	RequiresMembership *bool `json:"requires_membership,omitempty"`
+	// Category keeps products in this category or its subcategories
+	Category string `json:"category,omitempty"`
+	// Seller keeps products sold by a matching seller, the retailer itself
+	// for its own listings
+	Seller string `json:"seller,omitempty"`
+	// ExcludeMarketplaceSellers keeps only the retailers' own offers
+	ExcludeMarketplaceSellers bool `json:"exclude_marketplace_sellers,omitempty"`
+	// Condition keeps new, used or refurbished products; listings that
+	// don't state a condition count as new
+	Condition string `json:"condition,omitempty"`
+	// Strict drops accessories and other results that don't really match
+	// the query; the response's debug field says why each was dropped
+	Strict *bool `json:"strict,omitempty"`
+}
+
+// StrictOn reports whether the strict result filter is on. It is safe on a
+// nil f.
+func (f *Filters) StrictOn() bool {
+	return f != nil && f.Strict != nil && *f.Strict
 }
 
 type Sort struct {
-	Field string `json:"field"` // price, rating, name
+	Field string `json:"field"` // price, rating, name, discount_percent
 	Order string `json:"order"` // asc, desc
 }
 
@@ -53,10 +277,60 @@ type SearchParams struct {
 	Limit   int      `json:"limit"`
 	Filters *Filters `json:"filters,omitempty"`
 	Sort    *Sort    `json:"sort,omitempty"`
+	// Preferences are applied after the cache and never part of its key
+	Preferences *Preferences `json:"preferences,omitempty"`
+	// ResultSetID pages through the results of an earlier response instead
+	// of searching again
+	ResultSetID string `json:"result_set_id,omitempty"`
+	// Debug skips cached results so every source is searched, for the
+	// diagnostics debug=true asks for; it is never stored
+	Debug bool `json:"-"`
+}
+
+// Preferences personalize results for one user: preferred retailers are
+// listed first, excluded sellers are dropped, prices are converted to
+// Currency and SafeSearch hides adult products.
+type Preferences struct {
+	PreferredRetailers []string `json:"preferred_retailers,omitempty"`
+	ExcludedSellers    []string `json:"excluded_sellers,omitempty"`
+	Currency           string   `json:"currency,omitempty"`
+	SafeSearch         string   `json:"safe_search,omitempty"`
+}
+
+// Safe search levels; moderate hides explicit products, strict also hides
+// mature ones such as alcohol and tobacco.
+const (
+	SafeSearchOff      = "off"
+	SafeSearchModerate = "moderate"
+	SafeSearchStrict   = "strict"
+)
+
+var SafeSearchLevels = []string{SafeSearchOff, SafeSearchModerate, SafeSearchStrict}
+
+// Empty reports whether p changes nothing. It is safe on a nil p.
+func (p *Preferences) Empty() bool {
+	return p == nil || (len(p.PreferredRetailers) == 0 && len(p.ExcludedSellers) == 0 && p.Currency == "" &&
+		(p.SafeSearch == "" || p.SafeSearch == SafeSearchOff))
+}
+
+// RefineRequest changes the state of a search session. Set fields replace the
+// current value; ClearFilters drops all filters before applying Filters.
+type RefineRequest struct {
+	Filters      *Filters `json:"filters,omitempty"`
+	Sort         *Sort    `json:"sort,omitempty"`
+	Page         int      `json:"page,omitempty"`
+	Limit        int      `json:"limit,omitempty"`
+	ClearFilters bool     `json:"clear_filters,omitempty"`
+	ClearSort    bool     `json:"clear_sort,omitempty"`
 }
 
+// ErrorResponse is the body of every error. Error is the specific
+// machine-readable code ("invalid_sort") and Type the broad kind clients
+// branch on: validation, unsupported_country, blocked, timeout, upstream or
+// internal.
 type ErrorResponse struct {
 	Error   string `json:"error"`
+	Type    string `json:"type,omitempty"`
 	Code    int    `json:"code"`
 	Message string `json:"message"`
 	Details string `json:"details,omitempty"`
@@ -0,0 +1,63 @@
+package orgs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"price-comparison-api/internal/alerts"
+)
+
+// Notifier posts fired organization alerts to the organization's channels.
+type Notifier struct {
+	store  *Store
+	client *http.Client
+}
+
+func NewNotifier(store *Store, timeout time.Duration) *Notifier {
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &Notifier{store: store, client: &http.Client{Timeout: timeout}}
+}
+
+// Deliver sends event to every channel of its organization that takes its
+// type. Each delivery runs in the background so a slow webhook can't hold up
+// alert evaluation.
+func (n *Notifier) Deliver(event alerts.Event) {
+	if event.OrgID == "" {
+		return
+	}
+	o, err := n.store.Get(event.OrgID)
+	if err != nil {
+		log.Warn().Err(err).Msgf("No organization %s for alert %s", event.OrgID, event.AlertID)
+		return
+	}
+	for _, ch := range o.Channels {
+		if ch.wants(event.Type) {
+			go n.post(ch, event)
+		}
+	}
+}
+
+func (n *Notifier) post(ch Channel, event alerts.Event) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	resp, err := n.client.Post(ch.URL, "application/json", bytes.NewReader(body))
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			err = fmt.Errorf("status %d", resp.StatusCode)
+		}
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("org_id", event.OrgID).Str("channel_id", ch.ID).Msg("Alert channel delivery failed")
+		return
+	}
+	log.Debug().Str("org_id", event.OrgID).Str("channel_id", ch.ID).Msgf("Delivered alert %s", event.AlertID)
+}
@@ -0,0 +1,209 @@
+package orgs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Member roles, from least to most access
+const (
+	RoleViewer = "viewer" // read the watchlist, alerts and channels
+	RoleEditor = "editor" // also change the watchlist, alerts and channels
+	RoleAdmin  = "admin"  // also manage members and the organization
+)
+
+// Roles lists the member roles.
+var Roles = []string{RoleViewer, RoleEditor, RoleAdmin}
+
+var roleRank = map[string]int{RoleViewer: 1, RoleEditor: 2, RoleAdmin: 3}
+
+// ChannelWebhook is the only channel type: fired alerts are POSTed as JSON.
+const ChannelWebhook = "webhook"
+
+// ErrNotFound is returned for unknown organizations, and for organizations
+// the caller isn't a member of so their IDs can't be probed.
+var ErrNotFound = fmt.Errorf("organization not found")
+
+type Organization struct {
+	ID        string      `json:"id"`
+	Name      string      `json:"name"`
+	Members   []Member    `json:"members"`
+	Watchlist []WatchItem `json:"watchlist"`
+	Channels  []Channel   `json:"channels"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// Member is a caller, identified like preference profiles (a hash of the API
+// key, or a gateway user ID), and its role.
+type Member struct {
+	ID      string    `json:"id"`
+	Role    string    `json:"role"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+// WatchItem is a product the team follows.
+type WatchItem struct {
+	ID         string    `json:"id"`
+	ProductKey string    `json:"product_key"`
+	Name       string    `json:"name,omitempty"`
+	URL        string    `json:"url,omitempty"`
+	Country    string    `json:"country,omitempty"`
+	Note       string    `json:"note,omitempty"`
+	AddedBy    string    `json:"added_by"`
+	AddedAt    time.Time `json:"added_at"`
+}
+
+// Channel receives the organization's fired alerts.
+type Channel struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	Type string `json:"type"`
+	URL  string `json:"url"`
+	// AlertTypes limits the channel to some alert types; empty means all
+	AlertTypes []string  `json:"alert_types,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ValidRole reports whether role is a known member role.
+func ValidRole(role string) bool {
+	_, ok := roleRank[role]
+	return ok
+}
+
+// Role returns the member's role, or "" for non-members.
+func (o *Organization) Role(member string) string {
+	for _, m := range o.Members {
+		if m.ID == member {
+			return m.Role
+		}
+	}
+	return ""
+}
+
+// Can reports whether the member has at least the given role.
+func (o *Organization) Can(member, role string) bool {
+	return roleRank[o.Role(member)] >= roleRank[role]
+}
+
+// SetMember adds a member or changes its role. The last admin can't be
+// demoted.
+func (o *Organization) SetMember(member, role string) error {
+	if !ValidRole(role) {
+		return fmt.Errorf("invalid role: %s (use %s)", role, strings.Join(Roles, ", "))
+	}
+	for i, m := range o.Members {
+		if m.ID != member {
+			continue
+		}
+		if m.Role == RoleAdmin && role != RoleAdmin && o.admins() == 1 {
+			return fmt.Errorf("an organization needs at least one admin")
+		}
+		o.Members[i].Role = role
+		return nil
+	}
+	o.Members = append(o.Members, Member{ID: member, Role: role, AddedAt: time.Now()})
+	return nil
+}
+
+// RemoveMember removes a member. The last admin can't be removed.
+func (o *Organization) RemoveMember(member string) error {
+	for i, m := range o.Members {
+		if m.ID != member {
+			continue
+		}
+		if m.Role == RoleAdmin && o.admins() == 1 {
+			return fmt.Errorf("an organization needs at least one admin")
+		}
+		o.Members = append(o.Members[:i], o.Members[i+1:]...)
+		return nil
+	}
+	return fmt.Errorf("member not found")
+}
+
+func (o *Organization) admins() int {
+	n := 0
+	for _, m := range o.Members {
+		if m.Role == RoleAdmin {
+			n++
+		}
+	}
+	return n
+}
+
+// AddWatch adds a product to the watchlist, or returns the existing item for
+// the same product.
+func (o *Organization) AddWatch(item WatchItem) WatchItem {
+	for _, existing := range o.Watchlist {
+		if existing.ProductKey == item.ProductKey {
+			return existing
+		}
+	}
+	item.ID = newID()
+	item.AddedAt = time.Now()
+	o.Watchlist = append(o.Watchlist, item)
+	return item
+}
+
+func (o *Organization) RemoveWatch(id string) error {
+	for i, item := range o.Watchlist {
+		if item.ID == id {
+			o.Watchlist = append(o.Watchlist[:i], o.Watchlist[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("watchlist item not found")
+}
+
+// AddChannel validates and adds an alert channel.
+func (o *Organization) AddChannel(ch Channel) (Channel, error) {
+	ch.Type = strings.ToLower(strings.TrimSpace(ch.Type))
+	if ch.Type == "" {
+		ch.Type = ChannelWebhook
+	}
+	if ch.Type != ChannelWebhook {
+		return ch, fmt.Errorf("unsupported channel type: %s (use %s)", ch.Type, ChannelWebhook)
+	}
+	u, err := url.Parse(ch.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return ch, fmt.Errorf("url must be an http(s) URL")
+	}
+	ch.ID = newID()
+	ch.CreatedAt = time.Now()
+	o.Channels = append(o.Channels, ch)
+	return ch, nil
+}
+
+func (o *Organization) RemoveChannel(id string) error {
+	for i, ch := range o.Channels {
+		if ch.ID == id {
+			o.Channels = append(o.Channels[:i], o.Channels[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("channel not found")
+}
+
+// wants reports whether the channel takes alerts of the given type.
+func (ch Channel) wants(alertType string) bool {
+	if len(ch.AlertTypes) == 0 {
+		return true
+	}
+	for _, t := range ch.AlertTypes {
+		if t == alertType {
+			return true
+		}
+	}
+	return false
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,158 @@
+package orgs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	bolt "go.etcd.io/bbolt"
+	"price-comparison-api/internal/config"
+)
+
+var orgsBucket = []byte("orgs")
+
+// Store keeps organizations in a bolt database.
+type Store struct {
+	db *bolt.DB
+}
+
+func NewStore(cfg config.OrgsConfig) (*Store, error) {
+	path := cfg.Path
+	if path == "" {
+		path = filepath.Join(os.TempDir(), "price-comparison-orgs.db")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create orgs directory: %v", err)
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open orgs store: %v", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(orgsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to init orgs store: %v", err)
+	}
+
+	log.Info().Msgf("Organizations ready at %s", path)
+	return &Store{db: db}, nil
+}
+
+// Create stores a new organization with owner as its only admin.
+func (s *Store) Create(name, owner string) (*Organization, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	now := time.Now()
+	o := &Organization{
+		ID:        newID(),
+		Name:      name,
+		Members:   []Member{{ID: owner, Role: RoleAdmin, AddedAt: now}},
+		Watchlist: []WatchItem{},
+		Channels:  []Channel{},
+		CreatedAt: now,
+	}
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return put(tx, o)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func (s *Store) Get(id string) (*Organization, error) {
+	var o *Organization
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		o, err = get(tx, id)
+		return err
+	})
+	return o, err
+}
+
+// ForMember returns the organizations member belongs to, oldest first.
+func (s *Store) ForMember(member string) ([]Organization, error) {
+	list := []Organization{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(orgsBucket).ForEach(func(k, v []byte) error {
+			var o Organization
+			if err := json.Unmarshal(v, &o); err != nil {
+				log.Warn().Err(err).Msgf("Skipping unreadable organization %s", k)
+				return nil
+			}
+			if o.Role(member) != "" {
+				list = append(list, o)
+			}
+			return nil
+		})
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list, err
+}
+
+// Update applies fn to the organization and saves it unless fn fails.
+func (s *Store) Update(id string, fn func(o *Organization) error) (*Organization, error) {
+	var o *Organization
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		if o, err = get(tx, id); err != nil {
+			return err
+		}
+		if err := fn(o); err != nil {
+			return err
+		}
+		return put(tx, o)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func (s *Store) Delete(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(orgsBucket)
+		if bucket.Get([]byte(id)) == nil {
+			return ErrNotFound
+		}
+		return bucket.Delete([]byte(id))
+	})
+}
+
+func (s *Store) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+func get(tx *bolt.Tx, id string) (*Organization, error) {
+	raw := tx.Bucket(orgsBucket).Get([]byte(id))
+	if raw == nil {
+		return nil, ErrNotFound
+	}
+	var o Organization
+	if err := json.Unmarshal(raw, &o); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+func put(tx *bolt.Tx, o *Organization) error {
+	raw, err := json.Marshal(o)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(orgsBucket).Put([]byte(o.ID), raw)
+}
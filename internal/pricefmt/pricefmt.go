@@ -0,0 +1,229 @@
+// Package pricefmt rounds and formats prices the way the locale of the
+// country they were searched in writes them, from a small table of CLDR
+// (ICU) currency and number formatting data.
+package pricefmt
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Price formats a response can ask for
+const (
+	// Raw returns prices as the float they were parsed or converted to
+	Raw = "raw"
+	// Rounded rounds prices to the currency's minor unit
+	Rounded = "rounded"
+	// Localized returns prices as strings formatted for the country
+	Localized = "localized"
+)
+
+// Formats lists the accepted price formats.
+var Formats = []string{Raw, Rounded, Localized}
+
+// Valid reports whether format is one of Formats.
+func Valid(format string) bool {
+	for _, f := range Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// currency is CLDR's data for an ISO 4217 currency: how many fraction
+// digits it has, its symbol outside its home locale and its narrow symbol
+// at home.
+type currency struct {
+	digits int
+	symbol string
+	narrow string
+}
+
+var currencies = map[string]currency{
+	"USD": {2, "US$", "$"},
+	"EUR": {2, "€", "€"},
+	"GBP": {2, "£", "£"},
+	"INR": {2, "₹", "₹"},
+	"JPY": {0, "¥", "￥"},
+	"CNY": {2, "CN¥", "¥"},
+	"KRW": {0, "₩", "₩"},
+	"CAD": {2, "CA$", "$"},
+	"AUD": {2, "A$", "$"},
+	"NZD": {2, "NZ$", "$"},
+	"MXN": {2, "MX$", "$"},
+	"BRL": {2, "R$", "R$"},
+	"ARS": {2, "ARS", "$"},
+	"CLP": {0, "CLP", "$"},
+	"COP": {2, "COP", "$"},
+	"CHF": {2, "CHF", "CHF"},
+	"SEK": {2, "SEK", "kr"},
+	"NOK": {2, "NOK", "kr"},
+	"DKK": {2, "DKK", "kr."},
+	"PLN": {2, "PLN", "zł"},
+	"TRY": {2, "TRY", "₺"},
+	"AED": {2, "AED", "د.إ."},
+	"SGD": {2, "SGD", "$"},
+	"VND": {0, "₫", "₫"},
+	"BHD": {3, "BHD", "د.ب."},
+	"JOD": {3, "JOD", "د.أ."},
+	"KWD": {3, "KWD", "د.ك."},
+	"OMR": {3, "OMR", "ر.ع."},
+	"TND": {3, "TND", "د.ت."},
+}
+
+// locale is how a country writes amounts of money.
+type locale struct {
+	// currency is the country's own currency, shown with its narrow symbol
+	currency string
+	decimal  string
+	group    string
+	// secondary is the size of groups after the first three digits, where
+	// it differs: 2 for the Indian 1,23,45,678
+	secondary int
+	// minGrouping is the fewest integer digits grouping starts at; Spanish
+	// writes 1299 but 12.999
+	minGrouping int
+	// symbolAfter puts the symbol after the number, "1.299,99 €"
+	symbolAfter bool
+	// spaced separates the symbol from the number, "R$ 1.299,99"
+	spaced bool
+}
+
+const (
+	nbsp       = "\u00a0"
+	narrowNbsp = "\u202f"
+)
+
+var locales = map[string]locale{
+	"US": {currency: "USD", decimal: ".", group: ","},
+	"UK": {currency: "GBP", decimal: ".", group: ","},
+	"GB": {currency: "GBP", decimal: ".", group: ","},
+	"IN": {currency: "INR", decimal: ".", group: ",", secondary: 2},
+	"CA": {currency: "CAD", decimal: ".", group: ","},
+	"AU": {currency: "AUD", decimal: ".", group: ","},
+	"NZ": {currency: "NZD", decimal: ".", group: ","},
+	"SG": {currency: "SGD", decimal: ".", group: ","},
+	"JP": {currency: "JPY", decimal: ".", group: ","},
+	"CN": {currency: "CNY", decimal: ".", group: ","},
+	"KR": {currency: "KRW", decimal: ".", group: ","},
+	"MX": {currency: "MXN", decimal: ".", group: ","},
+	"DE": {currency: "EUR", decimal: ",", group: ".", symbolAfter: true, spaced: true},
+	"AT": {currency: "EUR", decimal: ",", group: nbsp, spaced: true},
+	"FR": {currency: "EUR", decimal: ",", group: narrowNbsp, symbolAfter: true, spaced: true},
+	"IT": {currency: "EUR", decimal: ",", group: ".", symbolAfter: true, spaced: true},
+	"ES": {currency: "EUR", decimal: ",", group: ".", minGrouping: 5, symbolAfter: true, spaced: true},
+	"NL": {currency: "EUR", decimal: ",", group: ".", spaced: true},
+	"BE": {currency: "EUR", decimal: ",", group: ".", symbolAfter: true, spaced: true},
+	"IE": {currency: "EUR", decimal: ".", group: ","},
+	"PT": {currency: "EUR", decimal: ",", group: nbsp, symbolAfter: true, spaced: true},
+	"CH": {currency: "CHF", decimal: ".", group: "’", spaced: true},
+	"SE": {currency: "SEK", decimal: ",", group: nbsp, symbolAfter: true, spaced: true},
+	"NO": {currency: "NOK", decimal: ",", group: nbsp, symbolAfter: true, spaced: true},
+	"DK": {currency: "DKK", decimal: ",", group: ".", symbolAfter: true, spaced: true},
+	"PL": {currency: "PLN", decimal: ",", group: nbsp, minGrouping: 5, symbolAfter: true, spaced: true},
+	"TR": {currency: "TRY", decimal: ",", group: "."},
+	"BR": {currency: "BRL", decimal: ",", group: ".", spaced: true},
+	"AR": {currency: "ARS", decimal: ",", group: ".", spaced: true},
+	"CL": {currency: "CLP", decimal: ",", group: ".", spaced: true},
+	"CO": {currency: "COP", decimal: ",", group: ".", spaced: true},
+	"AE": {currency: "AED", decimal: ".", group: ",", spaced: true},
+	"VN": {currency: "VND", decimal: ",", group: ".", symbolAfter: true, spaced: true},
+}
+
+// defaultLocale formats prices for countries without locale data.
+var defaultLocale = locales["US"]
+
+// Currency returns the currency prices in country are in, or "" when the
+// country has no locale data.
+func Currency(country string) string {
+	return locales[strings.ToUpper(country)].currency
+}
+
+// Digits returns how many fraction digits code has, 2 when unknown.
+func Digits(code string) int {
+	if c, ok := currencies[strings.ToUpper(code)]; ok {
+		return c.digits
+	}
+	return 2
+}
+
+// Round rounds value to code's minor unit: cents, or whole yen.
+func Round(value float64, code string) float64 {
+	scale := math.Pow10(Digits(code))
+	return math.Round(value*scale) / scale
+}
+
+// Localize formats value in code the way country writes it, with the
+// currency's narrow symbol in its own country and its international symbol
+// or ISO code elsewhere: "$1,299.99" in the US, "US$1,299.99" in Canada,
+// "1.299,99 €" in Germany and "₹1,29,999.00" in India. Prices without a
+// currency are taken to be in the country's.
+func Localize(value float64, code, country string) string {
+	loc, ok := locales[strings.ToUpper(country)]
+	if !ok {
+		loc = defaultLocale
+	}
+	code = strings.ToUpper(code)
+	if code == "" {
+		code = loc.currency
+	}
+
+	symbol := code
+	if c, ok := currencies[code]; ok {
+		symbol = c.symbol
+		if code == loc.currency {
+			symbol = c.narrow
+		}
+	}
+
+	number := formatNumber(math.Abs(value), Digits(code), loc)
+	spacer := ""
+	if loc.spaced || symbol == code {
+		spacer = nbsp
+	}
+	formatted := symbol + spacer + number
+	if loc.symbolAfter {
+		formatted = number + nbsp + symbol
+	}
+	if Round(value, code) < 0 {
+		formatted = "-" + formatted
+	}
+	return formatted
+}
+
+// formatNumber writes a non-negative value with digits fraction digits and
+// loc's separators.
+func formatNumber(value float64, digits int, loc locale) string {
+	text := fmt.Sprintf("%.*f", digits, value)
+	integer, fraction, _ := strings.Cut(text, ".")
+
+	minGrouping := loc.minGrouping
+	if minGrouping == 0 {
+		minGrouping = 4
+	}
+	if len(integer) >= minGrouping {
+		integer = group(integer, loc)
+	}
+	if fraction == "" {
+		return integer
+	}
+	return integer + loc.decimal + fraction
+}
+
+// group inserts loc's group separator into a run of digits: every three
+// digits, or three and then every secondary digits.
+func group(digits string, loc locale) string {
+	var groups []string
+	size := 3
+	for len(digits) > size {
+		groups = append([]string{digits[len(digits)-size:]}, groups...)
+		digits = digits[:len(digits)-size]
+		if loc.secondary > 0 {
+			size = loc.secondary
+		}
+	}
+	groups = append([]string{digits}, groups...)
+	return strings.Join(groups, loc.group)
+}
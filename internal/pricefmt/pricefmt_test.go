@@ -0,0 +1,109 @@
+package pricefmt
+
+import "testing"
+
+func TestLocalize(t *testing.T) {
+	for _, tc := range []struct {
+		value   float64
+		code    string
+		country string
+		want    string
+	}{
+		{1299.99, "USD", "US", "$1,299.99"},
+		{19.5, "USD", "US", "$19.50"},
+		{1299.99, "USD", "CA", "US$1,299.99"},
+		{1299.99, "CAD", "CA", "$1,299.99"},
+		{1299.99, "GBP", "UK", "£1,299.99"},
+		{1299.99, "EUR", "IE", "€1,299.99"},
+		{1299.99, "EUR", "DE", "1.299,99\u00a0€"},
+		{1299.99, "USD", "DE", "1.299,99\u00a0US$"},
+		{1299.99, "EUR", "FR", "1\u202f299,99\u00a0€"},
+		{1299.99, "EUR", "IT", "1.299,99\u00a0€"},
+		{1299.99, "EUR", "NL", "€\u00a01.299,99"},
+		{1299.99, "EUR", "AT", "€\u00a01\u00a0299,99"},
+		// Spanish and Polish group from five digits
+		{1299.99, "EUR", "ES", "1299,99\u00a0€"},
+		{12999.99, "EUR", "ES", "12.999,99\u00a0€"},
+		{1299.99, "PLN", "PL", "1299,99\u00a0zł"},
+		{12999.99, "PLN", "PL", "12\u00a0999,99\u00a0zł"},
+		// Indian grouping: three digits, then two
+		{129999, "INR", "IN", "₹1,29,999.00"},
+		{12345678.5, "INR", "IN", "₹1,23,45,678.50"},
+		{999, "INR", "IN", "₹999.00"},
+		{1299.4, "JPY", "JP", "￥1,299"},
+		{1299.4, "JPY", "US", "¥1,299"},
+		{45000, "KRW", "KR", "₩45,000"},
+		{1299.99, "CHF", "CH", "CHF\u00a01’299.99"},
+		{1299.99, "BRL", "BR", "R$\u00a01.299,99"},
+		{1299.99, "MXN", "MX", "$1,299.99"},
+		{15990, "CLP", "CL", "$\u00a015.990"},
+		{12.5, "KWD", "US", "KWD\u00a012.500"},
+		{1299.99, "SEK", "SE", "1\u00a0299,99\u00a0kr"},
+		{1299.99, "TRY", "TR", "₺1.299,99"},
+		// Prices without a currency are in the country's
+		{1299.99, "", "DE", "1.299,99\u00a0€"},
+		{1299.99, "", "IN", "₹1,299.99"},
+		// Countries without locale data are written as in the US
+		{1299.99, "USD", "ZZ", "$1,299.99"},
+		{1299.99, "eur", "zz", "€1,299.99"},
+		// Currencies without data are shown by code
+		{1299.99, "XYZ", "US", "XYZ\u00a01,299.99"},
+		{-5, "USD", "US", "-$5.00"},
+		{-5, "EUR", "DE", "-5,00\u00a0€"},
+		{-0.004, "USD", "US", "$0.00"},
+	} {
+		if got := Localize(tc.value, tc.code, tc.country); got != tc.want {
+			t.Errorf("Localize(%v, %q, %q) = %q, want %q", tc.value, tc.code, tc.country, got, tc.want)
+		}
+	}
+}
+
+func TestRound(t *testing.T) {
+	for _, tc := range []struct {
+		value float64
+		code  string
+		want  float64
+	}{
+		{19.994, "USD", 19.99},
+		{19.996, "USD", 20},
+		{1299.6, "JPY", 1300},
+		{1299.4, "jpy", 1299},
+		{1.23456, "KWD", 1.235},
+		{1.23456, "XYZ", 1.23},
+	} {
+		if got := Round(tc.value, tc.code); got != tc.want {
+			t.Errorf("Round(%v, %q) = %v, want %v", tc.value, tc.code, got, tc.want)
+		}
+	}
+}
+
+func TestCurrency(t *testing.T) {
+	for _, tc := range []struct {
+		country string
+		want    string
+	}{
+		{"US", "USD"},
+		{"in", "INR"},
+		{"GB", "GBP"},
+		{"UK", "GBP"},
+		{"FR", "EUR"},
+		{"ZZ", ""},
+	} {
+		if got := Currency(tc.country); got != tc.want {
+			t.Errorf("Currency(%q) = %q, want %q", tc.country, got, tc.want)
+		}
+	}
+}
+
+func TestValid(t *testing.T) {
+	for _, format := range Formats {
+		if !Valid(format) {
+			t.Errorf("Valid(%q) = false", format)
+		}
+	}
+	for _, format := range []string{"", "Raw", "pretty"} {
+		if Valid(format) {
+			t.Errorf("Valid(%q) = true", format)
+		}
+	}
+}
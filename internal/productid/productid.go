@@ -0,0 +1,187 @@
+// Package productid gives product listings IDs that stay the same from one
+// scrape to the next: the retailer's own ID for the listing (an Amazon ASIN,
+// an eBay item number, a Flipkart PID) when the listing URL carries one, or
+// a hash of the normalized URL when it doesn't, so clients can diff results.
+package productid
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// nativePatterns find a retailer's own listing ID in a listing URL's path
+// or query, keyed by scraper name. The first group is the ID.
+var nativePatterns = map[string][]*regexp.Regexp{
+	"amazon": {
+		regexp.MustCompile(`/(?:dp|gp/product|gp/aw/d|exec/obidos/asin)/([A-Z0-9]{10})(?:[/?]|$)`),
+	},
+	"ebay": {
+		regexp.MustCompile(`/itm/(?:[^/?]+/)?(\d{9,15})(?:[/?]|$)`),
+		regexp.MustCompile(`[?&]item=(\d{9,15})(?:&|$)`),
+	},
+	"flipkart": {
+		regexp.MustCompile(`[?&]pid=([A-Z0-9]{10,20})(?:&|$)`),
+		regexp.MustCompile(`/p/(itm[a-z0-9]+)(?:[/?]|$)`),
+	},
+	"walmart": {
+		regexp.MustCompile(`/ip/(?:[^/?]+/)?(\d{5,15})(?:[/?]|$)`),
+	},
+	"target": {
+		regexp.MustCompile(`/A-(\d{6,10})(?:[/?#]|$)`),
+	},
+	"bestbuy": {
+		regexp.MustCompile(`[?&]skuId=(\d{5,10})(?:&|$)`),
+		regexp.MustCompile(`/(\d{7})\.p(?:[/?]|$)`),
+	},
+	"google_shopping": {
+		regexp.MustCompile(`/shopping/product/(\d{5,25})(?:[/?]|$)`),
+	},
+	"aliexpress": {
+		regexp.MustCompile(`/item/(\d{8,20})\.html`),
+	},
+	"etsy": {
+		regexp.MustCompile(`/listing/(\d{6,12})(?:[/?]|$)`),
+	},
+	"costco": {
+		regexp.MustCompile(`\.product\.(\d{6,12})\.html`),
+	},
+	"samsclub": {
+		regexp.MustCompile(`/prod(\d{6,12})(?:[/?]|$)`),
+		regexp.MustCompile(`/ip/(?:[^/?]+/)?(\d{6,12})(?:[/?]|$)`),
+	},
+	"mercadolibre": {
+		regexp.MustCompile(`/(ML[A-Z])-?(\d{6,12})(?:[-/?_]|$)`),
+	},
+}
+
+// trackingParams are query parameters that say how a listing was reached,
+// not which listing it is; they are dropped before hashing a URL.
+var trackingParams = map[string]bool{
+	"ref": true, "ref_": true, "tag": true, "psc": true, "qid": true, "sr": true,
+	"keywords": true, "crid": true, "sprefix": true, "dib": true, "dib_tag": true,
+	"hash": true, "amdata": true, "itmmeta": true, "_trkparms": true, "_trksid": true,
+	"spm": true, "algo_pvid": true, "algo_exp_id": true, "pdp_npi": true,
+	"lid": true, "marketplace": true, "srno": true, "otracker": true, "fm": true,
+	"iid": true, "ppt": true, "ppn": true, "ssid": true, "from": true,
+	"athcpid": true, "athpgid": true, "athznid": true, "athieid": true, "athstid": true,
+	"athguid": true, "athancid": true, "athena": true, "classtype": true, "adsredirect": true,
+	"gclid": true, "fbclid": true, "clickid": true, "srsltid": true, "ga_order": true,
+	"ga_search_query": true, "ga_search_type": true, "ga_view_type": true,
+}
+
+// For returns a listing's stable ID, source_country_native when the URL
+// carries the retailer's ID and source_country_hash otherwise, along with
+// the native ID. Listings without a URL are hashed by name.
+func For(source, country, rawURL, name string) (id, native string) {
+	prefix := source + "_" + strings.ToLower(country)
+	if native = Native(source, rawURL); native != "" {
+		return prefix + "_" + native, native
+	}
+	key := normalizeURL(rawURL)
+	if key == "" {
+		key = "name:" + strings.ToLower(strings.Join(strings.Fields(name), " "))
+	}
+	sum := sha256.Sum256([]byte(source + "|" + strings.ToUpper(country) + "|" + key))
+	return prefix + "_" + hex.EncodeToString(sum[:8]), ""
+}
+
+// idPattern splits a listing ID into source, country and the native ID or
+// hash; sources may themselves contain underscores, as google_shopping does.
+var idPattern = regexp.MustCompile(`^([a-z0-9_]+?)_([a-z]{2})_([^_].*)$`)
+
+// Parse splits an ID made by For into its source, upper-case country and
+// the native ID or hash.
+func Parse(id string) (source, country, rest string, ok bool) {
+	m := idPattern.FindStringSubmatch(id)
+	if m == nil {
+		return "", "", "", false
+	}
+	return m[1], strings.ToUpper(m[2]), m[3], true
+}
+
+// Native returns the retailer's own ID for the listing at rawURL, or "".
+// Sponsored links that wrap the listing URL in a url parameter, as Amazon's
+// do, are looked through.
+func Native(source, rawURL string) string {
+	patterns := nativePatterns[source]
+	if len(patterns) == 0 || rawURL == "" {
+		return ""
+	}
+	candidates := []string{rawURL}
+	if u, err := url.Parse(rawURL); err == nil {
+		if inner := u.Query().Get("url"); inner != "" {
+			candidates = append(candidates, inner)
+		}
+	}
+	for _, candidate := range candidates {
+		for _, p := range patterns {
+			if m := p.FindStringSubmatch(candidate); m != nil {
+				return strings.Join(m[1:], "")
+			}
+		}
+	}
+	return ""
+}
+
+// normalizeURL reduces a listing URL to the parts that identify it: host
+// without www, path without a trailing slash, and the query without
+// tracking parameters, sorted.
+func normalizeURL(rawURL string) string {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || u.Host == "" {
+		return strings.TrimSpace(rawURL)
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	path := strings.TrimSuffix(u.Path, "/")
+	if query := identifyingQuery(u.Query()); query != "" {
+		return host + path + "?" + query
+	}
+	return host + path
+}
+
+// CleanURL returns rawURL without tracking parameters or fragment, the
+// shortest link that still opens the listing.
+func CleanURL(rawURL string) string {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	query := u.Query()
+	for k := range query {
+		if tracking(k) {
+			query.Del(k)
+		}
+	}
+	u.RawQuery = query.Encode()
+	u.Fragment = ""
+	return u.String()
+}
+
+// identifyingQuery keeps the query parameters that aren't tracking
+// parameters, sorted.
+func identifyingQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		if !tracking(k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	var params []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			params = append(params, fmt.Sprintf("%s=%s", k, v))
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+func tracking(param string) bool {
+	lower := strings.ToLower(param)
+	return trackingParams[lower] || strings.HasPrefix(lower, "utm_") || strings.HasPrefix(lower, "pf_rd_") || strings.HasPrefix(lower, "pd_rd_")
+}
@@ -0,0 +1,122 @@
+package profiles
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	bolt "go.etcd.io/bbolt"
+	"price-comparison-api/internal/config"
+)
+
+var profilesBucket = []byte("profiles")
+
+// ErrNotFound is returned when the caller has no stored profile.
+var ErrNotFound = fmt.Errorf("no preferences stored")
+
+// Profile holds a caller's search defaults. Every field can be overridden by
+// the matching search parameter.
+type Profile struct {
+	Country          string   `json:"country,omitempty"`
+	Currency         string   `json:"currency,omitempty"`
+	PreferredSources []string `json:"preferred_sources,omitempty"`
+	ExcludedSellers  []string `json:"excluded_sellers,omitempty"`
+	SafeSearch       string   `json:"safe_search,omitempty"`
+	// TimeZone is the IANA time zone dates the caller gives without one,
+	// like an alert's buy_by, are read in
+	TimeZone  string    `json:"time_zone,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Store keeps one profile per owner in a bolt database.
+type Store struct {
+	db *bolt.DB
+}
+
+func NewStore(cfg config.ProfilesConfig) (*Store, error) {
+	path := cfg.Path
+	if path == "" {
+		path = filepath.Join(os.TempDir(), "price-comparison-profiles.db")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create profiles directory: %v", err)
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open profiles store: %v", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(profilesBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to init profiles store: %v", err)
+	}
+
+	log.Info().Msgf("Preference profiles ready at %s", path)
+	return &Store{db: db}, nil
+}
+
+// KeyOwner identifies a caller by API key. Only a hash of the key is stored.
+func KeyOwner(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return "key:" + hex.EncodeToString(sum[:])
+}
+
+// UserOwner identifies a caller by a user ID set by a trusted gateway.
+func UserOwner(userID string) string {
+	return "user:" + strings.TrimSpace(userID)
+}
+
+func (s *Store) Get(owner string) (*Profile, error) {
+	var p *Profile
+	err := s.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket(profilesBucket).Get([]byte(owner))
+		if raw == nil {
+			return ErrNotFound
+		}
+		p = &Profile{}
+		return json.Unmarshal(raw, p)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Put replaces the owner's profile.
+func (s *Store) Put(owner string, p *Profile) error {
+	p.UpdatedAt = time.Now()
+	raw, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(profilesBucket).Put([]byte(owner), raw)
+	})
+}
+
+func (s *Store) Delete(owner string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(profilesBucket)
+		if bucket.Get([]byte(owner)) == nil {
+			return ErrNotFound
+		}
+		return bucket.Delete([]byte(owner))
+	})
+}
+
+func (s *Store) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.db.Close()
+}
@@ -0,0 +1,143 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"price-comparison-api/internal/categories"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+)
+
+const (
+	bestBuyProductsURL = "https://api.bestbuy.com/v1/products"
+	bestBuyFields      = "sku,name,salePrice,regularPrice,url,image,customerReviewAverage,customerReviewCount,onlineAvailability,condition,categoryPath.name"
+)
+
+// bestBuyProvider searches the Best Buy Products API (US only).
+type bestBuyProvider struct {
+	cfg    config.BestBuyAPIConfig
+	client *http.Client
+}
+
+func newBestBuy(cfg config.BestBuyAPIConfig, client *http.Client) *bestBuyProvider {
+	return &bestBuyProvider{cfg: cfg, client: client}
+}
+
+func (b *bestBuyProvider) Name() string { return config.ScraperBestBuy }
+
+func (b *bestBuyProvider) Covers(country string) bool {
+	return strings.ToUpper(country) == "US"
+}
+
+func (b *bestBuyProvider) Endpoint(query, country string) string {
+	return b.endpoint(searchTerms(query), "redacted")
+}
+
+// searchTerms expresses keyword search as one search= term per word:
+// search=gaming&search=laptop
+func searchTerms(query string) string {
+	var terms []string
+	for _, word := range strings.Fields(query) {
+		terms = append(terms, "search="+url.PathEscape(word))
+	}
+	return strings.Join(terms, "&")
+}
+
+// endpoint builds a products query for filter, like
+// /v1/products(search=gaming&search=laptop) or /v1/products(upc=...).
+func (b *bestBuyProvider) endpoint(filter, apiKey string) string {
+	params := url.Values{}
+	params.Set("apiKey", apiKey)
+	params.Set("format", "json")
+	params.Set("show", bestBuyFields)
+	params.Set("pageSize", "50")
+	return fmt.Sprintf("%s(%s)?%s", bestBuyProductsURL, filter, params.Encode())
+}
+
+func (b *bestBuyProvider) Search(ctx context.Context, query, country string) ([]models.Product, error) {
+	if len(strings.Fields(query)) == 0 {
+		return nil, fmt.Errorf("bestbuy api: empty query")
+	}
+	return b.products(ctx, searchTerms(query))
+}
+
+// LookupGTIN filters on the UPC. Best Buy stores 12-digit UPCs, so EAN-13
+// and GTIN-14 codes are shortened when their leading digits are zeros.
+func (b *bestBuyProvider) LookupGTIN(ctx context.Context, gtin, country string) ([]models.Product, error) {
+	for len(gtin) > 12 && gtin[0] == '0' {
+		gtin = gtin[1:]
+	}
+	return b.products(ctx, "upc="+gtin)
+}
+
+func (b *bestBuyProvider) products(ctx context.Context, filter string) ([]models.Product, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.endpoint(filter, b.cfg.APIKey), nil)
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Products []struct {
+			SKU                   int64   `json:"sku"`
+			Name                  string  `json:"name"`
+			SalePrice             float64 `json:"salePrice"`
+			RegularPrice          float64 `json:"regularPrice"`
+			URL                   string  `json:"url"`
+			Image                 string  `json:"image"`
+			CustomerReviewAverage float64 `json:"customerReviewAverage"`
+			CustomerReviewCount   int     `json:"customerReviewCount"`
+			OnlineAvailability    bool    `json:"onlineAvailability"`
+			Condition             string  `json:"condition"`
+			CategoryPath          []struct {
+				Name string `json:"name"`
+			} `json:"categoryPath"`
+		} `json:"products"`
+	}
+	if err := doJSON(b.client, req, &result); err != nil {
+		// The API key travels in the query string; keep it out of logs
+		return nil, fmt.Errorf("bestbuy api: %s", strings.ReplaceAll(err.Error(), b.cfg.APIKey, "***"))
+	}
+
+	products := make([]models.Product, 0, len(result.Products))
+	for _, item := range result.Products {
+		if item.Name == "" || item.SalePrice <= 0 {
+			continue
+		}
+		p := models.Product{
+			ID:              fmt.Sprintf("bestbuy_us_%d", item.SKU),
+			SourceProductID: strconv.FormatInt(item.SKU, 10),
+			Name:            item.Name,
+			Price:           formatPrice(item.SalePrice, "USD"),
+			PriceValue:      item.SalePrice,
+			Currency:        "USD",
+			URL:             item.URL,
+			Image:           item.Image,
+			Source:          "Best Buy US",
+			ScrapedAt:       time.Now(),
+			InStock:         item.OnlineAvailability,
+			Merchant:        "Best Buy",
+			Condition:       normalizeCondition(item.Condition),
+		}
+		var path []string
+		for _, c := range item.CategoryPath {
+			path = append(path, c.Name)
+		}
+		p.Category = categories.Detect(strings.Join(path, " "), item.Name)
+		if item.CustomerReviewAverage > 0 {
+			p.Rating = strconv.FormatFloat(item.CustomerReviewAverage, 'f', 1, 64) + "/5"
+			p.Reviews = strconv.Itoa(item.CustomerReviewCount)
+		}
+		if item.RegularPrice > item.SalePrice {
+			p.OriginalPrice = formatPrice(item.RegularPrice, "USD")
+			p.Discount = math.Round((item.RegularPrice-item.SalePrice)/item.RegularPrice*1000) / 10
+		}
+		products = append(products, p)
+	}
+	return products, nil
+}
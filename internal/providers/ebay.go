@@ -0,0 +1,204 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"price-comparison-api/internal/categories"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+)
+
+const (
+	ebayTokenURL  = "https://api.ebay.com/identity/v1/oauth2/token"
+	ebaySearchURL = "https://api.ebay.com/buy/browse/v1/item_summary/search"
+	ebayScope     = "https://api.ebay.com/oauth/api_scope"
+)
+
+// ebayMarketplaces maps countries to Browse API marketplace IDs.
+var ebayMarketplaces = map[string]string{
+	"US": "EBAY_US", "UK": "EBAY_GB", "DE": "EBAY_DE", "CA": "EBAY_CA",
+	"AU": "EBAY_AU", "FR": "EBAY_FR", "IT": "EBAY_IT", "ES": "EBAY_ES",
+}
+
+// ebayProvider searches the eBay Browse API with an application token,
+// fetched with the client credentials grant and reused until it expires.
+type ebayProvider struct {
+	cfg    config.EbayAPIConfig
+	client *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func newEbay(cfg config.EbayAPIConfig, client *http.Client) *ebayProvider {
+	return &ebayProvider{cfg: cfg, client: client}
+}
+
+func (e *ebayProvider) Name() string { return config.ScraperEbay }
+
+func (e *ebayProvider) Covers(country string) bool {
+	_, ok := ebayMarketplaces[strings.ToUpper(country)]
+	return ok
+}
+
+// Endpoint is the same for every marketplace, which is picked by header.
+func (e *ebayProvider) Endpoint(query, country string) string {
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("limit", "50")
+	return ebaySearchURL + "?" + params.Encode()
+}
+
+func (e *ebayProvider) Search(ctx context.Context, query, country string) ([]models.Product, error) {
+	return e.search(ctx, e.Endpoint(query, country), country)
+}
+
+// LookupGTIN uses the Browse API's gtin filter, which matches listings
+// whose product identifier is gtin.
+func (e *ebayProvider) LookupGTIN(ctx context.Context, gtin, country string) ([]models.Product, error) {
+	params := url.Values{}
+	params.Set("gtin", gtin)
+	params.Set("limit", "50")
+	return e.search(ctx, ebaySearchURL+"?"+params.Encode(), country)
+}
+
+func (e *ebayProvider) search(ctx context.Context, endpoint, country string) ([]models.Product, error) {
+	country = strings.ToUpper(country)
+	marketplace, ok := ebayMarketplaces[country]
+	if !ok {
+		return nil, fmt.Errorf("ebay api: country %s not supported", country)
+	}
+	token, err := e.accessToken(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("ebay api: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("X-EBAY-C-MARKETPLACE-ID", marketplace)
+
+	var result struct {
+		ItemSummaries []struct {
+			ItemID string `json:"itemId"`
+			// LegacyItemID is the item number in listing URLs, which
+			// scraped eBay listings are identified by
+			LegacyItemID string `json:"legacyItemId"`
+			Title        string `json:"title"`
+			ItemWebURL   string `json:"itemWebUrl"`
+			Condition    string `json:"condition"`
+			Price        amount `json:"price"`
+			Image        struct {
+				ImageURL string `json:"imageUrl"`
+			} `json:"image"`
+			Seller struct {
+				Username           string `json:"username"`
+				FeedbackPercentage string `json:"feedbackPercentage"`
+			} `json:"seller"`
+			Categories []struct {
+				CategoryName string `json:"categoryName"`
+			} `json:"categories"`
+			MarketingPrice *struct {
+				OriginalPrice      amount `json:"originalPrice"`
+				DiscountPercentage string `json:"discountPercentage"`
+			} `json:"marketingPrice"`
+		} `json:"itemSummaries"`
+	}
+	if err := doJSON(e.client, req, &result); err != nil {
+		return nil, fmt.Errorf("ebay api: %v", err)
+	}
+
+	products := make([]models.Product, 0, len(result.ItemSummaries))
+	for _, item := range result.ItemSummaries {
+		price := item.Price.value()
+		if item.Title == "" || price <= 0 {
+			continue
+		}
+		p := models.Product{
+			ID:              fmt.Sprintf("ebay_%s_%s", strings.ToLower(country), strings.ReplaceAll(item.ItemID, "|", "_")),
+			SourceProductID: item.LegacyItemID,
+			Name:            item.Title,
+			Price:           formatPrice(price, item.Price.Currency),
+			PriceValue:      price,
+			Currency:        item.Price.Currency,
+			URL:             item.ItemWebURL,
+			Image:           item.Image.ImageURL,
+			Source:          fmt.Sprintf("eBay %s", country),
+			ScrapedAt:       time.Now(),
+			InStock:         true,
+			Merchant:        item.Seller.Username,
+			Condition:       normalizeCondition(item.Condition),
+		}
+		if item.LegacyItemID != "" {
+			p.ID = fmt.Sprintf("ebay_%s_%s", strings.ToLower(country), item.LegacyItemID)
+		}
+		var path []string
+		for _, c := range item.Categories {
+			path = append(path, c.CategoryName)
+		}
+		p.Category = categories.Detect(strings.Join(path, " "), item.Title)
+		if item.Seller.FeedbackPercentage != "" {
+			p.SellerRating = item.Seller.FeedbackPercentage + "%"
+		}
+		if mp := item.MarketingPrice; mp != nil {
+			if list := mp.OriginalPrice.value(); list > price {
+				p.OriginalPrice = formatPrice(list, mp.OriginalPrice.Currency)
+			}
+			p.Discount, _ = strconv.ParseFloat(mp.DiscountPercentage, 64)
+		}
+		products = append(products, p)
+	}
+	return products, nil
+}
+
+// accessToken returns the cached application token, fetching a new one
+// shortly before it expires.
+func (e *ebayProvider) accessToken(ctx context.Context) (string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.token != "" && time.Now().Before(e.expires) {
+		return e.token, nil
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("scope", ebayScope)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ebayTokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(e.cfg.ClientID, e.cfg.ClientSecret)
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := doJSON(e.client, req, &token); err != nil {
+		return "", fmt.Errorf("token: %v", err)
+	}
+	e.token = token.AccessToken
+	e.expires = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return e.token, nil
+}
+
+// amount is the Browse API's money type; the value is a decimal string.
+type amount struct {
+	Value    string `json:"value"`
+	Currency string `json:"currency"`
+}
+
+func (a amount) value() float64 {
+	v, _ := strconv.ParseFloat(a.Value, 64)
+	return v
+}
@@ -0,0 +1,104 @@
+// Package providers searches retailers through their official product APIs.
+// APIs return structured data without the risk of being blocked, so a
+// retailer with configured credentials is searched through its API and its
+// scraper is kept as the fallback.
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+)
+
+// Provider searches one retailer's official API.
+type Provider interface {
+	// Name is the scraper the provider stands in for
+	Name() string
+	// Covers reports whether the API serves country
+	Covers(country string) bool
+	// Endpoint is the search request URL, with secrets masked
+	Endpoint(query, country string) string
+	Search(ctx context.Context, query, country string) ([]models.Product, error)
+}
+
+// CodeLookup is implemented by providers that can find a product by its
+// GTIN (UPC, EAN) instead of by keywords.
+type CodeLookup interface {
+	LookupGTIN(ctx context.Context, gtin, country string) ([]models.Product, error)
+}
+
+// New returns a provider, keyed by scraper name, for every retailer with
+// credentials in cfg.
+func New(cfg config.ProvidersConfig) (map[string]Provider, error) {
+	client := &http.Client{Timeout: cfg.Timeout}
+	providers := make(map[string]Provider)
+	if cfg.Ebay.ClientID != "" {
+		providers[config.ScraperEbay] = newEbay(cfg.Ebay, client)
+	}
+	if cfg.BestBuy.APIKey != "" {
+		providers[config.ScraperBestBuy] = newBestBuy(cfg.BestBuy, client)
+	}
+	if cfg.Walmart.ConsumerID != "" {
+		w, err := newWalmart(cfg.Walmart, client)
+		if err != nil {
+			return providers, fmt.Errorf("walmart: %v", err)
+		}
+		providers[config.ScraperWalmart] = w
+	}
+	return providers, nil
+}
+
+// doJSON sends req and decodes a 200 response into v.
+func doJSON(client *http.Client, req *http.Request, v interface{}) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned status %d: %s", req.URL.Host, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("invalid response from %s: %v", req.URL.Host, err)
+	}
+	return nil
+}
+
+var currencySymbols = map[string]string{
+	"USD": "$", "GBP": "£", "EUR": "€", "INR": "₹", "JPY": "¥",
+	"CAD": "CA$", "AUD": "AU$",
+}
+
+// formatPrice renders an amount the way scraped prices look: "$19.99".
+func formatPrice(amount float64, currency string) string {
+	value := strconv.FormatFloat(amount, 'f', 2, 64)
+	if symbol, ok := currencySymbols[currency]; ok {
+		return symbol + value
+	}
+	return currency + " " + value
+}
+
+// normalizeCondition maps a retailer's condition label to new, used or
+// refurbished; labels it doesn't recognize are dropped.
+func normalizeCondition(label string) string {
+	label = strings.ToLower(label)
+	switch {
+	case strings.Contains(label, "refurb"):
+		return models.ConditionRefurbished
+	case strings.HasPrefix(label, "new"):
+		return models.ConditionNew
+	case strings.Contains(label, "used"), strings.Contains(label, "pre-owned"),
+		strings.Contains(label, "open box"), strings.Contains(label, "parts"):
+		return models.ConditionUsed
+	}
+	return ""
+}
@@ -0,0 +1,177 @@
+package providers
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"price-comparison-api/internal/categories"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+)
+
+const (
+	walmartSearchURL = "https://developer.api.walmart.com/api-proxy/service/affil/product/v2/search"
+	walmartItemsURL  = "https://developer.api.walmart.com/api-proxy/service/affil/product/v2/items"
+)
+
+// walmartProvider searches the Walmart Affiliate API (US only). Every
+// request carries an RSA-SHA256 signature of the consumer ID, timestamp and
+// key version.
+type walmartProvider struct {
+	cfg    config.WalmartAPIConfig
+	key    *rsa.PrivateKey
+	client *http.Client
+}
+
+func newWalmart(cfg config.WalmartAPIConfig, client *http.Client) (*walmartProvider, error) {
+	data, err := os.ReadFile(cfg.PrivateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not a PEM file", cfg.PrivateKeyFile)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return nil, fmt.Errorf("invalid private key: %v", err)
+		}
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key must be RSA")
+	}
+	return &walmartProvider{cfg: cfg, key: key, client: client}, nil
+}
+
+func (w *walmartProvider) Name() string { return config.ScraperWalmart }
+
+func (w *walmartProvider) Covers(country string) bool {
+	return strings.ToUpper(country) == "US"
+}
+
+// Endpoint needs no masking; requests are authenticated by signed headers.
+func (w *walmartProvider) Endpoint(query, country string) string {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("numItems", "25")
+	if w.cfg.PublisherID != "" {
+		params.Set("publisherId", w.cfg.PublisherID)
+	}
+	return walmartSearchURL + "?" + params.Encode()
+}
+
+func (w *walmartProvider) Search(ctx context.Context, query, country string) ([]models.Product, error) {
+	return w.items(ctx, w.Endpoint(query, country))
+}
+
+// LookupGTIN uses the items endpoint, which looks products up by UPC.
+func (w *walmartProvider) LookupGTIN(ctx context.Context, gtin, country string) ([]models.Product, error) {
+	params := url.Values{}
+	params.Set("upc", gtin)
+	if w.cfg.PublisherID != "" {
+		params.Set("publisherId", w.cfg.PublisherID)
+	}
+	return w.items(ctx, walmartItemsURL+"?"+params.Encode())
+}
+
+// items fetches endpoint; search and item lookups answer with the same
+// item list.
+func (w *walmartProvider) items(ctx context.Context, endpoint string) ([]models.Product, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := w.sign(req); err != nil {
+		return nil, fmt.Errorf("walmart api: %v", err)
+	}
+
+	var result struct {
+		Items []struct {
+			ItemID             int64   `json:"itemId"`
+			Name               string  `json:"name"`
+			SalePrice          float64 `json:"salePrice"`
+			MSRP               float64 `json:"msrp"`
+			ProductURL         string  `json:"productUrl"`
+			ProductTrackingURL string  `json:"productTrackingUrl"`
+			MediumImage        string  `json:"mediumImage"`
+			CustomerRating     string  `json:"customerRating"`
+			NumReviews         int     `json:"numReviews"`
+			Stock              string  `json:"stock"`
+			SellerInfo         string  `json:"sellerInfo"`
+			CategoryPath       string  `json:"categoryPath"`
+		} `json:"items"`
+	}
+	if err := doJSON(w.client, req, &result); err != nil {
+		return nil, fmt.Errorf("walmart api: %v", err)
+	}
+
+	products := make([]models.Product, 0, len(result.Items))
+	for _, item := range result.Items {
+		if item.Name == "" || item.SalePrice <= 0 {
+			continue
+		}
+		p := models.Product{
+			ID:              fmt.Sprintf("walmart_us_%d", item.ItemID),
+			SourceProductID: strconv.FormatInt(item.ItemID, 10),
+			Name:            item.Name,
+			Price:           formatPrice(item.SalePrice, "USD"),
+			PriceValue:      item.SalePrice,
+			Currency:        "USD",
+			URL:             item.ProductURL,
+			Image:           item.MediumImage,
+			Source:          "Walmart US",
+			ScrapedAt:       time.Now(),
+			InStock:         !strings.EqualFold(item.Stock, "Not available"),
+			Merchant:        item.SellerInfo,
+			// categoryPath reads like "Electronics/Cell Phones/Smartphones"
+			Category: categories.Detect(item.CategoryPath, item.Name),
+		}
+		if w.cfg.PublisherID != "" && item.ProductTrackingURL != "" {
+			p.URL = item.ProductTrackingURL
+		}
+		if rating, err := strconv.ParseFloat(item.CustomerRating, 64); err == nil && rating > 0 {
+			p.Rating = strconv.FormatFloat(rating, 'f', 1, 64) + "/5"
+			p.Reviews = strconv.Itoa(item.NumReviews)
+		}
+		if item.MSRP > item.SalePrice {
+			p.OriginalPrice = formatPrice(item.MSRP, "USD")
+			p.Discount = math.Round((item.MSRP-item.SalePrice)/item.MSRP*1000) / 10
+		}
+		products = append(products, p)
+	}
+	return products, nil
+}
+
+// sign adds the consumer headers and the signature of
+// "consumerID\ntimestamp\nkeyVersion\n".
+func (w *walmartProvider) sign(req *http.Request) error {
+	timestamp := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	digest := sha256.Sum256([]byte(w.cfg.ConsumerID + "\n" + timestamp + "\n" + w.cfg.KeyVersion + "\n"))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, w.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return err
+	}
+	// Set directly so the names keep Walmart's spelling instead of being
+	// canonicalized
+	req.Header["WM_CONSUMER.ID"] = []string{w.cfg.ConsumerID}
+	req.Header["WM_CONSUMER.INTIMESTAMP"] = []string{timestamp}
+	req.Header["WM_SEC.KEY_VERSION"] = []string{w.cfg.KeyVersion}
+	req.Header["WM_SEC.AUTH_SIGNATURE"] = []string{base64.StdEncoding.EncodeToString(signature)}
+	return nil
+}
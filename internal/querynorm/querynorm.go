@@ -0,0 +1,168 @@
+// Package querynorm cleans up search queries before they reach the
+// scrapers and the cache: "Samsng Galaxy S24 256 GB" and "samsung galaxy
+// s24 256gb" are the same search, so they should scrape the same way and
+// share a cache entry.
+package querynorm
+
+import (
+	"regexp"
+	"strings"
+)
+
+// unitPattern joins units to the number before them, "64 gb" becoming "64gb", the
+// way retailers write them in product names.
+var unitPattern = regexp.MustCompile(`\b(\d+(?:\.\d+)?)[ -]?(gb|tb|mb|mah|mp|ghz|hz|mm|kg|ml|w)\b`)
+
+// unitSpellings are spelled-out units, replaced before units are joined.
+var unitSpellings = map[string]string{
+	"gigabyte": "gb", "gigabytes": "gb",
+	"terabyte": "tb", "terabytes": "tb",
+	"megabyte": "mb", "megabytes": "mb",
+	"megapixel": "mp", "megapixels": "mp",
+	"watt": "w", "watts": "w",
+}
+
+// inchPattern matches screen sizes written as 55", 55-inch or 55 inches;
+// they become "55 inch". "in" is left alone, as in "2 in 1 laptop".
+var inchPattern = regexp.MustCompile(`\b(\d+(?:\.\d+)?)(?:\s*"|[ -]?(?:inch|inches)\b)`)
+
+// typos are common misspellings seen in search logs.
+var typos = map[string]string{
+	"iphnoe": "iphone", "ipone": "iphone", "iphne": "iphone",
+	"samsumg": "samsung", "samsnug": "samsung", "samung": "samsung",
+	"labtop": "laptop", "leptop": "laptop", "latop": "laptop",
+	"macbok": "macbook", "mackbook": "macbook",
+	"playstaion": "playstation", "plastation": "playstation",
+	"headphnes": "headphones", "hedphones": "headphones",
+	"bluetooh": "bluetooth", "bluetoth": "bluetooth", "blutooth": "bluetooth",
+	"wireles": "wireless", "wirless": "wireless",
+	"keybord": "keyboard", "moniter": "monitor",
+	"televison": "television", "telivision": "television",
+	"refrigirator": "refrigerator", "refridgerator": "refrigerator",
+}
+
+// aliases are brand and product nicknames, and names commonly split into
+// two words, mapped to the form retailers list them under.
+var aliases = map[string]string{
+	"mi":           "xiaomi",
+	"moto":         "motorola",
+	"one plus":     "oneplus",
+	"i phone":      "iphone",
+	"mac book":     "macbook",
+	"air pods":     "airpods",
+	"play station": "playstation",
+	"ps 5":         "ps5",
+	"ps 4":         "ps4",
+	"x box":        "xbox",
+	"smart watch":  "smartwatch",
+}
+
+// vocabulary holds brands and shopping words that a misspelling one edit
+// away is corrected to.
+var vocabulary = []string{
+	"apple", "samsung", "xiaomi", "oneplus", "motorola", "google", "huawei", "realme",
+	"lenovo", "nokia", "philips", "panasonic", "toshiba", "microsoft", "nintendo",
+	"logitech", "canon", "nikon", "garmin", "fitbit", "iphone", "macbook", "galaxy",
+	"playstation", "bluetooth", "wireless", "headphones", "earbuds", "laptop", "keyboard",
+	"monitor", "television", "charger", "speaker", "camera", "smartwatch", "tablet",
+}
+
+// Normalize returns query lower-cased, with its whitespace collapsed, units
+// written the retailers' way, known typos and near-misses of known brands
+// corrected and brand aliases expanded.
+func Normalize(query string) string {
+	q := strings.Join(strings.Fields(strings.ToLower(query)), " ")
+	if q == "" {
+		return ""
+	}
+
+	q = inchPattern.ReplaceAllString(q, "$1 inch")
+	words := strings.Fields(q)
+	for i, w := range words {
+		if unit, ok := unitSpellings[w]; ok {
+			words[i] = unit
+		}
+	}
+	q = unitPattern.ReplaceAllString(strings.Join(words, " "), "$1$2")
+
+	q = replacePhrases(q, typos)
+	q = replacePhrases(q, aliases)
+
+	words = strings.Fields(q)
+	for i, w := range words {
+		words[i] = correct(w)
+	}
+	return strings.Join(words, " ")
+}
+
+// replacePhrases replaces whole-word occurrences of each key of m.
+func replacePhrases(q string, m map[string]string) string {
+	padded := " " + q + " "
+	for from, to := range m {
+		padded = strings.ReplaceAll(padded, " "+from+" ", " "+to+" ")
+	}
+	return strings.TrimSpace(padded)
+}
+
+// correct returns the vocabulary word w is one edit (an insertion,
+// deletion, substitution or swap of neighbours) away from, when exactly one
+// is. Short words, words with digits, plurals of vocabulary words and words
+// already in the vocabulary are left alone.
+func correct(w string) string {
+	if len(w) < 5 || strings.ContainsAny(w, "0123456789") {
+		return w
+	}
+	match := ""
+	for _, v := range vocabulary {
+		if w == v || w == v+"s" || w == v+"es" {
+			return w
+		}
+		if oneEdit(w, v) {
+			if match != "" {
+				return w
+			}
+			match = v
+		}
+	}
+	if match == "" {
+		return w
+	}
+	return match
+}
+
+// oneEdit reports whether a and b differ by exactly one edit.
+func oneEdit(a, b string) bool {
+	if a == b {
+		return false
+	}
+	switch len(a) - len(b) {
+	case 0:
+		diff := -1
+		for i := 0; i < len(a); i++ {
+			if a[i] == b[i] {
+				continue
+			}
+			if diff >= 0 {
+				// A second difference is only allowed as a swap with the first
+				return i == diff+1 && a[diff] == b[i] && a[i] == b[diff] && a[i+1:] == b[i+1:]
+			}
+			diff = i
+		}
+		return true
+	case 1:
+		return deletesTo(a, b)
+	case -1:
+		return deletesTo(b, a)
+	}
+	return false
+}
+
+// deletesTo reports whether deleting one byte of long gives short.
+func deletesTo(long, short string) bool {
+	for i := 0; i < len(short); i++ {
+		if long[i] != short[i] {
+			return long[i+1:] == short[i:]
+		}
+	}
+	return true
+}
@@ -0,0 +1,70 @@
+package querynorm
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	for _, tc := range []struct {
+		query string
+		want  string
+	}{
+		{"", ""},
+		{"   ", ""},
+		{"usb c cable", "usb c cable"},
+		{"  iPhone   15  ", "iphone 15"},
+		{"Samsng Galaxy S24 256 GB", "samsung galaxy s24 256gb"},
+		// Units
+		{"128 gigabytes", "128gb"},
+		{"5000 mAh power bank", "5000mah power bank"},
+		{"12-mp camera", "12mp camera"},
+		{"2.4 GHz router", "2.4ghz router"},
+		{"65 watts charger", "65w charger"},
+		// Screen sizes, but not "2 in 1"
+		{`55" tv`, "55 inch tv"},
+		{"65-inch TV", "65 inch tv"},
+		{"43 inches monitor", "43 inch monitor"},
+		{"2 in 1 laptop", "2 in 1 laptop"},
+		// Known typos and aliases
+		{"iphnoe 15", "iphone 15"},
+		{"labtop bag", "laptop bag"},
+		{"one plus 12", "oneplus 12"},
+		{"Moto G", "motorola g"},
+		{"mi band", "xiaomi band"},
+		{"play station 5", "playstation 5"},
+		// Near misses of the vocabulary
+		{"lenova laptop", "lenovo laptop"},
+		{"logitec keyboard", "logitech keyboard"},
+		{"blutooth speakr", "bluetooth speaker"},
+		// Left alone: short words, words with digits, plurals
+		{"aple watch", "aple watch"},
+		{"galaxy2 case", "galaxy2 case"},
+		{"chargers", "chargers"},
+		{"speakers", "speakers"},
+	} {
+		if got := Normalize(tc.query); got != tc.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tc.query, got, tc.want)
+		}
+	}
+}
+
+func TestOneEdit(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want bool
+	}{
+		{"apple", "apple", false},
+		{"apple", "appel", true},  // swap
+		{"apple", "applr", true},  // substitution
+		{"apple", "aple", true},   // deletion
+		{"aple", "apple", true},   // insertion
+		{"apple", "appled", true}, // insertion at the end
+		{"apple", "pple", true},   // deletion at the start
+		{"apple", "elppa", false},
+		{"apple", "apxly", false},
+		{"apple", "ap", false},
+		{"abcd", "badc", false}, // two swaps
+	} {
+		if got := oneEdit(tc.a, tc.b); got != tc.want {
+			t.Errorf("oneEdit(%q, %q) = %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+// Package reviews sums up the review snippets of a product page: whether
+// reviewers sound pleased or not, and which aspects of the product they
+// praise or complain about. It reads words, not meaning, so it gives
+// comparison shoppers a hint beyond the star rating, not a verdict.
+package reviews
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"price-comparison-api/internal/models"
+)
+
+// Sentiments a summary reports
+const (
+	SentimentPositive = "positive"
+	SentimentNegative = "negative"
+	SentimentMixed    = "mixed"
+	SentimentNeutral  = "neutral"
+)
+
+const (
+	// MaxSnippets is how many reviews a summary reads
+	MaxSnippets = 5
+	// snippetLength caps each snippet, cut at a word boundary
+	snippetLength = 300
+	// maxAspects caps the pros and the cons
+	maxAspects = 5
+	// A score beyond ±leaning is positive or negative rather than mixed
+	leaning = 0.25
+)
+
+// positiveWords and negativeWords are the opinion words that are counted.
+var positiveWords = words(
+	"good", "great", "excellent", "amazing", "awesome", "love", "loved", "loves", "perfect",
+	"fantastic", "best", "nice", "happy", "recommend", "recommended", "easy", "fast", "quick",
+	"solid", "sturdy", "reliable", "comfortable", "beautiful", "bright", "clear", "crisp",
+	"smooth", "worth", "impressed", "impressive", "superb", "quiet", "lightweight", "durable",
+	"value", "satisfied", "works", "wonderful", "decent", "premium", "responsive",
+)
+
+var negativeWords = words(
+	"bad", "poor", "terrible", "awful", "horrible", "worst", "hate", "broke", "broken",
+	"cheap", "flimsy", "slow", "disappointed", "disappointing", "disappointment", "defective",
+	"faulty", "useless", "waste", "returned", "return", "refund", "problem", "problems",
+	"issue", "issues", "noisy", "loud", "overpriced", "expensive", "dim", "laggy", "lag",
+	"stopped", "died", "dead", "fragile", "uncomfortable", "heavy", "annoying", "junk",
+	"cracked", "scratched", "missing", "damaged",
+)
+
+// negations flip the opinion word up to two words after them: "not good",
+// "doesn't work well".
+var negations = words(
+	"not", "no", "never", "don't", "doesn't", "didn't", "isn't", "wasn't", "won't",
+	"can't", "cannot", "hardly", "barely", "dont", "doesnt", "didnt", "isnt", "wasnt",
+)
+
+// aspects are the product qualities reviews are read for, each listed under
+// the words that mention it.
+var aspects = map[string]string{
+	"battery": "battery", "charge": "battery", "charging": "battery",
+	"screen": "screen", "display": "screen",
+	"camera": "camera", "photos": "camera", "pictures": "camera",
+	"sound": "sound", "audio": "sound", "speaker": "sound", "speakers": "sound", "bass": "sound",
+	"quality": "quality", "build": "build", "material": "build", "materials": "build",
+	"price": "price", "value": "price", "money": "price",
+	"design": "design", "look": "design", "looks": "design", "color": "design",
+	"size": "size", "fit": "fit", "fits": "fit",
+	"weight": "weight", "heavy": "weight", "lightweight": "weight",
+	"performance": "performance", "speed": "performance",
+	"comfort": "comfort", "comfortable": "comfort", "uncomfortable": "comfort",
+	"delivery": "delivery", "shipping": "delivery", "arrived": "delivery",
+	"packaging": "packaging", "box": "packaging",
+	"setup": "setup", "install": "setup", "installation": "setup",
+	"software": "software", "app": "software", "update": "software", "updates": "software",
+	"keyboard": "keyboard", "noise": "noise", "noisy": "noise", "quiet": "noise",
+	"durability": "durability", "durable": "durability", "broke": "durability", "broken": "durability",
+	"service": "customer service", "support": "customer service", "seller": "seller",
+	"instructions": "instructions", "manual": "instructions",
+}
+
+func words(list ...string) map[string]bool {
+	m := make(map[string]bool, len(list))
+	for _, w := range list {
+		m[w] = true
+	}
+	return m
+}
+
+// Summarize reads up to MaxSnippets of snippets, skipping blank and repeated
+// ones, and returns their summary, or nil when there's nothing to read.
+func Summarize(snippets []string) *models.ReviewSummary {
+	summary := &models.ReviewSummary{Snippets: []string{}}
+	seen := map[string]bool{}
+	for _, s := range snippets {
+		s = strings.Join(strings.Fields(s), " ")
+		if s == "" || seen[s] {
+			continue
+		}
+		seen[s] = true
+		summary.Snippets = append(summary.Snippets, shorten(s, snippetLength))
+		if len(summary.Snippets) == MaxSnippets {
+			break
+		}
+	}
+	if len(summary.Snippets) == 0 {
+		return nil
+	}
+
+	var positive, negative int
+	pros, cons := map[string]int{}, map[string]int{}
+	for _, s := range summary.Snippets {
+		for _, sentence := range sentences(s) {
+			score := sentenceScore(sentence)
+			switch {
+			case score > 0:
+				positive += score
+				countAspects(sentence, pros)
+			case score < 0:
+				negative -= score
+				countAspects(sentence, cons)
+			}
+		}
+	}
+
+	summary.Sentiment = SentimentNeutral
+	if positive+negative > 0 {
+		summary.Score = math.Round(float64(positive-negative)/float64(positive+negative)*100) / 100
+		switch {
+		case summary.Score > leaning:
+			summary.Sentiment = SentimentPositive
+		case summary.Score < -leaning:
+			summary.Sentiment = SentimentNegative
+		default:
+			summary.Sentiment = SentimentMixed
+		}
+	}
+	summary.Pros = topAspects(pros)
+	summary.Cons = topAspects(cons)
+	return summary
+}
+
+// sentences splits s at sentence ends and "but", which usually turns a
+// review from praise to complaint or back, into lower-case words.
+func sentences(s string) [][]string {
+	var out [][]string
+	var current []string
+	for _, w := range strings.Fields(strings.ToLower(s)) {
+		end := strings.ContainsAny(w[len(w)-1:], ".!?;")
+		w = strings.TrimFunc(w, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+		})
+		if w == "but" || w == "however" {
+			out, current = appendSentence(out, current), nil
+			continue
+		}
+		if w != "" {
+			current = append(current, w)
+		}
+		if end {
+			out, current = appendSentence(out, current), nil
+		}
+	}
+	return appendSentence(out, current)
+}
+
+func appendSentence(out [][]string, sentence []string) [][]string {
+	if len(sentence) > 0 {
+		out = append(out, sentence)
+	}
+	return out
+}
+
+// sentenceScore counts a sentence's positive words less its negative ones,
+// flipping those that follow a negation closely.
+func sentenceScore(sentence []string) int {
+	score := 0
+	for i, w := range sentence {
+		value := 0
+		switch {
+		case positiveWords[w]:
+			value = 1
+		case negativeWords[w]:
+			value = -1
+		default:
+			continue
+		}
+		for j := i - 1; j >= 0 && j >= i-2; j-- {
+			if negations[sentence[j]] {
+				value = -value
+				break
+			}
+		}
+		score += value
+	}
+	return score
+}
+
+// countAspects counts each aspect the sentence mentions once.
+func countAspects(sentence []string, counts map[string]int) {
+	mentioned := map[string]bool{}
+	for _, w := range sentence {
+		if aspect, ok := aspects[w]; ok && !mentioned[aspect] {
+			mentioned[aspect] = true
+			counts[aspect]++
+		}
+	}
+}
+
+// topAspects returns the most counted aspects, ties in alphabetical order.
+func topAspects(counts map[string]int) []string {
+	list := make([]string, 0, len(counts))
+	for aspect := range counts {
+		list = append(list, aspect)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if counts[list[i]] != counts[list[j]] {
+			return counts[list[i]] > counts[list[j]]
+		}
+		return list[i] < list[j]
+	})
+	if len(list) > maxAspects {
+		list = list[:maxAspects]
+	}
+	return list
+}
+
+// shorten cuts s to at most n bytes at a word boundary, marking the cut.
+func shorten(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	cut := strings.LastIndex(s[:n], " ")
+	if cut <= 0 {
+		cut = n
+	}
+	return strings.TrimSpace(s[:cut]) + "..."
+}
@@ -0,0 +1,88 @@
+package savedsearch
+
+import (
+	"encoding/xml"
+	"fmt"
+	"time"
+
+	"price-comparison-api/internal/pricefmt"
+)
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	TTL           int       `xml:"ttl"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link,omitempty"`
+	Description string  `xml:"description"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+}
+
+type rssGUID struct {
+	ID          string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+// RSS renders a saved search's events as an RSS 2.0 feed. link is the feed's
+// own URL, and ttl tells readers how often it can change.
+func RSS(search *Search, link string, ttl time.Duration) ([]byte, error) {
+	channel := rssChannel{
+		Title:       fmt.Sprintf("Prices for %q in %s", search.Query, search.Country),
+		Link:        link,
+		Description: fmt.Sprintf("New offers and price changes found for %q in %s", search.Query, search.Country),
+		TTL:         int(ttl.Minutes()),
+		Items:       []rssItem{},
+	}
+	if search.LastRun != nil {
+		channel.LastBuildDate = search.LastRun.UTC().Format(time.RFC1123Z)
+	}
+	for _, e := range search.Events {
+		channel.Items = append(channel.Items, rssItem{
+			Title:       title(e, search.Country),
+			Link:        e.URL,
+			Description: description(e, search.Country),
+			GUID:        rssGUID{ID: search.ID + ":" + e.ID},
+			PubDate:     e.At.UTC().Format(time.RFC1123Z),
+		})
+	}
+
+	data, err := xml.MarshalIndent(rss{Version: "2.0", Channel: channel}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+func title(e Event, country string) string {
+	price := pricefmt.Localize(e.Price, e.Currency, country)
+	if e.Type == EventPriceChange {
+		return fmt.Sprintf("%s: %s → %s", e.Name, pricefmt.Localize(e.PreviousPrice, e.Currency, country), price)
+	}
+	return fmt.Sprintf("%s: %s", e.Name, price)
+}
+
+func description(e Event, country string) string {
+	price := pricefmt.Localize(e.Price, e.Currency, country)
+	if e.Type == EventPriceChange {
+		direction := "dropped"
+		if e.Price > e.PreviousPrice {
+			direction = "rose"
+		}
+		return fmt.Sprintf("The price at %s %s from %s to %s.", e.Source, direction,
+			pricefmt.Localize(e.PreviousPrice, e.Currency, country), price)
+	}
+	return fmt.Sprintf("New offer at %s for %s.", e.Source, price)
+}
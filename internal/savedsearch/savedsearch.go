@@ -0,0 +1,395 @@
+// Package savedsearch keeps the queries users saved and runs them again on a
+// schedule. Each run is compared with the offers the search found before, and
+// new offers and price changes become the items of the search's feed.
+package savedsearch
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	bolt "go.etcd.io/bbolt"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/history"
+	"price-comparison-api/internal/models"
+)
+
+var searchesBucket = []byte("searches")
+
+// runTick is how often the worker looks for searches that are due.
+const runTick = time.Minute
+
+// runTimeout bounds one run of a saved search
+const runTimeout = 3 * time.Minute
+
+// offerRetention is how long an offer no run found is remembered, so one
+// that comes back within it isn't announced as new again.
+const offerRetention = 30 * 24 * time.Hour
+
+// ErrNotFound is returned for unknown saved search IDs.
+var ErrNotFound = fmt.Errorf("saved search not found")
+
+// Feed item types.
+const (
+	EventNewOffer    = "new_offer"
+	EventPriceChange = "price_change"
+)
+
+// Search is a saved query and what its runs found.
+type Search struct {
+	ID      string    `json:"id"`
+	Query   string    `json:"query"`
+	Country string    `json:"country"`
+	Owner   string    `json:"owner,omitempty"`
+	Created time.Time `json:"created"`
+
+	LastRun *time.Time `json:"last_run,omitempty"`
+	NextRun time.Time  `json:"next_run"`
+	// Runs counts the runs, failed ones included
+	Runs      int    `json:"runs"`
+	LastError string `json:"last_error,omitempty"`
+	// BaselineAt is when the first successful run recorded the offers
+	// already listed; the feed reports changes after it
+	BaselineAt *time.Time `json:"baseline_at,omitempty"`
+
+	// Offers are the listings the runs found, by history key
+	Offers map[string]Offer `json:"offers,omitempty"`
+	// Events are the feed's items, newest first
+	Events []Event `json:"events,omitempty"`
+}
+
+// Offer is the last price a run found for a listing.
+type Offer struct {
+	Price    float64   `json:"price"`
+	Currency string    `json:"currency,omitempty"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// Event is a new offer or a price change found by a run.
+type Event struct {
+	ID       string  `json:"id"`
+	Type     string  `json:"type"`
+	Name     string  `json:"name"`
+	URL      string  `json:"url,omitempty"`
+	Source   string  `json:"source"`
+	Price    float64 `json:"price"`
+	Currency string  `json:"currency,omitempty"`
+	// PreviousPrice is set on price changes
+	PreviousPrice float64   `json:"previous_price,omitempty"`
+	At            time.Time `json:"at"`
+}
+
+// SearchFunc returns every listing found for a query.
+type SearchFunc func(ctx context.Context, query, country string) ([]models.Product, error)
+
+// Service stores saved searches in a bbolt file and runs each one every
+// configured interval. Runs happen one at a time to keep the load on
+// retailers low.
+type Service struct {
+	db     *bolt.DB
+	cfg    config.SavedSearchesConfig
+	search SearchFunc
+
+	stop chan struct{}
+	done chan struct{}
+	// wake asks the worker to look for due searches now, as after a Create
+	wake chan struct{}
+	// mu keeps Delete from racing a run of the same search
+	mu sync.Mutex
+}
+
+func NewService(cfg config.SavedSearchesConfig, search SearchFunc) (*Service, error) {
+	path := cfg.Path
+	if path == "" {
+		path = filepath.Join(os.TempDir(), "price-comparison-saved-searches.db")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create saved searches directory: %v", err)
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open saved searches store: %v", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(searchesBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to init saved searches store: %v", err)
+	}
+
+	log.Info().Msgf("Saved searches ready at %s", path)
+	return &Service{
+		db:     db,
+		cfg:    cfg,
+		search: search,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+		wake:   make(chan struct{}, 1),
+	}, nil
+}
+
+func (s *Service) Start() {
+	log.Info().Msgf("Saved search runs enabled, interval: %s", s.cfg.Interval)
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(runTick)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			case <-s.wake:
+			}
+			s.runDue()
+		}
+	}()
+}
+
+// Stop ends the worker, waiting for a running search to finish.
+func (s *Service) Stop() {
+	if s == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+}
+
+func (s *Service) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// Interval is how often each saved search runs.
+func (s *Service) Interval() time.Duration {
+	return s.cfg.Interval
+}
+
+// Create saves a query for owner and runs it right away. That first run only
+// records the offers already listed; later runs report what changed.
+func (s *Service) Create(query, country, owner string) (*Search, error) {
+	query = strings.TrimSpace(query)
+	country = strings.ToUpper(strings.TrimSpace(country))
+	if query == "" {
+		return nil, fmt.Errorf("query is required")
+	}
+	if len(country) != 2 {
+		return nil, fmt.Errorf("country must be a two-letter code")
+	}
+
+	now := time.Now()
+	search := &Search{
+		ID:      newID(),
+		Query:   query,
+		Country: country,
+		Owner:   owner,
+		Created: now,
+		NextRun: now,
+	}
+	s.mu.Lock()
+	err := s.put(search)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return search, nil
+}
+
+// Get returns a saved search with its offers and events.
+func (s *Service) Get(id string) (*Search, error) {
+	var search *Search
+	err := s.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket(searchesBucket).Get([]byte(id))
+		if raw == nil {
+			return ErrNotFound
+		}
+		search = &Search{}
+		return json.Unmarshal(raw, search)
+	})
+	return search, err
+}
+
+// List returns owner's saved searches without their offers and events, most
+// recently created first.
+func (s *Service) List(owner string) ([]Search, error) {
+	searches := []Search{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(searchesBucket).ForEach(func(k, v []byte) error {
+			var search Search
+			if err := json.Unmarshal(v, &search); err != nil {
+				return nil
+			}
+			if search.Owner == owner {
+				search.Offers, search.Events = nil, nil
+				searches = append(searches, search)
+			}
+			return nil
+		})
+	})
+	sort.Slice(searches, func(i, j int) bool { return searches[i].Created.After(searches[j].Created) })
+	return searches, err
+}
+
+// Delete removes a saved search and its feed.
+func (s *Service) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(searchesBucket)
+		if bucket.Get([]byte(id)) == nil {
+			return ErrNotFound
+		}
+		return bucket.Delete([]byte(id))
+	})
+}
+
+// runDue runs every search whose next run has come up.
+func (s *Service) runDue() {
+	now := time.Now()
+	var due []string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(searchesBucket).ForEach(func(k, v []byte) error {
+			var search Search
+			if err := json.Unmarshal(v, &search); err == nil && !now.Before(search.NextRun) {
+				due = append(due, search.ID)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to read saved searches")
+		return
+	}
+
+	for _, id := range due {
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+		s.run(id)
+	}
+}
+
+func (s *Service) run(id string) {
+	search, err := s.Get(id)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), runTimeout)
+	defer cancel()
+	products, searchErr := s.search(ctx, search.Query, search.Country)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// The search may have been deleted during the run
+	if search, err = s.Get(id); err != nil {
+		return
+	}
+	now := time.Now()
+	search.LastRun = &now
+	search.NextRun = now.Add(s.cfg.Interval)
+	search.Runs++
+	search.LastError = ""
+	if searchErr != nil {
+		search.LastError = searchErr.Error()
+		log.Warn().Err(searchErr).Str("saved_search_id", id).Msg("Saved search run failed")
+	} else {
+		s.record(search, products, now)
+	}
+	if err := s.put(search); err != nil {
+		log.Warn().Err(err).Str("saved_search_id", id).Msg("Failed to save saved search run")
+	}
+}
+
+// record compares a run's products with the offers found before and adds
+// an event for each new offer and changed price. The first run has nothing
+// to compare with, so it only records the offers.
+func (s *Service) record(search *Search, products []models.Product, now time.Time) {
+	first := search.BaselineAt == nil
+	if first {
+		search.BaselineAt = &now
+	}
+	if search.Offers == nil {
+		search.Offers = map[string]Offer{}
+	}
+
+	var events []Event
+	for _, p := range products {
+		if p.PriceValue <= 0 {
+			continue
+		}
+		key := history.Key(search.Country, p)
+		previous, known := search.Offers[key]
+		search.Offers[key] = Offer{Price: p.PriceValue, Currency: p.Currency, LastSeen: now}
+		if first {
+			continue
+		}
+
+		event := Event{
+			Name:     p.Name,
+			URL:      p.URL,
+			Source:   p.Source,
+			Price:    p.PriceValue,
+			Currency: p.Currency,
+			At:       now,
+		}
+		switch {
+		case !known:
+			event.Type = EventNewOffer
+		case previous.Price != p.PriceValue && previous.Currency == p.Currency:
+			event.Type = EventPriceChange
+			event.PreviousPrice = previous.Price
+		default:
+			continue
+		}
+		event.ID = newID()
+		events = append(events, event)
+	}
+
+	for key, offer := range search.Offers {
+		if now.Sub(offer.LastSeen) > offerRetention {
+			delete(search.Offers, key)
+		}
+	}
+	search.Events = append(events, search.Events...)
+	if len(search.Events) > s.cfg.FeedItems {
+		search.Events = search.Events[:s.cfg.FeedItems]
+	}
+}
+
+func (s *Service) put(search *Search) error {
+	data, err := json.Marshal(search)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(searchesBucket).Put([]byte(search.ID), data)
+	})
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
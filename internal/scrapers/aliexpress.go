@@ -0,0 +1,241 @@
+package scrapers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly/v2"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/productid"
+	"price-comparison-api/internal/scrapetrace"
+	"price-comparison-api/pkg/politeness"
+	"price-comparison-api/pkg/retry"
+)
+
+// AliExpressScraper searches AliExpress from any country. Its result pages are
+// built with JavaScript, so they are loaded through the Chrome renderer when
+// one is set; without it the server-rendered HTML is used, which only holds
+// results some of the time. Prices are requested in the country's currency.
+type AliExpressScraper struct {
+	cfg       config.ScraperConfig
+	transport http.RoundTripper
+	ctx       context.Context
+	base      collectorBase
+	render    RenderFunc
+}
+
+var aliExpressCurrencies = map[string]string{
+	"US": "USD", "CA": "CAD", "IN": "INR", "UK": "GBP", "DE": "EUR",
+	"FR": "EUR", "IT": "EUR", "ES": "EUR", "AU": "AUD", "JP": "JPY",
+	"MX": "MXN", "BR": "BRL",
+}
+
+var (
+	// "1,000+ sold", "5K+ sold", "236 sold"
+	soldPattern   = regexp.MustCompile(`(?i)(\d[\d,.]*\s*[kK]?\+?)\s*sold`)
+	ratingPattern = regexp.MustCompile(`\b([0-5](?:\.\d)?)\b`)
+)
+
+func NewAliExpressScraper(cfg config.ScraperConfig) *AliExpressScraper {
+	return &AliExpressScraper{cfg: cfg}
+}
+
+// SetTransport replaces the HTTP transport used by the collector.
+func (a *AliExpressScraper) SetTransport(transport http.RoundTripper) {
+	a.transport = transport
+	a.base.reset()
+}
+
+// SetContext bounds every page fetch by ctx.
+func (a *AliExpressScraper) SetContext(ctx context.Context) {
+	a.ctx = ctx
+	a.base.reset()
+}
+
+// SetRenderer loads result pages in Chrome instead of fetching the raw HTML.
+func (a *AliExpressScraper) SetRenderer(render RenderFunc) {
+	a.render = render
+}
+
+func (a *AliExpressScraper) newCollector() *colly.Collector {
+	c := a.base.clone(func() *colly.Collector {
+		c := colly.NewCollector(
+			colly.AllowedDomains("aliexpress.com", "www.aliexpress.com", "aliexpress.us", "www.aliexpress.us"),
+			colly.AllowURLRevisit(),
+		)
+		politeness.Limit(c, config.ScraperAliExpress, a.cfg)
+		if a.transport != nil {
+			c.WithTransport(a.transport)
+		}
+		if a.ctx != nil {
+			c.Context = a.ctx
+		}
+		return c
+	})
+	fingerprintPages(c, config.ScraperAliExpress, PageSearch)
+	detectBlocks(c, config.ScraperAliExpress)
+	timePhases(c)
+
+	c.OnRequest(func(r *colly.Request) {
+		r.Headers.Set("User-Agent", a.cfg.UserAgent)
+		r.Headers.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+		r.Headers.Set("Accept-Language", "en-US,en;q=0.5")
+	})
+
+	retry.Attach(c)
+
+	return c
+}
+
+func (a *AliExpressScraper) Search(ctx context.Context, query, country string) ([]models.Product, error) {
+	logger := scraperLogger(ctx, config.ScraperAliExpress, query, country)
+	products := make([]models.Product, 0)
+	country = strings.ToUpper(country)
+	currency := aliExpressCurrency(country)
+
+	searchURL := a.getSearchURL(query, country, currency)
+	logger.Info().Msgf("Searching AliExpress (%s, %s) with URL: %s", country, currency, searchURL)
+
+	sel := siteSelectors(config.ScraperAliExpress)
+	add := func(e *colly.HTMLElement) {
+		scrapetrace.RecordHit(ctx, sel.card())
+		if product, ok := a.parseCard(e, sel, country, currency); ok {
+			products = append(products, product)
+			logger.Debug().Msgf("Found AliExpress (%s) product: %s - %s", country, product.Name, product.Price)
+		}
+	}
+
+	structured := newStructuredResults(ctx, config.ScraperAliExpress, country, currency, fmt.Sprintf("AliExpress %s", country))
+
+	var renderErr error
+	if a.render != nil {
+		html, err := renderPage(ctx, config.ScraperAliExpress, a.render, searchURL)
+		renderErr = err
+		if err != nil {
+			logger.Warn().Err(err).Msg("AliExpress render failed, falling back to plain HTML")
+		} else if doc, err := goquery.NewDocumentFromReader(strings.NewReader(html)); err == nil {
+			structured.readRendered(doc, searchURL)
+			doc.Find(sel.card()).Each(func(i int, s *goquery.Selection) {
+				add(colly.NewHTMLElementFromSelectionNode(&colly.Response{}, s, s.Nodes[0], i))
+			})
+		}
+	}
+
+	if len(products) == 0 && !structured.found() {
+		collector := a.newCollector()
+		structured.attach(collector)
+		collector.OnResponse(func(r *colly.Response) {
+			logger.Debug().Msgf("AliExpress (%s) Response status: %d", country, r.StatusCode)
+		})
+		collector.OnHTML(sel.card(), add)
+		if err := visit(ctx, collector, searchURL); err != nil {
+			logger.Warn().Err(err).Msgf("Error visiting AliExpress %s", country)
+			return products, fmt.Errorf("aliexpress: %w", err)
+		}
+	}
+	products = structured.merge(products)
+	if len(products) == 0 && isBlocked(renderErr) {
+		return products, fmt.Errorf("aliexpress: %w", renderErr)
+	}
+
+	logger.Info().Msgf("AliExpress %s found %d products", country, len(products))
+	return products, nil
+}
+
+// getSearchURL asks for prices in currency and shipping to country.
+func (a *AliExpressScraper) getSearchURL(query, country, currency string) string {
+	params := url.Values{}
+	params.Set("SearchText", query)
+	params.Set("currency", currency)
+	params.Set("shipToCountry", googleCountryCode(country))
+	slug := strings.Join(strings.Fields(strings.ToLower(query)), "-")
+	return fmt.Sprintf("https://www.aliexpress.com/w/wholesale-%s.html?%s", url.PathEscape(slug), params.Encode())
+}
+
+func (a *AliExpressScraper) parseCard(e *colly.HTMLElement, sel SiteSelectors, country, currency string) (models.Product, bool) {
+	product := models.Product{
+		Source:    fmt.Sprintf("AliExpress %s", country),
+		Currency:  currency,
+		ScrapedAt: time.Now(),
+		InStock:   true,
+	}
+	applyRelease(&product, e.Text)
+
+	product.Name = firstText(e, sel.Name)
+	if len(product.Name) <= 5 {
+		return product, false
+	}
+
+	price := firstText(e, sel.Price)
+	if m := priceAmount.FindString(price); m != "" {
+		price = m
+	} else if price = priceAmount.FindString(e.Text); price == "" {
+		return product, false
+	}
+	product.Price = price
+
+	href := e.Attr("href")
+	if href == "" {
+		href = childAttr(e, sel.URL, "href")
+	}
+	product.URL = aliExpressURL(href)
+
+	for _, attr := range []string{"src", "data-src"} {
+		if src := childAttr(e, sel.Image, attr); src != "" && !strings.HasPrefix(src, "data:") {
+			product.Image = aliExpressURL(src)
+			break
+		}
+	}
+
+	// Sold counts are the closest thing AliExpress cards have to a review
+	// count; the star score is the item's rating
+	if m := soldPattern.FindStringSubmatch(e.Text); m != nil {
+		product.Reviews = strings.TrimSpace(m[1]) + " sold"
+	}
+	for _, selector := range sel.Rating {
+		if m := ratingPattern.FindStringSubmatch(e.DOM.Find(selector).First().Text()); m != nil {
+			product.Rating = m[1] + "/5"
+			break
+		}
+	}
+
+	applyDeal(&product, e, config.ScraperAliExpress)
+	applyCondition(&product, e, config.ScraperAliExpress)
+	applySeller(&product, e, config.ScraperAliExpress)
+
+	applyCategory(&product, e, config.ScraperAliExpress)
+	product.ID, product.SourceProductID = productid.For(config.ScraperAliExpress, country, product.URL, product.Name)
+	return product, true
+}
+
+func aliExpressCurrency(country string) string {
+	if currency, ok := aliExpressCurrencies[country]; ok {
+		return currency
+	}
+	return "USD"
+}
+
+// aliExpressURL makes protocol-relative and relative links absolute and
+// drops tracking parameters.
+func aliExpressURL(href string) string {
+	switch {
+	case href == "":
+		return ""
+	case strings.HasPrefix(href, "//"):
+		href = "https:" + href
+	case strings.HasPrefix(href, "/"):
+		href = "https://www.aliexpress.com" + href
+	}
+	if u, err := url.Parse(href); err == nil && strings.Contains(u.Path, "/item/") {
+		u.RawQuery = ""
+		return u.String()
+	}
+	return href
+}
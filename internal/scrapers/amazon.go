@@ -1,180 +1,206 @@
 package scrapers
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"net/http"
 	"regexp"
 	"strings"
 	"time"
 
 	"github.com/gocolly/colly/v2"
 	"github.com/gocolly/colly/v2/debug"
+	"price-comparison-api/internal/config"
 	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/productid"
+	"price-comparison-api/pkg/politeness"
+	"price-comparison-api/pkg/retry"
 )
 
 type AmazonScraper struct {
-	collector *colly.Collector
+	cfg       config.ScraperConfig
+	transport http.RoundTripper
+	ctx       context.Context
+	base      collectorBase
 }
 
-func NewAmazonScraper() *AmazonScraper {
-	c := colly.NewCollector(
-		colly.AllowedDomains("amazon.com", "www.amazon.com", "amazon.in", "www.amazon.in",
-			"amazon.co.uk", "www.amazon.co.uk", "amazon.de", "www.amazon.de",
-			"amazon.ca", "www.amazon.ca", "amazon.com.au", "www.amazon.com.au"),
-		colly.Debugger(&debug.LogDebugger{}),
-	)
+func NewAmazonScraper(cfg config.ScraperConfig) *AmazonScraper {
+	return &AmazonScraper{cfg: cfg}
+}
+
+// SetTransport replaces the HTTP transport used by the collector.
+func (a *AmazonScraper) SetTransport(transport http.RoundTripper) {
+	a.transport = transport
+	a.base.reset()
+}
+
+// SetContext bounds every page fetch by ctx.
+func (a *AmazonScraper) SetContext(ctx context.Context) {
+	a.ctx = ctx
+	a.base.reset()
+}
+
+func (a *AmazonScraper) newCollector() *colly.Collector {
+	c := a.base.clone(func() *colly.Collector {
+		c := colly.NewCollector(
+			colly.AllowedDomains("amazon.com", "www.amazon.com", "amazon.in", "www.amazon.in",
+				"amazon.co.uk", "www.amazon.co.uk", "amazon.de", "www.amazon.de",
+				"amazon.ca", "www.amazon.ca", "amazon.com.au", "www.amazon.com.au"),
+			colly.Debugger(&debug.LogDebugger{}),
+			colly.AllowURLRevisit(),
+		)
+		politeness.Limit(c, config.ScraperAmazon, a.cfg)
+		if a.transport != nil {
+			c.WithTransport(a.transport)
+		}
+		if a.ctx != nil {
+			c.Context = a.ctx
+		}
+		return c
+	})
+	fingerprintPages(c, config.ScraperAmazon, PageSearch)
+	detectBlocks(c, config.ScraperAmazon)
+	timePhases(c)
 
 	c.OnRequest(func(r *colly.Request) {
-		r.Headers.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
+		r.Headers.Set("User-Agent", a.cfg.UserAgent)
 		r.Headers.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8")
 		r.Headers.Set("Accept-Language", "en-US,en;q=0.5")
 	})
 
-	c.Limit(&colly.LimitRule{
-		DomainGlob:  "*amazon.*",
-		Parallelism: 1,
-		Delay:       2 * time.Second,
-	})
+	retry.Attach(c)
 
-	return &AmazonScraper{collector: c}
+	return c
 }
 
-func (a *AmazonScraper) Search(query, country string) ([]models.Product, error) {
-	// Always return empty slice instead of nil
-	products := make([]models.Product, 0)
+func (a *AmazonScraper) Search(ctx context.Context, query, country string) ([]models.Product, error) {
+	logger := scraperLogger(ctx, config.ScraperAmazon, query, country)
 
 	searchURL := a.getSearchURL(query, country)
-	log.Printf("Searching Amazon (%s) with URL: %s", country, searchURL)
+	logger.Info().Msgf("Searching Amazon (%s) with URL: %s", country, searchURL)
 
 	// Multiple selector strategies
-	selectors := []string{
-		"div[data-component-type='s-search-result']",
-		"[data-component-type='s-search-result']",
-		"div.s-result-item",
-		"div[data-asin]",
-		".s-search-result",
-	}
-
-	foundAny := false
-
-	a.collector.OnResponse(func(r *colly.Response) {
-		log.Printf("Amazon (%s) Response status: %d", country, r.StatusCode)
-		bodyStr := string(r.Body)
-		log.Printf("Page contains search results: %v", strings.Contains(bodyStr, "s-search-result"))
-	})
-
-	for _, selector := range selectors {
-		log.Printf("Trying Amazon (%s) selector: %s", country, selector)
-
-		a.collector.OnHTML(selector, func(e *colly.HTMLElement) {
-			foundAny = true
-
-			product := models.Product{
-				Source:    fmt.Sprintf("Amazon %s", strings.ToUpper(country)),
-				Currency:  a.getCurrencyForCountry(country),
-				ScrapedAt: time.Now(),
-				InStock:   true,
-			}
-
-			// Try multiple name selectors
-			nameSelectors := []string{
-				"h2 a span",
-				"h2.a-size-mini span",
-				".s-size-mini span",
-				"h2 span",
-				".a-link-normal span",
-			}
-
-			for _, nameSelector := range nameSelectors {
-				name := strings.TrimSpace(e.ChildText(nameSelector))
-				if name != "" && len(name) > 5 {
-					product.Name = name
-					break
-				}
-			}
-
-			if product.Name == "" {
-				return // Skip if no valid name
-			}
-
-			product.Price = a.extractPrice(e, country)
-			product.URL = a.extractURL(e, country)
-
-			// Try multiple image selectors
-			imageSelectors := []string{
-				"img.s-image",
-				".s-product-image-container img",
-				"img[data-image-latency='s-product-image']",
-				"img",
+	sel := siteSelectors(config.ScraperAmazon)
+
+	search := cardSearch{
+		url:          searchURL,
+		cards:        sel.Cards,
+		newCollector: a.newCollector,
+		newStructured: func() *structuredResults {
+			return newStructuredResults(ctx, config.ScraperAmazon, country,
+				a.getCurrencyForCountry(country), fmt.Sprintf("Amazon %s", strings.ToUpper(country)))
+		},
+		parse: func(e *colly.HTMLElement) (models.Product, bool) {
+			product, ok := a.parseCard(e, sel, country)
+			if ok {
+				logger.Debug().Msgf("Found Amazon (%s) product: %s - %s", country, product.Name, product.Price)
 			}
+			return product, ok
+		},
+		onResponse: func(r *colly.Response) {
+			logger.Debug().Msgf("Amazon (%s) Response status: %d", country, r.StatusCode)
+			bodyStr := string(r.Body)
+			logger.Debug().Msgf("Page contains search results: %v", strings.Contains(bodyStr, "s-search-result"))
+		},
+		logger: logger,
+	}
+	attempt, failed := search.run(ctx)
+	if isBlocked(attempt.err) {
+		return attempt.products, attempt.err
+	}
+	products := attempt.results()
 
-			for _, imgSelector := range imageSelectors {
-				image := e.ChildAttr(imgSelector, "src")
-				if image != "" {
-					product.Image = image
-					break
-				}
-			}
+	if len(products) == 0 && failed == len(sel.Cards) {
+		logger.Warn().Msgf("Amazon (%s): No products found and all selectors failed for query: %s", country, query)
+		return products, fmt.Errorf("all Amazon scraping attempts failed")
+	}
 
-			product.Rating = strings.TrimSpace(e.ChildText(".a-icon-alt"))
-			product.Reviews = strings.TrimSpace(e.ChildText(".a-size-base"))
+	if len(products) == 0 {
+		logger.Info().Msgf("No Amazon (%s) products found for query: %s", country, query)
+	}
 
-			if product.Price != "" {
-				product.ID = fmt.Sprintf("amazon_%s_%d", country, time.Now().UnixNano())
-				products = append(products, product)
-				log.Printf("Found Amazon (%s) product: %s - %s", country, product.Name, product.Price)
-			}
-		})
+	logger.Info().Msgf("Amazon %s found %d products", country, len(products))
+	return products, nil
+}
 
-		err := a.collector.Visit(searchURL)
-		if err != nil {
-			log.Printf("Error visiting Amazon %s: %v", country, err)
-		}
+func (a *AmazonScraper) parseCard(e *colly.HTMLElement, sel SiteSelectors, country string) (models.Product, bool) {
+	product := models.Product{
+		Source:    fmt.Sprintf("Amazon %s", strings.ToUpper(country)),
+		Currency:  a.getCurrencyForCountry(country),
+		ScrapedAt: time.Now(),
+		InStock:   true,
+	}
+	applyRelease(&product, e.Text)
 
-		if foundAny {
+	// Try multiple name selectors
+	for _, nameSelector := range sel.Name {
+		name := strings.TrimSpace(e.ChildText(nameSelector))
+		if name != "" && len(name) > 5 {
+			product.Name = name
 			break
 		}
+	}
 
-		// Reset collector for next selector
-		a.collector = a.collector.Clone()
+	if product.Name == "" {
+		return product, false // Skip if no valid name
 	}
 
-	if !foundAny {
-		log.Printf("No Amazon (%s) products found for query: %s", country, query)
+	product.Price = a.extractPrice(e, sel, country)
+	product.URL = a.extractURL(e, sel, country)
+	product.Image = childAttr(e, sel.Image, "src")
+	product.Rating = childText(e, sel.Rating)
+	product.Reviews = childText(e, sel.Reviews)
+
+	if product.Price == "" {
+		return product, false
 	}
+	applyDeal(&product, e, config.ScraperAmazon)
+	applyCondition(&product, e, config.ScraperAmazon)
+	applySeller(&product, e, config.ScraperAmazon)
+	applyCategory(&product, e, config.ScraperAmazon)
+	product.ID, product.SourceProductID = productid.For(config.ScraperAmazon, country, product.URL, product.Name)
+	return product, true
+}
 
-	log.Printf("Amazon %s found %d products", country, len(products))
-	return products, nil
+// amazonSearchURLs are the country-specific search URLs; other countries
+// are searched on amazon.com
+var amazonSearchURLs = map[string]string{
+	"US": "https://www.amazon.com/s?k=%s",
+	"IN": "https://www.amazon.in/s?k=%s",
+	"UK": "https://www.amazon.co.uk/s?k=%s",
+	"DE": "https://www.amazon.de/s?k=%s",
+	"CA": "https://www.amazon.ca/s?k=%s",
+	"AU": "https://www.amazon.com.au/s?k=%s",
+	"FR": "https://www.amazon.fr/s?k=%s",
+	"IT": "https://www.amazon.it/s?k=%s",
+	"ES": "https://www.amazon.es/s?k=%s",
+	"JP": "https://www.amazon.co.jp/s?k=%s",
+	"MX": "https://www.amazon.com.mx/s?k=%s",
+	"BR": "https://www.amazon.com.br/s?k=%s",
 }
 
 // Build country-specific search URLs
 func (a *AmazonScraper) getSearchURL(query, country string) string {
-	domains := map[string]string{
-		"US": "https://www.amazon.com/s?k=%s",
-		"IN": "https://www.amazon.in/s?k=%s",
-		"UK": "https://www.amazon.co.uk/s?k=%s",
-		"DE": "https://www.amazon.de/s?k=%s",
-		"CA": "https://www.amazon.ca/s?k=%s",
-		"AU": "https://www.amazon.com.au/s?k=%s",
-		"FR": "https://www.amazon.fr/s?k=%s",
-		"IT": "https://www.amazon.it/s?k=%s",
-		"ES": "https://www.amazon.es/s?k=%s",
-		"JP": "https://www.amazon.co.jp/s?k=%s",
-	}
-
-	baseURL := domains[strings.ToUpper(country)]
+	baseURL := amazonSearchURLs[strings.ToUpper(country)]
 	if baseURL == "" {
-		baseURL = domains["US"] // fallback
+		baseURL = amazonSearchURLs["US"] // fallback
 	}
 
 	return fmt.Sprintf(baseURL, strings.ReplaceAll(query, " ", "+"))
 }
 
+// AmazonProductURL is the product page of asin on the country's Amazon
+// site, or on amazon.com where there is none.
+func AmazonProductURL(asin, country string) string {
+	searchURL := (&AmazonScraper{}).getSearchURL("", country)
+	return searchURL[:strings.Index(searchURL, "/s?")] + "/dp/" + asin
+}
+
 func (a *AmazonScraper) getCurrencyForCountry(country string) string {
 	currencies := map[string]string{
 		"US": "USD", "CA": "CAD", "IN": "INR", "UK": "GBP",
 		"DE": "EUR", "FR": "EUR", "IT": "EUR", "ES": "EUR",
-		"AU": "AUD", "JP": "JPY",
+		"AU": "AUD", "JP": "JPY", "MX": "MXN", "BR": "BRL",
 	}
 
 	if currency, exists := currencies[strings.ToUpper(country)]; exists {
@@ -183,26 +209,15 @@ func (a *AmazonScraper) getCurrencyForCountry(country string) string {
 	return "USD"
 }
 
-func (a *AmazonScraper) extractPrice(e *colly.HTMLElement, country string) string {
-	priceSelectors := []string{
-		".a-price-whole",
-		".a-price .a-offscreen",
-		".a-price-fraction",
-		".a-price-symbol",
+func (a *AmazonScraper) extractPrice(e *colly.HTMLElement, sel SiteSelectors, country string) string {
+	if price := childText(e, sel.Price); price != "" {
+		return a.formatPriceForCountry(price, country)
 	}
-
-	for _, selector := range priceSelectors {
-		price := strings.TrimSpace(e.ChildText(selector))
-		if price != "" {
-			return a.formatPriceForCountry(price, country)
-		}
-	}
-
 	return ""
 }
 
-func (a *AmazonScraper) extractURL(e *colly.HTMLElement, country string) string {
-	relativeURL := e.ChildAttr("h2 a", "href")
+func (a *AmazonScraper) extractURL(e *colly.HTMLElement, sel SiteSelectors, country string) string {
+	relativeURL := childAttr(e, sel.URL, "href")
 	if relativeURL != "" {
 		baseURL := a.getBaseURL(country)
 		return baseURL + relativeURL
@@ -218,6 +233,8 @@ func (a *AmazonScraper) getBaseURL(country string) string {
 		"DE": "https://www.amazon.de",
 		"CA": "https://www.amazon.ca",
 		"AU": "https://www.amazon.com.au",
+		"MX": "https://www.amazon.com.mx",
+		"BR": "https://www.amazon.com.br",
 	}
 
 	if baseURL, exists := baseURLs[strings.ToUpper(country)]; exists {
@@ -242,6 +259,8 @@ func (a *AmazonScraper) formatPriceForCountry(price, country string) string {
 		return "€" + price
 	case "JPY":
 		return "¥" + price
+	case "BRL":
+		return "R$" + price
 	default:
 		return "$" + price
 	}
@@ -0,0 +1,118 @@
+package scrapers
+
+import (
+	"context"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+	"github.com/rs/zerolog"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/scrapetrace"
+)
+
+// cardSearch fetches a search page once per card selector, in order, until
+// one of them finds products. Retailers that rework their markup often are
+// given several selectors, from newest to oldest.
+//
+// Every attempt gets a collector of its own, cloned from the scraper's
+// base so it shares the politeness delay and parallelism, with only that
+// attempt's handlers. It keeps what it reads to itself: an attempt never
+// parses cards with an earlier attempt's selector or returns products an
+// earlier attempt found.
+type cardSearch struct {
+	url   string
+	cards []string
+	// newCollector returns a collector set up the scraper's way, with none
+	// of a search's handlers.
+	newCollector  func() *colly.Collector
+	newStructured func() *structuredResults
+	// parse turns a matched card into a product, or reports it isn't one.
+	parse func(e *colly.HTMLElement) (models.Product, bool)
+	// onResponse, when set, is called with every page fetched, for
+	// logging.
+	onResponse colly.ResponseCallback
+	// pause is waited after an attempt that fetched the page but found
+	// nothing, before the next one.
+	pause  time.Duration
+	logger zerolog.Logger
+}
+
+// cardAttempt is what one fetch of a search page read with one card
+// selector.
+type cardAttempt struct {
+	products   []models.Product
+	structured *structuredResults
+	err        error
+}
+
+// found reports whether the attempt found products, from its cards or the
+// page's structured data. A selector matching elements that don't parse as
+// products, like a part of each card, hasn't found the cards.
+func (a cardAttempt) found() bool {
+	return len(a.products) > 0 || a.structured.found()
+}
+
+// results returns the attempt's products, merged with the page's
+// structured ones.
+func (a cardAttempt) results() []models.Product {
+	return a.structured.merge(a.products)
+}
+
+// run tries each card selector until one finds results, and returns that
+// attempt, or the last one made when none did, along with how many
+// attempts failed to fetch the page. It gives up early when the page turns
+// out to be a bot challenge, which every selector would get, or ctx is
+// done.
+func (s cardSearch) run(ctx context.Context) (cardAttempt, int) {
+	last := cardAttempt{products: make([]models.Product, 0), structured: s.newStructured()}
+	failed := 0
+	for i, selector := range s.cards {
+		if i > 0 && last.err == nil && s.pause > 0 {
+			timer := time.NewTimer(s.pause)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return last, failed
+			}
+		}
+
+		last = s.attempt(ctx, selector)
+		if last.err != nil {
+			failed++
+			s.logger.Warn().Err(last.err).Str("selector", selector).Msg("Error visiting search page")
+			if isBlocked(last.err) || ctx.Err() != nil {
+				return last, failed
+			}
+			continue
+		}
+		if last.found() {
+			return last, failed
+		}
+	}
+	return last, failed
+}
+
+// attempt fetches the search page with a fresh clone of the scraper's
+// collector, reading its cards with selector.
+func (s cardSearch) attempt(ctx context.Context, selector string) cardAttempt {
+	s.logger.Debug().Str("selector", selector).Msg("Trying card selector")
+
+	a := cardAttempt{
+		products:   make([]models.Product, 0),
+		structured: s.newStructured(),
+	}
+	c := s.newCollector()
+	a.structured.attach(c)
+	if s.onResponse != nil {
+		c.OnResponse(s.onResponse)
+	}
+	c.OnHTML(selector, func(e *colly.HTMLElement) {
+		scrapetrace.RecordHit(ctx, selector)
+		if product, ok := s.parse(e); ok {
+			a.products = append(a.products, product)
+		}
+	})
+	a.err = visit(ctx, c, s.url)
+	return a
+}
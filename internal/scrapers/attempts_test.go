@@ -0,0 +1,91 @@
+package scrapers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"price-comparison-api/internal/config"
+)
+
+// timedTransport answers every request with an empty results page and
+// records when each was sent.
+type timedTransport struct {
+	mu    sync.Mutex
+	times []time.Time
+}
+
+func (t *timedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	t.times = append(t.times, time.Now())
+	t.mu.Unlock()
+	return StaticTransport{HTML: "<html><body><p>No results</p></body></html>"}.RoundTrip(req)
+}
+
+func TestAttemptsShareTheDelay(t *testing.T) {
+	const delay = 100 * time.Millisecond
+	scraper := NewAmazonScraper(config.ScraperConfig{Delay: delay, Parallelism: 1, UserAgent: "test"})
+	transport := &timedTransport{}
+	scraper.SetTransport(transport)
+
+	// Two searches at once, each trying every card selector on a page
+	// with no cards
+	var wg sync.WaitGroup
+	for _, query := range []string{"usb c cable", "paper towels"} {
+		wg.Add(1)
+		go func(query string) {
+			defer wg.Done()
+			if _, err := scraper.Search(context.Background(), query, "US"); err != nil {
+				t.Errorf("search %q: %v", query, err)
+			}
+		}(query)
+	}
+	wg.Wait()
+
+	want := 2 * len(siteSelectors(config.ScraperAmazon).Cards)
+	if len(transport.times) != want {
+		t.Fatalf("%d pages fetched, want %d", len(transport.times), want)
+	}
+	sort.Slice(transport.times, func(i, j int) bool { return transport.times[i].Before(transport.times[j]) })
+	for i := 1; i < len(transport.times); i++ {
+		if gap := transport.times[i].Sub(transport.times[i-1]); gap < delay-10*time.Millisecond {
+			t.Errorf("fetch %d followed fetch %d after %v, want at least %v", i+1, i, gap, delay)
+		}
+	}
+}
+
+// downTransport fails every request, as an unreachable site does.
+type downTransport struct{}
+
+func (downTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestSearchFailsWhenEveryAttemptDoes(t *testing.T) {
+	for _, tc := range []struct {
+		scraper, country string
+	}{
+		{config.ScraperAmazon, "US"},
+		{config.ScraperEbay, "US"},
+		{config.ScraperFlipkart, "IN"},
+		{config.ScraperWalmart, "US"},
+		{config.ScraperTarget, "US"},
+		{config.ScraperBestBuy, "US"},
+	} {
+		t.Run(tc.scraper, func(t *testing.T) {
+			scraper, err := New(tc.scraper, config.ScraperConfig{Parallelism: 1, UserAgent: "test"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			scraper.SetTransport(downTransport{})
+			products, err := scraper.Search(context.Background(), "usb c cable", tc.country)
+			if err == nil {
+				t.Errorf("no error with every fetch failing, %d products", len(products))
+			}
+		})
+	}
+}
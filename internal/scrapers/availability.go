@@ -0,0 +1,94 @@
+package scrapers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"price-comparison-api/pkg/politeness"
+)
+
+// maxDetailPage caps how much of a product page is read for availability.
+const maxDetailPage = 4 << 20
+
+var (
+	// schema.org availability, from JSON-LD or microdata
+	schemaAvailability = regexp.MustCompile(`(?i)schema\.org/(InStock|InStoreOnly|LimitedAvailability|OnlineOnly|OutOfStock|SoldOut|Discontinued|PreOrder|BackOrder)`)
+
+	outOfStockPhrases = []string{
+		"currently unavailable",
+		"out of stock",
+		"sold out",
+		"no longer available",
+		"temporarily unavailable",
+	}
+	inStockPhrases = []string{
+		"add to cart",
+		"add to basket",
+		"buy now",
+		"in stock",
+	}
+)
+
+// CheckAvailability fetches a product detail page and reports whether the
+// product can be bought. Structured data wins over page text; an error is
+// returned when the page gives no usable signal.
+func CheckAvailability(ctx context.Context, client *http.Client, url, userAgent string) (bool, error) {
+	if err := politeness.Wait(ctx, url); err != nil {
+		return false, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	req.Header.Set("Accept-Language", "en-US,en;q=0.5")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("detail page returned status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDetailPage))
+	if err != nil {
+		return false, err
+	}
+	recordFingerprint(req.URL, "", PageDetail, resp.StatusCode, resp.Header.Get("Content-Type"), body)
+	return parseAvailability(string(body))
+}
+
+func parseAvailability(page string) (bool, error) {
+	if m := schemaAvailability.FindStringSubmatch(page); m != nil {
+		switch strings.ToLower(m[1]) {
+		case "instock", "instoreonly", "limitedavailability", "onlineonly":
+			return true, nil
+		default:
+			return false, nil
+		}
+	}
+
+	text := strings.ToLower(page)
+	for _, phrase := range outOfStockPhrases {
+		if strings.Contains(text, phrase) {
+			return false, nil
+		}
+	}
+	for _, phrase := range inStockPhrases {
+		if strings.Contains(text, phrase) {
+			return true, nil
+		}
+	}
+	return false, fmt.Errorf("no availability information on page")
+}
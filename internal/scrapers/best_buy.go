@@ -1,29 +1,68 @@
 package scrapers
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"net/http"
 	"regexp"
 	"strings"
 	"time"
 
 	"github.com/gocolly/colly/v2"
 	"github.com/gocolly/colly/v2/debug"
+	"github.com/rs/zerolog/log"
+	"price-comparison-api/internal/config"
 	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/productid"
+	"price-comparison-api/pkg/politeness"
+	"price-comparison-api/pkg/retry"
 )
 
 type BestBuyScraper struct {
-	collector *colly.Collector
+	cfg       config.ScraperConfig
+	transport http.RoundTripper
+	ctx       context.Context
+	base      collectorBase
 }
 
-func NewBestBuyScraper() *BestBuyScraper {
-	c := colly.NewCollector(
-		colly.AllowedDomains("bestbuy.com", "www.bestbuy.com"),
-		colly.Debugger(&debug.LogDebugger{}),
-	)
+func NewBestBuyScraper(cfg config.ScraperConfig) *BestBuyScraper {
+	return &BestBuyScraper{cfg: cfg}
+}
+
+// SetTransport replaces the HTTP transport used by the collector.
+func (b *BestBuyScraper) SetTransport(transport http.RoundTripper) {
+	b.transport = transport
+	b.base.reset()
+}
+
+// SetContext bounds every page fetch by ctx.
+func (b *BestBuyScraper) SetContext(ctx context.Context) {
+	b.ctx = ctx
+	b.base.reset()
+}
+
+func (b *BestBuyScraper) newCollector() *colly.Collector {
+	c := b.base.clone(func() *colly.Collector {
+		c := colly.NewCollector(
+			colly.AllowedDomains("bestbuy.com", "www.bestbuy.com"),
+			colly.Debugger(&debug.LogDebugger{}),
+			colly.AllowURLRevisit(),
+		)
+		politeness.Limit(c, config.ScraperBestBuy, b.cfg)
+		if b.transport != nil {
+			c.WithTransport(b.transport)
+		}
+		if b.ctx != nil {
+			c.Context = b.ctx
+		}
+		return c
+	})
+	fingerprintPages(c, config.ScraperBestBuy, PageSearch)
+	detectBlocks(c, config.ScraperBestBuy)
+	timePhases(c)
 
 	c.OnRequest(func(r *colly.Request) {
-		r.Headers.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
+		r.Headers.Set("User-Agent", b.cfg.UserAgent)
 		r.Headers.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8")
 		r.Headers.Set("Accept-Language", "en-US,en;q=0.5")
 		r.Headers.Set("Accept-Encoding", "gzip, deflate, br")
@@ -35,155 +74,114 @@ func NewBestBuyScraper() *BestBuyScraper {
 		r.Headers.Set("Sec-Fetch-Site", "none")
 	})
 
-	c.Limit(&colly.LimitRule{
-		DomainGlob:  "*bestbuy.*",
-		Parallelism: 1,
-		Delay:       3 * time.Second,
-	})
-
 	c.OnError(func(r *colly.Response, err error) {
-		log.Printf("Best Buy scraper error: %v", err)
+		log.Warn().Err(err).Str("scraper", config.ScraperBestBuy).Msg("Best Buy scraper error")
 	})
 
-	return &BestBuyScraper{collector: c}
+	retry.Attach(c)
+
+	return c
 }
 
-func (b *BestBuyScraper) Search(query, country string) ([]models.Product, error) {
-	// Always return empty slice instead of nil
-	products := make([]models.Product, 0)
+func (b *BestBuyScraper) Search(ctx context.Context, query, country string) ([]models.Product, error) {
+	logger := scraperLogger(ctx, config.ScraperBestBuy, query, country)
 
 	if strings.ToUpper(country) != "US" {
-		log.Printf("Best Buy: Country %s not supported, returning empty results", country)
-		return products, nil
+		logger.Warn().Msgf("Best Buy: Country %s not supported, returning empty results", country)
+		// Always return empty slice instead of nil
+		return make([]models.Product, 0), nil
 	}
 
 	searchURL := b.getSearchURL(query)
-	log.Printf("Searching Best Buy (US) with URL: %s", searchURL)
+	logger.Info().Msgf("Searching Best Buy (US) with URL: %s", searchURL)
 
 	// Multiple selector strategies for Best Buy's product listings
-	selectors := []string{
-		".sku-item",
-		"[data-testid='product-card']",
-		".sr-item",
-		".list-item",
-		".product-item",
-		"li.sku-item",
-		"[data-sku-id]",
-	}
-
-	foundAny := false
-	errorCount := 0
-
-	b.collector.OnResponse(func(r *colly.Response) {
-		log.Printf("Best Buy Response status: %d, Content-Length: %d", r.StatusCode, len(r.Body))
-		bodyStr := string(r.Body)
-		log.Printf("Page contains product data: %v", strings.Contains(bodyStr, "sku-item") || strings.Contains(bodyStr, "product"))
-	})
-
-	for _, selector := range selectors {
-		log.Printf("Trying Best Buy selector: %s", selector)
-
-		b.collector.OnHTML(selector, func(e *colly.HTMLElement) {
-			foundAny = true
-
-			product := models.Product{
-				Source:    "Best Buy US",
-				Currency:  "USD",
-				ScrapedAt: time.Now(),
-				InStock:   true,
-			}
-
-			// Extract name with multiple fallback selectors
-			nameSelectors := []string{
-				".sku-header a",
-				".sku-title",
-				"h4.sr-product-title a",
-				"h3.sr-product-title a",
-				".sr-product-title",
-				"a.v-fw-medium",
-				".product-title",
-				"[data-testid='product-title']",
-				"h4 a",
-			}
-
-			for _, nameSelector := range nameSelectors {
-				name := strings.TrimSpace(e.ChildText(nameSelector))
-				if name == "" {
-					// Try getting from title attribute
-					name = strings.TrimSpace(e.ChildAttr(nameSelector, "title"))
-				}
-
-				if name != "" && len(name) > 5 && !b.isGenericTitle(name) {
-					product.Name = b.cleanProductName(name)
-					break
-				}
+	sel := siteSelectors(config.ScraperBestBuy)
+
+	search := cardSearch{
+		url:          searchURL,
+		cards:        sel.Cards,
+		newCollector: b.newCollector,
+		newStructured: func() *structuredResults {
+			return newStructuredResults(ctx, config.ScraperBestBuy, "US", "USD", "Best Buy US")
+		},
+		parse: func(e *colly.HTMLElement) (models.Product, bool) {
+			product, ok := b.parseCard(e, sel)
+			if ok {
+				logger.Debug().Msgf("Found Best Buy product: %s - %s", product.Name, product.Price)
 			}
-
-			if product.Name == "" {
-				return // Skip if no valid name found
-			}
-
-			product.Price = b.extractPrice(e)
-			product.URL = b.extractURL(e)
-			product.Image = b.extractImage(e)
-			product.Rating = b.extractRating(e)
-			product.Reviews = b.extractReviews(e)
-
-			if product.Price != "" {
-				product.ID = fmt.Sprintf("bestbuy_us_%d", time.Now().UnixNano())
-				products = append(products, product)
-				log.Printf("Found Best Buy product: %s - %s", product.Name, product.Price)
-			}
-		})
-
-		err := b.collector.Visit(searchURL)
-		if err != nil {
-			log.Printf("Error visiting Best Buy with selector %s: %v", selector, err)
-			errorCount++
-			continue
-		}
-
-		// If we found products with this selector, break
-		if foundAny {
-			break
-		}
-
-		// Reset collector for next selector attempt
-		b.collector = b.resetCollector()
-		time.Sleep(2 * time.Second) // Additional delay between selector attempts
+			return product, ok
+		},
+		onResponse: func(r *colly.Response) {
+			logger.Debug().Msgf("Best Buy Response status: %d, Content-Length: %d", r.StatusCode, len(r.Body))
+			bodyStr := string(r.Body)
+			logger.Debug().Msgf("Page contains product data: %v", strings.Contains(bodyStr, "sku-item") || strings.Contains(bodyStr, "product"))
+		},
+		// Additional delay between selector attempts
+		pause:  b.cfg.RetryDelay,
+		logger: logger,
 	}
+	attempt, failed := search.run(ctx)
+	if isBlocked(attempt.err) {
+		return attempt.products, attempt.err
+	}
+	products := attempt.results()
 
-	if !foundAny && errorCount == len(selectors) {
-		log.Printf("Best Buy: No products found and all selectors failed for query: %s", query)
+	if len(products) == 0 && failed == len(sel.Cards) {
+		logger.Warn().Msgf("Best Buy: No products found and all selectors failed for query: %s", query)
 		return products, fmt.Errorf("all Best Buy scraping attempts failed")
 	}
 
-	if !foundAny {
-		log.Printf("Best Buy: No products found for query: %s", query)
+	if len(products) == 0 {
+		logger.Info().Msgf("Best Buy: No products found for query: %s", query)
 	}
 
-	log.Printf("Best Buy found %d products", len(products))
+	logger.Info().Msgf("Best Buy found %d products", len(products))
 	return products, nil
 }
 
-func (b *BestBuyScraper) resetCollector() *colly.Collector {
-	c := colly.NewCollector(
-		colly.AllowedDomains("bestbuy.com", "www.bestbuy.com"),
-	)
+func (b *BestBuyScraper) parseCard(e *colly.HTMLElement, sel SiteSelectors) (models.Product, bool) {
+	product := models.Product{
+		Source:    "Best Buy US",
+		Currency:  "USD",
+		ScrapedAt: time.Now(),
+		InStock:   true,
+	}
+	applyRelease(&product, e.Text)
+
+	// Extract name with multiple fallback selectors
+	for _, nameSelector := range sel.Name {
+		name := strings.TrimSpace(e.ChildText(nameSelector))
+		if name == "" {
+			// Try getting from title attribute
+			name = strings.TrimSpace(e.ChildAttr(nameSelector, "title"))
+		}
 
-	c.OnRequest(func(r *colly.Request) {
-		r.Headers.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
-		r.Headers.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8")
-		r.Headers.Set("Accept-Language", "en-US,en;q=0.5")
-	})
+		if name != "" && len(name) > 5 && !b.isGenericTitle(name) {
+			product.Name = b.cleanProductName(name)
+			break
+		}
+	}
 
-	c.Limit(&colly.LimitRule{
-		DomainGlob:  "*bestbuy.*",
-		Parallelism: 1,
-		Delay:       3 * time.Second,
-	})
+	if product.Name == "" {
+		return product, false // Skip if no valid name found
+	}
 
-	return c
+	product.Price = b.extractPrice(e, sel)
+	product.URL = b.extractURL(e, sel)
+	product.Image = b.extractImage(e, sel)
+	product.Rating = b.extractRating(e, sel)
+	product.Reviews = b.extractReviews(e, sel)
+
+	if product.Price == "" {
+		return product, false
+	}
+	applyDeal(&product, e, config.ScraperBestBuy)
+	applyCondition(&product, e, config.ScraperBestBuy)
+	applySeller(&product, e, config.ScraperBestBuy)
+	applyCategory(&product, e, config.ScraperBestBuy)
+	product.ID, product.SourceProductID = productid.For(config.ScraperBestBuy, "US", product.URL, product.Name)
+	return product, true
 }
 
 func (b *BestBuyScraper) getSearchURL(query string) string {
@@ -191,21 +189,8 @@ func (b *BestBuyScraper) getSearchURL(query string) string {
 	return fmt.Sprintf("https://www.bestbuy.com/site/searchpage.jsp?st=%s", encodedQuery)
 }
 
-func (b *BestBuyScraper) extractPrice(e *colly.HTMLElement) string {
-	priceSelectors := []string{
-		".sr-price .visuallyhidden",
-		".pricing-price__range",
-		".sku-price",
-		".current-price",
-		".sr-price",
-		"[aria-label*='current price']",
-		".price-current",
-		"span.sr-price",
-		".visually-hidden:contains('current price')",
-		"span:contains('$')",
-	}
-
-	for _, selector := range priceSelectors {
+func (b *BestBuyScraper) extractPrice(e *colly.HTMLElement, sel SiteSelectors) string {
+	for _, selector := range sel.Price {
 		price := strings.TrimSpace(e.ChildText(selector))
 		if price != "" {
 			formattedPrice := b.formatPrice(price)
@@ -224,17 +209,8 @@ func (b *BestBuyScraper) extractPrice(e *colly.HTMLElement) string {
 	return ""
 }
 
-func (b *BestBuyScraper) extractURL(e *colly.HTMLElement) string {
-	urlSelectors := []string{
-		".sku-header a",
-		"h4.sr-product-title a",
-		"h3.sr-product-title a",
-		"a.v-fw-medium",
-		".product-title a",
-		"a",
-	}
-
-	for _, selector := range urlSelectors {
+func (b *BestBuyScraper) extractURL(e *colly.HTMLElement, sel SiteSelectors) string {
+	for _, selector := range sel.URL {
 		relativeURL := e.ChildAttr(selector, "href")
 		if relativeURL != "" {
 			if strings.HasPrefix(relativeURL, "http") {
@@ -249,16 +225,8 @@ func (b *BestBuyScraper) extractURL(e *colly.HTMLElement) string {
 	return ""
 }
 
-func (b *BestBuyScraper) extractImage(e *colly.HTMLElement) string {
-	imageSelectors := []string{
-		"img.product-image",
-		"img[src*='pisces.bbystatic.com']",
-		"img[alt*='product']",
-		"picture img",
-		"img",
-	}
-
-	for _, selector := range imageSelectors {
+func (b *BestBuyScraper) extractImage(e *colly.HTMLElement, sel SiteSelectors) string {
+	for _, selector := range sel.Image {
 		imgSrc := e.ChildAttr(selector, "src")
 		if imgSrc != "" && (strings.Contains(imgSrc, "bestbuy") || strings.Contains(imgSrc, "bbystatic")) {
 			return imgSrc
@@ -274,17 +242,8 @@ func (b *BestBuyScraper) extractImage(e *colly.HTMLElement) string {
 	return ""
 }
 
-func (b *BestBuyScraper) extractRating(e *colly.HTMLElement) string {
-	ratingSelectors := []string{
-		".sr-rating",
-		"[aria-label*='star']",
-		".c-stars",
-		".rating-stars",
-		"span[aria-label*='out of 5']",
-		".visually-hidden:contains('out of')",
-	}
-
-	for _, selector := range ratingSelectors {
+func (b *BestBuyScraper) extractRating(e *colly.HTMLElement, sel SiteSelectors) string {
+	for _, selector := range sel.Rating {
 		rating := strings.TrimSpace(e.ChildText(selector))
 		if rating != "" {
 			return rating
@@ -303,16 +262,8 @@ func (b *BestBuyScraper) extractRating(e *colly.HTMLElement) string {
 	return ""
 }
 
-func (b *BestBuyScraper) extractReviews(e *colly.HTMLElement) string {
-	reviewSelectors := []string{
-		".sr-review-count",
-		"a[aria-label*='review']",
-		".review-count",
-		"span[aria-label*='review']",
-		".c-reviews",
-	}
-
-	for _, selector := range reviewSelectors {
+func (b *BestBuyScraper) extractReviews(e *colly.HTMLElement, sel SiteSelectors) string {
+	for _, selector := range sel.Reviews {
 		reviews := strings.TrimSpace(e.ChildText(selector))
 		if reviews != "" {
 			return reviews
@@ -419,10 +370,5 @@ func (b *BestBuyScraper) cleanProductName(name string) string {
 	cleanName = strings.TrimSpace(cleanName)
 	cleanName = regexp.MustCompile(`\s+`).ReplaceAllString(cleanName, " ")
 
-	// Truncate if too long
-	if len(cleanName) > 100 {
-		cleanName = cleanName[:100] + "..."
-	}
-
 	return cleanName
 }
@@ -0,0 +1,133 @@
+package scrapers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gocolly/colly/v2"
+	"price-comparison-api/internal/apierr"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/pkg/metrics"
+)
+
+// Challenges a retailer can answer with instead of the page asked for
+const (
+	ChallengeRobotCheck     = "robot_check"     // Amazon's captcha page
+	ChallengeVerifyIdentity = "verify_identity" // Walmart's PerimeterX "Robot or human?" page
+	ChallengeCloudflare     = "cloudflare"      // Cloudflare's browser check
+)
+
+// blockedKey is where a fetch's colly context keeps the challenge its page
+// turned out to be
+const blockedKey = "blocked"
+
+// BlockedError is a retailer serving a captcha or bot challenge instead of
+// the page asked for. It is an apierr.ErrBlocked.
+type BlockedError struct {
+	Challenge string
+	URL       string
+	// Status is the HTTP status the challenge came with, 0 for rendered
+	// pages
+	Status int
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("blocked by %s challenge at %s", e.Challenge, e.URL)
+}
+
+func (e *BlockedError) Unwrap() error { return apierr.ErrBlocked }
+
+// challengeMarkers are text only the challenge pages carry. Each is checked
+// against the start of the page, where the challenges put their title and
+// scripts.
+var challengeMarkers = []struct {
+	challenge string
+	markers   [][]byte
+}{
+	{ChallengeRobotCheck, [][]byte{
+		[]byte("<title dir=\"ltr\">Robot Check</title>"),
+		[]byte("/errors/validateCaptcha"),
+		[]byte("Type the characters you see in this image"),
+		[]byte("Enter the characters you see below"),
+	}},
+	{ChallengeVerifyIdentity, [][]byte{
+		[]byte("Verify your identity"),
+		[]byte("Robot or human?"),
+		[]byte("px-captcha"),
+	}},
+	{ChallengeCloudflare, [][]byte{
+		[]byte("<title>Just a moment...</title>"),
+		[]byte("Attention Required! | Cloudflare"),
+		[]byte("/cdn-cgi/challenge-platform/"),
+		[]byte("cf-chl-"),
+		[]byte("cf_chl_opt"),
+	}},
+}
+
+// challengeBytes caps how much of a page is searched for challenge markers;
+// challenge pages are small, and result pages mentioning a marker further
+// down aren't mistaken for one
+const challengeBytes = 64 << 10
+
+// detectChallenge returns the challenge body is, or "" for a regular page.
+func detectChallenge(body []byte) string {
+	head := body[:min(len(body), challengeBytes)]
+	for _, c := range challengeMarkers {
+		for _, marker := range c.markers {
+			if bytes.Contains(head, marker) {
+				return c.challenge
+			}
+		}
+	}
+	return ""
+}
+
+// detectBlocks marks fetches of c whose page, fetched or failed, is a
+// challenge. visit then reports them as a BlockedError.
+func detectBlocks(c *colly.Collector, scraper string) {
+	check := func(r *colly.Response) {
+		if r == nil || r.Ctx == nil || r.Request == nil {
+			return
+		}
+		challenge := detectChallenge(r.Body)
+		// Walmart redirects blocked requests to its /blocked page
+		if challenge == "" && scraper == config.ScraperWalmart && strings.HasPrefix(r.Request.URL.Path, "/blocked") {
+			challenge = ChallengeVerifyIdentity
+		}
+		if challenge == "" {
+			r.Ctx.Put(blockedKey, nil)
+			return
+		}
+		metrics.ObserveBlock(scraper, challenge)
+		r.Ctx.Put(blockedKey, &BlockedError{Challenge: challenge, URL: r.Request.URL.String(), Status: r.StatusCode})
+	}
+	c.OnResponse(check)
+	c.OnError(func(r *colly.Response, _ error) { check(r) })
+}
+
+// isBlocked reports whether err is a BlockedError.
+func isBlocked(err error) bool {
+	var blocked *BlockedError
+	return errors.As(err, &blocked)
+}
+
+// blockedFetch returns the BlockedError of a fetch made with rctx, or nil.
+func blockedFetch(rctx *colly.Context) error {
+	if blocked, ok := rctx.GetAny(blockedKey).(*BlockedError); ok {
+		return blocked
+	}
+	return nil
+}
+
+// blockedPage returns a BlockedError when a rendered page is a challenge,
+// or nil.
+func blockedPage(scraper, pageURL, html string) error {
+	challenge := detectChallenge([]byte(html))
+	if challenge == "" {
+		return nil
+	}
+	metrics.ObserveBlock(scraper, challenge)
+	return &BlockedError{Challenge: challenge, URL: pageURL}
+}
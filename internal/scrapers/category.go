@@ -0,0 +1,72 @@
+package scrapers
+
+import (
+	"strings"
+
+	"github.com/gocolly/colly/v2"
+	"price-comparison-api/internal/categories"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+)
+
+// categoryCards locate the department or category label some retailers
+// print on their search result cards.
+var categoryCards = map[string][]string{
+	config.ScraperBestBuy:      {".sku-category", "[data-testid='category-name']"},
+	config.ScraperTarget:       {"[data-test='product-category']", "[data-test='product-department']"},
+	config.ScraperWalmart:      {"[data-automation-id='product-department']"},
+	config.ScraperEtsy:         {"[data-listing-category]", ".wt-text-caption.v2-listing-card__category"},
+	config.ScraperCostco:       {".product-category", "[automation-id='productCategory']"},
+	config.ScraperMercadoLibre: {".poly-component__category", ".ui-search-item__group__element.ui-search-item__category"},
+}
+
+// Attributes retailers put a card's category in
+var genericCategoryAttrs = []string{"data-category", "data-department", "data-category-name"}
+
+// applyCategory sets the product's category from the hints on its result
+// card: the retailer's category label, category attributes and schema.org
+// microdata and, failing those, the product name. It runs after the name is
+// set.
+func applyCategory(product *models.Product, e *colly.HTMLElement, scraper string) {
+	var hints []string
+	for _, selector := range append(categoryCards[scraper], "[itemprop='category']") {
+		if text := cleanText(e.ChildText(selector)); text != "" {
+			hints = append(hints, text)
+		}
+	}
+	for _, attr := range genericCategoryAttrs {
+		if v := e.Attr(attr); v != "" {
+			hints = append(hints, v)
+		}
+		if v := e.ChildAttr("["+attr+"]", attr); v != "" {
+			hints = append(hints, v)
+		}
+	}
+	hints = append(hints, product.Name)
+	product.Category = categories.Detect(hints...)
+}
+
+// breadcrumbText joins the breadcrumb trail of a product page into one hint,
+// e.g. "Electronics Cell Phones Unlocked Phones".
+func breadcrumbText(e *colly.HTMLElement, selectors []string) string {
+	for _, selector := range selectors {
+		var crumbs []string
+		e.ForEach(selector, func(_ int, crumb *colly.HTMLElement) {
+			crumbs = appendCrumb(crumbs, crumb.Text)
+		})
+		if len(crumbs) > 0 {
+			return strings.Join(crumbs, " ")
+		}
+	}
+	return ""
+}
+
+// appendCrumb adds a breadcrumb's text, skipping the "Home" link most trails
+// start with so it isn't taken for the home category.
+func appendCrumb(crumbs []string, text string) []string {
+	text = cleanText(text)
+	if text == "" || strings.EqualFold(text, "home") {
+		return crumbs
+	}
+	return append(crumbs, text)
+}
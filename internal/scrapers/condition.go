@@ -0,0 +1,70 @@
+package scrapers
+
+import (
+	"regexp"
+
+	"github.com/gocolly/colly/v2"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+)
+
+// conditionCards locate the condition label on a retailer's search result
+// card, for retailers that show one.
+var conditionCards = map[string][]string{
+	// "Pre-Owned", "Brand New", "Certified - Refurbished", "Open Box"
+	config.ScraperEbay:         {".SECONDARY_INFO", ".s-item__subtitle"},
+	config.ScraperAmazon:       {"[data-cy='condition']", ".s-condition-label"},
+	config.ScraperBestBuy:      {"[data-testid='open-box-option']", ".open-box-option__label"},
+	config.ScraperWalmart:      {"[data-automation-id='product-condition']", "[data-testid='condition-label']"},
+	config.ScraperMercadoLibre: {".poly-component__item-condition", ".ui-search-item__group__element--condition"},
+	config.ScraperAliExpress:   {"[class*='condition']"},
+}
+
+var (
+	// Refurbished under every retailer's name for it: Amazon Renewed, Walmart
+	// Restored, eBay's "Certified - Refurbished"
+	refurbishedPattern = regexp.MustCompile(`(?i)\b(?:refurbished|renewed|restored|reconditioned|recertified|reacondicionado|recondicionado)\b`)
+	// "Pre-Owned", "Open Box", "Used - Like New" (Amazon Warehouse), "For
+	// parts", "Usado"
+	usedPattern = regexp.MustCompile(`(?i)\b(?:pre-?owned|open[- ]box|used|usado|seminuevo|for parts)\b`)
+	newPattern  = regexp.MustCompile(`(?i)\b(?:brand new|new|nuevo|novo)\b`)
+
+	// Titles only count a condition they state on purpose: "(Renewed)",
+	// "Refurbished", "Pre-Owned", a leading "Used" or a bracketed "[Used]".
+	// "Used by professionals" is not a used product.
+	titleUsedPattern = regexp.MustCompile(`(?i)\b(?:pre-?owned|open[- ]box)\b|^used\b|[(\[]\s*used\b`)
+)
+
+// applyCondition sets the product's condition from its result card's
+// condition label, or from the title when the card has none. Listings that
+// state no condition are left without one.
+func applyCondition(product *models.Product, e *colly.HTMLElement, scraper string) {
+	if label := firstText(e, conditionCards[scraper]); label != "" {
+		if condition := parseCondition(label); condition != "" {
+			product.Condition = condition
+			return
+		}
+	}
+	switch {
+	case refurbishedPattern.MatchString(product.Name):
+		product.Condition = models.ConditionRefurbished
+	case titleUsedPattern.MatchString(product.Name):
+		product.Condition = models.ConditionUsed
+	}
+}
+
+// parseCondition maps a condition label to new, used or refurbished;
+// labels it doesn't recognize give "". Refurbished is checked first, since
+// "Refurbished - Like New" is not new, and used before new for "Used - Like
+// New".
+func parseCondition(label string) string {
+	switch {
+	case refurbishedPattern.MatchString(label):
+		return models.ConditionRefurbished
+	case usedPattern.MatchString(label):
+		return models.ConditionUsed
+	case newPattern.MatchString(label):
+		return models.ConditionNew
+	}
+	return ""
+}
@@ -0,0 +1,198 @@
+package scrapers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/productid"
+	"price-comparison-api/internal/scrapetrace"
+	"price-comparison-api/pkg/politeness"
+	"price-comparison-api/pkg/retry"
+)
+
+// CostcoScraper searches costco.com (US only). Costco is a warehouse club,
+// so every listing is marked RequiresMembership: non-members either can't buy
+// or pay a surcharge on top of the listed price.
+type CostcoScraper struct {
+	cfg       config.ScraperConfig
+	transport http.RoundTripper
+	ctx       context.Context
+	base      collectorBase
+}
+
+var (
+	// "4.6 out of 5 stars", "Rated 4.5 out of 5"
+	starsOutOfFive = regexp.MustCompile(`(?i)([0-5](?:\.\d+)?)\s*out of 5`)
+	// "(1,234)", "1,234 reviews"
+	reviewCount = regexp.MustCompile(`(?i)\((\d[\d,]*)\)|(\d[\d,]*)\s+reviews?`)
+)
+
+func NewCostcoScraper(cfg config.ScraperConfig) *CostcoScraper {
+	return &CostcoScraper{cfg: cfg}
+}
+
+// SetTransport replaces the HTTP transport used by the collector.
+func (s *CostcoScraper) SetTransport(transport http.RoundTripper) {
+	s.transport = transport
+	s.base.reset()
+}
+
+// SetContext bounds every page fetch by ctx.
+func (s *CostcoScraper) SetContext(ctx context.Context) {
+	s.ctx = ctx
+	s.base.reset()
+}
+
+func (s *CostcoScraper) newCollector() *colly.Collector {
+	c := s.base.clone(func() *colly.Collector {
+		c := colly.NewCollector(
+			colly.AllowedDomains("costco.com", "www.costco.com"),
+			colly.AllowURLRevisit(),
+		)
+		politeness.Limit(c, config.ScraperCostco, s.cfg)
+		if s.transport != nil {
+			c.WithTransport(s.transport)
+		}
+		if s.ctx != nil {
+			c.Context = s.ctx
+		}
+		return c
+	})
+	fingerprintPages(c, config.ScraperCostco, PageSearch)
+	detectBlocks(c, config.ScraperCostco)
+	timePhases(c)
+
+	c.OnRequest(func(r *colly.Request) {
+		r.Headers.Set("User-Agent", s.cfg.UserAgent)
+		r.Headers.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+		r.Headers.Set("Accept-Language", "en-US,en;q=0.5")
+	})
+
+	retry.Attach(c)
+
+	return c
+}
+
+func (s *CostcoScraper) Search(ctx context.Context, query, country string) ([]models.Product, error) {
+	logger := scraperLogger(ctx, config.ScraperCostco, query, country)
+	products := make([]models.Product, 0)
+
+	if strings.ToUpper(country) != "US" {
+		logger.Warn().Msgf("Costco: Country %s not supported, returning empty results", country)
+		return products, nil
+	}
+
+	searchURL := s.getSearchURL(query)
+	logger.Info().Msgf("Searching Costco (US) with URL: %s", searchURL)
+
+	seen := make(map[string]bool)
+	sel := siteSelectors(config.ScraperCostco)
+	collector := s.newCollector()
+	structured := newStructuredResults(ctx, config.ScraperCostco, "US", "USD", "Costco US")
+	structured.membership = true
+	structured.attach(collector)
+	collector.OnResponse(func(r *colly.Response) {
+		logger.Debug().Msgf("Costco Response status: %d", r.StatusCode)
+	})
+	collector.OnHTML(sel.card(), func(e *colly.HTMLElement) {
+		scrapetrace.RecordHit(ctx, sel.card())
+		product, ok := s.parseCard(e, sel)
+		if !ok || seen[product.URL] {
+			return
+		}
+		seen[product.URL] = true
+		products = append(products, product)
+		logger.Debug().Msgf("Found Costco product: %s - %s", product.Name, product.Price)
+	})
+
+	if err := visit(ctx, collector, searchURL); err != nil {
+		logger.Warn().Err(err).Msg("Error visiting Costco")
+		return products, fmt.Errorf("costco: %w", err)
+	}
+	products = structured.merge(products)
+
+	logger.Info().Msgf("Costco found %d products", len(products))
+	return products, nil
+}
+
+func (s *CostcoScraper) getSearchURL(query string) string {
+	params := url.Values{}
+	params.Set("dept", "All")
+	params.Set("keyword", query)
+	return "https://www.costco.com/CatalogSearch?" + params.Encode()
+}
+
+func (s *CostcoScraper) parseCard(e *colly.HTMLElement, sel SiteSelectors) (models.Product, bool) {
+	product := models.Product{
+		Source:             "Costco US",
+		Currency:           "USD",
+		ScrapedAt:          time.Now(),
+		InStock:            true,
+		RequiresMembership: true,
+	}
+	applyRelease(&product, e.Text)
+
+	product.Name = firstText(e, sel.Name)
+	if len(product.Name) <= 5 {
+		return product, false
+	}
+
+	// Some prices are only shown after signing in; those cards are skipped
+	price := priceAmount.FindString(firstText(e, sel.Price))
+	if price == "" {
+		return product, false
+	}
+	product.Price = price
+
+	href := childAttr(e, sel.URL, "href")
+	product.URL = e.Request.AbsoluteURL(href)
+	if product.URL == "" {
+		product.URL = href
+	}
+
+	for _, attr := range []string{"src", "data-src"} {
+		if src := childAttr(e, sel.Image, attr); src != "" && !strings.HasPrefix(src, "data:") {
+			product.Image = src
+			break
+		}
+	}
+
+	product.Rating, product.Reviews = starRating(e, sel.Rating)
+
+	text := strings.ToLower(e.Text)
+	if strings.Contains(text, "out of stock") || strings.Contains(text, "sold out") {
+		product.InStock = false
+	}
+
+	applyDeal(&product, e, config.ScraperCostco)
+	applyCondition(&product, e, config.ScraperCostco)
+
+	applyCategory(&product, e, config.ScraperCostco)
+	product.ID, product.SourceProductID = productid.For(config.ScraperCostco, "US", product.URL, product.Name)
+	return product, true
+}
+
+// starRating reads a "4.6 out of 5" score, from text or the aria-label of
+// the elements labels match, and the review count next to it.
+func starRating(e *colly.HTMLElement, labels []string) (string, string) {
+	rating := ""
+	if m := starsOutOfFive.FindStringSubmatch(e.Text); m != nil {
+		rating = m[1] + "/5"
+	} else if m := starsOutOfFive.FindStringSubmatch(childAttr(e, labels, "aria-label")); m != nil {
+		rating = m[1] + "/5"
+	}
+
+	reviews := ""
+	if m := reviewCount.FindStringSubmatch(e.Text); m != nil {
+		reviews = m[1] + m[2]
+	}
+	return rating, reviews
+}
@@ -0,0 +1,115 @@
+package scrapers
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"price-comparison-api/internal/config"
+)
+
+// Coverage is how one scraper handles a country.
+type Coverage struct {
+	Scraper   string `json:"scraper"`
+	Supported bool   `json:"supported"`
+	// Site is the storefront searched for the country
+	Site     string `json:"site,omitempty"`
+	Currency string `json:"currency,omitempty"`
+	// Note explains partial support, like falling back to the US site
+	Note string `json:"note,omitempty"`
+}
+
+// Market is the currency and locale a country's storefronts are expected to
+// use.
+type Market struct {
+	Currency string `json:"currency"`
+	Locale   string `json:"locale"`
+}
+
+// knownMarkets are the countries at least one scraper has a storefront for.
+var knownMarkets = map[string]Market{
+	"US": {"USD", "en-US"},
+	"UK": {"GBP", "en-GB"},
+	"IN": {"INR", "en-IN"},
+	"DE": {"EUR", "de-DE"},
+	"CA": {"CAD", "en-CA"},
+	"AU": {"AUD", "en-AU"},
+	"FR": {"EUR", "fr-FR"},
+	"IT": {"EUR", "it-IT"},
+	"ES": {"EUR", "es-ES"},
+	"JP": {"JPY", "ja-JP"},
+	"MX": {"MXN", "es-MX"},
+	"BR": {"BRL", "pt-BR"},
+	"AR": {"ARS", "es-AR"},
+}
+
+// KnownMarket returns the currency and locale expected for country.
+func KnownMarket(country string) (Market, bool) {
+	m, ok := knownMarkets[strings.ToUpper(country)]
+	return m, ok
+}
+
+// usOnly lists the scrapers that only search their US storefront.
+var usOnly = map[string]string{
+	config.ScraperWalmart:  "www.walmart.com",
+	config.ScraperTarget:   "www.target.com",
+	config.ScraperBestBuy:  "www.bestbuy.com",
+	config.ScraperCostco:   "www.costco.com",
+	config.ScraperSamsClub: "www.samsclub.com",
+}
+
+// CountryCoverage reports whether the named scraper searches country, and
+// the storefront and currency it uses for it.
+func CountryCoverage(name, country string) Coverage {
+	country = strings.ToUpper(country)
+	cov := Coverage{Scraper: name}
+	switch name {
+	case config.ScraperAmazon:
+		cov.Currency = (&AmazonScraper{}).getCurrencyForCountry(country)
+		cov.Supported, cov.Site, cov.Note = siteCoverage(amazonSearchURLs, country, "amazon.com")
+	case config.ScraperEbay:
+		cov.Currency = (&EbayScraper{}).getCurrencyForCountry(country)
+		cov.Supported, cov.Site, cov.Note = siteCoverage(ebaySearchURLs, country, "ebay.com")
+	case config.ScraperFlipkart:
+		if cov.Supported = country == "IN"; cov.Supported {
+			cov.Site, cov.Currency = "www.flipkart.com", "INR"
+		}
+	case config.ScraperGoogleShopping:
+		cov.Supported, cov.Site, cov.Currency = true, googleHost(country), googleCurrency(country)
+		if _, ok := googleDomains[country]; !ok {
+			cov.Note = "searched on www.google.com with gl=" + strings.ToLower(googleCountryCode(country))
+		}
+	case config.ScraperAliExpress:
+		cov.Supported, cov.Site, cov.Currency = true, "www.aliexpress.com", aliExpressCurrency(country)
+		if _, ok := aliExpressCurrencies[country]; !ok {
+			cov.Note = "prices shown in USD"
+		}
+	case config.ScraperMercadoLibre:
+		if region, ok := mercadoLibreRegions[country]; ok {
+			cov.Supported, cov.Site, cov.Currency = true, region.Host, region.Currency
+		}
+	case config.ScraperEtsy:
+		if region, ok := etsyRegions[country]; ok {
+			cov.Supported, cov.Site, cov.Currency = true, "www.etsy.com"+region.Path, region.Currency
+		}
+	default:
+		if site, ok := usOnly[name]; ok && country == "US" {
+			cov.Supported, cov.Site, cov.Currency = true, site, "USD"
+		}
+	}
+	if !cov.Supported && cov.Note == "" {
+		cov.Currency = ""
+	}
+	return cov
+}
+
+// siteCoverage looks country up in a scraper's search URLs; countries
+// without one are searched on the US site.
+func siteCoverage(searchURLs map[string]string, country, fallback string) (bool, string, string) {
+	if u, ok := searchURLs[country]; ok {
+		if parsed, err := url.Parse(u); err == nil {
+			return true, parsed.Host, ""
+		}
+	}
+	return false, "", fmt.Sprintf("no local site, searches fall back to %s", fallback)
+}
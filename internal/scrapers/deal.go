@@ -0,0 +1,190 @@
+package scrapers
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gocolly/colly/v2"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/pkg/utils"
+)
+
+// dealSelectors locate the strike-through list price, the discount label and
+// the deal badge on a retailer's search result card.
+type dealSelectors struct {
+	ListPrice []string
+	Discount  []string
+	Badge     []string
+}
+
+// Strike-through markup most retailers fall back to
+var genericListPrice = []string{"del", "s", "strike", ".strike", ".strikethrough"}
+
+var dealCards = map[string]dealSelectors{
+	config.ScraperAmazon: {
+		ListPrice: []string{".a-price.a-text-price .a-offscreen", "span[data-a-strike='true'] .a-offscreen"},
+		Badge:     []string{".a-badge-text", "span[data-a-badge-type] .a-badge-label-inner"},
+	},
+	config.ScraperBestBuy: {
+		ListPrice: []string{".pricing-price__regular-price", "[data-testid='regular-price']", ".regular-price"},
+		Discount:  []string{".pricing-price__savings", "[data-testid='savings-price']"},
+		Badge:     []string{".deal-badge", ".sku-badge"},
+	},
+	config.ScraperEbay: {
+		ListPrice: []string{".s-item__trending-price .STRIKETHROUGH", ".s-item__original-price", "span.STRIKETHROUGH"},
+		Discount:  []string{".s-item__discount"},
+		Badge:     []string{".s-item__deal-badge", ".s-item__etrs-badge"},
+	},
+	config.ScraperFlipkart: {
+		ListPrice: []string{"._3I9_wc", ".yRaY8j", "._27UcVY"},
+		Discount:  []string{"._3Ay6Sb span", ".UkUFwK span", "._3Ay6Sb"},
+		Badge:     []string{"._2Tpdn3", "._3Uu8QI"},
+	},
+	config.ScraperTarget: {
+		ListPrice: []string{"[data-test='comparison-price']", "span[data-test='product-regular-price']"},
+		Badge:     []string{"[data-test='product-deal-badge']", "[data-test='deal-label']"},
+	},
+	config.ScraperGoogleShopping: {
+		ListPrice: []string{".Hlkgzb", ".lmQWe"},
+		Badge:     []string{".Ib8pOd", ".VRpiue"},
+	},
+	config.ScraperAliExpress: {
+		ListPrice: []string{"[class*='price-original']", "[class*='price-del']"},
+		Discount:  []string{"[class*='price-discount']", "[class*='discount']"},
+		Badge:     []string{"[class*='choice']", "[class*='tag--']"},
+	},
+	config.ScraperEtsy: {
+		ListPrice: []string{".search-collage-promotion-price .currency-value", "span.wt-text-strikethrough"},
+		Discount:  []string{".search-collage-promotion-price", ".wt-text-grey.wt-text-truncate"},
+		Badge:     []string{".wt-badge", "[class*='star-seller']"},
+	},
+	config.ScraperCostco: {
+		ListPrice: []string{".strike-through", ".was-price"},
+		Discount:  []string{".promo", ".promotional-text"},
+		Badge:     []string{".product-tile-badge", ".promo"},
+	},
+	config.ScraperSamsClub: {
+		ListPrice: []string{"[data-testid='was-price']", ".sc-price-was", ".Price-characteristic--was"},
+		Discount:  []string{"[data-testid='savings']", ".sc-savings"},
+		Badge:     []string{"[data-testid='product-flag']", ".sc-pc-flag"},
+	},
+	config.ScraperMercadoLibre: {
+		Discount: []string{".andes-money-amount__discount", ".poly-price__disc_label"},
+		Badge:    []string{".poly-component__highlight", ".ui-search-item__highlight-label"},
+	},
+	config.ScraperWalmart: {
+		ListPrice: []string{"[data-automation-id='strikethrough-price']", "div.gray.strike", ".price-was"},
+		Badge:     []string{"[data-automation-id='product-badge']", "span.tag-leading-badge"},
+	},
+}
+
+var (
+	// "Save 20%", "20% off", "-20%", "(20% Off)"
+	discountPattern = regexp.MustCompile(`(?i)(?:save\s*(\d{1,2}(?:\.\d+)?)\s*%|(\d{1,2}(?:\.\d+)?)\s*%\s*off|-\s*(\d{1,2}(?:\.\d+)?)\s*%)`)
+	// "Save $50", "You save ₹2,000"
+	saveAmountPattern = regexp.MustCompile(`(?i)(?:you\s+)?save\s+([$£€₹¥][\d.,]+)`)
+
+	// Badge phrases found in card text, most specific first
+	dealBadges = []string{
+		"Deal of the Day",
+		"Lightning Deal",
+		"Limited time deal",
+		"Prime Day Deal",
+		"Black Friday Deal",
+		"Big Billion Days",
+		"Top Deal",
+		"Hot Deal",
+		"Daily Deal",
+		"Rollback",
+		"Clearance",
+		"Reduced price",
+		"Bank Offer",
+		"Coupon",
+	}
+)
+
+// applyDeal fills the list price, discount and deal badge of a scraped
+// product from its result card. It runs after the current price is set;
+// Discount is taken from the card when it shows one and is otherwise worked
+// out from the list price.
+func applyDeal(product *models.Product, e *colly.HTMLElement, scraper string) {
+	sel := dealCards[scraper]
+
+	price := utils.ParsePrice(product.Price)
+	for _, selector := range append(sel.ListPrice, genericListPrice...) {
+		text := strings.TrimSpace(e.ChildText(selector))
+		if text == "" {
+			continue
+		}
+		// Keep the first amount: cards sometimes repeat it for screen readers
+		if m := priceAmount.FindString(text); m != "" {
+			text = m
+		}
+		if list := utils.ParsePrice(text); list > price && price > 0 {
+			product.OriginalPrice = text
+			break
+		}
+	}
+
+	for _, selector := range sel.Discount {
+		if d := parseDiscount(e.ChildText(selector)); d > 0 {
+			product.Discount = d
+			break
+		}
+	}
+	if product.Discount == 0 {
+		product.Discount = parseDiscount(e.Text)
+	}
+	if product.Discount == 0 && product.OriginalPrice != "" {
+		list := utils.ParsePrice(product.OriginalPrice)
+		product.Discount = math.Round((list-price)/list*1000) / 10
+	}
+
+	for _, selector := range sel.Badge {
+		if badge := strings.Join(strings.Fields(e.ChildText(selector)), " "); badge != "" && len(badge) <= 40 {
+			product.DealBadge = badge
+			break
+		}
+	}
+	if product.DealBadge == "" {
+		product.DealBadge = findBadge(e.Text)
+	}
+}
+
+// priceAmount matches one amount with its currency symbol
+var priceAmount = regexp.MustCompile(`[$£€₹¥]\s?[\d.,]*\d|[\d.,]*\d\s?[$£€₹¥]`)
+
+// parseDiscount reads a percentage discount from card text.
+func parseDiscount(text string) float64 {
+	m := discountPattern.FindStringSubmatch(text)
+	if m == nil {
+		return 0
+	}
+	for _, group := range m[1:] {
+		if group == "" {
+			continue
+		}
+		if d, err := strconv.ParseFloat(group, 64); err == nil && d > 0 && d < 100 {
+			return d
+		}
+	}
+	return 0
+}
+
+// findBadge returns the first known deal phrase in the card text, or a
+// "Save $50" style label when the card only shows an amount off.
+func findBadge(text string) string {
+	lower := strings.ToLower(text)
+	for _, badge := range dealBadges {
+		if strings.Contains(lower, strings.ToLower(badge)) {
+			return badge
+		}
+	}
+	if m := saveAmountPattern.FindStringSubmatch(text); m != nil {
+		return "Save " + m[1]
+	}
+	return ""
+}
@@ -0,0 +1,666 @@
+package scrapers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+	"price-comparison-api/internal/categories"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/productid"
+	"price-comparison-api/internal/reviews"
+	"price-comparison-api/pkg/politeness"
+	"price-comparison-api/pkg/retry"
+	"price-comparison-api/pkg/utils"
+)
+
+// Availability values reported on product details
+const (
+	AvailabilityInStock    = "in_stock"
+	AvailabilityOutOfStock = "out_of_stock"
+	AvailabilityPreorder   = "preorder"
+	AvailabilityUnknown    = "unknown"
+)
+
+// detailSite describes the retailer behind a product page host.
+type detailSite struct {
+	scraper  string
+	source   string
+	country  string
+	currency string
+}
+
+var detailHosts = map[string]detailSite{
+	"amazon.com":    {config.ScraperAmazon, "Amazon US", "US", "USD"},
+	"amazon.in":     {config.ScraperAmazon, "Amazon IN", "IN", "INR"},
+	"amazon.co.uk":  {config.ScraperAmazon, "Amazon UK", "UK", "GBP"},
+	"amazon.de":     {config.ScraperAmazon, "Amazon DE", "DE", "EUR"},
+	"amazon.ca":     {config.ScraperAmazon, "Amazon CA", "CA", "CAD"},
+	"amazon.com.au": {config.ScraperAmazon, "Amazon AU", "AU", "AUD"},
+	"amazon.fr":     {config.ScraperAmazon, "Amazon FR", "FR", "EUR"},
+	"amazon.it":     {config.ScraperAmazon, "Amazon IT", "IT", "EUR"},
+	"amazon.es":     {config.ScraperAmazon, "Amazon ES", "ES", "EUR"},
+	"amazon.co.jp":  {config.ScraperAmazon, "Amazon JP", "JP", "JPY"},
+	"amazon.com.mx": {config.ScraperAmazon, "Amazon MX", "MX", "MXN"},
+	"amazon.com.br": {config.ScraperAmazon, "Amazon BR", "BR", "BRL"},
+	"ebay.com":      {config.ScraperEbay, "eBay US", "US", "USD"},
+	"ebay.co.uk":    {config.ScraperEbay, "eBay UK", "UK", "GBP"},
+	"ebay.de":       {config.ScraperEbay, "eBay DE", "DE", "EUR"},
+	"ebay.ca":       {config.ScraperEbay, "eBay CA", "CA", "CAD"},
+	"ebay.com.au":   {config.ScraperEbay, "eBay AU", "AU", "AUD"},
+	"ebay.fr":       {config.ScraperEbay, "eBay FR", "FR", "EUR"},
+	"ebay.it":       {config.ScraperEbay, "eBay IT", "IT", "EUR"},
+	"flipkart.com":  {config.ScraperFlipkart, "Flipkart", "IN", "INR"},
+	"walmart.com":   {config.ScraperWalmart, "Walmart US", "US", "USD"},
+	"target.com":    {config.ScraperTarget, "Target US", "US", "USD"},
+	"bestbuy.com":   {config.ScraperBestBuy, "Best Buy US", "US", "USD"},
+}
+
+// detailSelectors are the CSS selectors for one retailer's product page.
+// Each list is tried in order; JSON-LD data fills whatever they miss.
+type detailSelectors struct {
+	title        []string
+	price        []string
+	description  []string
+	specRows     string
+	specKey      string
+	specValue    string
+	seller       []string
+	shipping     []string
+	availability []string
+	images       string
+	// breadcrumbs select each link of the category trail
+	breadcrumbs []string
+	// reviews select the text of each review shown on the page
+	reviews []string
+}
+
+// Breadcrumb markup used when a retailer's own selectors find nothing
+var genericBreadcrumbs = []string{
+	"[itemtype*='BreadcrumbList'] [itemprop='name']",
+	"nav[aria-label='breadcrumb'] li",
+	"nav[aria-label='Breadcrumb'] li",
+	".breadcrumb li",
+	".breadcrumbs a",
+}
+
+// Review markup used when a retailer's own selectors find nothing
+var genericReviews = []string{
+	"[itemprop='reviewBody']",
+	"[itemtype*='schema.org/Review'] [itemprop='description']",
+}
+
+var detailPages = map[string]detailSelectors{
+	config.ScraperAmazon: {
+		title:        []string{"#productTitle"},
+		price:        []string{"#corePrice_feature_div .a-offscreen", ".a-price .a-offscreen", "#priceblock_ourprice"},
+		description:  []string{"#feature-bullets ul", "#productDescription"},
+		specRows:     "#productDetails_techSpec_section_1 tr, #productDetails_detailBullets_sections1 tr, #technicalSpecifications_section_1 tr",
+		specKey:      "th",
+		specValue:    "td",
+		seller:       []string{"#sellerProfileTriggerId", "#merchant-info a", "#merchant-info"},
+		shipping:     []string{"#mir-layout-DELIVERY_BLOCK-slot-PRIMARY_DELIVERY_MESSAGE_LARGE", "#deliveryBlockMessage"},
+		availability: []string{"#availability"},
+		images:       "#altImages img",
+		breadcrumbs:  []string{"#wayfinding-breadcrumbs_feature_div ul li a"},
+		reviews:      []string{"[data-hook='review-body'] span", "[data-hook='review-collapsed'] span"},
+	},
+	config.ScraperEbay: {
+		title:        []string{"h1.x-item-title__mainTitle", "#itemTitle"},
+		price:        []string{".x-price-primary", "#prcIsum"},
+		description:  []string{"#viTabs_0_is", ".x-item-description"},
+		specRows:     ".ux-layout-section-evo__col",
+		specKey:      ".ux-labels-values__labels",
+		specValue:    ".ux-labels-values__values",
+		seller:       []string{".x-sellercard-atf__info__about-seller a", ".mbg-nw"},
+		shipping:     []string{".ux-labels-values--shipping .ux-textspans--BOLD", "#fshippingCost"},
+		availability: []string{"#qtySubTxt", ".x-quantity__availability"},
+		images:       ".ux-image-carousel-item img",
+		breadcrumbs:  []string{"nav.breadcrumbs li a span", ".seo-breadcrumb-text span"},
+		reviews:      []string{".review-item-content p", ".fdbk-container__details__comment span"},
+	},
+	config.ScraperFlipkart: {
+		title:        []string{"span.B_NuCI", "h1 span"},
+		price:        []string{"div._30jeq3._16Jk6d", "div.Nx9bqj"},
+		description:  []string{"div._1mXcCf", "div.yN+eNk"},
+		specRows:     "table._14cfVK tr",
+		specKey:      "td._1hKmbr",
+		specValue:    "td.URwL2w",
+		seller:       []string{"#sellerName span"},
+		shipping:     []string{"div._3XINqE"},
+		availability: []string{"div._16FRp0"},
+		images:       "ul._3GnUWp img, img._396cs4",
+		breadcrumbs:  []string{"div._1MR4o5 a", "div.r2CdBx a"},
+		reviews:      []string{"div.t-ZTKy div div", "div.ZmyHeo div div"},
+	},
+	config.ScraperWalmart: {
+		title:        []string{"h1[itemprop='name']", "h1#main-title"},
+		price:        []string{"[itemprop='price']", "[data-testid='price-wrap'] span"},
+		description:  []string{"[data-testid='product-description-content']", ".dangerous-html"},
+		specRows:     "[data-testid='specifications'] .pb2",
+		specKey:      "h3",
+		specValue:    "span",
+		seller:       []string{"[data-testid='product-seller-info'] a", "a[data-testid='seller-name-link']"},
+		shipping:     []string{"[data-testid='fulfillment-shipping-text']"},
+		availability: []string{"[data-testid='add-to-cart-section']"},
+		images:       "[data-testid='media-thumbnail'] img",
+		breadcrumbs:  []string{"nav[aria-label='breadcrumb'] li a", "[data-testid='breadcrumb'] a"},
+		reviews:      []string{"[data-testid='enhanced-review-content'] span", "[itemprop='reviewBody']"},
+	},
+	config.ScraperTarget: {
+		title:        []string{"h1[data-test='product-title']"},
+		price:        []string{"[data-test='product-price']"},
+		description:  []string{"[data-test='item-details-description']"},
+		specRows:     "[data-test='item-details-specifications'] > div",
+		specKey:      "b",
+		specValue:    "span",
+		shipping:     []string{"[data-test='fulfillment-cell-shipping']"},
+		availability: []string{"[data-test='shippingButton']", "[data-test='outOfStockMessage']"},
+		images:       "[data-test='product-image'] img, [aria-label='image gallery'] img",
+		breadcrumbs:  []string{"[data-test='@web/Breadcrumbs/BreadcrumbLink']", "nav[aria-label='Breadcrumbs'] a"},
+		reviews:      []string{"[data-test='review-card--text']"},
+	},
+	config.ScraperBestBuy: {
+		title:        []string{".sku-title h1", "h1.heading-5"},
+		price:        []string{".priceView-customer-price span", "[data-testid='customer-price'] span"},
+		description:  []string{".product-description", "[data-testid='product-description']"},
+		specRows:     ".spec-table li, .specifications-listing li",
+		specKey:      ".row-title",
+		specValue:    ".row-value",
+		seller:       []string{".marketplace-seller-name"},
+		shipping:     []string{".fulfillment-fulfillment-summary"},
+		availability: []string{".fulfillment-add-to-cart-button button"},
+		images:       ".thumbnail-list img, .primary-image",
+		breadcrumbs:  []string{".shop-breadcrumb li a", "nav[aria-label='Breadcrumb'] li a"},
+		reviews:      []string{".ugc-review-body p", ".review-item-content p"},
+	},
+}
+
+// DetailScraperFor returns the scraper name that handles a product URL.
+func DetailScraperFor(rawURL string) (string, error) {
+	site, _, err := lookupDetailSite(rawURL)
+	if err != nil {
+		return "", err
+	}
+	return site.scraper, nil
+}
+
+// DetailCountry returns the country of the storefront a product URL is on.
+func DetailCountry(rawURL string) (string, error) {
+	site, _, err := lookupDetailSite(rawURL)
+	if err != nil {
+		return "", err
+	}
+	return site.country, nil
+}
+
+func lookupDetailSite(rawURL string) (detailSite, *url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return detailSite{}, nil, fmt.Errorf("invalid product url: %s", rawURL)
+	}
+	host := strings.ToLower(u.Hostname())
+	host = strings.TrimPrefix(host, "www.")
+	host = strings.TrimPrefix(host, "m.")
+	site, ok := detailHosts[host]
+	if !ok {
+		return detailSite{}, nil, fmt.Errorf("unsupported retailer: %s", host)
+	}
+	return site, u, nil
+}
+
+// ScrapeDetail fetches a single product page and extracts the full product
+// data using the retailer's selectors, filling gaps from JSON-LD markup.
+func ScrapeDetail(ctx context.Context, cfg config.ScraperConfig, rawURL string) (*models.ProductDetail, error) {
+	site, u, err := lookupDetailSite(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	sel := detailPages[site.scraper]
+
+	c := colly.NewCollector(colly.StdlibContext(ctx))
+	fingerprintPages(c, site.scraper, PageDetail)
+	detectBlocks(c, site.scraper)
+	timePhases(c)
+	c.OnRequest(func(r *colly.Request) {
+		r.Headers.Set("User-Agent", cfg.UserAgent)
+		r.Headers.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+		r.Headers.Set("Accept-Language", "en-US,en;q=0.5")
+	})
+
+	detail := &models.ProductDetail{
+		Product: models.Product{
+			URL:       rawURL,
+			Source:    site.source,
+			Currency:  site.currency,
+			ScrapedAt: time.Now(),
+		},
+		Country:        site.country,
+		Specifications: map[string]string{},
+		Availability:   AvailabilityUnknown,
+	}
+	detail.ID, detail.SourceProductID = productid.For(site.scraper, site.country, rawURL, "")
+	var ld ldProduct
+	var page, crumbs, ldCrumbs string
+	var snippets []string
+	var found bool
+
+	c.OnResponse(func(r *colly.Response) {
+		page = string(r.Body)
+	})
+
+	c.OnHTML("html", func(e *colly.HTMLElement) {
+		found = true
+		detail.Name = firstText(e, sel.title)
+		detail.Price = firstText(e, sel.price)
+		detail.Description = firstText(e, sel.description)
+		detail.Seller = firstText(e, sel.seller)
+		detail.ShippingCost = firstText(e, sel.shipping)
+
+		if sel.specRows != "" {
+			e.ForEach(sel.specRows, func(_ int, row *colly.HTMLElement) {
+				key := cleanText(row.ChildText(sel.specKey))
+				value := cleanText(row.ChildText(sel.specValue))
+				if key != "" && value != "" {
+					detail.Specifications[key] = value
+				}
+			})
+		}
+
+		seen := map[string]bool{}
+		e.ForEach(sel.images, func(_ int, img *colly.HTMLElement) {
+			src := img.Attr("data-old-hires")
+			if src == "" {
+				src = img.Attr("src")
+			}
+			if src == "" || seen[src] || strings.HasPrefix(src, "data:") {
+				return
+			}
+			seen[src] = true
+			detail.Images = append(detail.Images, e.Request.AbsoluteURL(src))
+		})
+
+		if text := firstText(e, sel.availability); text != "" {
+			detail.Preorder, detail.ReleaseDate = detectRelease(text)
+			if inStock, err := parseAvailability(strings.ToLower(text)); err == nil {
+				detail.Availability = availabilityValue(inStock)
+			}
+		}
+
+		crumbs = breadcrumbText(e, append(sel.breadcrumbs, genericBreadcrumbs...))
+		snippets = allText(e, append(sel.reviews, genericReviews...), reviews.MaxSnippets)
+
+		e.ForEach("script[type='application/ld+json']", func(_ int, s *colly.HTMLElement) {
+			if ld.Name == "" {
+				ld = findLDProduct(s.Text)
+			}
+			if ldCrumbs == "" {
+				ldCrumbs = findLDBreadcrumbs(s.Text)
+			}
+		})
+	})
+	retry.Attach(c)
+
+	if err := politeness.Wait(ctx, u.String()); err != nil {
+		return nil, err
+	}
+	rctx := colly.NewContext()
+	err = retry.Visit(ctx, c, u.String(), rctx)
+	if blocked := blockedFetch(rctx); blocked != nil {
+		return nil, blocked
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch product page: %v", err)
+	}
+	if !found {
+		return nil, fmt.Errorf("product page returned no HTML")
+	}
+
+	ld.fill(detail)
+	if len(snippets) == 0 {
+		snippets = ld.Reviews
+	}
+	detail.ReviewSummary = reviews.Summarize(snippets)
+	if detail.Availability == AvailabilityUnknown {
+		if inStock, err := parseAvailability(page); err == nil {
+			detail.Availability = availabilityValue(inStock)
+		}
+	}
+	if detail.Preorder {
+		detail.Availability = AvailabilityPreorder
+	}
+	detail.InStock = detail.Availability == AvailabilityInStock
+	detail.PriceValue = utils.ParsePrice(detail.Price)
+	if len(detail.Images) > 0 {
+		detail.Image = detail.Images[0]
+	}
+	if len(detail.Specifications) == 0 {
+		detail.Specifications = nil
+	}
+	detail.Category = categories.Detect(crumbs, ldCrumbs, ld.Category, detail.Name)
+
+	if detail.Name == "" {
+		return nil, fmt.Errorf("no product found on page")
+	}
+	return detail, nil
+}
+
+func availabilityValue(inStock bool) string {
+	if inStock {
+		return AvailabilityInStock
+	}
+	return AvailabilityOutOfStock
+}
+
+// firstText returns the text of the first element matched by the first
+// selector that matches anything.
+func firstText(e *colly.HTMLElement, selectors []string) string {
+	for _, selector := range selectors {
+		if text := cleanText(e.DOM.Find(selector).First().Text()); text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
+// allText returns the texts, up to max of them, of the elements matched by
+// the first selector that matches anything.
+func allText(e *colly.HTMLElement, selectors []string, max int) []string {
+	for _, selector := range selectors {
+		var texts []string
+		e.ForEach(selector, func(_ int, el *colly.HTMLElement) {
+			if text := cleanText(el.Text); text != "" && len(texts) < max {
+				texts = append(texts, text)
+			}
+		})
+		if len(texts) > 0 {
+			return texts
+		}
+	}
+	return nil
+}
+
+func cleanText(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// ldProduct is the subset of a schema.org Product read from JSON-LD.
+type ldProduct struct {
+	Name        string
+	URL         string
+	Description string
+	Brand       string
+	Images      []string
+	Price       string
+	Currency    string
+	Seller      string
+	InStock     *bool
+	Preorder    bool
+	ReleaseDate string
+	Category    string
+	// Rating is the aggregate rating out of BestRating, 5 when the markup
+	// doesn't say; ReviewCount is how many reviews or ratings it averages
+	Rating      string
+	BestRating  string
+	ReviewCount string
+	// Reviews are the review texts, for pages whose markup has no review
+	// elements the selectors find
+	Reviews []string
+}
+
+// findLDProduct returns the first Product in a JSON-LD script, which may
+// hold a single object, an array or an @graph.
+func findLDProduct(script string) ldProduct {
+	if products := findLDProducts(script); len(products) > 0 {
+		return products[0]
+	}
+	return ldProduct{}
+}
+
+// findLDProducts returns every Product in a JSON-LD script, including the
+// items of an ItemList, as search pages list their results.
+func findLDProducts(script string) []ldProduct {
+	var raw interface{}
+	if err := json.Unmarshal([]byte(script), &raw); err != nil {
+		return nil
+	}
+
+	var products []ldProduct
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch t := v.(type) {
+		case []interface{}:
+			for _, item := range t {
+				walk(item)
+			}
+		case map[string]interface{}:
+			if ldIsType(t["@type"], "Product") {
+				products = append(products, newLDProduct(t))
+				return
+			}
+			for _, key := range []string{"@graph", "itemListElement", "item", "mainEntity"} {
+				if child, ok := t[key]; ok {
+					walk(child)
+				}
+			}
+		}
+	}
+	walk(raw)
+	return products
+}
+
+// ldIsType reports whether a JSON-LD @type, a string or a list of them,
+// names typ.
+func ldIsType(v interface{}, typ string) bool {
+	if list, ok := v.([]interface{}); ok {
+		for _, item := range list {
+			if ldString(item) == typ {
+				return true
+			}
+		}
+		return false
+	}
+	return ldString(v) == typ
+}
+
+// newLDProduct reads a JSON-LD Product object.
+func newLDProduct(m map[string]interface{}) ldProduct {
+	p := ldProduct{
+		ReleaseDate: ldString(m["releaseDate"]),
+		Name:        ldString(m["name"]),
+		URL:         ldString(m["url"]),
+		Description: ldString(m["description"]),
+		Images:      ldStrings(m["image"]),
+		Category:    ldString(m["category"]),
+	}
+	reviewList := m["review"]
+	if review, ok := reviewList.(map[string]interface{}); ok {
+		reviewList = []interface{}{review}
+	}
+	if list, ok := reviewList.([]interface{}); ok {
+		for _, item := range list {
+			if review, ok := item.(map[string]interface{}); ok {
+				text := ldString(review["reviewBody"])
+				if text == "" {
+					text = ldString(review["description"])
+				}
+				if text != "" {
+					p.Reviews = append(p.Reviews, text)
+				}
+			}
+		}
+	}
+	if rating, ok := m["aggregateRating"].(map[string]interface{}); ok {
+		p.Rating = ldString(rating["ratingValue"])
+		p.BestRating = ldString(rating["bestRating"])
+		p.ReviewCount = ldString(rating["reviewCount"])
+		if p.ReviewCount == "" {
+			p.ReviewCount = ldString(rating["ratingCount"])
+		}
+	}
+	if brand, ok := m["brand"].(map[string]interface{}); ok {
+		p.Brand = ldString(brand["name"])
+	} else {
+		p.Brand = ldString(m["brand"])
+	}
+
+	offers := m["offers"]
+	if list, ok := offers.([]interface{}); ok && len(list) > 0 {
+		offers = list[0]
+	}
+	if offer, ok := offers.(map[string]interface{}); ok {
+		p.Price = ldString(offer["price"])
+		if p.Price == "" {
+			p.Price = ldString(offer["lowPrice"])
+		}
+		p.Currency = ldString(offer["priceCurrency"])
+		if seller, ok := offer["seller"].(map[string]interface{}); ok {
+			p.Seller = ldString(seller["name"])
+		}
+		p.InStock, p.Preorder = ldAvailability(ldString(offer["availability"]))
+	}
+	return p
+}
+
+// findLDBreadcrumbs returns the names in the first BreadcrumbList of a
+// JSON-LD script, joined by spaces.
+func findLDBreadcrumbs(script string) string {
+	var raw interface{}
+	if err := json.Unmarshal([]byte(script), &raw); err != nil {
+		return ""
+	}
+
+	var walk func(v interface{}) []interface{}
+	walk = func(v interface{}) []interface{} {
+		switch t := v.(type) {
+		case []interface{}:
+			for _, item := range t {
+				if list := walk(item); list != nil {
+					return list
+				}
+			}
+		case map[string]interface{}:
+			if ldString(t["@type"]) == "BreadcrumbList" {
+				list, _ := t["itemListElement"].([]interface{})
+				return list
+			}
+			if graph, ok := t["@graph"]; ok {
+				return walk(graph)
+			}
+		}
+		return nil
+	}
+
+	var names []string
+	for _, el := range walk(raw) {
+		m, ok := el.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name := ldString(m["name"])
+		if item, ok := m["item"].(map[string]interface{}); ok && name == "" {
+			name = ldString(item["name"])
+		}
+		names = appendCrumb(names, name)
+	}
+	return strings.Join(names, " ")
+}
+
+// fill copies JSON-LD values into fields the selectors left empty.
+func (p ldProduct) fill(d *models.ProductDetail) {
+	if d.Name == "" {
+		d.Name = p.Name
+	}
+	if d.Description == "" {
+		d.Description = p.Description
+	}
+	if d.Brand == "" {
+		d.Brand = p.Brand
+	}
+	if d.Price == "" {
+		d.Price = p.Price
+	}
+	if p.Currency != "" {
+		d.Currency = p.Currency
+	}
+	if d.Seller == "" {
+		d.Seller = p.Seller
+	}
+	if len(d.Images) == 0 {
+		d.Images = p.Images
+	}
+	if p.Preorder {
+		d.Preorder = true
+	}
+	if d.ReleaseDate == nil && p.ReleaseDate != "" {
+		if t, ok := parseReleaseDate(strings.SplitN(p.ReleaseDate, "T", 2)[0]); ok {
+			d.ReleaseDate = &t
+			if t.After(time.Now()) {
+				d.Preorder = true
+			}
+		}
+	}
+	if p.InStock != nil && d.Availability == AvailabilityUnknown {
+		d.Availability = availabilityValue(*p.InStock)
+	}
+}
+
+// ldAvailability reads a schema.org availability, which pages give as
+// "https://schema.org/InStock" or just "InStock". inStock is nil when the
+// value isn't one.
+func ldAvailability(availability string) (inStock *bool, preorder bool) {
+	if availability == "" {
+		return nil, false
+	}
+	if !strings.Contains(availability, "schema.org/") {
+		availability = "https://schema.org/" + availability
+	}
+	preorder = strings.Contains(strings.ToLower(availability), "preorder")
+	if v, err := parseAvailability(availability); err == nil {
+		inStock = &v
+	}
+	return inStock, preorder
+}
+
+func ldString(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return strings.TrimSpace(t)
+	case float64:
+		return fmt.Sprintf("%g", t)
+	case []interface{}:
+		if len(t) > 0 {
+			return ldString(t[0])
+		}
+	}
+	return ""
+}
+
+func ldStrings(v interface{}) []string {
+	switch t := v.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		var out []string
+		for _, item := range t {
+			if s := ldString(item); s != "" {
+				out = append(out, s)
+			} else if m, ok := item.(map[string]interface{}); ok {
+				if s := ldString(m["url"]); s != "" {
+					out = append(out, s)
+				}
+			}
+		}
+		return out
+	case map[string]interface{}:
+		if s := ldString(t["url"]); s != "" {
+			return []string{s}
+		}
+	}
+	return nil
+}
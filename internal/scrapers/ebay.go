@@ -1,135 +1,177 @@
 package scrapers
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"net/http"
 	"regexp"
 	"strings"
 	"time"
 
 	"github.com/gocolly/colly/v2"
 	"github.com/gocolly/colly/v2/debug"
+	"price-comparison-api/internal/config"
 	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/productid"
+	"price-comparison-api/pkg/politeness"
+	"price-comparison-api/pkg/retry"
 )
 
 type EbayScraper struct {
-	collector *colly.Collector
+	cfg       config.ScraperConfig
+	transport http.RoundTripper
+	ctx       context.Context
+	base      collectorBase
 }
 
-func NewEbayScraper() *EbayScraper {
-	c := colly.NewCollector(
-		colly.AllowedDomains("ebay.com", "www.ebay.com", "ebay.co.uk", "www.ebay.co.uk",
-			"ebay.de", "www.ebay.de", "ebay.ca", "www.ebay.ca", "ebay.com.au", "www.ebay.com.au",
-			"ebay.fr", "www.ebay.fr", "ebay.it", "www.ebay.it"),
-		colly.Debugger(&debug.LogDebugger{}),
-	)
+func NewEbayScraper(cfg config.ScraperConfig) *EbayScraper {
+	return &EbayScraper{cfg: cfg}
+}
+
+// SetTransport replaces the HTTP transport used by the collector.
+func (e *EbayScraper) SetTransport(transport http.RoundTripper) {
+	e.transport = transport
+	e.base.reset()
+}
+
+// SetContext bounds every page fetch by ctx.
+func (e *EbayScraper) SetContext(ctx context.Context) {
+	e.ctx = ctx
+	e.base.reset()
+}
+
+func (e *EbayScraper) newCollector() *colly.Collector {
+	c := e.base.clone(func() *colly.Collector {
+		c := colly.NewCollector(
+			colly.AllowedDomains("ebay.com", "www.ebay.com", "ebay.co.uk", "www.ebay.co.uk",
+				"ebay.de", "www.ebay.de", "ebay.ca", "www.ebay.ca", "ebay.com.au", "www.ebay.com.au",
+				"ebay.fr", "www.ebay.fr", "ebay.it", "www.ebay.it"),
+			colly.Debugger(&debug.LogDebugger{}),
+			colly.AllowURLRevisit(),
+		)
+		politeness.Limit(c, config.ScraperEbay, e.cfg)
+		if e.transport != nil {
+			c.WithTransport(e.transport)
+		}
+		if e.ctx != nil {
+			c.Context = e.ctx
+		}
+		return c
+	})
+	fingerprintPages(c, config.ScraperEbay, PageSearch)
+	detectBlocks(c, config.ScraperEbay)
+	timePhases(c)
 
 	c.OnRequest(func(r *colly.Request) {
-		r.Headers.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
+		r.Headers.Set("User-Agent", e.cfg.UserAgent)
 		r.Headers.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8")
 		r.Headers.Set("Accept-Language", "en-US,en;q=0.5")
 		r.Headers.Set("Accept-Encoding", "gzip, deflate")
 		r.Headers.Set("Cache-Control", "no-cache")
 	})
 
-	c.Limit(&colly.LimitRule{
-		DomainGlob:  "*ebay.*",
-		Parallelism: 1,
-		Delay:       2 * time.Second,
-	})
+	retry.Attach(c)
 
-	return &EbayScraper{collector: c}
+	return c
 }
 
-func (e *EbayScraper) Search(query string, country string) ([]models.Product, error) {
-	// Always return empty slice instead of nil
-	products := make([]models.Product, 0)
+func (e *EbayScraper) Search(ctx context.Context, query string, country string) ([]models.Product, error) {
+	logger := scraperLogger(ctx, config.ScraperEbay, query, country)
 
 	searchURL := e.getSearchURL(query, country)
-	log.Printf("Searching eBay (%s) with URL: %s", country, searchURL)
-
-	selectors := []string{
-		".s-item",
-		"div.s-item",
-		"[data-view='mi:1686|iid:1']",
-	}
-
-	foundAny := false
-
-	e.collector.OnResponse(func(r *colly.Response) {
-		log.Printf("eBay (%s) Response status: %d", country, r.StatusCode)
-		bodyStr := string(r.Body)
-		log.Printf("Page contains 's-item': %v", strings.Contains(bodyStr, "s-item"))
-	})
-
-	for _, selector := range selectors {
-		log.Printf("Trying eBay (%s) selector: %s", country, selector)
-
-		e.collector.OnHTML(selector, func(element *colly.HTMLElement) {
-			foundAny = true
-
-			product := models.Product{
-				Source:    fmt.Sprintf("eBay %s", country),
-				Currency:  e.getCurrencyForCountry(country),
-				ScrapedAt: time.Now(),
-				InStock:   true,
-			}
-
-			// Extract product details
-			product.Name = e.cleanEbayProductName(strings.TrimSpace(element.ChildText("h3.s-item__title, .s-item__title")))
-			if product.Name == "" {
-				return // Skip if no valid name
+	logger.Info().Msgf("Searching eBay (%s) with URL: %s", country, searchURL)
+
+	sel := siteSelectors(config.ScraperEbay)
+	search := cardSearch{
+		url:          searchURL,
+		cards:        sel.Cards,
+		newCollector: e.newCollector,
+		newStructured: func() *structuredResults {
+			return newStructuredResults(ctx, config.ScraperEbay, country,
+				e.getCurrencyForCountry(country), fmt.Sprintf("eBay %s", country))
+		},
+		parse: func(element *colly.HTMLElement) (models.Product, bool) {
+			product, ok := e.parseCard(element, sel, country)
+			if ok {
+				logger.Debug().Msgf("Found eBay (%s) product: %s - %s", country, product.Name, product.Price)
 			}
+			return product, ok
+		},
+		onResponse: func(r *colly.Response) {
+			logger.Debug().Msgf("eBay (%s) Response status: %d", country, r.StatusCode)
+			bodyStr := string(r.Body)
+			logger.Debug().Msgf("Page contains 's-item': %v", strings.Contains(bodyStr, "s-item"))
+		},
+		logger: logger,
+	}
+	attempt, failed := search.run(ctx)
+	if isBlocked(attempt.err) {
+		return attempt.products, attempt.err
+	}
+	products := attempt.results()
 
-			product.Price = e.extractPrice(element, country)
-			product.URL = e.extractURL(element, country)
-			product.Image = element.ChildAttr("img", "src")
-			product.Rating = strings.TrimSpace(element.ChildText(".ebay-review-stars"))
-			product.Reviews = strings.TrimSpace(element.ChildText(".s-item__reviews-count"))
+	if len(products) == 0 && failed == len(sel.Cards) {
+		logger.Warn().Msgf("eBay (%s): No products found and all selectors failed for query: %s", country, query)
+		return products, fmt.Errorf("all eBay scraping attempts failed")
+	}
 
-			if product.Price != "" {
-				product.ID = fmt.Sprintf("ebay_%s_%d", country, time.Now().UnixNano())
-				products = append(products, product)
-				log.Printf("Found eBay (%s) product: %s - %s", country, product.Name, product.Price)
-			}
-		})
+	if len(products) == 0 {
+		logger.Info().Msgf("No eBay (%s) products found for query: %s", country, query)
+	}
 
-		err := e.collector.Visit(searchURL)
-		if err != nil {
-			log.Printf("Error visiting eBay (%s): %v", country, err)
-		}
+	logger.Info().Msgf("eBay (%s) found %d products", country, len(products))
+	return products, nil
+}
 
-		if foundAny {
-			break
-		}
+func (e *EbayScraper) parseCard(element *colly.HTMLElement, sel SiteSelectors, country string) (models.Product, bool) {
+	product := models.Product{
+		Source:    fmt.Sprintf("eBay %s", country),
+		Currency:  e.getCurrencyForCountry(country),
+		ScrapedAt: time.Now(),
+		InStock:   true,
+	}
+	applyRelease(&product, element.Text)
 
-		// Reset collector for next selector
-		e.collector = e.collector.Clone()
+	// Extract product details
+	product.Name = e.cleanEbayProductName(childText(element, sel.Name))
+	if product.Name == "" {
+		return product, false // Skip if no valid name
 	}
 
-	if !foundAny {
-		log.Printf("No eBay (%s) products found for query: %s", country, query)
+	product.Price = e.extractPrice(element, sel, country)
+	product.URL = childAttr(element, sel.URL, "href")
+	product.Image = childAttr(element, sel.Image, "src")
+	product.Rating = childText(element, sel.Rating)
+	product.Reviews = childText(element, sel.Reviews)
+
+	if product.Price == "" {
+		return product, false
 	}
+	applyDeal(&product, element, config.ScraperEbay)
+	applyCondition(&product, element, config.ScraperEbay)
+	applySeller(&product, element, config.ScraperEbay)
+	applyCategory(&product, element, config.ScraperEbay)
+	product.ID, product.SourceProductID = productid.For(config.ScraperEbay, country, product.URL, product.Name)
+	return product, true
+}
 
-	log.Printf("eBay (%s) found %d products", country, len(products))
-	return products, nil
+// ebaySearchURLs are the country-specific search URLs; other countries are
+// searched on ebay.com
+var ebaySearchURLs = map[string]string{
+	"US": "https://www.ebay.com/sch/i.html?_nkw=%s&_sacat=0",
+	"UK": "https://www.ebay.co.uk/sch/i.html?_nkw=%s&_sacat=0",
+	"DE": "https://www.ebay.de/sch/i.html?_nkw=%s&_sacat=0",
+	"CA": "https://www.ebay.ca/sch/i.html?_nkw=%s&_sacat=0",
+	"AU": "https://www.ebay.com.au/sch/i.html?_nkw=%s&_sacat=0",
+	"FR": "https://www.ebay.fr/sch/i.html?_nkw=%s&_sacat=0",
+	"IT": "https://www.ebay.it/sch/i.html?_nkw=%s&_sacat=0",
+	"IN": "https://www.ebay.com/sch/i.html?_nkw=%s&_sacat=0",
 }
 
 func (e *EbayScraper) getSearchURL(query, country string) string {
-	domains := map[string]string{
-		"US": "https://www.ebay.com/sch/i.html?_nkw=%s&_sacat=0",
-		"UK": "https://www.ebay.co.uk/sch/i.html?_nkw=%s&_sacat=0",
-		"DE": "https://www.ebay.de/sch/i.html?_nkw=%s&_sacat=0",
-		"CA": "https://www.ebay.ca/sch/i.html?_nkw=%s&_sacat=0",
-		"AU": "https://www.ebay.com.au/sch/i.html?_nkw=%s&_sacat=0",
-		"FR": "https://www.ebay.fr/sch/i.html?_nkw=%s&_sacat=0",
-		"IT": "https://www.ebay.it/sch/i.html?_nkw=%s&_sacat=0",
-		"IN": "https://www.ebay.com/sch/i.html?_nkw=%s&_sacat=0",
-	}
-
-	baseURL := domains[country]
+	baseURL := ebaySearchURLs[country]
 	if baseURL == "" {
-		baseURL = domains["US"] // fallback to US
+		baseURL = ebaySearchURLs["US"] // fallback to US
 	}
 
 	return fmt.Sprintf(baseURL, strings.ReplaceAll(query, " ", "+"))
@@ -153,31 +195,13 @@ func (e *EbayScraper) getCurrencyForCountry(country string) string {
 	return "USD"
 }
 
-func (e *EbayScraper) extractPrice(element *colly.HTMLElement, country string) string {
-	priceSelectors := []string{
-		".s-item__price .notranslate",
-		".s-item__price",
-		".s-item__detail .s-item__price",
+func (e *EbayScraper) extractPrice(element *colly.HTMLElement, sel SiteSelectors, country string) string {
+	if price := childText(element, sel.Price); price != "" {
+		return e.formatPriceForCountry(price, country)
 	}
-
-	for _, selector := range priceSelectors {
-		price := strings.TrimSpace(element.ChildText(selector))
-		if price != "" {
-			return e.formatPriceForCountry(price, country)
-		}
-	}
-
 	return ""
 }
 
-func (e *EbayScraper) extractURL(element *colly.HTMLElement, country string) string {
-	url := element.ChildAttr("h3.s-item__title a, .s-item__title a", "href")
-	if url == "" {
-		url = element.ChildAttr("a", "href")
-	}
-	return url
-}
-
 func (e *EbayScraper) formatPriceForCountry(price, country string) string {
 	// Clean up the price string
 	price = strings.TrimSpace(price)
@@ -243,9 +267,5 @@ func (e *EbayScraper) cleanEbayProductName(name string) string {
 	cleanName = strings.TrimSpace(cleanName)
 	cleanName = regexp.MustCompile(`\s+`).ReplaceAllString(cleanName, " ")
 
-	if len(cleanName) > 80 {
-		cleanName = cleanName[:80] + "..."
-	}
-
 	return cleanName
 }
@@ -0,0 +1,295 @@
+package scrapers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/productid"
+	"price-comparison-api/internal/scrapetrace"
+	"price-comparison-api/pkg/politeness"
+	"price-comparison-api/pkg/retry"
+)
+
+// EtsyScraper searches Etsy's US, UK, Canadian and Australian storefronts for
+// handmade and marketplace goods. Etsy shows prices in the visitor's currency
+// but falls back to the seller's for some listings, so each price is read
+// with its own currency symbol rather than assumed from the country.
+type EtsyScraper struct {
+	cfg       config.ScraperConfig
+	transport http.RoundTripper
+	ctx       context.Context
+	base      collectorBase
+}
+
+// etsyRegions maps supported countries to their storefront path, the
+// currency Etsy prices them in and the ship_to region.
+var etsyRegions = map[string]struct {
+	Path     string
+	Currency string
+	ShipTo   string
+}{
+	"US": {"", "USD", "US"},
+	"UK": {"/uk", "GBP", "GB"},
+	"CA": {"/ca", "CAD", "CA"},
+	"AU": {"/au", "AUD", "AU"},
+}
+
+// EtsyCovers reports whether Etsy has a storefront for country.
+func EtsyCovers(country string) bool {
+	_, ok := etsyRegions[strings.ToUpper(country)]
+	return ok
+}
+
+var (
+	// Longest symbols first so "CA$" isn't read as "$"
+	etsyCurrencySymbols = []struct {
+		Symbol   string
+		Currency string
+	}{
+		{"US$", "USD"}, {"CA$", "CAD"}, {"AU$", "AUD"}, {"A$", "AUD"}, {"NZ$", "NZD"},
+		{"£", "GBP"}, {"€", "EUR"}, {"₹", "INR"}, {"¥", "JPY"},
+	}
+
+	// "CA$24.99", "£1,250.00", "$8.50+"
+	etsyPricePattern = regexp.MustCompile(`(?:[A-Z]{1,2}\$|[$£€₹¥])\s?\d[\d,]*(?:\.\d{1,2})?`)
+	// "4.9 out of 5 stars", "5 out of 5 stars"
+	etsyStarsPattern = regexp.MustCompile(`(?i)([0-5](?:\.\d+)?)\s*out of 5 stars`)
+	// "(12,345)", "(1.2k)"
+	etsyCountPattern = regexp.MustCompile(`\((\d[\d,.]*[kK]?)\)`)
+)
+
+func NewEtsyScraper(cfg config.ScraperConfig) *EtsyScraper {
+	return &EtsyScraper{cfg: cfg}
+}
+
+// SetTransport replaces the HTTP transport used by the collector.
+func (e *EtsyScraper) SetTransport(transport http.RoundTripper) {
+	e.transport = transport
+	e.base.reset()
+}
+
+// SetContext bounds every page fetch by ctx.
+func (e *EtsyScraper) SetContext(ctx context.Context) {
+	e.ctx = ctx
+	e.base.reset()
+}
+
+func (e *EtsyScraper) newCollector() *colly.Collector {
+	c := e.base.clone(func() *colly.Collector {
+		c := colly.NewCollector(
+			colly.AllowedDomains("etsy.com", "www.etsy.com"),
+			colly.AllowURLRevisit(),
+		)
+		politeness.Limit(c, config.ScraperEtsy, e.cfg)
+		if e.transport != nil {
+			c.WithTransport(e.transport)
+		}
+		if e.ctx != nil {
+			c.Context = e.ctx
+		}
+		return c
+	})
+	fingerprintPages(c, config.ScraperEtsy, PageSearch)
+	detectBlocks(c, config.ScraperEtsy)
+	timePhases(c)
+
+	c.OnRequest(func(r *colly.Request) {
+		r.Headers.Set("User-Agent", e.cfg.UserAgent)
+		r.Headers.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+		r.Headers.Set("Accept-Language", "en-US,en;q=0.5")
+	})
+
+	retry.Attach(c)
+
+	return c
+}
+
+func (e *EtsyScraper) Search(ctx context.Context, query, country string) ([]models.Product, error) {
+	logger := scraperLogger(ctx, config.ScraperEtsy, query, country)
+	products := make([]models.Product, 0)
+	country = strings.ToUpper(country)
+	region, ok := etsyRegions[country]
+	if !ok {
+		logger.Info().Msgf("Etsy does not cover %s", country)
+		return products, nil
+	}
+
+	searchURL := e.getSearchURL(query, country)
+	logger.Info().Msgf("Searching Etsy (%s) with URL: %s", country, searchURL)
+
+	seen := make(map[string]bool)
+	sel := siteSelectors(config.ScraperEtsy)
+	collector := e.newCollector()
+	structured := newStructuredResults(ctx, config.ScraperEtsy, country, region.Currency, fmt.Sprintf("Etsy %s", country))
+	structured.attach(collector)
+	collector.OnResponse(func(r *colly.Response) {
+		logger.Debug().Msgf("Etsy (%s) Response status: %d", country, r.StatusCode)
+	})
+	collector.OnHTML(sel.card(), func(el *colly.HTMLElement) {
+		scrapetrace.RecordHit(ctx, sel.card())
+		product, ok := e.parseCard(el, sel, country, region.Currency)
+		if !ok || seen[product.URL] {
+			return
+		}
+		seen[product.URL] = true
+		products = append(products, product)
+		logger.Debug().Msgf("Found Etsy (%s) product: %s - %s", country, product.Name, product.Price)
+	})
+
+	if err := visit(ctx, collector, searchURL); err != nil {
+		logger.Warn().Err(err).Msgf("Error visiting Etsy %s", country)
+		return products, fmt.Errorf("etsy: %w", err)
+	}
+	products = structured.merge(products)
+
+	logger.Info().Msgf("Etsy %s found %d products", country, len(products))
+	return products, nil
+}
+
+func (e *EtsyScraper) getSearchURL(query, country string) string {
+	region := etsyRegions[country]
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("ship_to", region.ShipTo)
+	return fmt.Sprintf("https://www.etsy.com%s/search?%s", region.Path, params.Encode())
+}
+
+func (e *EtsyScraper) parseCard(el *colly.HTMLElement, sel SiteSelectors, country, currency string) (models.Product, bool) {
+	product := models.Product{
+		Source:    fmt.Sprintf("Etsy %s", country),
+		Currency:  currency,
+		ScrapedAt: time.Now(),
+		InStock:   true,
+	}
+
+	product.Name = firstText(el, sel.Name)
+	if product.Name == "" {
+		product.Name = strings.TrimSpace(el.ChildAttr("a[title]", "title"))
+	}
+	if len(product.Name) <= 5 {
+		return product, false
+	}
+
+	price, priceCurrency := etsyPrice(firstText(el, sel.Price), currency)
+	if price == "" {
+		if price, priceCurrency = etsyPrice(el.Text, currency); price == "" {
+			return product, false
+		}
+	}
+	product.Price, product.Currency = price, priceCurrency
+
+	for _, selector := range sel.URL {
+		if product.URL = etsyURL(el.ChildAttr(selector, "href")); product.URL != "" {
+			break
+		}
+	}
+
+	for _, attr := range []string{"src", "data-src"} {
+		if src := childAttr(el, sel.Image, attr); src != "" && !strings.HasPrefix(src, "data:") {
+			product.Image = src
+			break
+		}
+	}
+
+	// Etsy cards rate the shop, not the item: the stars are the shop's
+	// average and the count its number of reviews
+	product.Merchant = etsyShopName(el, sel.Merchant)
+	product.Rating, product.Reviews = etsyShopRating(el, sel.Rating)
+
+	if strings.Contains(strings.ToLower(el.Text), "sold out") {
+		product.InStock = false
+	}
+
+	applyDeal(&product, el, config.ScraperEtsy)
+	applyCondition(&product, el, config.ScraperEtsy)
+
+	applyCategory(&product, el, config.ScraperEtsy)
+	product.ID, product.SourceProductID = productid.For(config.ScraperEtsy, country, product.URL, product.Name)
+	return product, true
+}
+
+// etsyPrice finds the first price in text and the currency its symbol
+// stands for. A bare "$" is the storefront's own dollar.
+func etsyPrice(text, currency string) (string, string) {
+	price := etsyPricePattern.FindString(text)
+	if price == "" {
+		return "", ""
+	}
+	price = strings.Join(strings.Fields(price), "")
+	for _, s := range etsyCurrencySymbols {
+		if strings.HasPrefix(price, s.Symbol) {
+			return price, s.Currency
+		}
+	}
+	if strings.HasPrefix(price, "$") {
+		switch currency {
+		case "USD", "CAD", "AUD":
+			return price, currency
+		}
+		return price, "USD"
+	}
+	return price, currency
+}
+
+func etsyShopName(el *colly.HTMLElement, selectors []string) string {
+	if name := strings.TrimSpace(el.ChildAttr("[data-shop-name]", "data-shop-name")); name != "" {
+		return name
+	}
+	name := firstText(el, selectors)
+	name = strings.TrimPrefix(name, "Ad by ")
+	name = strings.TrimPrefix(name, "From shop ")
+	if etsyStarsPattern.MatchString(name) || etsyCountPattern.MatchString(name) {
+		return ""
+	}
+	return strings.TrimSpace(name)
+}
+
+// etsyShopRating reads the shop's star score ("4.9/5") and review count. The
+// score is in screen-reader text, or the value of the hidden input inputs
+// match on older layouts.
+func etsyShopRating(el *colly.HTMLElement, inputs []string) (string, string) {
+	rating := ""
+	if m := etsyStarsPattern.FindStringSubmatch(el.Text); m != nil {
+		rating = m[1]
+	} else if value := childAttr(el, inputs, "value"); value != "" {
+		rating = value
+	}
+	if i := strings.Index(rating, "."); i >= 0 && len(rating) > i+2 {
+		rating = rating[:i+2]
+	}
+	if rating != "" {
+		rating += "/5"
+	}
+
+	reviews := ""
+	if m := etsyCountPattern.FindStringSubmatch(el.Text); m != nil {
+		reviews = m[1]
+	}
+	return rating, reviews
+}
+
+// etsyURL makes relative links absolute and drops tracking parameters.
+func etsyURL(href string) string {
+	switch {
+	case href == "":
+		return ""
+	case strings.HasPrefix(href, "//"):
+		href = "https:" + href
+	case strings.HasPrefix(href, "/"):
+		href = "https://www.etsy.com" + href
+	}
+	if u, err := url.Parse(href); err == nil && strings.Contains(u.Path, "/listing/") {
+		u.RawQuery = ""
+		u.Fragment = ""
+		return u.String()
+	}
+	return href
+}
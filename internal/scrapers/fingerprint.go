@@ -0,0 +1,289 @@
+package scrapers
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly/v2"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/net/html"
+	"price-comparison-api/pkg/metrics"
+)
+
+// Page kinds, fingerprinted apart since a retailer's search and product
+// pages are laid out differently.
+const (
+	PageSearch   = "search"
+	PageDetail   = "detail"
+	PageRendered = "rendered"
+)
+
+// fingerprintRegions are the parts of a page whose structure is hashed. A
+// redesign shows up as a change in one or more of them, while new products
+// or prices don't.
+var fingerprintRegions = []struct{ name, selector string }{
+	{"head", "head"},
+	{"header", "header, #header, [role=banner]"},
+	{"nav", "nav, [role=navigation]"},
+	{"main", "main, #main, [role=main]"},
+	{"forms", "form"},
+	{"footer", "footer, #footer, [role=contentinfo]"},
+}
+
+// fingerprintDepth is how many levels below a region's root are hashed;
+// product cards sit deeper, so their number and contents don't matter.
+const fingerprintDepth = 4
+
+// PageFingerprint is a compact summary of a fetched page: its status and
+// size, and a hash of each key region's element structure. Layout combines
+// the region hashes, and changing from one fetch to the next means the
+// retailer changed its page.
+type PageFingerprint struct {
+	Domain  string `json:"domain"`
+	Kind    string `json:"kind"`
+	Scraper string `json:"scraper,omitempty"`
+	Status  int    `json:"status"`
+	Bytes   int    `json:"bytes"`
+	// Regions holds the hash of each region found on the page
+	Regions map[string]string `json:"regions,omitempty"`
+	Layout  string            `json:"layout,omitempty"`
+	At      time.Time         `json:"at"`
+
+	// Changes counts the layout changes seen since the server started;
+	// ChangedAt and ChangedRegions describe the last one
+	Changes        int        `json:"changes"`
+	ChangedAt      *time.Time `json:"changed_at,omitempty"`
+	ChangedRegions []string   `json:"changed_regions,omitempty"`
+}
+
+var (
+	fingerprintsMu sync.Mutex
+	// fingerprints holds the last fingerprint per domain and page kind
+	fingerprints = map[string]*PageFingerprint{}
+)
+
+// Fingerprints returns the last fingerprint of each domain and page kind,
+// most recently changed first.
+func Fingerprints() []PageFingerprint {
+	fingerprintsMu.Lock()
+	list := make([]PageFingerprint, 0, len(fingerprints))
+	for _, fp := range fingerprints {
+		list = append(list, *fp)
+	}
+	fingerprintsMu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		ci, cj := list[i].ChangedAt, list[j].ChangedAt
+		if (ci == nil) != (cj == nil) {
+			return ci != nil
+		}
+		if ci != nil && !ci.Equal(*cj) {
+			return ci.After(*cj)
+		}
+		if list[i].Domain != list[j].Domain {
+			return list[i].Domain < list[j].Domain
+		}
+		return list[i].Kind < list[j].Kind
+	})
+	return list
+}
+
+// fingerprintPages logs a fingerprint of every page c fetches, failed ones
+// included.
+func fingerprintPages(c *colly.Collector, scraper, kind string) {
+	c.OnResponse(func(r *colly.Response) {
+		recordFingerprint(r.Request.URL, scraper, kind, r.StatusCode, r.Headers.Get("Content-Type"), r.Body)
+	})
+	c.OnError(func(r *colly.Response, err error) {
+		if r == nil || r.Request == nil || r.StatusCode == 0 {
+			return
+		}
+		recordFingerprint(r.Request.URL, scraper, kind, r.StatusCode, r.Headers.Get("Content-Type"), r.Body)
+	})
+}
+
+// recordFingerprint fingerprints a page, logs it and, when the page is a
+// successfully fetched HTML page whose layout differs from the last one
+// seen for its domain and kind, warns which regions changed.
+func recordFingerprint(pageURL *url.URL, scraper, kind string, status int, contentType string, body []byte) {
+	if pageURL == nil {
+		return
+	}
+	fp := &PageFingerprint{
+		Domain:  strings.TrimPrefix(pageURL.Hostname(), "www."),
+		Kind:    kind,
+		Scraper: scraper,
+		Status:  status,
+		Bytes:   len(body),
+		At:      time.Now(),
+	}
+	isHTML := strings.Contains(contentType, "html") || (contentType == "" && bytes.Contains(body[:min(len(body), 512)], []byte("<")))
+	if isHTML && len(body) > 0 {
+		fp.Regions, fp.Layout = pageLayout(body)
+	}
+
+	event := log.Info().
+		Str("domain", fp.Domain).
+		Str("kind", fp.Kind).
+		Str("scraper", fp.Scraper).
+		Int("status", fp.Status).
+		Int("bytes", fp.Bytes).
+		Str("layout", fp.Layout)
+	names := make([]string, 0, len(fp.Regions))
+	for name := range fp.Regions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		event = event.Str("region_"+name, fp.Regions[name])
+	}
+	event.Msg("Page fingerprint")
+
+	if status < 200 || status >= 300 || fp.Layout == "" {
+		metrics.ObservePage(fp.Domain, fp.Kind, status, false)
+		return
+	}
+	key := fp.Domain + "|" + fp.Kind
+	fingerprintsMu.Lock()
+	defer fingerprintsMu.Unlock()
+	last := fingerprints[key]
+	changed := last != nil && last.Layout != fp.Layout
+	metrics.ObservePage(fp.Domain, fp.Kind, status, changed)
+	if last != nil {
+		fp.Changes, fp.ChangedAt, fp.ChangedRegions = last.Changes, last.ChangedAt, last.ChangedRegions
+		if changed {
+			fp.Changes++
+			fp.ChangedAt = &fp.At
+			fp.ChangedRegions = changedRegions(last.Regions, fp.Regions)
+			log.Warn().
+				Str("domain", fp.Domain).
+				Str("kind", fp.Kind).
+				Str("scraper", fp.Scraper).
+				Str("previous_layout", last.Layout).
+				Str("layout", fp.Layout).
+				Strs("changed_regions", fp.ChangedRegions).
+				Msg("Page layout changed; the retailer may have shipped a redesign, check its selectors")
+		}
+	}
+	fingerprints[key] = fp
+}
+
+// pageLayout hashes the structure of each region of an HTML page, and all
+// of them together.
+func pageLayout(body []byte) (map[string]string, string) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, ""
+	}
+	regions := map[string]string{}
+	var names []string
+	for _, region := range fingerprintRegions {
+		matches := doc.Find(region.selector)
+		if matches.Length() == 0 {
+			continue
+		}
+		signatures := map[string]bool{}
+		matches.Each(func(_ int, s *goquery.Selection) {
+			collectSignatures(s.Nodes[0], "", 0, signatures)
+		})
+		regions[region.name] = hashSignatures(signatures)
+		names = append(names, region.name+"="+regions[region.name])
+	}
+	if _, ok := regions["head"]; len(regions) == 0 || (ok && len(regions) == 1) {
+		// Pages without landmark elements are hashed from the body down
+		signatures := map[string]bool{}
+		if page := doc.Find("body"); page.Length() > 0 {
+			collectSignatures(page.Nodes[0], "", 0, signatures)
+			regions["body"] = hashSignatures(signatures)
+			names = append(names, "body="+regions["body"])
+		}
+	}
+	if len(names) == 0 {
+		return nil, ""
+	}
+	return regions, hashString(strings.Join(names, "\n"))
+}
+
+// collectSignatures adds the signature of n and its descendants, down to
+// fingerprintDepth levels, each qualified by its parent's. Repeated
+// elements give one signature, so a longer list doesn't change the hash.
+func collectSignatures(n *html.Node, parent string, depth int, signatures map[string]bool) {
+	if n.Type != html.ElementNode || depth > fingerprintDepth {
+		return
+	}
+	signature := elementSignature(n)
+	signatures[parent+">"+signature] = true
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		collectSignatures(child, signature, depth+1, signatures)
+	}
+}
+
+// elementSignature is an element's tag, id and classes. Ids and classes
+// with digits are left out: they are usually generated per build or per
+// product rather than part of the design.
+func elementSignature(n *html.Node) string {
+	var b strings.Builder
+	b.WriteString(n.Data)
+	var classes []string
+	for _, attr := range n.Attr {
+		switch attr.Key {
+		case "id":
+			if stableName(attr.Val) {
+				b.WriteString("#" + attr.Val)
+			}
+		case "class":
+			for _, class := range strings.Fields(attr.Val) {
+				if stableName(class) {
+					classes = append(classes, class)
+				}
+			}
+		}
+	}
+	sort.Strings(classes)
+	for _, class := range classes {
+		b.WriteString("." + class)
+	}
+	return b.String()
+}
+
+func stableName(name string) bool {
+	return name != "" && len(name) <= 40 && !strings.ContainsAny(name, "0123456789")
+}
+
+func hashSignatures(signatures map[string]bool) string {
+	list := make([]string, 0, len(signatures))
+	for s := range signatures {
+		list = append(list, s)
+	}
+	sort.Strings(list)
+	return hashString(strings.Join(list, "\n"))
+}
+
+func hashString(s string) string {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+func changedRegions(before, after map[string]string) []string {
+	var changed []string
+	for name, hash := range after {
+		if before[name] != hash {
+			changed = append(changed, name)
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
@@ -0,0 +1,171 @@
+package scrapers
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"price-comparison-api/internal/config"
+)
+
+// SelfTestCase describes a canned search page used to verify a scraper's
+// extraction logic without touching the live site.
+type SelfTestCase struct {
+	Query   string
+	Country string
+	// Host is the retailer host the scraper talks to, used for DNS checks
+	Host string
+	HTML string
+}
+
+// SelfTestCases holds one minimal search result page per built-in scraper.
+var SelfTestCases = map[string]SelfTestCase{
+	config.ScraperAmazon: {
+		Query: "selftest widget", Country: "US", Host: "www.amazon.com",
+		HTML: `<html><body>
+			<div data-component-type="s-search-result" data-asin="B000SELFTEST">
+				<h2><a href="/dp/B000SELFTEST"><span>Selftest Widget Pro</span></a></h2>
+				<span class="a-price"><span class="a-price-whole">19</span></span>
+				<img class="s-image" src="https://m.media-amazon.com/images/selftest.jpg">
+			</div>
+		</body></html>`,
+	},
+	config.ScraperEbay: {
+		Query: "selftest widget", Country: "US", Host: "www.ebay.com",
+		HTML: `<html><body>
+			<div class="s-item">
+				<h3 class="s-item__title"><a href="https://www.ebay.com/itm/100000000001">Selftest Widget Pro</a></h3>
+				<span class="s-item__price">$19.99</span>
+				<img src="https://i.ebayimg.com/images/selftest.jpg">
+			</div>
+		</body></html>`,
+	},
+	config.ScraperFlipkart: {
+		Query: "selftest widget", Country: "IN", Host: "www.flipkart.com",
+		HTML: `<html><body>
+			<div data-id="SELFTEST01">
+				<a href="/selftest-widget/p/itmselftest"><div class="_4rR01T">Selftest Widget Pro</div></a>
+				<div class="_30jeq3">₹1,999</div>
+				<img class="_396cs4" src="https://rukminim1.flixcart.com/selftest.jpg">
+			</div>
+		</body></html>`,
+	},
+	config.ScraperWalmart: {
+		Query: "selftest widget", Country: "US", Host: "www.walmart.com",
+		HTML: `<html><body>
+			<div data-testid="item">
+				<a data-testid="product-title" href="/ip/selftest/100001"><span data-automation-id="product-title">Selftest Widget Pro</span></a>
+				<span itemprop="price">$19.99</span>
+				<img data-testid="productTileImage" src="https://i5.walmartimages.com/selftest.jpg">
+			</div>
+		</body></html>`,
+	},
+	config.ScraperTarget: {
+		Query: "selftest widget", Country: "US", Host: "www.target.com",
+		HTML: `<html><body>
+			<div data-test="product-card">
+				<a data-test="product-title" href="/p/selftest/-/A-100001">Selftest Widget Pro</a>
+				<span data-test="product-price">$19.99</span>
+				<img src="https://target.scene7.com/is/image/Target/selftest">
+			</div>
+		</body></html>`,
+	},
+	config.ScraperBestBuy: {
+		Query: "selftest widget", Country: "US", Host: "www.bestbuy.com",
+		HTML: `<html><body>
+			<li class="sku-item">
+				<h4 class="sku-header"><a href="/site/selftest/100001.p">Selftest Widget Pro</a></h4>
+				<div class="sku-price">$19.99</div>
+				<img class="product-image" src="https://pisces.bbystatic.com/selftest.jpg">
+			</li>
+		</body></html>`,
+	},
+	config.ScraperGoogleShopping: {
+		Query: "selftest widget", Country: "US", Host: "www.google.com",
+		HTML: `<html><body>
+			<div class="sh-dgr__grid-result">
+				<a href="/url?url=https://www.example-store.com/selftest-widget"><h3>Selftest Widget Pro</h3></a>
+				<span class="a8Pemb">$19.99</span>
+				<div class="aULzUe">Example Store</div>
+				<img src="https://encrypted-tbn0.gstatic.com/selftest.jpg">
+			</div>
+		</body></html>`,
+	},
+	config.ScraperAliExpress: {
+		Query: "selftest widget", Country: "US", Host: "www.aliexpress.com",
+		HTML: `<html><body>
+			<a class="search-card-item" href="//www.aliexpress.com/item/1005000000001.html?spm=selftest">
+				<img src="//ae01.alicdn.com/kf/selftest.jpg">
+				<h3>Selftest Widget Pro</h3>
+				<div class="multi--price-sale--selftest">US $19.99</div>
+				<span class="multi--trade--selftest">1,000+ sold</span>
+				<span class="multi--evaluation--selftest">4.8</span>
+			</a>
+		</body></html>`,
+	},
+	config.ScraperEtsy: {
+		Query: "selftest widget", Country: "UK", Host: "www.etsy.com",
+		HTML: `<html><body>
+			<div class="v2-listing-card" data-listing-id="1000000001">
+				<a href="https://www.etsy.com/uk/listing/1000000001/selftest-widget?ref=search">
+					<img src="https://i.etsystatic.com/selftest.jpg">
+					<h3 class="v2-listing-card__title">Selftest Widget Handmade</h3>
+				</a>
+				<div class="v2-listing-card__shop"><p>SelftestShop</p></div>
+				<span class="wt-screen-reader-only">4.9 out of 5 stars</span> <span>(1,234)</span>
+				<p class="lc-price"><span class="currency-symbol">£</span><span class="currency-value">19.99</span></p>
+			</div>
+		</body></html>`,
+	},
+	config.ScraperCostco: {
+		Query: "selftest widget", Country: "US", Host: "www.costco.com",
+		HTML: `<html><body>
+			<div class="product-tile-set">
+				<img src="https://cdn.bfldr.com/selftest.jpg">
+				<span class="description"><a href="https://www.costco.com/selftest-widget.product.100000001.html">Selftest Widget Pro, 2-pack</a></span>
+				<div class="price">$19.99</div>
+				<span aria-label="Rated 4.6 out of 5 stars">(1,234)</span>
+			</div>
+		</body></html>`,
+	},
+	config.ScraperSamsClub: {
+		Query: "selftest widget", Country: "US", Host: "www.samsclub.com",
+		HTML: `<html><body>
+			<div data-testid="product-tile">
+				<a href="/p/selftest-widget/P000000001">
+					<img src="https://scene7.samsclub.com/selftest.jpg">
+					<span data-testid="productTileTitle">Selftest Widget Pro, 2-pack</span>
+				</a>
+				<span data-testid="price">$19.98</span>
+			</div>
+		</body></html>`,
+	},
+	config.ScraperMercadoLibre: {
+		Query: "selftest widget", Country: "BR", Host: "lista.mercadolivre.com.br",
+		HTML: `<html><body>
+			<li class="ui-search-layout__item"><div class="poly-card">
+				<img data-src="https://http2.mlstatic.com/D_selftest.jpg">
+				<a class="poly-component__title" href="https://produto.mercadolivre.com.br/MLB-1000000001-selftest-widget-_JM#polycard_client=search">Selftest Widget Pro</a>
+				<span class="poly-reviews__rating">4.8</span> <span class="poly-reviews__total">(1.234)</span>
+				<div class="poly-price__current">
+					<span class="andes-money-amount"><span class="andes-money-amount__currency-symbol">R$</span><span class="andes-money-amount__fraction">1.299</span><span class="andes-money-amount__cents">90</span></span>
+				</div>
+			</div></li>
+		</body></html>`,
+	},
+}
+
+// StaticTransport answers every request with the same HTML page.
+type StaticTransport struct {
+	HTML string
+}
+
+func (t StaticTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Header:     http.Header{"Content-Type": []string{"text/html; charset=utf-8"}},
+		Body:       io.NopCloser(strings.NewReader(t.HTML)),
+		Request:    req,
+	}, nil
+}
@@ -1,157 +1,178 @@
 package scrapers
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"net/http"
 	"regexp"
 	"strings"
 	"time"
 
 	"github.com/gocolly/colly/v2"
 	"github.com/gocolly/colly/v2/debug"
+	"price-comparison-api/internal/config"
 	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/productid"
+	"price-comparison-api/pkg/politeness"
+	"price-comparison-api/pkg/retry"
 )
 
 type FlipkartScraper struct {
-	collector *colly.Collector
+	cfg       config.ScraperConfig
+	transport http.RoundTripper
+	ctx       context.Context
+	base      collectorBase
 }
 
-func NewFlipkartScraper() *FlipkartScraper {
-	c := colly.NewCollector(
-		colly.AllowedDomains("flipkart.com", "www.flipkart.com"),
-		colly.Debugger(&debug.LogDebugger{}),
-	)
+func NewFlipkartScraper(cfg config.ScraperConfig) *FlipkartScraper {
+	return &FlipkartScraper{cfg: cfg}
+}
+
+// SetTransport replaces the HTTP transport used by the collector.
+func (f *FlipkartScraper) SetTransport(transport http.RoundTripper) {
+	f.transport = transport
+	f.base.reset()
+}
+
+// SetContext bounds every page fetch by ctx.
+func (f *FlipkartScraper) SetContext(ctx context.Context) {
+	f.ctx = ctx
+	f.base.reset()
+}
+
+func (f *FlipkartScraper) newCollector() *colly.Collector {
+	c := f.base.clone(func() *colly.Collector {
+		c := colly.NewCollector(
+			colly.AllowedDomains("flipkart.com", "www.flipkart.com"),
+			colly.Debugger(&debug.LogDebugger{}),
+			colly.AllowURLRevisit(),
+		)
+		politeness.Limit(c, config.ScraperFlipkart, f.cfg)
+		if f.transport != nil {
+			c.WithTransport(f.transport)
+		}
+		if f.ctx != nil {
+			c.Context = f.ctx
+		}
+		return c
+	})
+	fingerprintPages(c, config.ScraperFlipkart, PageSearch)
+	detectBlocks(c, config.ScraperFlipkart)
+	timePhases(c)
 
 	c.OnRequest(func(r *colly.Request) {
-		r.Headers.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
+		r.Headers.Set("User-Agent", f.cfg.UserAgent)
 		r.Headers.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8")
 		r.Headers.Set("Accept-Language", "en-US,en;q=0.5")
 		r.Headers.Set("Referer", "https://www.flipkart.com/")
 		r.Headers.Set("Cache-Control", "no-cache")
 	})
 
-	c.Limit(&colly.LimitRule{
-		DomainGlob:  "*flipkart.*",
-		Parallelism: 1,
-		Delay:       5 * time.Second,
-	})
+	retry.Attach(c)
 
-	return &FlipkartScraper{collector: c}
+	return c
 }
 
-func (f *FlipkartScraper) Search(query string, country string) ([]models.Product, error) {
-	// Always return empty slice instead of nil
-	products := make([]models.Product, 0)
+func (f *FlipkartScraper) Search(ctx context.Context, query string, country string) ([]models.Product, error) {
+	logger := scraperLogger(ctx, config.ScraperFlipkart, query, country)
 
 	if strings.ToUpper(country) != "IN" {
-		log.Printf("Flipkart: Country %s not supported, returning empty results", country)
-		return products, nil // Flipkart only works in India
+		logger.Warn().Msgf("Flipkart: Country %s not supported, returning empty results", country)
+		// Always return empty slice instead of nil
+		return make([]models.Product, 0), nil // Flipkart only works in India
 	}
 
 	searchURL := f.getSearchURL(query)
-	log.Printf("Searching Flipkart (IN) with URL: %s", searchURL)
-
-	selectors := []string{
-		"[data-id]",
-		"._1AtVbE",
-		"._13oc-S",
-	}
-
-	foundAny := false
-
-	f.collector.OnResponse(func(r *colly.Response) {
-		log.Printf("Flipkart Response status: %d", r.StatusCode)
-	})
-
-	for _, selector := range selectors {
-		f.collector.OnHTML(selector, func(e *colly.HTMLElement) {
-			foundAny = true
-
-			product := models.Product{
-				Source:    "Flipkart",
-				Currency:  "INR",
-				ScrapedAt: time.Now(),
-				InStock:   true,
-			}
-
-			// Extract name with multiple selectors
-			nameSelectors := []string{"._4rR01T", ".s1Q9rs", "._2WkVRV"}
-			for _, nameSelector := range nameSelectors {
-				name := strings.TrimSpace(e.ChildText(nameSelector))
-				if name == "" {
-					continue
-				}
-				if name != "" && len(name) > 5 {
-					product.Name = name
-					break
-				}
-			}
-
-			if product.Name == "" {
-				return
+	logger.Info().Msgf("Searching Flipkart (IN) with URL: %s", searchURL)
+
+	sel := siteSelectors(config.ScraperFlipkart)
+	search := cardSearch{
+		url:          searchURL,
+		cards:        sel.Cards,
+		newCollector: f.newCollector,
+		newStructured: func() *structuredResults {
+			return newStructuredResults(ctx, config.ScraperFlipkart, "IN", "INR", "Flipkart")
+		},
+		parse: func(e *colly.HTMLElement) (models.Product, bool) {
+			product, ok := f.parseCard(e, sel)
+			if ok {
+				logger.Debug().Msgf("Found Flipkart product: %s - %s", product.Name, product.Price)
 			}
+			return product, ok
+		},
+		onResponse: func(r *colly.Response) {
+			logger.Debug().Msgf("Flipkart Response status: %d", r.StatusCode)
+		},
+		logger: logger,
+	}
+	attempt, failed := search.run(ctx)
+	if isBlocked(attempt.err) {
+		return attempt.products, attempt.err
+	}
+	products := attempt.results()
 
-			product.Price = f.extractPrice(e)
-			product.URL = f.extractURL(e)
+	if len(products) == 0 && failed == len(sel.Cards) {
+		logger.Warn().Msgf("Flipkart: No products found and all selectors failed for query: %s", query)
+		return products, fmt.Errorf("all Flipkart scraping attempts failed")
+	}
 
-			// Extract image
-			for _, imgSelector := range []string{"._396cs4", "._2r_T1I"} {
-				image := e.ChildAttr(imgSelector, "src")
-				if image != "" {
-					product.Image = image
-					break
-				}
-			}
+	if len(products) == 0 {
+		logger.Info().Msgf("No Flipkart products found for query: %s", query)
+	}
 
-			if product.Price != "" {
-				product.ID = fmt.Sprintf("flipkart_%d", time.Now().UnixNano())
-				products = append(products, product)
-				log.Printf("Found Flipkart product: %s - %s", product.Name, product.Price)
-			}
-		})
+	logger.Info().Msgf("Flipkart found %d products", len(products))
+	return products, nil
+}
 
-		err := f.collector.Visit(searchURL)
-		if err != nil {
-			log.Printf("Error visiting Flipkart: %v", err)
-		}
+func (f *FlipkartScraper) parseCard(e *colly.HTMLElement, sel SiteSelectors) (models.Product, bool) {
+	product := models.Product{
+		Source:    "Flipkart",
+		Currency:  "INR",
+		ScrapedAt: time.Now(),
+		InStock:   true,
+	}
+	applyRelease(&product, e.Text)
 
-		if foundAny {
+	// Extract name with multiple selectors
+	for _, nameSelector := range sel.Name {
+		name := strings.TrimSpace(e.ChildText(nameSelector))
+		if name != "" && len(name) > 5 {
+			product.Name = name
 			break
 		}
 	}
 
-	if !foundAny {
-		log.Printf("No Flipkart products found for query: %s", query)
+	if product.Name == "" {
+		return product, false
 	}
 
-	log.Printf("Flipkart found %d products", len(products))
-	return products, nil
+	product.Price = f.extractPrice(e, sel)
+	product.URL = f.extractURL(e, sel)
+	product.Image = childAttr(e, sel.Image, "src")
+
+	if product.Price == "" {
+		return product, false
+	}
+	applyDeal(&product, e, config.ScraperFlipkart)
+	applyCondition(&product, e, config.ScraperFlipkart)
+	applyCategory(&product, e, config.ScraperFlipkart)
+	product.ID, product.SourceProductID = productid.For(config.ScraperFlipkart, "IN", product.URL, product.Name)
+	return product, true
 }
 
 func (f *FlipkartScraper) getSearchURL(query string) string {
 	return fmt.Sprintf("https://www.flipkart.com/search?q=%s", strings.ReplaceAll(query, " ", "%20"))
 }
 
-func (f *FlipkartScraper) extractPrice(element *colly.HTMLElement) string {
-	priceSelectors := []string{
-		"._30jeq3",
-		"._16Jk6d",
-		"._1_WHN1",
-		".s1Q9rs",
+func (f *FlipkartScraper) extractPrice(element *colly.HTMLElement, sel SiteSelectors) string {
+	if price := childText(element, sel.Price); price != "" {
+		return f.formatPrice(price)
 	}
-
-	for _, selector := range priceSelectors {
-		price := strings.TrimSpace(element.ChildText(selector))
-		if price != "" {
-			return f.formatPrice(price)
-		}
-	}
-
 	return ""
 }
 
-func (f *FlipkartScraper) extractURL(element *colly.HTMLElement) string {
-	relativeURL := element.ChildAttr("a", "href")
+func (f *FlipkartScraper) extractURL(element *colly.HTMLElement, sel SiteSelectors) string {
+	relativeURL := childAttr(element, sel.URL, "href")
 	if relativeURL != "" && !strings.HasPrefix(relativeURL, "http") {
 		return "https://www.flipkart.com" + relativeURL
 	}
@@ -179,9 +200,5 @@ func (f *FlipkartScraper) cleanFlipkartProductName(name string) string {
 		}
 	}
 
-	cleanName := strings.TrimSpace(name)
-	if len(cleanName) > 80 {
-		cleanName = cleanName[:80] + "..."
-	}
-	return cleanName
+	return strings.TrimSpace(name)
 }
@@ -0,0 +1,331 @@
+package scrapers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly/v2"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/productid"
+	"price-comparison-api/internal/scrapetrace"
+	"price-comparison-api/pkg/politeness"
+	"price-comparison-api/pkg/retry"
+	"price-comparison-api/pkg/utils"
+)
+
+// GoogleShoppingScraper searches Google Shopping, which lists the same
+// product at many merchants. Listings are grouped by title so each product
+// comes back once with every merchant offer, priced at the cheapest one.
+//
+// When the plain HTML page has no results (Google often serves a consent or
+// script-only page to non-browsers) and a renderer is set, the regular search
+// page is loaded in Chrome and its shopping carousel is read instead.
+type GoogleShoppingScraper struct {
+	cfg       config.ScraperConfig
+	transport http.RoundTripper
+	ctx       context.Context
+	base      collectorBase
+	render    RenderFunc
+}
+
+// googleDomains maps countries to their Google domain; others use google.com
+// with the gl parameter.
+var googleDomains = map[string]string{
+	"US": "www.google.com", "UK": "www.google.co.uk", "IN": "www.google.co.in",
+	"DE": "www.google.de", "CA": "www.google.ca", "AU": "www.google.com.au",
+	"FR": "www.google.fr", "IT": "www.google.it", "ES": "www.google.es",
+	"JP": "www.google.co.jp", "MX": "www.google.com.mx", "BR": "www.google.com.br",
+	"AR": "www.google.com.ar",
+}
+
+var googleCurrencies = map[string]string{
+	"US": "USD", "CA": "CAD", "IN": "INR", "UK": "GBP", "DE": "EUR",
+	"FR": "EUR", "IT": "EUR", "ES": "EUR", "AU": "AUD", "JP": "JPY",
+	"MX": "MXN", "BR": "BRL", "AR": "ARS",
+}
+
+// "Best Buy & more", "Walmart + 3 more"
+var moreMerchants = regexp.MustCompile(`\s*(?:&|\+)\s*(?:\d+\s*)?more$`)
+
+func NewGoogleShoppingScraper(cfg config.ScraperConfig) *GoogleShoppingScraper {
+	return &GoogleShoppingScraper{cfg: cfg}
+}
+
+// SetTransport replaces the HTTP transport used by the collector.
+func (g *GoogleShoppingScraper) SetTransport(transport http.RoundTripper) {
+	g.transport = transport
+	g.base.reset()
+}
+
+// SetContext bounds every page fetch by ctx.
+func (g *GoogleShoppingScraper) SetContext(ctx context.Context) {
+	g.ctx = ctx
+	g.base.reset()
+}
+
+// SetRenderer enables the browser fallback.
+func (g *GoogleShoppingScraper) SetRenderer(render RenderFunc) {
+	g.render = render
+}
+
+func (g *GoogleShoppingScraper) newCollector() *colly.Collector {
+	c := g.base.clone(func() *colly.Collector {
+		c := colly.NewCollector(
+			colly.AllowedDomains(googleHost(""), googleHost("UK"), googleHost("IN"), googleHost("DE"),
+				googleHost("CA"), googleHost("AU"), googleHost("FR"), googleHost("IT"), googleHost("ES"), googleHost("JP"),
+				googleHost("MX"), googleHost("BR"), googleHost("AR")),
+			colly.AllowURLRevisit(),
+		)
+		politeness.Limit(c, config.ScraperGoogleShopping, g.cfg)
+		if g.transport != nil {
+			c.WithTransport(g.transport)
+		}
+		if g.ctx != nil {
+			c.Context = g.ctx
+		}
+		return c
+	})
+	fingerprintPages(c, config.ScraperGoogleShopping, PageSearch)
+	detectBlocks(c, config.ScraperGoogleShopping)
+	timePhases(c)
+
+	c.OnRequest(func(r *colly.Request) {
+		r.Headers.Set("User-Agent", g.cfg.UserAgent)
+		r.Headers.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+		r.Headers.Set("Accept-Language", "en-US,en;q=0.5")
+	})
+
+	retry.Attach(c)
+
+	return c
+}
+
+func (g *GoogleShoppingScraper) Search(ctx context.Context, query, country string) ([]models.Product, error) {
+	logger := scraperLogger(ctx, config.ScraperGoogleShopping, query, country)
+	country = strings.ToUpper(country)
+	sel := siteSelectors(config.ScraperGoogleShopping)
+	offers := newOfferGroups(sel, country, googleCurrency(country))
+
+	searchURL := g.searchURL(query, country, true)
+	logger.Info().Msgf("Searching Google Shopping (%s) with URL: %s", country, searchURL)
+
+	structured := newStructuredResults(ctx, config.ScraperGoogleShopping, country, googleCurrency(country), fmt.Sprintf("Google Shopping %s", country))
+	collector := g.newCollector()
+	structured.attach(collector)
+	collector.OnResponse(func(r *colly.Response) {
+		logger.Debug().Msgf("Google Shopping (%s) Response status: %d", country, r.StatusCode)
+	})
+	collector.OnHTML(sel.card(), func(e *colly.HTMLElement) {
+		scrapetrace.RecordHit(ctx, sel.card())
+		offers.add(e)
+	})
+
+	visitErr := visit(ctx, collector, searchURL)
+	if visitErr != nil {
+		logger.Warn().Err(visitErr).Msgf("Error visiting Google Shopping %s", country)
+	}
+
+	if offers.empty() && !structured.found() && g.render != nil {
+		serpURL := g.searchURL(query, country, false)
+		logger.Info().Msgf("No Google Shopping (%s) results in HTML, rendering %s", country, serpURL)
+		html, err := renderPage(ctx, config.ScraperGoogleShopping, g.render, serpURL)
+		if err != nil {
+			logger.Warn().Err(err).Msg("Google Shopping render failed")
+			if isBlocked(err) && !isBlocked(visitErr) {
+				visitErr = err
+			}
+		} else if doc, err := goquery.NewDocumentFromReader(strings.NewReader(html)); err == nil {
+			structured.readRendered(doc, serpURL)
+			doc.Find(sel.card()).Each(func(i int, s *goquery.Selection) {
+				scrapetrace.RecordHit(ctx, sel.card())
+				offers.add(colly.NewHTMLElementFromSelectionNode(&colly.Response{}, s, s.Nodes[0], i))
+			})
+		}
+	}
+
+	products := structured.merge(offers.products())
+	if len(products) == 0 && visitErr != nil {
+		return products, fmt.Errorf("google shopping: %w", visitErr)
+	}
+
+	logger.Info().Msgf("Google Shopping %s found %d products", country, len(products))
+	return products, nil
+}
+
+// searchURL builds the Shopping tab URL, or the regular results page whose
+// carousel the browser fallback reads.
+func (g *GoogleShoppingScraper) searchURL(query, country string, shopping bool) string {
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("gl", strings.ToLower(googleCountryCode(country)))
+	params.Set("hl", "en")
+	if shopping {
+		params.Set("tbm", "shop")
+	}
+	return fmt.Sprintf("https://%s/search?%s", googleHost(country), params.Encode())
+}
+
+func googleHost(country string) string {
+	if host, ok := googleDomains[strings.ToUpper(country)]; ok {
+		return host
+	}
+	return "www.google.com"
+}
+
+func googleCurrency(country string) string {
+	if currency, ok := googleCurrencies[strings.ToUpper(country)]; ok {
+		return currency
+	}
+	return "USD"
+}
+
+// googleCountryCode maps the service's UK to Google's ISO code.
+func googleCountryCode(country string) string {
+	if country == "UK" {
+		return "GB"
+	}
+	return country
+}
+
+// offerGroups collects result cards into one product per title.
+type offerGroups struct {
+	sel      SiteSelectors
+	country  string
+	currency string
+	order    []string
+	byTitle  map[string]*models.Product
+}
+
+func newOfferGroups(sel SiteSelectors, country, currency string) *offerGroups {
+	return &offerGroups{sel: sel, country: country, currency: currency, byTitle: make(map[string]*models.Product)}
+}
+
+func (o *offerGroups) empty() bool {
+	return len(o.order) == 0
+}
+
+// add reads one result card and files its offer under the card's title.
+func (o *offerGroups) add(e *colly.HTMLElement) {
+	name := firstText(e, o.sel.Name)
+	if len(name) <= 5 {
+		return
+	}
+	price := firstText(e, o.sel.Price)
+	if m := priceAmount.FindString(price); m != "" {
+		price = m
+	} else if price = priceAmount.FindString(e.Text); price == "" {
+		return
+	}
+	value := parseOfferPrice(price)
+	if value <= 0 {
+		return
+	}
+
+	merchant := moreMerchants.ReplaceAllString(firstText(e, o.sel.Merchant), "")
+	if merchant == "" {
+		merchant = "Unknown merchant"
+	}
+	offer := models.Offer{
+		Merchant:   merchant,
+		Price:      price,
+		PriceValue: value,
+		URL:        googleLink(childAttr(e, o.sel.URL, "href"), o.country),
+	}
+
+	key := strings.ToLower(strings.Join(strings.Fields(name), " "))
+	product, ok := o.byTitle[key]
+	if !ok {
+		product = &models.Product{
+			Name:      name,
+			Currency:  o.currency,
+			Source:    fmt.Sprintf("Google Shopping %s", o.country),
+			ScrapedAt: time.Now(),
+			InStock:   true,
+			Image:     googleImage(e, o.sel.Image),
+			Rating:    childAttr(e, o.sel.Rating, "aria-label"),
+		}
+		applyRelease(product, e.Text)
+		o.byTitle[key] = product
+		o.order = append(o.order, key)
+	}
+	for _, existing := range product.Offers {
+		if existing.Merchant == offer.Merchant && existing.PriceValue == offer.PriceValue {
+			return
+		}
+	}
+	product.Offers = append(product.Offers, offer)
+
+	// The product itself carries its cheapest offer
+	if product.Price == "" || value < parseOfferPrice(product.Price) {
+		product.Price = price
+		product.Merchant = merchant
+		product.URL = offer.URL
+		product.OriginalPrice, product.Discount, product.DealBadge = "", 0, ""
+		applyDeal(product, e, config.ScraperGoogleShopping)
+		applyCondition(product, e, config.ScraperGoogleShopping)
+		applyCategory(product, e, config.ScraperGoogleShopping)
+	}
+}
+
+func (o *offerGroups) products() []models.Product {
+	products := make([]models.Product, 0, len(o.order))
+	for _, key := range o.order {
+		product := *o.byTitle[key]
+		product.ID, product.SourceProductID = productid.For(config.ScraperGoogleShopping, o.country, product.URL, product.Name)
+		products = append(products, product)
+	}
+	return products
+}
+
+// decimalComma matches European amounts like "1.299,00" or "89,00".
+var decimalComma = regexp.MustCompile(`,\d{2}$`)
+
+// parseOfferPrice reads an offer price. Google localizes prices, so unlike
+// the retailer scrapers it also sees decimal commas.
+func parseOfferPrice(price string) float64 {
+	amount := strings.TrimSpace(strings.Trim(price, "$£€₹¥ \u00a0"))
+	if decimalComma.MatchString(amount) {
+		amount = strings.ReplaceAll(amount, ".", "")
+		amount = strings.Replace(amount, ",", ".", 1)
+	}
+	return utils.ParsePrice(amount)
+}
+
+// googleLink unwraps Google's /url redirects and makes relative product
+// links absolute.
+func googleLink(href, country string) string {
+	if href == "" {
+		return ""
+	}
+	u, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	if u.Path == "/url" {
+		for _, param := range []string{"url", "q", "adurl"} {
+			if target := u.Query().Get(param); strings.HasPrefix(target, "http") {
+				return target
+			}
+		}
+	}
+	if u.IsAbs() {
+		return href
+	}
+	return "https://" + googleHost(country) + u.String()
+}
+
+func googleImage(e *colly.HTMLElement, selectors []string) string {
+	for _, attr := range []string{"data-src", "src"} {
+		if src := childAttr(e, selectors, attr); strings.HasPrefix(src, "http") {
+			return src
+		}
+	}
+	return ""
+}
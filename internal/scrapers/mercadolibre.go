@@ -0,0 +1,261 @@
+package scrapers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly/v2"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/productid"
+	"price-comparison-api/internal/scrapetrace"
+	"price-comparison-api/pkg/politeness"
+	"price-comparison-api/pkg/retry"
+	"price-comparison-api/pkg/utils"
+)
+
+// MercadoLibreScraper searches Mercado Libre in Mexico, Argentina and, as
+// Mercado Livre, Brazil. Each country has its own domain and prices are in
+// the local currency.
+type MercadoLibreScraper struct {
+	cfg       config.ScraperConfig
+	transport http.RoundTripper
+	ctx       context.Context
+	base      collectorBase
+}
+
+// mercadoLibreRegions maps supported countries to their search host, the
+// currency prices are listed in with its symbol, and the Accept-Language
+// sent.
+var mercadoLibreRegions = map[string]struct {
+	Host     string
+	Currency string
+	Symbol   string
+	Language string
+}{
+	"MX": {"listado.mercadolibre.com.mx", "MXN", "MX$", "es-MX,es;q=0.9"},
+	"BR": {"lista.mercadolivre.com.br", "BRL", "R$", "pt-BR,pt;q=0.9"},
+	"AR": {"listado.mercadolibre.com.ar", "ARS", "AR$", "es-AR,es;q=0.9"},
+}
+
+// MercadoLibreCovers reports whether Mercado Libre has a site for country.
+func MercadoLibreCovers(country string) bool {
+	_, ok := mercadoLibreRegions[strings.ToUpper(country)]
+	return ok
+}
+
+// mercadoLibreListPriceSelector matches the struck-through amount
+const mercadoLibreListPriceSelector = ".andes-money-amount--previous"
+
+func NewMercadoLibreScraper(cfg config.ScraperConfig) *MercadoLibreScraper {
+	return &MercadoLibreScraper{cfg: cfg}
+}
+
+// SetTransport replaces the HTTP transport used by the collector.
+func (m *MercadoLibreScraper) SetTransport(transport http.RoundTripper) {
+	m.transport = transport
+	m.base.reset()
+}
+
+// SetContext bounds every page fetch by ctx.
+func (m *MercadoLibreScraper) SetContext(ctx context.Context) {
+	m.ctx = ctx
+	m.base.reset()
+}
+
+func (m *MercadoLibreScraper) newCollector(language string) *colly.Collector {
+	c := m.base.clone(func() *colly.Collector {
+		hosts := make([]string, 0, len(mercadoLibreRegions))
+		for _, region := range mercadoLibreRegions {
+			hosts = append(hosts, region.Host)
+		}
+		c := colly.NewCollector(
+			colly.AllowedDomains(hosts...),
+			colly.AllowURLRevisit(),
+		)
+		politeness.Limit(c, config.ScraperMercadoLibre, m.cfg)
+		if m.transport != nil {
+			c.WithTransport(m.transport)
+		}
+		if m.ctx != nil {
+			c.Context = m.ctx
+		}
+		return c
+	})
+	fingerprintPages(c, config.ScraperMercadoLibre, PageSearch)
+	detectBlocks(c, config.ScraperMercadoLibre)
+	timePhases(c)
+
+	c.OnRequest(func(r *colly.Request) {
+		r.Headers.Set("User-Agent", m.cfg.UserAgent)
+		r.Headers.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+		r.Headers.Set("Accept-Language", language)
+	})
+
+	retry.Attach(c)
+
+	return c
+}
+
+func (m *MercadoLibreScraper) Search(ctx context.Context, query, country string) ([]models.Product, error) {
+	logger := scraperLogger(ctx, config.ScraperMercadoLibre, query, country)
+	products := make([]models.Product, 0)
+	country = strings.ToUpper(country)
+	region, ok := mercadoLibreRegions[country]
+	if !ok {
+		logger.Info().Msgf("Mercado Libre does not cover %s", country)
+		return products, nil
+	}
+
+	searchURL := m.getSearchURL(query, country)
+	logger.Info().Msgf("Searching Mercado Libre (%s) with URL: %s", country, searchURL)
+
+	seen := make(map[string]bool)
+	sel := siteSelectors(config.ScraperMercadoLibre)
+	collector := m.newCollector(region.Language)
+	structured := newStructuredResults(ctx, config.ScraperMercadoLibre, country, region.Currency, fmt.Sprintf("Mercado Libre %s", country))
+	structured.attach(collector)
+	collector.OnResponse(func(r *colly.Response) {
+		logger.Debug().Msgf("Mercado Libre (%s) Response status: %d", country, r.StatusCode)
+	})
+	collector.OnHTML(sel.card(), func(e *colly.HTMLElement) {
+		scrapetrace.RecordHit(ctx, sel.card())
+		product, ok := m.parseCard(e, sel, country)
+		if !ok || seen[product.URL] {
+			return
+		}
+		seen[product.URL] = true
+		products = append(products, product)
+		logger.Debug().Msgf("Found Mercado Libre (%s) product: %s - %s", country, product.Name, product.Price)
+	})
+
+	if err := visit(ctx, collector, searchURL); err != nil {
+		logger.Warn().Err(err).Msgf("Error visiting Mercado Libre %s", country)
+		return products, fmt.Errorf("mercadolibre: %w", err)
+	}
+	products = structured.merge(products)
+
+	logger.Info().Msgf("Mercado Libre %s found %d products", country, len(products))
+	return products, nil
+}
+
+// getSearchURL builds the listing URL, which takes the query as a
+// dash-separated path: listado.mercadolibre.com.mx/iphone-15
+func (m *MercadoLibreScraper) getSearchURL(query, country string) string {
+	slug := strings.Join(strings.Fields(strings.ToLower(query)), "-")
+	return fmt.Sprintf("https://%s/%s", mercadoLibreRegions[country].Host, url.PathEscape(slug))
+}
+
+func (m *MercadoLibreScraper) parseCard(e *colly.HTMLElement, sel SiteSelectors, country string) (models.Product, bool) {
+	region := mercadoLibreRegions[country]
+	product := models.Product{
+		Source:    fmt.Sprintf("Mercado Libre %s", country),
+		Currency:  region.Currency,
+		ScrapedAt: time.Now(),
+		InStock:   true,
+	}
+
+	product.Name = firstText(e, sel.Name)
+	if len(product.Name) <= 5 {
+		return product, false
+	}
+
+	for _, selector := range sel.Price {
+		if product.Price = mercadoLibrePrice(e.DOM.Find(selector).First(), region.Symbol); product.Price != "" {
+			break
+		}
+	}
+	if product.Price == "" {
+		return product, false
+	}
+
+	href := childAttr(e, sel.URL, "href")
+	product.URL = mercadoLibreURL(e.Request.AbsoluteURL(href))
+
+	for _, attr := range []string{"data-src", "src"} {
+		if src := childAttr(e, sel.Image, attr); src != "" && !strings.HasPrefix(src, "data:") {
+			product.Image = src
+			break
+		}
+	}
+
+	if rating := firstText(e, sel.Rating); rating != "" {
+		product.Rating = rating + "/5"
+	}
+	// "(1.234)" in the local number format
+	product.Reviews = strings.Trim(firstText(e, sel.Reviews), "() ")
+
+	applyDeal(&product, e, config.ScraperMercadoLibre)
+	applyCondition(&product, e, config.ScraperMercadoLibre)
+	applySeller(&product, e, config.ScraperMercadoLibre)
+
+	applyCategory(&product, e, config.ScraperMercadoLibre)
+	// The struck-through price uses the local thousands separator, which the
+	// generic list price selectors misread, so it is read here instead
+	if list := mercadoLibrePrice(e.DOM.Find(mercadoLibreListPriceSelector).First(), region.Symbol); list != "" {
+		price, listValue := utils.ParsePrice(product.Price), utils.ParsePrice(list)
+		if listValue > price {
+			product.OriginalPrice = list
+			if product.Discount == 0 {
+				product.Discount = math.Round((listValue-price)/listValue*1000) / 10
+			}
+		} else {
+			product.OriginalPrice = ""
+		}
+	}
+
+	product.ID, product.SourceProductID = productid.For(config.ScraperMercadoLibre, country, product.URL, product.Name)
+	return product, true
+}
+
+// mercadoLibrePrice reads an andes-money-amount block. The whole part uses
+// the country's thousands separator ("1.299" in Brazil, "1,299" in Mexico)
+// and the cents are a separate element, so the amount is rebuilt as
+// "R$1,299.90" for the price parser.
+func mercadoLibrePrice(amount *goquery.Selection, symbol string) string {
+	if amount.Length() == 0 {
+		return ""
+	}
+	whole := digitsOnly(amount.Find(".andes-money-amount__fraction").First().Text())
+	if whole == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(symbol)
+	for i, r := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(r)
+	}
+	if cents := digitsOnly(amount.Find(".andes-money-amount__cents").First().Text()); cents != "" {
+		b.WriteString("." + cents)
+	}
+	return b.String()
+}
+
+func digitsOnly(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// mercadoLibreURL drops the tracking query and fragment from listing links.
+func mercadoLibreURL(href string) string {
+	u, err := url.Parse(href)
+	if err != nil || u.Host == "" {
+		return href
+	}
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
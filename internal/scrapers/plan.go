@@ -0,0 +1,70 @@
+package scrapers
+
+import (
+	"strings"
+
+	"price-comparison-api/internal/config"
+)
+
+// Ways a page is fetched.
+const (
+	ModeColly  = "colly"
+	ModeChrome = "chrome"
+)
+
+// Request is one page a scraper fetches for a search.
+type Request struct {
+	URL  string `json:"url"`
+	Mode string `json:"mode"`
+	// Fallback marks a page only fetched when the ones before it found
+	// nothing
+	Fallback bool `json:"fallback,omitempty"`
+}
+
+// PlanRequests returns the pages the scraper registered under name fetches
+// for query in country, in order, without fetching them. rendered tells
+// whether the Chrome renderer is set for the scrapers that use it.
+func PlanRequests(name, query, country string, rendered bool) []Request {
+	country = strings.ToUpper(country)
+	colly := func(u string) []Request { return []Request{{URL: u, Mode: ModeColly}} }
+
+	switch name {
+	case config.ScraperAmazon:
+		return colly((&AmazonScraper{}).getSearchURL(query, country))
+	case config.ScraperEbay:
+		return colly((&EbayScraper{}).getSearchURL(query, country))
+	case config.ScraperFlipkart:
+		return colly((&FlipkartScraper{}).getSearchURL(query))
+	case config.ScraperWalmart:
+		return colly((&WalmartScraper{}).getSearchURL(query))
+	case config.ScraperTarget:
+		return colly((&TargetScraper{}).getSearchURL(query))
+	case config.ScraperBestBuy:
+		return colly((&BestBuyScraper{}).getSearchURL(query))
+	case config.ScraperCostco:
+		return colly((&CostcoScraper{}).getSearchURL(query))
+	case config.ScraperSamsClub:
+		return colly((&SamsClubScraper{}).getSearchURL(query))
+	case config.ScraperEtsy:
+		return colly((&EtsyScraper{}).getSearchURL(query, country))
+	case config.ScraperMercadoLibre:
+		if !MercadoLibreCovers(country) {
+			return nil
+		}
+		return colly((&MercadoLibreScraper{}).getSearchURL(query, country))
+	case config.ScraperGoogleShopping:
+		g := &GoogleShoppingScraper{}
+		requests := colly(g.searchURL(query, country, true))
+		if rendered {
+			requests = append(requests, Request{URL: g.searchURL(query, country, false), Mode: ModeChrome, Fallback: true})
+		}
+		return requests
+	case config.ScraperAliExpress:
+		searchURL := (&AliExpressScraper{}).getSearchURL(query, country, aliExpressCurrency(country))
+		if rendered {
+			return []Request{{URL: searchURL, Mode: ModeChrome}, {URL: searchURL, Mode: ModeColly, Fallback: true}}
+		}
+		return colly(searchURL)
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+package scrapers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// recordingIndex is the file in a recording's directory listing its
+// responses.
+const recordingIndex = "responses.json"
+
+// RecordedResponse is one response a scraper received. Its body is kept
+// in File, next to the index, so recorded pages can be read and diffed.
+type RecordedResponse struct {
+	Method      string `json:"method"`
+	URL         string `json:"url"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type,omitempty"`
+	File        string `json:"file"`
+}
+
+// Recording is the responses of a scraper's searches, in the order they
+// were fetched.
+type Recording struct {
+	Responses []RecordedResponse `json:"responses"`
+}
+
+// ReadRecording reads the recording saved in dir.
+func ReadRecording(dir string) (Recording, error) {
+	var rec Recording
+	data, err := os.ReadFile(filepath.Join(dir, recordingIndex))
+	if err != nil {
+		return rec, err
+	}
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return rec, fmt.Errorf("%s: %v", filepath.Join(dir, recordingIndex), err)
+	}
+	return rec, nil
+}
+
+// RecordingTransport fetches pages through Base and saves every response
+// to Dir, for a ReplayTransport to serve later. Bodies are saved
+// decompressed, and HTML pages sanitized.
+type RecordingTransport struct {
+	Base http.RoundTripper
+	Dir  string
+
+	mu  sync.Mutex
+	rec Recording
+}
+
+// NewRecordingTransport records the responses base returns into dir,
+// replacing what dir held. A nil base uses http.DefaultTransport.
+func NewRecordingTransport(base http.RoundTripper, dir string) (*RecordingTransport, error) {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &RecordingTransport{Base: base, Dir: dir}, nil
+}
+
+func (t *RecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.Base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := readBody(resp)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(resp.Header.Get("Content-Type"), "html") {
+		body = SanitizePage(body)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	r := RecordedResponse{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		Status:      resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		File:        fmt.Sprintf("page-%02d.html", len(t.rec.Responses)+1),
+	}
+	if err := os.WriteFile(filepath.Join(t.Dir, r.File), body, 0o644); err != nil {
+		return nil, err
+	}
+	t.rec.Responses = append(t.rec.Responses, r)
+	if err := t.save(); err != nil {
+		return nil, err
+	}
+
+	resp.Header = resp.Header.Clone()
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = int64(len(body))
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// save writes the index; t.mu is held.
+func (t *RecordingTransport) save() error {
+	data, err := json.MarshalIndent(t.rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(t.Dir, recordingIndex), append(data, '\n'), 0o644)
+}
+
+var (
+	scriptElement = regexp.MustCompile(`(?is)<script\b([^>]*)>.*?</script\s*>`)
+	// dataScript is the type of the scripts scrapers read, JSON-LD among them
+	dataScript  = regexp.MustCompile(`(?i)\btype\s*=\s*["']?application/(ld\+)?json\b`)
+	htmlComment = regexp.MustCompile(`(?s)<!--.*?-->`)
+	hiddenInput = regexp.MustCompile(`(?i)<input\b[^>]*\btype\s*=\s*["']?hidden\b[^>]*>`)
+	inputValue  = regexp.MustCompile(`(?i)\bvalue\s*=\s*("[^"]*"|'[^']*'|[^\s>]+)`)
+)
+
+// SanitizePage strips what a recorded page doesn't need and may tie to the
+// session that fetched it: scripts other than JSON data, comments, and the
+// values of hidden form fields such as CSRF tokens. It also keeps
+// recordings small.
+func SanitizePage(page []byte) []byte {
+	page = scriptElement.ReplaceAllFunc(page, func(script []byte) []byte {
+		attrs := scriptElement.FindSubmatch(script)[1]
+		if dataScript.Match(attrs) {
+			return script
+		}
+		return nil
+	})
+	page = htmlComment.ReplaceAll(page, nil)
+	return hiddenInput.ReplaceAllFunc(page, func(input []byte) []byte {
+		return inputValue.ReplaceAll(input, []byte(`value=""`))
+	})
+}
+
+// readBody reads and closes a response body, gunzipping it when the
+// server compressed it for a client that asked.
+func readBody(resp *http.Response) ([]byte, error) {
+	defer resp.Body.Close()
+	var r io.Reader = resp.Body
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	return io.ReadAll(r)
+}
+
+// ReplayTransport serves a recording instead of fetching pages. A URL
+// fetched several times gets its responses in the order they were
+// recorded, and the last one again after that. Requests the recording
+// doesn't hold fail, and are listed by Unmatched.
+type ReplayTransport struct {
+	dir string
+
+	mu        sync.Mutex
+	responses map[string][]RecordedResponse
+	unmatched []string
+}
+
+// NewReplayTransport serves the recording saved in dir.
+func NewReplayTransport(dir string) (*ReplayTransport, error) {
+	rec, err := ReadRecording(dir)
+	if err != nil {
+		return nil, err
+	}
+	t := &ReplayTransport{dir: dir, responses: make(map[string][]RecordedResponse)}
+	for _, r := range rec.Responses {
+		key := r.Method + " " + r.URL
+		t.responses[key] = append(t.responses[key], r)
+	}
+	return t, nil
+}
+
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := req.Method + " " + req.URL.String()
+
+	t.mu.Lock()
+	queue := t.responses[key]
+	if len(queue) == 0 {
+		t.unmatched = append(t.unmatched, key)
+		t.mu.Unlock()
+		return nil, fmt.Errorf("no recorded response for %s", key)
+	}
+	r := queue[0]
+	if len(queue) > 1 {
+		t.responses[key] = queue[1:]
+	}
+	t.mu.Unlock()
+
+	body, err := os.ReadFile(filepath.Join(t.dir, r.File))
+	if err != nil {
+		return nil, err
+	}
+	header := http.Header{}
+	if r.ContentType != "" {
+		header.Set("Content-Type", r.ContentType)
+	}
+	return &http.Response{
+		StatusCode:    r.Status,
+		Status:        fmt.Sprintf("%d %s", r.Status, http.StatusText(r.Status)),
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// Unmatched lists the requests the recording held no response for, as
+// "METHOD URL".
+func (t *ReplayTransport) Unmatched() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]string(nil), t.unmatched...)
+}
@@ -0,0 +1,66 @@
+package scrapers
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"price-comparison-api/internal/models"
+)
+
+var (
+	preorderPattern = regexp.MustCompile(`(?i)\bpre-?\s?orders?\b`)
+
+	// "Releases November 12, 2026", "Release date: 12 Nov 2026",
+	// "Available Nov 12, 2026", "Coming 12/11/2026" and similar
+	releasePattern = regexp.MustCompile(`(?i)(?:release(?:s|d)?|release date|available(?: on| from)?|coming(?: soon)?|ships?(?: on| from)?|launch(?:es)?(?: on)?)\s*:?\s*` +
+		`([A-Z][a-z]{2,8}\.? \d{1,2},? \d{4}|\d{1,2} [A-Z][a-z]{2,8}\.? \d{4}|\d{4}-\d{2}-\d{2})`)
+
+	releaseLayouts = []string{
+		"January 2, 2006", "January 2 2006", "Jan 2, 2006", "Jan 2 2006", "Jan. 2, 2006",
+		"2 January 2006", "2 Jan 2006", "2 Jan. 2006", "2006-01-02",
+	}
+)
+
+// detectRelease looks for preorder markers and a release date in a listing's
+// text. A listing with a future release date counts as a preorder even when
+// it isn't labelled as one.
+func detectRelease(text string) (bool, *time.Time) {
+	preorder := preorderPattern.MatchString(text)
+
+	var release *time.Time
+	if m := releasePattern.FindStringSubmatch(text); m != nil {
+		if t, ok := parseReleaseDate(m[1]); ok {
+			release = &t
+		}
+	}
+	if release != nil && release.After(time.Now()) {
+		preorder = true
+	}
+	return preorder, release
+}
+
+func parseReleaseDate(s string) (time.Time, bool) {
+	s = strings.Join(strings.Fields(s), " ")
+	for _, layout := range releaseLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	// Full month names in the short layouts ("Sept 5, 2026" and the like)
+	if fields := strings.Fields(s); len(fields) == 3 && len(fields[0]) > 3 {
+		fields[0] = fields[0][:3]
+		return parseReleaseDate(strings.Join(fields, " "))
+	}
+	return time.Time{}, false
+}
+
+// applyRelease marks a scraped product as a preorder when its card says so.
+// Preorders can't be bought for immediate delivery, so they are reported
+// as out of stock.
+func applyRelease(product *models.Product, text string) {
+	product.Preorder, product.ReleaseDate = detectRelease(text)
+	if product.Preorder {
+		product.InStock = false
+	}
+}
@@ -0,0 +1,316 @@
+package scrapers
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+)
+
+// TestReplay checks every scraper's extraction against pages recorded from
+// its live retailer site, without touching the live sites. It replays the
+// pages saved in testdata/replay/live/<scraper> through the scraper's
+// transport and compares the products extracted with the recording's
+// golden.json, so a change that alters what a scraper reads from a page
+// fails the test. A scraper without a live recording is skipped.
+//
+// With -record the searches in replayCases run against the live sites
+// instead, their pages are sanitized and saved and the golden files
+// rewritten. With -update only the golden files are rewritten, from the
+// pages already recorded, after an intended change to extraction:
+//
+//	go test ./internal/scrapers -run TestReplay/amazon -record
+//	go test ./internal/scrapers -run TestReplay -update
+var (
+	recordPages   = flag.Bool("record", false, "record the pages again and rewrite the golden files")
+	updateGolden  = flag.Bool("update", false, "rewrite the golden files from the recorded pages instead of checking them")
+	replayTimeout = flag.Duration("replay-timeout", time.Minute, "per-search timeout")
+)
+
+// replayCase is the search recorded for one scraper.
+type replayCase struct {
+	Scraper string
+	Query   string
+	Country string
+}
+
+// replayCases are what -record searches the live sites for: everyday
+// products each retailer always lists, in a country it serves. A replay
+// repeats the search its golden file names.
+var replayCases = []replayCase{
+	{Scraper: config.ScraperAmazon, Query: "usb c cable", Country: "US"},
+	{Scraper: config.ScraperEbay, Query: "usb c cable", Country: "US"},
+	{Scraper: config.ScraperFlipkart, Query: "usb c cable", Country: "IN"},
+	{Scraper: config.ScraperWalmart, Query: "paper towels", Country: "US"},
+	{Scraper: config.ScraperTarget, Query: "paper towels", Country: "US"},
+	{Scraper: config.ScraperBestBuy, Query: "usb c cable", Country: "US"},
+	{Scraper: config.ScraperGoogleShopping, Query: "usb c cable", Country: "US"},
+	{Scraper: config.ScraperAliExpress, Query: "usb c cable", Country: "US"},
+	{Scraper: config.ScraperEtsy, Query: "coffee mug", Country: "US"},
+	{Scraper: config.ScraperCostco, Query: "paper towels", Country: "US"},
+	{Scraper: config.ScraperSamsClub, Query: "paper towels", Country: "US"},
+	{Scraper: config.ScraperMercadoLibre, Query: "cable usb c", Country: "MX"},
+}
+
+// Where a recording's pages came from, and the directory under
+// testdata/replay holding its recordings
+const (
+	pagesLive     = "live"
+	pagesSelfTest = "selftest"
+)
+
+// golden is a recording's golden file: the search recorded, where its
+// pages came from and the products extracted from them.
+type golden struct {
+	Scraper  string                   `json:"scraper"`
+	Query    string                   `json:"query"`
+	Country  string                   `json:"country"`
+	Pages    string                   `json:"pages"`
+	Products []map[string]interface{} `json:"products"`
+}
+
+// maxProblems caps how many differences are reported per scraper.
+const maxProblems = 20
+
+// volatileFields change from one run to the next, so they aren't compared.
+var volatileFields = []string{"scraped_at"}
+
+func TestReplay(t *testing.T) {
+	replayAll(t, pagesLive)
+}
+
+// TestReplaySmoke replays each scraper's built-in self-test page, recorded
+// in testdata/replay/selftest. These are smoke cases: they show a scraper's
+// extraction works end to end on a page it's known to parse, not that its
+// selectors still match the live site. -record records the self-test pages
+// again.
+func TestReplaySmoke(t *testing.T) {
+	replayAll(t, pagesSelfTest)
+}
+
+func replayAll(t *testing.T, pages string) {
+	cfg := config.Default()
+	for _, tc := range replayCases {
+		tc := tc
+		t.Run(tc.Scraper, func(t *testing.T) {
+			replay(t, cfg.Scrapers[tc.Scraper], tc, pages)
+		})
+	}
+}
+
+// replay records a scraper's pages when asked, then replays them and
+// compares the products extracted with the golden file, or rewrites it.
+func replay(t *testing.T, cfg config.ScraperConfig, tc replayCase, pages string) {
+	dir := filepath.Join("testdata", "replay", pages, tc.Scraper)
+	goldenPath := filepath.Join(dir, "golden.json")
+
+	var want golden
+	switch {
+	case *recordPages:
+		var base http.RoundTripper
+		if pages == pagesSelfTest {
+			st := SelfTestCases[tc.Scraper]
+			tc.Query, tc.Country = st.Query, st.Country
+			base = StaticTransport{HTML: st.HTML}
+		}
+		transport, err := NewRecordingTransport(base, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := replaySearch(cfg, transport, tc); err != nil {
+			t.Fatalf("recording: %v", err)
+		}
+		want.Pages = pages
+	default:
+		// Replays repeat the recorded search, which may not be the case's
+		// current one
+		var err error
+		want, err = readGolden(goldenPath)
+		if os.IsNotExist(err) && pages == pagesLive {
+			t.Skipf("no live recording in %s: record it with -record from a machine that can reach the site", dir)
+		}
+		if os.IsNotExist(err) {
+			t.Fatalf("nothing recorded in %s, run with -record", dir)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want.Pages != pages {
+			t.Fatalf("%s holds %s pages, want %s", dir, want.Pages, pages)
+		}
+		tc.Query, tc.Country = want.Query, want.Country
+	}
+
+	// Replayed pages need no politeness delay
+	cfg.Delay = 0
+	cfg.RetryDelay = 0
+	transport, err := NewReplayTransport(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	products, err := replaySearch(cfg, transport, tc)
+	for _, key := range transport.Unmatched() {
+		t.Errorf("not recorded: %s, the search URL changed: record again", key)
+	}
+	if err != nil {
+		t.Fatalf("search %q in %s: %v", tc.Query, tc.Country, err)
+	}
+	got, err := goldenProducts(products)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *recordPages || *updateGolden {
+		g := golden{Scraper: tc.Scraper, Query: tc.Query, Country: tc.Country, Pages: want.Pages, Products: got}
+		if err := writeGolden(goldenPath, g); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	for _, problem := range diffProducts(want.Products, got) {
+		t.Error(problem)
+	}
+}
+
+// replaySearch runs a search with the scraper fetching through transport.
+func replaySearch(cfg config.ScraperConfig, transport http.RoundTripper, tc replayCase) ([]models.Product, error) {
+	scraper, err := New(tc.Scraper, cfg)
+	if err != nil {
+		return nil, err
+	}
+	scraper.SetTransport(transport)
+	ctx, cancel := context.WithTimeout(context.Background(), *replayTimeout)
+	defer cancel()
+	return scraper.Search(ctx, tc.Query, tc.Country)
+}
+
+// goldenProducts turns products into their JSON fields, as clients see
+// them, without the volatile ones.
+func goldenProducts(products []models.Product) ([]map[string]interface{}, error) {
+	data, err := json.Marshal(products)
+	if err != nil {
+		return nil, err
+	}
+	fields := []map[string]interface{}{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for _, p := range fields {
+		for _, name := range volatileFields {
+			delete(p, name)
+		}
+	}
+	return fields, nil
+}
+
+func readGolden(path string) (golden, error) {
+	var g golden
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return g, err
+	}
+	if err := json.Unmarshal(data, &g); err != nil {
+		return g, fmt.Errorf("%s: %v", path, err)
+	}
+	return g, nil
+}
+
+func writeGolden(path string, g golden) error {
+	data, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// diffProducts lists how the extracted products differ from the golden
+// ones, field by field.
+func diffProducts(want, got []map[string]interface{}) []string {
+	var problems []string
+	if len(got) != len(want) {
+		problems = append(problems, fmt.Sprintf("%d products, want %d", len(got), len(want)))
+	}
+	for i := 0; i < len(want) && i < len(got); i++ {
+		names := make(map[string]bool)
+		for name := range want[i] {
+			names[name] = true
+		}
+		for name := range got[i] {
+			names[name] = true
+		}
+		sorted := make([]string, 0, len(names))
+		for name := range names {
+			sorted = append(sorted, name)
+		}
+		sort.Strings(sorted)
+
+		for _, name := range sorted {
+			w, inWant := want[i][name]
+			g, inGot := got[i][name]
+			switch {
+			case !inGot:
+				problems = append(problems, fmt.Sprintf("products[%d].%s: missing, want %s", i, name, jsonText(w)))
+			case !inWant:
+				problems = append(problems, fmt.Sprintf("products[%d].%s: %s, want none", i, name, jsonText(g)))
+			case !reflect.DeepEqual(w, g):
+				problems = append(problems, fmt.Sprintf("products[%d].%s: %s, want %s", i, name, jsonText(g), jsonText(w)))
+			}
+		}
+	}
+	if len(problems) > maxProblems {
+		more := len(problems) - maxProblems
+		problems = append(problems[:maxProblems], fmt.Sprintf("and %d more", more))
+	}
+	return problems
+}
+
+func jsonText(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+func TestSanitizePage(t *testing.T) {
+	tests := []struct {
+		name string
+		page string
+		want string
+	}{
+		{
+			name: "drops scripts",
+			page: `<div>a</div><script>var session = "s3cr3t";</script><script src="/app.js"></script>`,
+			want: `<div>a</div>`,
+		},
+		{
+			name: "keeps JSON-LD and JSON data",
+			page: `<script type="application/ld+json">{"@type":"Product"}</script><script type='application/json'>{}</script>`,
+			want: `<script type="application/ld+json">{"@type":"Product"}</script><script type='application/json'>{}</script>`,
+		},
+		{
+			name: "drops comments",
+			page: "<p>price</p><!-- served by\nhost-12 -->",
+			want: "<p>price</p>",
+		},
+		{
+			name: "blanks hidden inputs",
+			page: `<input type="hidden" name="csrf" value="abc123"><input type="text" name="q" value="usb c cable">`,
+			want: `<input type="hidden" name="csrf" value=""><input type="text" name="q" value="usb c cable">`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(SanitizePage([]byte(tt.page))); got != tt.want {
+				t.Errorf("SanitizePage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
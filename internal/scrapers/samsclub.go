@@ -0,0 +1,170 @@
+package scrapers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/productid"
+	"price-comparison-api/internal/scrapetrace"
+	"price-comparison-api/pkg/politeness"
+	"price-comparison-api/pkg/retry"
+)
+
+// SamsClubScraper searches samsclub.com (US only). Like Costco it is a
+// warehouse club, so every listing is marked RequiresMembership.
+type SamsClubScraper struct {
+	cfg       config.ScraperConfig
+	transport http.RoundTripper
+	ctx       context.Context
+	base      collectorBase
+}
+
+func NewSamsClubScraper(cfg config.ScraperConfig) *SamsClubScraper {
+	return &SamsClubScraper{cfg: cfg}
+}
+
+// SetTransport replaces the HTTP transport used by the collector.
+func (s *SamsClubScraper) SetTransport(transport http.RoundTripper) {
+	s.transport = transport
+	s.base.reset()
+}
+
+// SetContext bounds every page fetch by ctx.
+func (s *SamsClubScraper) SetContext(ctx context.Context) {
+	s.ctx = ctx
+	s.base.reset()
+}
+
+func (s *SamsClubScraper) newCollector() *colly.Collector {
+	c := s.base.clone(func() *colly.Collector {
+		c := colly.NewCollector(
+			colly.AllowedDomains("samsclub.com", "www.samsclub.com"),
+			colly.AllowURLRevisit(),
+		)
+		politeness.Limit(c, config.ScraperSamsClub, s.cfg)
+		if s.transport != nil {
+			c.WithTransport(s.transport)
+		}
+		if s.ctx != nil {
+			c.Context = s.ctx
+		}
+		return c
+	})
+	fingerprintPages(c, config.ScraperSamsClub, PageSearch)
+	detectBlocks(c, config.ScraperSamsClub)
+	timePhases(c)
+
+	c.OnRequest(func(r *colly.Request) {
+		r.Headers.Set("User-Agent", s.cfg.UserAgent)
+		r.Headers.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+		r.Headers.Set("Accept-Language", "en-US,en;q=0.5")
+	})
+
+	retry.Attach(c)
+
+	return c
+}
+
+func (s *SamsClubScraper) Search(ctx context.Context, query, country string) ([]models.Product, error) {
+	logger := scraperLogger(ctx, config.ScraperSamsClub, query, country)
+	products := make([]models.Product, 0)
+
+	if strings.ToUpper(country) != "US" {
+		logger.Warn().Msgf("Sam's Club: Country %s not supported, returning empty results", country)
+		return products, nil
+	}
+
+	searchURL := s.getSearchURL(query)
+	logger.Info().Msgf("Searching Sam's Club (US) with URL: %s", searchURL)
+
+	seen := make(map[string]bool)
+	sel := siteSelectors(config.ScraperSamsClub)
+	collector := s.newCollector()
+	structured := newStructuredResults(ctx, config.ScraperSamsClub, "US", "USD", "Sam's Club US")
+	structured.membership = true
+	structured.attach(collector)
+	collector.OnResponse(func(r *colly.Response) {
+		logger.Debug().Msgf("Sam's Club Response status: %d", r.StatusCode)
+	})
+	collector.OnHTML(sel.card(), func(e *colly.HTMLElement) {
+		scrapetrace.RecordHit(ctx, sel.card())
+		product, ok := s.parseCard(e, sel)
+		if !ok || seen[product.URL] {
+			return
+		}
+		seen[product.URL] = true
+		products = append(products, product)
+		logger.Debug().Msgf("Found Sam's Club product: %s - %s", product.Name, product.Price)
+	})
+
+	if err := visit(ctx, collector, searchURL); err != nil {
+		logger.Warn().Err(err).Msg("Error visiting Sam's Club")
+		return products, fmt.Errorf("samsclub: %w", err)
+	}
+	products = structured.merge(products)
+
+	logger.Info().Msgf("Sam's Club found %d products", len(products))
+	return products, nil
+}
+
+func (s *SamsClubScraper) getSearchURL(query string) string {
+	return "https://www.samsclub.com/s/" + url.PathEscape(query)
+}
+
+func (s *SamsClubScraper) parseCard(e *colly.HTMLElement, sel SiteSelectors) (models.Product, bool) {
+	product := models.Product{
+		Source:             "Sam's Club US",
+		Currency:           "USD",
+		ScrapedAt:          time.Now(),
+		InStock:            true,
+		RequiresMembership: true,
+	}
+	applyRelease(&product, e.Text)
+
+	product.Name = firstText(e, sel.Name)
+	if len(product.Name) <= 5 {
+		return product, false
+	}
+
+	price := priceAmount.FindString(firstText(e, sel.Price))
+	if price == "" {
+		if price = priceAmount.FindString(e.Text); price == "" {
+			return product, false
+		}
+	}
+	product.Price = price
+
+	href := childAttr(e, sel.URL, "href")
+	product.URL = e.Request.AbsoluteURL(href)
+	if product.URL == "" {
+		product.URL = href
+	}
+
+	for _, attr := range []string{"src", "data-src"} {
+		if src := childAttr(e, sel.Image, attr); src != "" && !strings.HasPrefix(src, "data:") {
+			product.Image = src
+			break
+		}
+	}
+
+	product.Rating, product.Reviews = starRating(e, sel.Rating)
+
+	text := strings.ToLower(e.Text)
+	if strings.Contains(text, "out of stock") || strings.Contains(text, "sold out") {
+		product.InStock = false
+	}
+
+	applyDeal(&product, e, config.ScraperSamsClub)
+	applyCondition(&product, e, config.ScraperSamsClub)
+
+	applyCategory(&product, e, config.ScraperSamsClub)
+	product.ID, product.SourceProductID = productid.For(config.ScraperSamsClub, "US", product.URL, product.Name)
+	return product, true
+}
@@ -0,0 +1,194 @@
+package scrapers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+	"github.com/rs/zerolog"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/scrapetrace"
+	"price-comparison-api/pkg/politeness"
+	"price-comparison-api/pkg/retry"
+)
+
+// Scraper is implemented by every retailer scraper.
+type Scraper interface {
+	// Search logs through the logger carried by ctx, if any.
+	Search(ctx context.Context, query, country string) ([]models.Product, error)
+	// SetTransport swaps the HTTP transport used for page fetches, e.g. to
+	// serve canned pages during self-tests.
+	SetTransport(transport http.RoundTripper)
+	// SetContext bounds every page fetch by ctx; cancelling it aborts the
+	// scraper's in-flight requests, e.g. on shutdown.
+	SetContext(ctx context.Context)
+}
+
+// RenderFunc loads a page in a real browser and returns the rendered HTML,
+// for retailers whose results are built with JavaScript.
+type RenderFunc func(ctx context.Context, pageURL string) (string, error)
+
+// New builds the scraper registered under name.
+func New(name string, cfg config.ScraperConfig) (Scraper, error) {
+	switch name {
+	case config.ScraperAmazon:
+		return NewAmazonScraper(cfg), nil
+	case config.ScraperEbay:
+		return NewEbayScraper(cfg), nil
+	case config.ScraperFlipkart:
+		return NewFlipkartScraper(cfg), nil
+	case config.ScraperWalmart:
+		return NewWalmartScraper(cfg), nil
+	case config.ScraperTarget:
+		return NewTargetScraper(cfg), nil
+	case config.ScraperBestBuy:
+		return NewBestBuyScraper(cfg), nil
+	case config.ScraperGoogleShopping:
+		return NewGoogleShoppingScraper(cfg), nil
+	case config.ScraperAliExpress:
+		return NewAliExpressScraper(cfg), nil
+	case config.ScraperEtsy:
+		return NewEtsyScraper(cfg), nil
+	case config.ScraperCostco:
+		return NewCostcoScraper(cfg), nil
+	case config.ScraperSamsClub:
+		return NewSamsClubScraper(cfg), nil
+	case config.ScraperMercadoLibre:
+		return NewMercadoLibreScraper(cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown scraper: %s", name)
+	}
+}
+
+// scraperLogger returns the request logger from ctx tagged with the scraper,
+// country and query so entries correlate with the request that caused them.
+func scraperLogger(ctx context.Context, name, query, country string) zerolog.Logger {
+	return zerolog.Ctx(ctx).With().
+		Str("scraper", name).
+		Str("country", country).
+		Str("query", query).
+		Logger()
+}
+
+// collectorBase is the collector a scraper's fetches are cloned from.
+// Clones share its HTTP backend, so the delay and parallelism politeness
+// sets on it hold across a search's attempts and across concurrent
+// searches. It's built on first use and built again after the scraper's
+// transport or context changes.
+type collectorBase struct {
+	mu        sync.Mutex
+	collector *colly.Collector
+}
+
+// clone returns a collector sharing the base's backend and settings, with
+// none of its callbacks. build makes the base when there is none yet; it
+// should allow revisits, since clones share the record of visited pages.
+func (b *collectorBase) clone(build func() *colly.Collector) *colly.Collector {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.collector == nil {
+		b.collector = build()
+	}
+	return b.collector.Clone()
+}
+
+// reset drops the base, so the next clone builds it with the scraper's
+// current transport and context.
+func (b *collectorBase) reset() {
+	b.mu.Lock()
+	b.collector = nil
+	b.mu.Unlock()
+}
+
+// visit fetches pageURL with c, once the retailer's robots.txt lets it,
+// retrying it when it fails, and records the fetch in the request's scrape
+// trace. A page that turns out to be a captcha or bot challenge is
+// reported as a BlockedError. Colly only reports the
+// status of failed fetches, as the error text, so successful ones are
+// recorded as 200.
+func visit(ctx context.Context, c *colly.Collector, pageURL string) error {
+	start := time.Now()
+	err := politeness.Wait(ctx, pageURL)
+	phases := &scrapetrace.Phases{Wait: time.Since(start).String()}
+	if err == nil {
+		fetchStart := time.Now()
+		rctx := colly.NewContext()
+		err = retry.Visit(ctx, c, pageURL, rctx)
+		if blocked := blockedFetch(rctx); blocked != nil {
+			err = blocked
+		}
+		phases.Fetch, phases.Extract = fetchPhases(rctx, fetchStart)
+	}
+	scrapetrace.RecordFetch(ctx, pageURL, fetchStatus(err), time.Since(start), phases, err)
+	return err
+}
+
+// Keys of the times timePhases records in a fetch's context
+const (
+	respondedAtKey = "phases.responded"
+	scrapedAtKey   = "phases.scraped"
+)
+
+// timePhases records when each page c fetches arrives and when its
+// callbacks are done with it, for the phase timings of scrape traces.
+func timePhases(c *colly.Collector) {
+	c.OnResponse(func(r *colly.Response) {
+		r.Ctx.Put(respondedAtKey, time.Now())
+	})
+	c.OnScraped(func(r *colly.Response) {
+		r.Ctx.Put(scrapedAtKey, time.Now())
+	})
+}
+
+// fetchPhases splits the time since start of a fetch made with rctx into
+// fetching the page and extracting from it. A fetch that got no page spent
+// it all fetching.
+func fetchPhases(rctx *colly.Context, start time.Time) (fetch, extract string) {
+	end := time.Now()
+	responded, ok := rctx.GetAny(respondedAtKey).(time.Time)
+	if !ok {
+		return end.Sub(start).String(), "0s"
+	}
+	if scraped, ok := rctx.GetAny(scrapedAtKey).(time.Time); ok {
+		end = scraped
+	}
+	return responded.Sub(start).String(), end.Sub(responded).String()
+}
+
+// fetchStatus recovers the HTTP status from a colly fetch error, or returns
+// 0 when the fetch failed without a response.
+func fetchStatus(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	for code := 400; code < 600; code++ {
+		if text := http.StatusText(code); text != "" && text == err.Error() {
+			return code
+		}
+	}
+	return 0
+}
+
+// renderPage renders pageURL for scraper and records it in the request's
+// scrape trace. A challenge page is reported as a BlockedError.
+func renderPage(ctx context.Context, scraper string, render RenderFunc, pageURL string) (string, error) {
+	start := time.Now()
+	if err := politeness.Wait(ctx, pageURL); err != nil {
+		scrapetrace.RecordRender(ctx, pageURL, time.Since(start), err)
+		return "", err
+	}
+	html, err := render(ctx, pageURL)
+	if err == nil {
+		err = blockedPage(scraper, pageURL, html)
+	}
+	scrapetrace.RecordRender(ctx, pageURL, time.Since(start), err)
+	if u, parseErr := url.Parse(pageURL); err == nil && parseErr == nil {
+		recordFingerprint(u, "", PageRendered, http.StatusOK, "text/html", []byte(html))
+	}
+	return html, err
+}
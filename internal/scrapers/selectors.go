@@ -0,0 +1,229 @@
+package scrapers
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/gocolly/colly/v2"
+	"gopkg.in/yaml.v3"
+	"price-comparison-api/internal/apierr"
+	"price-comparison-api/internal/config"
+)
+
+// SiteSelectors are the CSS selectors a scraper reads a retailer's search
+// results with. Each list is tried in order until a selector matches, and
+// all but Cards are looked up inside a card.
+type SiteSelectors struct {
+	// Cards select each result. Scrapers that read one page layout match
+	// all of them at once; the others try one page fetch per selector.
+	Cards  []string `yaml:"cards" json:"cards"`
+	Name   []string `yaml:"name" json:"name"`
+	Price  []string `yaml:"price" json:"price"`
+	Image  []string `yaml:"image" json:"image"`
+	URL    []string `yaml:"url" json:"url"`
+	Rating []string `yaml:"rating" json:"rating,omitempty"`
+	// Reviews select the review count, and Merchant the store or shop
+	// selling the product, on retailers that show them
+	Reviews  []string `yaml:"reviews" json:"reviews,omitempty"`
+	Merchant []string `yaml:"merchant" json:"merchant,omitempty"`
+}
+
+// card joins the card selectors into one selector matching any of them.
+func (s SiteSelectors) card() string {
+	return strings.Join(s.Cards, ", ")
+}
+
+// fields lists the selectors under their YAML names, for validation.
+func (s SiteSelectors) fields() map[string][]string {
+	return map[string][]string{
+		"cards": s.Cards, "name": s.Name, "price": s.Price, "image": s.Image,
+		"url": s.URL, "rating": s.Rating, "reviews": s.Reviews, "merchant": s.Merchant,
+	}
+}
+
+// overlay returns s with the lists o sets replaced.
+func (s SiteSelectors) overlay(o SiteSelectors) SiteSelectors {
+	pick := func(base, over []string) []string {
+		if over != nil {
+			return over
+		}
+		return base
+	}
+	return SiteSelectors{
+		Cards:    pick(s.Cards, o.Cards),
+		Name:     pick(s.Name, o.Name),
+		Price:    pick(s.Price, o.Price),
+		Image:    pick(s.Image, o.Image),
+		URL:      pick(s.URL, o.URL),
+		Rating:   pick(s.Rating, o.Rating),
+		Reviews:  pick(s.Reviews, o.Reviews),
+		Merchant: pick(s.Merchant, o.Merchant),
+	}
+}
+
+// builtinSelectors are the selectors the server ships with, one
+// <scraper>.yaml per retailer.
+//
+//go:embed selectors/*.yaml
+var builtinSelectors embed.FS
+
+// SelectorStatus describes the selectors in use.
+type SelectorStatus struct {
+	// Dir is where override files are read from, empty when only the
+	// built-in selectors are used
+	Dir string `json:"dir,omitempty"`
+	// Overrides lists the retailers with an override file in Dir
+	Overrides []string                 `json:"overrides"`
+	LoadedAt  time.Time                `json:"loaded_at"`
+	Sites     map[string]SiteSelectors `json:"sites"`
+}
+
+var (
+	selectorsMu sync.RWMutex
+	selectorSet = SelectorStatus{Sites: mustLoadBuiltinSelectors()}
+)
+
+func mustLoadBuiltinSelectors() map[string]SiteSelectors {
+	sites, err := readSelectors(builtinSelectors, "selectors")
+	if err != nil {
+		panic(fmt.Sprintf("built-in selectors: %v", err))
+	}
+	return sites
+}
+
+// ConfigureSelectors loads the built-in selectors overlaid with the files in
+// cfg.Dir. It fails, leaving the selectors in use unchanged, when a file is
+// invalid.
+func ConfigureSelectors(cfg config.SelectorsConfig) error {
+	selectorsMu.Lock()
+	defer selectorsMu.Unlock()
+	return loadSelectors(cfg.Dir)
+}
+
+// ReloadSelectors re-reads the override files, so selector fixes apply
+// without a restart. When a file is invalid the selectors in use are kept
+// and a validation error names the problem.
+func ReloadSelectors() (SelectorStatus, error) {
+	selectorsMu.Lock()
+	defer selectorsMu.Unlock()
+	if err := loadSelectors(selectorSet.Dir); err != nil {
+		return SelectorStatus{}, apierr.Validation("invalid_selectors", "selector files were not reloaded").WithDetails(err.Error())
+	}
+	return selectorSet, nil
+}
+
+// Selectors returns the selectors in use.
+func Selectors() SelectorStatus {
+	selectorsMu.RLock()
+	defer selectorsMu.RUnlock()
+	return selectorSet
+}
+
+// siteSelectors returns the selectors scraper reads search results with. A
+// search takes them once, so a reload never mixes two sets in one page.
+func siteSelectors(scraper string) SiteSelectors {
+	selectorsMu.RLock()
+	defer selectorsMu.RUnlock()
+	return selectorSet.Sites[scraper]
+}
+
+// loadSelectors replaces the selectors in use; selectorsMu is held.
+func loadSelectors(dir string) error {
+	sites := mustLoadBuiltinSelectors()
+	overrides := []string{}
+	if dir != "" {
+		files, err := readSelectors(os.DirFS(dir), ".")
+		if err != nil {
+			return fmt.Errorf("selectors dir %s: %w", dir, err)
+		}
+		for name, o := range files {
+			sites[name] = sites[name].overlay(o)
+			overrides = append(overrides, name)
+		}
+		sort.Strings(overrides)
+	}
+	for name, s := range sites {
+		if len(s.Cards) == 0 || len(s.Name) == 0 || len(s.Price) == 0 {
+			return fmt.Errorf("%s: cards, name and price need at least one selector", name)
+		}
+	}
+	selectorSet = SelectorStatus{Dir: dir, Overrides: overrides, LoadedAt: time.Now(), Sites: sites}
+	return nil
+}
+
+// readSelectors reads every <scraper>.yaml file in dir of fsys. Files for
+// unknown scrapers, unknown keys and selectors that don't parse are errors.
+func readSelectors(fsys fs.FS, dir string) (map[string]SiteSelectors, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool, len(config.ScraperNames))
+	for _, name := range config.ScraperNames {
+		known[name] = true
+	}
+
+	sites := make(map[string]SiteSelectors)
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), ext)
+		if !known[name] {
+			return nil, fmt.Errorf("%s: unknown scraper %q", entry.Name(), name)
+		}
+		data, err := fs.ReadFile(fsys, filepath.ToSlash(filepath.Join(dir, entry.Name())))
+		if err != nil {
+			return nil, err
+		}
+		var s SiteSelectors
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&s); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%s: %v", entry.Name(), err)
+		}
+		for field, list := range s.fields() {
+			for _, selector := range list {
+				if _, err := cascadia.ParseGroup(selector); err != nil {
+					return nil, fmt.Errorf("%s: %s selector %q: %v", entry.Name(), field, selector, err)
+				}
+			}
+		}
+		sites[name] = s
+	}
+	return sites, nil
+}
+
+// childText returns the text of the elements the first selector with any
+// text matches in e.
+func childText(e *colly.HTMLElement, selectors []string) string {
+	for _, selector := range selectors {
+		if text := strings.TrimSpace(e.ChildText(selector)); text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
+// childAttr returns attr of the first element, matched by the first
+// selector that matches one having it, in e.
+func childAttr(e *colly.HTMLElement, selectors []string, attr string) string {
+	for _, selector := range selectors {
+		if value := e.ChildAttr(selector, attr); value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,86 @@
+package scrapers
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/gocolly/colly/v2"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+)
+
+// sellerSelectors locate the seller line and the seller's feedback on a
+// marketplace's search result card.
+type sellerSelectors struct {
+	Name   []string
+	Rating []string
+}
+
+var sellerCards = map[string]sellerSelectors{
+	config.ScraperAmazon: {
+		Name: []string{"[data-cy='seller-name']", ".s-seller-name", "span.a-size-small.a-color-secondary:contains('Sold by')"},
+	},
+	config.ScraperEbay: {
+		// "techdeals (12,345) 99.5%"
+		Name:   []string{".s-item__seller-info-text", ".s-item__seller-info"},
+		Rating: []string{".s-item__seller-info-text", ".s-item__etrs-text"},
+	},
+	config.ScraperWalmart: {
+		Name: []string{"[data-automation-id='product-seller']", "[data-testid='seller-name']", "span:contains('Sold by')"},
+	},
+	config.ScraperTarget: {
+		Name: []string{"[data-test='targetPlusExtraInfo']", "[data-test='product-seller']"},
+	},
+	config.ScraperBestBuy: {
+		Name: []string{"[data-testid='marketplace-seller-name']", ".marketplace-seller-name"},
+	},
+	config.ScraperMercadoLibre: {
+		Name: []string{".poly-component__seller", ".ui-search-official-store-label"},
+	},
+	config.ScraperAliExpress: {
+		Name: []string{"[class*='store-name']", "a[class*='cards--store']"},
+	},
+}
+
+var (
+	// "Sold by", "Sold and shipped by", "Ships from and sold by", "Vendido
+	// por", "by" in front of the seller's name
+	sellerPrefix = regexp.MustCompile(`(?i)^(?:(?:ships\s+from\s+and\s+)?sold(?:\s+and\s+shipped)?\s+by|vendido\s+por|por|by)\b\s*:?\s*`)
+	// eBay's "(12,345)" feedback count after the name
+	sellerFeedbackCount = regexp.MustCompile(`\s*\([\d.,]+\)`)
+	sellerPercent       = regexp.MustCompile(`\d{1,3}(?:[.,]\d+)?%`)
+)
+
+// applySeller fills the product's merchant, the seller on marketplaces, and
+// the seller's feedback from its result card. Cards without a seller line
+// leave the merchant as the scraper set it.
+func applySeller(product *models.Product, e *colly.HTMLElement, scraper string) {
+	sel := sellerCards[scraper]
+	for _, selector := range sel.Name {
+		if name := sellerName(e.ChildText(selector)); name != "" {
+			product.Merchant = name
+			break
+		}
+	}
+	for _, selector := range sel.Rating {
+		if rating := sellerPercent.FindString(e.ChildText(selector)); rating != "" {
+			product.SellerRating = strings.Replace(rating, ",", ".", 1)
+			break
+		}
+	}
+}
+
+// sellerName reads the seller's name from a seller line, dropping the
+// "Sold by" label, feedback counts and percentages around it.
+func sellerName(text string) string {
+	text = cleanText(text)
+	text = sellerPrefix.ReplaceAllString(text, "")
+	text = sellerFeedbackCount.ReplaceAllString(text, "")
+	text = sellerPercent.ReplaceAllString(text, "")
+	text = strings.TrimSpace(strings.Trim(text, "·|-–"))
+	// A whole sentence is a card's blurb, not a seller's name
+	if len(text) > 60 {
+		return ""
+	}
+	return text
+}
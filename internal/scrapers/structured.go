@@ -0,0 +1,283 @@
+package scrapers
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly/v2"
+	"price-comparison-api/internal/categories"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/productid"
+	"price-comparison-api/internal/scrapetrace"
+	"price-comparison-api/pkg/utils"
+)
+
+// Where structured products were read from, as recorded in scrape traces
+const (
+	hitJSONLD    = "script[type='application/ld+json']"
+	hitMicrodata = "[itemtype*='schema.org/Product']"
+)
+
+// structuredResults reads the schema.org Products a search page describes
+// in JSON-LD or microdata. Many retailers embed them for search engines,
+// and unlike their markup they don't change with a redesign, so scrapers
+// read them first and fall back to their CSS selectors for whatever the
+// page doesn't describe.
+type structuredResults struct {
+	ctx      context.Context
+	scraper  string
+	country  string
+	currency string
+	source   string
+	// membership marks every product RequiresMembership, for warehouse
+	// clubs
+	membership bool
+
+	products []models.Product
+	seen     map[string]bool
+}
+
+func newStructuredResults(ctx context.Context, scraper, country, currency, source string) *structuredResults {
+	return &structuredResults{
+		ctx:      ctx,
+		scraper:  scraper,
+		country:  strings.ToUpper(country),
+		currency: currency,
+		source:   source,
+		seen:     make(map[string]bool),
+	}
+}
+
+// attach reads the structured products of every page c fetches.
+func (s *structuredResults) attach(c *colly.Collector) {
+	c.OnHTML("html", func(e *colly.HTMLElement) {
+		s.read(e.DOM, e.Request.URL)
+	})
+}
+
+// readRendered reads the structured products of a page rendered from
+// pageURL.
+func (s *structuredResults) readRendered(doc *goquery.Document, pageURL string) {
+	base, _ := url.Parse(pageURL)
+	s.read(doc.Selection, base)
+}
+
+func (s *structuredResults) read(doc *goquery.Selection, base *url.URL) {
+	doc.Find(hitJSONLD).Each(func(_ int, script *goquery.Selection) {
+		for _, p := range findLDProducts(script.Text()) {
+			s.add(p, base, hitJSONLD)
+		}
+	})
+	doc.Find(hitMicrodata).Each(func(_ int, item *goquery.Selection) {
+		// Products nested in another, like its accessories, aren't results
+		if item.ParentsFiltered(hitMicrodata).Length() > 0 {
+			return
+		}
+		s.add(microdataProduct(item), base, hitMicrodata)
+	})
+}
+
+// add turns a structured product into a result, when it has a name and a
+// price.
+func (s *structuredResults) add(p ldProduct, base *url.URL, hit string) {
+	value := utils.ParsePrice(p.Price)
+	if len(p.Name) <= 5 || value <= 0 {
+		return
+	}
+
+	product := models.Product{
+		Name:      p.Name,
+		Currency:  s.currency,
+		URL:       absoluteURL(base, p.URL),
+		Source:    s.source,
+		ScrapedAt: time.Now(),
+		InStock:   p.InStock == nil || *p.InStock,
+		Preorder:  p.Preorder,
+
+		RequiresMembership: s.membership,
+	}
+	if p.Currency != "" {
+		product.Currency = p.Currency
+	}
+	product.Price = structuredPrice(value, product.Currency)
+	if len(p.Images) > 0 {
+		product.Image = absoluteURL(base, p.Images[0])
+	}
+	if p.Rating != "" {
+		scale := p.BestRating
+		if scale == "" {
+			scale = "5"
+		}
+		product.Rating = p.Rating + " out of " + scale
+	}
+	product.Reviews = p.ReviewCount
+	if p.ReleaseDate != "" {
+		if t, ok := parseReleaseDate(strings.SplitN(p.ReleaseDate, "T", 2)[0]); ok {
+			product.ReleaseDate = &t
+			if t.After(time.Now()) {
+				product.Preorder = true
+			}
+		}
+	}
+	if product.Preorder {
+		product.InStock = false
+	}
+	product.Category = categories.Detect(p.Category, product.Name)
+	product.ID, product.SourceProductID = productid.For(s.scraper, s.country, product.URL, product.Name)
+
+	// Pages often describe a product in both JSON-LD and microdata
+	if key := structuredKey(product); !s.seen[key] {
+		s.seen[key] = true
+		s.products = append(s.products, product)
+		scrapetrace.RecordHit(s.ctx, hit)
+	}
+}
+
+// found reports whether any page read so far described products.
+func (s *structuredResults) found() bool {
+	return len(s.products) > 0
+}
+
+// merge returns the structured products, with whatever they lack filled
+// from the same product scraped with selectors, followed by the scraped
+// products the structured data didn't describe. With no structured
+// products, it returns scraped unchanged.
+func (s *structuredResults) merge(scraped []models.Product) []models.Product {
+	if len(s.products) == 0 {
+		return scraped
+	}
+	byKey := make(map[string]int, len(s.products))
+	merged := make([]models.Product, len(s.products), len(s.products)+len(scraped))
+	for i, p := range s.products {
+		merged[i] = p
+		byKey[p.ID] = i
+		byKey[structuredKey(p)] = i
+	}
+	for _, p := range scraped {
+		i, ok := byKey[p.ID]
+		if !ok {
+			i, ok = byKey[structuredKey(p)]
+		}
+		if !ok {
+			merged = append(merged, p)
+			continue
+		}
+		fillStructured(&merged[i], p)
+	}
+	return merged
+}
+
+// fillStructured copies what a card's selectors found into the fields the
+// structured data left empty, and the card details structured data doesn't
+// carry: deals, condition and seller.
+func fillStructured(p *models.Product, card models.Product) {
+	if p.URL == "" {
+		p.URL = card.URL
+	}
+	if p.Image == "" {
+		p.Image = card.Image
+	}
+	if p.Rating == "" {
+		p.Rating = card.Rating
+	}
+	if p.Reviews == "" {
+		p.Reviews = card.Reviews
+	}
+	if p.Category == "" {
+		p.Category = card.Category
+	}
+	if p.ReleaseDate == nil {
+		p.ReleaseDate = card.ReleaseDate
+	}
+	if card.Preorder {
+		p.Preorder, p.InStock = true, false
+	}
+	p.OriginalPrice, p.Discount, p.DealBadge = card.OriginalPrice, card.Discount, card.DealBadge
+	p.Condition = card.Condition
+	p.Merchant, p.MarketplaceSeller, p.SellerRating = card.Merchant, card.MarketplaceSeller, card.SellerRating
+}
+
+// structuredKey matches the same product found by structured data and by
+// selectors, by its normalized name.
+func structuredKey(p models.Product) string {
+	return strings.ToLower(strings.Join(strings.Fields(p.Name), " "))
+}
+
+// microdataProduct reads a schema.org Product marked up with itemprop
+// attributes.
+func microdataProduct(item *goquery.Selection) ldProduct {
+	p := ldProduct{
+		Name:        microdataValue(item, "name"),
+		URL:         microdataValue(item, "url"),
+		Price:       microdataValue(item, "price"),
+		Currency:    microdataValue(item, "priceCurrency"),
+		Category:    microdataValue(item, "category"),
+		ReleaseDate: microdataValue(item, "releaseDate"),
+		Rating:      microdataValue(item, "ratingValue"),
+		BestRating:  microdataValue(item, "bestRating"),
+		ReviewCount: microdataValue(item, "reviewCount"),
+	}
+	if p.Price == "" {
+		p.Price = microdataValue(item, "lowPrice")
+	}
+	if p.URL == "" {
+		p.URL, _ = item.Find("a[href]").First().Attr("href")
+	}
+	if image := microdataValue(item, "image"); image != "" {
+		p.Images = []string{image}
+	}
+	p.InStock, p.Preorder = ldAvailability(microdataValue(item, "availability"))
+	return p
+}
+
+// microdataValue returns the value of the first itemprop element named
+// prop: its content, href or src attribute, or else its text.
+func microdataValue(item *goquery.Selection, prop string) string {
+	el := item.Find("[itemprop='" + prop + "']").First()
+	if el.Length() == 0 {
+		return ""
+	}
+	for _, attr := range []string{"content", "href", "src"} {
+		if v, ok := el.Attr(attr); ok && strings.TrimSpace(v) != "" {
+			return strings.TrimSpace(v)
+		}
+	}
+	return cleanText(el.Text())
+}
+
+// structuredCurrencySymbols are put before structured prices, which are
+// bare amounts, so they read like scraped ones
+var structuredCurrencySymbols = map[string]string{
+	"USD": "$", "GBP": "£", "EUR": "€", "INR": "₹", "JPY": "¥",
+	"CAD": "$", "AUD": "$", "MXN": "$", "BRL": "R$", "ARS": "$",
+}
+
+// structuredPrice renders a structured amount the way scraped prices look:
+// "$19.99".
+func structuredPrice(amount float64, currency string) string {
+	value := strconv.FormatFloat(amount, 'f', 2, 64)
+	if symbol, ok := structuredCurrencySymbols[currency]; ok {
+		return symbol + value
+	}
+	if currency != "" {
+		return currency + " " + value
+	}
+	return value
+}
+
+// absoluteURL resolves a link from structured data against the page it was
+// found on.
+func absoluteURL(base *url.URL, link string) string {
+	if link == "" || base == nil {
+		return link
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return base.ResolveReference(u).String()
+}
@@ -1,29 +1,68 @@
 package scrapers
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"net/http"
 	"regexp"
 	"strings"
 	"time"
 
 	"github.com/gocolly/colly/v2"
 	"github.com/gocolly/colly/v2/debug"
+	"github.com/rs/zerolog/log"
+	"price-comparison-api/internal/config"
 	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/productid"
+	"price-comparison-api/pkg/politeness"
+	"price-comparison-api/pkg/retry"
 )
 
 type TargetScraper struct {
-	collector *colly.Collector
+	cfg       config.ScraperConfig
+	transport http.RoundTripper
+	ctx       context.Context
+	base      collectorBase
 }
 
-func NewTargetScraper() *TargetScraper {
-	c := colly.NewCollector(
-		colly.AllowedDomains("target.com", "www.target.com"),
-		colly.Debugger(&debug.LogDebugger{}),
-	)
+func NewTargetScraper(cfg config.ScraperConfig) *TargetScraper {
+	return &TargetScraper{cfg: cfg}
+}
+
+// SetTransport replaces the HTTP transport used by the collector.
+func (t *TargetScraper) SetTransport(transport http.RoundTripper) {
+	t.transport = transport
+	t.base.reset()
+}
+
+// SetContext bounds every page fetch by ctx.
+func (t *TargetScraper) SetContext(ctx context.Context) {
+	t.ctx = ctx
+	t.base.reset()
+}
+
+func (t *TargetScraper) newCollector() *colly.Collector {
+	c := t.base.clone(func() *colly.Collector {
+		c := colly.NewCollector(
+			colly.AllowedDomains("target.com", "www.target.com"),
+			colly.Debugger(&debug.LogDebugger{}),
+			colly.AllowURLRevisit(),
+		)
+		politeness.Limit(c, config.ScraperTarget, t.cfg)
+		if t.transport != nil {
+			c.WithTransport(t.transport)
+		}
+		if t.ctx != nil {
+			c.Context = t.ctx
+		}
+		return c
+	})
+	fingerprintPages(c, config.ScraperTarget, PageSearch)
+	detectBlocks(c, config.ScraperTarget)
+	timePhases(c)
 
 	c.OnRequest(func(r *colly.Request) {
-		r.Headers.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
+		r.Headers.Set("User-Agent", t.cfg.UserAgent)
 		r.Headers.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8")
 		r.Headers.Set("Accept-Language", "en-US,en;q=0.5")
 		r.Headers.Set("Accept-Encoding", "gzip, deflate, br")
@@ -34,157 +73,117 @@ func NewTargetScraper() *TargetScraper {
 		r.Headers.Set("Sec-Fetch-Mode", "navigate")
 	})
 
-	c.Limit(&colly.LimitRule{
-		DomainGlob:  "*target.*",
-		Parallelism: 1,
-		Delay:       3 * time.Second,
-	})
-
 	c.OnError(func(r *colly.Response, err error) {
-		log.Printf("Target scraper error: %v", err)
+		log.Warn().Err(err).Str("scraper", config.ScraperTarget).Msg("Target scraper error")
 	})
 
-	return &TargetScraper{collector: c}
+	retry.Attach(c)
+
+	return c
 }
 
-func (t *TargetScraper) Search(query, country string) ([]models.Product, error) {
-	// Always return empty slice instead of nil
-	products := make([]models.Product, 0)
+func (t *TargetScraper) Search(ctx context.Context, query, country string) ([]models.Product, error) {
+	logger := scraperLogger(ctx, config.ScraperTarget, query, country)
 
 	if strings.ToUpper(country) != "US" {
-		log.Printf("Target: Country %s not supported, returning empty results", country)
-		return products, nil
+		logger.Warn().Msgf("Target: Country %s not supported, returning empty results", country)
+		// Always return empty slice instead of nil
+		return make([]models.Product, 0), nil
 	}
 
 	searchURL := t.getSearchURL(query)
-	log.Printf("Searching Target (US) with URL: %s", searchURL)
+	logger.Info().Msgf("Searching Target (US) with URL: %s", searchURL)
 
 	// Multiple selector strategies for Target's dynamic content
-	selectors := []string{
-		"[data-test='product-card']",
-		"[data-test='@web/site-top-of-funnel/ProductCard']",
-		".ProductCardImageWrapper",
-		"section[data-test='product-card']",
-		"div[data-test='product-card']",
-		".h-full.flex.flex-col",
-		"[data-test='product-title']",
-	}
-
-	foundAny := false
-	errorCount := 0
-
-	t.collector.OnResponse(func(r *colly.Response) {
-		log.Printf("Target Response status: %d, Content-Length: %d", r.StatusCode, len(r.Body))
-		bodyStr := string(r.Body)
-		log.Printf("Page contains product data: %v", strings.Contains(bodyStr, "data-test") || strings.Contains(bodyStr, "product"))
-	})
-
-	for _, selector := range selectors {
-		log.Printf("Trying Target selector: %s", selector)
-
-		t.collector.OnHTML(selector, func(e *colly.HTMLElement) {
-			foundAny = true
-
-			product := models.Product{
-				Source:    "Target US",
-				Currency:  "USD",
-				ScrapedAt: time.Now(),
-				InStock:   true,
-			}
-
-			// Extract name with multiple fallback selectors
-			nameSelectors := []string{
-				"[data-test='product-title']",
-				"a[data-test='product-title']",
-				".ProductCardImageWrapper h3",
-				"h3 a",
-				".styled__StyledLink-sc-1de6opt-0",
-				"a[aria-label]",
-				".h-text-sm",
-				".h-text-bs",
+	sel := siteSelectors(config.ScraperTarget)
+
+	search := cardSearch{
+		url:          searchURL,
+		cards:        sel.Cards,
+		newCollector: t.newCollector,
+		newStructured: func() *structuredResults {
+			return newStructuredResults(ctx, config.ScraperTarget, "US", "USD", "Target US")
+		},
+		parse: func(e *colly.HTMLElement) (models.Product, bool) {
+			product, ok := t.parseCard(e, sel)
+			if ok {
+				logger.Debug().Msgf("Found Target product: %s - %s", product.Name, product.Price)
 			}
+			return product, ok
+		},
+		onResponse: func(r *colly.Response) {
+			logger.Debug().Msgf("Target Response status: %d, Content-Length: %d", r.StatusCode, len(r.Body))
+			bodyStr := string(r.Body)
+			logger.Debug().Msgf("Page contains product data: %v", strings.Contains(bodyStr, "data-test") || strings.Contains(bodyStr, "product"))
+		},
+		// Additional delay between selector attempts
+		pause:  t.cfg.RetryDelay,
+		logger: logger,
+	}
+	attempt, failed := search.run(ctx)
+	if isBlocked(attempt.err) {
+		return attempt.products, attempt.err
+	}
+	products := attempt.results()
 
-			for _, nameSelector := range nameSelectors {
-				name := strings.TrimSpace(e.ChildText(nameSelector))
-				if name == "" {
-					// Try getting from aria-label or title attribute
-					name = strings.TrimSpace(e.ChildAttr(nameSelector, "aria-label"))
-					if name == "" {
-						name = strings.TrimSpace(e.ChildAttr(nameSelector, "title"))
-					}
-				}
-
-				if name != "" && len(name) > 5 && !t.isGenericTitle(name) {
-					product.Name = t.cleanProductName(name)
-					break
-				}
-			}
+	if len(products) == 0 && failed == len(sel.Cards) {
+		logger.Warn().Msgf("Target: No products found and all selectors failed for query: %s", query)
+		return products, fmt.Errorf("all Target scraping attempts failed")
+	}
 
-			if product.Name == "" {
-				return // Skip if no valid name found
-			}
+	if len(products) == 0 {
+		logger.Info().Msgf("Target: No products found for query: %s", query)
+	}
 
-			product.Price = t.extractPrice(e)
-			product.URL = t.extractURL(e)
-			product.Image = t.extractImage(e)
-			product.Rating = t.extractRating(e)
-			product.Reviews = t.extractReviews(e)
+	logger.Info().Msgf("Target found %d products", len(products))
+	return products, nil
+}
 
-			if product.Price != "" {
-				product.ID = fmt.Sprintf("target_us_%d", time.Now().UnixNano())
-				products = append(products, product)
-				log.Printf("Found Target product: %s - %s", product.Name, product.Price)
+func (t *TargetScraper) parseCard(e *colly.HTMLElement, sel SiteSelectors) (models.Product, bool) {
+	product := models.Product{
+		Source:    "Target US",
+		Currency:  "USD",
+		ScrapedAt: time.Now(),
+		InStock:   true,
+	}
+	applyRelease(&product, e.Text)
+
+	// Extract name with multiple fallback selectors
+	for _, nameSelector := range sel.Name {
+		name := strings.TrimSpace(e.ChildText(nameSelector))
+		if name == "" {
+			// Try getting from aria-label or title attribute
+			name = strings.TrimSpace(e.ChildAttr(nameSelector, "aria-label"))
+			if name == "" {
+				name = strings.TrimSpace(e.ChildAttr(nameSelector, "title"))
 			}
-		})
-
-		err := t.collector.Visit(searchURL)
-		if err != nil {
-			log.Printf("Error visiting Target with selector %s: %v", selector, err)
-			errorCount++
-			continue
 		}
 
-		// If we found products with this selector, break
-		if foundAny {
+		if name != "" && len(name) > 5 && !t.isGenericTitle(name) {
+			product.Name = t.cleanProductName(name)
 			break
 		}
-
-		// Reset collector for next selector attempt
-		t.collector = t.resetCollector()
-		time.Sleep(2 * time.Second) // Additional delay between selector attempts
-	}
-
-	if !foundAny && errorCount == len(selectors) {
-		log.Printf("Target: No products found and all selectors failed for query: %s", query)
-		return products, fmt.Errorf("all Target scraping attempts failed")
 	}
 
-	if !foundAny {
-		log.Printf("Target: No products found for query: %s", query)
+	if product.Name == "" {
+		return product, false // Skip if no valid name found
 	}
 
-	log.Printf("Target found %d products", len(products))
-	return products, nil
-}
-
-func (t *TargetScraper) resetCollector() *colly.Collector {
-	c := colly.NewCollector(
-		colly.AllowedDomains("target.com", "www.target.com"),
-	)
-
-	c.OnRequest(func(r *colly.Request) {
-		r.Headers.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
-		r.Headers.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8")
-		r.Headers.Set("Accept-Language", "en-US,en;q=0.5")
-	})
-
-	c.Limit(&colly.LimitRule{
-		DomainGlob:  "*target.*",
-		Parallelism: 1,
-		Delay:       3 * time.Second,
-	})
+	product.Price = t.extractPrice(e, sel)
+	product.URL = t.extractURL(e, sel)
+	product.Image = t.extractImage(e, sel)
+	product.Rating = t.extractRating(e, sel)
+	product.Reviews = t.extractReviews(e, sel)
 
-	return c
+	if product.Price == "" {
+		return product, false
+	}
+	applyDeal(&product, e, config.ScraperTarget)
+	applyCondition(&product, e, config.ScraperTarget)
+	applySeller(&product, e, config.ScraperTarget)
+	applyCategory(&product, e, config.ScraperTarget)
+	product.ID, product.SourceProductID = productid.For(config.ScraperTarget, "US", product.URL, product.Name)
+	return product, true
 }
 
 func (t *TargetScraper) getSearchURL(query string) string {
@@ -192,21 +191,8 @@ func (t *TargetScraper) getSearchURL(query string) string {
 	return fmt.Sprintf("https://www.target.com/s?searchTerm=%s", encodedQuery)
 }
 
-func (t *TargetScraper) extractPrice(e *colly.HTMLElement) string {
-	priceSelectors := []string{
-		"[data-test='product-price']",
-		"span[data-test='product-price']",
-		".price-current",
-		".sr-price",
-		"[aria-label*='current price']",
-		"[aria-label*='$']",
-		".h-text-red",
-		".styled__CurrentPrice-sc-108xfm0-0",
-		"span.h-text-sm.h-text-red",
-		".h-display-flex span",
-	}
-
-	for _, selector := range priceSelectors {
+func (t *TargetScraper) extractPrice(e *colly.HTMLElement, sel SiteSelectors) string {
+	for _, selector := range sel.Price {
 		price := strings.TrimSpace(e.ChildText(selector))
 		if price != "" {
 			formattedPrice := t.formatPrice(price)
@@ -225,16 +211,8 @@ func (t *TargetScraper) extractPrice(e *colly.HTMLElement) string {
 	return ""
 }
 
-func (t *TargetScraper) extractURL(e *colly.HTMLElement) string {
-	urlSelectors := []string{
-		"a[data-test='product-title']",
-		"h3 a",
-		".ProductCardImageWrapper a",
-		"a[aria-label]",
-		"a",
-	}
-
-	for _, selector := range urlSelectors {
+func (t *TargetScraper) extractURL(e *colly.HTMLElement, sel SiteSelectors) string {
+	for _, selector := range sel.URL {
 		relativeURL := e.ChildAttr(selector, "href")
 		if relativeURL != "" {
 			if strings.HasPrefix(relativeURL, "http") {
@@ -249,16 +227,8 @@ func (t *TargetScraper) extractURL(e *colly.HTMLElement) string {
 	return ""
 }
 
-func (t *TargetScraper) extractImage(e *colly.HTMLElement) string {
-	imageSelectors := []string{
-		"img[data-test='productImage']",
-		"img[src*='target.scene7.com']",
-		"img[alt*='product']",
-		"picture img",
-		"img",
-	}
-
-	for _, selector := range imageSelectors {
+func (t *TargetScraper) extractImage(e *colly.HTMLElement, sel SiteSelectors) string {
+	for _, selector := range sel.Image {
 		imgSrc := e.ChildAttr(selector, "src")
 		if imgSrc != "" && (strings.Contains(imgSrc, "target") || strings.Contains(imgSrc, "scene7")) {
 			return imgSrc
@@ -274,16 +244,8 @@ func (t *TargetScraper) extractImage(e *colly.HTMLElement) string {
 	return ""
 }
 
-func (t *TargetScraper) extractRating(e *colly.HTMLElement) string {
-	ratingSelectors := []string{
-		"[data-test='rating']",
-		"[aria-label*='star']",
-		".sr-rating",
-		".rating",
-		"span[aria-label*='out of 5']",
-	}
-
-	for _, selector := range ratingSelectors {
+func (t *TargetScraper) extractRating(e *colly.HTMLElement, sel SiteSelectors) string {
+	for _, selector := range sel.Rating {
 		rating := strings.TrimSpace(e.ChildText(selector))
 		if rating != "" {
 			return rating
@@ -302,15 +264,8 @@ func (t *TargetScraper) extractRating(e *colly.HTMLElement) string {
 	return ""
 }
 
-func (t *TargetScraper) extractReviews(e *colly.HTMLElement) string {
-	reviewSelectors := []string{
-		"[data-test='review-count']",
-		"a[aria-label*='review']",
-		".review-count",
-		"span[aria-label*='review']",
-	}
-
-	for _, selector := range reviewSelectors {
+func (t *TargetScraper) extractReviews(e *colly.HTMLElement, sel SiteSelectors) string {
+	for _, selector := range sel.Reviews {
 		reviews := strings.TrimSpace(e.ChildText(selector))
 		if reviews != "" {
 			return reviews
@@ -415,10 +370,5 @@ func (t *TargetScraper) cleanProductName(name string) string {
 	cleanName = strings.TrimSpace(cleanName)
 	cleanName = regexp.MustCompile(`\s+`).ReplaceAllString(cleanName, " ")
 
-	// Truncate if too long
-	if len(cleanName) > 100 {
-		cleanName = cleanName[:100] + "..."
-	}
-
 	return cleanName
 }
@@ -1,29 +1,68 @@
 package scrapers
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"net/http"
 	"regexp"
 	"strings"
 	"time"
 
 	"github.com/gocolly/colly/v2"
 	"github.com/gocolly/colly/v2/debug"
+	"github.com/rs/zerolog/log"
+	"price-comparison-api/internal/config"
 	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/productid"
+	"price-comparison-api/pkg/politeness"
+	"price-comparison-api/pkg/retry"
 )
 
 type WalmartScraper struct {
-	collector *colly.Collector
+	cfg       config.ScraperConfig
+	transport http.RoundTripper
+	ctx       context.Context
+	base      collectorBase
 }
 
-func NewWalmartScraper() *WalmartScraper {
-	c := colly.NewCollector(
-		colly.AllowedDomains("walmart.com", "www.walmart.com"),
-		colly.Debugger(&debug.LogDebugger{}),
-	)
+func NewWalmartScraper(cfg config.ScraperConfig) *WalmartScraper {
+	return &WalmartScraper{cfg: cfg}
+}
+
+// SetTransport replaces the HTTP transport used by the collector.
+func (w *WalmartScraper) SetTransport(transport http.RoundTripper) {
+	w.transport = transport
+	w.base.reset()
+}
+
+// SetContext bounds every page fetch by ctx.
+func (w *WalmartScraper) SetContext(ctx context.Context) {
+	w.ctx = ctx
+	w.base.reset()
+}
+
+func (w *WalmartScraper) newCollector() *colly.Collector {
+	c := w.base.clone(func() *colly.Collector {
+		c := colly.NewCollector(
+			colly.AllowedDomains("walmart.com", "www.walmart.com"),
+			colly.Debugger(&debug.LogDebugger{}),
+			colly.AllowURLRevisit(),
+		)
+		politeness.Limit(c, config.ScraperWalmart, w.cfg)
+		if w.transport != nil {
+			c.WithTransport(w.transport)
+		}
+		if w.ctx != nil {
+			c.Context = w.ctx
+		}
+		return c
+	})
+	fingerprintPages(c, config.ScraperWalmart, PageSearch)
+	detectBlocks(c, config.ScraperWalmart)
+	timePhases(c)
 
 	c.OnRequest(func(r *colly.Request) {
-		r.Headers.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
+		r.Headers.Set("User-Agent", w.cfg.UserAgent)
 		r.Headers.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8")
 		r.Headers.Set("Accept-Language", "en-US,en;q=0.5")
 		r.Headers.Set("Accept-Encoding", "gzip, deflate, br")
@@ -32,147 +71,109 @@ func NewWalmartScraper() *WalmartScraper {
 		r.Headers.Set("Upgrade-Insecure-Requests", "1")
 	})
 
-	c.Limit(&colly.LimitRule{
-		DomainGlob:  "*walmart.*",
-		Parallelism: 1,
-		Delay:       3 * time.Second,
-	})
-
 	c.OnError(func(r *colly.Response, err error) {
-		log.Printf("Walmart scraper error: %v", err)
+		log.Warn().Err(err).Str("scraper", config.ScraperWalmart).Msg("Walmart scraper error")
 	})
 
-	return &WalmartScraper{collector: c}
+	retry.Attach(c)
+
+	return c
 }
 
-func (w *WalmartScraper) Search(query, country string) ([]models.Product, error) {
-	// Always return empty slice instead of nil
-	products := make([]models.Product, 0)
+func (w *WalmartScraper) Search(ctx context.Context, query, country string) ([]models.Product, error) {
+	logger := scraperLogger(ctx, config.ScraperWalmart, query, country)
 
 	if strings.ToUpper(country) != "US" {
-		log.Printf("Walmart: Country %s not supported, returning empty results", country)
-		return products, nil
+		logger.Warn().Msgf("Walmart: Country %s not supported, returning empty results", country)
+		// Always return empty slice instead of nil
+		return make([]models.Product, 0), nil
 	}
 
 	searchURL := w.getSearchURL(query)
-	log.Printf("Searching Walmart (US) with URL: %s", searchURL)
+	logger.Info().Msgf("Searching Walmart (US) with URL: %s", searchURL)
 
 	// Multiple selector strategies for robustness
-	selectors := []string{
-		"[data-testid='item']",
-		"[data-automation-id='product-title']",
-		".search-result-gridview-item",
-		"[data-testid='list-view'] > div",
-		".mb0.ph1.pa0-xl.bb.b--near-white.w-25",
-		".search-result-listview-item",
-	}
-
-	foundAny := false
-	errorCount := 0
-
-	w.collector.OnResponse(func(r *colly.Response) {
-		log.Printf("Walmart Response status: %d, Content-Length: %d", r.StatusCode, len(r.Body))
-		bodyStr := string(r.Body)
-		log.Printf("Page contains product data: %v", strings.Contains(bodyStr, "data-testid") || strings.Contains(bodyStr, "search-result"))
-	})
-
-	for _, selector := range selectors {
-		log.Printf("Trying Walmart selector: %s", selector)
-
-		w.collector.OnHTML(selector, func(e *colly.HTMLElement) {
-			foundAny = true
-
-			product := models.Product{
-				Source:    "Walmart US",
-				Currency:  "USD",
-				ScrapedAt: time.Now(),
-				InStock:   true,
-			}
-
-			// Extract name with multiple fallback selectors
-			nameSelectors := []string{
-				"[data-automation-id='product-title']",
-				"span[data-automation-id='product-title']",
-				".normal.dark-gray.mb1",
-				"h3 a span",
-				".f6.f5-l.lh-title.dark-gray.mv1",
-				"a[data-testid='product-title']",
-				".w_DJ",
-			}
-
-			for _, nameSelector := range nameSelectors {
-				name := strings.TrimSpace(e.ChildText(nameSelector))
-				if name != "" && len(name) > 5 && !w.isGenericTitle(name) {
-					product.Name = w.cleanProductName(name)
-					break
-				}
+	sel := siteSelectors(config.ScraperWalmart)
+
+	search := cardSearch{
+		url:          searchURL,
+		cards:        sel.Cards,
+		newCollector: w.newCollector,
+		newStructured: func() *structuredResults {
+			return newStructuredResults(ctx, config.ScraperWalmart, "US", "USD", "Walmart US")
+		},
+		parse: func(e *colly.HTMLElement) (models.Product, bool) {
+			product, ok := w.parseCard(e, sel)
+			if ok {
+				logger.Debug().Msgf("Found Walmart product: %s - %s", product.Name, product.Price)
 			}
-
-			if product.Name == "" {
-				return // Skip if no valid name found
-			}
-
-			product.Price = w.extractPrice(e)
-			product.URL = w.extractURL(e)
-			product.Image = w.extractImage(e)
-			product.Rating = w.extractRating(e)
-			product.Reviews = w.extractReviews(e)
-
-			if product.Price != "" {
-				product.ID = fmt.Sprintf("walmart_us_%d", time.Now().UnixNano())
-				products = append(products, product)
-				log.Printf("Found Walmart product: %s - %s", product.Name, product.Price)
-			}
-		})
-
-		err := w.collector.Visit(searchURL)
-		if err != nil {
-			log.Printf("Error visiting Walmart with selector %s: %v", selector, err)
-			errorCount++
-			continue
-		}
-
-		// If we found products with this selector, break
-		if foundAny {
-			break
-		}
-
-		// Reset collector for next selector attempt
-		w.collector = w.resetCollector()
-		time.Sleep(2 * time.Second) // Additional delay between selector attempts
+			return product, ok
+		},
+		onResponse: func(r *colly.Response) {
+			logger.Debug().Msgf("Walmart Response status: %d, Content-Length: %d", r.StatusCode, len(r.Body))
+			bodyStr := string(r.Body)
+			logger.Debug().Msgf("Page contains product data: %v", strings.Contains(bodyStr, "data-testid") || strings.Contains(bodyStr, "search-result"))
+		},
+		// Additional delay between selector attempts
+		pause:  w.cfg.RetryDelay,
+		logger: logger,
 	}
+	attempt, failed := search.run(ctx)
+	if isBlocked(attempt.err) {
+		return attempt.products, attempt.err
+	}
+	products := attempt.results()
 
-	if !foundAny && errorCount == len(selectors) {
-		log.Printf("Walmart: No products found and all selectors failed for query: %s", query)
+	if len(products) == 0 && failed == len(sel.Cards) {
+		logger.Warn().Msgf("Walmart: No products found and all selectors failed for query: %s", query)
 		return products, fmt.Errorf("all Walmart scraping attempts failed")
 	}
 
-	if !foundAny {
-		log.Printf("Walmart: No products found for query: %s", query)
+	if len(products) == 0 {
+		logger.Info().Msgf("Walmart: No products found for query: %s", query)
 	}
 
-	log.Printf("Walmart found %d products", len(products))
+	logger.Info().Msgf("Walmart found %d products", len(products))
 	return products, nil
 }
 
-func (w *WalmartScraper) resetCollector() *colly.Collector {
-	c := colly.NewCollector(
-		colly.AllowedDomains("walmart.com", "www.walmart.com"),
-	)
+func (w *WalmartScraper) parseCard(e *colly.HTMLElement, sel SiteSelectors) (models.Product, bool) {
+	product := models.Product{
+		Source:    "Walmart US",
+		Currency:  "USD",
+		ScrapedAt: time.Now(),
+		InStock:   true,
+	}
+	applyRelease(&product, e.Text)
 
-	c.OnRequest(func(r *colly.Request) {
-		r.Headers.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
-		r.Headers.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8")
-		r.Headers.Set("Accept-Language", "en-US,en;q=0.5")
-	})
+	// Extract name with multiple fallback selectors
+	for _, nameSelector := range sel.Name {
+		name := strings.TrimSpace(e.ChildText(nameSelector))
+		if name != "" && len(name) > 5 && !w.isGenericTitle(name) {
+			product.Name = w.cleanProductName(name)
+			break
+		}
+	}
 
-	c.Limit(&colly.LimitRule{
-		DomainGlob:  "*walmart.*",
-		Parallelism: 1,
-		Delay:       3 * time.Second,
-	})
+	if product.Name == "" {
+		return product, false // Skip if no valid name found
+	}
 
-	return c
+	product.Price = w.extractPrice(e, sel)
+	product.URL = w.extractURL(e, sel)
+	product.Image = w.extractImage(e, sel)
+	product.Rating = w.extractRating(e, sel)
+	product.Reviews = w.extractReviews(e, sel)
+
+	if product.Price == "" {
+		return product, false
+	}
+	applyDeal(&product, e, config.ScraperWalmart)
+	applyCondition(&product, e, config.ScraperWalmart)
+	applySeller(&product, e, config.ScraperWalmart)
+	applyCategory(&product, e, config.ScraperWalmart)
+	product.ID, product.SourceProductID = productid.For(config.ScraperWalmart, "US", product.URL, product.Name)
+	return product, true
 }
 
 func (w *WalmartScraper) getSearchURL(query string) string {
@@ -180,22 +181,8 @@ func (w *WalmartScraper) getSearchURL(query string) string {
 	return fmt.Sprintf("https://www.walmart.com/search?q=%s", encodedQuery)
 }
 
-func (w *WalmartScraper) extractPrice(e *colly.HTMLElement) string {
-	priceSelectors := []string{
-		"[itemprop='price']",
-		"span[itemprop='price']",
-		".price-current",
-		".sr-price .visuallyhidden",
-		"[data-automation-id='product-price']",
-		".f2.b.dark-gray",
-		".price-group .price-current",
-		".arrange-fit.arrange-fill",
-		".price.display-inline-block.arrange-fit",
-		"span.price",
-		"[aria-label*='current price']",
-	}
-
-	for _, selector := range priceSelectors {
+func (w *WalmartScraper) extractPrice(e *colly.HTMLElement, sel SiteSelectors) string {
+	for _, selector := range sel.Price {
 		price := strings.TrimSpace(e.ChildText(selector))
 		if price != "" {
 			formattedPrice := w.formatPrice(price)
@@ -214,15 +201,8 @@ func (w *WalmartScraper) extractPrice(e *colly.HTMLElement) string {
 	return ""
 }
 
-func (w *WalmartScraper) extractURL(e *colly.HTMLElement) string {
-	urlSelectors := []string{
-		"a[data-testid='product-title']",
-		"h3 a",
-		"a[data-automation-id='product-title']",
-		"a",
-	}
-
-	for _, selector := range urlSelectors {
+func (w *WalmartScraper) extractURL(e *colly.HTMLElement, sel SiteSelectors) string {
+	for _, selector := range sel.URL {
 		relativeURL := e.ChildAttr(selector, "href")
 		if relativeURL != "" {
 			if strings.HasPrefix(relativeURL, "http") {
@@ -237,15 +217,8 @@ func (w *WalmartScraper) extractURL(e *colly.HTMLElement) string {
 	return ""
 }
 
-func (w *WalmartScraper) extractImage(e *colly.HTMLElement) string {
-	imageSelectors := []string{
-		"img[data-testid='productTileImage']",
-		"img[src*='i5.walmartimages.com']",
-		"img[alt*='product']",
-		"img",
-	}
-
-	for _, selector := range imageSelectors {
+func (w *WalmartScraper) extractImage(e *colly.HTMLElement, sel SiteSelectors) string {
+	for _, selector := range sel.Image {
 		imgSrc := e.ChildAttr(selector, "src")
 		if imgSrc != "" && strings.Contains(imgSrc, "walmart") {
 			return imgSrc
@@ -255,16 +228,8 @@ func (w *WalmartScraper) extractImage(e *colly.HTMLElement) string {
 	return ""
 }
 
-func (w *WalmartScraper) extractRating(e *colly.HTMLElement) string {
-	ratingSelectors := []string{
-		".average-rating",
-		"[data-testid='reviews-rating']",
-		".stars-reviews-count-node",
-		"span[aria-label*='star']",
-		".review-stars",
-	}
-
-	for _, selector := range ratingSelectors {
+func (w *WalmartScraper) extractRating(e *colly.HTMLElement, sel SiteSelectors) string {
+	for _, selector := range sel.Rating {
 		rating := strings.TrimSpace(e.ChildText(selector))
 		if rating != "" {
 			return rating
@@ -280,14 +245,8 @@ func (w *WalmartScraper) extractRating(e *colly.HTMLElement) string {
 	return ""
 }
 
-func (w *WalmartScraper) extractReviews(e *colly.HTMLElement) string {
-	reviewSelectors := []string{
-		"[data-testid='reviews-count']",
-		".reviews-count",
-		"span[aria-label*='review']",
-	}
-
-	for _, selector := range reviewSelectors {
+func (w *WalmartScraper) extractReviews(e *colly.HTMLElement, sel SiteSelectors) string {
+	for _, selector := range sel.Reviews {
 		reviews := strings.TrimSpace(e.ChildText(selector))
 		if reviews != "" {
 			return reviews
@@ -375,10 +334,5 @@ func (w *WalmartScraper) cleanProductName(name string) string {
 	cleanName = strings.TrimSpace(cleanName)
 	cleanName = regexp.MustCompile(`\s+`).ReplaceAllString(cleanName, " ")
 
-	// Truncate if too long
-	if len(cleanName) > 100 {
-		cleanName = cleanName[:100] + "..."
-	}
-
 	return cleanName
 }
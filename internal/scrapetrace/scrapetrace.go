@@ -0,0 +1,328 @@
+// Package scrapetrace records what a request's searches did, source by
+// source: the pages fetched and their status, which selectors matched, and
+// how many products survived filtering. Traces are kept briefly so support
+// can look a request ID up and see why a search returned what it did.
+package scrapetrace
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"price-comparison-api/internal/models"
+)
+
+// Trace is everything recorded for one request.
+type Trace struct {
+	RequestID string    `json:"request_id"`
+	Searches  []*Search `json:"searches"`
+
+	// mu guards the whole trace; sources record concurrently
+	mu sync.Mutex
+}
+
+// Search is one search run by the request.
+type Search struct {
+	Query   string          `json:"query"`
+	Country string          `json:"country"`
+	Page    int             `json:"page"`
+	Limit   int             `json:"limit"`
+	Filters *models.Filters `json:"filters,omitempty"`
+	Sort    *models.Sort    `json:"sort,omitempty"`
+	At      time.Time       `json:"at"`
+	// Cached is set when the search was answered from the cache and no
+	// source ran
+	Cached   bool      `json:"cached"`
+	Sources  []*Source `json:"sources"`
+	Duration string    `json:"duration"`
+	Error    string    `json:"error,omitempty"`
+
+	// Found counts the products the sources returned, Matched those left
+	// after filtering and Returned those on the requested page
+	Found    int `json:"found"`
+	Matched  int `json:"matched"`
+	Returned int `json:"returned"`
+	// Products are the products found, each marked if it was returned
+	Products []Product `json:"products,omitempty"`
+	// Removed lists what strict mode dropped, and why
+	Removed []models.RemovedProduct `json:"removed,omitempty"`
+
+	trace *Trace
+	start time.Time
+}
+
+// Source is one scraper's or API's part of a search.
+type Source struct {
+	Name string `json:"name"`
+	// Via is "api" when an official API answered, "cache" when the source's
+	// cached results did and "scrape" otherwise
+	Via       string        `json:"via"`
+	Fetches   []Fetch       `json:"fetches"`
+	Selectors []SelectorHit `json:"selectors"`
+	Products  int           `json:"products"`
+	Duration  string        `json:"duration"`
+	// Status is ok, failed, blocked for a source served a captcha or bot
+	// challenge, or circuit_open for one skipped after repeated failures
+	Status string `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+
+	trace *Trace
+	start time.Time
+}
+
+// Fetch is one page request.
+type Fetch struct {
+	URL string `json:"url"`
+	// Status is the HTTP status, 0 when no response arrived or the page was
+	// rendered
+	Status int `json:"status"`
+	// Rendered is set for pages loaded in headless Chrome
+	Rendered bool    `json:"rendered,omitempty"`
+	Duration string  `json:"duration"`
+	Phases   *Phases `json:"phases,omitempty"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// Phases splits the duration of a fetch: waiting out the retailer's
+// robots.txt crawl-delay, fetching the page, per-domain delay and retries
+// included, and extracting products from it.
+type Phases struct {
+	Wait    string `json:"wait"`
+	Fetch   string `json:"fetch"`
+	Extract string `json:"extract"`
+}
+
+// SelectorHit counts the elements a selector matched.
+type SelectorHit struct {
+	Selector string `json:"selector"`
+	Hits     int    `json:"hits"`
+}
+
+// Product is a found product as the trace shows it.
+type Product struct {
+	Source   string `json:"source"`
+	Name     string `json:"name"`
+	Price    string `json:"price"`
+	URL      string `json:"url,omitempty"`
+	Returned bool   `json:"returned"`
+}
+
+type traceKey struct{}
+type searchKey struct{}
+type sourceKey struct{}
+
+// WithTrace starts the trace of a request.
+func WithTrace(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, traceKey{}, &Trace{RequestID: requestID})
+}
+
+// FromContext returns the request's trace, or nil.
+func FromContext(ctx context.Context) *Trace {
+	t, _ := ctx.Value(traceKey{}).(*Trace)
+	return t
+}
+
+// Detach returns ctx without its trace, for work done on the request's
+// behalf that isn't part of what it was served.
+func Detach(ctx context.Context) context.Context {
+	if FromContext(ctx) == nil {
+		return ctx
+	}
+	ctx = context.WithValue(ctx, traceKey{}, (*Trace)(nil))
+	ctx = context.WithValue(ctx, searchKey{}, (*Search)(nil))
+	return context.WithValue(ctx, sourceKey{}, (*Source)(nil))
+}
+
+// StartSearch records a new search of the request. Without a trace in ctx
+// nothing is recorded and the returned search is nil, which every method
+// accepts.
+func StartSearch(ctx context.Context, params models.SearchParams) (context.Context, *Search) {
+	t := FromContext(ctx)
+	if t == nil {
+		return ctx, nil
+	}
+	s := &Search{
+		Query:   params.Query,
+		Country: params.Country,
+		Page:    params.Page,
+		Limit:   params.Limit,
+		Filters: params.Filters,
+		Sort:    params.Sort,
+		At:      time.Now().UTC(),
+		Sources: []*Source{},
+		trace:   t,
+		start:   time.Now(),
+	}
+	t.mu.Lock()
+	t.Searches = append(t.Searches, s)
+	t.mu.Unlock()
+	return context.WithValue(ctx, searchKey{}, s), s
+}
+
+// SetCached marks the search as answered from the cache.
+func (s *Search) SetCached() {
+	if s == nil {
+		return
+	}
+	s.trace.mu.Lock()
+	s.Cached = true
+	s.trace.mu.Unlock()
+}
+
+// SetResults records the found products and the response built from them.
+// When found is nil, as for a page answered whole from the cache, only the
+// page's products are known.
+func (s *Search) SetResults(found []models.Product, response *models.SearchResponse) {
+	if s == nil || response == nil {
+		return
+	}
+	if found == nil {
+		found = response.Products
+	}
+	returned := make(map[string]bool, len(response.Products))
+	for _, p := range response.Products {
+		returned[p.Source+"\x00"+p.URL+"\x00"+p.Name] = true
+	}
+
+	s.trace.mu.Lock()
+	defer s.trace.mu.Unlock()
+	s.Found = len(found)
+	s.Matched = response.Total
+	s.Returned = len(response.Products)
+	s.Products = make([]Product, len(found))
+	for i, p := range found {
+		s.Products[i] = Product{
+			Source:   p.Source,
+			Name:     p.Name,
+			Price:    p.Price,
+			URL:      p.URL,
+			Returned: returned[p.Source+"\x00"+p.URL+"\x00"+p.Name],
+		}
+	}
+	if response.Debug != nil {
+		s.Removed = response.Debug.Removed
+	}
+}
+
+// End records how long the search took and how it failed, if it did.
+func (s *Search) End(err error) {
+	if s == nil {
+		return
+	}
+	s.trace.mu.Lock()
+	defer s.trace.mu.Unlock()
+	s.Duration = time.Since(s.start).String()
+	if err != nil {
+		s.Error = err.Error()
+	}
+}
+
+// StartSource records a source of the search in ctx.
+func StartSource(ctx context.Context, name string) context.Context {
+	s, _ := ctx.Value(searchKey{}).(*Search)
+	if s == nil {
+		return ctx
+	}
+	src := &Source{
+		Name:      name,
+		Via:       "scrape",
+		Fetches:   []Fetch{},
+		Selectors: []SelectorHit{},
+		trace:     s.trace,
+		start:     time.Now(),
+	}
+	s.trace.mu.Lock()
+	s.Sources = append(s.Sources, src)
+	s.trace.mu.Unlock()
+	return context.WithValue(ctx, sourceKey{}, src)
+}
+
+func sourceFrom(ctx context.Context) *Source {
+	src, _ := ctx.Value(sourceKey{}).(*Source)
+	return src
+}
+
+// EndSource records the source's outcome.
+func EndSource(ctx context.Context, products int, err error) {
+	src := sourceFrom(ctx)
+	if src == nil {
+		return
+	}
+	src.trace.mu.Lock()
+	defer src.trace.mu.Unlock()
+	src.Products = products
+	src.Duration = time.Since(src.start).String()
+	if err != nil {
+		src.Error = err.Error()
+	}
+}
+
+// SetVia records how the source was searched: "api", "cache" or "scrape".
+func SetVia(ctx context.Context, via string) {
+	if src := sourceFrom(ctx); src != nil {
+		src.trace.mu.Lock()
+		src.Via = via
+		src.trace.mu.Unlock()
+	}
+}
+
+// SetStatus records how the source went.
+func SetStatus(ctx context.Context, status string) {
+	if src := sourceFrom(ctx); src != nil {
+		src.trace.mu.Lock()
+		src.Status = status
+		src.trace.mu.Unlock()
+	}
+}
+
+// RecordFetch records a page request of the source in ctx, and the phases
+// it took when known.
+func RecordFetch(ctx context.Context, pageURL string, status int, duration time.Duration, phases *Phases, err error) {
+	record(ctx, Fetch{URL: pageURL, Status: status, Duration: duration.String(), Phases: phases}, err)
+}
+
+// RecordRender records a page the source in ctx loaded in headless Chrome.
+func RecordRender(ctx context.Context, pageURL string, duration time.Duration, err error) {
+	record(ctx, Fetch{URL: pageURL, Rendered: true, Duration: duration.String()}, err)
+}
+
+func record(ctx context.Context, f Fetch, err error) {
+	src := sourceFrom(ctx)
+	if src == nil {
+		return
+	}
+	if err != nil {
+		f.Error = err.Error()
+	}
+	src.trace.mu.Lock()
+	src.Fetches = append(src.Fetches, f)
+	src.trace.mu.Unlock()
+}
+
+// RecordHit counts an element matched by selector.
+func RecordHit(ctx context.Context, selector string) {
+	src := sourceFrom(ctx)
+	if src == nil {
+		return
+	}
+	src.trace.mu.Lock()
+	defer src.trace.mu.Unlock()
+	for i := range src.Selectors {
+		if src.Selectors[i].Selector == selector {
+			src.Selectors[i].Hits++
+			return
+		}
+	}
+	src.Selectors = append(src.Selectors, SelectorHit{Selector: selector, Hits: 1})
+}
+
+// MarshalJSON encodes a consistent snapshot of the trace.
+func (t *Trace) MarshalJSON() ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return json.Marshal(struct {
+		RequestID string    `json:"request_id"`
+		Searches  []*Search `json:"searches"`
+	}{t.RequestID, t.Searches})
+}
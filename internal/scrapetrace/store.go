@@ -0,0 +1,57 @@
+package scrapetrace
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"price-comparison-api/pkg/cache"
+)
+
+// Store keeps finished traces in the cache for a while.
+type Store struct {
+	cache *cache.RedisCache
+	ttl   time.Duration
+}
+
+// NewStore returns a store keeping traces for ttl, or nil when no cache is
+// available to keep them in.
+func NewStore(c *cache.RedisCache, ttl time.Duration) *Store {
+	if c == nil {
+		return nil
+	}
+	return &Store{cache: c, ttl: ttl}
+}
+
+func key(requestID string) string {
+	return "scrapetrace:" + requestID
+}
+
+// Save stores t unless it recorded no search.
+func (s *Store) Save(ctx context.Context, t *Trace) error {
+	if s == nil || t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	empty := len(t.Searches) == 0
+	t.mu.Unlock()
+	if empty {
+		return nil
+	}
+
+	data, err := json.Marshal(t)
+	if err != nil {
+		return fmt.Errorf("json marshal error: %v", err)
+	}
+	return s.cache.Set(ctx, key(t.RequestID), data, s.ttl)
+}
+
+// Get returns the stored trace of a request as JSON, or nil when there is
+// none or it has expired.
+func (s *Store) Get(ctx context.Context, requestID string) (json.RawMessage, error) {
+	if s == nil {
+		return nil, nil
+	}
+	return s.cache.Get(ctx, key(requestID))
+}
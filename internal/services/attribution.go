@@ -0,0 +1,134 @@
+package services
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+)
+
+// retailer is who owns the data behind a product source.
+type retailer struct {
+	key      string // scraper name, used for terms_urls overrides
+	prefix   string // product sources start with it: "Amazon US", "Amazon (Chrome)"
+	name     string
+	termsURL string
+}
+
+// Longer prefixes first so "Google Shopping" doesn't fall through to a
+// shorter match
+var retailers = []retailer{
+	{config.ScraperGoogleShopping, "Google Shopping", "Google Shopping", "https://policies.google.com/terms"},
+	{config.ScraperSamsClub, "Sam's Club", "Sam's Club", ""},
+	{config.ScraperBestBuy, "Best Buy", "Best Buy", "https://www.bestbuy.com/site/help-topics/terms-and-conditions/pcmcat204400050067.c"},
+	{config.ScraperAliExpress, "AliExpress", "AliExpress", ""},
+	{config.ScraperMercadoLibre, "Mercado Libre", "Mercado Libre", ""},
+	{config.ScraperFlipkart, "Flipkart", "Flipkart", "https://www.flipkart.com/pages/terms"},
+	{config.ScraperWalmart, "Walmart", "Walmart", "https://www.walmart.com/help/article/walmart-com-terms-of-use/3b75080af40340d6bbd596f116fae5a0"},
+	{config.ScraperAmazon, "Amazon", "Amazon", "https://www.amazon.com/gp/help/customer/display.html?nodeId=508088"},
+	{config.ScraperTarget, "Target", "Target", "https://www.target.com/c/terms-conditions/-/N-4sr7l"},
+	{config.ScraperCostco, "Costco", "Costco", "https://www.costco.com/terms-and-conditions-of-use.html"},
+	{config.ScraperEtsy, "Etsy", "Etsy", "https://www.etsy.com/legal/terms-of-use"},
+	{config.ScraperEbay, "eBay", "eBay", "https://www.ebay.com/help/policies/member-behaviour-policies/user-agreement?id=4259"},
+	{"myntra", "Myntra", "Myntra", "https://www.myntra.com/termsofuse"},
+}
+
+// Attributor builds the attribution attached to results for users who
+// redistribute them. A nil Attributor attaches nothing.
+type Attributor struct {
+	cfg config.AttributionConfig
+}
+
+// NewAttributor returns nil when attribution is disabled.
+func NewAttributor(cfg config.AttributionConfig) *Attributor {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &Attributor{cfg: cfg}
+}
+
+// Source returns the attribution for one product source retrieved at the
+// given time.
+func (a *Attributor) Source(source string, retrievedAt time.Time) models.Attribution {
+	attr := models.Attribution{Retailer: source, Source: source, RetrievedAt: retrievedAt.UTC()}
+	if a == nil {
+		return attr
+	}
+	if r, ok := findRetailer(source); ok {
+		attr.Retailer, attr.TermsURL = r.name, r.termsURL
+		if u, ok := a.cfg.TermsURLs[r.key]; ok {
+			attr.TermsURL = u
+		}
+	}
+	if a.cfg.EmbedText {
+		attr.Text = a.text(attr)
+	}
+	return attr
+}
+
+// Retailer returns the scraper name and display name of the retailer behind
+// a product source, such as "amazon" and "Amazon" for "Amazon US". Unknown
+// sources are returned as both.
+func Retailer(source string) (key, name string) {
+	if r, ok := findRetailer(source); ok {
+		return r.key, r.name
+	}
+	return source, source
+}
+
+func findRetailer(source string) (retailer, bool) {
+	for _, r := range retailers {
+		if strings.HasPrefix(source, r.prefix) {
+			return r, true
+		}
+	}
+	return retailer{}, false
+}
+
+func (a *Attributor) text(attr models.Attribution) string {
+	terms := attr.TermsURL
+	if terms == "" {
+		terms = "see retailer"
+	}
+	return strings.NewReplacer(
+		"{retailer}", attr.Retailer,
+		"{source}", attr.Source,
+		"{retrieved_at}", attr.RetrievedAt.Format(time.RFC3339),
+		"{terms_url}", terms,
+	).Replace(a.cfg.Template)
+}
+
+// Products returns one attribution per source among products, retrieved at
+// the latest time any of its products was scraped, sorted by source.
+func (a *Attributor) Products(products []models.Product) []models.Attribution {
+	if a == nil || len(products) == 0 {
+		return nil
+	}
+	latest := make(map[string]time.Time)
+	counts := make(map[string]int)
+	for _, p := range products {
+		if p.Source == "" {
+			continue
+		}
+		counts[p.Source]++
+		if p.ScrapedAt.After(latest[p.Source]) {
+			latest[p.Source] = p.ScrapedAt
+		}
+	}
+	attrs := make([]models.Attribution, 0, len(counts))
+	for source, n := range counts {
+		attr := a.Source(source, latest[source])
+		attr.Products = n
+		attrs = append(attrs, attr)
+	}
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].Source < attrs[j].Source })
+	return attrs
+}
+
+// Attribution returns the search service's attributor, nil when
+// attribution is disabled.
+func (s *SearchService) Attribution() *Attributor {
+	return s.attribution
+}
@@ -0,0 +1,312 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/pkg/metrics"
+)
+
+// Canary statuses, besides the source statuses ok, failed and blocked
+const (
+	// CanaryEmpty is a search that worked but found fewer products than
+	// canary.min_products, the usual sign of selectors that stopped matching
+	CanaryEmpty       = "empty"
+	CanaryDisabled    = "disabled"
+	CanaryMaintenance = "maintenance"
+	// CanaryPending is a retailer whose canary hasn't run yet
+	CanaryPending = "pending"
+)
+
+// Overall canary health
+const (
+	CanaryHealthy  = "healthy"
+	CanaryDegraded = "degraded"
+	CanaryUnknown  = "unknown"
+)
+
+// canarySampleSize is how many product names a canary result keeps.
+const canarySampleSize = 3
+
+// canaryQueries are the built-in canary searches: everyday products each
+// retailer always lists, in a country it serves.
+var canaryQueries = map[string]config.CanaryQuery{
+	config.ScraperAmazon:         {Query: "usb c cable", Country: "US"},
+	config.ScraperEbay:           {Query: "usb c cable", Country: "US"},
+	config.ScraperFlipkart:       {Query: "usb c cable", Country: "IN"},
+	config.ScraperWalmart:        {Query: "paper towels", Country: "US"},
+	config.ScraperTarget:         {Query: "paper towels", Country: "US"},
+	config.ScraperBestBuy:        {Query: "usb c cable", Country: "US"},
+	config.ScraperGoogleShopping: {Query: "usb c cable", Country: "US"},
+	config.ScraperAliExpress:     {Query: "usb c cable", Country: "US"},
+	config.ScraperEtsy:           {Query: "coffee mug", Country: "US"},
+	config.ScraperCostco:         {Query: "paper towels", Country: "US"},
+	config.ScraperSamsClub:       {Query: "paper towels", Country: "US"},
+	config.ScraperMercadoLibre:   {Query: "cable usb c", Country: "MX"},
+}
+
+// CanaryResult is the last canary search of one retailer.
+type CanaryResult struct {
+	Scraper string `json:"scraper"`
+	Status  string `json:"status"`
+	Query   string `json:"query"`
+	Country string `json:"country"`
+	// Products is how many products the search found, and Sample the
+	// names of the first few
+	Products   int      `json:"products"`
+	Sample     []string `json:"sample,omitempty"`
+	DurationMS int64    `json:"duration_ms"`
+	Error      string   `json:"error,omitempty"`
+	// CheckedAt is when the search ran, and LastSuccess when one last found
+	// enough products
+	CheckedAt   *time.Time `json:"checked_at,omitempty"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	// Failures counts the unhealthy canary searches in a row
+	Failures int `json:"failures"`
+}
+
+// healthy reports whether the result doesn't need attention. Retailers that
+// are switched off or in maintenance aren't searched, so they don't count.
+func (r CanaryResult) healthy() bool {
+	switch r.Status {
+	case SourceOK, CanaryDisabled, CanaryMaintenance:
+		return true
+	}
+	return false
+}
+
+// CanaryReport is what GET /health/scrapers returns.
+type CanaryReport struct {
+	// Status is healthy when every searched retailer passed, degraded when
+	// any didn't, and unknown before the first run
+	Status   string         `json:"status"`
+	Enabled  bool           `json:"enabled"`
+	Interval string         `json:"interval,omitempty"`
+	Running  bool           `json:"running"`
+	LastRun  *time.Time     `json:"last_run,omitempty"`
+	Scrapers []CanaryResult `json:"scrapers"`
+}
+
+// CanaryRunner periodically runs a small search per retailer straight
+// through its scraper, bypassing the cache, official APIs and circuit
+// breaker, and keeps the last result of each.
+type CanaryRunner struct {
+	service *SearchService
+	cfg     config.CanaryConfig
+	stop    chan struct{}
+
+	// runMu lets one run through at a time
+	runMu sync.Mutex
+
+	mu      sync.Mutex
+	results map[string]*CanaryResult
+	lastRun time.Time
+	running bool
+}
+
+func NewCanaryRunner(service *SearchService, cfg config.CanaryConfig) *CanaryRunner {
+	r := &CanaryRunner{
+		service: service,
+		cfg:     cfg,
+		stop:    make(chan struct{}),
+		results: make(map[string]*CanaryResult, len(config.ScraperNames)),
+	}
+	for _, name := range config.ScraperNames {
+		q := r.query(name)
+		r.results[name] = &CanaryResult{Scraper: name, Status: CanaryPending, Query: q.Query, Country: q.Country}
+	}
+	return r
+}
+
+// Start runs the canaries now and then every interval, when enabled.
+func (r *CanaryRunner) Start() {
+	if !r.cfg.Enabled {
+		return
+	}
+	if r.service.cfg.Mock.Enabled {
+		log.Warn().Msg("Scraper canaries not started: mock mode doesn't scrape")
+		return
+	}
+	log.Info().Msgf("Scraper canaries enabled, interval: %s", r.cfg.Interval)
+	go func() {
+		ticker := time.NewTicker(r.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			r.Run(context.Background())
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (r *CanaryRunner) Stop() {
+	if r == nil || !r.cfg.Enabled {
+		return
+	}
+	close(r.stop)
+}
+
+// Run searches every retailer once, concurrently, and returns the report.
+// A call while a run is in progress waits for that run instead of
+// starting another.
+func (r *CanaryRunner) Run(ctx context.Context) CanaryReport {
+	if !r.runMu.TryLock() {
+		r.runMu.Lock()
+		r.runMu.Unlock()
+		return r.Report()
+	}
+	defer r.runMu.Unlock()
+
+	if !r.service.track() {
+		return r.Report()
+	}
+	defer r.service.inFlight.Done()
+
+	r.mu.Lock()
+	r.running = true
+	r.mu.Unlock()
+
+	// Canaries outlive the request that asked for them, but not shutdown
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+	go func() {
+		select {
+		case <-r.service.ctx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for _, name := range config.ScraperNames {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if rec := recover(); rec != nil {
+					log.Error().Str("scraper", name).Msgf("Canary search panic recovered: %v", rec)
+				}
+			}()
+			r.check(ctx, name)
+		}()
+	}
+	wg.Wait()
+
+	r.mu.Lock()
+	r.running = false
+	r.lastRun = time.Now()
+	r.mu.Unlock()
+	return r.Report()
+}
+
+// check runs the canary search of one retailer and records the result.
+func (r *CanaryRunner) check(ctx context.Context, name string) {
+	q := r.query(name)
+	result := CanaryResult{Scraper: name, Query: q.Query, Country: q.Country}
+
+	switch {
+	case !r.service.enabled(name):
+		result.Status = CanaryDisabled
+	case r.inMaintenance(name):
+		result.Status = CanaryMaintenance
+	default:
+		logger := log.With().Str("canary", name).Logger()
+		ctx, cancel := context.WithTimeout(logger.WithContext(ctx), r.cfg.Timeout)
+		defer cancel()
+
+		start := time.Now()
+		products, err := r.service.scraper(name).Search(ctx, q.Query, q.Country)
+		result.DurationMS = time.Since(start).Milliseconds()
+		result.Products = len(products)
+		for i := 0; i < len(products) && i < canarySampleSize; i++ {
+			result.Sample = append(result.Sample, products[i].Name)
+		}
+
+		result.Status = sourceStatus(err)
+		if err != nil {
+			result.Error = err.Error()
+		} else if len(products) < r.cfg.MinProducts {
+			result.Status = CanaryEmpty
+		}
+		if result.Status != SourceOK {
+			logger.Warn().Str("status", result.Status).Int("products", len(products)).Msgf("Canary search unhealthy: %s", result.Error)
+		}
+		metrics.ObserveCanary(name, result.Status == SourceOK, len(products))
+	}
+	r.record(result)
+}
+
+func (r *CanaryRunner) inMaintenance(name string) bool {
+	_, down := r.service.inMaintenance(name)
+	return down
+}
+
+// query is the canary search of a retailer, the configured one first.
+func (r *CanaryRunner) query(name string) config.CanaryQuery {
+	q := canaryQueries[name]
+	if override, ok := r.cfg.Queries[name]; ok {
+		q.Query = override.Query
+		if override.Country != "" {
+			q.Country = override.Country
+		}
+	}
+	return q
+}
+
+func (r *CanaryRunner) record(result CanaryResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	prev := r.results[result.Scraper]
+	now := time.Now()
+	result.LastSuccess = prev.LastSuccess
+	result.Failures = prev.Failures
+	switch {
+	case result.Status == SourceOK:
+		result.CheckedAt, result.LastSuccess = &now, &now
+		result.Failures = 0
+	case result.healthy():
+		// Not searched: keep when the last search ran
+		result.CheckedAt = prev.CheckedAt
+	default:
+		result.CheckedAt = &now
+		result.Failures++
+	}
+	r.results[result.Scraper] = &result
+}
+
+// Report returns the last result of every retailer.
+func (r *CanaryRunner) Report() CanaryReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report := CanaryReport{
+		Status:   CanaryHealthy,
+		Enabled:  r.cfg.Enabled,
+		Running:  r.running,
+		Scrapers: make([]CanaryResult, 0, len(r.results)),
+	}
+	if r.cfg.Enabled {
+		report.Interval = r.cfg.Interval.String()
+	}
+	if !r.lastRun.IsZero() {
+		lastRun := r.lastRun
+		report.LastRun = &lastRun
+	} else {
+		report.Status = CanaryUnknown
+	}
+	for _, name := range config.ScraperNames {
+		result := *r.results[name]
+		report.Scrapers = append(report.Scrapers, result)
+		if report.LastRun != nil && !result.healthy() {
+			report.Status = CanaryDegraded
+		}
+	}
+	return report
+}
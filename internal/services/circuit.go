@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"price-comparison-api/internal/apierr"
+	"price-comparison-api/internal/config"
+)
+
+// Source statuses, as reported per source in progress events, scrape
+// traces and search responses. Maintenance and disabled sources are only
+// ever skipped, so they appear in search responses alone.
+const (
+	SourceOK          = "ok"
+	SourceFailed      = "failed"
+	SourceBlocked     = "blocked"
+	SourceCircuitOpen = "circuit_open"
+	SourceMaintenance = "maintenance"
+	SourceDisabled    = "disabled"
+)
+
+// sourceStatus is the status of a source that finished with err.
+func sourceStatus(err error) string {
+	var e *apierr.Error
+	switch {
+	case err == nil:
+		return SourceOK
+	case errors.As(err, &e) && e.Code == SourceCircuitOpen:
+		return SourceCircuitOpen
+	case errors.Is(err, apierr.ErrBlocked):
+		return SourceBlocked
+	}
+	return SourceFailed
+}
+
+// Circuit states
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// CircuitStatus is where a retailer's circuit breaker stands.
+type CircuitStatus struct {
+	State string `json:"state"`
+	// Failures counts the retailer's consecutive failed searches
+	Failures int `json:"failures"`
+	// OpenUntil is when an open circuit lets a trial search through
+	OpenUntil *time.Time `json:"open_until,omitempty"`
+}
+
+// circuitBreaker skips a retailer that keeps failing. Threshold failed
+// searches in a row open it, and the retailer isn't searched for the
+// cooldown; then one trial search is let through (half open), which closes
+// the circuit when it succeeds and opens it again when it fails.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	state     string
+	failures  int
+	openUntil time.Time
+	probing   bool // a half-open trial search is running
+}
+
+func newCircuitBreaker(cfg config.CircuitBreakerConfig) *circuitBreaker {
+	return &circuitBreaker{threshold: cfg.FailureThreshold, cooldown: cfg.Cooldown, state: CircuitClosed}
+}
+
+// allow reports whether the retailer may be searched now; when it may not,
+// it returns when the circuit lets a trial search through.
+func (b *circuitBreaker) allow(now time.Time) (time.Time, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case CircuitOpen:
+		if now.Before(b.openUntil) {
+			return b.openUntil, false
+		}
+		b.state = CircuitHalfOpen
+		b.probing = true
+		return time.Time{}, true
+	case CircuitHalfOpen:
+		if b.probing {
+			return b.openUntil, false
+		}
+		b.probing = true
+	}
+	return time.Time{}, true
+}
+
+// record counts a search's outcome. It reports whether it opened the
+// circuit.
+func (b *circuitBreaker) record(ok bool, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if ok {
+		b.state = CircuitClosed
+		b.failures = 0
+		return false
+	}
+	b.failures++
+	if b.state == CircuitHalfOpen || b.failures >= b.threshold {
+		b.state = CircuitOpen
+		b.openUntil = now.Add(b.cooldown)
+		return true
+	}
+	return false
+}
+
+// release ends a trial search without counting it, so the next search is
+// the trial instead.
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+func (b *circuitBreaker) status() CircuitStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	st := CircuitStatus{State: b.state, Failures: b.failures}
+	if b.state != CircuitClosed {
+		until := b.openUntil
+		st.OpenUntil = &until
+	}
+	return st
+}
+
+// circuitAllows reports whether a retailer's circuit lets it be searched,
+// returning the error reported for it when it doesn't.
+func (s *SearchService) circuitAllows(name string) error {
+	b := s.circuits[name]
+	if b == nil {
+		return nil
+	}
+	if until, ok := b.allow(time.Now()); !ok {
+		return apierr.New(apierr.ErrUpstream, SourceCircuitOpen,
+			fmt.Sprintf("%s skipped after repeated failures until %s", name, until.Format(time.RFC3339)))
+	}
+	return nil
+}
+
+// recordCircuit counts a retailer's search in its circuit breaker. Searches
+// the client cancelled count neither way.
+func (s *SearchService) recordCircuit(ctx context.Context, name string, err error) {
+	b := s.circuits[name]
+	if b == nil {
+		return
+	}
+	if errors.Is(err, context.Canceled) {
+		b.release()
+		return
+	}
+	if b.record(err == nil, time.Now()) {
+		zerolog.Ctx(ctx).Warn().Err(err).Str("scraper", name).Dur("cooldown", b.cooldown).
+			Msg("Circuit opened, skipping source after repeated failures")
+	}
+}
+
+// Circuits returns each retailer's circuit breaker, or nil when circuit
+// breaking is off.
+func (s *SearchService) Circuits() map[string]CircuitStatus {
+	if len(s.circuits) == 0 {
+		return nil
+	}
+	out := make(map[string]CircuitStatus, len(s.circuits))
+	for name, b := range s.circuits {
+		out[name] = b.status()
+	}
+	return out
+}
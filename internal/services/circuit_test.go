@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"price-comparison-api/internal/apierr"
+	"price-comparison-api/internal/config"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	// A step either asks the breaker whether a search may run at a time,
+	// or records a search's outcome then
+	type step struct {
+		at      time.Duration
+		allow   bool // ask, expecting allowed
+		record  *bool
+		release bool
+		state   string // the state after the step
+	}
+	ok, failed := true, false
+	ask := func(at time.Duration, allowed bool, state string) step {
+		return step{at: at, allow: allowed, state: state}
+	}
+	rec := func(at time.Duration, outcome *bool, state string) step {
+		return step{at: at, record: outcome, state: state}
+	}
+
+	for _, tc := range []struct {
+		name  string
+		steps []step
+	}{
+		{"stays closed below the threshold", []step{
+			rec(0, &failed, CircuitClosed),
+			rec(0, &failed, CircuitClosed),
+			ask(0, true, CircuitClosed),
+		}},
+		{"a success resets the failures", []step{
+			rec(0, &failed, CircuitClosed),
+			rec(0, &failed, CircuitClosed),
+			rec(0, &ok, CircuitClosed),
+			rec(0, &failed, CircuitClosed),
+			rec(0, &failed, CircuitClosed),
+			ask(0, true, CircuitClosed),
+		}},
+		{"opens at the threshold", []step{
+			rec(0, &failed, CircuitClosed),
+			rec(0, &failed, CircuitClosed),
+			rec(0, &failed, CircuitOpen),
+			ask(time.Second, false, CircuitOpen),
+			ask(59*time.Second, false, CircuitOpen),
+		}},
+		{"half opens after the cooldown for one trial", []step{
+			rec(0, &failed, CircuitClosed),
+			rec(0, &failed, CircuitClosed),
+			rec(0, &failed, CircuitOpen),
+			ask(time.Minute, true, CircuitHalfOpen),
+			ask(time.Minute, false, CircuitHalfOpen),
+		}},
+		{"a successful trial closes it", []step{
+			rec(0, &failed, CircuitClosed),
+			rec(0, &failed, CircuitClosed),
+			rec(0, &failed, CircuitOpen),
+			ask(time.Minute, true, CircuitHalfOpen),
+			rec(time.Minute, &ok, CircuitClosed),
+			ask(time.Minute, true, CircuitClosed),
+			ask(time.Minute, true, CircuitClosed),
+		}},
+		{"a failed trial opens it again", []step{
+			rec(0, &failed, CircuitClosed),
+			rec(0, &failed, CircuitClosed),
+			rec(0, &failed, CircuitOpen),
+			ask(time.Minute, true, CircuitHalfOpen),
+			rec(time.Minute, &failed, CircuitOpen),
+			ask(time.Minute+time.Second, false, CircuitOpen),
+			ask(2*time.Minute, true, CircuitHalfOpen),
+		}},
+		{"a released trial lets the next search be the trial", []step{
+			rec(0, &failed, CircuitClosed),
+			rec(0, &failed, CircuitClosed),
+			rec(0, &failed, CircuitOpen),
+			ask(time.Minute, true, CircuitHalfOpen),
+			{at: time.Minute, release: true, state: CircuitHalfOpen},
+			ask(time.Minute, true, CircuitHalfOpen),
+			ask(time.Minute, false, CircuitHalfOpen),
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			start := time.Now()
+			b := newCircuitBreaker(config.CircuitBreakerConfig{FailureThreshold: 3, Cooldown: time.Minute})
+			for i, s := range tc.steps {
+				now := start.Add(s.at)
+				switch {
+				case s.record != nil:
+					b.record(*s.record, now)
+				case s.release:
+					b.release()
+				default:
+					if _, allowed := b.allow(now); allowed != s.allow {
+						t.Fatalf("step %d: allowed %v, want %v", i, allowed, s.allow)
+					}
+				}
+				if st := b.status(); st.State != s.state {
+					t.Fatalf("step %d: state %s, want %s", i, st.State, s.state)
+				}
+			}
+		})
+	}
+}
+
+func TestRecordCircuit(t *testing.T) {
+	s := &SearchService{circuits: map[string]*circuitBreaker{
+		config.ScraperAmazon: newCircuitBreaker(config.CircuitBreakerConfig{FailureThreshold: 1, Cooldown: time.Minute}),
+	}}
+	ctx := context.Background()
+
+	// Cancelled searches count neither way
+	s.recordCircuit(ctx, config.ScraperAmazon, context.Canceled)
+	if err := s.circuitAllows(config.ScraperAmazon); err != nil {
+		t.Fatalf("circuit open after a cancelled search: %v", err)
+	}
+
+	s.recordCircuit(ctx, config.ScraperAmazon, errors.New("timeout"))
+	err := s.circuitAllows(config.ScraperAmazon)
+	if got := sourceStatus(err); got != SourceCircuitOpen {
+		t.Errorf("status %q of the skipped source, want %q: %v", got, SourceCircuitOpen, err)
+	}
+	if !errors.Is(err, apierr.ErrUpstream) {
+		t.Errorf("%v is not an upstream error", err)
+	}
+
+	// Retailers without a breaker are always searched
+	if err := s.circuitAllows(config.ScraperEbay); err != nil {
+		t.Errorf("circuitAllows(ebay) = %v", err)
+	}
+}
@@ -0,0 +1,287 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"price-comparison-api/internal/apierr"
+	"price-comparison-api/internal/catalog"
+	"price-comparison-api/internal/pricefmt"
+	"price-comparison-api/internal/scrapers"
+	"price-comparison-api/pkg/utils"
+)
+
+// maxCompared bounds the products one comparison takes.
+const maxCompared = 10
+
+// maxCompareScrapes bounds the product pages scraped at once.
+const maxCompareScrapes = 4
+
+// Comparison is products side by side: a column per product and a row per
+// source, each cell the source's offer for that product.
+type Comparison struct {
+	Products []ComparedProduct `json:"products"`
+	Rows     []ComparisonRow   `json:"rows"`
+	// Currency is what totals are compared in
+	Currency string `json:"currency"`
+	// Cheapest is the lowest total of all, by product index and source
+	Cheapest *CheapestCell `json:"cheapest,omitempty"`
+	Duration string        `json:"duration"`
+}
+
+// ComparedProduct is a column of the comparison.
+type ComparedProduct struct {
+	// Ref is the catalog ID or URL the product was asked for by
+	Ref string `json:"ref"`
+	// CatalogID is set for catalog products and offers
+	CatalogID string `json:"catalog_id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Image     string `json:"image,omitempty"`
+	// Offers counts the product's cells
+	Offers int `json:"offers"`
+	// Cheapest is the source with the product's lowest total
+	Cheapest      string  `json:"cheapest,omitempty"`
+	CheapestTotal float64 `json:"cheapest_total,omitempty"`
+	Error         string  `json:"error,omitempty"`
+}
+
+// ComparisonRow is one source's offers, a cell per product in the order of
+// Products; nil where the source has no offer for it.
+type ComparisonRow struct {
+	Source string            `json:"source"`
+	Cells  []*ComparisonCell `json:"cells"`
+}
+
+// ComparisonCell is one source's offer for one product.
+type ComparisonCell struct {
+	Price    float64 `json:"price"`
+	Currency string  `json:"currency"`
+	// Shipping is nil when the source's shipping cost isn't known
+	Shipping *float64 `json:"shipping"`
+	// Total is price plus known shipping in the comparison's currency; 0
+	// when it can't be converted
+	Total        float64 `json:"total"`
+	Rating       float64 `json:"rating,omitempty"`
+	Availability string  `json:"availability"`
+	URL          string  `json:"url,omitempty"`
+	// Cheapest marks the product's lowest total, in stock if any is; the
+	// overall cheapest cell is also named in Comparison.Cheapest
+	Cheapest bool `json:"cheapest"`
+}
+
+// CheapestCell points at a cell of the comparison.
+type CheapestCell struct {
+	Product int     `json:"product"`
+	Source  string  `json:"source"`
+	Total   float64 `json:"total"`
+}
+
+// comparedOffer is a cell before it's placed in its row.
+type comparedOffer struct {
+	source string
+	cell   ComparisonCell
+}
+
+// Compare builds a comparison of refs: catalog product or offer IDs, looked
+// up in the catalog, and product page URLs, which are scraped. Totals are
+// compared in currency, or in the first offer's currency when it's empty.
+// A product that can't be found gets an error in its column instead of
+// failing the comparison.
+func (s *SearchService) Compare(ctx context.Context, refs []string, currency string) (*Comparison, error) {
+	startTime := time.Now()
+	if len(refs) == 0 {
+		return nil, apierr.Validation("missing_products", "ids or urls is required")
+	}
+	if len(refs) > maxCompared {
+		return nil, apierr.Validation("too_many_products", fmt.Sprintf("at most %d products can be compared", maxCompared))
+	}
+	currency = strings.ToUpper(currency)
+	if currency != "" {
+		if _, ok := s.cfg.Currency.Rates[currency]; !ok {
+			return nil, apierr.Validation("invalid_currency", fmt.Sprintf("no exchange rate for %s", currency))
+		}
+	}
+
+	products := make([]ComparedProduct, len(refs))
+	offers := make([][]comparedOffer, len(refs))
+	sem := make(chan struct{}, maxCompareScrapes)
+	var wg sync.WaitGroup
+	for i, ref := range refs {
+		products[i].Ref = ref
+		if !strings.HasPrefix(ref, "http://") && !strings.HasPrefix(ref, "https://") {
+			if err := s.compareCatalog(ref, &products[i], &offers[i]); err != nil {
+				products[i].Error = err.Error()
+			}
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if err := s.comparePage(ctx, ref, &products[i], &offers[i]); err != nil {
+				products[i].Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if currency == "" {
+		for _, list := range offers {
+			if len(list) > 0 {
+				currency = strings.ToUpper(list[0].cell.Currency)
+				break
+			}
+		}
+	}
+
+	result := &Comparison{Products: products, Rows: []ComparisonRow{}, Currency: currency}
+	rows := map[string]*ComparisonRow{}
+	var overall *ComparisonCell
+	for i, list := range offers {
+		var cheapest *ComparisonCell
+		for _, o := range list {
+			cell := o.cell
+			cell.Total = s.compareTotal(cell, currency)
+			row, ok := rows[o.source]
+			if !ok {
+				row = &ComparisonRow{Source: o.source, Cells: make([]*ComparisonCell, len(refs))}
+				rows[o.source] = row
+			}
+			// A source listing the product twice keeps its cheaper offer
+			if existing := row.Cells[i]; existing != nil && (cell.Total == 0 || existing.Total > 0 && existing.Total <= cell.Total) {
+				continue
+			}
+			row.Cells[i] = &cell
+		}
+		for _, row := range rows {
+			if cell := row.Cells[i]; cell != nil {
+				products[i].Offers++
+				if cheaperCell(cell, cheapest) {
+					cheapest, products[i].Cheapest = cell, row.Source
+				}
+			}
+		}
+		if cheapest != nil {
+			cheapest.Cheapest = true
+			products[i].CheapestTotal = cheapest.Total
+			if overall == nil || cheaperCell(cheapest, overall) {
+				overall = cheapest
+				result.Cheapest = &CheapestCell{Product: i, Source: products[i].Cheapest, Total: cheapest.Total}
+			}
+		}
+	}
+	for _, row := range rows {
+		result.Rows = append(result.Rows, *row)
+	}
+	sort.Slice(result.Rows, func(i, j int) bool { return result.Rows[i].Source < result.Rows[j].Source })
+
+	result.Duration = time.Since(startTime).String()
+	zerolog.Ctx(ctx).Info().Msgf("Compared %d products across %d sources", len(refs), len(result.Rows))
+	return result, nil
+}
+
+// compareCatalog fills a column from a catalog product's offers, or a single
+// catalog offer.
+func (s *SearchService) compareCatalog(id string, product *ComparedProduct, offers *[]comparedOffer) error {
+	if s.catalog == nil {
+		return fmt.Errorf("catalog ids need the catalog (CATALOG_ENABLED); send urls instead")
+	}
+	var list []catalog.Offer
+	if offer, err := s.catalog.Offer(id); err == nil {
+		product.CatalogID, product.Name, product.Image = offer.ProductID, offer.Name, offer.Image
+		list = []catalog.Offer{*offer}
+	} else if view, err := s.catalog.Get(id); err == nil {
+		product.CatalogID, product.Name, product.Image = view.ID, view.Name, view.Image
+		list = view.Offers
+	} else {
+		return fmt.Errorf("%s is not a catalog product or offer", id)
+	}
+	for _, o := range list {
+		availability := scrapers.AvailabilityOutOfStock
+		if o.InStock {
+			availability = scrapers.AvailabilityInStock
+		}
+		*offers = append(*offers, comparedOffer{source: o.Source, cell: ComparisonCell{
+			Price:        o.Price,
+			Currency:     o.Currency,
+			Availability: availability,
+			URL:          o.URL,
+		}})
+	}
+	return nil
+}
+
+// comparePage fills a column by scraping a product page.
+func (s *SearchService) comparePage(ctx context.Context, url string, product *ComparedProduct, offers *[]comparedOffer) error {
+	if _, err := scrapers.DetailScraperFor(url); err != nil {
+		return err
+	}
+	detail, err := s.ProductDetail(ctx, url)
+	if err != nil {
+		return err
+	}
+	product.CatalogID, product.Name, product.Image = detail.CatalogID, detail.Name, detail.Image
+	if detail.PriceValue <= 0 {
+		return fmt.Errorf("no price found on the product page")
+	}
+	*offers = append(*offers, comparedOffer{source: detail.Source, cell: ComparisonCell{
+		Price:        detail.PriceValue,
+		Currency:     detail.Currency,
+		Shipping:     shippingCost(detail.ShippingCost),
+		Rating:       detail.RatingValue,
+		Availability: detail.Availability,
+		URL:          detail.URL,
+	}})
+	return nil
+}
+
+// cheaperCell reports whether cell beats best as the cheapest: like
+// cheapestOffer, available offers beat unavailable ones, then the lower
+// total wins. Cells without a total never win.
+func cheaperCell(cell, best *ComparisonCell) bool {
+	if cell.Total <= 0 {
+		return false
+	}
+	if best == nil {
+		return true
+	}
+	inStock, bestInStock := cell.Availability == scrapers.AvailabilityInStock, best.Availability == scrapers.AvailabilityInStock
+	return (inStock && !bestInStock) || (inStock == bestInStock && cell.Total < best.Total)
+}
+
+// compareTotal is a cell's price plus known shipping, in currency.
+func (s *SearchService) compareTotal(cell ComparisonCell, currency string) float64 {
+	total := cell.Price
+	if cell.Shipping != nil {
+		total += *cell.Shipping
+	}
+	if strings.EqualFold(cell.Currency, currency) || cell.Currency == "" {
+		return pricefmt.Round(total, currency)
+	}
+	converted, ok := s.convertPrice(total, cell.Currency, currency)
+	if !ok {
+		return 0
+	}
+	return converted
+}
+
+// shippingCost reads a product page's shipping text: 0 when it's free, nil
+// when it names no amount.
+func shippingCost(text string) *float64 {
+	if text == "" {
+		return nil
+	}
+	cost := 0.0
+	if !strings.Contains(strings.ToLower(text), "free") {
+		if cost = utils.ParsePrice(text); cost <= 0 {
+			return nil
+		}
+	}
+	return &cost
+}
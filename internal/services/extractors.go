@@ -0,0 +1,363 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"price-comparison-api/internal/apierr"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/scrapetrace"
+	"price-comparison-api/pkg/tracing"
+)
+
+// Extractor sets a search can run with.
+const (
+	ExtractorStable = "stable"
+	ExtractorNext   = "next"
+)
+
+// maxComparisonsInFlight caps the background stable runs behind next-set
+// searches; extra comparisons are dropped.
+const maxComparisonsInFlight = 2
+
+// ExtractorComparison records how the next extractor set's results for a
+// search differed from the stable set's.
+type ExtractorComparison struct {
+	Query          string                  `json:"query"`
+	Country        string                  `json:"country"`
+	At             time.Time               `json:"at"`
+	StableCount    int                     `json:"stable_count"`
+	NextCount      int                     `json:"next_count"`
+	OnlyStable     []string                `json:"only_stable,omitempty"`
+	OnlyNext       []string                `json:"only_next,omitempty"`
+	PriceChanges   []ExtractorPriceChange  `json:"price_changes,omitempty"`
+	RankChanges    int                     `json:"rank_changes"`
+	Retailers      map[string]RetailerDiff `json:"retailers"`
+	StableDuration string                  `json:"stable_duration"`
+	NextDuration   string                  `json:"next_duration"`
+	Error          string                  `json:"error,omitempty"`
+}
+
+type ExtractorPriceChange struct {
+	Product string `json:"product"`
+	Stable  string `json:"stable"`
+	Next    string `json:"next"`
+}
+
+// RetailerDiff counts one source's products under each set.
+type RetailerDiff struct {
+	Stable int `json:"stable"`
+	Next   int `json:"next"`
+}
+
+// ExtractorReport sums up the recorded comparisons.
+type ExtractorReport struct {
+	ConfigFile string `json:"config_file"`
+	// Changed lists the retailers whose scraper settings differ between
+	// the sets; the others extract the same way under both
+	Changed []string `json:"changed"`
+	// Served counts searches answered by each set
+	Served   map[string]int `json:"served"`
+	Compared int            `json:"compared"`
+	Dropped  int            `json:"dropped"`
+	// Identical comparisons found the same products at the same prices
+	Identical    int `json:"identical"`
+	LostProducts int `json:"lost_products"`
+	NewProducts  int `json:"new_products"`
+	PriceChanges int `json:"price_changes"`
+	Errors       int `json:"errors"`
+	// Retailers totals each source's products over the stored comparisons
+	Retailers map[string]RetailerDiff `json:"retailers"`
+}
+
+// NextExtractors runs searches with the next extractor set, a SearchService
+// built from an overlay of the live config, and compares them with the
+// stable set.
+type NextExtractors struct {
+	service        *SearchService
+	configFile     string
+	changed        []string
+	maxComparisons int
+	inFlight       chan struct{}
+
+	mu          sync.Mutex
+	comparisons []ExtractorComparison
+	served      map[string]int
+	compared    int
+	dropped     int
+}
+
+// NewNextExtractors builds the next set from nextCfg. Like the shadow side,
+// it never reads or writes the cache.
+func NewNextExtractors(stableCfg, nextCfg *config.Config, opts config.ExtractorsConfig) *NextExtractors {
+	var changed []string
+	for _, name := range config.ScraperNames {
+		if !reflect.DeepEqual(stableCfg.Scraper(name), nextCfg.Scraper(name)) {
+			changed = append(changed, name)
+		}
+	}
+
+	log.Info().Msgf("Next extractor set loaded from %s; changed retailers: %s", opts.NextConfigFile, strings.Join(changed, ", "))
+
+	return &NextExtractors{
+		service:        NewSearchService(nextCfg, nil),
+		configFile:     opts.NextConfigFile,
+		changed:        changed,
+		maxComparisons: opts.MaxComparisons,
+		inFlight:       make(chan struct{}, maxComparisonsInFlight),
+		served:         map[string]int{ExtractorStable: 0, ExtractorNext: 0},
+	}
+}
+
+// SetNextExtractors makes the next extractor set available to searches.
+func (s *SearchService) SetNextExtractors(next *NextExtractors) {
+	s.next = next
+}
+
+// NextExtractors returns the next extractor set, or nil when none is
+// configured.
+func (s *SearchService) NextExtractors() *NextExtractors {
+	return s.next
+}
+
+// ParseExtractorSet checks a requested set name; empty means stable.
+func ParseExtractorSet(set string) (string, error) {
+	switch set = strings.ToLower(strings.TrimSpace(set)); set {
+	case "", ExtractorStable:
+		return ExtractorStable, nil
+	case ExtractorNext:
+		return ExtractorNext, nil
+	}
+	return "", apierr.Validation("invalid_extractor_set", fmt.Sprintf("unknown extractor set: %s. Valid sets: %s, %s", set, ExtractorStable, ExtractorNext))
+}
+
+// CountServed records that a search was answered by set.
+func (s *SearchService) CountServed(set string) {
+	if n := s.next; n != nil {
+		n.mu.Lock()
+		n.served[set]++
+		n.mu.Unlock()
+	}
+}
+
+// SearchNext runs a search with the next extractor set. Results aren't
+// cached or kept in a session; the stable set re-runs the search in the
+// background for the comparison report.
+func (s *SearchService) SearchNext(ctx context.Context, params models.SearchParams) (*models.SearchResponse, error) {
+	n := s.next
+	if n == nil {
+		return nil, apierr.Validation("extractor_set_unavailable", "no next extractor set is configured (EXTRACTORS_NEXT_CONFIG_FILE)")
+	}
+	if params.Country == "" {
+		params.Country = s.cfg.Server.DefaultCountry
+	}
+	s.CountServed(ExtractorNext)
+
+	response, err := n.service.SearchProducts(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case n.inFlight <- struct{}{}:
+	default:
+		n.mu.Lock()
+		n.dropped++
+		n.mu.Unlock()
+		return response, nil
+	}
+
+	// Keep the trace but not the request's cancellation. The stable search
+	// stays out of the request's scrape trace, which shows what was served.
+	ctx = withoutProgress(scrapetrace.Detach(context.WithoutCancel(ctx)))
+	go func() {
+		defer func() { <-n.inFlight }()
+		defer func() {
+			if rec := recover(); rec != nil {
+				log.Error().Msgf("Extractor comparison panic recovered: %v", rec)
+			}
+		}()
+
+		ctx, span := tracing.Start(ctx, "extractors.compare")
+		defer span.End()
+
+		stable, err := s.SearchProducts(ctx, params)
+		n.record(compareExtractors(params, stable, response, err))
+	}()
+	return response, nil
+}
+
+// CompareExtractors runs a search with both sets side by side, records the
+// comparison and returns it.
+func (s *SearchService) CompareExtractors(ctx context.Context, params models.SearchParams) (*ExtractorComparison, error) {
+	n := s.next
+	if n == nil {
+		return nil, apierr.Validation("extractor_set_unavailable", "no next extractor set is configured (EXTRACTORS_NEXT_CONFIG_FILE)")
+	}
+	// Validate once up front so a bad request isn't reported as a difference
+	if params.Country == "" {
+		params.Country = s.cfg.Server.DefaultCountry
+	}
+	if err := s.validateSearchParams(&params); err != nil {
+		return nil, err
+	}
+
+	var (
+		wg        sync.WaitGroup
+		next      *models.SearchResponse
+		nextErr   error
+		stable    *models.SearchResponse
+		stableErr error
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		next, nextErr = n.service.SearchProducts(ctx, params)
+	}()
+	stable, stableErr = s.SearchProducts(ctx, params)
+	wg.Wait()
+
+	if stableErr != nil {
+		return nil, stableErr
+	}
+	comparison := compareExtractors(params, stable, next, nextErr)
+	n.record(comparison)
+	return &comparison, nil
+}
+
+// compareExtractors compares the two sets' responses with compareResults,
+// stable standing in for the live side. err is whichever run failed.
+func compareExtractors(params models.SearchParams, stable, next *models.SearchResponse, err error) ExtractorComparison {
+	comparison := ExtractorComparison{
+		Query:     params.Query,
+		Country:   params.Country,
+		At:        time.Now(),
+		Retailers: map[string]RetailerDiff{},
+	}
+	if err != nil {
+		comparison.Error = err.Error()
+	}
+	if stable == nil {
+		return comparison
+	}
+
+	diff := compareResults(params, stable, next)
+	comparison.StableCount = diff.LiveCount
+	comparison.NextCount = diff.ShadowCount
+	comparison.OnlyStable = diff.OnlyLive
+	comparison.OnlyNext = diff.OnlyShadow
+	comparison.RankChanges = diff.RankChanges
+	comparison.StableDuration = stable.Duration
+	for _, change := range diff.PriceChanges {
+		comparison.PriceChanges = append(comparison.PriceChanges, ExtractorPriceChange{
+			Product: change.Product,
+			Stable:  change.Live,
+			Next:    change.Shadow,
+		})
+	}
+
+	for _, p := range stable.Products {
+		counts := comparison.Retailers[p.Source]
+		counts.Stable++
+		comparison.Retailers[p.Source] = counts
+	}
+	if next != nil {
+		comparison.NextDuration = next.Duration
+		for _, p := range next.Products {
+			counts := comparison.Retailers[p.Source]
+			counts.Next++
+			comparison.Retailers[p.Source] = counts
+		}
+	}
+	return comparison
+}
+
+// Shutdown stops the next set's service; see SearchService.Shutdown.
+func (n *NextExtractors) Shutdown(ctx context.Context) error {
+	if n == nil {
+		return nil
+	}
+	return n.service.Shutdown(ctx)
+}
+
+func (n *NextExtractors) record(comparison ExtractorComparison) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.compared++
+	n.comparisons = append(n.comparisons, comparison)
+	if over := len(n.comparisons) - n.maxComparisons; over > 0 {
+		n.comparisons = append([]ExtractorComparison(nil), n.comparisons[over:]...)
+	}
+}
+
+// Comparisons returns the recorded comparisons, newest first.
+func (n *NextExtractors) Comparisons() []ExtractorComparison {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	comparisons := make([]ExtractorComparison, 0, len(n.comparisons))
+	for i := len(n.comparisons) - 1; i >= 0; i-- {
+		comparisons = append(comparisons, n.comparisons[i])
+	}
+	return comparisons
+}
+
+// Report sums up the stored comparisons.
+func (n *NextExtractors) Report() ExtractorReport {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	report := ExtractorReport{
+		ConfigFile: n.configFile,
+		Changed:    append([]string{}, n.changed...),
+		Served:     make(map[string]int, len(n.served)),
+		Compared:   n.compared,
+		Dropped:    n.dropped,
+		Retailers:  map[string]RetailerDiff{},
+	}
+	for set, count := range n.served {
+		report.Served[set] = count
+	}
+	for _, c := range n.comparisons {
+		switch {
+		case c.Error != "":
+			report.Errors++
+		case len(c.OnlyStable) == 0 && len(c.OnlyNext) == 0 && len(c.PriceChanges) == 0:
+			report.Identical++
+		}
+		if len(c.OnlyStable) > 0 {
+			report.LostProducts++
+		}
+		if len(c.OnlyNext) > 0 {
+			report.NewProducts++
+		}
+		if len(c.PriceChanges) > 0 {
+			report.PriceChanges++
+		}
+		for source, counts := range c.Retailers {
+			total := report.Retailers[source]
+			total.Stable += counts.Stable
+			total.Next += counts.Next
+			report.Retailers[source] = total
+		}
+	}
+	return report
+}
+
+func (n *NextExtractors) Reset() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.comparisons = nil
+	n.compared = 0
+	n.dropped = 0
+	for set := range n.served {
+		n.served[set] = 0
+	}
+}
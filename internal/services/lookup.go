@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"price-comparison-api/internal/apierr"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/providers"
+	"price-comparison-api/internal/scrapers"
+)
+
+// Product code types accepted by Lookup.
+const (
+	CodeUPC  = "upc"
+	CodeEAN  = "ean"
+	CodeGTIN = "gtin"
+	CodeASIN = "asin"
+)
+
+// LookupOffer is one offer found for a product code.
+type LookupOffer struct {
+	models.Product
+	// Exact is set when the retailer matched the code itself (an official
+	// API's barcode lookup or the Amazon page of an ASIN), rather than the
+	// offer being found by searching for the code
+	Exact bool `json:"exact"`
+}
+
+// LookupResult is every offer found for a barcode or ASIN, cheapest first.
+type LookupResult struct {
+	Code        string               `json:"code"`
+	CodeType    string               `json:"code_type"`
+	Country     string               `json:"country"`
+	Offers      []LookupOffer        `json:"offers"`
+	Total       int                  `json:"total"`
+	Duration    string               `json:"duration"`
+	Attribution []models.Attribution `json:"attribution,omitempty"`
+}
+
+// Lookup resolves a UPC, EAN, GTIN-14 or ASIN to offers across sources.
+// Barcodes are looked up through the official APIs that support it, ASINs
+// through their Amazon page, and every enabled source is also searched for
+// the code; the offers are merged and de-duplicated by URL.
+func (s *SearchService) Lookup(ctx context.Context, rawCode, country string) (*LookupResult, error) {
+	startTime := time.Now()
+	code, codeType, err := parseProductCode(rawCode)
+	if err != nil {
+		return nil, err
+	}
+	if country == "" {
+		country = s.cfg.Server.DefaultCountry
+	}
+	country = strings.ToUpper(country)
+	if err := s.validateCountry(country); err != nil {
+		return nil, err
+	}
+	logger := zerolog.Ctx(ctx).With().Str("code", code).Str("country", country).Logger()
+
+	exactDone := make(chan []models.Product, 1)
+	go func() {
+		exactDone <- s.exactOffers(ctx, code, codeType, country, logger)
+	}()
+	searched, searchErr := s.Products(ctx, code, country)
+	exact := <-exactDone
+	if searchErr != nil && len(exact) == 0 {
+		return nil, searchErr
+	}
+	s.processProducts(exact)
+
+	offers := make([]LookupOffer, 0, len(exact)+len(searched))
+	seen := make(map[string]bool)
+	for i, products := range [][]models.Product{exact, searched} {
+		for _, p := range products {
+			if p.URL != "" && seen[p.URL] {
+				continue
+			}
+			seen[p.URL] = true
+			offers = append(offers, LookupOffer{Product: p, Exact: i == 0})
+		}
+	}
+	// Cheapest first; offers without a readable price go last
+	sort.SliceStable(offers, func(i, j int) bool {
+		pi, pj := offers[i].PriceValue, offers[j].PriceValue
+		if (pi > 0) != (pj > 0) {
+			return pi > 0
+		}
+		return pi < pj
+	})
+
+	products := make([]models.Product, len(offers))
+	for i, o := range offers {
+		products[i] = o.Product
+	}
+	logger.Info().Msgf("Lookup found %d offers, %d exact", len(offers), len(exact))
+	return &LookupResult{
+		Code:        code,
+		CodeType:    codeType,
+		Country:     country,
+		Offers:      offers,
+		Total:       len(offers),
+		Duration:    time.Since(startTime).String(),
+		Attribution: s.attribution.Products(products),
+	}, nil
+}
+
+// exactOffers returns the offers retailers matched to code itself in
+// country: the Amazon page of an ASIN, or the official APIs' barcode
+// lookups.
+func (s *SearchService) exactOffers(ctx context.Context, code, codeType, country string, logger zerolog.Logger) []models.Product {
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		exact []models.Product
+	)
+	addExact := func(products []models.Product) {
+		mu.Lock()
+		exact = append(exact, products...)
+		mu.Unlock()
+	}
+
+	if codeType == CodeASIN {
+		if s.enabled(config.ScraperAmazon) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				detail, err := s.ProductDetail(ctx, scrapers.AmazonProductURL(code, country))
+				if err != nil {
+					logger.Warn().Err(err).Msg("ASIN page lookup failed")
+					return
+				}
+				addExact([]models.Product{detail.Product})
+			}()
+		}
+	} else {
+		for name, provider := range s.providers {
+			lookup, ok := provider.(providers.CodeLookup)
+			if !ok || !provider.Covers(country) || !s.enabled(name) {
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				products, err := lookup.LookupGTIN(ctx, code, country)
+				if err != nil {
+					logger.Warn().Err(err).Str("scraper", name).Msg("Barcode lookup failed")
+					return
+				}
+				addExact(products)
+			}()
+		}
+	}
+	wg.Wait()
+	return exact
+}
+
+// parseProductCode normalizes a scanned code and tells its type: 8 or 13
+// digits are an EAN, 12 a UPC-A and 14 a GTIN-14, all checked against their
+// check digit; 10 letters and digits are an ASIN (or an ISBN-10, which
+// Amazon uses as the ASIN of books).
+func parseProductCode(raw string) (code, codeType string, err error) {
+	code = strings.ToUpper(strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(raw)))
+	if code == "" {
+		return "", "", apierr.Validation("invalid_code", "gtin is required")
+	}
+
+	if strings.Trim(code, "0123456789") == "" {
+		switch len(code) {
+		case 8, 13:
+			codeType = CodeEAN
+		case 12:
+			codeType = CodeUPC
+		case 14:
+			codeType = CodeGTIN
+		case 10:
+			return code, CodeASIN, nil
+		default:
+			return "", "", apierr.Validation("invalid_code", fmt.Sprintf("%s is not a UPC, EAN, GTIN-14 or ASIN", code))
+		}
+		if !validCheckDigit(code) {
+			return "", "", apierr.Validation("invalid_code", fmt.Sprintf("%s has an invalid check digit", code))
+		}
+		return code, codeType, nil
+	}
+
+	if len(code) == 10 && strings.Trim(code, "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ") == "" {
+		return code, CodeASIN, nil
+	}
+	return "", "", apierr.Validation("invalid_code", fmt.Sprintf("%s is not a UPC, EAN, GTIN-14 or ASIN", code))
+}
+
+// validCheckDigit checks a GS1 code: from the right, digits before the
+// check digit are weighted 3, 1, 3, ... and the total plus the check digit
+// must be a multiple of 10.
+func validCheckDigit(code string) bool {
+	sum := 0
+	for i := len(code) - 2; i >= 0; i-- {
+		d := int(code[i] - '0')
+		if (len(code)-2-i)%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	return (sum+int(code[len(code)-1]-'0'))%10 == 0
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"price-comparison-api/internal/mockdata"
+	"price-comparison-api/internal/models"
+)
+
+// mockSource is the source filter that asks for mock products whatever the
+// mode.
+const mockSource = "mock"
+
+// mockRequested reports whether a search asked for source=mock.
+func mockRequested(params models.SearchParams) bool {
+	return params.Filters != nil && strings.EqualFold(strings.TrimSpace(params.Filters.Source), mockSource)
+}
+
+// mockProducts returns the mock products of a search after the configured
+// latency, in place of scraping.
+func (s *SearchService) mockProducts(ctx context.Context, query, country string) ([]models.Product, error) {
+	if err := s.mockLatency(ctx); err != nil {
+		return nil, err
+	}
+	return mockdata.Products(query, country, s.cfg.Mock.Products), nil
+}
+
+// mockDetail returns the mock page of a product after the configured
+// latency, in place of scraping it. Like mock search results it stays out
+// of price history.
+func (s *SearchService) mockDetail(ctx context.Context, rawURL string) (*models.ProductDetail, error) {
+	if err := s.mockLatency(ctx); err != nil {
+		return nil, err
+	}
+	detail, err := mockdata.Detail(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	products := []models.Product{detail.Product}
+	s.processProducts(products)
+	detail.Product = products[0]
+	if s.attribution != nil {
+		attr := s.attribution.Source(detail.Source, detail.ScrapedAt)
+		detail.Attribution = &attr
+	}
+	return detail, nil
+}
+
+// mockLatency waits out the configured mock latency.
+func (s *SearchService) mockLatency(ctx context.Context) error {
+	if s.cfg.Mock.Latency <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(s.cfg.Mock.Latency)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// mockSearch answers a search with mock products generated on the spot:
+// searches that asked for source=mock outside mock mode, and quick
+// searches. Neither the cache nor the history and catalog stores are
+// touched.
+func (s *SearchService) mockSearch(ctx context.Context, params models.SearchParams, startTime time.Time) (*models.SearchResponse, []models.Product, error) {
+	products, err := s.mockProducts(ctx, params.Query, strings.ToUpper(params.Country))
+	if err != nil {
+		return nil, nil, err
+	}
+	s.processProducts(products)
+	return s.buildResponse(params, products, startTime), products, nil
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"price-comparison-api/internal/apierr"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/scrapers"
+)
+
+// SourceCoverage is a scraper's coverage of a country with its current
+// on/off state.
+type SourceCoverage struct {
+	scrapers.Coverage
+	Enabled bool `json:"enabled"`
+}
+
+// OnboardingReport is what it takes to serve a new country: which scrapers
+// already cover it, the currency and locale to expect, and a starter config.
+type OnboardingReport struct {
+	Country  string `json:"country"`
+	Known    bool   `json:"known"`
+	Currency string `json:"currency,omitempty"`
+	Locale   string `json:"locale,omitempty"`
+	// CurrencyRate is the configured rate for Currency, 0 when conversions
+	// into it aren't possible yet
+	CurrencyRate float64          `json:"currency_rate,omitempty"`
+	Sources      []SourceCoverage `json:"sources"`
+	Supported    int              `json:"supported"`
+	Warnings     []string         `json:"warnings,omitempty"`
+	// Config is a config.yaml stanza enabling the scrapers that cover the
+	// country
+	Config string `json:"config"`
+}
+
+// Onboarding reports how ready the service is to search country.
+func (s *SearchService) Onboarding(country string) (*OnboardingReport, error) {
+	country = strings.ToUpper(strings.TrimSpace(country))
+	if len(country) != 2 || strings.Trim(country, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		return nil, apierr.Validation("invalid_country", "country must be a two-letter code")
+	}
+
+	report := &OnboardingReport{Country: country, Sources: []SourceCoverage{}}
+	if market, ok := scrapers.KnownMarket(country); ok {
+		report.Known, report.Currency, report.Locale = true, market.Currency, market.Locale
+	} else {
+		report.Warnings = append(report.Warnings, "no scraper has a storefront for "+country+"; its currency and locale are unknown")
+	}
+	if report.Currency != "" {
+		report.CurrencyRate = s.cfg.Currency.Rates[report.Currency]
+		if report.CurrencyRate == 0 {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("no currency rate for %s (CURRENCY_RATES), prices can't be converted into it", report.Currency))
+		}
+	}
+
+	status := s.ScraperStatus()
+	for _, name := range config.ScraperNames {
+		cov := SourceCoverage{Coverage: scrapers.CountryCoverage(name, country), Enabled: status[name]}
+		if cov.Supported {
+			report.Supported++
+			if !cov.Enabled {
+				report.Warnings = append(report.Warnings, name+" covers "+country+" but is disabled")
+			}
+		}
+		if cov.Supported && report.Currency != "" && cov.Currency != report.Currency {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("%s prices %s in %s, not %s", name, country, cov.Currency, report.Currency))
+		}
+		report.Sources = append(report.Sources, cov)
+	}
+	if report.Supported == 0 {
+		report.Warnings = append(report.Warnings, "no scraper covers "+country+"; searches only reach fallback sites")
+	}
+	report.Config = s.onboardingConfig(report)
+	return report, nil
+}
+
+// onboardingConfig renders the server and scrapers sections of a config
+// file for a deployment serving the report's country. Scrapers that don't
+// cover it are left as comments.
+func (s *SearchService) onboardingConfig(report *OnboardingReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Starter config for %s", report.Country)
+	if report.Known {
+		fmt.Fprintf(&b, " (%s, %s)", report.Currency, report.Locale)
+	}
+	fmt.Fprintf(&b, "\nserver:\n  default_country: %s\n\nscrapers:\n", report.Country)
+	for _, src := range report.Sources {
+		if !src.Supported {
+			continue
+		}
+		sc := s.cfg.Scraper(src.Scraper)
+		fmt.Fprintf(&b, "  %s: # %s\n    enabled: true\n    delay: %s\n", src.Scraper, src.Site, sc.Delay)
+		if sc.Parallelism > 1 {
+			fmt.Fprintf(&b, "    parallelism: %d\n", sc.Parallelism)
+		}
+	}
+	for _, src := range report.Sources {
+		if src.Supported {
+			continue
+		}
+		note := "doesn't cover " + report.Country
+		if src.Note != "" {
+			note = src.Note
+		}
+		fmt.Fprintf(&b, "  # %s: %s\n", src.Scraper, note)
+	}
+	if report.Currency != "" && report.CurrencyRate == 0 {
+		fmt.Fprintf(&b, "\ncurrency:\n  rates:\n    # %s: <units per US dollar>\n", report.Currency)
+	}
+	return b.String()
+}
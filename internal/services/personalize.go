@@ -0,0 +1,154 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"price-comparison-api/internal/apierr"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/pkg/utils"
+)
+
+var currencySymbols = map[string]string{
+	"USD": "$", "EUR": "€", "GBP": "£", "INR": "₹", "JPY": "¥", "CAD": "CA$", "AUD": "A$",
+}
+
+// Whole words in a title that safe search hides. Strict also hides
+// everything moderate does.
+var (
+	explicitTerms = regexp.MustCompile(`(?i)\b(sex toys?|vibrators?|dildos?|fetish|bdsm|erotic|adult toys?|xxx|porn)\b`)
+	matureTerms   = regexp.MustCompile(`(?i)\b(lingerie|condoms?|lubricants?|whiske?y|vodka|wines?|beers?|cigars?|cigarettes?|e-cigarettes?|vapes?|tobacco|hookah)\b`)
+)
+
+// validatePreferences normalizes prefs and rejects currencies without a
+// configured rate.
+func (s *SearchService) validatePreferences(prefs *models.Preferences) error {
+	if prefs == nil {
+		return nil
+	}
+	prefs.Currency = strings.ToUpper(strings.TrimSpace(prefs.Currency))
+	if prefs.Currency != "" {
+		if _, ok := s.cfg.Currency.Rates[prefs.Currency]; !ok {
+			return apierr.Validation("invalid_preferences", fmt.Sprintf("unsupported currency: %s", prefs.Currency))
+		}
+	}
+	prefs.SafeSearch = strings.ToLower(strings.TrimSpace(prefs.SafeSearch))
+	if prefs.SafeSearch != "" && !contains(models.SafeSearchLevels, prefs.SafeSearch) {
+		return apierr.Validation("invalid_preferences", fmt.Sprintf("invalid safe_search: %s. Valid levels: %s", prefs.SafeSearch, strings.Join(models.SafeSearchLevels, ", ")))
+	}
+	return nil
+}
+
+// personalize drops excluded sellers and products hidden by safe search and
+// converts prices to the preferred currency. products is a private copy and
+// is modified in place.
+func (s *SearchService) personalize(products []models.Product, prefs *models.Preferences) []models.Product {
+	kept := products[:0]
+	for _, product := range products {
+		if excludedSeller(product, prefs.ExcludedSellers) || unsafeProduct(product, prefs.SafeSearch) {
+			continue
+		}
+		if prefs.Currency != "" && product.Currency != prefs.Currency {
+			s.convertProduct(&product, prefs.Currency)
+		}
+		kept = append(kept, product)
+	}
+	return kept
+}
+
+// excludedSeller matches the product's merchant and source against the
+// excluded names, ignoring case.
+func excludedSeller(product models.Product, excluded []string) bool {
+	merchant := strings.ToLower(product.Merchant)
+	source := strings.ToLower(product.Source)
+	for _, name := range excluded {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if merchant == name || strings.Contains(source, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// unsafeProduct reports whether the product's title has a term hidden at the
+// given safe search level.
+func unsafeProduct(product models.Product, level string) bool {
+	switch level {
+	case models.SafeSearchModerate:
+		return explicitTerms.MatchString(product.Name)
+	case models.SafeSearchStrict:
+		return explicitTerms.MatchString(product.Name) || matureTerms.MatchString(product.Name)
+	}
+	return false
+}
+
+// convertProduct rewrites the product's prices in currency, keeping the
+// retailer's own price in SourcePrice. Products in a currency without a rate
+// are left alone.
+func (s *SearchService) convertProduct(product *models.Product, currency string) {
+	from, ok := s.cfg.Currency.Rates[product.Currency]
+	to := s.cfg.Currency.Rates[currency]
+	if !ok || product.PriceValue <= 0 {
+		return
+	}
+	convert := func(value float64) float64 {
+		return math.Round(value/from*to*100) / 100
+	}
+
+	product.SourcePrice, product.SourceCurrency = product.Price, product.Currency
+	product.PriceValue = convert(product.PriceValue)
+	product.Price = formatPrice(product.PriceValue, currency)
+	product.Currency = currency
+	if product.OriginalPrice != "" {
+		product.OriginalPrice = formatPrice(convert(utils.ParsePrice(product.OriginalPrice)), currency)
+	}
+
+	offers := make([]models.Offer, len(product.Offers))
+	for i, offer := range product.Offers {
+		offer.PriceValue = convert(offer.PriceValue)
+		offer.Price = formatPrice(offer.PriceValue, currency)
+		offers[i] = offer
+	}
+	if len(offers) > 0 {
+		product.Offers = offers
+	}
+}
+
+func formatPrice(value float64, currency string) string {
+	symbol, ok := currencySymbols[currency]
+	if !ok {
+		symbol = currency + " "
+	}
+	if currency == "JPY" {
+		return fmt.Sprintf("%s%.0f", symbol, value)
+	}
+	return fmt.Sprintf("%s%.2f", symbol, value)
+}
+
+// rankPreferred moves products from preferred retailers to the front,
+// keeping the existing order within each group.
+func rankPreferred(products []models.Product, preferred []string) {
+	if len(preferred) == 0 {
+		return
+	}
+	isPreferred := func(p models.Product) bool {
+		source := strings.ToLower(p.Source)
+		merchant := strings.ToLower(p.Merchant)
+		for _, name := range preferred {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name != "" && (strings.Contains(source, name) || merchant == name) {
+				return true
+			}
+		}
+		return false
+	}
+	sort.SliceStable(products, func(i, j int) bool {
+		return isPreferred(products[i]) && !isPreferred(products[j])
+	})
+}
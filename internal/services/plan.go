@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/querynorm"
+	"price-comparison-api/internal/scrapers"
+)
+
+// modeAPI marks a request to a retailer's official API.
+const modeAPI = "api"
+
+// SearchPlan is what a search would do, worked out without scraping.
+type SearchPlan struct {
+	Query   string    `json:"query"`
+	Country string    `json:"country"`
+	Cache   PlanCache `json:"cache"`
+	// Scrapes is false when the search would be answered from the cache
+	Scrapes bool         `json:"scrapes"`
+	Sources []SourcePlan `json:"sources"`
+}
+
+// PlanCache lists the cache keys a search reads and writes.
+type PlanCache struct {
+	Available bool `json:"available"`
+	// SearchKey holds the finished page; personalized searches skip it
+	SearchKey string `json:"search_key,omitempty"`
+	// ProductsKey holds the unfiltered product set shared by every page
+	ProductsKey string `json:"products_key,omitempty"`
+	// Hit is "search" or "products" when that key is currently cached
+	Hit string `json:"hit,omitempty"`
+}
+
+// SourcePlan is how one scraper would take part in a search.
+type SourcePlan struct {
+	Scraper string `json:"scraper"`
+	Runs    bool   `json:"runs"`
+	// Reason says why a source doesn't run: disabled, maintenance,
+	// circuit_open or the country it doesn't cover
+	Reason string `json:"reason,omitempty"`
+	// MaintenanceUntil is when the maintenance window skipping the source
+	// ends
+	MaintenanceUntil *time.Time `json:"maintenance_until,omitempty"`
+	// CircuitOpenUntil is when the open circuit skipping the source lets a
+	// trial search through
+	CircuitOpenUntil *time.Time `json:"circuit_open_until,omitempty"`
+	// Mode is how the first request is made: api, colly or chrome
+	Mode     string             `json:"mode,omitempty"`
+	Requests []scrapers.Request `json:"requests,omitempty"`
+	Budget   SourceBudget       `json:"budget"`
+	// CacheKey holds the source's own results; Cached is set when they are
+	// cached now and the source wouldn't be searched again
+	CacheKey string `json:"cache_key,omitempty"`
+	Cached   bool   `json:"cached,omitempty"`
+}
+
+// SourceBudget is the pacing and time limits a source runs with.
+type SourceBudget struct {
+	Delay       string `json:"delay"`
+	Parallelism int    `json:"parallelism"`
+	RetryDelay  string `json:"retry_delay"`
+	// APITimeout bounds each official API request
+	APITimeout string `json:"api_timeout,omitempty"`
+	// RenderTimeout bounds each page rendered in Chrome
+	RenderTimeout string `json:"render_timeout,omitempty"`
+}
+
+// Plan explains which sources a search for params would run, how, against
+// which URLs, and which cache keys it would use. Nothing is scraped; the
+// cache is only read to tell whether the search would be a hit.
+func (s *SearchService) Plan(ctx context.Context, params models.SearchParams) (*SearchPlan, error) {
+	if params.Country == "" {
+		params.Country = s.cfg.Server.DefaultCountry
+	}
+	// Plan the query as search would run it
+	params.Query = querynorm.Normalize(params.Query)
+	if err := s.validateSearchParams(&params); err != nil {
+		return nil, err
+	}
+
+	country := strings.ToUpper(params.Country)
+	plan := &SearchPlan{
+		Query:   params.Query,
+		Country: country,
+		Cache:   s.planCache(ctx, params),
+		Sources: make([]SourcePlan, 0, len(config.ScraperNames)),
+	}
+	plan.Scrapes = plan.Cache.Hit == ""
+	for _, name := range config.ScraperNames {
+		sp := s.planSource(name, params.Query, country)
+		if sp.Runs && plan.Cache.Available {
+			sp.CacheKey = s.cache.GenerateSourceKey(params.Query, params.Country, name)
+			hit, err := s.cache.GetSearchResults(ctx, sp.CacheKey)
+			sp.Cached = err == nil && hit != nil
+		}
+		plan.Sources = append(plan.Sources, sp)
+	}
+	return plan, nil
+}
+
+// planCache mirrors the cache lookups in search.
+func (s *SearchService) planCache(ctx context.Context, params models.SearchParams) PlanCache {
+	var pc PlanCache
+	if s.cache == nil || !s.cache.IsAvailable() {
+		return pc
+	}
+	pc.Available = true
+	pc.ProductsKey = s.cache.GenerateProductsKey(params.Query, params.Country)
+	if params.Preferences.Empty() {
+		pc.SearchKey = s.cache.GenerateSearchKey(params)
+		if hit, err := s.cache.GetSearchResults(ctx, pc.SearchKey); err == nil && hit != nil {
+			pc.Hit = "search"
+			return pc
+		}
+	}
+	if set, err := s.cache.GetSearchResults(ctx, pc.ProductsKey); err == nil && set != nil {
+		pc.Hit = "products"
+	}
+	return pc
+}
+
+func (s *SearchService) planSource(name, query, country string) SourcePlan {
+	cfg := s.cfg.Scraper(name)
+	sp := SourcePlan{
+		Scraper: name,
+		Budget: SourceBudget{
+			Delay:       cfg.Delay.String(),
+			Parallelism: cfg.Parallelism,
+			RetryDelay:  cfg.RetryDelay.String(),
+		},
+	}
+	switch {
+	case !s.enabled(name):
+		sp.Reason = "disabled"
+		return sp
+	case !sourceCovers(name, country):
+		sp.Reason = fmt.Sprintf("does not cover %s", country)
+		return sp
+	}
+	if until, down := s.inMaintenance(name); down {
+		sp.Reason = "maintenance"
+		sp.MaintenanceUntil = &until
+		return sp
+	}
+	if b := s.circuits[name]; b != nil {
+		if st := b.status(); st.State == CircuitOpen && st.OpenUntil.After(time.Now()) {
+			sp.Reason = SourceCircuitOpen
+			sp.CircuitOpenUntil = st.OpenUntil
+			return sp
+		}
+	}
+	sp.Runs = true
+
+	sp.Requests = scrapers.PlanRequests(name, query, country, s.renderer != nil)
+	if provider, ok := s.providers[name]; ok && provider.Covers(country) {
+		// Scraping only happens when the API call fails
+		for i := range sp.Requests {
+			sp.Requests[i].Fallback = true
+		}
+		api := scrapers.Request{URL: provider.Endpoint(query, country), Mode: modeAPI}
+		sp.Requests = append([]scrapers.Request{api}, sp.Requests...)
+		sp.Budget.APITimeout = s.cfg.Providers.Timeout.String()
+	}
+	if len(sp.Requests) > 0 {
+		sp.Mode = sp.Requests[0].Mode
+	}
+	for _, r := range sp.Requests {
+		if r.Mode == scrapers.ModeChrome {
+			sp.Budget.RenderTimeout = s.cfg.Chrome.Timeout.String()
+		}
+	}
+	return sp
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+	"price-comparison-api/internal/apierr"
+	"price-comparison-api/internal/history"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/scrapers"
+	"price-comparison-api/pkg/tracing"
+)
+
+// ProductDetail scrapes a single product page with the detail scraper of
+// the retailer that owns the URL, or in mock mode returns its mock page.
+func (s *SearchService) ProductDetail(ctx context.Context, rawURL string) (*models.ProductDetail, error) {
+	name, err := scrapers.DetailScraperFor(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if s.cfg.Mock.Enabled {
+		return s.mockDetail(ctx, rawURL)
+	}
+	if !s.enabled(name) {
+		return nil, fmt.Errorf("scraper %s is disabled", name)
+	}
+	if until, down := s.inMaintenance(name); down {
+		return nil, apierr.New(apierr.ErrUpstream, "source_maintenance",
+			fmt.Sprintf("%s is in a maintenance window until %s", name, until.Format(time.RFC3339)))
+	}
+	if !s.track() {
+		return nil, fmt.Errorf("server is shutting down")
+	}
+	defer s.inFlight.Done()
+
+	// Bounded by the scrape timeout and aborted on shutdown
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Server.ScrapeTimeout)
+	defer cancel()
+	stop := context.AfterFunc(s.ctx, cancel)
+	defer stop()
+
+	ctx, span := tracing.Start(ctx, "scraper."+name+".detail",
+		attribute.String("scraper.name", name),
+		attribute.String("product.url", rawURL),
+	)
+	detail, err := scrapers.ScrapeDetail(ctx, s.cfg.Scraper(name), rawURL)
+	tracing.End(span, err)
+
+	logger := zerolog.Ctx(ctx).With().Str("scraper", name).Str("url", rawURL).Logger()
+	if err != nil {
+		logger.Warn().Err(err).Msg("Product detail scrape failed")
+		return nil, err
+	}
+	logger.Info().Msgf("Product detail scraped: %s (%s)", detail.Name, detail.Availability)
+	s.recordListing(ctx, rawURL, detail)
+	if s.attribution != nil {
+		attr := s.attribution.Source(detail.Source, detail.ScrapedAt)
+		detail.Attribution = &attr
+	}
+	return detail, nil
+}
+
+// recordListing passes what the page showed of a product in price history
+// to the history store, which notes title, image and seller changes. The
+// page may be known by the URL it was asked for or the one it reports.
+func (s *SearchService) recordListing(ctx context.Context, rawURL string, detail *models.ProductDetail) {
+	if s.history == nil {
+		return
+	}
+	urls := []string{rawURL}
+	if detail.URL != "" && detail.URL != rawURL {
+		urls = append(urls, detail.URL)
+	}
+	for _, u := range urls {
+		key := history.Key(detail.Country, models.Product{URL: u})
+		if err := s.history.RecordListing(key, detail.Name, detail.Image, detail.Seller); err != nil {
+			zerolog.Ctx(ctx).Warn().Err(err).Msg("Failed to record listing details")
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"price-comparison-api/internal/models"
+)
+
+// Progress event types, in the order a search sends them: each source
+// starts, sends its products as one batch and finishes, then the search is
+// done.
+const (
+	ProgressSourceStarted  = "source_started"
+	ProgressProductsBatch  = "products_batch"
+	ProgressSourceFinished = "source_finished"
+	ProgressDone           = "done"
+)
+
+// ProgressEvent is a step of a search in progress.
+type ProgressEvent struct {
+	Type   string `json:"type"`
+	Source string `json:"source,omitempty"`
+	// Products are a source's listings, before filtering and sorting
+	Products []models.Product `json:"products,omitempty"`
+	// Count is how many products the source found
+	Count int    `json:"count"`
+	Error string `json:"error,omitempty"`
+	// Status is how a finished source went: ok, failed, blocked or
+	// circuit_open
+	Status string `json:"status,omitempty"`
+	// Response is the finished search, sent with done
+	Response *models.SearchResponse `json:"response,omitempty"`
+	// Cancelled is set on the done event of a search the client cancelled
+	Cancelled bool   `json:"cancelled,omitempty"`
+	Elapsed   string `json:"elapsed"`
+}
+
+type progressKey struct{}
+
+type progress struct {
+	events chan<- ProgressEvent
+	start  time.Time
+}
+
+// WithProgress makes searches run with ctx send their progress to events.
+// Sends block until events is read or ctx is done, so the reader must keep
+// reading until the search returns.
+func WithProgress(ctx context.Context, events chan<- ProgressEvent) context.Context {
+	return context.WithValue(ctx, progressKey{}, &progress{events: events, start: time.Now()})
+}
+
+// withoutProgress returns ctx with no progress reporting, for work done on
+// a search's behalf after it returns.
+func withoutProgress(ctx context.Context) context.Context {
+	if ctx.Value(progressKey{}) == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, progressKey{}, (*progress)(nil))
+}
+
+// reportProgress sends event to the search's progress reader, if it has one.
+func reportProgress(ctx context.Context, event ProgressEvent) {
+	p, _ := ctx.Value(progressKey{}).(*progress)
+	if p == nil {
+		return
+	}
+	event.Elapsed = time.Since(p.start).Round(time.Millisecond).String()
+	select {
+	case p.events <- event:
+	case <-ctx.Done():
+	}
+}
+
+// reportSource sends a source's products and its finish. The products are
+// processed like the search's, on a copy.
+func (s *SearchService) reportSource(ctx context.Context, name string, products []models.Product, err error) {
+	if p, _ := ctx.Value(progressKey{}).(*progress); p == nil {
+		return
+	}
+	if len(products) > 0 {
+		batch := make([]models.Product, len(products))
+		copy(batch, products)
+		s.processProducts(batch)
+		reportProgress(ctx, ProgressEvent{Type: ProgressProductsBatch, Source: name, Products: batch, Count: len(batch)})
+	}
+	finished := ProgressEvent{Type: ProgressSourceFinished, Source: name, Count: len(products), Status: sourceStatus(err)}
+	if err != nil {
+		finished.Error = err.Error()
+	}
+	reportProgress(ctx, finished)
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"price-comparison-api/internal/models"
+)
+
+// Reasons the strict filter gives for dropping a product.
+const (
+	RemovedAccessory    = "accessory"
+	RemovedMissingTerm  = "missing_term"
+	RemovedTermOrder    = "term_order"
+	RemovedPriceOutlier = "price_outlier"
+)
+
+// accessoryKeywords mark listings that go with a product rather than being
+// it. A keyword the query itself contains doesn't count, so searching for
+// "iphone case" still finds cases.
+var accessoryKeywords = []string{
+	"case", "cover", "sleeve", "skin", "screen protector", "tempered glass", "protector",
+	"cable", "charger", "adapter", "mount", "holder", "strap", "replacement", "compatible",
+	"sticker", "decal", "pouch", "lanyard", "stylus", "lens protector", "wall plug",
+	"power bank", "refill", "cartridge", "spare",
+}
+
+// queryStopwords are left out when matching query terms against names.
+var queryStopwords = map[string]bool{
+	"a": true, "an": true, "the": true, "and": true, "or": true, "of": true,
+	"for": true, "with": true, "in": true, "on": true, "to": true, "new": true,
+}
+
+// Prices below strictPriceFloor or above strictPriceCeiling times the median
+// are outliers; it takes strictMinPriced priced products to tell.
+const (
+	strictPriceFloor   = 0.3
+	strictPriceCeiling = 4.0
+	strictMinPriced    = 4
+)
+
+// strictFilter drops results that don't really match query, in three
+// passes: accessories, names missing the query's terms or holding them out
+// of order, and prices far from the median of what's left. It returns the
+// products kept and why the others were removed.
+func strictFilter(query string, products []models.Product) ([]models.Product, []models.RemovedProduct) {
+	terms := nameTerms(query)
+	text := " " + strings.Join(terms, " ") + " "
+
+	kept := make([]models.Product, 0, len(products))
+	removed := []models.RemovedProduct{}
+	remove := func(p models.Product, reason, detail string) {
+		removed = append(removed, models.RemovedProduct{
+			Name:   p.Name,
+			Source: p.Source,
+			URL:    p.URL,
+			Price:  p.Price,
+			Reason: reason,
+			Detail: detail,
+		})
+	}
+
+	for _, p := range products {
+		name := nameTerms(p.Name)
+		if kw := accessoryKeyword(name, text); kw != "" {
+			remove(p, RemovedAccessory, fmt.Sprintf("name mentions %q, which the query doesn't", kw))
+			continue
+		}
+		if reason, detail := termMismatch(terms, name); reason != "" {
+			remove(p, reason, detail)
+			continue
+		}
+		kept = append(kept, p)
+	}
+
+	var prices []float64
+	for _, p := range kept {
+		if p.PriceValue > 0 {
+			prices = append(prices, p.PriceValue)
+		}
+	}
+	if len(prices) < strictMinPriced {
+		return kept, removed
+	}
+	median := medianOf(prices)
+
+	inBand := kept[:0]
+	for _, p := range kept {
+		switch {
+		case p.PriceValue > 0 && p.PriceValue < median*strictPriceFloor:
+			remove(p, RemovedPriceOutlier, fmt.Sprintf("%.2f is under %.0f%% of the median price %.2f", p.PriceValue, strictPriceFloor*100, median))
+		case p.PriceValue > median*strictPriceCeiling:
+			remove(p, RemovedPriceOutlier, fmt.Sprintf("%.2f is over %.0f times the median price %.2f", p.PriceValue, strictPriceCeiling, median))
+		default:
+			inBand = append(inBand, p)
+		}
+	}
+	return inBand, removed
+}
+
+// nameTerms lower-cases s and splits it into words, dropping stopwords.
+func nameTerms(s string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	terms := fields[:0]
+	for _, f := range fields {
+		if !queryStopwords[f] {
+			terms = append(terms, f)
+		}
+	}
+	return terms
+}
+
+// accessoryKeyword returns the accessory keyword in name that query (both
+// padded, space-joined terms) lacks, or "".
+func accessoryKeyword(name []string, query string) string {
+	text := " " + strings.Join(name, " ") + " "
+	for _, kw := range accessoryKeywords {
+		if mentions(text, kw) && !mentions(query, kw) {
+			return kw
+		}
+	}
+	return ""
+}
+
+// mentions reports whether padded text holds kw, singular or plural.
+func mentions(text, kw string) bool {
+	for _, form := range []string{kw, kw + "s", kw + "es"} {
+		if strings.Contains(text, " "+form+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// termMismatch checks that every query term is in name, in the query's
+// order: "iphone 15" matches "Apple iPhone 15 Pro" but not "15W charger
+// for iPhone" or "Pro 15 iPhone".
+func termMismatch(terms, name []string) (reason, detail string) {
+	next := 0
+	for i, term := range terms {
+		at := indexTerm(name, term, next)
+		if at < 0 {
+			if indexTerm(name, term, 0) >= 0 {
+				return RemovedTermOrder, fmt.Sprintf("%q comes before %q in the name", term, terms[i-1])
+			}
+			return RemovedMissingTerm, fmt.Sprintf("name doesn't contain %q", term)
+		}
+		next = at + 1
+	}
+	return "", ""
+}
+
+// indexTerm finds term in name from position from, allowing a plural "s".
+func indexTerm(name []string, term string, from int) int {
+	for i := from; i < len(name); i++ {
+		if w := name[i]; w == term || w == term+"s" || w+"s" == term {
+			return i
+		}
+	}
+	return -1
+}
+
+func medianOf(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
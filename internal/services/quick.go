@@ -0,0 +1,226 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"price-comparison-api/internal/catalog"
+	"price-comparison-api/internal/categories"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/pricefmt"
+	"price-comparison-api/internal/querynorm"
+)
+
+// quickBudget is how long a quick search may take. Whatever hasn't answered
+// by then is left out.
+const quickBudget = 3 * time.Second
+
+// maxQuickCatalogProducts bounds the catalog products a quick search takes
+// offers from, most recently seen first.
+const maxQuickCatalogProducts = 20
+
+// quickPart is what one of a quick search's sources found.
+type quickPart struct {
+	source   string
+	products []models.Product
+}
+
+// QuickSearch answers a search within quickBudget without scraping. A cached
+// response or product set is used as is; otherwise each source's cached
+// results, the official APIs of sources without any and the catalog's
+// offers are gathered, and whatever arrived in time is returned. No
+// retailer page is fetched or rendered, and nothing is cached, since the
+// result may be partial. The response's Source lists where products came
+// from.
+func (s *SearchService) QuickSearch(ctx context.Context, params models.SearchParams) (*models.SearchResponse, error) {
+	startTime := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, quickBudget)
+	defer cancel()
+
+	if params.Country == "" {
+		params.Country = s.cfg.Server.DefaultCountry
+	}
+	rawQuery := params.Query
+	params.Query = querynorm.Normalize(params.Query)
+	if err := s.validateSearchParams(&params); err != nil {
+		return nil, err
+	}
+	params.ResultSetID = ""
+	country := strings.ToUpper(params.Country)
+	if s.cfg.Mock.Enabled || mockRequested(params) {
+		response, _, err := s.mockSearch(ctx, params, startTime)
+		if response != nil {
+			echoQuery(response, rawQuery, params.Query)
+		}
+		return response, err
+	}
+	logger := zerolog.Ctx(ctx).With().Str("query", params.Query).Str("country", country).Logger()
+
+	useCache := s.cache != nil && s.cache.IsAvailable()
+	if useCache {
+		// Stale entries are still the quickest numbers there are
+		if params.Preferences.Empty() {
+			if hit, _, err := s.cache.LookupSearchResults(ctx, s.cache.GenerateSearchKey(params)); err == nil && hit != nil {
+				hit.Duration = fmt.Sprintf("%s (cached)", time.Since(startTime).String())
+				echoQuery(hit, rawQuery, params.Query)
+				return hit, nil
+			}
+		}
+		productsKey := s.cache.GenerateProductsKey(params.Query, params.Country)
+		if set, _, err := s.cache.LookupSearchResults(ctx, productsKey); err == nil && set != nil {
+			response := s.buildResponse(params, set.Products, startTime)
+			response.Duration = fmt.Sprintf("%s (cached)", response.Duration)
+			response.ResultSetID = set.ResultSetID
+			echoQuery(response, rawQuery, params.Query)
+			return response, nil
+		}
+	}
+
+	parts := make(chan quickPart, len(config.ScraperNames)+1)
+	var wg sync.WaitGroup
+	for _, name := range config.ScraperNames {
+		if !s.runs(name, country) {
+			continue
+		}
+		if useCache {
+			key := s.cache.GenerateSourceKey(params.Query, country, name)
+			if hit, err := s.cache.GetSearchResults(ctx, key); err == nil && hit != nil {
+				parts <- quickPart{source: name + " (cache)", products: hit.Products}
+				continue
+			}
+		}
+		provider, ok := s.providers[name]
+		if !ok || !provider.Covers(country) {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			products, err := provider.Search(ctx, params.Query, country)
+			if err != nil {
+				logger.Debug().Err(err).Str("scraper", name).Msg("Quick search API call failed")
+				return
+			}
+			parts <- quickPart{source: name + " (api)", products: products}
+		}()
+	}
+	if s.catalog != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			products, err := s.catalogOffers(params.Query, country)
+			if err != nil {
+				logger.Debug().Err(err).Msg("Quick search catalog lookup failed")
+				return
+			}
+			parts <- quickPart{source: "catalog", products: products}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	// Take what arrives until every source answered or the budget ran out
+	var received []quickPart
+collect:
+	for {
+		select {
+		case part := <-parts:
+			received = append(received, part)
+		case <-done:
+			for len(parts) > 0 {
+				received = append(received, <-parts)
+			}
+			break collect
+		case <-ctx.Done():
+			logger.Info().Msg("Quick search budget ran out; answering with what arrived")
+			break collect
+		}
+	}
+
+	// Live results come first, so a listing also in the catalog keeps its
+	// current price
+	var products []models.Product
+	var sources []string
+	seen := map[string]bool{}
+	for _, live := range []bool{true, false} {
+		for _, part := range received {
+			if (part.source != "catalog") != live {
+				continue
+			}
+			if live {
+				s.processProducts(part.products)
+			}
+			sources = append(sources, part.source)
+			for _, p := range part.products {
+				key := p.URL
+				if key == "" {
+					key = p.Source + ":" + p.Name
+				}
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				products = append(products, p)
+			}
+		}
+	}
+	if products == nil {
+		products = make([]models.Product, 0)
+	}
+
+	response := s.buildResponse(params, products, startTime)
+	response.Source = strings.Join(sources, ", ")
+	echoQuery(response, rawQuery, params.Query)
+	logger.Info().Msgf("Quick search found %d products from %d sources in %s", len(products), len(sources), response.Duration)
+	return response, nil
+}
+
+// catalogOffers returns the offers of the catalog products in country whose
+// name holds every word of query.
+func (s *SearchService) catalogOffers(query, country string) ([]models.Product, error) {
+	found, err := s.catalog.Find(catalog.Filter{Country: country, Query: query})
+	if err != nil {
+		return nil, err
+	}
+	if len(found) > maxQuickCatalogProducts {
+		found = found[:maxQuickCatalogProducts]
+	}
+	var products []models.Product
+	for _, product := range found {
+		view, err := s.catalog.Get(product.ID)
+		if err != nil {
+			continue
+		}
+		for _, offer := range view.Offers {
+			p := models.Product{
+				ID:         offer.ID,
+				CatalogID:  offer.ProductID,
+				Name:       offer.Name,
+				Title:      offer.Name,
+				PriceValue: offer.Price,
+				Currency:   offer.Currency,
+				URL:        offer.URL,
+				Image:      offer.Image,
+				Source:     offer.Source,
+				Merchant:   offer.Merchant,
+				InStock:    offer.InStock,
+				Condition:  offer.Condition,
+				Category:   categories.Detect(offer.Name),
+				ScrapedAt:  offer.LastSeen,
+			}
+			if offer.Price > 0 {
+				p.Price = pricefmt.Localize(offer.Price, offer.Currency, country)
+			}
+			products = append(products, p)
+		}
+	}
+	return products, nil
+}
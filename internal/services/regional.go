@@ -0,0 +1,273 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"price-comparison-api/internal/apierr"
+	"price-comparison-api/internal/catalog"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/pricefmt"
+	"price-comparison-api/internal/productid"
+)
+
+// maxRegionalLookups bounds the countries looked up at once.
+const maxRegionalLookups = 4
+
+// RegionalPrice is a product's cheapest exact offer in one country.
+type RegionalPrice struct {
+	Country string `json:"country"`
+	// Found is false when no retailer in the country matched the product
+	Found bool `json:"found"`
+	// Offer is the cheapest in-stock offer, or the cheapest one when none
+	// is in stock, in the retailer's currency
+	Offer *models.Product `json:"offer,omitempty"`
+	// Price is the offer's price in the report's currency, and LandedCost
+	// that plus shipping and import taxes to the home country when landed
+	// costs were asked for
+	Price      float64 `json:"price,omitempty"`
+	LandedCost float64 `json:"landed_cost,omitempty"`
+	// Difference is how much more than the cheapest country this one costs
+	Difference float64 `json:"difference"`
+	// SavingsPercent is how much cheaper than at home this country is;
+	// negative when it costs more
+	SavingsPercent float64 `json:"savings_percent"`
+	// Arbitrage is set when the savings reach the configured threshold
+	Arbitrage bool   `json:"arbitrage"`
+	Error     string `json:"error,omitempty"`
+}
+
+// RegionalResult compares a product's price across countries, cheapest
+// first, in one currency.
+type RegionalResult struct {
+	ID       string `json:"id"`
+	Code     string `json:"code"`
+	CodeType string `json:"code_type"`
+	Currency string `json:"currency"`
+	Home     string `json:"home"`
+	// Landed is set when prices include shipping and import taxes
+	Landed    bool            `json:"landed"`
+	Countries []RegionalPrice `json:"countries"`
+	Cheapest  string          `json:"cheapest,omitempty"`
+	Priciest  string          `json:"priciest,omitempty"`
+	// SpreadPercent is how much more the priciest country costs than the
+	// cheapest
+	SpreadPercent float64 `json:"spread_percent"`
+	// Arbitrage lists the countries worth buying from instead of home
+	Arbitrage []string `json:"arbitrage"`
+	Duration  string   `json:"duration"`
+}
+
+// Regional looks the product up by its GTIN or ASIN in each country and
+// compares the cheapest offers in currency, as landed costs to home when
+// landed is set. id is a GTIN or ASIN, an Amazon listing ID, or a catalog
+// product or offer with an Amazon offer.
+func (s *SearchService) Regional(ctx context.Context, id string, countries []string, currency string, landed bool, home string) (*RegionalResult, error) {
+	startTime := time.Now()
+	code, codeType, err := s.regionalCode(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(countries) == 0 {
+		countries = s.cfg.Regional.Countries
+	}
+	if home == "" {
+		home = s.cfg.Server.DefaultCountry
+	}
+	home = strings.ToUpper(home)
+	currency = strings.ToUpper(currency)
+	if _, ok := s.cfg.Currency.Rates[currency]; !ok {
+		return nil, apierr.Validation("invalid_currency", fmt.Sprintf("no exchange rate for %s", currency))
+	}
+
+	seen := map[string]bool{}
+	var unique []string
+	for _, country := range countries {
+		country = strings.ToUpper(strings.TrimSpace(country))
+		if country == "" || seen[country] {
+			continue
+		}
+		seen[country] = true
+		unique = append(unique, country)
+	}
+	logger := zerolog.Ctx(ctx).With().Str("code", code).Logger()
+
+	prices := make([]RegionalPrice, len(unique))
+	sem := make(chan struct{}, maxRegionalLookups)
+	var wg sync.WaitGroup
+	for i, country := range unique {
+		prices[i].Country = country
+		if err := s.validateCountry(country); err != nil {
+			prices[i].Error = err.Error()
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			offers := s.exactOffers(ctx, code, codeType, country, logger.With().Str("country", country).Logger())
+			s.processProducts(offers)
+			prices[i].Offer = cheapestOffer(offers)
+		}()
+	}
+	wg.Wait()
+
+	result := &RegionalResult{
+		ID:        id,
+		Code:      code,
+		CodeType:  codeType,
+		Currency:  currency,
+		Home:      home,
+		Landed:    landed,
+		Arbitrage: []string{},
+	}
+	for i := range prices {
+		p := &prices[i]
+		if p.Offer == nil {
+			continue
+		}
+		from := p.Offer.Currency
+		if from == "" {
+			from = pricefmt.Currency(p.Country)
+		}
+		price, ok := s.convertPrice(p.Offer.PriceValue, from, currency)
+		if !ok {
+			p.Error = fmt.Sprintf("no exchange rate for %s", from)
+			continue
+		}
+		p.Found, p.Price = true, price
+		if landed {
+			p.LandedCost = price
+			// Buying abroad adds shipping, and import taxes on top of both
+			if p.Country != home {
+				shipping, _ := s.convertPrice(s.cfg.Regional.Shipping, "USD", currency)
+				p.LandedCost = pricefmt.Round((price+shipping)*(1+s.cfg.Regional.ImportTax[home]), currency)
+			}
+		}
+	}
+
+	// Cheapest first; countries without a price go last
+	cost := func(p RegionalPrice) float64 {
+		if landed {
+			return p.LandedCost
+		}
+		return p.Price
+	}
+	sort.SliceStable(prices, func(i, j int) bool {
+		if prices[i].Found != prices[j].Found {
+			return prices[i].Found
+		}
+		return cost(prices[i]) < cost(prices[j])
+	})
+
+	var homeCost, cheapest, priciest float64
+	for _, p := range prices {
+		if !p.Found {
+			continue
+		}
+		if p.Country == home {
+			homeCost = cost(p)
+		}
+		if result.Cheapest == "" {
+			result.Cheapest, cheapest = p.Country, cost(p)
+		}
+		result.Priciest, priciest = p.Country, cost(p)
+	}
+	if cheapest > 0 {
+		result.SpreadPercent = math.Round((priciest-cheapest)/cheapest*1000) / 10
+	}
+	for i := range prices {
+		p := &prices[i]
+		if !p.Found {
+			continue
+		}
+		p.Difference = pricefmt.Round(cost(*p)-cheapest, currency)
+		if homeCost > 0 && p.Country != home {
+			p.SavingsPercent = math.Round((homeCost-cost(*p))/homeCost*1000) / 10
+			if p.SavingsPercent >= s.cfg.Regional.ArbitragePercent {
+				p.Arbitrage = true
+				result.Arbitrage = append(result.Arbitrage, p.Country)
+			}
+		}
+	}
+
+	result.Countries = prices
+	result.Duration = time.Since(startTime).String()
+	logger.Info().Msgf("Regional comparison priced %s in %d of %d countries", code, len(prices)-countMissing(prices), len(prices))
+	return result, nil
+}
+
+// regionalCode finds the GTIN or ASIN that identifies id's product in every
+// country.
+func (s *SearchService) regionalCode(id string) (code, codeType string, err error) {
+	if code, codeType, err := parseProductCode(id); err == nil {
+		return code, codeType, nil
+	}
+	if source, _, rest, ok := productid.Parse(id); ok && source == "amazon" {
+		if code, codeType, err := parseProductCode(rest); err == nil {
+			return code, codeType, nil
+		}
+	}
+
+	if s.catalog != nil {
+		var offers []catalog.Offer
+		if offer, err := s.catalog.Offer(id); err == nil {
+			offers = []catalog.Offer{*offer}
+		} else if product, err := s.catalog.Get(id); err == nil {
+			offers = product.Offers
+		}
+		for _, offer := range offers {
+			if asin := productid.Native("amazon", offer.URL); offer.Source == "amazon" && asin != "" {
+				return asin, CodeASIN, nil
+			}
+		}
+		if len(offers) > 0 {
+			return "", "", apierr.Validation("no_product_code", fmt.Sprintf("%s has no Amazon offer to match it across countries by; use its GTIN or ASIN", id))
+		}
+	}
+	return "", "", apierr.Validation("invalid_product_id", fmt.Sprintf("%s is not a GTIN, ASIN, Amazon listing or catalog product", id))
+}
+
+// convertPrice converts value between currencies at the configured rates,
+// rounded to to's minor unit.
+func (s *SearchService) convertPrice(value float64, from, to string) (float64, bool) {
+	fromRate, ok := s.cfg.Currency.Rates[strings.ToUpper(from)]
+	toRate, ok2 := s.cfg.Currency.Rates[to]
+	if !ok || !ok2 || fromRate <= 0 {
+		return 0, false
+	}
+	return pricefmt.Round(value/fromRate*toRate, to), true
+}
+
+// cheapestOffer picks the cheapest in-stock offer with a price, or the
+// cheapest out-of-stock one when none is in stock.
+func cheapestOffer(offers []models.Product) *models.Product {
+	var best *models.Product
+	for i := range offers {
+		o := &offers[i]
+		if o.PriceValue <= 0 {
+			continue
+		}
+		if best == nil || (o.InStock && !best.InStock) || (o.InStock == best.InStock && o.PriceValue < best.PriceValue) {
+			best = o
+		}
+	}
+	return best
+}
+
+func countMissing(prices []RegionalPrice) int {
+	missing := 0
+	for _, p := range prices {
+		if !p.Found {
+			missing++
+		}
+	}
+	return missing
+}
@@ -1,135 +1,667 @@
 package services
 
 import (
+	"context"
 	"fmt"
-	"log"
 	"math"
 	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"price-comparison-api/internal/apierr"
+	"price-comparison-api/internal/catalog"
+	"price-comparison-api/internal/categories"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/history"
 	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/providers"
+	"price-comparison-api/internal/querynorm"
 	"price-comparison-api/internal/scrapers"
+	"price-comparison-api/internal/scrapetrace"
 	"price-comparison-api/pkg/browser"
 	"price-comparison-api/pkg/cache"
+	"price-comparison-api/pkg/metrics"
+	"price-comparison-api/pkg/tracing"
 	"price-comparison-api/pkg/utils"
 )
 
 type SearchService struct {
-	amazonScraper   *scrapers.AmazonScraper
-	ebayScraper     *scrapers.EbayScraper
-	flipkartScraper *scrapers.FlipkartScraper
-	walmartScraper  *scrapers.WalmartScraper
-	targetScraper   *scrapers.TargetScraper
-	bestBuyScraper  *scrapers.BestBuyScraper
-	chromeScraper   *browser.ChromeScraper
-	cache           *cache.RedisCache
-}
-
-func NewSearchService() *SearchService {
-	return &SearchService{
-		amazonScraper:   scrapers.NewAmazonScraper(),
-		ebayScraper:     scrapers.NewEbayScraper(),
-		flipkartScraper: scrapers.NewFlipkartScraper(),
-		chromeScraper:   browser.NewChromeScraper(),
-		walmartScraper:  scrapers.NewWalmartScraper(),
-		targetScraper:   scrapers.NewTargetScraper(),
-		bestBuyScraper:  scrapers.NewBestBuyScraper(),
-		cache:           cache.NewRedisCache(),
-	}
-}
-
-func (s *SearchService) SearchProducts(params models.SearchParams) (*models.SearchResponse, error) {
+	amazonScraper     *scrapers.AmazonScraper
+	ebayScraper       *scrapers.EbayScraper
+	flipkartScraper   *scrapers.FlipkartScraper
+	walmartScraper    *scrapers.WalmartScraper
+	targetScraper     *scrapers.TargetScraper
+	bestBuyScraper    *scrapers.BestBuyScraper
+	googleScraper     *scrapers.GoogleShoppingScraper
+	aliExpressScraper *scrapers.AliExpressScraper
+	etsyScraper       *scrapers.EtsyScraper
+	costcoScraper     *scrapers.CostcoScraper
+	samsClubScraper   *scrapers.SamsClubScraper
+	mercadoLibre      *scrapers.MercadoLibreScraper
+	renderer          browser.Renderer
+	cache             *cache.RedisCache
+	cfg               *config.Config
+	shadow            *ShadowRunner
+	next              *NextExtractors
+	sessions          *SessionStore
+	history           *history.Store
+	catalog           *catalog.Store
+	attribution       *Attributor
+	// providers are official retailer APIs, keyed by the scraper they are
+	// preferred over
+	providers map[string]providers.Provider
+
+	// circuits skip retailers that keep failing, keyed by scraper; empty
+	// when circuit breaking is off
+	circuits map[string]*circuitBreaker
+
+	// enabledScrapers holds runtime on/off switches, seeded from config
+	enabledScrapers map[string]bool
+	enabledMu       sync.RWMutex
+
+	// revalidating holds the product set keys of stale queries being
+	// scraped again in the background
+	revalidating sync.Map
+
+	// ctx bounds every scraper request and is cancelled on shutdown
+	ctx      context.Context
+	cancel   context.CancelFunc
+	lifeMu   sync.Mutex
+	closing  bool
+	inFlight sync.WaitGroup
+}
+
+func NewSearchService(cfg *config.Config, redisCache *cache.RedisCache) *SearchService {
+	enabledScrapers := make(map[string]bool, len(config.ScraperNames))
+	for _, name := range config.ScraperNames {
+		enabledScrapers[name] = cfg.Scraper(name).Enabled
+	}
+
+	s := &SearchService{
+		amazonScraper:     scrapers.NewAmazonScraper(cfg.Scraper(config.ScraperAmazon)),
+		ebayScraper:       scrapers.NewEbayScraper(cfg.Scraper(config.ScraperEbay)),
+		flipkartScraper:   scrapers.NewFlipkartScraper(cfg.Scraper(config.ScraperFlipkart)),
+		walmartScraper:    scrapers.NewWalmartScraper(cfg.Scraper(config.ScraperWalmart)),
+		targetScraper:     scrapers.NewTargetScraper(cfg.Scraper(config.ScraperTarget)),
+		bestBuyScraper:    scrapers.NewBestBuyScraper(cfg.Scraper(config.ScraperBestBuy)),
+		googleScraper:     scrapers.NewGoogleShoppingScraper(cfg.Scraper(config.ScraperGoogleShopping)),
+		aliExpressScraper: scrapers.NewAliExpressScraper(cfg.Scraper(config.ScraperAliExpress)),
+		etsyScraper:       scrapers.NewEtsyScraper(cfg.Scraper(config.ScraperEtsy)),
+		costcoScraper:     scrapers.NewCostcoScraper(cfg.Scraper(config.ScraperCostco)),
+		samsClubScraper:   scrapers.NewSamsClubScraper(cfg.Scraper(config.ScraperSamsClub)),
+		mercadoLibre:      scrapers.NewMercadoLibreScraper(cfg.Scraper(config.ScraperMercadoLibre)),
+		cache:             redisCache,
+		cfg:               cfg,
+		enabledScrapers:   enabledScrapers,
+		attribution:       NewAttributor(cfg.Attribution),
+	}
+
+	if cfg.Circuit.Enabled {
+		s.circuits = make(map[string]*circuitBreaker, len(config.ScraperNames))
+		for _, name := range config.ScraperNames {
+			s.circuits[name] = newCircuitBreaker(cfg.Circuit)
+		}
+	}
+
+	var err error
+	if s.providers, err = providers.New(cfg.Providers); err != nil {
+		log.Warn().Err(err).Msg("Official API provider disabled, scraping instead")
+	}
+	for name := range s.providers {
+		log.Info().Msgf("Searching %s through its official API", name)
+	}
+	if cfg.Mock.Enabled {
+		log.Warn().Msg("Mock mode: searches return synthetic products instead of scraping")
+	}
+
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	for _, scraper := range []scrapers.Scraper{s.amazonScraper, s.ebayScraper, s.flipkartScraper, s.walmartScraper, s.targetScraper, s.bestBuyScraper, s.googleScraper, s.aliExpressScraper, s.etsyScraper, s.costcoScraper, s.samsClubScraper, s.mercadoLibre} {
+		scraper.SetContext(s.ctx)
+	}
+	if s.renderer, err = browser.NewRenderer(cfg.Chrome); err != nil {
+		log.Error().Err(err).Msg("Browser rendering disabled")
+	} else {
+		s.googleScraper.SetRenderer(browser.RenderFunc(s.renderer, cfg.Scraper(config.ScraperGoogleShopping).SolveCaptchas))
+		s.aliExpressScraper.SetRenderer(browser.RenderFunc(s.renderer, cfg.Scraper(config.ScraperAliExpress).SolveCaptchas))
+	}
+	return s
+}
+
+// Shutdown stops new scrapes, waits for in-flight ones until ctx expires,
+// then aborts whatever is left and closes the Chrome browser.
+func (s *SearchService) Shutdown(ctx context.Context) error {
+	s.lifeMu.Lock()
+	s.closing = true
+	s.lifeMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.inFlight.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("in-flight scrapes did not finish: %v", ctx.Err())
+	}
+	s.cancel()
+	if s.renderer != nil {
+		s.renderer.Close()
+	}
+
+	if shadowErr := s.shadow.Shutdown(ctx); shadowErr != nil && err == nil {
+		err = shadowErr
+	}
+	if nextErr := s.next.Shutdown(ctx); nextErr != nil && err == nil {
+		err = nextErr
+	}
+	return err
+}
+
+// track registers in-flight work (a scrape or a history write). It reports
+// false once shutdown has begun.
+func (s *SearchService) track() bool {
+	s.lifeMu.Lock()
+	defer s.lifeMu.Unlock()
+	if s.closing {
+		return false
+	}
+	s.inFlight.Add(1)
+	return true
+}
+
+func (s *SearchService) SearchProducts(ctx context.Context, params models.SearchParams) (*models.SearchResponse, error) {
+	response, _, err := s.search(ctx, params)
+	return response, err
+}
+
+// Products returns every listing found for a query, unfiltered, from the
+// cache when the query was searched recently.
+func (s *SearchService) Products(ctx context.Context, query, country string) ([]models.Product, error) {
+	response, products, err := s.search(ctx, models.SearchParams{Query: query, Country: country, Limit: 100})
+	if err != nil {
+		return nil, err
+	}
+	if products == nil {
+		// Only the finished first page was cached
+		products = response.Products
+	}
+	return products, nil
+}
+
+// search runs a search and also returns the full, unfiltered product set it
+// scraped or found in the cache. The product set is nil when the finished
+// response came from the cache.
+func (s *SearchService) search(ctx context.Context, params models.SearchParams) (response *models.SearchResponse, allProducts []models.Product, err error) {
 	startTime := time.Now()
 
-	// Set default country to IN (India) if not specified
+	ctx, span := tracing.Start(ctx, "search",
+		attribute.String("search.query", params.Query),
+		attribute.String("search.country", params.Country),
+		attribute.Int("search.page", params.Page),
+	)
+	cached := false
+	defer func() {
+		if response != nil {
+			span.SetAttributes(
+				attribute.Int("search.total", response.Total),
+				attribute.Bool("search.cached", cached),
+				attribute.Bool("search.personalized", response.Personalized),
+			)
+		}
+		tracing.End(span, err)
+	}()
+
+	// Fall back to the configured default country (IN unless overridden)
 	if params.Country == "" {
-		params.Country = "IN"
+		params.Country = s.cfg.Server.DefaultCountry
 	}
 
+	// Spellings of the same search scrape and cache as one; the response
+	// keeps the query as typed
+	rawQuery := params.Query
+	params.Query = querynorm.Normalize(params.Query)
+	defer func() {
+		if response != nil {
+			echoed := *response
+			echoQuery(&echoed, rawQuery, params.Query)
+			response = &echoed
+		}
+	}()
+
 	// Validate input
 	if err := s.validateSearchParams(&params); err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	if mockRequested(params) && !s.cfg.Mock.Enabled {
+		return s.mockSearch(ctx, params, startTime)
+	}
+
+	ctx, traced := scrapetrace.StartSearch(ctx, params)
+	defer func() {
+		traced.SetResults(allProducts, response)
+		traced.End(err)
+	}()
+
+	logger := zerolog.Ctx(ctx).With().Str("query", params.Query).Str("country", params.Country).Logger()
+
+	// Personalized responses are built from the shared product set and never
+	// cached themselves
+	personalized := !params.Preferences.Empty()
+	useCache := s.cache != nil && s.cache.IsAvailable()
+
+	// Debug searches scrape every source, so their diagnostics say how
+	// each is doing now
+	if params.Debug {
+		ctx = withoutCache(ctx)
+		useCache = false
+	}
+
+	if params.ResultSetID != "" {
+		cached = true
+		traced.SetCached()
+		return s.resultSet(ctx, params, startTime)
 	}
 
 	// Try cache first
-	cacheKey := ""
-	if s.cache != nil && s.cache.IsAvailable() {
+	cacheKey, productsKey := "", ""
+	var stale *models.SearchResponse
+	if useCache {
 		cacheKey = s.cache.GenerateSearchKey(params)
-		if cached, err := s.cache.GetSearchResults(cacheKey); err == nil && cached != nil {
-			cached.Duration = fmt.Sprintf("%s (cached)", time.Since(startTime).String())
-			log.Printf("Cache HIT for key: %s", cacheKey)
-			return cached, nil
+		productsKey = s.cache.GenerateProductsKey(params.Query, params.Country)
+		if !personalized {
+			hit, hitStale, err := s.cache.LookupSearchResults(ctx, cacheKey)
+			if err == nil && hit != nil && !hitStale {
+				hit.Duration = fmt.Sprintf("%s (cached)", time.Since(startTime).String())
+				logger.Debug().Msgf("Cache HIT for key: %s", cacheKey)
+				cached = true
+				traced.SetCached()
+				return hit, nil, nil
+			}
+			if hitStale {
+				// The product set may have been refreshed since
+				logger.Debug().Msgf("Cache STALE for key: %s", cacheKey)
+				stale = hit
+			} else {
+				logger.Debug().Msgf("Cache MISS for key: %s", cacheKey)
+			}
+		}
+
+		// Another page, filter or personalization of a query already scraped
+		if set, setStale, err := s.cache.LookupSearchResults(ctx, productsKey); err == nil && set != nil {
+			logger.Debug().Msgf("Cache HIT for product set: %s", productsKey)
+			cached = true
+			traced.SetCached()
+			response = s.buildResponse(params, set.Products, startTime)
+			response.Duration = fmt.Sprintf("%s (cached)", response.Duration)
+			response.ResultSetID = set.ResultSetID
+			response.SourcesStatus = set.SourcesStatus
+			if !personalized {
+				if err := s.cache.SetSearchResults(ctx, cacheKey, response); err != nil {
+					logger.Warn().Err(err).Msg("Failed to cache results")
+				}
+			}
+			if setStale {
+				s.revalidate(ctx, params)
+			}
+			return response, set.Products, nil
+		}
+		if stale != nil {
+			stale.Duration = fmt.Sprintf("%s (cached)", time.Since(startTime).String())
+			cached = true
+			traced.SetCached()
+			s.revalidate(ctx, params)
+			return stale, nil, nil
+		}
+
+		// The query failed on every source moments ago
+		if failure, err := s.cache.GetSearchFailure(ctx, s.cache.GenerateFailureKey(params.Query, params.Country)); err == nil && failure != nil {
+			logger.Debug().Msg("Cache HIT for search failure")
+			cached = true
+			traced.SetCached()
+			return nil, nil, failure
 		}
-		log.Printf("Cache MISS for key: %s", cacheKey)
 	}
 
-	// Cache miss or Redis unavailable - proceed with scraping
+	return s.scrape(ctx, params, startTime)
+}
+
+// scrape searches every source for params and caches the product set and,
+// unless it's personalized, the response. Empty and failed searches are
+// cached for the negative TTL only.
+func (s *SearchService) scrape(ctx context.Context, params models.SearchParams, startTime time.Time) (*models.SearchResponse, []models.Product, error) {
+	logger := zerolog.Ctx(ctx).With().Str("query", params.Query).Str("country", params.Country).Logger()
+	personalized := !params.Preferences.Empty()
+	useCache := s.cache != nil && s.cache.IsAvailable()
 	country := strings.ToUpper(params.Country)
 
-	allProducts := s.scrapeAllSources(params.Query, country)
+	allProducts, statuses, err := s.scrapeAllSources(ctx, params.Query, country)
+	if err != nil {
+		// A client that went away didn't see the retailers fail
+		if useCache && ctx.Err() == nil {
+			if err := s.cache.SetSearchFailure(ctx, s.cache.GenerateFailureKey(params.Query, params.Country), err); err != nil {
+				logger.Warn().Err(err).Msg("Failed to cache search failure")
+			}
+		}
+		return nil, nil, err
+	}
 	s.processProducts(allProducts)
-	filteredProducts := s.applyFilters(allProducts, params.Filters)
+	// Mock products aren't prices anyone saw, so they stay out of the stores
+	if !s.cfg.Mock.Enabled {
+		s.upsertCatalog(ctx, params.Query, country, allProducts)
+	}
+	response := s.buildResponse(params, allProducts, startTime)
+	response.SourcesStatus = statuses
+	if useCache {
+		response.ResultSetID = newID()
+	}
+
+	if !s.cfg.Mock.Enabled {
+		if !personalized {
+			s.shadow.Mirror(ctx, params, response)
+		}
+		s.recordHistory(params.Query, country, allProducts)
+	}
+
+	// Cache the product set, and the response unless it's personalized
+	if useCache {
+		set := s.cache.SetSearchResults
+		if len(allProducts) == 0 {
+			set = s.cache.SetNegativeResults
+		}
+		productsKey := s.cache.GenerateProductsKey(params.Query, params.Country)
+		resultSetKey := s.cache.GenerateResultSetKey(params.Query, params.Country, response.ResultSetID)
+		products := &models.SearchResponse{Query: params.Query, Products: allProducts, Total: len(allProducts), Source: response.Source, ResultSetID: response.ResultSetID, SourcesStatus: statuses}
+		for _, key := range []string{productsKey, resultSetKey} {
+			if err := set(ctx, key, products); err != nil {
+				logger.Warn().Err(err).Msg("Failed to cache product set")
+			}
+		}
+		if !personalized {
+			cacheKey := s.cache.GenerateSearchKey(params)
+			if err := set(ctx, cacheKey, response); err != nil {
+				logger.Warn().Err(err).Msg("Failed to cache results")
+			} else {
+				logger.Debug().Msgf("Cached results for key: %s", cacheKey)
+			}
+		}
+	}
+
+	return response, allProducts, nil
+}
+
+// resultSet builds the response for params from the result set it names,
+// so every page of a search comes from the same scrape in the same order.
+func (s *SearchService) resultSet(ctx context.Context, params models.SearchParams, startTime time.Time) (*models.SearchResponse, []models.Product, error) {
+	var set *models.SearchResponse
+	if s.cache != nil && s.cache.IsAvailable() {
+		key := s.cache.GenerateResultSetKey(params.Query, params.Country, params.ResultSetID)
+		set, _, _ = s.cache.LookupSearchResults(ctx, key)
+	}
+	if set == nil {
+		return nil, nil, apierr.Validation("result_set_expired",
+			fmt.Sprintf("result set %s has expired or belongs to another query; search again without result_set", params.ResultSetID))
+	}
+
+	response := s.buildResponse(params, set.Products, startTime)
+	response.Duration = fmt.Sprintf("%s (cached)", response.Duration)
+	response.ResultSetID = set.ResultSetID
+	response.SourcesStatus = set.SourcesStatus
+	return response, set.Products, nil
+}
+
+// revalidate scrapes the query of a stale cache hit again in the
+// background, once at a time per query, refreshing its cached product set
+// and the page params asked for.
+func (s *SearchService) revalidate(ctx context.Context, params models.SearchParams) {
+	key := s.cache.GenerateProductsKey(params.Query, params.Country)
+	if _, running := s.revalidating.LoadOrStore(key, struct{}{}); running {
+		return
+	}
+
+	// Keep the logger but not the request's cancellation or scrape trace
+	ctx = withoutProgress(scrapetrace.Detach(context.WithoutCancel(ctx)))
+	go func() {
+		defer s.revalidating.Delete(key)
+		defer func() {
+			if rec := recover(); rec != nil {
+				log.Error().Msgf("Cache revalidation panic recovered: %v", rec)
+			}
+		}()
+
+		ctx, span := tracing.Start(ctx, "cache.revalidate")
+		defer span.End()
+
+		if _, _, err := s.scrape(ctx, params, time.Now()); err != nil {
+			zerolog.Ctx(ctx).Warn().Err(err).Str("query", params.Query).Msg("Cache revalidation failed")
+		}
+	}()
+}
+
+// buildResponse filters, sorts and paginates a scraped product set. The input
+// slice is left untouched so it can be reused for later refinements.
+func (s *SearchService) buildResponse(params models.SearchParams, allProducts []models.Product, startTime time.Time) *models.SearchResponse {
+	products := make([]models.Product, len(allProducts))
+	copy(products, allProducts)
+
+	if !params.Preferences.Empty() {
+		products = s.personalize(products, params.Preferences)
+	}
+
+	// Strict filtering looks at every result, so the price median isn't
+	// skewed by the other filters
+	var debug *models.SearchDebug
+	if params.Filters.StrictOn() {
+		var removed []models.RemovedProduct
+		products, removed = strictFilter(params.Query, products)
+		debug = &models.SearchDebug{Removed: removed}
+	}
+
+	filteredProducts := s.applyFilters(products, params.Filters)
 	s.applySorting(filteredProducts, params.Sort)
+	if params.Sort == nil && params.Preferences != nil {
+		rankPreferred(filteredProducts, params.Preferences.PreferredRetailers)
+	}
 	paginatedProducts, totalPages := s.applyPagination(filteredProducts, params.Page, params.Limit)
+	for i := range paginatedProducts {
+		paginatedProducts[i].DisplayName = displayName(paginatedProducts[i].Title, s.cfg.Display.NameLength)
+	}
+	s.annotateTrends(paginatedProducts, params.Country)
+
+	return &models.SearchResponse{
+		Query:        params.Query,
+		Products:     paginatedProducts,
+		Total:        len(filteredProducts),
+		Page:         params.Page,
+		Limit:        params.Limit,
+		TotalPages:   totalPages,
+		Source:       sourceNames(allProducts),
+		Filters:      params.Filters,
+		Sort:         params.Sort,
+		Duration:     time.Since(startTime).String(),
+		Personalized: !params.Preferences.Empty(),
+		Attribution:  s.attribution.Products(paginatedProducts),
+		Summary:      summarize(filteredProducts),
+		Debug:        debug,
+	}
+}
+
+// sourceNames names the retailers a search's products came from, in
+// scraper order: the sources that ran and found something, never those
+// disabled, skipped for the country or behind an open circuit.
+func sourceNames(products []models.Product) string {
+	found := make(map[string]string)
+	for _, p := range products {
+		if p.Source != "" {
+			key, name := Retailer(p.Source)
+			found[key] = name
+		}
+	}
+	names := make([]string, 0, len(found))
+	for _, key := range config.ScraperNames {
+		if name, ok := found[key]; ok {
+			names = append(names, name)
+			delete(found, key)
+		}
+	}
+	// Sources without a scraper of their own follow, by name
+	var others []string
+	for _, name := range found {
+		others = append(others, name)
+	}
+	sort.Strings(others)
+	return strings.Join(append(names, others...), ", ")
+}
+
+// SetHistory makes every scraped search feed the price history store.
+func (s *SearchService) SetHistory(store *history.Store) {
+	s.history = store
+}
+
+// History returns the price history store, or nil when it's disabled.
+func (s *SearchService) History() *history.Store {
+	return s.history
+}
+
+func (s *SearchService) recordHistory(query, country string, products []models.Product) {
+	if s.history == nil || len(products) == 0 || !s.track() {
+		return
+	}
+	go func() {
+		defer s.inFlight.Done()
+		if err := s.history.Record(query, country, products); err != nil {
+			log.Warn().Err(err).Msg("Failed to record price history")
+		}
+	}()
+}
+
+// SetCatalog makes every scraped search upsert its listings into the
+// product catalog, which gives them stable IDs.
+func (s *SearchService) SetCatalog(store *catalog.Store) {
+	s.catalog = store
+}
+
+// Catalog returns the product catalog, or nil when it's disabled.
+func (s *SearchService) Catalog() *catalog.Store {
+	return s.catalog
+}
+
+// upsertCatalog runs before the response is built, so the catalog IDs it
+// sets are what the caller and the cache see.
+func (s *SearchService) upsertCatalog(ctx context.Context, query, country string, products []models.Product) {
+	if s.catalog == nil || len(products) == 0 {
+		return
+	}
+	if err := s.catalog.Upsert(query, country, products); err != nil {
+		zerolog.Ctx(ctx).Warn().Err(err).Msg("Failed to update product catalog")
+	}
+}
+
+// SetShadow enables mirroring of scraped (non-cached) searches to runner.
+func (s *SearchService) SetShadow(runner *ShadowRunner) {
+	s.shadow = runner
+}
 
-	duration := time.Since(startTime)
+// Shadow returns the shadow runner, or nil when shadow mode is off.
+func (s *SearchService) Shadow() *ShadowRunner {
+	return s.shadow
+}
+
+// SearchCached answers a search from the cache only and never scrapes. It
+// returns nil, nil on a cache miss or when no cache is available.
+func (s *SearchService) SearchCached(ctx context.Context, params models.SearchParams) (*models.SearchResponse, error) {
+	startTime := time.Now()
 
-	// Update source information based on country
-	sourceInfo := "Amazon, eBay"
-	if country == "IN" {
-		sourceInfo = "Amazon, eBay, Flipkart"
+	if params.Country == "" {
+		params.Country = s.cfg.Server.DefaultCountry
+	}
+	rawQuery := params.Query
+	params.Query = querynorm.Normalize(params.Query)
+	if err := s.validateSearchParams(&params); err != nil {
+		return nil, err
 	}
 
-	response := &models.SearchResponse{
-		Query:      params.Query,
-		Products:   paginatedProducts,
-		Total:      len(filteredProducts),
-		Page:       params.Page,
-		Limit:      params.Limit,
-		TotalPages: totalPages,
-		Source:     sourceInfo,
-		Filters:    params.Filters,
-		Sort:       params.Sort,
-		Duration:   duration.String(),
+	if s.cache == nil || !s.cache.IsAvailable() {
+		return nil, nil
 	}
 
-	// Cache the response
-	if s.cache != nil && s.cache.IsAvailable() && cacheKey != "" {
-		if err := s.cache.SetSearchResults(cacheKey, response); err != nil {
-			log.Printf("Failed to cache results: %v", err)
-		} else {
-			log.Printf("Cached results for key: %s", cacheKey)
+	if params.ResultSetID != "" {
+		response, _, err := s.resultSet(ctx, params, startTime)
+		if response != nil {
+			echoQuery(response, rawQuery, params.Query)
 		}
+		return response, err
+	}
+
+	cacheKey := s.cache.GenerateSearchKey(params)
+	cached, err := s.cache.GetSearchResults(ctx, cacheKey)
+	if err != nil || cached == nil {
+		return nil, nil
 	}
+	cached.Duration = fmt.Sprintf("%s (cached)", time.Since(startTime).String())
+	echoQuery(cached, rawQuery, params.Query)
+	return cached, nil
+}
 
-	return response, nil
+// echoQuery shows the query as the caller typed it, and the normalized
+// query when it differs. Cached responses may have been stored for another
+// spelling of the same search.
+func echoQuery(response *models.SearchResponse, raw, normalized string) {
+	response.Query = raw
+	response.NormalizedQuery = ""
+	if normalized != raw {
+		response.NormalizedQuery = normalized
+	}
 }
 
-func (s *SearchService) scrapeAllSources(query, country string) []models.Product {
+// scrapeAllSources searches every enabled source for the country, and
+// reports how each source for the country fared. It only fails when no
+// source returned products and at least one of them errored.
+func (s *SearchService) scrapeAllSources(ctx context.Context, query, country string) ([]models.Product, []models.SourceStatus, error) {
 	var allProducts []models.Product
 	var wg sync.WaitGroup
 	var mu sync.Mutex
+	logger := zerolog.Ctx(ctx).With().Str("query", query).Str("country", country).Logger()
+
+	if !s.track() {
+		logger.Warn().Msg("Shutting down, skipping scrape")
+		return make([]models.Product, 0), nil, nil
+	}
+	defer s.inFlight.Done()
+
+	if s.cfg.Mock.Enabled {
+		// Mock products stand in for every source that would run
+		products, err := s.mockProducts(ctx, query, country)
+		return products, s.sourcesStatus(country, nil), err
+	}
 
 	// Track errors for better debugging
 	var scraperErrors []error
 	var errorMu sync.Mutex
+	finished := make(map[string]error)
 
-	// Helper function to safely append errors
-	addError := func(err error) {
+	// Helper function to safely record how a source finished
+	addError := func(name string, err error) {
+		errorMu.Lock()
+		finished[name] = err
 		if err != nil {
-			errorMu.Lock()
 			scraperErrors = append(scraperErrors, err)
-			errorMu.Unlock()
 		}
+		errorMu.Unlock()
 	}
 
 	// Helper function to safely append products
 	addProducts := func(products []models.Product, source string) {
 		mu.Lock()
 		allProducts = append(allProducts, products...)
-		log.Printf("%s scraper completed: found %d products", source, len(products))
+		logger.Info().Msgf("%s scraper completed: found %d products", source, len(products))
 		mu.Unlock()
 	}
 
@@ -137,60 +669,70 @@ func (s *SearchService) scrapeAllSources(query, country string) []models.Product
 	// wg.Add(1)
 	// go func() {
 	//	defer wg.Done()
-	//	chromeProducts, err := s.chromeScraper.SearchUniversal(query, country)
+	//	chromeProducts, err := browser.NewChromeScraper(s.cfg.Chrome).SearchUniversal(ctx, query, country)
 	//	addError(err)
 	//	addProducts(chromeProducts, "Chrome")
 	// }()
 
 	// Amazon scraping
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		defer func() {
-			if r := recover(); r != nil {
-				log.Printf("Amazon scraper panic recovered: %v", r)
+	if s.runs(config.ScraperAmazon, country) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					logger.Error().Msgf("Amazon scraper panic recovered: %v", r)
+				}
+			}()
+
+			scrapeCtx, span := startScraperSpan(ctx, config.ScraperAmazon, country)
+			amazonProducts, err := s.searchSource(scrapeCtx, config.ScraperAmazon, s.amazonScraper, query, country)
+			endScraperSpan(scrapeCtx, span, len(amazonProducts), err)
+			addError(config.ScraperAmazon, err)
+			if amazonProducts == nil {
+				amazonProducts = make([]models.Product, 0)
 			}
+			addProducts(amazonProducts, "Amazon")
 		}()
-
-		amazonProducts, err := s.amazonScraper.Search(query, country)
-		addError(err)
-		if amazonProducts == nil {
-			amazonProducts = make([]models.Product, 0)
-		}
-		addProducts(amazonProducts, "Amazon")
-	}()
+	}
 
 	// eBay scraping
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		defer func() {
-			if r := recover(); r != nil {
-				log.Printf("eBay scraper panic recovered: %v", r)
+	if s.runs(config.ScraperEbay, country) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					logger.Error().Msgf("eBay scraper panic recovered: %v", r)
+				}
+			}()
+
+			scrapeCtx, span := startScraperSpan(ctx, config.ScraperEbay, country)
+			ebayProducts, err := s.searchSource(scrapeCtx, config.ScraperEbay, s.ebayScraper, query, country)
+			endScraperSpan(scrapeCtx, span, len(ebayProducts), err)
+			addError(config.ScraperEbay, err)
+			if ebayProducts == nil {
+				ebayProducts = make([]models.Product, 0)
 			}
+			addProducts(ebayProducts, "eBay")
 		}()
-
-		ebayProducts, err := s.ebayScraper.Search(query, country)
-		addError(err)
-		if ebayProducts == nil {
-			ebayProducts = make([]models.Product, 0)
-		}
-		addProducts(ebayProducts, "eBay")
-	}()
+	}
 
 	// Flipkart scraping (only for India)
-	if strings.ToUpper(country) == "IN" {
+	if s.runs(config.ScraperFlipkart, country) {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 			defer func() {
 				if r := recover(); r != nil {
-					log.Printf("Flipkart scraper panic recovered: %v", r)
+					logger.Error().Msgf("Flipkart scraper panic recovered: %v", r)
 				}
 			}()
 
-			flipkartProducts, err := s.flipkartScraper.Search(query, country)
-			addError(err)
+			scrapeCtx, span := startScraperSpan(ctx, config.ScraperFlipkart, country)
+			flipkartProducts, err := s.searchSource(scrapeCtx, config.ScraperFlipkart, s.flipkartScraper, query, country)
+			endScraperSpan(scrapeCtx, span, len(flipkartProducts), err)
+			addError(config.ScraperFlipkart, err)
 			if flipkartProducts == nil {
 				flipkartProducts = make([]models.Product, 0)
 			}
@@ -199,18 +741,20 @@ func (s *SearchService) scrapeAllSources(query, country string) []models.Product
 	}
 
 	// Walmart scraping (only for US)
-	if strings.ToUpper(country) == "US" {
+	if s.runs(config.ScraperWalmart, country) {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 			defer func() {
 				if r := recover(); r != nil {
-					log.Printf("Walmart scraper panic recovered: %v", r)
+					logger.Error().Msgf("Walmart scraper panic recovered: %v", r)
 				}
 			}()
 
-			walmartProducts, err := s.walmartScraper.Search(query, country)
-			addError(err)
+			scrapeCtx, span := startScraperSpan(ctx, config.ScraperWalmart, country)
+			walmartProducts, err := s.searchSource(scrapeCtx, config.ScraperWalmart, s.walmartScraper, query, country)
+			endScraperSpan(scrapeCtx, span, len(walmartProducts), err)
+			addError(config.ScraperWalmart, err)
 			if walmartProducts == nil {
 				walmartProducts = make([]models.Product, 0)
 			}
@@ -219,18 +763,20 @@ func (s *SearchService) scrapeAllSources(query, country string) []models.Product
 	}
 
 	// Target scraping (only for US)
-	if strings.ToUpper(country) == "US" {
+	if s.runs(config.ScraperTarget, country) {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 			defer func() {
 				if r := recover(); r != nil {
-					log.Printf("Target scraper panic recovered: %v", r)
+					logger.Error().Msgf("Target scraper panic recovered: %v", r)
 				}
 			}()
 
-			targetProducts, err := s.targetScraper.Search(query, country)
-			addError(err)
+			scrapeCtx, span := startScraperSpan(ctx, config.ScraperTarget, country)
+			targetProducts, err := s.searchSource(scrapeCtx, config.ScraperTarget, s.targetScraper, query, country)
+			endScraperSpan(scrapeCtx, span, len(targetProducts), err)
+			addError(config.ScraperTarget, err)
 			if targetProducts == nil {
 				targetProducts = make([]models.Product, 0)
 			}
@@ -239,18 +785,20 @@ func (s *SearchService) scrapeAllSources(query, country string) []models.Product
 	}
 
 	// Best Buy scraping (only for US)
-	if strings.ToUpper(country) == "US" {
+	if s.runs(config.ScraperBestBuy, country) {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 			defer func() {
 				if r := recover(); r != nil {
-					log.Printf("Best Buy scraper panic recovered: %v", r)
+					logger.Error().Msgf("Best Buy scraper panic recovered: %v", r)
 				}
 			}()
 
-			bestBuyProducts, err := s.bestBuyScraper.Search(query, country)
-			addError(err)
+			scrapeCtx, span := startScraperSpan(ctx, config.ScraperBestBuy, country)
+			bestBuyProducts, err := s.searchSource(scrapeCtx, config.ScraperBestBuy, s.bestBuyScraper, query, country)
+			endScraperSpan(scrapeCtx, span, len(bestBuyProducts), err)
+			addError(config.ScraperBestBuy, err)
 			if bestBuyProducts == nil {
 				bestBuyProducts = make([]models.Product, 0)
 			}
@@ -258,14 +806,147 @@ func (s *SearchService) scrapeAllSources(query, country string) []models.Product
 		}()
 	}
 
+	// Costco scraping (only for US)
+	if s.runs(config.ScraperCostco, country) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					logger.Error().Msgf("Costco scraper panic recovered: %v", r)
+				}
+			}()
+
+			scrapeCtx, span := startScraperSpan(ctx, config.ScraperCostco, country)
+			costcoProducts, err := s.searchSource(scrapeCtx, config.ScraperCostco, s.costcoScraper, query, country)
+			endScraperSpan(scrapeCtx, span, len(costcoProducts), err)
+			addError(config.ScraperCostco, err)
+			if costcoProducts == nil {
+				costcoProducts = make([]models.Product, 0)
+			}
+			addProducts(costcoProducts, "Costco")
+		}()
+	}
+
+	// Sam's Club scraping (only for US)
+	if s.runs(config.ScraperSamsClub, country) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					logger.Error().Msgf("Sam's Club scraper panic recovered: %v", r)
+				}
+			}()
+
+			scrapeCtx, span := startScraperSpan(ctx, config.ScraperSamsClub, country)
+			samsClubProducts, err := s.searchSource(scrapeCtx, config.ScraperSamsClub, s.samsClubScraper, query, country)
+			endScraperSpan(scrapeCtx, span, len(samsClubProducts), err)
+			addError(config.ScraperSamsClub, err)
+			if samsClubProducts == nil {
+				samsClubProducts = make([]models.Product, 0)
+			}
+			addProducts(samsClubProducts, "Sam's Club")
+		}()
+	}
+
+	// Google Shopping scraping (all countries)
+	if s.runs(config.ScraperGoogleShopping, country) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					logger.Error().Msgf("Google Shopping scraper panic recovered: %v", r)
+				}
+			}()
+
+			scrapeCtx, span := startScraperSpan(ctx, config.ScraperGoogleShopping, country)
+			googleProducts, err := s.searchSource(scrapeCtx, config.ScraperGoogleShopping, s.googleScraper, query, country)
+			endScraperSpan(scrapeCtx, span, len(googleProducts), err)
+			addError(config.ScraperGoogleShopping, err)
+			if googleProducts == nil {
+				googleProducts = make([]models.Product, 0)
+			}
+			addProducts(googleProducts, "Google Shopping")
+		}()
+	}
+
+	// AliExpress scraping (all countries)
+	if s.runs(config.ScraperAliExpress, country) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					logger.Error().Msgf("AliExpress scraper panic recovered: %v", r)
+				}
+			}()
+
+			scrapeCtx, span := startScraperSpan(ctx, config.ScraperAliExpress, country)
+			aliExpressProducts, err := s.searchSource(scrapeCtx, config.ScraperAliExpress, s.aliExpressScraper, query, country)
+			endScraperSpan(scrapeCtx, span, len(aliExpressProducts), err)
+			addError(config.ScraperAliExpress, err)
+			if aliExpressProducts == nil {
+				aliExpressProducts = make([]models.Product, 0)
+			}
+			addProducts(aliExpressProducts, "AliExpress")
+		}()
+	}
+
+	// Etsy scraping (US, UK, CA, AU)
+	if s.runs(config.ScraperEtsy, country) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					logger.Error().Msgf("Etsy scraper panic recovered: %v", r)
+				}
+			}()
+
+			scrapeCtx, span := startScraperSpan(ctx, config.ScraperEtsy, country)
+			etsyProducts, err := s.searchSource(scrapeCtx, config.ScraperEtsy, s.etsyScraper, query, country)
+			endScraperSpan(scrapeCtx, span, len(etsyProducts), err)
+			addError(config.ScraperEtsy, err)
+			if etsyProducts == nil {
+				etsyProducts = make([]models.Product, 0)
+			}
+			addProducts(etsyProducts, "Etsy")
+		}()
+	}
+
+	// Mercado Libre scraping (MX, BR, AR)
+	if s.runs(config.ScraperMercadoLibre, country) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					logger.Error().Msgf("Mercado Libre scraper panic recovered: %v", r)
+				}
+			}()
+
+			scrapeCtx, span := startScraperSpan(ctx, config.ScraperMercadoLibre, country)
+			mercadoLibreProducts, err := s.searchSource(scrapeCtx, config.ScraperMercadoLibre, s.mercadoLibre, query, country)
+			endScraperSpan(scrapeCtx, span, len(mercadoLibreProducts), err)
+			addError(config.ScraperMercadoLibre, err)
+			if mercadoLibreProducts == nil {
+				mercadoLibreProducts = make([]models.Product, 0)
+			}
+			addProducts(mercadoLibreProducts, "Mercado Libre")
+		}()
+	}
+
 	wg.Wait()
 
 	// Log any errors that occurred
 	if len(scraperErrors) > 0 {
-		log.Printf("Scraping completed with %d errors:", len(scraperErrors))
-		for i, err := range scraperErrors {
-			log.Printf("  Error %d: %v", i+1, err)
-		}
+		logger.Warn().Errs("errors", scraperErrors).Msgf("Scraping completed with %d errors", len(scraperErrors))
+	}
+
+	if len(allProducts) == 0 && len(scraperErrors) > 0 {
+		return nil, nil, sourcesFailed(scraperErrors)
 	}
 
 	// Ensure we always return a valid slice
@@ -273,13 +954,288 @@ func (s *SearchService) scrapeAllSources(query, country string) []models.Product
 		allProducts = make([]models.Product, 0)
 	}
 
-	log.Printf("Total products scraped: %d from %s", len(allProducts), country)
-	return allProducts
+	logger.Info().Msgf("Total products scraped: %d from %s", len(allProducts), country)
+	return allProducts, s.sourcesStatus(country, finished), nil
+}
+
+// sourcesStatus lists the status of every source for country, in scraper
+// order: how the sources that ran finished, with their errors in finished,
+// and why the others were skipped. With finished nil, as for mock
+// products, every source that ran counts as ok; otherwise one missing from
+// finished panicked.
+func (s *SearchService) sourcesStatus(country string, finished map[string]error) []models.SourceStatus {
+	var statuses []models.SourceStatus
+	for _, name := range config.ScraperNames {
+		if !sourceCovers(name, country) {
+			continue
+		}
+		st := models.SourceStatus{Name: name}
+		switch until, down := s.inMaintenance(name); {
+		case !s.enabled(name):
+			st.Status = SourceDisabled
+		case down:
+			st.Status = SourceMaintenance
+			st.Reason = fmt.Sprintf("in a maintenance window until %s", until.Format(time.RFC3339))
+		default:
+			err, ok := finished[name]
+			if finished != nil && !ok {
+				err = fmt.Errorf("%s scraper panicked", name)
+			}
+			st.Status = sourceStatus(err)
+			if err != nil {
+				st.Reason = err.Error()
+			}
+		}
+		statuses = append(statuses, st)
+	}
+	return statuses
+}
+
+// sourcesFailed reports a search in which every source that ran failed. The
+// error takes the kind the failures share, such as ErrBlocked when every
+// retailer refused the request, and ErrUpstream when they differ.
+func sourcesFailed(errs []error) error {
+	first := apierr.Classify(errs[0])
+	kind, code := first.Kind, first.Code
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		if e := apierr.Classify(err); e.Kind != kind {
+			kind, code = apierr.ErrUpstream, "upstream_error"
+		}
+		messages[i] = err.Error()
+	}
+	return apierr.New(kind, code, fmt.Sprintf("all sources failed: %d errors", len(errs))).
+		WithDetails(strings.Join(messages, "; "))
+}
+
+type noCacheKey struct{}
+
+// withoutCache marks the searches of ctx to skip every source's cached
+// results. Fresh results are still cached.
+func withoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noCacheKey{}, true)
+}
+
+// cacheSkipped reports whether ctx was marked by withoutCache.
+func cacheSkipped(ctx context.Context) bool {
+	skip, _ := ctx.Value(noCacheKey{}).(bool)
+	return skip
+}
+
+// searchSource searches one retailer. Its results are cached on their own,
+// for the scraper's cache TTL, so a retailer whose results are still fresh
+// isn't searched again when others' have expired.
+func (s *SearchService) searchSource(ctx context.Context, name string, scraper scrapers.Scraper, query, country string) ([]models.Product, error) {
+	reportProgress(ctx, ProgressEvent{Type: ProgressSourceStarted, Source: name})
+	useCache := s.cache != nil && s.cache.IsAvailable()
+	key := ""
+	if useCache {
+		key = s.cache.GenerateSourceKey(query, country, name)
+	}
+	if useCache && !cacheSkipped(ctx) {
+		if hit, err := s.cache.GetSearchResults(ctx, key); err == nil && hit != nil {
+			trace.SpanFromContext(ctx).SetAttributes(attribute.String("scraper.via", "cache"))
+			scrapetrace.SetVia(ctx, "cache")
+			s.reportSource(ctx, name, hit.Products, nil)
+			return hit.Products, nil
+		}
+	}
+
+	// Retailers that keep failing are skipped, their cached results aside
+	if err := s.circuitAllows(name); err != nil {
+		s.reportSource(ctx, name, nil, err)
+		return nil, err
+	}
+
+	start := time.Now()
+	products, err := s.searchRetailer(ctx, name, scraper, query, country)
+	metrics.ObserveScrape(name, len(products), err, time.Since(start))
+	s.recordCircuit(ctx, name, err)
+	s.reportSource(ctx, name, products, err)
+
+	if useCache && err == nil {
+		set := &models.SearchResponse{Query: query, Products: products, Total: len(products), Source: name}
+		if err := s.cache.SetSourceResults(ctx, key, set, s.cfg.Scraper(name).CacheTTL); err != nil {
+			zerolog.Ctx(ctx).Warn().Err(err).Str("scraper", name).Msg("Failed to cache source results")
+		}
+	}
+	return products, err
+}
+
+// searchRetailer searches a retailer through its official API when one is
+// configured for the country, and falls back to its scraper otherwise or
+// when the API call fails.
+func (s *SearchService) searchRetailer(ctx context.Context, name string, scraper scrapers.Scraper, query, country string) ([]models.Product, error) {
+	if provider, ok := s.providers[name]; ok && provider.Covers(country) {
+		products, err := provider.Search(ctx, query, country)
+		if err == nil {
+			trace.SpanFromContext(ctx).SetAttributes(attribute.String("scraper.via", "api"))
+			scrapetrace.SetVia(ctx, "api")
+			return products, nil
+		}
+		zerolog.Ctx(ctx).Warn().Err(err).Str("scraper", name).Msg("Official API search failed, falling back to scraping")
+	}
+	return scraper.Search(ctx, query, country)
+}
+
+// startScraperSpan opens the span covering one scraper goroutine, and its
+// entry in the request's scrape trace.
+func startScraperSpan(ctx context.Context, name, country string) (context.Context, trace.Span) {
+	ctx = scrapetrace.StartSource(ctx, name)
+	return tracing.Start(ctx, "scraper."+name,
+		attribute.String("scraper.name", name),
+		attribute.String("search.country", country),
+	)
+}
+
+func endScraperSpan(ctx context.Context, span trace.Span, products int, err error) {
+	scrapetrace.SetStatus(ctx, sourceStatus(err))
+	scrapetrace.EndSource(ctx, products, err)
+	span.SetAttributes(attribute.Int("scraper.products", products))
+	tracing.End(span, err)
+}
+
+// runs reports whether scrapeAllSources searches the scraper for country.
+func (s *SearchService) runs(name, country string) bool {
+	if !s.enabled(name) || !sourceCovers(name, country) {
+		return false
+	}
+	_, down := s.inMaintenance(name)
+	return !down
+}
+
+// inMaintenance reports whether a scraper is in one of its configured
+// maintenance windows, and when the window ends.
+func (s *SearchService) inMaintenance(name string) (time.Time, bool) {
+	return s.cfg.Scraper(name).InMaintenance(time.Now())
+}
+
+// Maintenance lists the scrapers in a maintenance window now, with when
+// each window ends.
+func (s *SearchService) Maintenance() map[string]time.Time {
+	down := map[string]time.Time{}
+	for _, name := range config.ScraperNames {
+		if until, ok := s.inMaintenance(name); ok {
+			down[name] = until
+		}
+	}
+	return down
+}
+
+// sourceCovers reports whether a scraper is run for country. Amazon, eBay,
+// Google Shopping and AliExpress run everywhere, falling back to a global
+// site; the rest only run where they have a storefront.
+func sourceCovers(name, country string) bool {
+	country = strings.ToUpper(country)
+	switch name {
+	case config.ScraperAmazon, config.ScraperEbay, config.ScraperGoogleShopping, config.ScraperAliExpress:
+		return true
+	case config.ScraperFlipkart:
+		return country == "IN"
+	case config.ScraperWalmart, config.ScraperTarget, config.ScraperBestBuy, config.ScraperCostco, config.ScraperSamsClub:
+		return country == "US"
+	case config.ScraperEtsy:
+		return scrapers.EtsyCovers(country)
+	case config.ScraperMercadoLibre:
+		return scrapers.MercadoLibreCovers(country)
+	}
+	return false
+}
+
+// scraper returns the scraper registered under name, or nil.
+func (s *SearchService) scraper(name string) scrapers.Scraper {
+	switch name {
+	case config.ScraperAmazon:
+		return s.amazonScraper
+	case config.ScraperEbay:
+		return s.ebayScraper
+	case config.ScraperFlipkart:
+		return s.flipkartScraper
+	case config.ScraperWalmart:
+		return s.walmartScraper
+	case config.ScraperTarget:
+		return s.targetScraper
+	case config.ScraperBestBuy:
+		return s.bestBuyScraper
+	case config.ScraperGoogleShopping:
+		return s.googleScraper
+	case config.ScraperAliExpress:
+		return s.aliExpressScraper
+	case config.ScraperEtsy:
+		return s.etsyScraper
+	case config.ScraperCostco:
+		return s.costcoScraper
+	case config.ScraperSamsClub:
+		return s.samsClubScraper
+	case config.ScraperMercadoLibre:
+		return s.mercadoLibre
+	}
+	return nil
+}
+
+// enabled reports whether a scraper is currently switched on.
+func (s *SearchService) enabled(name string) bool {
+	s.enabledMu.RLock()
+	defer s.enabledMu.RUnlock()
+	return s.enabledScrapers[name]
+}
+
+// SetScraperEnabled turns a scraper on or off at runtime, e.g. when a retailer
+// starts serving captchas. It does not persist across restarts.
+func (s *SearchService) SetScraperEnabled(name string, enabled bool) error {
+	name = strings.ToLower(name)
+
+	s.enabledMu.Lock()
+	defer s.enabledMu.Unlock()
+
+	if _, exists := s.enabledScrapers[name]; !exists {
+		return fmt.Errorf("unknown scraper: %s. Valid scrapers: %s", name, strings.Join(config.ScraperNames, ", "))
+	}
+	s.enabledScrapers[name] = enabled
+	log.Info().Msgf("Scraper %s enabled=%v", name, enabled)
+	return nil
+}
+
+// OfficialAPIs lists the scrapers searched through their official API.
+func (s *SearchService) OfficialAPIs() []string {
+	names := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validateCountry rejects countries that aren't ISO codes or that no enabled
+// scraper can search.
+func (s *SearchService) validateCountry(country string) error {
+	country = strings.ToUpper(country)
+	if len(country) != 2 || strings.Trim(country, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		return apierr.UnsupportedCountry(fmt.Sprintf("invalid country code: %q", country))
+	}
+	for name, enabled := range s.ScraperStatus() {
+		if enabled && scrapers.CountryCoverage(name, country).Supported {
+			return nil
+		}
+	}
+	return apierr.UnsupportedCountry(fmt.Sprintf("no enabled source covers %s", country))
+}
+
+// ScraperStatus returns the on/off state of every scraper.
+func (s *SearchService) ScraperStatus() map[string]bool {
+	s.enabledMu.RLock()
+	defer s.enabledMu.RUnlock()
+
+	status := make(map[string]bool, len(s.enabledScrapers))
+	for name, enabled := range s.enabledScrapers {
+		status[name] = enabled
+	}
+	return status
 }
 
 func (s *SearchService) validateSearchParams(params *models.SearchParams) error {
 	if params.Query == "" {
-		return fmt.Errorf("search query cannot be empty")
+		return apierr.Validation("empty_query", "search query cannot be empty")
 	}
 
 	// Set defaults
@@ -296,26 +1252,49 @@ func (s *SearchService) validateSearchParams(params *models.SearchParams) error
 	// Validate filters
 	if params.Filters != nil {
 		if params.Filters.MinPrice < 0 {
-			return fmt.Errorf("minimum price cannot be negative")
+			return apierr.Validation("invalid_filter", "minimum price cannot be negative")
 		}
 		if params.Filters.MaxPrice > 0 && params.Filters.MaxPrice < params.Filters.MinPrice {
-			return fmt.Errorf("maximum price cannot be less than minimum price")
+			return apierr.Validation("invalid_filter", "maximum price cannot be less than minimum price")
 		}
 		if params.Filters.MinRating < 0 || params.Filters.MinRating > 5 {
-			return fmt.Errorf("minimum rating must be between 0 and 5")
+			return apierr.Validation("invalid_filter", "minimum rating must be between 0 and 5")
+		}
+		if params.Filters.MinDiscount < 0 || params.Filters.MinDiscount >= 100 {
+			return apierr.Validation("invalid_filter", "minimum discount must be a percentage between 0 and 100")
 		}
+		if params.Filters.Category != "" {
+			params.Filters.Category = strings.ToLower(params.Filters.Category)
+			if _, ok := categories.Lookup(params.Filters.Category); !ok {
+				return apierr.Validation("invalid_filter", fmt.Sprintf("unknown category: %s. Valid categories: %s", params.Filters.Category, strings.Join(categories.Slugs(), ", ")))
+			}
+		}
+		if params.Filters.Condition != "" {
+			params.Filters.Condition = strings.ToLower(params.Filters.Condition)
+			if !contains(models.Conditions, params.Filters.Condition) {
+				return apierr.Validation("invalid_filter", fmt.Sprintf("invalid condition: %s. Valid conditions: %s", params.Filters.Condition, strings.Join(models.Conditions, ", ")))
+			}
+		}
+	}
+
+	if err := s.validatePreferences(params.Preferences); err != nil {
+		return err
+	}
+
+	if err := s.validateCountry(params.Country); err != nil {
+		return err
 	}
 
 	// Validate sort
 	if params.Sort != nil {
-		validFields := []string{"price", "rating", "name"}
+		validFields := []string{"price", "rating", "name", "discount_percent"}
 		validOrders := []string{"asc", "desc"}
 
 		if !contains(validFields, params.Sort.Field) {
-			return fmt.Errorf("invalid sort field: %s. Valid fields: %s", params.Sort.Field, strings.Join(validFields, ", "))
+			return apierr.Validation("invalid_sort", fmt.Sprintf("invalid sort field: %s. Valid fields: %s", params.Sort.Field, strings.Join(validFields, ", ")))
 		}
 		if !contains(validOrders, params.Sort.Order) {
-			return fmt.Errorf("invalid sort order: %s. Valid orders: %s", params.Sort.Order, strings.Join(validOrders, ", "))
+			return apierr.Validation("invalid_sort", fmt.Sprintf("invalid sort order: %s. Valid orders: %s", params.Sort.Order, strings.Join(validOrders, ", ")))
 		}
 	}
 
@@ -324,7 +1303,16 @@ func (s *SearchService) validateSearchParams(params *models.SearchParams) error
 
 func (s *SearchService) processProducts(products []models.Product) {
 	for i := range products {
+		products[i].Title = products[i].Name
 		products[i].PriceValue = utils.ParsePrice(products[i].Price)
+		products[i].RatingValue, products[i].RatingScale = utils.NormalizeRating(products[i].Rating)
+		products[i].ReviewCount = utils.ParseReviewCount(products[i].Reviews)
+		products[i].MarketplaceSeller = marketplaceSeller(products[i])
+		products[i].Attributes = utils.ParseAttributes(products[i].Name)
+		// Sources without category hints are categorized by name
+		if products[i].Category == "" {
+			products[i].Category = categories.Detect(products[i].Name)
+		}
 	}
 }
 
@@ -351,8 +1339,41 @@ func (s *SearchService) applyFilters(products []models.Product, filters *models.
 
 		// Rating filter
 		if filters.MinRating > 0 {
-			rating := utils.ParseRating(product.Rating)
-			if rating < filters.MinRating {
+			if product.RatingValue < filters.MinRating {
+				continue
+			}
+		}
+
+		// Discount filter
+		if filters.MinDiscount > 0 && product.Discount < filters.MinDiscount {
+			continue
+		}
+
+		// Warehouse-club filter
+		if filters.RequiresMembership != nil && product.RequiresMembership != *filters.RequiresMembership {
+			continue
+		}
+
+		// Category filter, which includes subcategories
+		if filters.Category != "" && !categories.Within(product.Category, filters.Category) {
+			continue
+		}
+
+		// Seller filters
+		if filters.ExcludeMarketplaceSellers && product.MarketplaceSeller {
+			continue
+		}
+		if filters.Seller != "" && !sellerMatches(product, filters.Seller) {
+			continue
+		}
+
+		// Condition filter; listings without one are taken to be new
+		if filters.Condition != "" {
+			condition := product.Condition
+			if condition == "" {
+				condition = models.ConditionNew
+			}
+			if condition != filters.Condition {
 				continue
 			}
 		}
@@ -384,7 +1405,8 @@ func (s *SearchService) applySorting(products []models.Product, sortParams *mode
 		return
 	}
 
-	sort.Slice(products, func(i, j int) bool {
+	// Stable, so products that tie keep their order and pages don't overlap
+	sort.SliceStable(products, func(i, j int) bool {
 		switch sortParams.Field {
 		case "price":
 			if sortParams.Order == "desc" {
@@ -393,12 +1415,10 @@ func (s *SearchService) applySorting(products []models.Product, sortParams *mode
 			return products[i].PriceValue < products[j].PriceValue
 
 		case "rating":
-			ratingI := utils.ParseRating(products[i].Rating)
-			ratingJ := utils.ParseRating(products[j].Rating)
 			if sortParams.Order == "desc" {
-				return ratingI > ratingJ
+				return products[i].RatingValue > products[j].RatingValue
 			}
-			return ratingI < ratingJ
+			return products[i].RatingValue < products[j].RatingValue
 
 		case "name":
 			if sortParams.Order == "desc" {
@@ -406,6 +1426,12 @@ func (s *SearchService) applySorting(products []models.Product, sortParams *mode
 			}
 			return products[i].Name < products[j].Name
 
+		case "discount_percent":
+			if sortParams.Order == "desc" {
+				return products[i].Discount > products[j].Discount
+			}
+			return products[i].Discount < products[j].Discount
+
 		default:
 			return false
 		}
@@ -429,6 +1455,21 @@ func (s *SearchService) applyPagination(products []models.Product, page, limit i
 	return products[start:end], totalPages
 }
 
+// displayName cuts title to at most length characters, at the last word
+// boundary when there is one, marking the cut with "...". It returns "" when
+// length is 0 or title already fits.
+func displayName(title string, length int) string {
+	runes := []rune(title)
+	if length <= 0 || len(runes) <= length {
+		return ""
+	}
+	cut := string(runes[:length])
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,;:-") + "..."
+}
+
 func contains(slice []string, item string) bool {
 	for _, s := range slice {
 		if s == item {
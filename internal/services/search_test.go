@@ -0,0 +1,73 @@
+package services
+
+import (
+	"testing"
+
+	"price-comparison-api/internal/apierr"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+)
+
+func TestSourceNames(t *testing.T) {
+	for _, tc := range []struct {
+		sources []string
+		want    string
+	}{
+		{nil, ""},
+		{[]string{"eBay US", "Amazon US", "Amazon US", "Walmart US"}, "Amazon, eBay, Walmart"},
+		{[]string{"Etsy US", "Mercado Libre MX", "Amazon IN (Chrome)"}, "Amazon, Etsy, Mercado Libre"},
+		{[]string{"Flipkart", "Myntra", "Amazon IN (Mock)"}, "Amazon, Flipkart, Myntra"},
+	} {
+		products := make([]models.Product, len(tc.sources))
+		for i, source := range tc.sources {
+			products[i].Source = source
+		}
+		if got := sourceNames(products); got != tc.want {
+			t.Errorf("sources %q: %q, want %q", tc.sources, got, tc.want)
+		}
+	}
+}
+
+func TestSourcesStatus(t *testing.T) {
+	cfg := config.Default()
+	flipkart := cfg.Scraper(config.ScraperFlipkart)
+	// Down all day
+	flipkart.Maintenance = []config.MaintenanceWindow{{Start: "00:00", End: "12:00"}, {Start: "12:00", End: "00:00"}}
+	cfg.Scrapers[config.ScraperFlipkart] = flipkart
+	s := NewSearchService(cfg, nil)
+	if err := s.SetScraperEnabled(config.ScraperAliExpress, false); err != nil {
+		t.Fatal(err)
+	}
+
+	finished := map[string]error{
+		config.ScraperAmazon:         nil,
+		config.ScraperEbay:           apierr.New(apierr.ErrUpstream, SourceCircuitOpen, "ebay skipped after repeated failures"),
+		config.ScraperGoogleShopping: apierr.New(apierr.ErrBlocked, "blocked", "captcha page"),
+	}
+	want := map[string]string{
+		config.ScraperAmazon:         SourceOK,
+		config.ScraperEbay:           SourceCircuitOpen,
+		config.ScraperFlipkart:       SourceMaintenance,
+		config.ScraperGoogleShopping: SourceBlocked,
+		config.ScraperAliExpress:     SourceDisabled,
+	}
+	statuses := s.sourcesStatus("IN", finished)
+	if len(statuses) != len(want) {
+		t.Fatalf("%d statuses, want one for each of the %d sources covering IN: %+v", len(statuses), len(want), statuses)
+	}
+	for _, st := range statuses {
+		if st.Status != want[st.Name] {
+			t.Errorf("%s: status %q, want %q", st.Name, st.Status, want[st.Name])
+		}
+		if wantReason := st.Status != SourceOK && st.Status != SourceDisabled; (st.Reason != "") != wantReason {
+			t.Errorf("%s: reason %q", st.Name, st.Reason)
+		}
+	}
+
+	// Mock products stand in for every source that runs
+	for _, st := range s.sourcesStatus("IN", nil) {
+		if st.Name == config.ScraperEbay && st.Status != SourceOK {
+			t.Errorf("mock %s: status %q, want ok", st.Name, st.Status)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"strings"
+
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+)
+
+// marketplaces are the sources every listing of which comes from a
+// third-party seller.
+var marketplaces = map[string]bool{
+	config.ScraperEbay:         true,
+	config.ScraperEtsy:         true,
+	config.ScraperAliExpress:   true,
+	config.ScraperMercadoLibre: true,
+}
+
+// marketplaceSeller reports whether a third party sells the product: every
+// listing on a marketplace, and listings of other retailers whose merchant
+// isn't the retailer itself ("Amazon.com" is Amazon's own offer). An
+// aggregator's merchants are stores, not marketplace sellers.
+func marketplaceSeller(p models.Product) bool {
+	key, name := Retailer(p.Source)
+	switch {
+	case marketplaces[key]:
+		return true
+	case key == config.ScraperGoogleShopping || p.Merchant == "":
+		return false
+	}
+	return !strings.Contains(compactName(p.Merchant), compactName(name))
+}
+
+// sellerMatches matches the product's seller against name, ignoring case:
+// the merchant, or the retailer for its own listings.
+func sellerMatches(p models.Product, name string) bool {
+	seller := p.Merchant
+	if seller == "" {
+		_, seller = Retailer(p.Source)
+	}
+	return strings.Contains(compactName(seller), compactName(name))
+}
+
+// compactName lowercases a store name and drops everything but letters and
+// digits, so "Best Buy" matches "bestbuy" and "Sam's Club" "samsclub".
+func compactName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r > 127 {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,486 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+)
+
+// SessionStep is one state a search session has been in.
+type SessionStep struct {
+	Action  string          `json:"action"` // search, refine
+	Filters *models.Filters `json:"filters,omitempty"`
+	Sort    *models.Sort    `json:"sort,omitempty"`
+	Page    int             `json:"page"`
+	Limit   int             `json:"limit"`
+	Total   int             `json:"total"`
+	At      time.Time       `json:"at"`
+}
+
+// SearchSession keeps the unfiltered results of a search server-side so
+// refinements only re-filter instead of re-scraping.
+type SearchSession struct {
+	ID      string `json:"session_id"`
+	Query   string `json:"query"`
+	Country string `json:"country"`
+	// Preferences stay applied to every refinement of the session
+	Preferences *models.Preferences `json:"preferences,omitempty"`
+	Steps       []SessionStep       `json:"steps"`
+	Undos       int                 `json:"undos"`
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+
+	products []models.Product
+}
+
+func (ss *SearchSession) current() SessionStep {
+	return ss.Steps[len(ss.Steps)-1]
+}
+
+func (ss *SearchSession) params(step SessionStep) models.SearchParams {
+	return models.SearchParams{
+		Query:       ss.Query,
+		Country:     ss.Country,
+		Page:        step.Page,
+		Limit:       step.Limit,
+		Filters:     step.Filters,
+		Sort:        step.Sort,
+		Preferences: ss.Preferences,
+	}
+}
+
+// SessionStore holds search sessions in memory with a sliding TTL.
+type SessionStore struct {
+	mu          sync.Mutex
+	sessions    map[string]*SearchSession
+	ttl         time.Duration
+	maxSessions int
+
+	// analytics
+	created      int
+	refinements  int
+	undos        int
+	filterUsage  map[string]int
+	expired      int
+	stepsOnClose int
+}
+
+func NewSessionStore(cfg config.SessionConfig) *SessionStore {
+	ttl := cfg.TTL
+	if ttl <= 0 {
+		ttl = 30 * time.Minute
+	}
+	maxSessions := cfg.MaxSessions
+	if maxSessions <= 0 {
+		maxSessions = 5000
+	}
+
+	store := &SessionStore{
+		sessions:    make(map[string]*SearchSession),
+		ttl:         ttl,
+		maxSessions: maxSessions,
+		filterUsage: make(map[string]int),
+	}
+	go store.janitor()
+	return store
+}
+
+func (st *SessionStore) janitor() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		st.mu.Lock()
+		now := time.Now()
+		for id, sess := range st.sessions {
+			if now.Sub(sess.UpdatedAt) > st.ttl {
+				st.remove(id)
+			}
+		}
+		st.mu.Unlock()
+	}
+}
+
+// remove drops a session and folds it into the analytics. Caller holds mu.
+func (st *SessionStore) remove(id string) {
+	if sess, ok := st.sessions[id]; ok {
+		st.expired++
+		st.stepsOnClose += len(sess.Steps)
+		delete(st.sessions, id)
+	}
+}
+
+func (st *SessionStore) create(params models.SearchParams, products []models.Product, total int) *SearchSession {
+	now := time.Now()
+	sess := &SearchSession{
+		ID:          newID(),
+		Query:       params.Query,
+		Country:     params.Country,
+		Preferences: params.Preferences,
+		Steps: []SessionStep{{
+			Action:  "search",
+			Filters: params.Filters,
+			Sort:    params.Sort,
+			Page:    params.Page,
+			Limit:   params.Limit,
+			Total:   total,
+			At:      now,
+		}},
+		CreatedAt: now,
+		UpdatedAt: now,
+		products:  products,
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	// Evict the least recently used session when full
+	if len(st.sessions) >= st.maxSessions {
+		var oldestID string
+		var oldest time.Time
+		for id, s := range st.sessions {
+			if oldestID == "" || s.UpdatedAt.Before(oldest) {
+				oldestID, oldest = id, s.UpdatedAt
+			}
+		}
+		st.remove(oldestID)
+	}
+
+	st.sessions[sess.ID] = sess
+	st.created++
+	return sess
+}
+
+// get returns a session and refreshes its TTL. Caller holds mu.
+func (st *SessionStore) get(id string) (*SearchSession, error) {
+	sess, ok := st.sessions[id]
+	if !ok || time.Since(sess.UpdatedAt) > st.ttl {
+		return nil, fmt.Errorf("session not found or expired: %s", id)
+	}
+	sess.UpdatedAt = time.Now()
+	return sess, nil
+}
+
+// Get returns a copy of the session state without its product set.
+func (st *SessionStore) Get(id string) (*SearchSession, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	sess, err := st.get(id)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := *sess
+	snapshot.Steps = append([]SessionStep(nil), sess.Steps...)
+	snapshot.products = nil
+	return &snapshot, nil
+}
+
+func (st *SessionStore) Stats() map[string]interface{} {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	steps := st.stepsOnClose
+	for _, sess := range st.sessions {
+		steps += len(sess.Steps)
+	}
+	avgSteps := 0.0
+	if st.created > 0 {
+		avgSteps = float64(steps) / float64(st.created)
+	}
+
+	usage := make(map[string]int, len(st.filterUsage))
+	for field, n := range st.filterUsage {
+		usage[field] = n
+	}
+
+	return map[string]interface{}{
+		"active":                  len(st.sessions),
+		"created":                 st.created,
+		"expired":                 st.expired,
+		"refinements":             st.refinements,
+		"undos":                   st.undos,
+		"avg_steps":               avgSteps,
+		"filter_usage":            usage,
+		"ttl_seconds":             int(st.ttl.Seconds()),
+		"max_sessions":            st.maxSessions,
+		"refinements_per_session": ratio(st.refinements, st.created),
+	}
+}
+
+func ratio(a, b int) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
+
+// newID returns a random ID for a session or result set.
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// SetSessions enables search sessions on the service.
+func (s *SearchService) SetSessions(store *SessionStore) {
+	s.sessions = store
+}
+
+// Sessions returns the session store, or nil when sessions are disabled.
+func (s *SearchService) Sessions() *SessionStore {
+	return s.sessions
+}
+
+// StartSession runs a normal search and opens a session for it. When the
+// results came from the cache the full product set is loaded on the first
+// refinement instead.
+func (s *SearchService) StartSession(ctx context.Context, params models.SearchParams) (*models.SearchResponse, error) {
+	response, products, err := s.search(ctx, params)
+	if err != nil || s.sessions == nil {
+		return response, err
+	}
+
+	if params.Country == "" {
+		params.Country = s.cfg.Server.DefaultCountry
+	}
+	params.Page, params.Limit = response.Page, response.Limit
+
+	sess := s.sessions.create(params, products, response.Total)
+	response.SessionID = sess.ID
+	return response, nil
+}
+
+// RefineSession applies req on top of the session's current state and
+// returns the new page of results.
+func (s *SearchService) RefineSession(ctx context.Context, id string, req models.RefineRequest) (*models.SearchResponse, error) {
+	startTime := time.Now()
+	st := s.sessions
+	if st == nil {
+		return nil, fmt.Errorf("search sessions are disabled")
+	}
+
+	st.mu.Lock()
+	sess, err := st.get(id)
+	if err != nil {
+		st.mu.Unlock()
+		return nil, err
+	}
+	next := mergeRefinement(sess.current(), req)
+	products := sess.products
+	query, country, prefs := sess.Query, sess.Country, sess.Preferences
+	st.mu.Unlock()
+
+	params := models.SearchParams{Query: query, Country: country, Page: next.Page, Limit: next.Limit, Filters: next.Filters, Sort: next.Sort, Preferences: prefs}
+	if err := s.validateSearchParams(&params); err != nil {
+		return nil, err
+	}
+
+	if products == nil {
+		// Session was started from a cache hit; scrape once and keep the set
+		if products, _, err = s.scrapeAllSources(ctx, query, strings.ToUpper(country)); err != nil {
+			return nil, err
+		}
+		s.processProducts(products)
+	}
+
+	response := s.buildResponse(params, products, startTime)
+	response.SessionID = id
+
+	next.Action = "refine"
+	next.Page, next.Limit = params.Page, params.Limit
+	next.Total = response.Total
+	next.At = time.Now()
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if sess, err = st.get(id); err != nil {
+		return nil, err
+	}
+	sess.products = products
+	sess.Steps = append(sess.Steps, next)
+	st.refinements++
+	for _, field := range refinedFields(req) {
+		st.filterUsage[field]++
+	}
+	return response, nil
+}
+
+// UndoSession steps the session back to its previous state.
+func (s *SearchService) UndoSession(ctx context.Context, id string) (*models.SearchResponse, error) {
+	startTime := time.Now()
+	st := s.sessions
+	if st == nil {
+		return nil, fmt.Errorf("search sessions are disabled")
+	}
+
+	st.mu.Lock()
+	sess, err := st.get(id)
+	if err != nil {
+		st.mu.Unlock()
+		return nil, err
+	}
+	if len(sess.Steps) < 2 {
+		st.mu.Unlock()
+		return nil, fmt.Errorf("nothing to undo")
+	}
+	sess.Steps = sess.Steps[:len(sess.Steps)-1]
+	sess.Undos++
+	st.undos++
+	params := sess.params(sess.current())
+	products := sess.products
+	zerolog.Ctx(ctx).Info().Str("session_id", id).Msgf("Session undo, back to %d steps", len(sess.Steps))
+	st.mu.Unlock()
+
+	if products == nil {
+		var err error
+		if products, _, err = s.scrapeAllSources(ctx, params.Query, strings.ToUpper(params.Country)); err != nil {
+			return nil, err
+		}
+		s.processProducts(products)
+	}
+
+	response := s.buildResponse(params, products, startTime)
+	response.SessionID = id
+	return response, nil
+}
+
+// mergeRefinement builds the next step from the current one. Filter fields
+// are merged one by one so a refinement can narrow a single dimension.
+func mergeRefinement(current SessionStep, req models.RefineRequest) SessionStep {
+	next := current
+
+	var filters models.Filters
+	if current.Filters != nil && !req.ClearFilters {
+		filters = *current.Filters
+	}
+	if f := req.Filters; f != nil {
+		if f.MinPrice != 0 {
+			filters.MinPrice = f.MinPrice
+		}
+		if f.MaxPrice != 0 {
+			filters.MaxPrice = f.MaxPrice
+		}
+		if f.InStock != nil {
+			inStock := *f.InStock
+			filters.InStock = &inStock
+		}
+		if f.MinRating != 0 {
+			filters.MinRating = f.MinRating
+		}
+		if f.Source != "" {
+			filters.Source = f.Source
+		}
+		if f.MinDiscount != 0 {
+			filters.MinDiscount = f.MinDiscount
+		}
+		if f.RequiresMembership != nil {
+			membership := *f.RequiresMembership
+			filters.RequiresMembership = &membership
+		}
+		if f.Category != "" {
+			filters.Category = f.Category
+		}
+		if f.Seller != "" {
+			filters.Seller = f.Seller
+		}
+		if f.ExcludeMarketplaceSellers {
+			filters.ExcludeMarketplaceSellers = true
+		}
+		if f.Condition != "" {
+			filters.Condition = f.Condition
+		}
+		if f.Strict != nil {
+			strict := *f.Strict
+			filters.Strict = &strict
+		}
+	}
+	next.Filters = nil
+	if filters != (models.Filters{}) {
+		next.Filters = &filters
+	}
+
+	if req.ClearSort {
+		next.Sort = nil
+	}
+	if req.Sort != nil {
+		sort := *req.Sort
+		if sort.Order == "" {
+			sort.Order = "asc"
+		}
+		next.Sort = &sort
+	}
+
+	// Changing filters or sort goes back to the first page unless a page is given
+	if req.Filters != nil || req.Sort != nil || req.ClearFilters || req.ClearSort {
+		next.Page = 1
+	}
+	if req.Page > 0 {
+		next.Page = req.Page
+	}
+	if req.Limit > 0 {
+		next.Limit = req.Limit
+	}
+	return next
+}
+
+func refinedFields(req models.RefineRequest) []string {
+	var fields []string
+	if f := req.Filters; f != nil {
+		if f.MinPrice != 0 {
+			fields = append(fields, "min_price")
+		}
+		if f.MaxPrice != 0 {
+			fields = append(fields, "max_price")
+		}
+		if f.InStock != nil {
+			fields = append(fields, "in_stock")
+		}
+		if f.MinRating != 0 {
+			fields = append(fields, "min_rating")
+		}
+		if f.Source != "" {
+			fields = append(fields, "source")
+		}
+		if f.MinDiscount != 0 {
+			fields = append(fields, "min_discount")
+		}
+		if f.RequiresMembership != nil {
+			fields = append(fields, "requires_membership")
+		}
+		if f.Category != "" {
+			fields = append(fields, "category")
+		}
+		if f.Seller != "" {
+			fields = append(fields, "seller")
+		}
+		if f.ExcludeMarketplaceSellers {
+			fields = append(fields, "exclude_marketplace_sellers")
+		}
+		if f.Condition != "" {
+			fields = append(fields, "condition")
+		}
+		if f.Strict != nil {
+			fields = append(fields, "strict")
+		}
+	}
+	if req.Sort != nil {
+		fields = append(fields, "sort")
+	}
+	if req.ClearFilters {
+		fields = append(fields, "clear_filters")
+	}
+	if req.Page > 0 {
+		fields = append(fields, "page")
+	}
+	return fields
+}
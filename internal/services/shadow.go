@@ -0,0 +1,230 @@
+package services
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/scrapetrace"
+	"price-comparison-api/pkg/tracing"
+)
+
+// maxShadowInFlight caps concurrent shadow searches so mirroring never piles
+// up scraper load; extra samples are dropped.
+const maxShadowInFlight = 2
+
+// ShadowDiff records how a shadow run differed from the live response.
+type ShadowDiff struct {
+	Query          string        `json:"query"`
+	Country        string        `json:"country"`
+	Page           int           `json:"page"`
+	At             time.Time     `json:"at"`
+	LiveCount      int           `json:"live_count"`
+	ShadowCount    int           `json:"shadow_count"`
+	LiveTotal      int           `json:"live_total"`
+	ShadowTotal    int           `json:"shadow_total"`
+	OnlyLive       []string      `json:"only_live,omitempty"`
+	OnlyShadow     []string      `json:"only_shadow,omitempty"`
+	PriceChanges   []PriceChange `json:"price_changes,omitempty"`
+	RankChanges    int           `json:"rank_changes"`
+	LiveDuration   string        `json:"live_duration"`
+	ShadowDuration string        `json:"shadow_duration"`
+	Error          string        `json:"error,omitempty"`
+}
+
+type PriceChange struct {
+	Product string `json:"product"`
+	Live    string `json:"live"`
+	Shadow  string `json:"shadow"`
+}
+
+// ShadowRunner mirrors a sample of live searches to a SearchService built
+// from an alternate config and keeps the most recent diffs in memory.
+type ShadowRunner struct {
+	service    *SearchService
+	sampleRate float64
+	maxDiffs   int
+	inFlight   chan struct{}
+
+	mu       sync.Mutex
+	diffs    []ShadowDiff
+	mirrored int
+	dropped  int
+}
+
+// NewShadowRunner builds the shadow service from shadowCfg. The shadow side
+// never reads or writes the cache.
+func NewShadowRunner(shadowCfg *config.Config, opts config.ShadowConfig) *ShadowRunner {
+	maxDiffs := opts.MaxDiffs
+	if maxDiffs <= 0 {
+		maxDiffs = 200
+	}
+
+	log.Info().Msgf("Shadow mode enabled: mirroring %.0f%% of scraped searches using %s", opts.SampleRate*100, opts.ConfigFile)
+
+	return &ShadowRunner{
+		service:    NewSearchService(shadowCfg, nil),
+		sampleRate: opts.SampleRate,
+		maxDiffs:   maxDiffs,
+		inFlight:   make(chan struct{}, maxShadowInFlight),
+	}
+}
+
+// Mirror samples the request and, if picked, re-runs it in the background
+// against the shadow service. It never blocks the live request.
+func (r *ShadowRunner) Mirror(ctx context.Context, params models.SearchParams, live *models.SearchResponse) {
+	if r == nil || live == nil || rand.Float64() >= r.sampleRate {
+		return
+	}
+
+	select {
+	case r.inFlight <- struct{}{}:
+	default:
+		r.mu.Lock()
+		r.dropped++
+		r.mu.Unlock()
+		return
+	}
+
+	// Keep the trace but not the request's cancellation. The shadow search
+	// stays out of the request's scrape trace, which shows what was served.
+	ctx = withoutProgress(scrapetrace.Detach(context.WithoutCancel(ctx)))
+
+	go func() {
+		defer func() { <-r.inFlight }()
+		defer func() {
+			if rec := recover(); rec != nil {
+				log.Error().Msgf("Shadow search panic recovered: %v", rec)
+			}
+		}()
+
+		ctx, span := tracing.Start(ctx, "shadow.search")
+		defer span.End()
+
+		start := time.Now()
+		shadow, err := r.service.SearchProducts(ctx, params)
+		diff := compareResults(params, live, shadow)
+		diff.ShadowDuration = time.Since(start).String()
+		if err != nil {
+			diff.Error = err.Error()
+		}
+		r.record(diff)
+	}()
+}
+
+// Shutdown stops the shadow service; see SearchService.Shutdown.
+func (r *ShadowRunner) Shutdown(ctx context.Context) error {
+	if r == nil {
+		return nil
+	}
+	return r.service.Shutdown(ctx)
+}
+
+func (r *ShadowRunner) record(diff ShadowDiff) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.mirrored++
+	r.diffs = append(r.diffs, diff)
+	if over := len(r.diffs) - r.maxDiffs; over > 0 {
+		r.diffs = append([]ShadowDiff(nil), r.diffs[over:]...)
+	}
+}
+
+// Diffs returns the recorded diffs, newest first.
+func (r *ShadowRunner) Diffs() []ShadowDiff {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	diffs := make([]ShadowDiff, 0, len(r.diffs))
+	for i := len(r.diffs) - 1; i >= 0; i-- {
+		diffs = append(diffs, r.diffs[i])
+	}
+	return diffs
+}
+
+func (r *ShadowRunner) Stats() map[string]interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return map[string]interface{}{
+		"sample_rate": r.sampleRate,
+		"mirrored":    r.mirrored,
+		"dropped":     r.dropped,
+		"stored":      len(r.diffs),
+		"max_diffs":   r.maxDiffs,
+	}
+}
+
+func (r *ShadowRunner) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.diffs = nil
+	r.mirrored = 0
+	r.dropped = 0
+}
+
+// compareResults matches products by URL (or source+name when the URL is
+// missing) and reports membership, price and rank differences.
+func compareResults(params models.SearchParams, live, shadow *models.SearchResponse) ShadowDiff {
+	diff := ShadowDiff{
+		Query:        params.Query,
+		Country:      params.Country,
+		Page:         live.Page,
+		At:           time.Now(),
+		LiveCount:    len(live.Products),
+		LiveTotal:    live.Total,
+		LiveDuration: live.Duration,
+	}
+	if shadow == nil {
+		return diff
+	}
+	diff.ShadowCount = len(shadow.Products)
+	diff.ShadowTotal = shadow.Total
+
+	shadowIndex := make(map[string]int, len(shadow.Products))
+	for i, p := range shadow.Products {
+		shadowIndex[productKey(p)] = i
+	}
+
+	seen := make(map[string]bool, len(live.Products))
+	for i, p := range live.Products {
+		key := productKey(p)
+		seen[key] = true
+
+		j, ok := shadowIndex[key]
+		if !ok {
+			diff.OnlyLive = append(diff.OnlyLive, key)
+			continue
+		}
+		if i != j {
+			diff.RankChanges++
+		}
+		if p.Price != shadow.Products[j].Price {
+			diff.PriceChanges = append(diff.PriceChanges, PriceChange{
+				Product: key,
+				Live:    p.Price,
+				Shadow:  shadow.Products[j].Price,
+			})
+		}
+	}
+
+	for _, p := range shadow.Products {
+		if key := productKey(p); !seen[key] {
+			diff.OnlyShadow = append(diff.OnlyShadow, key)
+		}
+	}
+
+	return diff
+}
+
+func productKey(p models.Product) string {
+	if p.URL != "" {
+		return p.URL
+	}
+	return p.Source + ":" + p.Name
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/pricefmt"
+)
+
+// summarize computes the summary of a search's filtered products. Price
+// figures use the currency most of the priced products are in, so a few
+// listings in another currency don't skew them. The highest rated product
+// is picked from all of them, more reviews breaking ties.
+func summarize(products []models.Product) *models.SearchSummary {
+	if len(products) == 0 {
+		return nil
+	}
+
+	counts := map[string]int{}
+	for _, p := range products {
+		if p.PriceValue > 0 {
+			counts[strings.ToUpper(p.Currency)]++
+		}
+	}
+	summary := &models.SearchSummary{Sources: []models.SourcePriceRange{}}
+	for currency, n := range counts {
+		if n > counts[summary.Currency] || n == counts[summary.Currency] && currency < summary.Currency {
+			summary.Currency = currency
+		}
+	}
+
+	var prices []float64
+	var lowest, highest, rated *models.Product
+	ranges := map[string]*models.SourcePriceRange{}
+	for i := range products {
+		p := &products[i]
+		if p.RatingValue > 0 && (rated == nil || p.RatingValue > rated.RatingValue ||
+			p.RatingValue == rated.RatingValue && p.ReviewCount > rated.ReviewCount) {
+			rated = p
+		}
+		if p.PriceValue <= 0 || strings.ToUpper(p.Currency) != summary.Currency {
+			continue
+		}
+		prices = append(prices, p.PriceValue)
+		if lowest == nil || p.PriceValue < lowest.PriceValue {
+			lowest = p
+		}
+		if highest == nil || p.PriceValue > highest.PriceValue {
+			highest = p
+		}
+		r, ok := ranges[p.Source]
+		if !ok {
+			r = &models.SourcePriceRange{Source: p.Source, MinPrice: p.PriceValue, MaxPrice: p.PriceValue}
+			ranges[p.Source] = r
+		}
+		r.Products++
+		r.MinPrice = math.Min(r.MinPrice, p.PriceValue)
+		r.MaxPrice = math.Max(r.MaxPrice, p.PriceValue)
+	}
+
+	if rated != nil {
+		summary.HighestRated = summaryProduct(rated)
+	}
+	if lowest == nil {
+		return summary
+	}
+	summary.LowestPrice = summaryProduct(lowest)
+	summary.MedianPrice = pricefmt.Round(medianOf(prices), summary.Currency)
+	summary.Savings = pricefmt.Round(highest.PriceValue-lowest.PriceValue, summary.Currency)
+	summary.SavingsPercent = math.Round(summary.Savings/highest.PriceValue*1000) / 10
+	for _, r := range ranges {
+		summary.Sources = append(summary.Sources, *r)
+	}
+	sort.Slice(summary.Sources, func(i, j int) bool {
+		a, b := summary.Sources[i], summary.Sources[j]
+		if a.MinPrice != b.MinPrice {
+			return a.MinPrice < b.MinPrice
+		}
+		return a.Source < b.Source
+	})
+	return summary
+}
+
+func summaryProduct(p *models.Product) *models.SummaryProduct {
+	return &models.SummaryProduct{
+		ID:          p.ID,
+		Name:        p.Name,
+		Source:      p.Source,
+		URL:         p.URL,
+		PriceValue:  p.PriceValue,
+		Currency:    p.Currency,
+		RatingValue: p.RatingValue,
+	}
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"price-comparison-api/internal/history"
+	"price-comparison-api/internal/models"
+)
+
+// trendWindow is how far back price_change_24h looks.
+const trendWindow = 24 * time.Hour
+
+// stableChange is the largest change, in percent, still called stable.
+const stableChange = 1.0
+
+// annotateTrends sets the price change since a day ago and its trend on
+// every product the price history knows from then. Products are joined to
+// their history by the same key the search recorded them under.
+func (s *SearchService) annotateTrends(products []models.Product, country string) {
+	if s.history == nil {
+		return
+	}
+	since := time.Now().Add(-trendWindow)
+	for i := range products {
+		p := &products[i]
+		if p.PriceValue <= 0 {
+			continue
+		}
+		entry, err := s.history.Get(history.Key(country, *p))
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to read price history for trends")
+			return
+		}
+		if entry == nil || !strings.EqualFold(entry.Currency, p.Currency) {
+			continue
+		}
+		then, ok := entry.PriceAt(since)
+		if !ok || then.Price <= 0 {
+			continue
+		}
+
+		change := math.Round((p.PriceValue-then.Price)/then.Price*1000) / 10
+		p.PriceChange24h = &change
+		switch {
+		case change >= stableChange:
+			p.Trend = models.TrendUp
+		case change <= -stableChange:
+			p.Trend = models.TrendDown
+		default:
+			p.Trend = models.TrendStable
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package storage
+
+// Copy copies every record of buckets from one database to another, such as
+// from a store's bbolt file into SQL storage, and returns how many it
+// copied. Records already in to are overwritten.
+func Copy(from, to DB, buckets ...[]byte) (int, error) {
+	copied := 0
+	err := from.View(func(src Tx) error {
+		return to.Update(func(dst Tx) error {
+			for _, name := range buckets {
+				out := dst.Bucket(name)
+				err := src.Bucket(name).ForEach(func(k, v []byte) error {
+					copied++
+					return out.Put(k, v)
+				})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return copied, nil
+}
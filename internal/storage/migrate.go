@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"price-comparison-api/internal/config"
+)
+
+// migrations holds each SQL driver's schema changes, applied in file name
+// order. A migration is never edited once released; changes go in a new
+// file.
+//
+//go:embed migrations
+var migrations embed.FS
+
+// Migration is a schema change and when it was applied.
+type Migration struct {
+	Version string `json:"version"`
+	// AppliedAt is nil for a pending migration
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// migrationFiles lists the driver's migrations, oldest first.
+func (b *Backend) migrationFiles() ([]string, error) {
+	dir := path.Join("migrations", b.cfg.Driver)
+	entries, err := fs.ReadDir(migrations, dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".sql") {
+			files = append(files, e.Name())
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// querier is a *sql.DB, or the *sql.Conn holding the migration lock.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// ensureMigrationsTable creates the table recording applied migrations.
+func ensureMigrationsTable(ctx context.Context, q querier) error {
+	_, err := q.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    TEXT PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL
+	)`)
+	return err
+}
+
+// Migrations lists every migration of the driver, with when each was
+// applied.
+func (b *Backend) Migrations(ctx context.Context) ([]Migration, error) {
+	if b.db == nil {
+		return nil, nil
+	}
+	return b.migrations(ctx, b.db)
+}
+
+func (b *Backend) migrations(ctx context.Context, q querier) ([]Migration, error) {
+	if err := ensureMigrationsTable(ctx, q); err != nil {
+		return nil, err
+	}
+	files, err := b.migrationFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := map[string]time.Time{}
+	for rows.Next() {
+		var version string
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	list := make([]Migration, 0, len(files))
+	for _, file := range files {
+		m := Migration{Version: strings.TrimSuffix(file, ".sql")}
+		if at, ok := applied[m.Version]; ok {
+			m.AppliedAt = &at
+		}
+		list = append(list, m)
+	}
+	return list, nil
+}
+
+// Pending lists the migrations not applied yet.
+func (b *Backend) Pending(ctx context.Context) ([]string, error) {
+	if b.db == nil {
+		return nil, nil
+	}
+	return b.pending(ctx, b.db)
+}
+
+func (b *Backend) pending(ctx context.Context, q querier) ([]string, error) {
+	list, err := b.migrations(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	var pending []string
+	for _, m := range list {
+		if m.AppliedAt == nil {
+			pending = append(pending, m.Version)
+		}
+	}
+	return pending, nil
+}
+
+// migrationLock is the Postgres advisory lock held while migrating, so
+// instances starting together don't apply the same migration twice.
+const migrationLock = 7240518
+
+// Migrate applies the pending migrations in order, each in its own
+// transaction, and returns the ones it applied.
+func (b *Backend) Migrate(ctx context.Context) ([]string, error) {
+	if b.db == nil {
+		return nil, nil
+	}
+	conn, err := b.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if b.cfg.Driver == config.StoragePostgres {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLock); err != nil {
+			return nil, err
+		}
+		defer conn.ExecContext(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, migrationLock)
+	}
+
+	pending, err := b.pending(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	var applied []string
+	for _, version := range pending {
+		script, err := migrations.ReadFile(path.Join("migrations", b.cfg.Driver, version+".sql"))
+		if err != nil {
+			return applied, err
+		}
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return applied, err
+		}
+		if _, err := tx.ExecContext(ctx, string(script)); err != nil {
+			tx.Rollback()
+			return applied, fmt.Errorf("migration %s: %w", version, err)
+		}
+		insert := b.dialect.query(`INSERT INTO schema_migrations (version, applied_at) VALUES ($1, $2)`)
+		if _, err := tx.ExecContext(ctx, insert, version, time.Now().UTC()); err != nil {
+			tx.Rollback()
+			return applied, fmt.Errorf("migration %s: %w", version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return applied, fmt.Errorf("migration %s: %w", version, err)
+		}
+		applied = append(applied, version)
+	}
+	return applied, nil
+}
@@ -0,0 +1,8 @@
+-- Every store's records, by store namespace and bucket, ordered by key
+CREATE TABLE records (
+    store  TEXT  NOT NULL,
+    bucket TEXT  NOT NULL,
+    key    BYTEA NOT NULL,
+    value  BYTEA NOT NULL,
+    PRIMARY KEY (store, bucket, key)
+);
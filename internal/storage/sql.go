@@ -0,0 +1,225 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+	"price-comparison-api/internal/config"
+)
+
+// dialect is what differs between the SQL databases.
+type dialect struct {
+	// driver is the database/sql driver name
+	driver string
+	// dsn adjusts the configured DSN for the driver
+	dsn func(string) string
+	// placeholder is the n-th (1-based) query parameter
+	placeholder func(n int) string
+}
+
+var dialects = map[string]*dialect{
+	config.StoragePostgres: {
+		driver:      "postgres",
+		dsn:         func(dsn string) string { return dsn },
+		placeholder: func(n int) string { return fmt.Sprintf("$%d", n) },
+	},
+	config.StorageSQLite: {
+		driver: "sqlite3",
+		// One writer at a time, waiting for the lock rather than failing,
+		// and readers alongside it
+		dsn: func(dsn string) string {
+			if strings.Contains(dsn, "?") {
+				return dsn
+			}
+			return dsn + "?_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate"
+		},
+		placeholder: func(int) string { return "?" },
+	},
+}
+
+// query writes q's numbered parameters ($1, $2...) the dialect's way.
+func (d *dialect) query(q string) string {
+	for n := strings.Count(q, "$"); n > 0; n-- {
+		q = strings.ReplaceAll(q, fmt.Sprintf("$%d", n), d.placeholder(n))
+	}
+	return q
+}
+
+// sqlDB is a store's namespace of the records table.
+type sqlDB struct {
+	db        *sql.DB
+	dialect   *dialect
+	namespace string
+}
+
+func (s *sqlDB) View(fn func(Tx) error) error {
+	return s.run(true, fn)
+}
+
+func (s *sqlDB) Update(fn func(Tx) error) error {
+	return s.run(false, fn)
+}
+
+func (s *sqlDB) run(readOnly bool, fn func(Tx) error) error {
+	sqlTx, err := s.db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: readOnly && s.dialect.driver == "postgres"})
+	if err != nil {
+		return err
+	}
+	tx := &sqlTxn{tx: sqlTx, db: s}
+	if err := fn(tx); err != nil {
+		sqlTx.Rollback()
+		return err
+	}
+	// A failed query inside fn, which Bucket's methods can't return
+	if tx.err != nil {
+		sqlTx.Rollback()
+		return tx.err
+	}
+	return sqlTx.Commit()
+}
+
+// The shared pool stays open; Backend.Close closes it.
+func (s *sqlDB) Close() error {
+	return nil
+}
+
+type sqlTxn struct {
+	tx *sql.Tx
+	db *sqlDB
+	// err is the first query that failed
+	err error
+}
+
+func (t *sqlTxn) Bucket(name []byte) Bucket {
+	return &sqlBucket{txn: t, name: string(name)}
+}
+
+func (t *sqlTxn) fail(err error) {
+	if err != nil && t.err == nil {
+		t.err = err
+	}
+}
+
+// record runs a query returning at most one key and value.
+func (t *sqlTxn) record(q string, args ...any) (key, value []byte) {
+	err := t.tx.QueryRow(t.db.dialect.query(q), args...).Scan(&key, &value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	t.fail(err)
+	if err != nil {
+		return nil, nil
+	}
+	if value == nil {
+		value = []byte{}
+	}
+	return key, value
+}
+
+type sqlBucket struct {
+	txn  *sqlTxn
+	name string
+}
+
+func (b *sqlBucket) Get(key []byte) []byte {
+	_, value := b.txn.record(`SELECT key, value FROM records WHERE store = $1 AND bucket = $2 AND key = $3`,
+		b.txn.db.namespace, b.name, key)
+	return value
+}
+
+func (b *sqlBucket) Put(key, value []byte) error {
+	if value == nil {
+		value = []byte{}
+	}
+	_, err := b.txn.tx.Exec(b.txn.db.dialect.query(`INSERT INTO records (store, bucket, key, value) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (store, bucket, key) DO UPDATE SET value = excluded.value`),
+		b.txn.db.namespace, b.name, key, value)
+	return err
+}
+
+func (b *sqlBucket) Delete(key []byte) error {
+	_, err := b.txn.tx.Exec(b.txn.db.dialect.query(`DELETE FROM records WHERE store = $1 AND bucket = $2 AND key = $3`),
+		b.txn.db.namespace, b.name, key)
+	return err
+}
+
+// ForEach reads every record before calling fn, so fn can query the
+// transaction too.
+func (b *sqlBucket) ForEach(fn func(k, v []byte) error) error {
+	rows, err := b.txn.tx.Query(b.txn.db.dialect.query(`SELECT key, value FROM records WHERE store = $1 AND bucket = $2 ORDER BY key`),
+		b.txn.db.namespace, b.name)
+	if err != nil {
+		return err
+	}
+	var keys, values [][]byte
+	for rows.Next() {
+		var k, v []byte
+		if err := rows.Scan(&k, &v); err != nil {
+			rows.Close()
+			return err
+		}
+		keys, values = append(keys, k), append(values, v)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for i := range keys {
+		if err := fn(keys[i], values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *sqlBucket) Cursor() Cursor {
+	return &sqlCursor{bucket: b}
+}
+
+func (b *sqlBucket) KeyN() int {
+	var n int
+	err := b.txn.tx.QueryRow(b.txn.db.dialect.query(`SELECT COUNT(*) FROM records WHERE store = $1 AND bucket = $2`),
+		b.txn.db.namespace, b.name).Scan(&n)
+	b.txn.fail(err)
+	return n
+}
+
+// sqlCursor queries the neighbouring record at each step, so records put or
+// deleted along the way are seen.
+type sqlCursor struct {
+	bucket *sqlBucket
+	key    []byte
+}
+
+func (c *sqlCursor) move(where, order string, args ...any) (key, value []byte) {
+	args = append([]any{c.bucket.txn.db.namespace, c.bucket.name}, args...)
+	key, value = c.bucket.txn.record(`SELECT key, value FROM records WHERE store = $1 AND bucket = $2`+where+` ORDER BY key `+order+` LIMIT 1`, args...)
+	c.key = key
+	return key, value
+}
+
+func (c *sqlCursor) First() ([]byte, []byte) { return c.move("", "ASC") }
+func (c *sqlCursor) Last() ([]byte, []byte)  { return c.move("", "DESC") }
+
+func (c *sqlCursor) Next() ([]byte, []byte) {
+	if c.key == nil {
+		return nil, nil
+	}
+	return c.move(" AND key > $3", "ASC", c.key)
+}
+
+func (c *sqlCursor) Prev() ([]byte, []byte) {
+	if c.key == nil {
+		return nil, nil
+	}
+	return c.move(" AND key < $3", "DESC", c.key)
+}
+
+func (c *sqlCursor) Seek(seek []byte) ([]byte, []byte) {
+	return c.move(" AND key >= $3", "ASC", seek)
+}
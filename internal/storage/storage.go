@@ -0,0 +1,179 @@
+// Package storage is the persistence layer of the catalog, price history
+// and alerts. Each store keeps JSON records in named buckets, ordered by
+// key, and reads and writes them in transactions. The buckets live in a
+// bbolt file per store by default, or in one shared Postgres or SQLite
+// database, where every store has its own namespace.
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"price-comparison-api/internal/config"
+)
+
+// DB is one store's database.
+type DB interface {
+	// View runs fn in a read-only transaction
+	View(fn func(Tx) error) error
+	// Update runs fn in a read-write transaction, committed when fn returns
+	// nil and rolled back otherwise
+	Update(fn func(Tx) error) error
+	Close() error
+}
+
+// Tx is a transaction.
+type Tx interface {
+	// Bucket returns a bucket the store declared when it was opened
+	Bucket(name []byte) Bucket
+}
+
+// Bucket is a set of records ordered by key. Values are only valid until the
+// transaction ends.
+type Bucket interface {
+	// Get returns a record's value, or nil when there is none
+	Get(key []byte) []byte
+	Put(key, value []byte) error
+	Delete(key []byte) error
+	// ForEach calls fn for every record in key order, stopping at its first
+	// error
+	ForEach(fn func(k, v []byte) error) error
+	Cursor() Cursor
+	// KeyN counts the records
+	KeyN() int
+}
+
+// Cursor walks a bucket in key order. Each method returns the record it
+// moved to, or a nil key past either end.
+type Cursor interface {
+	First() (key, value []byte)
+	Last() (key, value []byte)
+	Next() (key, value []byte)
+	Prev() (key, value []byte)
+	// Seek moves to the first record at or after seek
+	Seek(seek []byte) (key, value []byte)
+}
+
+// Backend opens the stores' databases with the configured driver. The SQL
+// drivers share one connection pool between the stores.
+type Backend struct {
+	cfg config.StorageConfig
+	db  *sql.DB
+	// dialect is nil for bolt
+	dialect *dialect
+}
+
+// NewBackend connects to the configured database and, when migrate is on,
+// brings its schema up to date; otherwise pending migrations are an error.
+// The bolt driver needs neither.
+func NewBackend(cfg config.StorageConfig) (*Backend, error) {
+	b, err := Connect(cfg)
+	if err != nil || b.db == nil {
+		return b, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if cfg.Migrate {
+		applied, err := b.Migrate(ctx)
+		if err != nil {
+			b.Close()
+			return nil, err
+		}
+		if len(applied) > 0 {
+			log.Info().Strs("migrations", applied).Msgf("Applied %d %s storage migrations", len(applied), cfg.Driver)
+		}
+	} else if pending, err := b.Pending(ctx); err != nil {
+		b.Close()
+		return nil, err
+	} else if len(pending) > 0 {
+		b.Close()
+		return nil, fmt.Errorf("%d storage migrations are pending (%s); run cmd/migrate or set STORAGE_MIGRATE", len(pending), pending[0])
+	}
+	return b, nil
+}
+
+// Connect opens the configured database without looking at its schema, for
+// tools that manage the migrations themselves.
+func Connect(cfg config.StorageConfig) (*Backend, error) {
+	b := &Backend{cfg: cfg}
+	if cfg.Driver == config.StorageBolt || cfg.Driver == "" {
+		return b, nil
+	}
+
+	d, ok := dialects[cfg.Driver]
+	if !ok {
+		return nil, fmt.Errorf("unknown storage driver: %s", cfg.Driver)
+	}
+	db, err := sql.Open(d.driver, d.dsn(cfg.DSN))
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("connecting to %s: %w", cfg.Driver, err)
+	}
+	b.db, b.dialect = db, d
+	return b, nil
+}
+
+// Driver is the configured storage driver.
+func (b *Backend) Driver() string {
+	if b.dialect == nil {
+		return config.StorageBolt
+	}
+	return b.cfg.Driver
+}
+
+// Open returns the database of the store called name, with buckets created.
+// With the bolt driver it is the file at path.
+func (b *Backend) Open(name, path string, buckets ...[]byte) (DB, error) {
+	if b.dialect == nil {
+		return openBolt(path, buckets)
+	}
+	return &sqlDB{db: b.db, dialect: b.dialect, namespace: name}, nil
+}
+
+// Describe says where a store opened at path keeps its data, for logs.
+func (b *Backend) Describe(path string) string {
+	if b.dialect == nil {
+		return path
+	}
+	return b.cfg.Driver + " database"
+}
+
+// Ping checks the database can be reached. Bolt files always can once open.
+func (b *Backend) Ping(ctx context.Context) error {
+	if b.db == nil {
+		return nil
+	}
+	return b.db.PingContext(ctx)
+}
+
+// Stats reports the SQL connection pool; nil for bolt.
+func (b *Backend) Stats() *sql.DBStats {
+	if b.db == nil {
+		return nil
+	}
+	stats := b.db.Stats()
+	return &stats
+}
+
+// Close closes the connection pool. The stores' databases must be closed
+// first.
+func (b *Backend) Close() error {
+	if b.db == nil {
+		return nil
+	}
+	return b.db.Close()
+}
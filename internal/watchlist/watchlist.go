@@ -0,0 +1,363 @@
+// Package watchlist keeps the products users asked to watch and re-scrapes
+// their detail pages on a schedule, so their prices are tracked even when
+// nobody searches for them.
+package watchlist
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	bolt "go.etcd.io/bbolt"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+)
+
+var itemsBucket = []byte("items")
+
+// watchTick is how often the worker looks for products that are due.
+const watchTick = time.Minute
+
+// checkTimeout bounds one detail page scrape
+const checkTimeout = 2 * time.Minute
+
+// ErrNotFound is returned for unknown watchlist IDs.
+var ErrNotFound = fmt.Errorf("watchlist item not found")
+
+// Item is a watched product and what its scrapes found.
+type Item struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	// ProductID and CatalogID are the listing's and its catalog product's
+	// IDs, when the item was added by one of them
+	ProductID string `json:"product_id,omitempty"`
+	CatalogID string `json:"catalog_id,omitempty"`
+	Owner     string `json:"owner,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Source    string `json:"source,omitempty"`
+	Image     string `json:"image,omitempty"`
+
+	Currency     string     `json:"currency,omitempty"`
+	CurrentPrice float64    `json:"current_price"`
+	LowestPrice  float64    `json:"lowest_price"`
+	LowestAt     *time.Time `json:"lowest_at,omitempty"`
+	InStock      bool       `json:"in_stock"`
+
+	Added       time.Time  `json:"added"`
+	LastChecked *time.Time `json:"last_checked,omitempty"`
+	NextCheck   time.Time  `json:"next_check"`
+	// Checks counts the scrapes, failed ones included
+	Checks    int        `json:"checks"`
+	LastError string     `json:"last_error,omitempty"`
+	Snapshots []Snapshot `json:"snapshots,omitempty"`
+}
+
+// Snapshot is what one scrape of a watched product found.
+type Snapshot struct {
+	Price    float64   `json:"price"`
+	Currency string    `json:"currency,omitempty"`
+	InStock  bool      `json:"in_stock"`
+	At       time.Time `json:"at"`
+}
+
+// ScrapeFunc scrapes a product detail page.
+type ScrapeFunc func(ctx context.Context, url string) (*models.ProductDetail, error)
+
+// Service stores watched products in a bbolt file and re-scrapes each one
+// every configured interval. Scrapes run one at a time to keep the load on
+// retailers low.
+type Service struct {
+	db     *bolt.DB
+	cfg    config.WatchlistConfig
+	scrape ScrapeFunc
+
+	stop chan struct{}
+	done chan struct{}
+	// wake asks the worker to look for due items now, as after an Add
+	wake chan struct{}
+	// mu keeps Add from racing a check of the same item
+	mu sync.Mutex
+}
+
+func NewService(cfg config.WatchlistConfig, scrape ScrapeFunc) (*Service, error) {
+	path := cfg.Path
+	if path == "" {
+		path = filepath.Join(os.TempDir(), "price-comparison-watchlist.db")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create watchlist directory: %v", err)
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open watchlist store: %v", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(itemsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to init watchlist store: %v", err)
+	}
+
+	log.Info().Msgf("Watchlist ready at %s", path)
+	return &Service{
+		db:     db,
+		cfg:    cfg,
+		scrape: scrape,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+		wake:   make(chan struct{}, 1),
+	}, nil
+}
+
+func (s *Service) Start() {
+	log.Info().Msgf("Watchlist checks enabled, interval: %s", s.cfg.Interval)
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(watchTick)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			case <-s.wake:
+			}
+			s.runDue()
+		}
+	}()
+}
+
+// Stop ends the worker, waiting for a running scrape to finish.
+func (s *Service) Stop() {
+	if s == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+}
+
+func (s *Service) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// Add watches the product at url for owner. Watching a URL the owner
+// already watches returns the existing item and false. New items are
+// scraped right away.
+func (s *Service) Add(url, productID, catalogID, owner string) (*Item, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var existing *Item
+	err := s.each(func(item *Item) bool {
+		if item.Owner == owner && item.URL == url {
+			existing = item
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if existing != nil {
+		return existing, false, nil
+	}
+
+	now := time.Now()
+	item := &Item{
+		ID:        newID(),
+		URL:       url,
+		ProductID: productID,
+		CatalogID: catalogID,
+		Owner:     owner,
+		Added:     now,
+		NextCheck: now,
+	}
+	if err := s.put(item); err != nil {
+		return nil, false, err
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return item, true, nil
+}
+
+// Get returns an item with its snapshots.
+func (s *Service) Get(id string) (*Item, error) {
+	var item *Item
+	err := s.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket(itemsBucket).Get([]byte(id))
+		if raw == nil {
+			return ErrNotFound
+		}
+		item = &Item{}
+		return json.Unmarshal(raw, item)
+	})
+	return item, err
+}
+
+// List returns owner's items without their snapshots, most recently added
+// first.
+func (s *Service) List(owner string) ([]Item, error) {
+	items := []Item{}
+	err := s.each(func(item *Item) bool {
+		if item.Owner == owner {
+			item.Snapshots = nil
+			items = append(items, *item)
+		}
+		return true
+	})
+	sort.Slice(items, func(i, j int) bool { return items[i].Added.After(items[j].Added) })
+	return items, err
+}
+
+// Remove stops watching an item.
+func (s *Service) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(itemsBucket)
+		if bucket.Get([]byte(id)) == nil {
+			return ErrNotFound
+		}
+		return bucket.Delete([]byte(id))
+	})
+}
+
+// runDue scrapes every item whose next check has come up.
+func (s *Service) runDue() {
+	now := time.Now()
+	var due []string
+	err := s.each(func(item *Item) bool {
+		if !now.Before(item.NextCheck) {
+			due = append(due, item.ID)
+		}
+		return true
+	})
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to read watchlist")
+		return
+	}
+
+	for _, id := range due {
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+		s.check(id)
+	}
+}
+
+func (s *Service) check(id string) {
+	item, err := s.Get(id)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+	detail, scrapeErr := s.scrape(ctx, item.URL)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// The item may have been removed during the scrape
+	if item, err = s.Get(id); err != nil {
+		return
+	}
+	now := time.Now()
+	item.LastChecked = &now
+	item.NextCheck = now.Add(s.cfg.Interval)
+	item.Checks++
+	item.LastError = ""
+	if scrapeErr != nil {
+		item.LastError = scrapeErr.Error()
+		log.Warn().Err(scrapeErr).Str("watch_id", id).Msg("Watchlist check failed")
+	} else {
+		s.record(item, detail, now)
+	}
+	if err := s.put(item); err != nil {
+		log.Warn().Err(err).Str("watch_id", id).Msg("Failed to save watchlist check")
+	}
+}
+
+// record takes a scrape's details and price into item.
+func (s *Service) record(item *Item, detail *models.ProductDetail, now time.Time) {
+	if detail.Name != "" {
+		item.Name = detail.Name
+	}
+	if detail.Image != "" {
+		item.Image = detail.Image
+	}
+	item.Source = detail.Source
+	item.InStock = detail.InStock || detail.Availability == "in_stock"
+
+	snapshot := Snapshot{Price: detail.PriceValue, Currency: detail.Currency, InStock: item.InStock, At: now}
+	item.Snapshots = append(item.Snapshots, snapshot)
+	if len(item.Snapshots) > s.cfg.MaxSnapshots {
+		item.Snapshots = item.Snapshots[len(item.Snapshots)-s.cfg.MaxSnapshots:]
+	}
+	if detail.PriceValue <= 0 {
+		return
+	}
+
+	// A currency change makes the old lowest price meaningless
+	if item.Currency != "" && detail.Currency != "" && item.Currency != detail.Currency {
+		item.LowestPrice, item.LowestAt = 0, nil
+	}
+	if detail.Currency != "" {
+		item.Currency = detail.Currency
+	}
+	item.CurrentPrice = detail.PriceValue
+	if item.LowestPrice == 0 || detail.PriceValue < item.LowestPrice {
+		item.LowestPrice = detail.PriceValue
+		item.LowestAt = &now
+	}
+}
+
+func (s *Service) each(fn func(item *Item) bool) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(itemsBucket).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var item Item
+			if err := json.Unmarshal(v, &item); err != nil {
+				continue
+			}
+			if !fn(&item) {
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Service) put(item *Item) error {
+	raw, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(itemsBucket).Put([]byte(item.ID), raw)
+	})
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,215 @@
+// Package watermark marks the responses given to licensed data resellers so
+// a leaked dataset can be traced to the API key that exported it. A mark is
+// a token naming the reseller, added to each response, and noise: every
+// price_value is moved to its cent plus a few ten-thousandths that depend on
+// the reseller and the listing. The price text beside a marked price_value
+// is dropped, since the exact price could be read back from it. The noise
+// survives the token being stripped and doesn't change a price rounded to
+// cents.
+package watermark
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+
+	"price-comparison-api/internal/config"
+)
+
+// Field is the response field holding the token.
+const Field = "watermark"
+
+// noiseSteps is how many ten-thousandths of a unit the noise can add. It
+// stays below half a cent so rounding to cents gives the price back.
+const noiseSteps = 49
+
+// Marker marks responses and traces marked datasets.
+type Marker struct {
+	secret []byte
+	token  bool
+	noise  bool
+	// resellers maps each API key's hash to its reseller name
+	resellers map[string]string
+	names     []string
+}
+
+// New returns the configured Marker, or nil when watermarking is off.
+func New(cfg config.WatermarkConfig) *Marker {
+	if !cfg.Enabled {
+		return nil
+	}
+	m := &Marker{
+		secret:    []byte(cfg.Secret),
+		token:     cfg.Token,
+		noise:     cfg.Noise,
+		resellers: make(map[string]string, len(cfg.Keys)),
+	}
+	for name, key := range cfg.Keys {
+		m.resellers[keyHash(key)] = name
+		m.names = append(m.names, name)
+	}
+	sort.Strings(m.names)
+	return m
+}
+
+// Reseller returns the reseller an API key belongs to, or "" when its
+// responses aren't marked. It is safe on a nil m.
+func (m *Marker) Reseller(apiKey string) string {
+	if m == nil || apiKey == "" {
+		return ""
+	}
+	return m.resellers[keyHash(apiKey)]
+}
+
+// Apply marks a decoded JSON response (decoded with UseNumber) for reseller
+// and returns it: the token is added to a top-level object, and every object
+// with a price_value gets the noise and loses its price text.
+func (m *Marker) Apply(reseller string, tree interface{}) interface{} {
+	if m.noise {
+		walk(tree, func(obj map[string]interface{}, identity string, value float64) {
+			noisy := math.Round((cents(value)+m.offset(reseller, identity))*10000) / 10000
+			obj["price_value"] = json.Number(strconv.FormatFloat(noisy, 'f', -1, 64))
+			delete(obj, "price")
+		})
+	}
+	if obj, ok := tree.(map[string]interface{}); ok && m.token {
+		obj[Field] = m.Token(reseller)
+	}
+	return tree
+}
+
+// Token is the token added to reseller's responses.
+func (m *Marker) Token(reseller string) string {
+	return "wm1." + hex.EncodeToString(m.sum("token", reseller)[:10])
+}
+
+// Match is how well a dataset matches one reseller's mark.
+type Match struct {
+	Reseller string `json:"reseller"`
+	// Token is set when the dataset carries the reseller's token
+	Token bool `json:"token"`
+	// Matched of Checked prices carry the reseller's noise
+	Matched int `json:"matched"`
+	Checked int `json:"checked"`
+	// Score is Matched over Checked. An unmarked price matches a given
+	// reseller about one time in 49, so a score near 1 over a few dozen
+	// prices is conclusive.
+	Score float64 `json:"score"`
+}
+
+// Trace compares a dataset, such as a leaked response or a list of
+// products, decoded with UseNumber, with every reseller's mark. Matches
+// come best first; resellers that match nothing are left out.
+func (m *Marker) Trace(tree interface{}) []Match {
+	tokens := map[string]bool{}
+	collectTokens(tree, tokens)
+
+	type price struct {
+		identity string
+		value    float64
+	}
+	var prices []price
+	walk(tree, func(_ map[string]interface{}, identity string, value float64) {
+		prices = append(prices, price{identity, value})
+	})
+
+	matches := []Match{}
+	for _, name := range m.names {
+		match := Match{Reseller: name, Token: tokens[m.Token(name)], Checked: len(prices)}
+		for _, p := range prices {
+			if math.Abs(p.value-(cents(p.value)+m.offset(name, p.identity))) < 0.00005 {
+				match.Matched++
+			}
+		}
+		if match.Checked > 0 {
+			match.Score = math.Round(float64(match.Matched)/float64(match.Checked)*1000) / 1000
+		}
+		if match.Token || match.Matched > 0 {
+			matches = append(matches, match)
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Token != matches[j].Token {
+			return matches[i].Token
+		}
+		return matches[i].Score > matches[j].Score
+	})
+	return matches
+}
+
+// walk calls fn for every object with a positive numeric price_value.
+func walk(node interface{}, fn func(obj map[string]interface{}, identity string, value float64)) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if number, ok := n["price_value"].(json.Number); ok {
+			if value, err := number.Float64(); err == nil && value > 0 {
+				fn(n, listing(n, value), value)
+			}
+		}
+		for _, child := range n {
+			walk(child, fn)
+		}
+	case []interface{}:
+		for _, child := range n {
+			walk(child, fn)
+		}
+	}
+}
+
+// listing identifies the listing an object describes by its url, id or
+// name. Objects with none of them are told apart by their price to the
+// cent, which the noise leaves alone.
+func listing(obj map[string]interface{}, value float64) string {
+	for _, field := range []string{"url", "id", "name"} {
+		if s, ok := obj[field].(string); ok && s != "" {
+			return field + ":" + s
+		}
+	}
+	return "cents:" + strconv.FormatFloat(cents(value), 'f', 2, 64)
+}
+
+func collectTokens(node interface{}, tokens map[string]bool) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if token, ok := n[Field].(string); ok {
+			tokens[token] = true
+		}
+		for _, child := range n {
+			collectTokens(child, tokens)
+		}
+	case []interface{}:
+		for _, child := range n {
+			collectTokens(child, tokens)
+		}
+	}
+}
+
+// offset is reseller's noise for a listing: 1 to noiseSteps ten-thousandths.
+func (m *Marker) offset(reseller, identity string) float64 {
+	n := binary.BigEndian.Uint32(m.sum("noise", reseller, identity))
+	return float64(n%noiseSteps+1) / 10000
+}
+
+func (m *Marker) sum(parts ...string) []byte {
+	mac := hmac.New(sha256.New, m.secret)
+	for _, part := range parts {
+		mac.Write([]byte(part))
+		mac.Write([]byte{0})
+	}
+	return mac.Sum(nil)
+}
+
+// cents rounds v to the cent.
+func cents(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+func keyHash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return string(sum[:])
+}
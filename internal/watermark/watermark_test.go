@@ -0,0 +1,159 @@
+package watermark
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"price-comparison-api/internal/config"
+)
+
+func testMarker(secret string) *Marker {
+	return New(config.WatermarkConfig{
+		Enabled: true,
+		Secret:  secret,
+		Keys:    map[string]string{"acme": "acme-key", "globex": "globex-key"},
+		Token:   true,
+		Noise:   true,
+	})
+}
+
+// decode reads JSON the way responses are read before marking.
+func decode(t *testing.T, s string) interface{} {
+	t.Helper()
+	decoder := json.NewDecoder(strings.NewReader(s))
+	decoder.UseNumber()
+	var tree interface{}
+	if err := decoder.Decode(&tree); err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func TestOffset(t *testing.T) {
+	m := testMarker("watermark-test-secret")
+	for _, tc := range []struct {
+		reseller, identity string
+	}{
+		{"acme", "url:https://www.amazon.com/dp/B000"},
+		{"acme", "url:https://www.walmart.com/ip/1"},
+		{"globex", "url:https://www.amazon.com/dp/B000"},
+		{"acme", "cents:19.99"},
+	} {
+		offset := m.offset(tc.reseller, tc.identity)
+		if offset < 0.0001 || offset > float64(noiseSteps)/10000 {
+			t.Errorf("%s %s: offset %v outside 0.0001 to %v", tc.reseller, tc.identity, offset, float64(noiseSteps)/10000)
+		}
+		if again := testMarker("watermark-test-secret").offset(tc.reseller, tc.identity); again != offset {
+			t.Errorf("%s %s: offset %v, then %v from a marker with the same secret", tc.reseller, tc.identity, offset, again)
+		}
+	}
+
+	// Over enough listings, resellers and secrets give different offsets
+	differ := func(a, b func(i int) float64) bool {
+		for i := 0; i < 20; i++ {
+			if a(i) != b(i) {
+				return true
+			}
+		}
+		return false
+	}
+	other := testMarker("another-test-secret")
+	identity := func(i int) string { return fmt.Sprintf("url:https://www.amazon.com/dp/B%03d", i) }
+	if !differ(func(i int) float64 { return m.offset("acme", identity(i)) }, func(i int) float64 { return m.offset("globex", identity(i)) }) {
+		t.Error("acme and globex get the same offsets")
+	}
+	if !differ(func(i int) float64 { return m.offset("acme", identity(i)) }, func(i int) float64 { return other.offset("acme", identity(i)) }) {
+		t.Error("changing the secret leaves the offsets unchanged")
+	}
+}
+
+func TestApplyRoundsBackToCents(t *testing.T) {
+	m := testMarker("watermark-test-secret")
+	for _, tc := range []struct {
+		name    string
+		product string
+		want    float64
+	}{
+		{"listing", `{"url":"https://www.amazon.com/dp/B000","price":"$19.99","price_value":19.99}`, 19.99},
+		{"whole amount", `{"url":"https://www.amazon.com/dp/B001","price":"$20.00","price_value":20}`, 20},
+		{"sub-cent source", `{"url":"https://www.amazon.com/dp/B002","price":"$4.999","price_value":4.999}`, 5},
+		{"large amount", `{"id":"p1","price":"₹1,29,999","price_value":129999}`, 129999},
+		{"no url, id or name", `{"price":"$7.50","price_value":7.5}`, 7.5},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tree := m.Apply("acme", decode(t, `{"products":[`+tc.product+`]}`))
+			product := tree.(map[string]interface{})["products"].([]interface{})[0].(map[string]interface{})
+
+			got, err := product["price_value"].(json.Number).Float64()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got == tc.want {
+				t.Errorf("price_value %v carries no noise", got)
+			}
+			if back := math.Round(got*100) / 100; back != tc.want {
+				t.Errorf("price_value %v rounds to %v, want %v", got, back, tc.want)
+			}
+			if price, ok := product["price"]; ok {
+				t.Errorf("price %v kept beside the marked price_value", price)
+			}
+		})
+	}
+}
+
+func TestTrace(t *testing.T) {
+	m := testMarker("watermark-test-secret")
+	var products []string
+	for i := 0; i < 40; i++ {
+		products = append(products, fmt.Sprintf(`{"url":"https://www.amazon.com/dp/B%03d","price":"$%d.99","price_value":%d.99}`, i, 10+i, 10+i))
+	}
+	dataset := `{"products":[` + strings.Join(products, ",") + `]}`
+
+	marked, err := json.Marshal(m.Apply("acme", decode(t, dataset)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Stripping the token leaves the noise
+	stripped := strings.Replace(string(marked), m.Token("acme"), "", 1)
+
+	score := func(matches []Match, reseller string) (float64, bool) {
+		for _, match := range matches {
+			if match.Reseller == reseller {
+				return match.Score, match.Token
+			}
+		}
+		return 0, false
+	}
+	for _, tc := range []struct {
+		name      string
+		dataset   string
+		wantToken bool
+		// acme scores at least minScore and at most maxScore; globex stays
+		// below 0.2
+		minScore, maxScore float64
+	}{
+		{"marked", string(marked), true, 1, 1},
+		{"token stripped", stripped, false, 1, 1},
+		{"unmarked", dataset, false, 0, 0.2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			matches := m.Trace(decode(t, tc.dataset))
+			got, token := score(matches, "acme")
+			if got < tc.minScore || got > tc.maxScore {
+				t.Errorf("acme scores %v, want %v to %v", got, tc.minScore, tc.maxScore)
+			}
+			if token != tc.wantToken {
+				t.Errorf("acme token found: %t, want %t", token, tc.wantToken)
+			}
+			if other, _ := score(matches, "globex"); other >= 0.2 {
+				t.Errorf("globex scores %v on acme's dataset", other)
+			}
+			if tc.wantToken && len(matches) > 0 && matches[0].Reseller != "acme" {
+				t.Errorf("best match is %s, want acme", matches[0].Reseller)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package browser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/chromedp/chromedp"
+	"github.com/rs/zerolog"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/pkg/metrics"
+)
+
+// Captcha kinds Chrome recognizes
+const (
+	CaptchaReCaptcha = "recaptcha_v2"
+	CaptchaHCaptcha  = "hcaptcha"
+	CaptchaImage     = "image"
+)
+
+// CaptchaChallenge is a captcha found on a rendered page.
+type CaptchaChallenge struct {
+	Kind    string `json:"kind"`
+	PageURL string `json:"-"`
+	// SiteKey identifies the reCAPTCHA or hCaptcha widget
+	SiteKey string `json:"site_key"`
+	// Image is the PNG of an image captcha's characters
+	Image []byte `json:"-"`
+}
+
+// CaptchaSolver answers captchas: the token of a reCAPTCHA or hCaptcha
+// widget, or the characters of an image captcha.
+type CaptchaSolver interface {
+	Solve(ctx context.Context, challenge CaptchaChallenge) (string, error)
+}
+
+// NewCaptchaSolver returns the solver of the configured provider, or nil
+// when none is configured.
+func NewCaptchaSolver(cfg config.CaptchaConfig) (CaptchaSolver, error) {
+	switch cfg.Provider {
+	case "":
+		return nil, nil
+	case config.CaptchaTwoCaptcha:
+		return newTwoCaptcha(cfg), nil
+	case config.CaptchaAntiCaptcha:
+		return newAntiCaptcha(cfg), nil
+	}
+	return nil, fmt.Errorf("unknown captcha provider: %s", cfg.Provider)
+}
+
+// Image captcha markup, as Amazon's "Type the characters you see" page has
+const (
+	captchaImageSelector = "form[action*='validateCaptcha'] img, img[src*='captcha' i]"
+	captchaInputSelector = "form[action*='validateCaptcha'] input[type='text'], input[name*='captcha' i]"
+)
+
+// detectCaptchaScript finds a captcha widget or image on the page.
+var detectCaptchaScript = `(() => {
+	const h = document.querySelector('.h-captcha[data-sitekey]');
+	if (h) return {kind: '` + CaptchaHCaptcha + `', site_key: h.dataset.sitekey};
+	const r = document.querySelector('.g-recaptcha[data-sitekey], #recaptcha[data-sitekey]');
+	if (r) return {kind: '` + CaptchaReCaptcha + `', site_key: r.dataset.sitekey};
+	const f = document.querySelector('iframe[src*="recaptcha/api2/anchor"]');
+	if (f) {
+		const k = new URL(f.src).searchParams.get('k');
+		if (k) return {kind: '` + CaptchaReCaptcha + `', site_key: k};
+	}
+	if (document.querySelector("` + captchaImageSelector + `") && document.querySelector("` + captchaInputSelector + `")) {
+		return {kind: '` + CaptchaImage + `', site_key: ''};
+	}
+	return {kind: '', site_key: ''};
+})()`
+
+// submitTokenScript fills a widget's response fields with the token and
+// hands it to the widget's callback, or submits the widget's form.
+const submitTokenScript = `((token) => {
+	document.querySelectorAll('[name="g-recaptcha-response"], [name="h-captcha-response"]').forEach(el => {
+		el.value = token;
+		el.innerHTML = token;
+	});
+	const widget = document.querySelector('.h-captcha[data-sitekey], .g-recaptcha[data-sitekey], [data-sitekey]');
+	const callback = widget && widget.dataset.callback;
+	if (callback && typeof window[callback] === 'function') {
+		window[callback](token);
+		return true;
+	}
+	const form = (widget && widget.closest('form')) || document.querySelector('form');
+	if (form) {
+		form.submit();
+		return true;
+	}
+	return false;
+})(%s)`
+
+// detectCaptcha returns the captcha on the page in ctx's tab, or nil.
+func detectCaptcha(ctx context.Context, pageURL string) (*CaptchaChallenge, error) {
+	var found CaptchaChallenge
+	if err := chromedp.Run(ctx, chromedp.Evaluate(detectCaptchaScript, &found)); err != nil {
+		return nil, err
+	}
+	if found.Kind == "" {
+		return nil, nil
+	}
+	found.PageURL = pageURL
+	if found.Kind == CaptchaImage {
+		if err := chromedp.Run(ctx, chromedp.Screenshot(captchaImageSelector, &found.Image, chromedp.NodeVisible, chromedp.ByQuery)); err != nil {
+			return nil, fmt.Errorf("failed to capture captcha image: %v", err)
+		}
+	}
+	return &found, nil
+}
+
+// solveCaptcha passes a captcha on the page in ctx's tab to the solver,
+// submits its answer and returns the page it leads to. It fails when the
+// solver gives no answer or the page still shows a captcha afterwards.
+func (c *ChromeScraper) solveCaptcha(ctx context.Context, challenge *CaptchaChallenge) (string, error) {
+	logger := zerolog.Ctx(ctx)
+	logger.Info().Str("captcha", challenge.Kind).Msgf("Chrome: solving captcha on %s", challenge.PageURL)
+
+	answer, err := c.solver.Solve(ctx, *challenge)
+	if err != nil {
+		metrics.ObserveCaptcha(challenge.Kind, "error")
+		return "", fmt.Errorf("captcha solver failed: %v", err)
+	}
+
+	var submit chromedp.Action
+	if challenge.Kind == CaptchaImage {
+		submit = chromedp.Tasks{
+			chromedp.SendKeys(captchaInputSelector, answer, chromedp.ByQuery),
+			chromedp.Submit(captchaInputSelector, chromedp.ByQuery),
+		}
+	} else {
+		token, _ := json.Marshal(answer)
+		var submitted bool
+		submit = chromedp.Evaluate(fmt.Sprintf(submitTokenScript, token), &submitted)
+	}
+
+	var html string
+	err = chromedp.Run(ctx,
+		submit,
+		chromedp.Sleep(3*time.Second),
+		chromedp.WaitReady("body", chromedp.ByQuery),
+		chromedp.OuterHTML("html", &html, chromedp.ByQuery),
+	)
+	if err != nil {
+		metrics.ObserveCaptcha(challenge.Kind, "error")
+		return "", fmt.Errorf("failed to submit captcha answer: %v", err)
+	}
+	if again, err := detectCaptcha(ctx, challenge.PageURL); err == nil && again != nil {
+		metrics.ObserveCaptcha(challenge.Kind, "rejected")
+		return "", fmt.Errorf("captcha answer was not accepted")
+	}
+	metrics.ObserveCaptcha(challenge.Kind, "solved")
+	logger.Info().Str("captcha", challenge.Kind).Msgf("Chrome: captcha solved on %s", challenge.PageURL)
+	return html, nil
+}
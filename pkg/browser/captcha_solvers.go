@@ -0,0 +1,216 @@
+package browser
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"price-comparison-api/internal/config"
+)
+
+// Provider API base URLs, used when no endpoint is configured
+const (
+	twoCaptchaEndpoint  = "https://2captcha.com"
+	antiCaptchaEndpoint = "https://api.anti-captcha.com"
+)
+
+// twoCaptcha speaks the 2captcha API: a task is posted to in.php and its
+// answer polled from res.php.
+type twoCaptcha struct {
+	cfg      config.CaptchaConfig
+	endpoint string
+	client   *http.Client
+}
+
+func newTwoCaptcha(cfg config.CaptchaConfig) *twoCaptcha {
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = twoCaptchaEndpoint
+	}
+	return &twoCaptcha{cfg: cfg, endpoint: strings.TrimSuffix(endpoint, "/"), client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// twoCaptchaResponse is the JSON reply of in.php and res.php. Request holds
+// the task ID, the answer, or an error code such as ERROR_ZERO_BALANCE.
+type twoCaptchaResponse struct {
+	Status  int    `json:"status"`
+	Request string `json:"request"`
+}
+
+func (t *twoCaptcha) Solve(ctx context.Context, challenge CaptchaChallenge) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.cfg.Timeout)
+	defer cancel()
+
+	form := url.Values{"key": {t.cfg.APIKey}, "json": {"1"}}
+	switch challenge.Kind {
+	case CaptchaReCaptcha:
+		form.Set("method", "userrecaptcha")
+		form.Set("googlekey", challenge.SiteKey)
+		form.Set("pageurl", challenge.PageURL)
+	case CaptchaHCaptcha:
+		form.Set("method", "hcaptcha")
+		form.Set("sitekey", challenge.SiteKey)
+		form.Set("pageurl", challenge.PageURL)
+	case CaptchaImage:
+		form.Set("method", "base64")
+		form.Set("body", base64.StdEncoding.EncodeToString(challenge.Image))
+	default:
+		return "", fmt.Errorf("unsupported captcha kind: %s", challenge.Kind)
+	}
+
+	var created twoCaptchaResponse
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint+"/in.php", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if err := doJSON(t.client, req, &created); err != nil {
+		return "", err
+	}
+	if created.Status != 1 {
+		return "", fmt.Errorf("2captcha refused the task: %s", created.Request)
+	}
+
+	query := url.Values{"key": {t.cfg.APIKey}, "action": {"get"}, "id": {created.Request}, "json": {"1"}}
+	return poll(ctx, t.cfg.PollInterval, func() (string, bool, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.endpoint+"/res.php?"+query.Encode(), nil)
+		if err != nil {
+			return "", false, err
+		}
+		var result twoCaptchaResponse
+		if err := doJSON(t.client, req, &result); err != nil {
+			return "", false, err
+		}
+		switch {
+		case result.Status == 1:
+			return result.Request, true, nil
+		case result.Request == "CAPCHA_NOT_READY":
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("2captcha failed: %s", result.Request)
+	})
+}
+
+// antiCaptcha speaks the Anti-Captcha API: a task is created with
+// createTask and its solution polled with getTaskResult.
+type antiCaptcha struct {
+	cfg      config.CaptchaConfig
+	endpoint string
+	client   *http.Client
+}
+
+func newAntiCaptcha(cfg config.CaptchaConfig) *antiCaptcha {
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = antiCaptchaEndpoint
+	}
+	return &antiCaptcha{cfg: cfg, endpoint: strings.TrimSuffix(endpoint, "/"), client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// antiCaptchaResponse is the JSON reply of createTask and getTaskResult.
+type antiCaptchaResponse struct {
+	ErrorID          int    `json:"errorId"`
+	ErrorDescription string `json:"errorDescription"`
+	TaskID           int64  `json:"taskId"`
+	Status           string `json:"status"`
+	Solution         struct {
+		GRecaptchaResponse string `json:"gRecaptchaResponse"`
+		Text               string `json:"text"`
+	} `json:"solution"`
+}
+
+func (a *antiCaptcha) Solve(ctx context.Context, challenge CaptchaChallenge) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, a.cfg.Timeout)
+	defer cancel()
+
+	var task map[string]interface{}
+	switch challenge.Kind {
+	case CaptchaReCaptcha:
+		task = map[string]interface{}{"type": "RecaptchaV2TaskProxyless", "websiteURL": challenge.PageURL, "websiteKey": challenge.SiteKey}
+	case CaptchaHCaptcha:
+		task = map[string]interface{}{"type": "HCaptchaTaskProxyless", "websiteURL": challenge.PageURL, "websiteKey": challenge.SiteKey}
+	case CaptchaImage:
+		task = map[string]interface{}{"type": "ImageToTextTask", "body": base64.StdEncoding.EncodeToString(challenge.Image)}
+	default:
+		return "", fmt.Errorf("unsupported captcha kind: %s", challenge.Kind)
+	}
+
+	var created antiCaptchaResponse
+	if err := a.call(ctx, "createTask", map[string]interface{}{"clientKey": a.cfg.APIKey, "task": task}, &created); err != nil {
+		return "", err
+	}
+	if created.ErrorID != 0 {
+		return "", fmt.Errorf("anti-captcha refused the task: %s", created.ErrorDescription)
+	}
+
+	return poll(ctx, a.cfg.PollInterval, func() (string, bool, error) {
+		var result antiCaptchaResponse
+		if err := a.call(ctx, "getTaskResult", map[string]interface{}{"clientKey": a.cfg.APIKey, "taskId": created.TaskID}, &result); err != nil {
+			return "", false, err
+		}
+		switch {
+		case result.ErrorID != 0:
+			return "", false, fmt.Errorf("anti-captcha failed: %s", result.ErrorDescription)
+		case result.Status != "ready":
+			return "", false, nil
+		case result.Solution.GRecaptchaResponse != "":
+			return result.Solution.GRecaptchaResponse, true, nil
+		}
+		return result.Solution.Text, true, nil
+	})
+}
+
+func (a *antiCaptcha) call(ctx context.Context, method string, body interface{}, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.endpoint+"/"+method, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return doJSON(a.client, req, out)
+}
+
+// doJSON sends req and decodes its JSON reply into out.
+func doJSON(client *http.Client, req *http.Request, out interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return fmt.Errorf("captcha service returned status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// poll calls check every interval until it reports an answer, fails or ctx
+// runs out.
+func poll(ctx context.Context, interval time.Duration, check func() (string, bool, error)) (string, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("no captcha answer in time: %v", ctx.Err())
+		case <-ticker.C:
+		}
+		answer, done, err := check()
+		if err != nil {
+			return "", err
+		}
+		if done {
+			return answer, nil
+		}
+	}
+}
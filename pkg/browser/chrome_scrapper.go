@@ -3,20 +3,34 @@ package browser
 import (
 	"context"
 	"fmt"
-	"log"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/chromedp/chromedp"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"price-comparison-api/internal/config"
 	"price-comparison-api/internal/models"
+	"price-comparison-api/internal/productid"
+	"price-comparison-api/pkg/tracing"
 )
 
 type ChromeScraper struct {
+	cfg           config.ChromeConfig
 	ctx           context.Context
 	allocCancel   context.CancelFunc
 	timeoutCancel context.CancelFunc
 	cancel        context.CancelFunc
+
+	// proxies is nil when Chrome connects directly
+	proxies *proxyPool
+	// solver is nil when no captcha-solving service is configured
+	solver    CaptchaSolver
+	startOnce sync.Once
+	startErr  error
 }
 
 type ShoppingSite struct {
@@ -24,37 +38,100 @@ type ShoppingSite struct {
 	Name string
 }
 
-func NewChromeScraper() *ChromeScraper {
+// AllocatorOptions turns the Chrome config into chromedp exec allocator flags.
+func AllocatorOptions(cfg config.ChromeConfig) []chromedp.ExecAllocatorOption {
 	opts := append(chromedp.DefaultExecAllocatorOptions[:],
-		chromedp.Flag("headless", true),
+		chromedp.Flag("headless", cfg.Headless),
 		chromedp.Flag("disable-gpu", true),
 		chromedp.Flag("disable-dev-shm-usage", true),
 		chromedp.Flag("disable-extensions", true),
-		chromedp.Flag("no-sandbox", true),
+		chromedp.Flag("no-sandbox", cfg.NoSandbox),
 		chromedp.Flag("disable-web-security", true),
-		chromedp.ExecPath("/Applications/Google Chrome.app/Contents/MacOS/Google Chrome"),
-		chromedp.UserAgent("Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36"),
+		chromedp.UserAgent(cfg.UserAgent),
 	)
+	if cfg.ExecPath != "" {
+		opts = append(opts, chromedp.ExecPath(cfg.ExecPath))
+	}
+	return opts
+}
+
+// NewAllocator returns the chromedp allocator for cfg's backend: a remote
+// browser at RemoteURL for the remote backend, a local Chrome otherwise.
+func NewAllocator(parent context.Context, cfg config.ChromeConfig) (context.Context, context.CancelFunc) {
+	if cfg.Backend == config.BrowserRemote {
+		return chromedp.NewRemoteAllocator(parent, cfg.RemoteURL)
+	}
+	return chromedp.NewExecAllocator(parent, AllocatorOptions(cfg)...)
+}
 
-	allocCtx, allocCancel := chromedp.NewExecAllocator(context.Background(), opts...)
+func NewChromeScraper(cfg config.ChromeConfig) *ChromeScraper {
+	allocCtx, allocCancel := NewAllocator(context.Background(), cfg)
 
 	// Create context without timeout in constructor
 	ctx, cancel := chromedp.NewContext(allocCtx)
 
+	proxies, err := newProxyPool(cfg.Proxies)
+	if err != nil {
+		log.Error().Err(err).Msg("Chrome proxies unavailable; connecting directly")
+	}
+	solver, err := NewCaptchaSolver(cfg.Captcha)
+	if err != nil {
+		log.Error().Err(err).Msg("Captcha solving disabled")
+	}
+
 	return &ChromeScraper{
+		cfg:         cfg,
 		ctx:         ctx,
 		allocCancel: allocCancel,
 		cancel:      cancel,
+		proxies:     proxies,
+		solver:      solver,
 	}
 }
 
-func (c *ChromeScraper) SearchUniversal(query, country string) ([]models.Product, error) {
+// newTab opens the tab for one scrape task, closed when parent is done.
+// Without proxies it's a plain new context of parent. With them, it's a
+// fresh browser context of the shared browser, so cookies don't carry over,
+// routed through the next proxy in turn.
+func (c *ChromeScraper) newTab(parent context.Context) (context.Context, context.CancelFunc, error) {
+	p := c.proxies.pick()
+	if p == nil {
+		ctx, cancel := chromedp.NewContext(parent)
+		return ctx, cancel, nil
+	}
+
+	// Browser contexts can only be created once the browser is running
+	c.startOnce.Do(func() {
+		c.startErr = chromedp.Run(c.ctx)
+	})
+	if c.startErr != nil {
+		return nil, nil, fmt.Errorf("failed to start Chrome: %v", c.startErr)
+	}
+
+	ctx, cancel := chromedp.NewContext(c.ctx, p.contextOption())
+	stop := context.AfterFunc(parent, cancel)
+	cancelTab := func() {
+		stop()
+		cancel()
+	}
+	if err := p.authenticate(ctx); err != nil {
+		cancelTab()
+		return nil, nil, fmt.Errorf("failed to set up proxy %s: %v", p.display, err)
+	}
+	zerolog.Ctx(parent).Debug().Msgf("Chrome: using proxy %s", p.display)
+	return ctx, cancelTab, nil
+}
+
+func (c *ChromeScraper) SearchUniversal(ctx context.Context, query, country string) ([]models.Product, error) {
+	logger := zerolog.Ctx(ctx).With().Str("scraper", "chrome").Str("country", country).Str("query", query).Logger()
+	ctx = logger.WithContext(ctx)
+
 	if c == nil {
-		log.Printf("Chrome scraper not available, skipping")
+		logger.Warn().Msg("Chrome scraper not available, skipping")
 		return []models.Product{}, nil
 	}
 
-	log.Printf("Chrome scraper: searching for '%s' in %s", query, country)
+	logger.Info().Msgf("Chrome scraper: searching for '%s' in %s", query, country)
 
 	var allProducts []models.Product
 
@@ -66,14 +143,14 @@ func (c *ChromeScraper) SearchUniversal(query, country string) ([]models.Product
 			break
 		}
 
-		products := c.scrapeDirectly(site.URL, site.Name, query, country)
+		products := c.scrapeDirectly(ctx, site.URL, site.Name, query, country)
 		allProducts = append(allProducts, products...)
 
 		// Add delay between sites
 		time.Sleep(2 * time.Second)
 	}
 
-	log.Printf("Chrome scraper: found %d products", len(allProducts))
+	logger.Info().Msgf("Chrome scraper: found %d products", len(allProducts))
 	return allProducts, nil
 }
 
@@ -111,32 +188,48 @@ func (c *ChromeScraper) getShoppingSites(query, country string) []ShoppingSite {
 	return sites
 }
 
-func (c *ChromeScraper) scrapeDirectly(siteURL, siteName, query, country string) []models.Product {
+func (c *ChromeScraper) scrapeDirectly(parent context.Context, siteURL, siteName, query, country string) []models.Product {
 	var products []models.Product
+	logger := zerolog.Ctx(parent)
+
+	_, span := tracing.Start(parent, "chromedp.run",
+		attribute.String("chrome.site", siteName),
+		attribute.String("chrome.url", siteURL),
+	)
+	defer func() {
+		span.SetAttributes(attribute.Int("scraper.products", len(products)))
+		span.End()
+	}()
 
-	log.Printf("Chrome: Scraping %s at %s", siteName, siteURL)
+	logger.Info().Msgf("Chrome: Scraping %s at %s", siteName, siteURL)
 
 	// Create a timeout context only for this specific scrape
-	ctx, cancel := context.WithTimeout(context.Background(), 45*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), c.cfg.Timeout)
 	defer cancel()
 
 	// Create a new browser context for this scrape
-	taskCtx, taskCancel := chromedp.NewContext(ctx)
+	taskCtx, taskCancel, err := c.newTab(ctx)
+	if err != nil {
+		logger.Warn().Err(err).Msgf("Chrome: could not open a tab for %s", siteName)
+		span.RecordError(err)
+		return products
+	}
 	defer taskCancel()
 
 	// Navigate and wait for page to load
-	err := chromedp.Run(taskCtx,
+	err = chromedp.Run(taskCtx,
 		chromedp.Navigate(siteURL),
 		chromedp.Sleep(3*time.Second),
 		chromedp.WaitVisible("body", chromedp.ByQuery),
 	)
 
 	if err != nil {
-		log.Printf("Chrome: Navigation error for %s: %v", siteName, err)
+		logger.Warn().Err(err).Msgf("Chrome: Navigation error for %s", siteName)
+		span.RecordError(err)
 		return products
 	}
 
-	log.Printf("Chrome: Successfully loaded %s", siteName)
+	logger.Info().Msgf("Chrome: Successfully loaded %s", siteName)
 
 	// Extract products based on site
 	if strings.Contains(siteName, "Amazon") {
@@ -147,22 +240,22 @@ func (c *ChromeScraper) scrapeDirectly(siteURL, siteName, query, country string)
 		products = c.extractWalmartProductsWithContext(taskCtx, query, country)
 	}
 
-	log.Printf("Chrome: Found %d products from %s", len(products), siteName)
+	logger.Info().Msgf("Chrome: Found %d products from %s", len(products), siteName)
 	return products
 }
 
 func (c *ChromeScraper) extractAmazonProductsWithContext(ctx context.Context, query, country string) []models.Product {
-	log.Printf("Chrome Amazon extraction temporarily disabled")
+	log.Warn().Msg("Chrome Amazon extraction temporarily disabled")
 	return []models.Product{}
 }
 
 func (c *ChromeScraper) extractEbayProductsWithContext(ctx context.Context, query, country string) []models.Product {
-	log.Printf("Chrome eBay extraction temporarily disabled")
+	log.Warn().Msg("Chrome eBay extraction temporarily disabled")
 	return []models.Product{}
 }
 
 func (c *ChromeScraper) extractWalmartProductsWithContext(ctx context.Context, query, country string) []models.Product {
-	log.Printf("Chrome Walmart extraction temporarily disabled")
+	log.Warn().Msg("Chrome Walmart extraction temporarily disabled")
 	return []models.Product{}
 }
 
@@ -174,7 +267,7 @@ func (c *ChromeScraper) findRelevantSites(query, country string) []string {
 	googleURL := fmt.Sprintf("https://www.google.com/search?q=%s",
 		url.QueryEscape(searchQuery))
 
-	log.Printf("Chrome: Searching Google with: %s", googleURL)
+	log.Info().Msgf("Chrome: Searching Google with: %s", googleURL)
 
 	err := chromedp.Run(c.ctx,
 		chromedp.Navigate(googleURL),
@@ -188,11 +281,11 @@ func (c *ChromeScraper) findRelevantSites(query, country string) []string {
 	)
 
 	if err != nil {
-		log.Printf("Chrome: Error finding sites: %v", err)
+		log.Warn().Err(err).Msg("Chrome: Error finding sites")
 		return []string{}
 	}
 
-	log.Printf("Chrome: Found %d relevant product links", len(links))
+	log.Info().Msgf("Chrome: Found %d relevant product links", len(links))
 	return links
 }
 
@@ -213,14 +306,13 @@ func (c *ChromeScraper) extractAmazonProducts(query, country string) []models.Pr
 	)
 
 	if err != nil {
-		log.Printf("Chrome: Error extracting Amazon products: %v", err)
+		log.Warn().Err(err).Msg("Chrome: Error extracting Amazon products")
 		return products
 	}
 
 	for _, data := range productData {
 		if c.isRelevantProduct(data["title"], query) {
 			product := models.Product{
-				ID:        fmt.Sprintf("chrome_amazon_%d", time.Now().UnixNano()),
 				Name:      data["title"],
 				Price:     c.cleanPrice(data["price"], country),
 				Currency:  c.getCurrencyForCountry(country),
@@ -230,6 +322,7 @@ func (c *ChromeScraper) extractAmazonProducts(query, country string) []models.Pr
 				ScrapedAt: time.Now(),
 				InStock:   true,
 			}
+			product.ID, product.SourceProductID = productid.For(config.ScraperAmazon, country, product.URL, product.Name)
 			products = append(products, product)
 		}
 	}
@@ -254,14 +347,13 @@ func (c *ChromeScraper) extractEbayProducts(query, country string) []models.Prod
 	)
 
 	if err != nil {
-		log.Printf("Chrome: Error extracting eBay products: %v", err)
+		log.Warn().Err(err).Msg("Chrome: Error extracting eBay products")
 		return products
 	}
 
 	for _, data := range productData {
 		if c.isRelevantProduct(data["title"], query) {
 			product := models.Product{
-				ID:        fmt.Sprintf("chrome_ebay_%d", time.Now().UnixNano()),
 				Name:      data["title"],
 				Price:     c.cleanPrice(data["price"], country),
 				Currency:  c.getCurrencyForCountry(country),
@@ -271,6 +363,7 @@ func (c *ChromeScraper) extractEbayProducts(query, country string) []models.Prod
 				ScrapedAt: time.Now(),
 				InStock:   true,
 			}
+			product.ID, product.SourceProductID = productid.For(config.ScraperEbay, country, product.URL, product.Name)
 			products = append(products, product)
 		}
 	}
@@ -297,14 +390,13 @@ func (c *ChromeScraper) extractFlipkartProducts(query, country string) []models.
 	)
 
 	if err != nil {
-		log.Printf("Chrome: Error extracting Flipkart products: %v", err)
+		log.Warn().Err(err).Msg("Chrome: Error extracting Flipkart products")
 		return products
 	}
 
 	for _, data := range productData {
 		if c.isRelevantProduct(data["title"], query) {
 			product := models.Product{
-				ID:        fmt.Sprintf("chrome_flipkart_%d", time.Now().UnixNano()),
 				Name:      data["title"],
 				Price:     c.cleanPrice(data["price"], country),
 				Currency:  "INR",
@@ -314,6 +406,7 @@ func (c *ChromeScraper) extractFlipkartProducts(query, country string) []models.
 				ScrapedAt: time.Now(),
 				InStock:   true,
 			}
+			product.ID, product.SourceProductID = productid.For(config.ScraperFlipkart, "IN", product.URL, product.Name)
 			products = append(products, product)
 		}
 	}
@@ -338,14 +431,13 @@ func (c *ChromeScraper) extractMyntraProducts(query, country string) []models.Pr
 	)
 
 	if err != nil {
-		log.Printf("Chrome: Error extracting Myntra products: %v", err)
+		log.Warn().Err(err).Msg("Chrome: Error extracting Myntra products")
 		return products
 	}
 
 	for _, data := range productData {
 		if c.isRelevantProduct(data["title"], query) {
 			product := models.Product{
-				ID:        fmt.Sprintf("myntra_%d", time.Now().UnixNano()),
 				Name:      data["title"],
 				Price:     data["price"],
 				URL:       data["link"],
@@ -355,6 +447,7 @@ func (c *ChromeScraper) extractMyntraProducts(query, country string) []models.Pr
 				ScrapedAt: time.Now(),
 				InStock:   true,
 			}
+			product.ID, product.SourceProductID = productid.For("myntra", "IN", product.URL, product.Name)
 			products = append(products, product)
 		}
 	}
@@ -379,14 +472,13 @@ func (c *ChromeScraper) extractWalmartProducts(query, country string) []models.P
 	)
 
 	if err != nil {
-		log.Printf("Chrome: Error extracting Walmart products: %v", err)
+		log.Warn().Err(err).Msg("Chrome: Error extracting Walmart products")
 		return products
 	}
 
 	for _, data := range productData {
 		if c.isRelevantProduct(data["title"], query) {
 			product := models.Product{
-				ID:        fmt.Sprintf("walmart_%d", time.Now().UnixNano()),
 				Name:      data["title"],
 				Price:     data["price"],
 				URL:       data["link"],
@@ -396,6 +488,7 @@ func (c *ChromeScraper) extractWalmartProducts(query, country string) []models.P
 				ScrapedAt: time.Now(),
 				InStock:   true,
 			}
+			product.ID, product.SourceProductID = productid.For(config.ScraperWalmart, country, product.URL, product.Name)
 			products = append(products, product)
 		}
 	}
@@ -406,7 +499,7 @@ func (c *ChromeScraper) extractWalmartProducts(query, country string) []models.P
 func (c *ChromeScraper) extractFromSite(siteURL, query, country string) []models.Product {
 	var products []models.Product
 
-	log.Printf("Chrome: Extracting from %s", siteURL)
+	log.Debug().Msgf("Chrome: Extracting from %s", siteURL)
 
 	var title, price, image string
 
@@ -431,23 +524,22 @@ func (c *ChromeScraper) extractFromSite(siteURL, query, country string) []models
 	)
 
 	if err != nil {
-		log.Printf("Chrome: Error extracting from %s: %v", siteURL, err)
+		log.Warn().Err(err).Msgf("Chrome: Error extracting from %s", siteURL)
 		return products
 	}
 
 	// Validate extracted data
 	if title == "" || len(title) < 5 {
-		log.Printf("Chrome: No valid title found for %s", siteURL)
+		log.Debug().Msgf("Chrome: No valid title found for %s", siteURL)
 		return products
 	}
 
 	if !c.isRelevantProduct(title, query) {
-		log.Printf("Chrome: Product not relevant: %s", title)
+		log.Debug().Msgf("Chrome: Product not relevant: %s", title)
 		return products
 	}
 
 	product := models.Product{
-		ID:        fmt.Sprintf("chrome_%d", time.Now().UnixNano()),
 		Name:      title,
 		Price:     c.cleanPrice(price, country),
 		Currency:  c.getCurrencyForCountry(country),
@@ -457,10 +549,11 @@ func (c *ChromeScraper) extractFromSite(siteURL, query, country string) []models
 		ScrapedAt: time.Now(),
 		InStock:   true,
 	}
+	product.ID, product.SourceProductID = productid.For("chrome", country, product.URL, product.Name)
 
 	if product.Price != "" {
 		products = append(products, product)
-		log.Printf("Chrome: Found product: %s - %s", product.Name, product.Price)
+		log.Debug().Msgf("Chrome: Found product: %s - %s", product.Name, product.Price)
 	}
 
 	return products
@@ -513,7 +606,7 @@ func (c *ChromeScraper) getCurrencyForCountry(country string) string {
 	currencies := map[string]string{
 		"US": "USD", "CA": "CAD", "IN": "INR", "UK": "GBP",
 		"DE": "EUR", "FR": "EUR", "IT": "EUR", "ES": "EUR",
-		"AU": "AUD", "JP": "JPY",
+		"AU": "AUD", "JP": "JPY", "MX": "MXN", "BR": "BRL", "AR": "ARS",
 	}
 
 	if currency, exists := currencies[strings.ToUpper(country)]; exists {
@@ -563,14 +656,14 @@ func (c *ChromeScraper) makeAbsoluteURL(baseURL, relativeURL string) string {
 	// Parse the base URL
 	base, err := url.Parse(baseURL)
 	if err != nil {
-		log.Printf("Error parsing base URL %s: %v", baseURL, err)
+		log.Warn().Err(err).Msgf("Error parsing base URL %s", baseURL)
 		return relativeURL
 	}
 
 	// Parse the relative URL
 	rel, err := url.Parse(relativeURL)
 	if err != nil {
-		log.Printf("Error parsing relative URL %s: %v", relativeURL, err)
+		log.Warn().Err(err).Msgf("Error parsing relative URL %s", relativeURL)
 		return relativeURL
 	}
 
@@ -579,10 +672,78 @@ func (c *ChromeScraper) makeAbsoluteURL(baseURL, relativeURL string) string {
 	return resolved.String()
 }
 
+// RenderHTML loads pageURL in a new tab of the shared browser and returns
+// the rendered document, for pages that build their content with JavaScript.
+func (c *ChromeScraper) RenderHTML(ctx context.Context, pageURL string) (string, error) {
+	return c.Render(ctx, pageURL, false)
+}
+
+// Render is RenderHTML, solving the page's captcha when solveCaptchas is
+// set and a captcha-solving service is configured.
+func (c *ChromeScraper) Render(ctx context.Context, pageURL string, solveCaptchas bool) (string, error) {
+	if c == nil {
+		return "", fmt.Errorf("chrome scraper not available")
+	}
+	solveCaptchas = solveCaptchas && c.solver != nil
+
+	_, span := tracing.Start(ctx, "chromedp.render", attribute.String("chrome.url", pageURL))
+	defer span.End()
+
+	taskCtx, taskCancel, err := c.newTab(c.ctx)
+	if err != nil {
+		span.RecordError(err)
+		return "", err
+	}
+	defer taskCancel()
+	timeout := c.cfg.Timeout
+	if solveCaptchas {
+		timeout += c.cfg.Captcha.Timeout
+	}
+	taskCtx, cancel := context.WithTimeout(taskCtx, timeout)
+	defer cancel()
+	stop := context.AfterFunc(ctx, cancel)
+	defer stop()
+
+	var html string
+	err = chromedp.Run(taskCtx,
+		chromedp.Navigate(pageURL),
+		chromedp.WaitReady("body", chromedp.ByQuery),
+		chromedp.Sleep(2*time.Second),
+		chromedp.OuterHTML("html", &html, chromedp.ByQuery),
+	)
+	if err != nil {
+		span.RecordError(err)
+		return "", fmt.Errorf("failed to render %s: %v", pageURL, err)
+	}
+
+	if solveCaptchas {
+		challenge, err := detectCaptcha(taskCtx, pageURL)
+		if err != nil {
+			zerolog.Ctx(ctx).Warn().Err(err).Msgf("Chrome: captcha check failed on %s", pageURL)
+		}
+		if challenge != nil {
+			span.SetAttributes(attribute.String("chrome.captcha", challenge.Kind))
+			html, err = c.solveCaptcha(zerolog.Ctx(ctx).WithContext(taskCtx), challenge)
+			if err != nil {
+				span.RecordError(err)
+				return "", fmt.Errorf("captcha on %s: %v", pageURL, err)
+			}
+		}
+	}
+	return html, nil
+}
+
 func (c *ChromeScraper) Close() {
+	if c == nil {
+		return
+	}
 	if c.cancel != nil {
 		c.cancel()
 	}
+	if c.allocCancel != nil {
+		c.allocCancel()
+	}
+	c.proxies.Close()
 }
 
 func (c *ChromeScraper) debugCurrentPage(siteName string) {
@@ -596,10 +757,9 @@ func (c *ChromeScraper) debugCurrentPage(siteName string) {
 	)
 
 	if err != nil {
-		log.Printf("Chrome debug error for %s: %v", siteName, err)
+		log.Warn().Err(err).Msgf("Chrome debug error for %s", siteName)
 		return
 	}
 
-	log.Printf("Chrome debug - Site: %s, Title: %s, URL: %s, Body length: %d",
-		siteName, title, url, bodyLength)
+	log.Debug().Msgf("Chrome debug - Site: %s, Title: %s, URL: %s, Body length: %d", siteName, title, url, bodyLength)
 }
@@ -0,0 +1,279 @@
+package browser
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/target"
+	"github.com/chromedp/chromedp"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/net/proxy"
+)
+
+// proxyPool hands Chrome's proxies out in turn, one per scrape task.
+type proxyPool struct {
+	proxies []*chromeProxy
+	next    atomic.Uint32
+}
+
+// chromeProxy is one configured proxy as Chrome uses it.
+type chromeProxy struct {
+	// server is the proxy Chrome connects to: the configured one or, for an
+	// authenticated SOCKS5 proxy, the local relay in front of it
+	server string
+	// display is the proxy URL without its password, for logs
+	display string
+	// username and password answer HTTP proxy auth challenges over CDP
+	username string
+	password string
+	relay    *socksRelay
+}
+
+// newProxyPool parses the configured proxy URLs. Chrome can't authenticate
+// to a SOCKS5 proxy itself, so those get a local relay that does.
+func newProxyPool(urls []string) (*proxyPool, error) {
+	if len(urls) == 0 {
+		return nil, nil
+	}
+	pool := &proxyPool{}
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" {
+			pool.Close()
+			return nil, fmt.Errorf("invalid proxy URL")
+		}
+		p := &chromeProxy{
+			server:  u.Scheme + "://" + u.Host,
+			display: u.Redacted(),
+		}
+		if u.User != nil {
+			p.username = u.User.Username()
+			p.password, _ = u.User.Password()
+		}
+		if u.Scheme == "socks5" && u.User != nil {
+			relay, err := newSOCKSRelay(u.Host, &proxy.Auth{User: p.username, Password: p.password})
+			if err != nil {
+				pool.Close()
+				return nil, fmt.Errorf("failed to start relay for %s: %v", p.display, err)
+			}
+			p.relay = relay
+			p.server = "socks5://" + relay.Addr()
+			p.username, p.password = "", ""
+		}
+		pool.proxies = append(pool.proxies, p)
+	}
+	return pool, nil
+}
+
+// pick returns the next proxy, or nil when none are configured.
+func (p *proxyPool) pick() *chromeProxy {
+	if p == nil || len(p.proxies) == 0 {
+		return nil
+	}
+	i := p.next.Add(1) - 1
+	return p.proxies[int(i)%len(p.proxies)]
+}
+
+// Close stops the SOCKS5 relays.
+func (p *proxyPool) Close() {
+	if p == nil {
+		return
+	}
+	for _, cp := range p.proxies {
+		if cp.relay != nil {
+			cp.relay.Close()
+		}
+	}
+}
+
+// contextOption routes a new browser context through the proxy.
+func (p *chromeProxy) contextOption() chromedp.ContextOption {
+	return chromedp.WithNewBrowserContext(func(params *target.CreateBrowserContextParams) *target.CreateBrowserContextParams {
+		return params.WithProxyServer(p.server)
+	})
+}
+
+// authenticate answers the proxy's auth challenges in the tab of ctx with
+// the proxy's credentials. It does nothing for proxies without any.
+func (p *chromeProxy) authenticate(ctx context.Context) error {
+	if p.username == "" {
+		return nil
+	}
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		switch ev := ev.(type) {
+		case *fetch.EventRequestPaused:
+			go func() {
+				_ = chromedp.Run(ctx, fetch.ContinueRequest(ev.RequestID))
+			}()
+		case *fetch.EventAuthRequired:
+			response := &fetch.AuthChallengeResponse{Response: fetch.AuthChallengeResponseResponseDefault}
+			if ev.AuthChallenge.Source == fetch.AuthChallengeSourceProxy {
+				response = &fetch.AuthChallengeResponse{
+					Response: fetch.AuthChallengeResponseResponseProvideCredentials,
+					Username: p.username,
+					Password: p.password,
+				}
+			}
+			go func() {
+				_ = chromedp.Run(ctx, fetch.ContinueWithAuth(ev.RequestID, response))
+			}()
+		}
+	})
+	return chromedp.Run(ctx, fetch.Enable().WithHandleAuthRequests(true))
+}
+
+// socksRelay is an unauthenticated SOCKS5 server on the loopback interface
+// that forwards every connection through an authenticated SOCKS5 proxy.
+type socksRelay struct {
+	listener net.Listener
+	dialer   proxy.Dialer
+	wg       sync.WaitGroup
+}
+
+func newSOCKSRelay(upstream string, auth *proxy.Auth) (*socksRelay, error) {
+	dialer, err := proxy.SOCKS5("tcp", upstream, auth, proxy.Direct)
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	r := &socksRelay{listener: listener, dialer: dialer}
+	r.wg.Add(1)
+	go r.serve()
+	return r, nil
+}
+
+// Addr is the host:port Chrome connects to.
+func (r *socksRelay) Addr() string {
+	return r.listener.Addr().String()
+}
+
+func (r *socksRelay) Close() {
+	r.listener.Close()
+	r.wg.Wait()
+}
+
+func (r *socksRelay) serve() {
+	defer r.wg.Done()
+	for {
+		conn, err := r.listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			if err := r.relay(conn); err != nil {
+				log.Debug().Err(err).Msg("SOCKS5 relay connection failed")
+			}
+		}()
+	}
+}
+
+// SOCKS5 protocol values (RFC 1928)
+const (
+	socksVersion     = 5
+	socksNoAuth      = 0
+	socksConnect     = 1
+	socksIPv4        = 1
+	socksDomain      = 3
+	socksIPv6        = 4
+	socksSucceeded   = 0
+	socksFailure     = 1
+	socksUnsupported = 7
+)
+
+// relay accepts one CONNECT request from Chrome and pipes it through the
+// upstream proxy.
+func (r *socksRelay) relay(conn net.Conn) error {
+	defer conn.Close()
+
+	// Greeting: version, method count, methods; we only offer "no auth"
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	if header[0] != socksVersion {
+		return fmt.Errorf("unsupported SOCKS version %d", header[0])
+	}
+	if _, err := io.ReadFull(conn, make([]byte, header[1])); err != nil {
+		return err
+	}
+	if _, err := conn.Write([]byte{socksVersion, socksNoAuth}); err != nil {
+		return err
+	}
+
+	// Request: version, command, reserved, address type, address, port
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return err
+	}
+	if request[1] != socksConnect {
+		r.reply(conn, socksUnsupported)
+		return fmt.Errorf("unsupported SOCKS command %d", request[1])
+	}
+	var host string
+	switch request[3] {
+	case socksIPv4, socksIPv6:
+		ip := make(net.IP, net.IPv4len)
+		if request[3] == socksIPv6 {
+			ip = make(net.IP, net.IPv6len)
+		}
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return err
+		}
+		host = ip.String()
+	case socksDomain:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return err
+		}
+		name := make([]byte, length[0])
+		if _, err := io.ReadFull(conn, name); err != nil {
+			return err
+		}
+		host = string(name)
+	default:
+		r.reply(conn, socksUnsupported)
+		return fmt.Errorf("unsupported SOCKS address type %d", request[3])
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return err
+	}
+
+	upstream, err := r.dialer.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))))
+	if err != nil {
+		r.reply(conn, socksFailure)
+		return err
+	}
+	defer upstream.Close()
+	if err := r.reply(conn, socksSucceeded); err != nil {
+		return err
+	}
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(upstream, conn)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(conn, upstream)
+		done <- struct{}{}
+	}()
+	<-done
+	return nil
+}
+
+func (r *socksRelay) reply(conn net.Conn, status byte) error {
+	_, err := conn.Write([]byte{socksVersion, status, 0, socksIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
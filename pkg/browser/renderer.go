@@ -0,0 +1,46 @@
+package browser
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"price-comparison-api/internal/config"
+)
+
+// Renderer loads pages in a headless browser, for retailers whose results
+// are built with JavaScript. Backends differ in how they get a browser:
+// launching a local Chrome with chromedp, connecting to a remote one over
+// the DevTools protocol, or driving one with go-rod.
+type Renderer interface {
+	// Render loads pageURL and returns the rendered document. With
+	// solveCaptchas, a captcha the page shows is handed to the configured
+	// solving service and the page it leads to is returned instead, on
+	// backends that support it.
+	Render(ctx context.Context, pageURL string, solveCaptchas bool) (string, error)
+	// Close shuts the browser down, or disconnects from a remote one.
+	Close()
+}
+
+// NewRenderer returns the rendering backend cfg selects. The browser is
+// only started, or connected to, when the first page is rendered.
+func NewRenderer(cfg config.ChromeConfig) (Renderer, error) {
+	switch cfg.Backend {
+	case "", config.BrowserChromedp, config.BrowserRemote:
+		return NewChromeScraper(cfg), nil
+	case config.BrowserRod:
+		if len(cfg.Proxies) > 0 || cfg.Captcha.Provider != "" {
+			log.Warn().Msg("The rod browser backend doesn't use chrome proxies or captcha solving")
+		}
+		return newRodRenderer(cfg), nil
+	}
+	return nil, fmt.Errorf("unknown browser backend %q", cfg.Backend)
+}
+
+// RenderFunc returns r's Render for one retailer, whose solveCaptchas
+// setting decides whether its captchas are solved.
+func RenderFunc(r Renderer, solveCaptchas bool) func(ctx context.Context, pageURL string) (string, error) {
+	return func(ctx context.Context, pageURL string) (string, error) {
+		return r.Render(ctx, pageURL, solveCaptchas)
+	}
+}
@@ -0,0 +1,132 @@
+package browser
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/launcher"
+	"github.com/go-rod/rod/lib/proto"
+	"go.opentelemetry.io/otel/attribute"
+	"price-comparison-api/internal/config"
+	"price-comparison-api/pkg/tracing"
+)
+
+// rodSettle is how long a page is given after loading to run the scripts
+// that build its results
+const rodSettle = 2 * time.Second
+
+// rodRenderer renders pages with go-rod. It connects to the browser at
+// RemoteURL, or else launches one: the Chrome at ExecPath, one found on the
+// system, or a Chromium rod downloads the first time.
+type rodRenderer struct {
+	cfg config.ChromeConfig
+
+	startOnce sync.Once
+	startErr  error
+	browser   *rod.Browser
+	launcher  *launcher.Launcher // nil when connected to a remote browser
+}
+
+func newRodRenderer(cfg config.ChromeConfig) *rodRenderer {
+	return &rodRenderer{cfg: cfg}
+}
+
+func (r *rodRenderer) start() error {
+	r.startOnce.Do(func() {
+		controlURL := r.cfg.RemoteURL
+		var err error
+		if controlURL != "" {
+			// http:// endpoints are resolved to the browser's websocket URL
+			if !strings.HasPrefix(controlURL, "ws") {
+				controlURL, err = launcher.ResolveURL(controlURL)
+			}
+		} else {
+			r.launcher = launcher.New().
+				Headless(r.cfg.Headless).
+				NoSandbox(r.cfg.NoSandbox).
+				Set("disable-gpu").
+				Set("disable-dev-shm-usage").
+				Set("disable-extensions")
+			// Without a Chrome at ExecPath, rod looks for one on the system
+			// and downloads Chromium when it finds none
+			if _, statErr := os.Stat(r.cfg.ExecPath); r.cfg.ExecPath != "" && statErr == nil {
+				r.launcher = r.launcher.Bin(r.cfg.ExecPath)
+			}
+			controlURL, err = r.launcher.Launch()
+		}
+		if err != nil {
+			r.startErr = fmt.Errorf("failed to start browser: %v", err)
+			return
+		}
+		b := rod.New().ControlURL(controlURL)
+		if err := b.Connect(); err != nil {
+			r.startErr = fmt.Errorf("failed to connect to browser: %v", err)
+			return
+		}
+		r.browser = b
+	})
+	return r.startErr
+}
+
+// Render loads pageURL in a new page. Captchas aren't solved on this
+// backend, so solveCaptchas is ignored.
+func (r *rodRenderer) Render(ctx context.Context, pageURL string, _ bool) (string, error) {
+	_, span := tracing.Start(ctx, "rod.render", attribute.String("chrome.url", pageURL))
+	defer span.End()
+
+	if err := r.start(); err != nil {
+		span.RecordError(err)
+		return "", err
+	}
+	page, err := r.browser.Page(proto.TargetCreateTarget{})
+	if err != nil {
+		span.RecordError(err)
+		return "", fmt.Errorf("failed to open page: %v", err)
+	}
+	defer page.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.Timeout)
+	defer cancel()
+	p := page.Context(ctx)
+
+	html, err := func() (string, error) {
+		if err := p.SetUserAgent(&proto.NetworkSetUserAgentOverride{UserAgent: r.cfg.UserAgent}); err != nil {
+			return "", err
+		}
+		if err := p.Navigate(pageURL); err != nil {
+			return "", err
+		}
+		if err := p.WaitLoad(); err != nil {
+			return "", err
+		}
+		select {
+		case <-time.After(rodSettle):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+		return p.HTML()
+	}()
+	if err != nil {
+		span.RecordError(err)
+		return "", fmt.Errorf("failed to render %s: %v", pageURL, err)
+	}
+	return html, nil
+}
+
+// Close closes a launched browser. A remote browser is left running for
+// its other clients.
+func (r *rodRenderer) Close() {
+	if r.launcher == nil {
+		return
+	}
+	if r.browser != nil {
+		_ = r.browser.Close()
+	}
+	r.launcher.Kill()
+	r.launcher.Cleanup()
+}
@@ -4,106 +4,331 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
-	"os"
-	"strconv"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"price-comparison-api/internal/apierr"
+	"price-comparison-api/internal/config"
 	"price-comparison-api/internal/models"
+	"price-comparison-api/pkg/metrics"
+	"price-comparison-api/pkg/tracing"
 )
 
 type RedisCache struct {
-	client *redis.Client
+	client redis.UniversalClient
+	mode   string
 	ttl    time.Duration
 	ctx    context.Context
+	// negativeTTL applies to empty and failed searches; staleFor keeps
+	// entries past ttl for stale-while-revalidate
+	negativeTTL time.Duration
+	staleFor    time.Duration
+
+	// fallback serves reads/writes while Redis is unreachable
+	fallback *DiskCache
+	healthy  atomic.Bool
+	// stop ends monitor, which signals running on exit
+	stop     chan struct{}
+	stopOnce sync.Once
+	running  sync.WaitGroup
+	// pending holds stats not yet flushed to Redis
+	pending statCounters
 }
 
-func NewRedisCache() *RedisCache {
-	redisURL := os.Getenv("REDIS_URL")
-	if redisURL == "" {
-		redisURL = "redis://localhost:6379"
+func NewRedisCache(cfg config.RedisConfig, diskCfg config.DiskCacheConfig) *RedisCache {
+	var fallback *DiskCache
+	if diskCfg.Enabled {
+		disk, err := NewDiskCache(diskCfg)
+		if err != nil {
+			log.Warn().Err(err).Msg("Disk cache fallback disabled")
+		} else {
+			fallback = disk
+		}
 	}
 
-	redisDB := 0
-	if db := os.Getenv("REDIS_DB"); db != "" {
-		if dbNum, err := strconv.Atoi(db); err == nil {
-			redisDB = dbNum
+	client, err := newRedisClient(cfg)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to create Redis client")
+		if fallback == nil {
+			return nil
 		}
 	}
+	ctx := context.Background()
 
-	ttlSeconds := 600 // 10 minutes default
-	if ttl := os.Getenv("CACHE_TTL"); ttl != "" {
-		if t, err := strconv.Atoi(ttl); err == nil {
-			ttlSeconds = t
+	r := &RedisCache{
+		client:      client,
+		mode:        cfg.Mode,
+		ttl:         cfg.TTL,
+		ctx:         ctx,
+		negativeTTL: cfg.NegativeTTL,
+		staleFor:    cfg.StaleWhileRevalidate,
+		fallback:    fallback,
+		stop:        make(chan struct{}),
+	}
+
+	// Test connection
+	if client != nil {
+		if _, err = client.Ping(ctx).Result(); err != nil {
+			log.Warn().Err(err).Msg("Redis connection failed")
+		} else {
+			r.healthy.Store(true)
+			log.Info().Msgf("Redis connected successfully, mode: %s, DB: %d, TTL: %d seconds", cfg.Mode, cfg.DB, int(cfg.TTL.Seconds()))
 		}
 	}
 
-	opt, err := redis.ParseURL(redisURL)
-	if err != nil {
-		log.Printf("Failed to parse Redis URL: %v", err)
-		return nil
+	if !r.healthy.Load() {
+		if fallback == nil {
+			if client != nil {
+				client.Close()
+			}
+			return nil
+		}
+		log.Info().Msg("Using disk cache until Redis becomes reachable")
 	}
 
-	// Use the redisDB variable here
-	opt.DB = redisDB
+	if client != nil {
+		r.running.Add(1)
+		go r.monitor()
+	}
 
-	client := redis.NewClient(opt)
-	ctx := context.Background()
+	return r
+}
 
-	// Test connection
-	_, err = client.Ping(ctx).Result()
-	if err != nil {
-		log.Printf("Redis connection failed: %v", err)
-		return nil
-	}
+// monitor pings Redis periodically so the cache switches back from the disk
+// fallback once Redis recovers (and over to it when Redis goes away), and
+// flushes the stats counted since the last ping.
+func (r *RedisCache) monitor() {
+	defer r.running.Done()
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
 
-	log.Printf("Redis connected successfully, DB: %d, TTL: %d seconds", redisDB, ttlSeconds)
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(r.ctx, 2*time.Second)
+			err := r.client.Ping(ctx).Err()
+			r.setHealthy(err == nil)
+			r.flushStats(ctx)
+			cancel()
+		}
+	}
+}
 
-	return &RedisCache{
-		client: client,
-		ttl:    time.Duration(ttlSeconds) * time.Second,
-		ctx:    ctx,
+func (r *RedisCache) setHealthy(healthy bool) {
+	if r.healthy.Swap(healthy) != healthy {
+		if healthy {
+			log.Info().Msg("Redis reachable again, leaving disk cache fallback")
+		} else {
+			log.Warn().Msg("Redis unreachable, switching to disk cache fallback")
+		}
 	}
 }
 
-func (r *RedisCache) GetSearchResults(key string) (*models.SearchResponse, error) {
-	if r == nil || r.client == nil {
-		return nil, fmt.Errorf("redis client not available")
+// redisUp reports whether calls should go to Redis rather than the fallback.
+func (r *RedisCache) redisUp() bool {
+	return r.client != nil && r.healthy.Load()
+}
+
+func (r *RedisCache) GetSearchResults(ctx context.Context, key string) (*models.SearchResponse, error) {
+	response, _, err := r.LookupSearchResults(ctx, key)
+	return response, err
+}
+
+// LookupSearchResults is GetSearchResults that also reports whether the
+// entry is stale: past the cache TTL and inside the stale-while-revalidate
+// window. Entries without products are negative ones and never stale, and
+// neither are entries served by the disk fallback.
+func (r *RedisCache) LookupSearchResults(ctx context.Context, key string) (response *models.SearchResponse, stale bool, err error) {
+	if !r.IsAvailable() {
+		return nil, false, fmt.Errorf("redis client not available")
 	}
 
-	val, err := r.client.Get(r.ctx, key).Result()
-	if err == redis.Nil {
-		return nil, nil // Cache miss
+	ctx, span := tracing.Start(ctx, "cache.get",
+		attribute.String("cache.key", key),
+		attribute.String("cache.backend", r.Backend()),
+	)
+	defer func() {
+		span.SetAttributes(
+			attribute.Bool("cache.hit", response != nil),
+			attribute.Bool("cache.stale", stale),
+		)
+		tracing.End(span, err)
+	}()
+
+	var val []byte
+	var remaining time.Duration
+	if r.redisUp() {
+		pipe := r.client.Pipeline()
+		get := pipe.Get(ctx, key)
+		var pttl *redis.DurationCmd
+		if r.staleFor > 0 {
+			pttl = pipe.PTTL(ctx, key)
+		}
+		pipe.Exec(ctx)
+
+		data, err := get.Bytes()
+		if err == redis.Nil {
+			metrics.CacheMiss("redis")
+			r.countLookup(false)
+			return nil, false, nil // Cache miss
+		}
+		if err != nil {
+			metrics.CacheError("redis")
+			if r.fallback == nil {
+				return nil, false, fmt.Errorf("redis get error: %v", err)
+			}
+			r.setHealthy(false)
+		} else {
+			metrics.CacheHit("redis")
+			r.countLookup(true)
+			val = data
+			if pttl != nil {
+				remaining = pttl.Val()
+			}
+		}
 	}
-	if err != nil {
-		return nil, fmt.Errorf("redis get error: %v", err)
+
+	if val == nil {
+		data, err := r.fallback.Get(key)
+		if err != nil {
+			metrics.CacheError("disk")
+			return nil, false, err
+		}
+		if data == nil {
+			metrics.CacheMiss("disk")
+			r.countLookup(false)
+			return nil, false, nil // Cache miss
+		}
+		metrics.CacheHit("disk")
+		r.countLookup(true)
+		val = data
 	}
 
-	var response models.SearchResponse
-	err = json.Unmarshal([]byte(val), &response)
-	if err != nil {
-		return nil, fmt.Errorf("json unmarshal error: %v", err)
+	response = &models.SearchResponse{}
+	if err := json.Unmarshal(val, response); err != nil {
+		return nil, false, fmt.Errorf("json unmarshal error: %v", err)
 	}
 
-	return &response, nil
+	stale = remaining > 0 && remaining < r.staleFor && len(response.Products) > 0
+	return response, stale, nil
+}
+
+func (r *RedisCache) SetSearchResults(ctx context.Context, key string, response *models.SearchResponse) error {
+	return r.setSearchResults(ctx, key, response, r.ttl+r.staleFor)
+}
+
+// SetNegativeResults caches a search that found nothing for the negative
+// TTL only, so a query that comes back empty is retried soon but not on
+// every request.
+func (r *RedisCache) SetNegativeResults(ctx context.Context, key string, response *models.SearchResponse) error {
+	return r.setSearchResults(ctx, key, response, r.negativeTTL)
 }
 
-func (r *RedisCache) SetSearchResults(key string, response *models.SearchResponse) error {
-	if r == nil || r.client == nil {
+func (r *RedisCache) setSearchResults(ctx context.Context, key string, response *models.SearchResponse, ttl time.Duration) (err error) {
+	if !r.IsAvailable() {
 		return fmt.Errorf("redis client not available")
 	}
 
+	ctx, span := tracing.Start(ctx, "cache.set",
+		attribute.String("cache.key", key),
+		attribute.String("cache.backend", r.Backend()),
+	)
+	defer func() { tracing.End(span, err) }()
+
 	data, err := json.Marshal(response)
 	if err != nil {
 		return fmt.Errorf("json marshal error: %v", err)
 	}
 
-	return r.client.Set(r.ctx, key, data, r.ttl).Err()
+	if err := r.Set(ctx, key, data, ttl); err != nil {
+		return err
+	}
+	r.countSet(len(data))
+	return nil
+}
+
+// SetSourceResults caches one source's results for ttl, or the cache TTL
+// when ttl is 0. Empty results get the negative TTL.
+func (r *RedisCache) SetSourceResults(ctx context.Context, key string, response *models.SearchResponse, ttl time.Duration) error {
+	if ttl <= 0 {
+		ttl = r.ttl
+	}
+	if len(response.Products) == 0 {
+		ttl = r.negativeTTL
+	}
+	return r.setSearchResults(ctx, key, response, ttl)
+}
+
+// GetSearchFailure returns the cached failure of a search, or nil when it
+// didn't fail recently.
+func (r *RedisCache) GetSearchFailure(ctx context.Context, key string) (*apierr.Error, error) {
+	data, err := r.Get(ctx, key)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	var resp models.ErrorResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("json unmarshal error: %v", err)
+	}
+	return apierr.FromResponse(resp), nil
+}
+
+// SetSearchFailure caches the error a search failed with for the negative
+// TTL, so retailers that are down or blocking aren't scraped again by
+// every request in a burst.
+func (r *RedisCache) SetSearchFailure(ctx context.Context, key string, failure error) error {
+	_, resp := apierr.Response(failure, "search_failed")
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("json marshal error: %v", err)
+	}
+	return r.Set(ctx, key, data, r.negativeTTL)
+}
+
+// Set stores raw data under key for ttl. The disk fallback keeps it for
+// ttl or its own TTL, whichever is shorter.
+func (r *RedisCache) Set(ctx context.Context, key string, data []byte, ttl time.Duration) error {
+	if !r.IsAvailable() {
+		return fmt.Errorf("redis client not available")
+	}
+	if r.redisUp() {
+		err := r.client.Set(ctx, key, data, ttl).Err()
+		if err == nil || r.fallback == nil {
+			return err
+		}
+		r.setHealthy(false)
+	}
+	return r.fallback.SetFor(key, data, ttl)
+}
+
+// Get returns the raw data stored under key, or nil on a miss.
+func (r *RedisCache) Get(ctx context.Context, key string) ([]byte, error) {
+	if !r.IsAvailable() {
+		return nil, fmt.Errorf("redis client not available")
+	}
+	if r.redisUp() {
+		data, err := r.client.Get(ctx, key).Bytes()
+		if err == redis.Nil {
+			return nil, nil
+		}
+		if err == nil || r.fallback == nil {
+			return data, err
+		}
+		r.setHealthy(false)
+	}
+	return r.fallback.Get(key)
 }
 
 func (r *RedisCache) GenerateSearchKey(params models.SearchParams) string {
-	key := fmt.Sprintf("search:%s:%s:p%d:l%d", params.Query, params.Country, params.Page, params.Limit)
+	key := fmt.Sprintf("search:%s:p%d:l%d", r.hashTag(params.Query+":"+params.Country), params.Page, params.Limit)
 
 	if params.Filters != nil {
 		if params.Filters.MinPrice > 0 {
@@ -121,6 +346,27 @@ func (r *RedisCache) GenerateSearchKey(params models.SearchParams) string {
 		if params.Filters.MinRating > 0 {
 			key += fmt.Sprintf(":rating%.1f", params.Filters.MinRating)
 		}
+		if params.Filters.MinDiscount > 0 {
+			key += fmt.Sprintf(":disc%.1f", params.Filters.MinDiscount)
+		}
+		if params.Filters.RequiresMembership != nil {
+			key += fmt.Sprintf(":member%t", *params.Filters.RequiresMembership)
+		}
+		if params.Filters.Category != "" {
+			key += fmt.Sprintf(":cat%s", params.Filters.Category)
+		}
+		if params.Filters.StrictOn() {
+			key += ":strict"
+		}
+		if params.Filters.Seller != "" {
+			key += fmt.Sprintf(":seller%s", strings.ToLower(strings.TrimSpace(params.Filters.Seller)))
+		}
+		if params.Filters.ExcludeMarketplaceSellers {
+			key += ":nomkt"
+		}
+		if params.Filters.Condition != "" {
+			key += fmt.Sprintf(":cond%s", strings.ToLower(params.Filters.Condition))
+		}
 	}
 
 	if params.Sort != nil {
@@ -130,54 +376,283 @@ func (r *RedisCache) GenerateSearchKey(params models.SearchParams) string {
 	return key
 }
 
+// GenerateSourceKey names one source's results for a query.
+func (r *RedisCache) GenerateSourceKey(query, country, source string) string {
+	return fmt.Sprintf("search:%s:src:%s", r.hashTag(query+":"+country), source)
+}
+
+// GenerateResultSetKey names a product set of a query kept as scraped, for
+// paging through it while the query's current product set is replaced.
+func (r *RedisCache) GenerateResultSetKey(query, country, id string) string {
+	return fmt.Sprintf("search:%s:set:%s", r.hashTag(query+":"+country), id)
+}
+
+// GenerateFailureKey names the cached failure of a query's last scrape.
+func (r *RedisCache) GenerateFailureKey(query, country string) string {
+	return fmt.Sprintf("search:%s:failed", r.hashTag(query+":"+country))
+}
+
+// GenerateProductsKey names the full, unfiltered product set of a query.
+// Any page, filter or personalization of the query can be built from it
+// without scraping again.
+func (r *RedisCache) GenerateProductsKey(query, country string) string {
+	return fmt.Sprintf("search:%s:all", r.hashTag(query+":"+country))
+}
+
+// GenerateQueryPattern is the DeleteKeys pattern matching every key of a
+// query in a country: its pages and filter variants, product sets, sources
+// and cached failure. In cluster mode the pattern has the keys' braces.
+func (r *RedisCache) GenerateQueryPattern(query, country string) string {
+	return fmt.Sprintf("search:%s:*", r.hashTag(globEscape(query+":"+country)))
+}
+
+// hashTag wraps the part of a key that must map to a single cluster slot, so
+// every page/filter variant of one query lives on the same shard and can be
+// handled by multi-key commands. Outside cluster mode keys are left as-is.
+func (r *RedisCache) hashTag(s string) string {
+	if r != nil && r.mode == ModeCluster {
+		return "{" + s + "}"
+	}
+	return s
+}
+
+// Close stops the health monitor, waits for a ping in flight, and closes
+// the fallback and the client. It is safe to call more than once.
 func (r *RedisCache) Close() error {
-	if r == nil || r.client == nil {
+	if r == nil {
 		return nil
 	}
+	closing := false
+	r.stopOnce.Do(func() {
+		if r.stop != nil {
+			close(r.stop)
+		}
+		closing = true
+	})
+	r.running.Wait()
+	if !closing {
+		return nil
+	}
+	if r.fallback != nil {
+		if err := r.fallback.Close(); err != nil {
+			log.Warn().Err(err).Msg("Failed to close disk cache")
+		}
+	}
+	if r.client == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(r.ctx, 2*time.Second)
+	r.flushStats(ctx)
+	cancel()
 	return r.client.Close()
 }
 
+// IsAvailable reports whether any cache backend (Redis or the disk fallback)
+// can serve requests.
 func (r *RedisCache) IsAvailable() bool {
-	return r != nil && r.client != nil
+	return r != nil && (r.redisUp() || r.fallback != nil)
 }
 
-func (r *RedisCache) GetStats() map[string]interface{} {
-	if r == nil || r.client == nil {
-		return map[string]interface{}{
-			"status": "unavailable",
-		}
-	}
-
-	info := r.client.Info(r.ctx, "memory").Val()
-	return map[string]interface{}{
-		"status":      "connected",
-		"ttl_seconds": int(r.ttl.Seconds()),
-		"memory_info": info,
+// Backend names the store currently serving requests: "redis" or "disk".
+func (r *RedisCache) Backend() string {
+	if r != nil && !r.redisUp() && r.fallback != nil {
+		return "disk"
 	}
+	return "redis"
 }
 
+// GetAllKeys lists the search cache keys, walking Redis with SCAN so a large
+// keyspace doesn't block it the way KEYS would.
 func (r *RedisCache) GetAllKeys() []string {
-	if r == nil || r.client == nil {
+	if !r.IsAvailable() {
 		return []string{}
 	}
-	keys, err := r.client.Keys(r.ctx, "search:*").Result()
+	if !r.redisUp() {
+		return r.fallback.Keys("search:")
+	}
+
+	keys := []string{}
+	var mu sync.Mutex
+	err := forEachNode(r.ctx, r.client, func(ctx context.Context, node redis.UniversalClient) error {
+		return scanKeys(ctx, node, "search:*", func(batch []string) error {
+			mu.Lock()
+			keys = append(keys, batch...)
+			mu.Unlock()
+			return nil
+		})
+	})
 	if err != nil {
 		return []string{}
 	}
 	return keys
 }
 
+// scanKeys walks the keys of one node matching pattern with SCAN, handing
+// them to fn a batch at a time.
+func scanKeys(ctx context.Context, node redis.UniversalClient, pattern string, fn func(keys []string) error) error {
+	var cursor uint64
+	for {
+		keys, next, err := node.Scan(ctx, cursor, pattern, scanBatch).Result()
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			if err := fn(keys); err != nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
 func (r *RedisCache) FlushCache() error {
-	if r == nil || r.client == nil {
+	if !r.IsAvailable() {
 		return fmt.Errorf("redis client not available")
 	}
-	return r.client.FlushDB(r.ctx).Err()
+	if r.fallback != nil {
+		if err := r.fallback.Flush(); err != nil {
+			log.Warn().Err(err).Msg("Failed to flush disk cache")
+		}
+	}
+	if !r.redisUp() {
+		return nil
+	}
+	return forEachNode(r.ctx, r.client, func(ctx context.Context, node redis.UniversalClient) error {
+		return node.FlushDB(ctx).Err()
+	})
+}
+
+// scanBatch is how many keys each SCAN asks for and each UNLINK removes.
+const scanBatch = 500
+
+// DeleteKeys removes the keys matching a Redis glob pattern, such as
+// "search:iphone*" (or "search:{iphone*" in cluster mode), from Redis and
+// the disk fallback, and returns how many were removed. Redis is walked with
+// SCAN and the keys UNLINKed in batches, so large caches are cleared without
+// blocking the server. A malformed pattern is an apierr.ErrValidation.
+func (r *RedisCache) DeleteKeys(ctx context.Context, pattern string) (int, error) {
+	if !r.IsAvailable() {
+		return 0, fmt.Errorf("redis client not available")
+	}
+	match, err := globRegexp(pattern)
+	if err != nil {
+		return 0, apierr.Validation("invalid_pattern", err.Error())
+	}
+
+	deleted := 0
+	if r.fallback != nil {
+		n, err := r.fallback.DeleteMatching(match.MatchString)
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to delete disk cache keys")
+		}
+		if !r.redisUp() {
+			return n, err
+		}
+		deleted += n
+	}
+
+	_, cluster := r.client.(*redis.ClusterClient)
+	var mu sync.Mutex
+	err = forEachNode(ctx, r.client, func(ctx context.Context, node redis.UniversalClient) error {
+		return scanKeys(ctx, node, pattern, func(keys []string) error {
+			n, err := unlink(ctx, node, keys, cluster)
+			mu.Lock()
+			deleted += n
+			mu.Unlock()
+			return err
+		})
+	})
+	return deleted, err
+}
+
+// unlink removes keys found on one node. A cluster node refuses multi-key
+// commands across slots, and keys of different queries live in different
+// slots, so in cluster mode the keys are unlinked one slot at a time.
+func unlink(ctx context.Context, node redis.UniversalClient, keys []string, cluster bool) (int, error) {
+	if !cluster {
+		n, err := node.Unlink(ctx, keys...).Result()
+		return int(n), err
+	}
+
+	bySlot := make(map[int][]string)
+	var slots []int
+	for _, key := range keys {
+		slot := keySlot(key)
+		if _, ok := bySlot[slot]; !ok {
+			slots = append(slots, slot)
+		}
+		bySlot[slot] = append(bySlot[slot], key)
+	}
+	deleted := 0
+	for _, slot := range slots {
+		n, err := node.Unlink(ctx, bySlot[slot]...).Result()
+		if err != nil {
+			return deleted, err
+		}
+		deleted += int(n)
+	}
+	return deleted, nil
+}
+
+// globRegexp compiles a Redis glob pattern (*, ?, [...] and \ escapes) for
+// matching keys outside Redis.
+func globRegexp(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("empty key pattern")
+	}
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			}
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed [ in key pattern")
+			}
+			// Classes read the same way in both syntaxes, ^ negation included
+			class := pattern[i+1 : i+1+end]
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// globEscape escapes the characters a Redis glob pattern reads as special.
+func globEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
 }
 
 func (r *RedisCache) GetKeyTTL(key string) time.Duration {
-	if r == nil || r.client == nil {
+	if !r.IsAvailable() {
 		return 0
 	}
+	if !r.redisUp() {
+		return r.fallback.TTL(key)
+	}
 	ttl, err := r.client.TTL(r.ctx, key).Result()
 	if err != nil {
 		return 0
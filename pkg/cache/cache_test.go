@@ -0,0 +1,122 @@
+package cache
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+
+	"price-comparison-api/internal/config"
+	"price-comparison-api/internal/models"
+)
+
+func TestGenerateSearchKeyCondition(t *testing.T) {
+	r := &RedisCache{}
+	search := func(condition string) models.SearchParams {
+		return models.SearchParams{
+			Query:   "iphone",
+			Country: "US",
+			Page:    1,
+			Limit:   20,
+			Filters: &models.Filters{Condition: condition},
+		}
+	}
+
+	keys := make(map[string]string)
+	for _, condition := range append([]string{""}, models.Conditions...) {
+		key := r.GenerateSearchKey(search(condition))
+		if other, ok := keys[key]; ok {
+			t.Errorf("conditions %q and %q share the key %s", condition, other, key)
+		}
+		keys[key] = condition
+	}
+
+	if got, want := r.GenerateSearchKey(search("Used")), r.GenerateSearchKey(search("used")); got != want {
+		t.Errorf("condition Used has key %s, want %s as for used", got, want)
+	}
+}
+
+func TestGenerateQueryPattern(t *testing.T) {
+	for _, mode := range []string{ModeStandalone, ModeCluster} {
+		r := &RedisCache{mode: mode}
+		match, err := globRegexp(r.GenerateQueryPattern("iphone", "US"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		search := models.SearchParams{Query: "iphone", Country: "US", Page: 2, Limit: 20, Filters: &models.Filters{Condition: "used"}}
+		for _, key := range []string{
+			r.GenerateSearchKey(search),
+			r.GenerateProductsKey("iphone", "US"),
+			r.GenerateSourceKey("iphone", "US", "amazon"),
+			r.GenerateResultSetKey("iphone", "US", "abc123"),
+			r.GenerateFailureKey("iphone", "US"),
+		} {
+			if !match.MatchString(key) {
+				t.Errorf("%s: pattern misses %s", mode, key)
+			}
+		}
+		for _, key := range []string{
+			r.GenerateProductsKey("iphone 15", "US"),
+			r.GenerateProductsKey("iphone", "UK"),
+		} {
+			if match.MatchString(key) {
+				t.Errorf("%s: pattern matches another query's %s", mode, key)
+			}
+		}
+	}
+}
+
+func TestGenerateQueryPatternCluster(t *testing.T) {
+	r := &RedisCache{mode: ModeCluster}
+	key := r.GenerateProductsKey("iphone 15", "US")
+	if key != "search:{iphone 15:US}:all" {
+		t.Fatalf("cluster key %s, want the query and country in braces", key)
+	}
+
+	// A plain prefix misses cluster keys; the braced form finds them
+	for pattern, want := range map[string]bool{"search:iphone*": false, "search:{iphone*": true} {
+		match, err := globRegexp(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := match.MatchString(key); got != want {
+			t.Errorf("%s matches %s: %t, want %t", pattern, key, got, want)
+		}
+	}
+
+	// Glob characters in a query match only themselves
+	match, err := globRegexp(r.GenerateQueryPattern("usb*", "US"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if match.MatchString(r.GenerateProductsKey("usb c cable", "US")) {
+		t.Error("the * in the query usb* matched usb c cable")
+	}
+	if !match.MatchString(r.GenerateProductsKey("usb*", "US")) {
+		t.Error("pattern misses the query usb*")
+	}
+}
+
+// TestCloseStopsMonitor closes a cache whose health monitor is running, as
+// with Redis down and the disk cache standing in; run with -race.
+func TestCloseStopsMonitor(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := lis.Addr().String()
+	lis.Close()
+
+	r := NewRedisCache(
+		config.RedisConfig{Mode: ModeStandalone, URL: "redis://" + addr},
+		config.DiskCacheConfig{Enabled: true, Path: filepath.Join(t.TempDir(), "cache.db")},
+	)
+	if r == nil {
+		t.Fatal("no cache with the disk fallback enabled")
+	}
+	for i := 0; i < 2; i++ {
+		if err := r.Close(); err != nil {
+			t.Errorf("close %d: %v", i+1, err)
+		}
+	}
+}
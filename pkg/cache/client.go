@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"price-comparison-api/internal/config"
+)
+
+// Supported values for the redis mode setting
+const (
+	ModeStandalone = "standalone"
+	ModeCluster    = "cluster"
+	ModeSentinel   = "sentinel"
+)
+
+// newRedisClient builds the client matching the configured deployment mode.
+// Standalone uses the URL; cluster and sentinel use Addrs as their seed nodes.
+func newRedisClient(cfg config.RedisConfig) (redis.UniversalClient, error) {
+	switch cfg.Mode {
+	case ModeCluster:
+		if len(cfg.Addrs) == 0 {
+			return nil, fmt.Errorf("redis addrs are required in cluster mode")
+		}
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:          cfg.Addrs,
+			Password:       cfg.Password,
+			MaxRedirects:   8,
+			RouteByLatency: cfg.RouteByLatency,
+			DialTimeout:    5 * time.Second,
+			ReadTimeout:    3 * time.Second,
+			WriteTimeout:   3 * time.Second,
+		}), nil
+
+	case ModeSentinel:
+		if len(cfg.Addrs) == 0 {
+			return nil, fmt.Errorf("sentinel addrs are required in sentinel mode")
+		}
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       cfg.MasterName,
+			SentinelAddrs:    cfg.Addrs,
+			SentinelPassword: cfg.SentinelPassword,
+			Password:         cfg.Password,
+			DB:               cfg.DB,
+			// Retry across a master switch instead of failing the request
+			MaxRetries:      5,
+			MinRetryBackoff: 100 * time.Millisecond,
+			MaxRetryBackoff: 2 * time.Second,
+			DialTimeout:     5 * time.Second,
+			ReadTimeout:     3 * time.Second,
+			WriteTimeout:    3 * time.Second,
+		}), nil
+
+	default:
+		opt, err := redis.ParseURL(cfg.URL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse Redis URL: %v", err)
+		}
+
+		opt.DB = cfg.DB
+		if cfg.Password != "" && opt.Password == "" {
+			opt.Password = cfg.Password
+		}
+		return redis.NewClient(opt), nil
+	}
+}
+
+// forEachNode runs fn against every node that owns keys. In cluster mode that
+// is each master shard, otherwise it's the single client.
+func forEachNode(ctx context.Context, client redis.UniversalClient, fn func(ctx context.Context, node redis.UniversalClient) error) error {
+	if cluster, ok := client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			return fn(ctx, node)
+		})
+	}
+	return fn(ctx, client)
+}
+
+// slotCount is how many hash slots a Redis cluster splits keys into.
+const slotCount = 16384
+
+// keySlot returns the cluster hash slot of a key: the CRC16 of its hash tag,
+// the part between the first { and the next }, or of the whole key when the
+// tag is missing or empty.
+func keySlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	var crc uint16
+	for i := 0; i < len(key); i++ {
+		crc ^= uint16(key[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return int(crc) % slotCount
+}
+
+// CheckRedis opens a short-lived connection and pings Redis. It's meant for
+// startup checks and doesn't touch the disk fallback.
+func CheckRedis(ctx context.Context, cfg config.RedisConfig) error {
+	client, err := newRedisClient(cfg)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	return client.Ping(ctx).Err()
+}
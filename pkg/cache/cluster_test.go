@@ -0,0 +1,237 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+	"price-comparison-api/internal/models"
+)
+
+func TestKeySlot(t *testing.T) {
+	// Slots as CLUSTER KEYSLOT reports them
+	for _, tc := range []struct {
+		key  string
+		slot int
+	}{
+		{"123456789", 12739},
+		{"{}foo", 9500},
+		{"foo{}", 5542},
+		{"foo{}{bar}", 8363},
+	} {
+		if got := keySlot(tc.key); got != tc.slot {
+			t.Errorf("keySlot(%q) = %d, want %d", tc.key, got, tc.slot)
+		}
+	}
+
+	// Keys with the same hash tag share a slot
+	for _, tc := range []struct {
+		one, two string
+	}{
+		{"foo{bar}", "bar"},
+		{"{foo}bar", "foo"},
+		{"{user1000}.following", "{user1000}.followers"},
+		{"foo{{bar}}zap", "{bar"},
+		{"foo{bar}{zap}", "bar"},
+	} {
+		if keySlot(tc.one) != keySlot(tc.two) {
+			t.Errorf("%q and %q are in slots %d and %d, want the same", tc.one, tc.two, keySlot(tc.one), keySlot(tc.two))
+		}
+	}
+}
+
+// fakeNode is a cluster master owning every slot. It knows the handful of
+// commands DeleteKeys sends and, like a real node, refuses multi-key
+// commands across slots with CROSSSLOT.
+type fakeNode struct {
+	lis net.Listener
+
+	mu   sync.Mutex
+	keys map[string]bool
+}
+
+func newFakeNode(t *testing.T, keys ...string) *fakeNode {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := &fakeNode{lis: lis, keys: make(map[string]bool)}
+	for _, key := range keys {
+		n.keys[key] = true
+	}
+	go n.serve()
+	t.Cleanup(func() { lis.Close() })
+	return n
+}
+
+func (n *fakeNode) serve() {
+	for {
+		conn, err := n.lis.Accept()
+		if err != nil {
+			return
+		}
+		go n.handle(conn)
+	}
+}
+
+func (n *fakeNode) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		if _, err := io.WriteString(conn, n.reply(args)); err != nil {
+			return
+		}
+	}
+}
+
+func (n *fakeNode) reply(args []string) string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "CLIENT", "READONLY":
+		return "+OK\r\n"
+	case "SCAN":
+		// SCAN cursor MATCH pattern COUNT n: every match in one go
+		match, err := globRegexp(args[3])
+		if err != nil {
+			return "-ERR " + err.Error() + "\r\n"
+		}
+		var found []string
+		for key := range n.keys {
+			if match.MatchString(key) {
+				found = append(found, key)
+			}
+		}
+		sort.Strings(found)
+		reply := "*2\r\n$1\r\n0\r\n*" + strconv.Itoa(len(found)) + "\r\n"
+		for _, key := range found {
+			reply += bulk(key)
+		}
+		return reply
+	case "UNLINK":
+		for _, key := range args[2:] {
+			if keySlot(key) != keySlot(args[1]) {
+				return "-CROSSSLOT Keys in request don't hash to the same slot\r\n"
+			}
+		}
+		deleted := 0
+		for _, key := range args[1:] {
+			if n.keys[key] {
+				delete(n.keys, key)
+				deleted++
+			}
+		}
+		return ":" + strconv.Itoa(deleted) + "\r\n"
+	}
+	return "-ERR unknown command '" + args[0] + "'\r\n"
+}
+
+func (n *fakeNode) remaining() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	var keys []string
+	for key := range n.keys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// readCommand reads one command sent as a RESP array of bulk strings.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil || count < 1 {
+		return nil, fmt.Errorf("unexpected command %q", line)
+	}
+	args := make([]string, count)
+	for i := range args {
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, fmt.Errorf("unexpected argument %q", line)
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func bulk(s string) string {
+	return "$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n"
+}
+
+func TestDeleteKeysCluster(t *testing.T) {
+	r := &RedisCache{mode: ModeCluster}
+	search := models.SearchParams{Query: "iphone", Country: "US", Page: 1, Limit: 20}
+	iphone := []string{
+		r.GenerateSearchKey(search),
+		r.GenerateProductsKey("iphone", "US"),
+		r.GenerateSourceKey("iphone", "US", "amazon"),
+	}
+	others := []string{
+		r.GenerateProductsKey("iphone", "UK"),
+		r.GenerateProductsKey("laptop", "US"),
+		r.GenerateSourceKey("headphones", "DE", "amazon"),
+	}
+	node := newFakeNode(t, append(append([]string{}, iphone...), others...)...)
+
+	client := redis.NewClusterClient(&redis.ClusterOptions{
+		ClusterSlots: func(context.Context) ([]redis.ClusterSlot, error) {
+			return []redis.ClusterSlot{{Start: 0, End: slotCount - 1, Nodes: []redis.ClusterNode{{Addr: node.lis.Addr().String()}}}}, nil
+		},
+		Protocol:        2,
+		DisableIdentity: true,
+	})
+	defer client.Close()
+	r.client = client
+	r.healthy.Store(true)
+
+	ctx := context.Background()
+	for _, tc := range []struct {
+		name, pattern string
+		deleted       int
+		remaining     []string
+	}{
+		// One query's keys share a slot
+		{"one query", r.GenerateQueryPattern("iphone", "US"), len(iphone), others},
+		// Every other pattern spans slots
+		{"every search", "search:*", len(others), nil},
+	} {
+		deleted, err := r.DeleteKeys(ctx, tc.pattern)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if deleted != tc.deleted {
+			t.Errorf("%s: deleted %d keys, want %d", tc.name, deleted, tc.deleted)
+		}
+		want := append([]string{}, tc.remaining...)
+		sort.Strings(want)
+		if got := node.remaining(); strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("%s: left %v, want %v", tc.name, got, want)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	bolt "go.etcd.io/bbolt"
+	"price-comparison-api/internal/config"
+)
+
+var diskBucket = []byte("search")
+
+// DiskCache is a small bbolt-backed cache used while Redis is unreachable.
+// It keeps a shorter TTL and a hard cap on the number of entries so it never
+// grows into a second source of truth.
+type DiskCache struct {
+	db         *bolt.DB
+	ttl        time.Duration
+	maxEntries int
+}
+
+type diskEntry struct {
+	ExpiresAt time.Time       `json:"expires_at"`
+	StoredAt  time.Time       `json:"stored_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+func NewDiskCache(cfg config.DiskCacheConfig) (*DiskCache, error) {
+	path := cfg.Path
+	if path == "" {
+		path = filepath.Join(os.TempDir(), "price-comparison-cache.db")
+	}
+
+	ttl := cfg.TTL
+	if ttl <= 0 {
+		ttl = 2 * time.Minute // deliberately shorter than Redis
+	}
+
+	maxEntries := cfg.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = 500
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create disk cache directory: %v", err)
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open disk cache: %v", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(diskBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to init disk cache: %v", err)
+	}
+
+	log.Info().Msgf("Disk cache ready at %s, TTL: %d seconds, max entries: %d", path, int(ttl.Seconds()), maxEntries)
+
+	return &DiskCache{
+		db:         db,
+		ttl:        ttl,
+		maxEntries: maxEntries,
+	}, nil
+}
+
+// Get returns the raw payload stored under key, or nil on a miss/expiry.
+func (d *DiskCache) Get(key string) ([]byte, error) {
+	var data []byte
+	expired := false
+
+	err := d.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket(diskBucket).Get([]byte(key))
+		if raw == nil {
+			return nil
+		}
+		var entry diskEntry
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			return err
+		}
+		if time.Now().After(entry.ExpiresAt) {
+			expired = true
+			return nil
+		}
+		data = append([]byte(nil), entry.Data...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("disk cache get error: %v", err)
+	}
+
+	if expired {
+		d.Delete(key)
+	}
+	return data, nil
+}
+
+// Set stores data under key and evicts the oldest entries over the size cap.
+func (d *DiskCache) Set(key string, data []byte) error {
+	return d.SetFor(key, data, d.ttl)
+}
+
+// SetFor is Set with an entry TTL shorter than the cache's; longer ones
+// are cut to it.
+func (d *DiskCache) SetFor(key string, data []byte, ttl time.Duration) error {
+	if ttl <= 0 || ttl > d.ttl {
+		ttl = d.ttl
+	}
+	now := time.Now()
+	raw, err := json.Marshal(diskEntry{
+		ExpiresAt: now.Add(ttl),
+		StoredAt:  now,
+		Data:      data,
+	})
+	if err != nil {
+		return fmt.Errorf("json marshal error: %v", err)
+	}
+
+	return d.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(diskBucket)
+		if err := bucket.Put([]byte(key), raw); err != nil {
+			return err
+		}
+		return d.evict(bucket, now)
+	})
+}
+
+// evict drops expired entries and then the oldest ones until the bucket is
+// back under maxEntries.
+func (d *DiskCache) evict(bucket *bolt.Bucket, now time.Time) error {
+	type stored struct {
+		key      string
+		storedAt time.Time
+	}
+
+	var live []stored
+	var remove []string
+
+	err := bucket.ForEach(func(k, v []byte) error {
+		var entry diskEntry
+		if err := json.Unmarshal(v, &entry); err != nil || now.After(entry.ExpiresAt) {
+			remove = append(remove, string(k))
+			return nil
+		}
+		live = append(live, stored{key: string(k), storedAt: entry.StoredAt})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if over := len(live) - d.maxEntries; over > 0 {
+		sort.Slice(live, func(i, j int) bool { return live[i].storedAt.Before(live[j].storedAt) })
+		for _, s := range live[:over] {
+			remove = append(remove, s.key)
+		}
+	}
+
+	for _, key := range remove {
+		if err := bucket.Delete([]byte(key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *DiskCache) Delete(key string) error {
+	return d.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(diskBucket).Delete([]byte(key))
+	})
+}
+
+// DeleteMatching removes every key match accepts and returns how many it
+// removed.
+func (d *DiskCache) DeleteMatching(match func(key string) bool) (int, error) {
+	deleted := 0
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(diskBucket)
+		var keys [][]byte
+		bucket.ForEach(func(k, _ []byte) error {
+			if match(string(k)) {
+				keys = append(keys, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		for _, k := range keys {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		deleted = len(keys)
+		return nil
+	})
+	return deleted, err
+}
+
+// Keys lists the non-expired keys with the given prefix.
+func (d *DiskCache) Keys(prefix string) []string {
+	keys := []string{}
+	now := time.Now()
+
+	d.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(diskBucket).ForEach(func(k, v []byte) error {
+			if !strings.HasPrefix(string(k), prefix) {
+				return nil
+			}
+			var entry diskEntry
+			if err := json.Unmarshal(v, &entry); err == nil && now.Before(entry.ExpiresAt) {
+				keys = append(keys, string(k))
+			}
+			return nil
+		})
+	})
+	return keys
+}
+
+func (d *DiskCache) TTL(key string) time.Duration {
+	var ttl time.Duration
+	d.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket(diskBucket).Get([]byte(key))
+		if raw == nil {
+			return nil
+		}
+		var entry diskEntry
+		if err := json.Unmarshal(raw, &entry); err == nil {
+			ttl = time.Until(entry.ExpiresAt)
+		}
+		return nil
+	})
+	if ttl < 0 {
+		return 0
+	}
+	return ttl
+}
+
+func (d *DiskCache) Flush() error {
+	return d.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(diskBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucket(diskBucket)
+		return err
+	})
+}
+
+func (d *DiskCache) Stats() map[string]interface{} {
+	entries := 0
+	d.db.View(func(tx *bolt.Tx) error {
+		entries = tx.Bucket(diskBucket).Stats().KeyN
+		return nil
+	})
+	return map[string]interface{}{
+		"path":        d.db.Path(),
+		"entries":     entries,
+		"max_entries": d.maxEntries,
+		"ttl_seconds": int(d.ttl.Seconds()),
+	}
+}
+
+func (d *DiskCache) Close() error {
+	return d.db.Close()
+}
@@ -0,0 +1,176 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+// Stats describes the cache as /cache/stats reports it. Hits, Misses and
+// Sets count search lookups and writes across every server sharing the
+// Redis, since they were last flushed.
+type Stats struct {
+	// Status is connected, fallback (the disk cache is serving) or unavailable
+	Status     string `json:"status"`
+	Backend    string `json:"backend,omitempty"`
+	Mode       string `json:"mode,omitempty"`
+	TTLSeconds int    `json:"ttl_seconds,omitempty"`
+
+	Hits     int64   `json:"hits"`
+	Misses   int64   `json:"misses"`
+	Sets     int64   `json:"sets"`
+	HitRatio float64 `json:"hit_ratio"`
+	// Keys counts every key of the serving backend
+	Keys int64 `json:"keys"`
+	// AvgEntryBytes is the average size of the entries written
+	AvgEntryBytes int64 `json:"avg_entry_bytes"`
+	// UsedMemoryBytes is Redis' used_memory, summed over cluster masters
+	UsedMemoryBytes int64 `json:"used_memory_bytes,omitempty"`
+
+	DiskCache map[string]interface{} `json:"disk_cache,omitempty"`
+}
+
+// statCounters are the lookups and writes counted since they were last
+// added to the shared counters in Redis.
+type statCounters struct {
+	hits     atomic.Int64
+	misses   atomic.Int64
+	sets     atomic.Int64
+	setBytes atomic.Int64
+}
+
+// Names of the shared counters
+var statNames = []string{"hits", "misses", "sets", "set_bytes"}
+
+func (s *statCounters) counters() []*atomic.Int64 {
+	return []*atomic.Int64{&s.hits, &s.misses, &s.sets, &s.setBytes}
+}
+
+// statsKey names a shared counter. In cluster mode all of them hash to one
+// slot, so they can be read with a single MGET.
+func (r *RedisCache) statsKey(name string) string {
+	return fmt.Sprintf("stats:%s:%s", r.hashTag("cache"), name)
+}
+
+func (r *RedisCache) countLookup(hit bool) {
+	if hit {
+		r.pending.hits.Add(1)
+	} else {
+		r.pending.misses.Add(1)
+	}
+}
+
+func (r *RedisCache) countSet(bytes int) {
+	r.pending.sets.Add(1)
+	r.pending.setBytes.Add(int64(bytes))
+}
+
+// flushStats adds the locally counted lookups and writes to the shared
+// counters with INCRBY. Counts that can't be written are kept for the next
+// flush.
+func (r *RedisCache) flushStats(ctx context.Context) {
+	if !r.redisUp() {
+		return
+	}
+	counters := r.pending.counters()
+	deltas := make([]int64, len(counters))
+	pipe := r.client.Pipeline()
+	for i, c := range counters {
+		if deltas[i] = c.Swap(0); deltas[i] != 0 {
+			pipe.IncrBy(ctx, r.statsKey(statNames[i]), deltas[i])
+		}
+	}
+	if pipe.Len() == 0 {
+		return
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		for i, c := range counters {
+			c.Add(deltas[i])
+		}
+		log.Debug().Err(err).Msg("Failed to flush cache stats")
+	}
+}
+
+// GetStats returns the shared counters plus what this server hasn't flushed
+// yet, and the size of the serving backend.
+func (r *RedisCache) GetStats() Stats {
+	if !r.IsAvailable() {
+		return Stats{Status: "unavailable"}
+	}
+
+	stats := Stats{
+		Status:     "connected",
+		Backend:    r.Backend(),
+		Mode:       r.mode,
+		TTLSeconds: int(r.ttl.Seconds()),
+	}
+	counts := make([]int64, len(statNames))
+	for i, c := range r.pending.counters() {
+		counts[i] = c.Load()
+	}
+
+	if r.redisUp() {
+		ctx, cancel := context.WithTimeout(r.ctx, 2*time.Second)
+		defer cancel()
+		keys := make([]string, len(statNames))
+		for i, name := range statNames {
+			keys[i] = r.statsKey(name)
+		}
+		if values, err := r.client.MGet(ctx, keys...).Result(); err == nil {
+			for i, v := range values {
+				if s, ok := v.(string); ok {
+					n, _ := strconv.ParseInt(s, 10, 64)
+					counts[i] += n
+				}
+			}
+		}
+
+		var dbKeys, memory atomic.Int64
+		forEachNode(ctx, r.client, func(ctx context.Context, node redis.UniversalClient) error {
+			if n, err := node.DBSize(ctx).Result(); err == nil {
+				dbKeys.Add(n)
+			}
+			if info, err := node.Info(ctx, "memory").Result(); err == nil {
+				memory.Add(infoInt(info, "used_memory"))
+			}
+			return nil
+		})
+		stats.Keys = dbKeys.Load()
+		stats.UsedMemoryBytes = memory.Load()
+	} else {
+		stats.Status = "fallback"
+		stats.Keys = int64(len(r.fallback.Keys("")))
+	}
+	if r.fallback != nil {
+		stats.DiskCache = r.fallback.Stats()
+	}
+
+	stats.Hits, stats.Misses, stats.Sets = counts[0], counts[1], counts[2]
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		stats.HitRatio = math.Round(float64(stats.Hits)/float64(lookups)*10000) / 10000
+	}
+	if stats.Sets > 0 {
+		stats.AvgEntryBytes = counts[3] / stats.Sets
+	}
+	return stats
+}
+
+// infoInt reads one integer field of an INFO reply.
+func infoInt(info, field string) int64 {
+	scanner := bufio.NewScanner(strings.NewReader(info))
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), field+":"); ok {
+			n, _ := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			return n
+		}
+	}
+	return 0
+}
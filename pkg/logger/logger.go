@@ -0,0 +1,40 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"price-comparison-api/internal/config"
+)
+
+// Setup configures the global zerolog logger. Loggers attached to a request
+// context (see zerolog.Ctx) derive from it, and code without a context falls
+// back to it as well.
+func Setup(cfg config.LogConfig) error {
+	level, err := zerolog.ParseLevel(strings.ToLower(cfg.Level))
+	if err != nil || cfg.Level == "" {
+		return fmt.Errorf("invalid log level: %q. Valid levels: debug, info, warn, error", cfg.Level)
+	}
+
+	var out io.Writer = os.Stdout
+	switch strings.ToLower(cfg.Format) {
+	case "", "json":
+	case "console":
+		out = zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}
+	default:
+		return fmt.Errorf("invalid log format: %q. Valid formats: json, console", cfg.Format)
+	}
+
+	zerolog.SetGlobalLevel(level)
+	zerolog.TimeFieldFormat = time.RFC3339Nano
+	log.Logger = zerolog.New(out).With().Timestamp().Str("service", "price-comparison-api").Logger()
+
+	// zerolog.Ctx returns this for contexts that carry no request logger
+	zerolog.DefaultContextLogger = &log.Logger
+	return nil
+}
@@ -0,0 +1,182 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "price_comparison"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method and route.",
+		Buckets:   []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"method", "route"})
+
+	cacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Search cache lookups by backend and result (hit, miss, error).",
+	}, []string{"backend", "result"})
+
+	scraperRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "scraper_requests_total",
+		Help:      "Scraper runs by scraper and result (success, empty, error).",
+	}, []string{"scraper", "result"})
+
+	scraperDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "scraper_duration_seconds",
+		Help:      "Time spent in a single scraper run.",
+		Buckets:   []float64{0.5, 1, 2, 5, 10, 20, 30, 60, 120},
+	}, []string{"scraper"})
+
+	scraperProducts = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "scraper_products",
+		Help:      "Products returned per scraper run.",
+		Buckets:   []float64{0, 1, 5, 10, 20, 50, 100},
+	}, []string{"scraper"})
+
+	scraperLastProducts = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "scraper_last_products",
+		Help:      "Products returned by the most recent run of each scraper.",
+	}, []string{"scraper"})
+
+	captchaSolves = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "captcha_solves_total",
+		Help:      "Captchas sent to the solving service by kind and result (solved, rejected, error).",
+	}, []string{"kind", "result"})
+
+	scraperBlocks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "scraper_blocks_total",
+		Help:      "Captcha or bot challenge pages served instead of results, by scraper and challenge (robot_check, verify_identity, cloudflare).",
+	}, []string{"scraper", "challenge"})
+
+	pageFetches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "page_fetches_total",
+		Help:      "Retailer pages fetched by domain, page kind (search, detail, rendered) and status code.",
+	}, []string{"domain", "kind", "status"})
+
+	pageLayoutChanges = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "page_layout_changes_total",
+		Help:      "Changes in the structure fingerprint of retailer pages, by domain and page kind.",
+	}, []string{"domain", "kind"})
+
+	canaryHealthy = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "canary_healthy",
+		Help:      "Whether the last canary search of each scraper found products (1) or not (0).",
+	}, []string{"scraper"})
+
+	canaryProducts = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "canary_products",
+		Help:      "Products found by the last canary search of each scraper.",
+	}, []string{"scraper"})
+
+	rateLimitRejections = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_rejections_total",
+		Help:      "Requests rejected by the per-IP rate limiter.",
+	})
+)
+
+// Handler serves the Prometheus scrape endpoint.
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
+
+// Middleware records request counts and latency. Routes are labelled by
+// their pattern (e.g. /admin/scrapers/:name) to keep cardinality bounded.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := c.Request.Method
+		httpRequests.WithLabelValues(method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		httpDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// CacheHit, CacheMiss and CacheError count search cache lookups.
+func CacheHit(backend string)   { cacheRequests.WithLabelValues(backend, "hit").Inc() }
+func CacheMiss(backend string)  { cacheRequests.WithLabelValues(backend, "miss").Inc() }
+func CacheError(backend string) { cacheRequests.WithLabelValues(backend, "error").Inc() }
+
+// ObserveScrape records one scraper run. A run without an error that finds
+// nothing counts as "empty" so retailers silently returning zero products
+// can be alerted on.
+func ObserveScrape(scraper string, products int, err error, duration time.Duration) {
+	result := "success"
+	switch {
+	case err != nil:
+		result = "error"
+	case products == 0:
+		result = "empty"
+	}
+
+	scraperRequests.WithLabelValues(scraper, result).Inc()
+	scraperDuration.WithLabelValues(scraper).Observe(duration.Seconds())
+	scraperProducts.WithLabelValues(scraper).Observe(float64(products))
+	scraperLastProducts.WithLabelValues(scraper).Set(float64(products))
+}
+
+// ObserveCaptcha records one captcha sent to the solving service: solved
+// when the page accepted the answer, rejected when it didn't and error when
+// no answer came back.
+func ObserveCaptcha(kind, result string) {
+	captchaSolves.WithLabelValues(kind, result).Inc()
+}
+
+// ObserveBlock records a challenge page a retailer served a scraper.
+func ObserveBlock(scraper, challenge string) {
+	scraperBlocks.WithLabelValues(scraper, challenge).Inc()
+}
+
+// ObservePage records a fetched page, and whether its layout fingerprint
+// changed from the last one seen for its domain and kind.
+func ObservePage(domain, kind string, status int, layoutChanged bool) {
+	pageFetches.WithLabelValues(domain, kind, strconv.Itoa(status)).Inc()
+	if layoutChanged {
+		pageLayoutChanges.WithLabelValues(domain, kind).Inc()
+	}
+}
+
+// ObserveCanary records the outcome of a scraper's canary search.
+func ObserveCanary(scraper string, healthy bool, products int) {
+	value := 0.0
+	if healthy {
+		value = 1
+	}
+	canaryHealthy.WithLabelValues(scraper).Set(value)
+	canaryProducts.WithLabelValues(scraper).Set(float64(products))
+}
+
+func RateLimitRejected() {
+	rateLimitRejections.Inc()
+}
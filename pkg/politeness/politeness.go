@@ -0,0 +1,233 @@
+// Package politeness decides how gently each retailer is crawled. It sets
+// the per-domain delay and parallelism of a scraper's collector, and reads
+// every retailer's robots.txt: its crawl-delay is honored across all
+// collectors, and in strict mode pages it disallows are not fetched.
+package politeness
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/temoto/robotstxt"
+	"price-comparison-api/internal/config"
+)
+
+// maxRobotsBytes caps how much of a robots.txt is read
+const maxRobotsBytes = 512 << 10
+
+// failedRobotsTTL is how long a robots.txt that couldn't be fetched counts
+// as allowing everything before it is tried again
+const failedRobotsTTL = 10 * time.Minute
+
+// ErrDisallowed is returned for pages a retailer's robots.txt disallows,
+// in strict mode.
+var ErrDisallowed = errors.New("disallowed by robots.txt")
+
+// domains are the domain globs each scraper's limits apply to.
+var domains = map[string]string{
+	config.ScraperAmazon:         "*amazon.*",
+	config.ScraperEbay:           "*ebay.*",
+	config.ScraperFlipkart:       "*flipkart.*",
+	config.ScraperWalmart:        "*walmart.*",
+	config.ScraperTarget:         "*target.*",
+	config.ScraperBestBuy:        "*bestbuy.*",
+	config.ScraperGoogleShopping: "*google.*",
+	config.ScraperAliExpress:     "*aliexpress.*",
+	config.ScraperEtsy:           "*etsy.*",
+	config.ScraperCostco:         "*costco.*",
+	config.ScraperSamsClub:       "*samsclub.*",
+	config.ScraperMercadoLibre:   "*mercadoli*",
+}
+
+// Limit applies a scraper's delay and parallelism to its collector, for
+// the scraper's domains.
+func Limit(c *colly.Collector, scraper string, sc config.ScraperConfig) {
+	glob := domains[scraper]
+	if glob == "" {
+		glob = "*"
+	}
+	c.Limit(&colly.LimitRule{
+		DomainGlob:  glob,
+		Parallelism: sc.Parallelism,
+		Delay:       sc.Delay,
+	})
+}
+
+// robots is what one host's robots.txt says, as last fetched.
+type robots struct {
+	data      *robotstxt.RobotsData // nil when it couldn't be fetched
+	expires   time.Time
+	fetching  bool
+	fetched   chan struct{} // closed when the first fetch finishes
+	nextVisit time.Time     // when crawl-delay next lets a page be fetched
+}
+
+// Engine reads and keeps robots.txt per host.
+type Engine struct {
+	cfg    config.PolitenessConfig
+	client *http.Client
+
+	mu    sync.Mutex
+	hosts map[string]*robots
+}
+
+// NewEngine returns an engine following cfg.
+func NewEngine(cfg config.PolitenessConfig) *Engine {
+	return &Engine{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		hosts:  make(map[string]*robots),
+	}
+}
+
+var (
+	defaultMu     sync.RWMutex
+	defaultEngine = NewEngine(config.PolitenessConfig{Robots: config.RobotsOff})
+)
+
+// Configure replaces the engine Wait uses. Until it is called robots.txt
+// is not read.
+func Configure(cfg config.PolitenessConfig) {
+	defaultMu.Lock()
+	defaultEngine = NewEngine(cfg)
+	defaultMu.Unlock()
+}
+
+// Wait blocks until pageURL may be fetched under the configured engine.
+func Wait(ctx context.Context, pageURL string) error {
+	defaultMu.RLock()
+	e := defaultEngine
+	defaultMu.RUnlock()
+	return e.Wait(ctx, pageURL)
+}
+
+// Wait blocks until pageURL's host's crawl-delay has passed since the last
+// page fetched from it, and in strict mode returns ErrDisallowed for pages
+// its robots.txt disallows. Outside strict mode a host's first page
+// doesn't wait for its robots.txt, which is fetched in the background.
+func (e *Engine) Wait(ctx context.Context, pageURL string) error {
+	if e.cfg.Robots == config.RobotsOff {
+		return nil
+	}
+	u, err := url.Parse(pageURL)
+	if err != nil || u.Host == "" {
+		return nil
+	}
+	strict := e.cfg.Robots == config.RobotsStrict
+
+	r := e.lookup(u)
+	if strict {
+		select {
+		case <-r.fetched:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	e.mu.Lock()
+	var group *robotstxt.Group
+	if r.data != nil {
+		group = r.data.FindGroup(e.cfg.Agent)
+	}
+	// TestAgent, unlike the group, knows a 5xx robots.txt disallows
+	// everything
+	if strict && r.data != nil && !r.data.TestAgent(u.EscapedPath(), e.cfg.Agent) {
+		e.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrDisallowed, pageURL)
+	}
+	var delay time.Duration
+	if group != nil {
+		delay = group.CrawlDelay
+	}
+	if e.cfg.MaxCrawlDelay > 0 && delay > e.cfg.MaxCrawlDelay {
+		delay = e.cfg.MaxCrawlDelay
+	}
+	now := time.Now()
+	start := now
+	if r.nextVisit.After(now) {
+		start = r.nextVisit
+	}
+	r.nextVisit = start.Add(delay)
+	e.mu.Unlock()
+
+	if wait := start.Sub(now); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// lookup returns u's host's robots.txt entry, starting a fetch when it has
+// none yet or it expired. Until a refetch finishes the old rules apply.
+func (e *Engine) lookup(u *url.URL) *robots {
+	host := u.Scheme + "://" + u.Host
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	r, ok := e.hosts[host]
+	if !ok {
+		r = &robots{fetched: make(chan struct{})}
+		e.hosts[host] = r
+	}
+	if !r.fetching && (!ok || time.Now().After(r.expires)) {
+		r.fetching = true
+		go e.fetch(host, r, !ok)
+	}
+	return r
+}
+
+// fetch reads host's robots.txt into r. A robots.txt that can't be fetched
+// allows everything for a while, or keeps the rules fetched before; status
+// codes are read the way search engines read them, so a 404 allows
+// everything and a 5xx nothing.
+func (e *Engine) fetch(host string, r *robots, first bool) {
+	data, err := e.get(host + "/robots.txt")
+	ttl := e.cfg.RobotsTTL
+	if err != nil {
+		log.Debug().Err(err).Str("host", host).Msg("robots.txt unavailable")
+		ttl = failedRobotsTTL
+	}
+
+	e.mu.Lock()
+	if err == nil {
+		r.data = data
+	}
+	r.expires = time.Now().Add(ttl)
+	r.fetching = false
+	e.mu.Unlock()
+
+	if first {
+		close(r.fetched)
+	}
+}
+
+func (e *Engine) get(robotsURL string) (*robotstxt.RobotsData, error) {
+	req, err := http.NewRequest(http.MethodGet, robotsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", e.cfg.Agent)
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRobotsBytes))
+	if err != nil {
+		return nil, err
+	}
+	return robotstxt.FromStatusAndBytes(resp.StatusCode, body)
+}
@@ -0,0 +1,115 @@
+package politeness
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"price-comparison-api/internal/config"
+)
+
+// robotsServer serves body as robots.txt with status.
+func robotsServer(t *testing.T, status int, body string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func testEngine(robots string) *Engine {
+	return NewEngine(config.PolitenessConfig{
+		Robots:    robots,
+		Agent:     "PriceBot",
+		RobotsTTL: time.Hour,
+		Timeout:   5 * time.Second,
+	})
+}
+
+func TestWaitRobotsRules(t *testing.T) {
+	const rules = "User-agent: *\nDisallow: /private\n\nUser-agent: PriceBot\nDisallow: /checkout\n"
+	for _, tc := range []struct {
+		name    string
+		robots  string
+		status  int
+		body    string
+		path    string
+		blocked bool
+	}{
+		{"allowed page", config.RobotsStrict, 200, rules, "/s?k=usb", false},
+		{"page disallowed for the agent", config.RobotsStrict, 200, rules, "/checkout/cart", true},
+		{"page disallowed for others only", config.RobotsStrict, 200, rules, "/private/list", false},
+		{"respect mode fetches disallowed pages", config.RobotsRespect, 200, rules, "/checkout/cart", false},
+		{"off mode fetches disallowed pages", config.RobotsOff, 200, rules, "/checkout/cart", false},
+		{"missing robots.txt allows everything", config.RobotsStrict, 404, "", "/checkout/cart", false},
+		{"server error disallows everything", config.RobotsStrict, 503, "", "/s?k=usb", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := robotsServer(t, tc.status, tc.body)
+			err := testEngine(tc.robots).Wait(context.Background(), srv.URL+tc.path)
+			if blocked := errors.Is(err, ErrDisallowed); blocked != tc.blocked {
+				t.Errorf("Wait(%s) = %v, want disallowed %v", tc.path, err, tc.blocked)
+			}
+			if err != nil && !errors.Is(err, ErrDisallowed) {
+				t.Errorf("Wait(%s) = %v", tc.path, err)
+			}
+		})
+	}
+}
+
+func TestWaitCrawlDelay(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		body     string
+		maxDelay time.Duration
+		want     time.Duration
+	}{
+		{"no crawl-delay", "User-agent: *\nDisallow:\n", 0, 0},
+		{"crawl-delay", "User-agent: *\nCrawl-delay: 0.2\n", 0, 200 * time.Millisecond},
+		{"crawl-delay over the cap", "User-agent: *\nCrawl-delay: 30\n", 100 * time.Millisecond, 100 * time.Millisecond},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := robotsServer(t, 200, tc.body)
+			e := testEngine(config.RobotsStrict)
+			e.cfg.MaxCrawlDelay = tc.maxDelay
+			ctx := context.Background()
+
+			// The first page waits for robots.txt only, the second for the
+			// crawl-delay after it
+			if err := e.Wait(ctx, srv.URL+"/a"); err != nil {
+				t.Fatal(err)
+			}
+			start := time.Now()
+			if err := e.Wait(ctx, srv.URL+"/b"); err != nil {
+				t.Fatal(err)
+			}
+			waited := time.Since(start)
+			if waited < tc.want-10*time.Millisecond || waited > tc.want+500*time.Millisecond {
+				t.Errorf("second page waited %v, want about %v", waited, tc.want)
+			}
+		})
+	}
+}
+
+func TestWaitCanceled(t *testing.T) {
+	srv := robotsServer(t, 200, "User-agent: *\nCrawl-delay: 30\n")
+	e := testEngine(config.RobotsStrict)
+	if err := e.Wait(context.Background(), srv.URL+"/a"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := e.Wait(ctx, srv.URL+"/b"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait = %v, want the context's error", err)
+	}
+}
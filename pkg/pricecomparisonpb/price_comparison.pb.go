@@ -0,0 +1,1559 @@
+// The gRPC API for backend services. It serves the same searches as the
+// REST API on a separate port (GRPC_PORT). Regenerate the Go stubs in
+// pkg/pricecomparisonpb after editing:
+//
+//   protoc --go_out=. --go_opt=module=price-comparison-api \
+//     --go-grpc_out=. --go-grpc_opt=module=price-comparison-api \
+//     proto/price_comparison.proto
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        v5.29.3
+// source: proto/price_comparison.proto
+
+package pricecomparisonpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type SearchEvent_Type int32
+
+const (
+	SearchEvent_TYPE_UNSPECIFIED SearchEvent_Type = 0
+	SearchEvent_SOURCE_STARTED   SearchEvent_Type = 1
+	SearchEvent_PRODUCTS_BATCH   SearchEvent_Type = 2
+	SearchEvent_SOURCE_FINISHED  SearchEvent_Type = 3
+	SearchEvent_DONE             SearchEvent_Type = 4
+)
+
+// Enum value maps for SearchEvent_Type.
+var (
+	SearchEvent_Type_name = map[int32]string{
+		0: "TYPE_UNSPECIFIED",
+		1: "SOURCE_STARTED",
+		2: "PRODUCTS_BATCH",
+		3: "SOURCE_FINISHED",
+		4: "DONE",
+	}
+	SearchEvent_Type_value = map[string]int32{
+		"TYPE_UNSPECIFIED": 0,
+		"SOURCE_STARTED":   1,
+		"PRODUCTS_BATCH":   2,
+		"SOURCE_FINISHED":  3,
+		"DONE":             4,
+	}
+)
+
+func (x SearchEvent_Type) Enum() *SearchEvent_Type {
+	p := new(SearchEvent_Type)
+	*p = x
+	return p
+}
+
+func (x SearchEvent_Type) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (SearchEvent_Type) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_price_comparison_proto_enumTypes[0].Descriptor()
+}
+
+func (SearchEvent_Type) Type() protoreflect.EnumType {
+	return &file_proto_price_comparison_proto_enumTypes[0]
+}
+
+func (x SearchEvent_Type) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use SearchEvent_Type.Descriptor instead.
+func (SearchEvent_Type) EnumDescriptor() ([]byte, []int) {
+	return file_proto_price_comparison_proto_rawDescGZIP(), []int{11, 0}
+}
+
+type SearchRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Query string                 `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
+	// Country code; the server's default when empty
+	Country string `protobuf:"bytes,2,opt,name=country,proto3" json:"country,omitempty"`
+	// 1 and 10 when unset
+	Page        int32        `protobuf:"varint,3,opt,name=page,proto3" json:"page,omitempty"`
+	Limit       int32        `protobuf:"varint,4,opt,name=limit,proto3" json:"limit,omitempty"`
+	Filters     *Filters     `protobuf:"bytes,5,opt,name=filters,proto3" json:"filters,omitempty"`
+	Sort        *Sort        `protobuf:"bytes,6,opt,name=sort,proto3" json:"sort,omitempty"`
+	Preferences *Preferences `protobuf:"bytes,7,opt,name=preferences,proto3" json:"preferences,omitempty"`
+	// Pages through an earlier response's results instead of searching again
+	ResultSetId   string `protobuf:"bytes,8,opt,name=result_set_id,json=resultSetId,proto3" json:"result_set_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchRequest) Reset() {
+	*x = SearchRequest{}
+	mi := &file_proto_price_comparison_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchRequest) ProtoMessage() {}
+
+func (x *SearchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_price_comparison_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchRequest.ProtoReflect.Descriptor instead.
+func (*SearchRequest) Descriptor() ([]byte, []int) {
+	return file_proto_price_comparison_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SearchRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetCountry() string {
+	if x != nil {
+		return x.Country
+	}
+	return ""
+}
+
+func (x *SearchRequest) GetPage() int32 {
+	if x != nil {
+		return x.Page
+	}
+	return 0
+}
+
+func (x *SearchRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *SearchRequest) GetFilters() *Filters {
+	if x != nil {
+		return x.Filters
+	}
+	return nil
+}
+
+func (x *SearchRequest) GetSort() *Sort {
+	if x != nil {
+		return x.Sort
+	}
+	return nil
+}
+
+func (x *SearchRequest) GetPreferences() *Preferences {
+	if x != nil {
+		return x.Preferences
+	}
+	return nil
+}
+
+func (x *SearchRequest) GetResultSetId() string {
+	if x != nil {
+		return x.ResultSetId
+	}
+	return ""
+}
+
+type Filters struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	MinPrice           float64                `protobuf:"fixed64,1,opt,name=min_price,json=minPrice,proto3" json:"min_price,omitempty"`
+	MaxPrice           float64                `protobuf:"fixed64,2,opt,name=max_price,json=maxPrice,proto3" json:"max_price,omitempty"`
+	InStock            *bool                  `protobuf:"varint,3,opt,name=in_stock,json=inStock,proto3,oneof" json:"in_stock,omitempty"`
+	MinRating          float64                `protobuf:"fixed64,4,opt,name=min_rating,json=minRating,proto3" json:"min_rating,omitempty"`
+	Source             string                 `protobuf:"bytes,5,opt,name=source,proto3" json:"source,omitempty"`
+	MinDiscount        float64                `protobuf:"fixed64,6,opt,name=min_discount,json=minDiscount,proto3" json:"min_discount,omitempty"`
+	RequiresMembership *bool                  `protobuf:"varint,7,opt,name=requires_membership,json=requiresMembership,proto3,oneof" json:"requires_membership,omitempty"`
+	Category           string                 `protobuf:"bytes,8,opt,name=category,proto3" json:"category,omitempty"`
+	Strict             *bool                  `protobuf:"varint,9,opt,name=strict,proto3,oneof" json:"strict,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *Filters) Reset() {
+	*x = Filters{}
+	mi := &file_proto_price_comparison_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Filters) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Filters) ProtoMessage() {}
+
+func (x *Filters) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_price_comparison_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Filters.ProtoReflect.Descriptor instead.
+func (*Filters) Descriptor() ([]byte, []int) {
+	return file_proto_price_comparison_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Filters) GetMinPrice() float64 {
+	if x != nil {
+		return x.MinPrice
+	}
+	return 0
+}
+
+func (x *Filters) GetMaxPrice() float64 {
+	if x != nil {
+		return x.MaxPrice
+	}
+	return 0
+}
+
+func (x *Filters) GetInStock() bool {
+	if x != nil && x.InStock != nil {
+		return *x.InStock
+	}
+	return false
+}
+
+func (x *Filters) GetMinRating() float64 {
+	if x != nil {
+		return x.MinRating
+	}
+	return 0
+}
+
+func (x *Filters) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *Filters) GetMinDiscount() float64 {
+	if x != nil {
+		return x.MinDiscount
+	}
+	return 0
+}
+
+func (x *Filters) GetRequiresMembership() bool {
+	if x != nil && x.RequiresMembership != nil {
+		return *x.RequiresMembership
+	}
+	return false
+}
+
+func (x *Filters) GetCategory() string {
+	if x != nil {
+		return x.Category
+	}
+	return ""
+}
+
+func (x *Filters) GetStrict() bool {
+	if x != nil && x.Strict != nil {
+		return *x.Strict
+	}
+	return false
+}
+
+type Sort struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// price, rating, name or discount_percent
+	Field string `protobuf:"bytes,1,opt,name=field,proto3" json:"field,omitempty"`
+	// asc or desc
+	Order         string `protobuf:"bytes,2,opt,name=order,proto3" json:"order,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Sort) Reset() {
+	*x = Sort{}
+	mi := &file_proto_price_comparison_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Sort) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Sort) ProtoMessage() {}
+
+func (x *Sort) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_price_comparison_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Sort.ProtoReflect.Descriptor instead.
+func (*Sort) Descriptor() ([]byte, []int) {
+	return file_proto_price_comparison_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Sort) GetField() string {
+	if x != nil {
+		return x.Field
+	}
+	return ""
+}
+
+func (x *Sort) GetOrder() string {
+	if x != nil {
+		return x.Order
+	}
+	return ""
+}
+
+type Preferences struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	PreferredRetailers []string               `protobuf:"bytes,1,rep,name=preferred_retailers,json=preferredRetailers,proto3" json:"preferred_retailers,omitempty"`
+	ExcludedSellers    []string               `protobuf:"bytes,2,rep,name=excluded_sellers,json=excludedSellers,proto3" json:"excluded_sellers,omitempty"`
+	Currency           string                 `protobuf:"bytes,3,opt,name=currency,proto3" json:"currency,omitempty"`
+	// off, moderate or strict
+	SafeSearch    string `protobuf:"bytes,4,opt,name=safe_search,json=safeSearch,proto3" json:"safe_search,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Preferences) Reset() {
+	*x = Preferences{}
+	mi := &file_proto_price_comparison_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Preferences) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Preferences) ProtoMessage() {}
+
+func (x *Preferences) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_price_comparison_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Preferences.ProtoReflect.Descriptor instead.
+func (*Preferences) Descriptor() ([]byte, []int) {
+	return file_proto_price_comparison_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Preferences) GetPreferredRetailers() []string {
+	if x != nil {
+		return x.PreferredRetailers
+	}
+	return nil
+}
+
+func (x *Preferences) GetExcludedSellers() []string {
+	if x != nil {
+		return x.ExcludedSellers
+	}
+	return nil
+}
+
+func (x *Preferences) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *Preferences) GetSafeSearch() string {
+	if x != nil {
+		return x.SafeSearch
+	}
+	return ""
+}
+
+type SearchResponse struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Query           string                 `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
+	Products        []*Product             `protobuf:"bytes,2,rep,name=products,proto3" json:"products,omitempty"`
+	Total           int32                  `protobuf:"varint,3,opt,name=total,proto3" json:"total,omitempty"`
+	Page            int32                  `protobuf:"varint,4,opt,name=page,proto3" json:"page,omitempty"`
+	Limit           int32                  `protobuf:"varint,5,opt,name=limit,proto3" json:"limit,omitempty"`
+	TotalPages      int32                  `protobuf:"varint,6,opt,name=total_pages,json=totalPages,proto3" json:"total_pages,omitempty"`
+	Source          string                 `protobuf:"bytes,7,opt,name=source,proto3" json:"source,omitempty"`
+	Duration        string                 `protobuf:"bytes,8,opt,name=duration,proto3" json:"duration,omitempty"`
+	NormalizedQuery string                 `protobuf:"bytes,9,opt,name=normalized_query,json=normalizedQuery,proto3" json:"normalized_query,omitempty"`
+	ResultSetId     string                 `protobuf:"bytes,10,opt,name=result_set_id,json=resultSetId,proto3" json:"result_set_id,omitempty"`
+	Personalized    bool                   `protobuf:"varint,11,opt,name=personalized,proto3" json:"personalized,omitempty"`
+	Attribution     []*Attribution         `protobuf:"bytes,12,rep,name=attribution,proto3" json:"attribution,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *SearchResponse) Reset() {
+	*x = SearchResponse{}
+	mi := &file_proto_price_comparison_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchResponse) ProtoMessage() {}
+
+func (x *SearchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_price_comparison_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchResponse.ProtoReflect.Descriptor instead.
+func (*SearchResponse) Descriptor() ([]byte, []int) {
+	return file_proto_price_comparison_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *SearchResponse) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *SearchResponse) GetProducts() []*Product {
+	if x != nil {
+		return x.Products
+	}
+	return nil
+}
+
+func (x *SearchResponse) GetTotal() int32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *SearchResponse) GetPage() int32 {
+	if x != nil {
+		return x.Page
+	}
+	return 0
+}
+
+func (x *SearchResponse) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *SearchResponse) GetTotalPages() int32 {
+	if x != nil {
+		return x.TotalPages
+	}
+	return 0
+}
+
+func (x *SearchResponse) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *SearchResponse) GetDuration() string {
+	if x != nil {
+		return x.Duration
+	}
+	return ""
+}
+
+func (x *SearchResponse) GetNormalizedQuery() string {
+	if x != nil {
+		return x.NormalizedQuery
+	}
+	return ""
+}
+
+func (x *SearchResponse) GetResultSetId() string {
+	if x != nil {
+		return x.ResultSetId
+	}
+	return ""
+}
+
+func (x *SearchResponse) GetPersonalized() bool {
+	if x != nil {
+		return x.Personalized
+	}
+	return false
+}
+
+func (x *SearchResponse) GetAttribution() []*Attribution {
+	if x != nil {
+		return x.Attribution
+	}
+	return nil
+}
+
+type Product struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Id              string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	SourceProductId string                 `protobuf:"bytes,2,opt,name=source_product_id,json=sourceProductId,proto3" json:"source_product_id,omitempty"`
+	CatalogId       string                 `protobuf:"bytes,3,opt,name=catalog_id,json=catalogId,proto3" json:"catalog_id,omitempty"`
+	Name            string                 `protobuf:"bytes,4,opt,name=name,proto3" json:"name,omitempty"`
+	Title           string                 `protobuf:"bytes,5,opt,name=title,proto3" json:"title,omitempty"`
+	DisplayName     string                 `protobuf:"bytes,6,opt,name=display_name,json=displayName,proto3" json:"display_name,omitempty"`
+	// Formatted for display; price_value is the number
+	Price              string                 `protobuf:"bytes,7,opt,name=price,proto3" json:"price,omitempty"`
+	PriceValue         float64                `protobuf:"fixed64,8,opt,name=price_value,json=priceValue,proto3" json:"price_value,omitempty"`
+	Currency           string                 `protobuf:"bytes,9,opt,name=currency,proto3" json:"currency,omitempty"`
+	Url                string                 `protobuf:"bytes,10,opt,name=url,proto3" json:"url,omitempty"`
+	Image              string                 `protobuf:"bytes,11,opt,name=image,proto3" json:"image,omitempty"`
+	Rating             string                 `protobuf:"bytes,12,opt,name=rating,proto3" json:"rating,omitempty"`
+	RatingValue        float64                `protobuf:"fixed64,13,opt,name=rating_value,json=ratingValue,proto3" json:"rating_value,omitempty"`
+	Reviews            string                 `protobuf:"bytes,14,opt,name=reviews,proto3" json:"reviews,omitempty"`
+	Source             string                 `protobuf:"bytes,15,opt,name=source,proto3" json:"source,omitempty"`
+	Merchant           string                 `protobuf:"bytes,16,opt,name=merchant,proto3" json:"merchant,omitempty"`
+	ScrapedAt          *timestamppb.Timestamp `protobuf:"bytes,17,opt,name=scraped_at,json=scrapedAt,proto3" json:"scraped_at,omitempty"`
+	InStock            bool                   `protobuf:"varint,18,opt,name=in_stock,json=inStock,proto3" json:"in_stock,omitempty"`
+	Description        string                 `protobuf:"bytes,19,opt,name=description,proto3" json:"description,omitempty"`
+	OriginalPrice      string                 `protobuf:"bytes,20,opt,name=original_price,json=originalPrice,proto3" json:"original_price,omitempty"`
+	DiscountPercent    float64                `protobuf:"fixed64,21,opt,name=discount_percent,json=discountPercent,proto3" json:"discount_percent,omitempty"`
+	DealBadge          string                 `protobuf:"bytes,22,opt,name=deal_badge,json=dealBadge,proto3" json:"deal_badge,omitempty"`
+	Preorder           bool                   `protobuf:"varint,23,opt,name=preorder,proto3" json:"preorder,omitempty"`
+	ReleaseDate        *timestamppb.Timestamp `protobuf:"bytes,24,opt,name=release_date,json=releaseDate,proto3" json:"release_date,omitempty"`
+	Offers             []*Offer               `protobuf:"bytes,25,rep,name=offers,proto3" json:"offers,omitempty"`
+	SourcePrice        string                 `protobuf:"bytes,26,opt,name=source_price,json=sourcePrice,proto3" json:"source_price,omitempty"`
+	SourceCurrency     string                 `protobuf:"bytes,27,opt,name=source_currency,json=sourceCurrency,proto3" json:"source_currency,omitempty"`
+	RequiresMembership bool                   `protobuf:"varint,28,opt,name=requires_membership,json=requiresMembership,proto3" json:"requires_membership,omitempty"`
+	Condition          string                 `protobuf:"bytes,29,opt,name=condition,proto3" json:"condition,omitempty"`
+	SellerRating       string                 `protobuf:"bytes,30,opt,name=seller_rating,json=sellerRating,proto3" json:"seller_rating,omitempty"`
+	Category           string                 `protobuf:"bytes,31,opt,name=category,proto3" json:"category,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *Product) Reset() {
+	*x = Product{}
+	mi := &file_proto_price_comparison_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Product) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Product) ProtoMessage() {}
+
+func (x *Product) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_price_comparison_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Product.ProtoReflect.Descriptor instead.
+func (*Product) Descriptor() ([]byte, []int) {
+	return file_proto_price_comparison_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Product) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Product) GetSourceProductId() string {
+	if x != nil {
+		return x.SourceProductId
+	}
+	return ""
+}
+
+func (x *Product) GetCatalogId() string {
+	if x != nil {
+		return x.CatalogId
+	}
+	return ""
+}
+
+func (x *Product) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Product) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *Product) GetDisplayName() string {
+	if x != nil {
+		return x.DisplayName
+	}
+	return ""
+}
+
+func (x *Product) GetPrice() string {
+	if x != nil {
+		return x.Price
+	}
+	return ""
+}
+
+func (x *Product) GetPriceValue() float64 {
+	if x != nil {
+		return x.PriceValue
+	}
+	return 0
+}
+
+func (x *Product) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *Product) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *Product) GetImage() string {
+	if x != nil {
+		return x.Image
+	}
+	return ""
+}
+
+func (x *Product) GetRating() string {
+	if x != nil {
+		return x.Rating
+	}
+	return ""
+}
+
+func (x *Product) GetRatingValue() float64 {
+	if x != nil {
+		return x.RatingValue
+	}
+	return 0
+}
+
+func (x *Product) GetReviews() string {
+	if x != nil {
+		return x.Reviews
+	}
+	return ""
+}
+
+func (x *Product) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *Product) GetMerchant() string {
+	if x != nil {
+		return x.Merchant
+	}
+	return ""
+}
+
+func (x *Product) GetScrapedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ScrapedAt
+	}
+	return nil
+}
+
+func (x *Product) GetInStock() bool {
+	if x != nil {
+		return x.InStock
+	}
+	return false
+}
+
+func (x *Product) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *Product) GetOriginalPrice() string {
+	if x != nil {
+		return x.OriginalPrice
+	}
+	return ""
+}
+
+func (x *Product) GetDiscountPercent() float64 {
+	if x != nil {
+		return x.DiscountPercent
+	}
+	return 0
+}
+
+func (x *Product) GetDealBadge() string {
+	if x != nil {
+		return x.DealBadge
+	}
+	return ""
+}
+
+func (x *Product) GetPreorder() bool {
+	if x != nil {
+		return x.Preorder
+	}
+	return false
+}
+
+func (x *Product) GetReleaseDate() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ReleaseDate
+	}
+	return nil
+}
+
+func (x *Product) GetOffers() []*Offer {
+	if x != nil {
+		return x.Offers
+	}
+	return nil
+}
+
+func (x *Product) GetSourcePrice() string {
+	if x != nil {
+		return x.SourcePrice
+	}
+	return ""
+}
+
+func (x *Product) GetSourceCurrency() string {
+	if x != nil {
+		return x.SourceCurrency
+	}
+	return ""
+}
+
+func (x *Product) GetRequiresMembership() bool {
+	if x != nil {
+		return x.RequiresMembership
+	}
+	return false
+}
+
+func (x *Product) GetCondition() string {
+	if x != nil {
+		return x.Condition
+	}
+	return ""
+}
+
+func (x *Product) GetSellerRating() string {
+	if x != nil {
+		return x.SellerRating
+	}
+	return ""
+}
+
+func (x *Product) GetCategory() string {
+	if x != nil {
+		return x.Category
+	}
+	return ""
+}
+
+// Offer is one merchant's price for a product found through an aggregator.
+type Offer struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Merchant      string                 `protobuf:"bytes,1,opt,name=merchant,proto3" json:"merchant,omitempty"`
+	Price         string                 `protobuf:"bytes,2,opt,name=price,proto3" json:"price,omitempty"`
+	PriceValue    float64                `protobuf:"fixed64,3,opt,name=price_value,json=priceValue,proto3" json:"price_value,omitempty"`
+	Url           string                 `protobuf:"bytes,4,opt,name=url,proto3" json:"url,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Offer) Reset() {
+	*x = Offer{}
+	mi := &file_proto_price_comparison_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Offer) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Offer) ProtoMessage() {}
+
+func (x *Offer) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_price_comparison_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Offer.ProtoReflect.Descriptor instead.
+func (*Offer) Descriptor() ([]byte, []int) {
+	return file_proto_price_comparison_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Offer) GetMerchant() string {
+	if x != nil {
+		return x.Merchant
+	}
+	return ""
+}
+
+func (x *Offer) GetPrice() string {
+	if x != nil {
+		return x.Price
+	}
+	return ""
+}
+
+func (x *Offer) GetPriceValue() float64 {
+	if x != nil {
+		return x.PriceValue
+	}
+	return 0
+}
+
+func (x *Offer) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+type Attribution struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Retailer      string                 `protobuf:"bytes,1,opt,name=retailer,proto3" json:"retailer,omitempty"`
+	Source        string                 `protobuf:"bytes,2,opt,name=source,proto3" json:"source,omitempty"`
+	RetrievedAt   *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=retrieved_at,json=retrievedAt,proto3" json:"retrieved_at,omitempty"`
+	TermsUrl      string                 `protobuf:"bytes,4,opt,name=terms_url,json=termsUrl,proto3" json:"terms_url,omitempty"`
+	Text          string                 `protobuf:"bytes,5,opt,name=text,proto3" json:"text,omitempty"`
+	Products      int32                  `protobuf:"varint,6,opt,name=products,proto3" json:"products,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Attribution) Reset() {
+	*x = Attribution{}
+	mi := &file_proto_price_comparison_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Attribution) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Attribution) ProtoMessage() {}
+
+func (x *Attribution) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_price_comparison_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Attribution.ProtoReflect.Descriptor instead.
+func (*Attribution) Descriptor() ([]byte, []int) {
+	return file_proto_price_comparison_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Attribution) GetRetailer() string {
+	if x != nil {
+		return x.Retailer
+	}
+	return ""
+}
+
+func (x *Attribution) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *Attribution) GetRetrievedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.RetrievedAt
+	}
+	return nil
+}
+
+func (x *Attribution) GetTermsUrl() string {
+	if x != nil {
+		return x.TermsUrl
+	}
+	return ""
+}
+
+func (x *Attribution) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *Attribution) GetProducts() int32 {
+	if x != nil {
+		return x.Products
+	}
+	return 0
+}
+
+type GetProductRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// A product page URL of a supported retailer
+	Url           string `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetProductRequest) Reset() {
+	*x = GetProductRequest{}
+	mi := &file_proto_price_comparison_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetProductRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetProductRequest) ProtoMessage() {}
+
+func (x *GetProductRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_price_comparison_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetProductRequest.ProtoReflect.Descriptor instead.
+func (*GetProductRequest) Descriptor() ([]byte, []int) {
+	return file_proto_price_comparison_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *GetProductRequest) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+type ProductDetail struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Product        *Product               `protobuf:"bytes,1,opt,name=product,proto3" json:"product,omitempty"`
+	Country        string                 `protobuf:"bytes,2,opt,name=country,proto3" json:"country,omitempty"`
+	Brand          string                 `protobuf:"bytes,3,opt,name=brand,proto3" json:"brand,omitempty"`
+	Specifications map[string]string      `protobuf:"bytes,4,rep,name=specifications,proto3" json:"specifications,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Seller         string                 `protobuf:"bytes,5,opt,name=seller,proto3" json:"seller,omitempty"`
+	ShippingCost   string                 `protobuf:"bytes,6,opt,name=shipping_cost,json=shippingCost,proto3" json:"shipping_cost,omitempty"`
+	// in_stock, out_of_stock, preorder or unknown
+	Availability  string         `protobuf:"bytes,7,opt,name=availability,proto3" json:"availability,omitempty"`
+	Images        []string       `protobuf:"bytes,8,rep,name=images,proto3" json:"images,omitempty"`
+	ReviewSummary *ReviewSummary `protobuf:"bytes,9,opt,name=review_summary,json=reviewSummary,proto3" json:"review_summary,omitempty"`
+	Attribution   *Attribution   `protobuf:"bytes,10,opt,name=attribution,proto3" json:"attribution,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProductDetail) Reset() {
+	*x = ProductDetail{}
+	mi := &file_proto_price_comparison_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProductDetail) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProductDetail) ProtoMessage() {}
+
+func (x *ProductDetail) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_price_comparison_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProductDetail.ProtoReflect.Descriptor instead.
+func (*ProductDetail) Descriptor() ([]byte, []int) {
+	return file_proto_price_comparison_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ProductDetail) GetProduct() *Product {
+	if x != nil {
+		return x.Product
+	}
+	return nil
+}
+
+func (x *ProductDetail) GetCountry() string {
+	if x != nil {
+		return x.Country
+	}
+	return ""
+}
+
+func (x *ProductDetail) GetBrand() string {
+	if x != nil {
+		return x.Brand
+	}
+	return ""
+}
+
+func (x *ProductDetail) GetSpecifications() map[string]string {
+	if x != nil {
+		return x.Specifications
+	}
+	return nil
+}
+
+func (x *ProductDetail) GetSeller() string {
+	if x != nil {
+		return x.Seller
+	}
+	return ""
+}
+
+func (x *ProductDetail) GetShippingCost() string {
+	if x != nil {
+		return x.ShippingCost
+	}
+	return ""
+}
+
+func (x *ProductDetail) GetAvailability() string {
+	if x != nil {
+		return x.Availability
+	}
+	return ""
+}
+
+func (x *ProductDetail) GetImages() []string {
+	if x != nil {
+		return x.Images
+	}
+	return nil
+}
+
+func (x *ProductDetail) GetReviewSummary() *ReviewSummary {
+	if x != nil {
+		return x.ReviewSummary
+	}
+	return nil
+}
+
+func (x *ProductDetail) GetAttribution() *Attribution {
+	if x != nil {
+		return x.Attribution
+	}
+	return nil
+}
+
+type ReviewSummary struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Snippets []string               `protobuf:"bytes,1,rep,name=snippets,proto3" json:"snippets,omitempty"`
+	// positive, negative, mixed or neutral
+	Sentiment     string   `protobuf:"bytes,2,opt,name=sentiment,proto3" json:"sentiment,omitempty"`
+	Score         float64  `protobuf:"fixed64,3,opt,name=score,proto3" json:"score,omitempty"`
+	Pros          []string `protobuf:"bytes,4,rep,name=pros,proto3" json:"pros,omitempty"`
+	Cons          []string `protobuf:"bytes,5,rep,name=cons,proto3" json:"cons,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReviewSummary) Reset() {
+	*x = ReviewSummary{}
+	mi := &file_proto_price_comparison_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReviewSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReviewSummary) ProtoMessage() {}
+
+func (x *ReviewSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_price_comparison_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReviewSummary.ProtoReflect.Descriptor instead.
+func (*ReviewSummary) Descriptor() ([]byte, []int) {
+	return file_proto_price_comparison_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ReviewSummary) GetSnippets() []string {
+	if x != nil {
+		return x.Snippets
+	}
+	return nil
+}
+
+func (x *ReviewSummary) GetSentiment() string {
+	if x != nil {
+		return x.Sentiment
+	}
+	return ""
+}
+
+func (x *ReviewSummary) GetScore() float64 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+func (x *ReviewSummary) GetPros() []string {
+	if x != nil {
+		return x.Pros
+	}
+	return nil
+}
+
+func (x *ReviewSummary) GetCons() []string {
+	if x != nil {
+		return x.Cons
+	}
+	return nil
+}
+
+// SearchEvent is a step of a streamed search, in the order: source_started,
+// products_batch and source_finished for each source, then done.
+type SearchEvent struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Type   SearchEvent_Type       `protobuf:"varint,1,opt,name=type,proto3,enum=pricecomparison.v1.SearchEvent_Type" json:"type,omitempty"`
+	Source string                 `protobuf:"bytes,2,opt,name=source,proto3" json:"source,omitempty"`
+	// A source's products, before filtering and sorting
+	Products []*Product `protobuf:"bytes,3,rep,name=products,proto3" json:"products,omitempty"`
+	// How many products the source, or the finished search, found
+	Count int32  `protobuf:"varint,4,opt,name=count,proto3" json:"count,omitempty"`
+	Error string `protobuf:"bytes,5,opt,name=error,proto3" json:"error,omitempty"`
+	// The finished search, sent with DONE unless it failed
+	Response *SearchResponse `protobuf:"bytes,6,opt,name=response,proto3" json:"response,omitempty"`
+	// Time since the search started, e.g. "1.2s"
+	Elapsed       string `protobuf:"bytes,7,opt,name=elapsed,proto3" json:"elapsed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SearchEvent) Reset() {
+	*x = SearchEvent{}
+	mi := &file_proto_price_comparison_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SearchEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SearchEvent) ProtoMessage() {}
+
+func (x *SearchEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_price_comparison_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SearchEvent.ProtoReflect.Descriptor instead.
+func (*SearchEvent) Descriptor() ([]byte, []int) {
+	return file_proto_price_comparison_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *SearchEvent) GetType() SearchEvent_Type {
+	if x != nil {
+		return x.Type
+	}
+	return SearchEvent_TYPE_UNSPECIFIED
+}
+
+func (x *SearchEvent) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *SearchEvent) GetProducts() []*Product {
+	if x != nil {
+		return x.Products
+	}
+	return nil
+}
+
+func (x *SearchEvent) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *SearchEvent) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *SearchEvent) GetResponse() *SearchResponse {
+	if x != nil {
+		return x.Response
+	}
+	return nil
+}
+
+func (x *SearchEvent) GetElapsed() string {
+	if x != nil {
+		return x.Elapsed
+	}
+	return ""
+}
+
+var File_proto_price_comparison_proto protoreflect.FileDescriptor
+
+const file_proto_price_comparison_proto_rawDesc = "" +
+	"\n" +
+	"\x1cproto/price_comparison.proto\x12\x12pricecomparison.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xb5\x02\n" +
+	"\rSearchRequest\x12\x14\n" +
+	"\x05query\x18\x01 \x01(\tR\x05query\x12\x18\n" +
+	"\acountry\x18\x02 \x01(\tR\acountry\x12\x12\n" +
+	"\x04page\x18\x03 \x01(\x05R\x04page\x12\x14\n" +
+	"\x05limit\x18\x04 \x01(\x05R\x05limit\x125\n" +
+	"\afilters\x18\x05 \x01(\v2\x1b.pricecomparison.v1.FiltersR\afilters\x12,\n" +
+	"\x04sort\x18\x06 \x01(\v2\x18.pricecomparison.v1.SortR\x04sort\x12A\n" +
+	"\vpreferences\x18\a \x01(\v2\x1f.pricecomparison.v1.PreferencesR\vpreferences\x12\"\n" +
+	"\rresult_set_id\x18\b \x01(\tR\vresultSetId\"\xdc\x02\n" +
+	"\aFilters\x12\x1b\n" +
+	"\tmin_price\x18\x01 \x01(\x01R\bminPrice\x12\x1b\n" +
+	"\tmax_price\x18\x02 \x01(\x01R\bmaxPrice\x12\x1e\n" +
+	"\bin_stock\x18\x03 \x01(\bH\x00R\ainStock\x88\x01\x01\x12\x1d\n" +
+	"\n" +
+	"min_rating\x18\x04 \x01(\x01R\tminRating\x12\x16\n" +
+	"\x06source\x18\x05 \x01(\tR\x06source\x12!\n" +
+	"\fmin_discount\x18\x06 \x01(\x01R\vminDiscount\x124\n" +
+	"\x13requires_membership\x18\a \x01(\bH\x01R\x12requiresMembership\x88\x01\x01\x12\x1a\n" +
+	"\bcategory\x18\b \x01(\tR\bcategory\x12\x1b\n" +
+	"\x06strict\x18\t \x01(\bH\x02R\x06strict\x88\x01\x01B\v\n" +
+	"\t_in_stockB\x16\n" +
+	"\x14_requires_membershipB\t\n" +
+	"\a_strict\"2\n" +
+	"\x04Sort\x12\x14\n" +
+	"\x05field\x18\x01 \x01(\tR\x05field\x12\x14\n" +
+	"\x05order\x18\x02 \x01(\tR\x05order\"\xa6\x01\n" +
+	"\vPreferences\x12/\n" +
+	"\x13preferred_retailers\x18\x01 \x03(\tR\x12preferredRetailers\x12)\n" +
+	"\x10excluded_sellers\x18\x02 \x03(\tR\x0fexcludedSellers\x12\x1a\n" +
+	"\bcurrency\x18\x03 \x01(\tR\bcurrency\x12\x1f\n" +
+	"\vsafe_search\x18\x04 \x01(\tR\n" +
+	"safeSearch\"\xaa\x03\n" +
+	"\x0eSearchResponse\x12\x14\n" +
+	"\x05query\x18\x01 \x01(\tR\x05query\x127\n" +
+	"\bproducts\x18\x02 \x03(\v2\x1b.pricecomparison.v1.ProductR\bproducts\x12\x14\n" +
+	"\x05total\x18\x03 \x01(\x05R\x05total\x12\x12\n" +
+	"\x04page\x18\x04 \x01(\x05R\x04page\x12\x14\n" +
+	"\x05limit\x18\x05 \x01(\x05R\x05limit\x12\x1f\n" +
+	"\vtotal_pages\x18\x06 \x01(\x05R\n" +
+	"totalPages\x12\x16\n" +
+	"\x06source\x18\a \x01(\tR\x06source\x12\x1a\n" +
+	"\bduration\x18\b \x01(\tR\bduration\x12)\n" +
+	"\x10normalized_query\x18\t \x01(\tR\x0fnormalizedQuery\x12\"\n" +
+	"\rresult_set_id\x18\n" +
+	" \x01(\tR\vresultSetId\x12\"\n" +
+	"\fpersonalized\x18\v \x01(\bR\fpersonalized\x12A\n" +
+	"\vattribution\x18\f \x03(\v2\x1f.pricecomparison.v1.AttributionR\vattribution\"\x88\b\n" +
+	"\aProduct\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12*\n" +
+	"\x11source_product_id\x18\x02 \x01(\tR\x0fsourceProductId\x12\x1d\n" +
+	"\n" +
+	"catalog_id\x18\x03 \x01(\tR\tcatalogId\x12\x12\n" +
+	"\x04name\x18\x04 \x01(\tR\x04name\x12\x14\n" +
+	"\x05title\x18\x05 \x01(\tR\x05title\x12!\n" +
+	"\fdisplay_name\x18\x06 \x01(\tR\vdisplayName\x12\x14\n" +
+	"\x05price\x18\a \x01(\tR\x05price\x12\x1f\n" +
+	"\vprice_value\x18\b \x01(\x01R\n" +
+	"priceValue\x12\x1a\n" +
+	"\bcurrency\x18\t \x01(\tR\bcurrency\x12\x10\n" +
+	"\x03url\x18\n" +
+	" \x01(\tR\x03url\x12\x14\n" +
+	"\x05image\x18\v \x01(\tR\x05image\x12\x16\n" +
+	"\x06rating\x18\f \x01(\tR\x06rating\x12!\n" +
+	"\frating_value\x18\r \x01(\x01R\vratingValue\x12\x18\n" +
+	"\areviews\x18\x0e \x01(\tR\areviews\x12\x16\n" +
+	"\x06source\x18\x0f \x01(\tR\x06source\x12\x1a\n" +
+	"\bmerchant\x18\x10 \x01(\tR\bmerchant\x129\n" +
+	"\n" +
+	"scraped_at\x18\x11 \x01(\v2\x1a.google.protobuf.TimestampR\tscrapedAt\x12\x19\n" +
+	"\bin_stock\x18\x12 \x01(\bR\ainStock\x12 \n" +
+	"\vdescription\x18\x13 \x01(\tR\vdescription\x12%\n" +
+	"\x0eoriginal_price\x18\x14 \x01(\tR\roriginalPrice\x12)\n" +
+	"\x10discount_percent\x18\x15 \x01(\x01R\x0fdiscountPercent\x12\x1d\n" +
+	"\n" +
+	"deal_badge\x18\x16 \x01(\tR\tdealBadge\x12\x1a\n" +
+	"\bpreorder\x18\x17 \x01(\bR\bpreorder\x12=\n" +
+	"\frelease_date\x18\x18 \x01(\v2\x1a.google.protobuf.TimestampR\vreleaseDate\x121\n" +
+	"\x06offers\x18\x19 \x03(\v2\x19.pricecomparison.v1.OfferR\x06offers\x12!\n" +
+	"\fsource_price\x18\x1a \x01(\tR\vsourcePrice\x12'\n" +
+	"\x0fsource_currency\x18\x1b \x01(\tR\x0esourceCurrency\x12/\n" +
+	"\x13requires_membership\x18\x1c \x01(\bR\x12requiresMembership\x12\x1c\n" +
+	"\tcondition\x18\x1d \x01(\tR\tcondition\x12#\n" +
+	"\rseller_rating\x18\x1e \x01(\tR\fsellerRating\x12\x1a\n" +
+	"\bcategory\x18\x1f \x01(\tR\bcategory\"l\n" +
+	"\x05Offer\x12\x1a\n" +
+	"\bmerchant\x18\x01 \x01(\tR\bmerchant\x12\x14\n" +
+	"\x05price\x18\x02 \x01(\tR\x05price\x12\x1f\n" +
+	"\vprice_value\x18\x03 \x01(\x01R\n" +
+	"priceValue\x12\x10\n" +
+	"\x03url\x18\x04 \x01(\tR\x03url\"\xcd\x01\n" +
+	"\vAttribution\x12\x1a\n" +
+	"\bretailer\x18\x01 \x01(\tR\bretailer\x12\x16\n" +
+	"\x06source\x18\x02 \x01(\tR\x06source\x12=\n" +
+	"\fretrieved_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\vretrievedAt\x12\x1b\n" +
+	"\tterms_url\x18\x04 \x01(\tR\btermsUrl\x12\x12\n" +
+	"\x04text\x18\x05 \x01(\tR\x04text\x12\x1a\n" +
+	"\bproducts\x18\x06 \x01(\x05R\bproducts\"%\n" +
+	"\x11GetProductRequest\x12\x10\n" +
+	"\x03url\x18\x01 \x01(\tR\x03url\"\x9e\x04\n" +
+	"\rProductDetail\x125\n" +
+	"\aproduct\x18\x01 \x01(\v2\x1b.pricecomparison.v1.ProductR\aproduct\x12\x18\n" +
+	"\acountry\x18\x02 \x01(\tR\acountry\x12\x14\n" +
+	"\x05brand\x18\x03 \x01(\tR\x05brand\x12]\n" +
+	"\x0especifications\x18\x04 \x03(\v25.pricecomparison.v1.ProductDetail.SpecificationsEntryR\x0especifications\x12\x16\n" +
+	"\x06seller\x18\x05 \x01(\tR\x06seller\x12#\n" +
+	"\rshipping_cost\x18\x06 \x01(\tR\fshippingCost\x12\"\n" +
+	"\favailability\x18\a \x01(\tR\favailability\x12\x16\n" +
+	"\x06images\x18\b \x03(\tR\x06images\x12H\n" +
+	"\x0ereview_summary\x18\t \x01(\v2!.pricecomparison.v1.ReviewSummaryR\rreviewSummary\x12A\n" +
+	"\vattribution\x18\n" +
+	" \x01(\v2\x1f.pricecomparison.v1.AttributionR\vattribution\x1aA\n" +
+	"\x13SpecificationsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x87\x01\n" +
+	"\rReviewSummary\x12\x1a\n" +
+	"\bsnippets\x18\x01 \x03(\tR\bsnippets\x12\x1c\n" +
+	"\tsentiment\x18\x02 \x01(\tR\tsentiment\x12\x14\n" +
+	"\x05score\x18\x03 \x01(\x01R\x05score\x12\x12\n" +
+	"\x04pros\x18\x04 \x03(\tR\x04pros\x12\x12\n" +
+	"\x04cons\x18\x05 \x03(\tR\x04cons\"\x83\x03\n" +
+	"\vSearchEvent\x128\n" +
+	"\x04type\x18\x01 \x01(\x0e2$.pricecomparison.v1.SearchEvent.TypeR\x04type\x12\x16\n" +
+	"\x06source\x18\x02 \x01(\tR\x06source\x127\n" +
+	"\bproducts\x18\x03 \x03(\v2\x1b.pricecomparison.v1.ProductR\bproducts\x12\x14\n" +
+	"\x05count\x18\x04 \x01(\x05R\x05count\x12\x14\n" +
+	"\x05error\x18\x05 \x01(\tR\x05error\x12>\n" +
+	"\bresponse\x18\x06 \x01(\v2\".pricecomparison.v1.SearchResponseR\bresponse\x12\x18\n" +
+	"\aelapsed\x18\a \x01(\tR\aelapsed\"c\n" +
+	"\x04Type\x12\x14\n" +
+	"\x10TYPE_UNSPECIFIED\x10\x00\x12\x12\n" +
+	"\x0eSOURCE_STARTED\x10\x01\x12\x12\n" +
+	"\x0ePRODUCTS_BATCH\x10\x02\x12\x13\n" +
+	"\x0fSOURCE_FINISHED\x10\x03\x12\b\n" +
+	"\x04DONE\x10\x042\x98\x02\n" +
+	"\x0fPriceComparison\x12W\n" +
+	"\x0eSearchProducts\x12!.pricecomparison.v1.SearchRequest\x1a\".pricecomparison.v1.SearchResponse\x12V\n" +
+	"\n" +
+	"GetProduct\x12%.pricecomparison.v1.GetProductRequest\x1a!.pricecomparison.v1.ProductDetail\x12T\n" +
+	"\fStreamSearch\x12!.pricecomparison.v1.SearchRequest\x1a\x1f.pricecomparison.v1.SearchEvent0\x01B>Z<price-comparison-api/pkg/pricecomparisonpb;pricecomparisonpbb\x06proto3"
+
+var (
+	file_proto_price_comparison_proto_rawDescOnce sync.Once
+	file_proto_price_comparison_proto_rawDescData []byte
+)
+
+func file_proto_price_comparison_proto_rawDescGZIP() []byte {
+	file_proto_price_comparison_proto_rawDescOnce.Do(func() {
+		file_proto_price_comparison_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_proto_price_comparison_proto_rawDesc), len(file_proto_price_comparison_proto_rawDesc)))
+	})
+	return file_proto_price_comparison_proto_rawDescData
+}
+
+var file_proto_price_comparison_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_proto_price_comparison_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
+var file_proto_price_comparison_proto_goTypes = []any{
+	(SearchEvent_Type)(0),         // 0: pricecomparison.v1.SearchEvent.Type
+	(*SearchRequest)(nil),         // 1: pricecomparison.v1.SearchRequest
+	(*Filters)(nil),               // 2: pricecomparison.v1.Filters
+	(*Sort)(nil),                  // 3: pricecomparison.v1.Sort
+	(*Preferences)(nil),           // 4: pricecomparison.v1.Preferences
+	(*SearchResponse)(nil),        // 5: pricecomparison.v1.SearchResponse
+	(*Product)(nil),               // 6: pricecomparison.v1.Product
+	(*Offer)(nil),                 // 7: pricecomparison.v1.Offer
+	(*Attribution)(nil),           // 8: pricecomparison.v1.Attribution
+	(*GetProductRequest)(nil),     // 9: pricecomparison.v1.GetProductRequest
+	(*ProductDetail)(nil),         // 10: pricecomparison.v1.ProductDetail
+	(*ReviewSummary)(nil),         // 11: pricecomparison.v1.ReviewSummary
+	(*SearchEvent)(nil),           // 12: pricecomparison.v1.SearchEvent
+	nil,                           // 13: pricecomparison.v1.ProductDetail.SpecificationsEntry
+	(*timestamppb.Timestamp)(nil), // 14: google.protobuf.Timestamp
+}
+var file_proto_price_comparison_proto_depIdxs = []int32{
+	2,  // 0: pricecomparison.v1.SearchRequest.filters:type_name -> pricecomparison.v1.Filters
+	3,  // 1: pricecomparison.v1.SearchRequest.sort:type_name -> pricecomparison.v1.Sort
+	4,  // 2: pricecomparison.v1.SearchRequest.preferences:type_name -> pricecomparison.v1.Preferences
+	6,  // 3: pricecomparison.v1.SearchResponse.products:type_name -> pricecomparison.v1.Product
+	8,  // 4: pricecomparison.v1.SearchResponse.attribution:type_name -> pricecomparison.v1.Attribution
+	14, // 5: pricecomparison.v1.Product.scraped_at:type_name -> google.protobuf.Timestamp
+	14, // 6: pricecomparison.v1.Product.release_date:type_name -> google.protobuf.Timestamp
+	7,  // 7: pricecomparison.v1.Product.offers:type_name -> pricecomparison.v1.Offer
+	14, // 8: pricecomparison.v1.Attribution.retrieved_at:type_name -> google.protobuf.Timestamp
+	6,  // 9: pricecomparison.v1.ProductDetail.product:type_name -> pricecomparison.v1.Product
+	13, // 10: pricecomparison.v1.ProductDetail.specifications:type_name -> pricecomparison.v1.ProductDetail.SpecificationsEntry
+	11, // 11: pricecomparison.v1.ProductDetail.review_summary:type_name -> pricecomparison.v1.ReviewSummary
+	8,  // 12: pricecomparison.v1.ProductDetail.attribution:type_name -> pricecomparison.v1.Attribution
+	0,  // 13: pricecomparison.v1.SearchEvent.type:type_name -> pricecomparison.v1.SearchEvent.Type
+	6,  // 14: pricecomparison.v1.SearchEvent.products:type_name -> pricecomparison.v1.Product
+	5,  // 15: pricecomparison.v1.SearchEvent.response:type_name -> pricecomparison.v1.SearchResponse
+	1,  // 16: pricecomparison.v1.PriceComparison.SearchProducts:input_type -> pricecomparison.v1.SearchRequest
+	9,  // 17: pricecomparison.v1.PriceComparison.GetProduct:input_type -> pricecomparison.v1.GetProductRequest
+	1,  // 18: pricecomparison.v1.PriceComparison.StreamSearch:input_type -> pricecomparison.v1.SearchRequest
+	5,  // 19: pricecomparison.v1.PriceComparison.SearchProducts:output_type -> pricecomparison.v1.SearchResponse
+	10, // 20: pricecomparison.v1.PriceComparison.GetProduct:output_type -> pricecomparison.v1.ProductDetail
+	12, // 21: pricecomparison.v1.PriceComparison.StreamSearch:output_type -> pricecomparison.v1.SearchEvent
+	19, // [19:22] is the sub-list for method output_type
+	16, // [16:19] is the sub-list for method input_type
+	16, // [16:16] is the sub-list for extension type_name
+	16, // [16:16] is the sub-list for extension extendee
+	0,  // [0:16] is the sub-list for field type_name
+}
+
+func init() { file_proto_price_comparison_proto_init() }
+func file_proto_price_comparison_proto_init() {
+	if File_proto_price_comparison_proto != nil {
+		return
+	}
+	file_proto_price_comparison_proto_msgTypes[1].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_price_comparison_proto_rawDesc), len(file_proto_price_comparison_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   13,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_proto_price_comparison_proto_goTypes,
+		DependencyIndexes: file_proto_price_comparison_proto_depIdxs,
+		EnumInfos:         file_proto_price_comparison_proto_enumTypes,
+		MessageInfos:      file_proto_price_comparison_proto_msgTypes,
+	}.Build()
+	File_proto_price_comparison_proto = out.File
+	file_proto_price_comparison_proto_goTypes = nil
+	file_proto_price_comparison_proto_depIdxs = nil
+}
@@ -0,0 +1,219 @@
+// The gRPC API for backend services. It serves the same searches as the
+// REST API on a separate port (GRPC_PORT). Regenerate the Go stubs in
+// pkg/pricecomparisonpb after editing:
+//
+//   protoc --go_out=. --go_opt=module=price-comparison-api \
+//     --go-grpc_out=. --go-grpc_opt=module=price-comparison-api \
+//     proto/price_comparison.proto
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: proto/price_comparison.proto
+
+package pricecomparisonpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	PriceComparison_SearchProducts_FullMethodName = "/pricecomparison.v1.PriceComparison/SearchProducts"
+	PriceComparison_GetProduct_FullMethodName     = "/pricecomparison.v1.PriceComparison/GetProduct"
+	PriceComparison_StreamSearch_FullMethodName   = "/pricecomparison.v1.PriceComparison/StreamSearch"
+)
+
+// PriceComparisonClient is the client API for PriceComparison service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PriceComparisonClient interface {
+	// SearchProducts searches every source for the country, like GET /search.
+	SearchProducts(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
+	// GetProduct scrapes a product page's full details, like GET /product.
+	GetProduct(ctx context.Context, in *GetProductRequest, opts ...grpc.CallOption) (*ProductDetail, error)
+	// StreamSearch runs a search and streams its progress: each source's start,
+	// products and finish, then the finished search. Cancelling the call
+	// cancels the search.
+	StreamSearch(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SearchEvent], error)
+}
+
+type priceComparisonClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPriceComparisonClient(cc grpc.ClientConnInterface) PriceComparisonClient {
+	return &priceComparisonClient{cc}
+}
+
+func (c *priceComparisonClient) SearchProducts(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SearchResponse)
+	err := c.cc.Invoke(ctx, PriceComparison_SearchProducts_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *priceComparisonClient) GetProduct(ctx context.Context, in *GetProductRequest, opts ...grpc.CallOption) (*ProductDetail, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ProductDetail)
+	err := c.cc.Invoke(ctx, PriceComparison_GetProduct_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *priceComparisonClient) StreamSearch(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SearchEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PriceComparison_ServiceDesc.Streams[0], PriceComparison_StreamSearch_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SearchRequest, SearchEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PriceComparison_StreamSearchClient = grpc.ServerStreamingClient[SearchEvent]
+
+// PriceComparisonServer is the server API for PriceComparison service.
+// All implementations must embed UnimplementedPriceComparisonServer
+// for forward compatibility.
+type PriceComparisonServer interface {
+	// SearchProducts searches every source for the country, like GET /search.
+	SearchProducts(context.Context, *SearchRequest) (*SearchResponse, error)
+	// GetProduct scrapes a product page's full details, like GET /product.
+	GetProduct(context.Context, *GetProductRequest) (*ProductDetail, error)
+	// StreamSearch runs a search and streams its progress: each source's start,
+	// products and finish, then the finished search. Cancelling the call
+	// cancels the search.
+	StreamSearch(*SearchRequest, grpc.ServerStreamingServer[SearchEvent]) error
+	mustEmbedUnimplementedPriceComparisonServer()
+}
+
+// UnimplementedPriceComparisonServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedPriceComparisonServer struct{}
+
+func (UnimplementedPriceComparisonServer) SearchProducts(context.Context, *SearchRequest) (*SearchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SearchProducts not implemented")
+}
+func (UnimplementedPriceComparisonServer) GetProduct(context.Context, *GetProductRequest) (*ProductDetail, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetProduct not implemented")
+}
+func (UnimplementedPriceComparisonServer) StreamSearch(*SearchRequest, grpc.ServerStreamingServer[SearchEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamSearch not implemented")
+}
+func (UnimplementedPriceComparisonServer) mustEmbedUnimplementedPriceComparisonServer() {}
+func (UnimplementedPriceComparisonServer) testEmbeddedByValue()                         {}
+
+// UnsafePriceComparisonServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PriceComparisonServer will
+// result in compilation errors.
+type UnsafePriceComparisonServer interface {
+	mustEmbedUnimplementedPriceComparisonServer()
+}
+
+func RegisterPriceComparisonServer(s grpc.ServiceRegistrar, srv PriceComparisonServer) {
+	// If the following call pancis, it indicates UnimplementedPriceComparisonServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&PriceComparison_ServiceDesc, srv)
+}
+
+func _PriceComparison_SearchProducts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PriceComparisonServer).SearchProducts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PriceComparison_SearchProducts_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PriceComparisonServer).SearchProducts(ctx, req.(*SearchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PriceComparison_GetProduct_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetProductRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PriceComparisonServer).GetProduct(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PriceComparison_GetProduct_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PriceComparisonServer).GetProduct(ctx, req.(*GetProductRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PriceComparison_StreamSearch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SearchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PriceComparisonServer).StreamSearch(m, &grpc.GenericServerStream[SearchRequest, SearchEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PriceComparison_StreamSearchServer = grpc.ServerStreamingServer[SearchEvent]
+
+// PriceComparison_ServiceDesc is the grpc.ServiceDesc for PriceComparison service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var PriceComparison_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pricecomparison.v1.PriceComparison",
+	HandlerType: (*PriceComparisonServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SearchProducts",
+			Handler:    _PriceComparison_SearchProducts_Handler,
+		},
+		{
+			MethodName: "GetProduct",
+			Handler:    _PriceComparison_GetProduct_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamSearch",
+			Handler:       _PriceComparison_StreamSearch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "proto/price_comparison.proto",
+}
@@ -0,0 +1,232 @@
+// Package retry retries scrapers' failed page fetches with exponential
+// backoff and jitter. A domain whose pages keep failing is left alone for a
+// cooldown: its pages aren't fetched until the cooldown ends.
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+	"github.com/rs/zerolog"
+	"price-comparison-api/internal/config"
+)
+
+// ErrCoolingDown is returned for pages of a domain that failed too often
+// lately to be fetched again yet.
+var ErrCoolingDown = errors.New("domain cooling down after repeated failures")
+
+// Keys of what a fetch carries in its colly context across attempts
+const (
+	ctxKey       = "retry.ctx"
+	attemptKey   = "retry.attempt"
+	failedKey    = "retry.failed"
+	succeededKey = "retry.succeeded"
+)
+
+// domain is how a domain's recent fetches went.
+type domain struct {
+	failures  int // consecutive failed pages
+	openUntil time.Time
+}
+
+// Policy decides which failed fetches are retried, and when, and tracks
+// each domain's failures.
+type Policy struct {
+	cfg      config.RetryConfig
+	statuses map[int]bool
+
+	mu      sync.Mutex
+	domains map[string]*domain
+}
+
+// NewPolicy returns a policy following cfg.
+func NewPolicy(cfg config.RetryConfig) *Policy {
+	statuses := make(map[int]bool, len(cfg.Statuses))
+	for _, status := range cfg.Statuses {
+		statuses[status] = true
+	}
+	return &Policy{cfg: cfg, statuses: statuses, domains: make(map[string]*domain)}
+}
+
+var (
+	defaultMu     sync.RWMutex
+	defaultPolicy = NewPolicy(config.RetryConfig{MaxAttempts: 1})
+)
+
+// Configure replaces the policy Attach and Visit use. Until it is called
+// fetches aren't retried.
+func Configure(cfg config.RetryConfig) {
+	defaultMu.Lock()
+	defaultPolicy = NewPolicy(cfg)
+	defaultMu.Unlock()
+}
+
+func current() *Policy {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultPolicy
+}
+
+// Attach makes c retry its failed fetches under the configured policy. It
+// is attached after c's other callbacks, so they see every attempt. Only
+// pages fetched with Visit are retried.
+func Attach(c *colly.Collector) {
+	c.OnResponse(func(r *colly.Response) {
+		if r.Ctx != nil {
+			r.Ctx.Put(succeededKey, true)
+		}
+	})
+	c.OnError(func(r *colly.Response, err error) {
+		if r == nil || r.Request == nil || r.Ctx == nil {
+			return
+		}
+		ctx, ok := r.Ctx.GetAny(ctxKey).(context.Context)
+		if !ok {
+			return
+		}
+		r.Ctx.Put(failedKey, true)
+
+		p := current()
+		attempt, _ := r.Ctx.GetAny(attemptKey).(int)
+		if attempt == 0 {
+			attempt = 1
+		}
+		if attempt >= p.cfg.MaxAttempts || !p.retryable(r.StatusCode, err) ||
+			ctx.Err() != nil || (c.Context != nil && c.Context.Err() != nil) {
+			return
+		}
+
+		wait := p.backoff(attempt)
+		zerolog.Ctx(ctx).Debug().Err(err).
+			Str("url", r.Request.URL.String()).
+			Int("attempt", attempt).
+			Dur("backoff", wait).
+			Msg("Retrying failed fetch")
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return
+		}
+
+		r.Ctx.Put(attemptKey, attempt+1)
+		if err := r.Request.Retry(); err != nil {
+			zerolog.Ctx(ctx).Debug().Err(err).Str("url", r.Request.URL.String()).Msg("Retry not sent")
+		}
+	})
+}
+
+// Visit fetches pageURL with c, retrying it under the configured policy
+// when c has the policy attached. It returns ErrCoolingDown without
+// fetching anything while pageURL's domain cools down, and nil when a
+// retry succeeded after earlier attempts failed. Callbacks of every
+// attempt share rctx, which may be nil.
+func Visit(ctx context.Context, c *colly.Collector, pageURL string, rctx *colly.Context) error {
+	return current().Visit(ctx, c, pageURL, rctx)
+}
+
+// Visit fetches pageURL with c under p.
+func (p *Policy) Visit(ctx context.Context, c *colly.Collector, pageURL string, rctx *colly.Context) error {
+	host := hostOf(pageURL)
+	if until, open := p.cooling(host); open {
+		return fmt.Errorf("%w: %s until %s", ErrCoolingDown, host, until.Format(time.RFC3339))
+	}
+
+	if rctx == nil {
+		rctx = colly.NewContext()
+	}
+	rctx.Put(ctxKey, ctx)
+	err := c.Request(http.MethodGet, pageURL, nil, rctx, nil)
+	if rctx.GetAny(succeededKey) != nil {
+		p.record(host, true)
+		return nil
+	}
+	// Fetches refused before reaching the retailer, such as pages already
+	// visited, say nothing about the domain
+	if err != nil && rctx.GetAny(failedKey) != nil && ctx.Err() == nil && p.record(host, false) {
+		zerolog.Ctx(ctx).Warn().Str("domain", host).Dur("cooldown", p.cfg.Cooldown).
+			Msg("Pausing fetches from domain after repeated failures")
+	}
+	return err
+}
+
+// retryable reports whether a fetch that failed with status, 0 when it got
+// no response, is worth retrying.
+func (p *Policy) retryable(status int, err error) bool {
+	if status == 0 {
+		return !errors.Is(err, context.Canceled)
+	}
+	return p.statuses[status]
+}
+
+// backoff is the wait before retrying a fetch that failed attempt times:
+// Backoff doubled for each earlier retry, capped at MaxBackoff, plus up to
+// half of that again as jitter so retries of many pages don't line up.
+func (p *Policy) backoff(attempt int) time.Duration {
+	wait := p.cfg.Backoff
+	for i := 1; i < attempt && wait < p.cfg.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > p.cfg.MaxBackoff {
+		wait = p.cfg.MaxBackoff
+	}
+	if wait <= 0 {
+		return 0
+	}
+	return wait + rand.N(wait/2+1)
+}
+
+// cooling reports whether host's pages are held back, and until when.
+func (p *Policy) cooling(host string) (time.Time, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	d := p.domains[host]
+	if d == nil || !time.Now().Before(d.openUntil) {
+		return time.Time{}, false
+	}
+	return d.openUntil, true
+}
+
+// record counts a page of host fetched or failed, starting the host's
+// cooldown once FailureThreshold pages failed in a row. It reports whether
+// the cooldown started.
+func (p *Policy) record(host string, ok bool) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	d := p.domains[host]
+	if d == nil {
+		if ok {
+			return false
+		}
+		d = &domain{}
+		p.domains[host] = d
+	}
+	if ok {
+		d.failures = 0
+		return false
+	}
+	d.failures++
+	if p.cfg.FailureThreshold > 0 && d.failures >= p.cfg.FailureThreshold {
+		d.openUntil = time.Now().Add(p.cfg.Cooldown)
+		d.failures = 0
+		return true
+	}
+	return false
+}
+
+func hostOf(pageURL string) string {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return pageURL
+	}
+	return strings.TrimPrefix(u.Hostname(), "www.")
+}
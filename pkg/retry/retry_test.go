@@ -0,0 +1,183 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+	"price-comparison-api/internal/config"
+)
+
+func TestBackoff(t *testing.T) {
+	p := NewPolicy(config.RetryConfig{Backoff: 100 * time.Millisecond, MaxBackoff: time.Second})
+	for _, tc := range []struct {
+		attempt int
+		base    time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{10, time.Second},
+	} {
+		// Jitter adds up to half the base
+		for i := 0; i < 50; i++ {
+			if got := p.backoff(tc.attempt); got < tc.base || got > tc.base+tc.base/2 {
+				t.Fatalf("backoff(%d) = %v, want %v to %v", tc.attempt, got, tc.base, tc.base+tc.base/2)
+			}
+		}
+	}
+
+	if got := NewPolicy(config.RetryConfig{}).backoff(3); got != 0 {
+		t.Errorf("backoff without a configured backoff = %v, want 0", got)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	p := NewPolicy(config.RetryConfig{Statuses: []int{429, 503}})
+	for _, tc := range []struct {
+		status int
+		err    error
+		want   bool
+	}{
+		{503, errors.New("Service Unavailable"), true},
+		{429, errors.New("Too Many Requests"), true},
+		{404, errors.New("Not Found"), false},
+		{403, errors.New("Forbidden"), false},
+		{0, errors.New("connection reset by peer"), true},
+		{0, context.DeadlineExceeded, true},
+		{0, context.Canceled, false},
+	} {
+		if got := p.retryable(tc.status, tc.err); got != tc.want {
+			t.Errorf("retryable(%d, %v) = %v, want %v", tc.status, tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestCooldown(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		fetches []bool
+		cooling bool
+	}{
+		{"below the threshold", []bool{false, false}, false},
+		{"at the threshold", []bool{false, false, false}, true},
+		{"a success resets the count", []bool{false, false, true, false, false}, false},
+		{"failures after a success", []bool{true, false, false, false}, true},
+		{"successes only", []bool{true, true, true}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := NewPolicy(config.RetryConfig{FailureThreshold: 3, Cooldown: time.Minute})
+			for _, ok := range tc.fetches {
+				p.record("amazon.com", ok)
+			}
+			if _, cooling := p.cooling("amazon.com"); cooling != tc.cooling {
+				t.Errorf("cooling = %v, want %v", cooling, tc.cooling)
+			}
+			if _, cooling := p.cooling("ebay.com"); cooling {
+				t.Error("another domain is cooling down")
+			}
+		})
+	}
+
+	t.Run("ends", func(t *testing.T) {
+		p := NewPolicy(config.RetryConfig{FailureThreshold: 1, Cooldown: 20 * time.Millisecond})
+		if !p.record("amazon.com", false) {
+			t.Fatal("cooldown didn't start")
+		}
+		time.Sleep(30 * time.Millisecond)
+		if _, cooling := p.cooling("amazon.com"); cooling {
+			t.Error("still cooling after the cooldown")
+		}
+	})
+
+	t.Run("off without a threshold", func(t *testing.T) {
+		p := NewPolicy(config.RetryConfig{Cooldown: time.Minute})
+		for i := 0; i < 10; i++ {
+			p.record("amazon.com", false)
+		}
+		if _, cooling := p.cooling("amazon.com"); cooling {
+			t.Error("cooling down without a failure threshold")
+		}
+	})
+}
+
+// flakyServer answers 503 to the first failures requests, then 200.
+func flakyServer(t *testing.T, failures int32) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("<html></html>"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+func TestVisit(t *testing.T) {
+	cfg := config.RetryConfig{
+		MaxAttempts:      3,
+		Backoff:          time.Millisecond,
+		MaxBackoff:       5 * time.Millisecond,
+		Statuses:         []int{503},
+		FailureThreshold: 2,
+		Cooldown:         time.Minute,
+	}
+	Configure(cfg)
+	t.Cleanup(func() { Configure(config.RetryConfig{MaxAttempts: 1}) })
+
+	for _, tc := range []struct {
+		name     string
+		failures int32
+		hits     int32
+		ok       bool
+	}{
+		{"first attempt", 0, 1, true},
+		{"after retries", 2, 3, true},
+		{"every attempt failed", 5, 3, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			Configure(cfg)
+			srv, hits := flakyServer(t, tc.failures)
+			c := colly.NewCollector()
+			Attach(c)
+
+			err := Visit(context.Background(), c, srv.URL+"/s?k=usb", nil)
+			if (err == nil) != tc.ok {
+				t.Errorf("Visit = %v, want success %v", err, tc.ok)
+			}
+			if got := hits.Load(); got != tc.hits {
+				t.Errorf("%d requests, want %d", got, tc.hits)
+			}
+		})
+	}
+
+	t.Run("cooling domain", func(t *testing.T) {
+		Configure(cfg)
+		srv, hits := flakyServer(t, 100)
+		c := colly.NewCollector()
+		Attach(c)
+
+		for _, path := range []string{"/a", "/b"} {
+			if err := Visit(context.Background(), c, srv.URL+path, nil); err == nil || errors.Is(err, ErrCoolingDown) {
+				t.Fatalf("Visit(%s) = %v, want the fetch's error", path, err)
+			}
+		}
+		before := hits.Load()
+		if err := Visit(context.Background(), c, srv.URL+"/c", nil); !errors.Is(err, ErrCoolingDown) {
+			t.Errorf("Visit after %d failed pages = %v, want ErrCoolingDown", cfg.FailureThreshold, err)
+		}
+		if hits.Load() != before {
+			t.Error("a page was fetched while the domain cooled down")
+		}
+	})
+}
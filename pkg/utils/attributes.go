@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Variant attributes read from product titles
+const (
+	AttributeStorage = "storage"
+	AttributeMemory  = "memory"
+	AttributeScreen  = "screen"
+	AttributeSize    = "size"
+	AttributeColor   = "color"
+)
+
+// colors are the color names recognized in titles, multi-word names first
+// so "Midnight Blue" isn't read as "Midnight".
+var colors = []string{
+	"space gray", "space grey", "space black", "midnight blue", "navy blue", "sky blue",
+	"rose gold", "jet black", "natural titanium", "black titanium", "white titanium",
+	"blue titanium", "desert titanium", "phantom black", "forest green", "light blue",
+	"dark blue", "dark green", "dark gray", "dark grey",
+	"midnight", "starlight", "graphite", "silver", "gold", "black", "white", "blue",
+	"red", "green", "pink", "purple", "yellow", "gray", "grey", "orange", "beige",
+	"brown", "navy", "teal", "lavender", "coral", "charcoal", "titanium",
+}
+
+// attributeRule reads one attribute: the value of the first match of
+// pattern, built by value from its submatches.
+type attributeRule struct {
+	key     string
+	pattern *regexp.Regexp
+	value   func(m []string) string
+}
+
+// attributeRules run in order, each on the title left by the ones before,
+// so "8GB RAM" is memory and the "256GB" after it storage.
+var attributeRules = []attributeRule{
+	{
+		key:     AttributeMemory,
+		pattern: regexp.MustCompile(`(?i)\b(\d{1,3})\s?gb\s*(?:of\s+)?(?:ram|memory|lpddr\d*x?|ddr\d*|unified memory)\b`),
+		value:   func(m []string) string { return m[1] + "gb" },
+	},
+	{
+		key:     AttributeStorage,
+		pattern: regexp.MustCompile(`(?i)\b(\d{1,4}(?:\.\d)?)\s?(gb|tb)\b`),
+		value:   func(m []string) string { return m[1] + strings.ToLower(m[2]) },
+	},
+	{
+		// 55", 65-inch, 15.6 inches, 27”; a bare "in" is left alone, since
+		// "10 in 1" is no screen
+		key:     AttributeScreen,
+		pattern: regexp.MustCompile(`(?i)\b(\d{2}(?:\.\d)?)\s?(?:"|”|''|-?\s?inch(?:es)?\b)`),
+		value:   func(m []string) string { return m[1] + "in" },
+	},
+	{
+		// "Size M", "size: 10", or a clothing size that can't be anything
+		// else: XS, XL, XXL, 2XL
+		key:     AttributeSize,
+		pattern: regexp.MustCompile(`(?i)\bsize\s*:?\s*(x{0,3}[sml]|\d?x{1,3}l|\d{1,2}(?:\.5)?)\b|\b(x{1,3}[sl]|[2-5]xl)\b`),
+		value: func(m []string) string {
+			size := strings.ToLower(m[1] + m[2])
+			if n := strings.Count(size, "x"); n > 1 && strings.HasPrefix(size, "xx") {
+				size = string(rune('0'+n)) + "x" + size[n:]
+			}
+			return size
+		},
+	},
+	{
+		key:     AttributeColor,
+		pattern: colorPattern(),
+		value: func(m []string) string {
+			words := strings.FieldsFunc(strings.ToLower(m[1]), func(r rune) bool { return r == ' ' || r == '-' })
+			return strings.Replace(strings.Join(words, " "), "grey", "gray", 1)
+		},
+	},
+}
+
+func colorPattern() *regexp.Regexp {
+	names := make([]string, len(colors))
+	for i, c := range colors {
+		names[i] = strings.ReplaceAll(regexp.QuoteMeta(c), " ", `[\s-]+`)
+	}
+	return regexp.MustCompile(`(?i)\b(` + strings.Join(names, "|") + `)\b`)
+}
+
+// ParseAttributes reads the variant attributes a product title names, such
+// as storage "256gb", color "midnight blue" or size "xl", or returns nil
+// when it names none. Values are lowercased and written one way, so
+// "128 GB" and "128GB" are the same storage.
+func ParseAttributes(title string) map[string]string {
+	_, attrs := SplitVariant(title)
+	return attrs
+}
+
+// SplitVariant splits a title into its base, the title without the text
+// naming its variant, and the variant's attributes, so listings of one
+// product can be matched by their base while telling a 128GB phone from the
+// 512GB one.
+func SplitVariant(title string) (base string, attrs map[string]string) {
+	base = title
+	for _, rule := range attributeRules {
+		m := rule.pattern.FindStringSubmatch(base)
+		if m == nil {
+			continue
+		}
+		if attrs == nil {
+			attrs = make(map[string]string)
+		}
+		attrs[rule.key] = rule.value(m)
+		base = rule.pattern.ReplaceAllString(base, " ")
+	}
+	return base, attrs
+}
+
+// VariantKey writes attrs as one sorted string, "color=black storage=128gb",
+// for use in match keys.
+func VariantKey(attrs map[string]string) string {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + "=" + attrs[k]
+	}
+	return strings.Join(parts, " ")
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"math"
 	"regexp"
 	"strconv"
 	"strings"
@@ -32,23 +33,92 @@ func ParsePrice(priceStr string) float64 {
 	return price
 }
 
-// ParseRating converts rating string to float64
-func ParseRating(ratingStr string) float64 {
-	if ratingStr == "" {
-		return 0
+// RatingScale is the scale every rating is normalized to.
+const RatingScale = 5
+
+var (
+	// ratingOutOf matches "4.5 out of 5", "4.5/5", "4,5 de 5" and "9 of 10"
+	ratingOutOf = regexp.MustCompile(`(\d+(?:[.,]\d+)?)\s*(?:/|out of|of|de|von|sur|su)\s*(\d+(?:[.,]\d+)?)`)
+	// ratingPercent matches "92%"
+	ratingPercent = regexp.MustCompile(`(\d+(?:[.,]\d+)?)\s*%`)
+	ratingNumber  = regexp.MustCompile(`\d+(?:[.,]\d+)?`)
+)
+
+// NormalizeRating reads a rating written as "4.5 out of 5 stars", "4.5/5",
+// "92%" or a bare number and returns it on the 0-5 scale, rounded to two
+// decimals, with the scale it was given on. A bare number is taken to be
+// out of 5, 10 or 100, whichever is the smallest it fits. It returns 0, 0
+// when ratingStr holds no rating.
+func NormalizeRating(ratingStr string) (value, scale float64) {
+	lower := strings.ToLower(ratingStr)
+	switch {
+	case ratingOutOf.MatchString(lower):
+		m := ratingOutOf.FindStringSubmatch(lower)
+		value, scale = parseDecimal(m[1]), parseDecimal(m[2])
+	case ratingPercent.MatchString(lower):
+		value, scale = parseDecimal(ratingPercent.FindStringSubmatch(lower)[1]), 100
+	default:
+		value = parseDecimal(ratingNumber.FindString(lower))
+		switch {
+		case value <= 5:
+			scale = 5
+		case value <= 10:
+			scale = 10
+		default:
+			scale = 100
+		}
+	}
+	if value <= 0 || scale <= 0 || value > scale {
+		return 0, 0
 	}
+	return math.Round(value/scale*RatingScale*100) / 100, scale
+}
 
-	// Extract numeric rating (e.g., "4.5 out of 5 stars" -> 4.5)
-	re := regexp.MustCompile(`[\d.]+`)
-	match := re.FindString(ratingStr)
-	if match == "" {
+// reviewCountNumber matches "1,234", "1.234", "(56)" and "2.5K"
+var reviewCountNumber = regexp.MustCompile(`(\d[\d.,'\x{00a0}\x{202f}]*)\s*([km]\b)?`)
+
+// thousands drops the thousands separators that can't be decimal points
+var thousands = strings.NewReplacer("'", "", "\u00a0", "", "\u202f", "")
+
+// ParseReviewCount reads a review count such as "1,234", "(56)",
+// "1.234 Bewertungen" or "2.5K ratings". A number in parentheses wins over
+// the others, such as a rating before it; otherwise the last one is taken.
+// Counts of items sold, which some marketplaces show instead, return 0.
+func ParseReviewCount(reviewsStr string) int {
+	lower := strings.ToLower(reviewsStr)
+	if strings.Contains(lower, "sold") {
 		return 0
 	}
-
-	rating, err := strconv.ParseFloat(match, 64)
+	if open := strings.Index(lower, "("); open >= 0 {
+		if end := strings.Index(lower[open:], ")"); end > 0 {
+			lower = lower[open+1 : open+end]
+		}
+	}
+	matches := reviewCountNumber.FindAllStringSubmatch(lower, -1)
+	if len(matches) == 0 {
+		return 0
+	}
+	m := matches[len(matches)-1]
+	digits := strings.TrimRight(m[1], ".,'\u00a0\u202f")
+	if m[2] != "" {
+		multiplier := 1000.0
+		if m[2] == "m" {
+			multiplier = 1000000
+		}
+		return int(math.Round(parseDecimal(thousands.Replace(digits)) * multiplier))
+	}
+	count, err := strconv.Atoi(thousands.Replace(strings.NewReplacer(",", "", ".", "").Replace(digits)))
 	if err != nil {
 		return 0
 	}
+	return count
+}
 
-	return rating
+// parseDecimal parses a number that may use a decimal comma, or returns 0.
+func parseDecimal(s string) float64 {
+	f, err := strconv.ParseFloat(strings.Replace(s, ",", ".", 1), 64)
+	if err != nil {
+		return 0
+	}
+	return f
 }